@@ -0,0 +1,342 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package account
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+
+	"github.com/elastos/Elastos.ELA/account/hdwallet"
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/crypto"
+	"github.com/elastos/Elastos.ELA/utils"
+)
+
+const (
+	// BackupVersion is the version of the backup file format, the backup
+	// carries the HD wallet data and the derivation paths of the accounts.
+	BackupVersion = "1.1.0"
+
+	// backupVersionAccounts is the version of the backup file format which
+	// carries the accounts only.
+	backupVersionAccounts = "1.0.0"
+)
+
+// BackupFile is the content of a backup file, the accounts are encrypted by
+// AES-GCM with the key derived from the password.
+type BackupFile struct {
+	Version string
+	KDF     *KDFParams
+	Data    string
+}
+
+// BackupAccount is an account in a backup file.  The private key is empty
+// for the accounts without private keys, such as the multi-signature and
+// Schnorr aggregate accounts, which are restored by the redeem script.
+type BackupAccount struct {
+	Address      string
+	ProgramHash  string
+	RedeemScript string
+	PrivateKey   string
+	Type         string
+	Path         string `json:",omitempty"`
+}
+
+// BackupHD is the HD wallet data in a backup file.  The seed is kept along
+// with the mnemonic, as it can not be recovered from the mnemonic without the
+// BIP39 passphrase.
+type BackupHD struct {
+	Mnemonic  string
+	Seed      string
+	Account   uint32
+	NextIndex uint32
+}
+
+// Backup is the content of a backup file after decrypted.
+type Backup struct {
+	HD       *BackupHD `json:",omitempty"`
+	Accounts []BackupAccount
+}
+
+// Backup writes all accounts of the wallet into a single backup file at the
+// given path, which is encrypted by the password with the key derivation
+// function of the given name.
+func (cl *Client) Backup(path string, password []byte, kdf string) error {
+	if utils.FileExisted(path) {
+		return errors.New(path + " file already exist")
+	}
+
+	storeAccounts, err := cl.LoadAccountData()
+	if err != nil {
+		return err
+	}
+	backup := Backup{
+		Accounts: make([]BackupAccount, 0, len(storeAccounts)),
+	}
+	if cl.IsHD() {
+		mnemonic, err := cl.Mnemonic()
+		if err != nil {
+			return err
+		}
+		seed, err := cl.decryptHDData(func(hd *HDData) string {
+			return hd.Seed
+		})
+		if err != nil {
+			return err
+		}
+		backup.HD = &BackupHD{
+			Mnemonic:  mnemonic,
+			Seed:      common.BytesToHexString(seed),
+			Account:   cl.hd.Account,
+			NextIndex: cl.hd.NextIndex,
+		}
+		common.ClearBytes(seed)
+	}
+	for _, a := range storeAccounts {
+		account := BackupAccount{
+			Address:      a.Address,
+			ProgramHash:  a.ProgramHash,
+			RedeemScript: a.RedeemScript,
+			Type:         a.Type,
+			Path:         a.Path,
+		}
+		if a.PrivateKeyEncrypted != "" {
+			encrypted, err := common.HexStringToBytes(a.PrivateKeyEncrypted)
+			if err != nil {
+				return err
+			}
+			keyPair, err := cl.DecryptPrivateKey(encrypted)
+			if err != nil {
+				return err
+			}
+			account.PrivateKey = common.BytesToHexString(keyPair[64:96])
+			common.ClearBytes(keyPair)
+		}
+		backup.Accounts = append(backup.Accounts, account)
+	}
+
+	plaintext, err := json.Marshal(&backup)
+	if err != nil {
+		return err
+	}
+	defer common.ClearBytes(plaintext)
+
+	params, err := NewKDFParams(kdf)
+	if err != nil {
+		return err
+	}
+	key, err := params.DeriveKey(password)
+	if err != nil {
+		return err
+	}
+	defer common.ClearBytes(key)
+	encrypted, err := crypto.AesGcmEncrypt(plaintext, key)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(&BackupFile{
+		Version: BackupVersion,
+		KDF:     params,
+		Data:    common.BytesToHexString(encrypted),
+	})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// ReadBackup reads and decrypts the backup file at the given path by the
+// password.  The backups of the former version only carry the accounts.
+func ReadBackup(path string, password []byte) (*Backup, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file BackupFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, errors.New("invalid backup file")
+	}
+	if file.Version != BackupVersion && file.Version != backupVersionAccounts {
+		return nil, errors.New("unknown backup version: " + file.Version)
+	}
+	if file.KDF == nil {
+		return nil, errors.New("invalid backup file, kdf params not found")
+	}
+
+	key, err := file.KDF.DeriveKey(password)
+	if err != nil {
+		return nil, err
+	}
+	defer common.ClearBytes(key)
+	encrypted, err := common.HexStringToBytes(file.Data)
+	if err != nil {
+		return nil, errors.New("invalid backup file")
+	}
+	plaintext, err := crypto.AesGcmDecrypt(encrypted, key)
+	if err != nil {
+		return nil, errors.New("password wrong or backup file modified")
+	}
+	defer common.ClearBytes(plaintext)
+
+	var backup Backup
+	if file.Version == backupVersionAccounts {
+		err = json.Unmarshal(plaintext, &backup.Accounts)
+	} else {
+		err = json.Unmarshal(plaintext, &backup)
+	}
+	if err != nil {
+		return nil, errors.New("invalid backup accounts")
+	}
+	return &backup, nil
+}
+
+// Restore restores the accounts in the backup file into the wallet at the
+// given path, the wallet is created if it does not exist.  Both of the
+// backup file and the wallet are protected by the password, and the
+// accounts already in the wallet are skipped.  The HD wallet data is only
+// restored into a created wallet, otherwise the HD accounts are restored by
+// their private keys without the derivation paths.
+func Restore(walletPath string, backupPath string, password []byte) (*Client, error) {
+	backup, err := ReadBackup(backupPath, password)
+	if err != nil {
+		return nil, err
+	}
+	accounts := backup.Accounts
+
+	// Restore the main account first, so it is still the main account of a
+	// new wallet.
+	for i, a := range accounts {
+		if a.Type == MAINACCOUNT {
+			accounts[0], accounts[i] = accounts[i], accounts[0]
+			break
+		}
+	}
+
+	exist := utils.FileExisted(walletPath)
+	client := NewClient(walletPath, password, !exist)
+	if client == nil {
+		return nil, errors.New("open wallet failed")
+	}
+	restoreHD := backup.HD != nil && !exist
+	if restoreHD {
+		if err := client.restoreHD(backup.HD); err != nil {
+			return nil, err
+		}
+	}
+	existing := make(map[string]struct{})
+	if exist {
+		storeAccounts, err := client.LoadAccountData()
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range storeAccounts {
+			existing[a.ProgramHash] = struct{}{}
+		}
+	}
+
+	for _, a := range accounts {
+		if _, ok := existing[a.ProgramHash]; ok {
+			continue
+		}
+		programHashBytes, err := common.HexStringToBytes(a.ProgramHash)
+		if err != nil {
+			return nil, err
+		}
+		programHash, err := common.Uint168FromBytes(programHashBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		if restoreHD && a.Path != "" {
+			if err := client.restoreHDAccount(programHash, a.Path); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if a.PrivateKey != "" {
+			privateKey, err := common.HexStringToBytes(a.PrivateKey)
+			if err != nil {
+				return nil, err
+			}
+			account, err := NewAccountWithPrivateKey(privateKey)
+			if err != nil {
+				return nil, err
+			}
+			if account.ProgramHash != *programHash {
+				return nil, errors.New("private key mismatch with address " +
+					a.Address)
+			}
+			if err := client.SaveAccount(account); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		redeemScript, err := common.HexStringToBytes(a.RedeemScript)
+		if err != nil {
+			return nil, err
+		}
+		if err := client.SaveAccountData(programHash, redeemScript, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := client.LoadAccounts(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// restoreHD saves the HD wallet data of the backup into the wallet.
+func (cl *Client) restoreHD(hd *BackupHD) error {
+	seed, err := common.HexStringToBytes(hd.Seed)
+	if err != nil {
+		return errors.New("invalid HD wallet seed")
+	}
+	defer common.ClearBytes(seed)
+	if err := cl.initHD(hd.Mnemonic, seed); err != nil {
+		return err
+	}
+
+	data, err := cl.LoadFileData()
+	if err != nil {
+		return err
+	}
+	data.HD.Account = hd.Account
+	data.HD.NextIndex = hd.NextIndex
+	if err := cl.SaveFileData(data); err != nil {
+		return err
+	}
+	cl.hd = data.HD
+	return nil
+}
+
+// restoreHDAccount derives the account of the derivation path from the
+// restored HD wallet data and saves it along with the path.
+func (cl *Client) restoreHDAccount(programHash *common.Uint168,
+	path string) error {
+	p, err := hdwallet.ParsePath(path)
+	if err != nil {
+		return err
+	}
+	if len(p) != len(hdwallet.NewPath(0, 0, 0)) {
+		return errors.New("invalid derivation path " + path)
+	}
+	change, index := p[3], p[4]
+	account, err := cl.DeriveHDAccount(change, index)
+	if err != nil {
+		return err
+	}
+	if account.ProgramHash != *programHash ||
+		hdwallet.NewPath(cl.hd.Account, change, index).String() != p.String() {
+		return errors.New("derivation path " + path +
+			" mismatch with the account")
+	}
+	return cl.saveHDAccount(account, change, index)
+}
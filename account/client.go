@@ -10,11 +10,9 @@ import (
 	"crypto/sha256"
 	"errors"
 	"fmt"
-	"math/rand"
 	"os"
 	"sort"
 	"sync"
-	"time"
 
 	"github.com/elastos/Elastos.ELA/common"
 	"github.com/elastos/Elastos.ELA/core/contract"
@@ -30,8 +28,10 @@ type Client struct {
 	mu sync.Mutex
 
 	path      string
+	version   string
 	iv        []byte
 	masterKey []byte
+	hd        *HDData
 
 	mainAccount common.Uint160
 	accounts    map[common.Uint160]*Account
@@ -75,7 +75,12 @@ func Add(path string, password []byte) (*Client, error) {
 	if client == nil {
 		return nil, errors.New("add account failed")
 	}
-	_, err := client.CreateAccount()
+	var err error
+	if client.IsHD() {
+		_, err = client.CreateHDAccount()
+	} else {
+		_, err = client.CreateAccount()
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -193,72 +198,94 @@ func NewClient(path string, password []byte, create bool) *Client {
 		FileStore: FileStore{path: path},
 	}
 
-	passwordKey := crypto.ToAesKey(password)
 	if create {
-		//create new client
-		client.iv = make([]byte, 16)
-		client.masterKey = make([]byte, 32)
-
-		//generate random number for iv/masterkey
-		r := rand.New(rand.NewSource(time.Now().UnixNano()))
-		for i := 0; i < 16; i++ {
-			client.iv[i] = byte(r.Intn(256))
-		}
-		for i := 0; i < 32; i++ {
-			client.masterKey[i] = byte(r.Intn(256))
-		}
-
-		//new client store (build DB)
-		client.BuildDatabase(path)
-
-		if err := client.SaveStoredData("Version", []byte(KeystoreVersion)); err != nil {
-			fmt.Println("error: failed to save Version")
+		// create new client with keystore of version 2
+		var err error
+		client.version = KeystoreVersion2
+		client.masterKey, err = newMasterKey()
+		if err != nil {
+			fmt.Println("error: failed to generate master key", err.Error())
 			return nil
 		}
-
-		pwdhash := sha256.Sum256(passwordKey)
-		if err := client.SaveStoredData("PasswordHash", pwdhash[:]); err != nil {
-			fmt.Println("error: failed to save PasswordHash")
+		params, err := NewKDFParams(KDFScrypt)
+		if err != nil {
+			fmt.Println("error: failed to generate kdf params", err.Error())
 			return nil
 		}
-		if err := client.SaveStoredData("IV", client.iv[:]); err != nil {
-			fmt.Println("error: failed to save IV")
+		encryptedMasterKey, err := encryptMasterKey(client.masterKey, password,
+			params)
+		if err != nil {
+			fmt.Println("error: failed to encrypt the master key", err.Error())
 			return nil
 		}
 
-		aesmk, err := crypto.AesEncrypt(client.masterKey[:], passwordKey, client.iv)
+		//new client store (build DB)
+		client.BuildDatabase(path)
+
+		err = client.SaveFileData(&FileData{
+			Version:   KeystoreVersion2,
+			MasterKey: common.BytesToHexString(encryptedMasterKey),
+			KDF:       params,
+		})
 		if err != nil {
-			fmt.Println("error: failed to encrypt the passoword", err.Error())
-			return nil
-		}
-		if err := client.SaveStoredData("MasterKey", aesmk); err != nil {
-			fmt.Println("error: failed to save MasterKey")
+			fmt.Println("error: failed to save keystore")
 			return nil
 		}
+		return client
+	}
 
-	} else {
-		if ok := client.verifyPasswordKey(passwordKey); !ok {
-			return nil
-		}
-		var err error
-		client.iv, err = client.LoadStoredData("IV")
-		if err != nil {
-			fmt.Println("error: failed to load iv")
+	data, err := client.LoadFileData()
+	if err != nil {
+		fmt.Println("error: failed to load keystore")
+		return nil
+	}
+	client.version = data.Version
+	if client.version == KeystoreVersion2 {
+		if data.KDF == nil {
+			fmt.Println("error: failed to load kdf params")
 			return nil
 		}
-		encryptedMasterKey, err := client.LoadStoredData("MasterKey")
+		encryptedMasterKey, err := common.HexStringToBytes(data.MasterKey)
 		if err != nil {
 			fmt.Println("error: failed to load master key")
 			return nil
 		}
-		client.masterKey, err = crypto.AesDecrypt(encryptedMasterKey, passwordKey, client.iv)
+		client.masterKey, err = decryptMasterKey(encryptedMasterKey, password,
+			data.KDF)
 		if err != nil {
-			fmt.Println("error: failed to decrypt master key")
+			fmt.Println("error:", err.Error())
 			return nil
 		}
+		client.hd = data.HD
+		return client
+	}
+
+	passwordKey := crypto.ToAesKey(password)
+	if ok := client.verifyPasswordKey(passwordKey); !ok {
+		return nil
+	}
+	client.iv, err = client.LoadStoredData("IV")
+	if err != nil {
+		fmt.Println("error: failed to load iv")
+		return nil
+	}
+	encryptedMasterKey, err := client.LoadStoredData("MasterKey")
+	if err != nil {
+		fmt.Println("error: failed to load master key")
+		return nil
+	}
+	client.masterKey, err = crypto.AesDecrypt(encryptedMasterKey, passwordKey, client.iv)
+	if err != nil {
+		fmt.Println("error: failed to decrypt master key")
+		return nil
 	}
 	common.ClearBytes(passwordKey)
 
+	// upgrade the legacy keystore now the password is known
+	if err := client.migrate(password); err != nil {
+		fmt.Println("warning: failed to upgrade keystore,", err.Error())
+	}
+
 	return client
 }
 
@@ -287,6 +314,24 @@ func (cl *Client) CreateMultiSigAccount(m int, pubKeys []*crypto.PublicKey) (*Ac
 	return account, nil
 }
 
+// CreateSchnorrAggregateAccount creates a Schnorr aggregate account of the
+// given accounts then save it, the private keys of the aggregate account
+// are the ones of the given accounts.
+func (cl *Client) CreateSchnorrAggregateAccount(accounts []*Account) (*SchnorAccount, error) {
+	if len(accounts) == 0 {
+		return nil, errors.New("no account to aggregate")
+	}
+	account := NewSchnorrAggregateAccount(accounts)
+	if account.ProgramHash == nil {
+		return nil, errors.New("create Schnorr aggregate account failed")
+	}
+	if err := cl.SaveAccountData(account.ProgramHash, account.RedeemScript, nil); err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
 // SaveAccount saves a Account to memory and db
 func (cl *Client) SaveAccount(ac *Account) error {
 	cl.mu.Lock()
@@ -295,10 +340,27 @@ func (cl *Client) SaveAccount(ac *Account) error {
 	// save Account to memory
 	cl.accounts[ac.ProgramHash.ToCodeHash()] = ac
 
+	encryptedPrivateKey, err := cl.encryptKeyPair(ac)
+	if err != nil {
+		return err
+	}
+
+	// save Account keys to db
+	err = cl.SaveAccountData(&ac.ProgramHash, ac.RedeemScript, encryptedPrivateKey)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// encryptKeyPair encrypts the key pair of the account, which is the 64 bytes
+// public key without prefix followed by the 32 bytes private key.
+func (cl *Client) encryptKeyPair(ac *Account) ([]byte, error) {
 	decryptedPrivateKey := make([]byte, 96)
 	temp, err := ac.PublicKey.EncodePoint(false)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	for i := 1; i <= 64; i++ {
 		decryptedPrivateKey[i-1] = temp[i]
@@ -307,18 +369,8 @@ func (cl *Client) SaveAccount(ac *Account) error {
 		decryptedPrivateKey[96+i-len(ac.PrivKey())] = ac.PrivKey()[i]
 	}
 	encryptedPrivateKey, err := cl.EncryptPrivateKey(decryptedPrivateKey)
-	if err != nil {
-		return err
-	}
 	common.ClearBytes(decryptedPrivateKey)
-
-	// save Account keys to db
-	err = cl.SaveAccountData(&ac.ProgramHash, ac.RedeemScript, encryptedPrivateKey)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return encryptedPrivateKey, err
 }
 
 func (cl *Client) GetAccounts() []*Account {
@@ -398,6 +450,10 @@ func (cl *Client) LoadAccounts() error {
 }
 
 func (cl *Client) EncryptPrivateKey(prikey []byte) ([]byte, error) {
+	if cl.version == KeystoreVersion2 {
+		return crypto.AesGcmEncrypt(prikey, cl.masterKey)
+	}
+
 	enc, err := crypto.AesEncrypt(prikey, cl.masterKey, cl.iv)
 	if err != nil {
 		return nil, err
@@ -410,6 +466,16 @@ func (cl *Client) DecryptPrivateKey(prikey []byte) ([]byte, error) {
 	if prikey == nil {
 		return nil, errors.New("the private key is nil")
 	}
+	if cl.version == KeystoreVersion2 {
+		dec, err := crypto.AesGcmDecrypt(prikey, cl.masterKey)
+		if err != nil {
+			return nil, err
+		}
+		if len(dec) != 96 {
+			return nil, errors.New("the len of private key is not 96bytes")
+		}
+		return dec, nil
+	}
 	if len(prikey) != 96 {
 		return nil, errors.New("the len of private key is not 96bytes")
 	}
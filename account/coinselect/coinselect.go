@@ -0,0 +1,255 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package coinselect
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/elastos/Elastos.ELA/common"
+)
+
+const (
+	// BranchAndBoundName is the name of the branch-and-bound strategy.
+	BranchAndBoundName = "bnb"
+
+	// LargestFirstName is the name of the largest-first strategy.
+	LargestFirstName = "largest"
+
+	// SmallestFirstName is the name of the smallest-first strategy.
+	SmallestFirstName = "smallest"
+
+	// RandomName is the name of the random strategy.
+	RandomName = "random"
+
+	// DefaultMaxTries is the max number of branches visited by the
+	// branch-and-bound search before giving up.
+	DefaultMaxTries = 100000
+)
+
+var (
+	// ErrInsufficientFunds is returned when the candidates can not cover
+	// the target amount.
+	ErrInsufficientFunds = errors.New("available token is not enough")
+
+	// ErrNoExactMatch is returned by branch-and-bound search when no
+	// selection within the cost of change is found.
+	ErrNoExactMatch = errors.New("no exact match found")
+)
+
+// Coin is a candidate of the coin selection, Index refers to the position
+// of the coin in the original candidates list.
+type Coin struct {
+	Index int
+	Value common.Fixed64
+}
+
+// Strategy selects coins from the candidates to cover the target amount.
+type Strategy interface {
+	Select(candidates []Coin, target common.Fixed64) ([]Coin, error)
+}
+
+// BranchAndBound searches a selection of which the total amount is within
+// [target, target + CostOfChange], so no change output is needed.  If no
+// such selection is found, it falls back to the Fallback strategy.
+type BranchAndBound struct {
+	CostOfChange common.Fixed64
+	MaxTries     int
+	Fallback     Strategy
+}
+
+func (s *BranchAndBound) Select(candidates []Coin,
+	target common.Fixed64) ([]Coin, error) {
+	selected, err := s.search(candidates, target)
+	if err == ErrNoExactMatch && s.Fallback != nil {
+		return s.Fallback.Select(candidates, target)
+	}
+	return selected, err
+}
+
+// search walks the binary tree of including or excluding each coin in the
+// descending order of value, and prunes a branch once it exceeds the upper
+// bound or the rest coins can not reach the target.
+func (s *BranchAndBound) search(candidates []Coin,
+	target common.Fixed64) ([]Coin, error) {
+	coins := sortedCoins(candidates, func(a, b Coin) bool {
+		return a.Value > b.Value
+	})
+	var available common.Fixed64
+	for _, c := range coins {
+		available += c.Value
+	}
+	if available < target {
+		return nil, ErrInsufficientFunds
+	}
+
+	maxTries := s.MaxTries
+	if maxTries <= 0 {
+		maxTries = DefaultMaxTries
+	}
+	upper := target + s.CostOfChange
+
+	var best []bool
+	var bestValue common.Fixed64
+	included := make([]bool, len(coins))
+	var value common.Fixed64
+	depth := 0
+	for tries := 0; tries < maxTries; tries++ {
+		backtrack := false
+		if value+available < target || value > upper ||
+			best != nil && value > bestValue {
+			backtrack = true
+		} else if value >= target {
+			if best == nil || value < bestValue {
+				best = append(best[:0:0], included...)
+				bestValue = value
+			}
+			if value == target {
+				break
+			}
+			backtrack = true
+		}
+
+		if backtrack {
+			// walk back to the last included coin and exclude it
+			for depth > 0 && !included[depth-1] {
+				depth--
+				available += coins[depth].Value
+			}
+			if depth == 0 {
+				break
+			}
+			depth--
+			included[depth] = false
+			value -= coins[depth].Value
+			depth++
+			continue
+		}
+		if depth == len(coins) {
+			continue
+		}
+
+		// include the coin of current depth first
+		available -= coins[depth].Value
+		included[depth] = true
+		value += coins[depth].Value
+		depth++
+	}
+
+	if best == nil {
+		return nil, ErrNoExactMatch
+	}
+	selected := make([]Coin, 0)
+	for i, in := range best {
+		if in {
+			selected = append(selected, coins[i])
+		}
+	}
+	return selected, nil
+}
+
+// LargestFirst selects the coins in the descending order of value, which
+// results in the fewest inputs.
+type LargestFirst struct{}
+
+func (s *LargestFirst) Select(candidates []Coin,
+	target common.Fixed64) ([]Coin, error) {
+	return accumulate(sortedCoins(candidates, func(a, b Coin) bool {
+		return a.Value > b.Value
+	}), target)
+}
+
+// SmallestFirst selects the coins in the ascending order of value, which
+// spends the dust coins first.
+type SmallestFirst struct{}
+
+func (s *SmallestFirst) Select(candidates []Coin,
+	target common.Fixed64) ([]Coin, error) {
+	return accumulate(sortedCoins(candidates, func(a, b Coin) bool {
+		return a.Value < b.Value
+	}), target)
+}
+
+// Random selects the coins in random order, so the selection does not
+// reveal which coins belong to the same owner by their values.
+type Random struct {
+	Rand *rand.Rand
+}
+
+func (s *Random) Select(candidates []Coin,
+	target common.Fixed64) ([]Coin, error) {
+	coins := append([]Coin(nil), candidates...)
+	shuffle := rand.Shuffle
+	if s.Rand != nil {
+		shuffle = s.Rand.Shuffle
+	}
+	shuffle(len(coins), func(i, j int) {
+		coins[i], coins[j] = coins[j], coins[i]
+	})
+	return accumulate(coins, target)
+}
+
+var (
+	mtx        sync.RWMutex
+	strategies = map[string]Strategy{
+		BranchAndBoundName: &BranchAndBound{Fallback: &LargestFirst{}},
+		LargestFirstName:   &LargestFirst{},
+		SmallestFirstName:  &SmallestFirst{},
+		RandomName:         &Random{},
+	}
+)
+
+// Register adds a strategy by name, or replaces the registered one.
+func Register(name string, strategy Strategy) {
+	mtx.Lock()
+	strategies[strings.ToLower(name)] = strategy
+	mtx.Unlock()
+}
+
+// GetStrategy returns the strategy registered by name.
+func GetStrategy(name string) (Strategy, error) {
+	mtx.RLock()
+	defer mtx.RUnlock()
+	strategy, ok := strategies[strings.ToLower(name)]
+	if !ok {
+		return nil, errors.New("unknown coin selection strategy " + name)
+	}
+	return strategy, nil
+}
+
+// Names returns the names of registered strategies in order.
+func Names() []string {
+	mtx.RLock()
+	defer mtx.RUnlock()
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedCoins(candidates []Coin, less func(a, b Coin) bool) []Coin {
+	coins := append([]Coin(nil), candidates...)
+	sort.SliceStable(coins, func(i, j int) bool {
+		return less(coins[i], coins[j])
+	})
+	return coins
+}
+
+func accumulate(coins []Coin, target common.Fixed64) ([]Coin, error) {
+	var total common.Fixed64
+	for i, c := range coins {
+		total += c.Value
+		if total >= target {
+			return coins[:i+1], nil
+		}
+	}
+	return nil, ErrInsufficientFunds
+}
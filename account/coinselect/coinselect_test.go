@@ -0,0 +1,114 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package coinselect
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newCoins(values ...common.Fixed64) []Coin {
+	coins := make([]Coin, 0, len(values))
+	for i, v := range values {
+		coins = append(coins, Coin{Index: i, Value: v})
+	}
+	return coins
+}
+
+func total(coins []Coin) common.Fixed64 {
+	var sum common.Fixed64
+	for _, c := range coins {
+		sum += c.Value
+	}
+	return sum
+}
+
+func TestBranchAndBound(t *testing.T) {
+	candidates := newCoins(7, 2, 9, 4, 13, 1)
+
+	strategy := &BranchAndBound{}
+	selected, err := strategy.Select(candidates, 15)
+	assert.NoError(t, err)
+	assert.Equal(t, common.Fixed64(15), total(selected))
+
+	selected, err = strategy.Select(candidates, 36)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, len(selected))
+
+	_, err = strategy.Select(candidates, 37)
+	assert.Equal(t, ErrInsufficientFunds, err)
+
+	// no exact match of 100 from coins of 30
+	candidates = newCoins(30, 30, 30, 30)
+	_, err = strategy.Select(candidates, 100)
+	assert.Equal(t, ErrNoExactMatch, err)
+
+	// the least excess within the cost of change
+	strategy.CostOfChange = 25
+	selected, err = strategy.Select(candidates, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, common.Fixed64(120), total(selected))
+
+	strategy = &BranchAndBound{Fallback: &LargestFirst{}}
+	selected, err = strategy.Select(newCoins(30, 50, 30, 30), 100)
+	assert.NoError(t, err)
+	assert.Equal(t, common.Fixed64(110), total(selected))
+	assert.Equal(t, common.Fixed64(50), selected[0].Value)
+
+	// the search gives up after max tries
+	strategy = &BranchAndBound{MaxTries: 3}
+	_, err = strategy.Select(newCoins(8, 8, 8, 8, 8, 8, 3), 27)
+	assert.Equal(t, ErrNoExactMatch, err)
+}
+
+func TestAccumulatedStrategies(t *testing.T) {
+	candidates := newCoins(5, 100, 1, 20, 3)
+
+	selected, err := (&LargestFirst{}).Select(candidates, 110)
+	assert.NoError(t, err)
+	assert.Equal(t, []Coin{{1, 100}, {3, 20}}, selected)
+
+	selected, err = (&SmallestFirst{}).Select(candidates, 8)
+	assert.NoError(t, err)
+	assert.Equal(t, []Coin{{2, 1}, {4, 3}, {0, 5}}, selected)
+
+	strategy := &Random{Rand: rand.New(rand.NewSource(1))}
+	for i := 0; i < 10; i++ {
+		selected, err = strategy.Select(candidates, 26)
+		assert.NoError(t, err)
+		assert.True(t, total(selected) >= 26)
+	}
+	assert.Equal(t, newCoins(5, 100, 1, 20, 3), candidates)
+
+	for _, strategy := range []Strategy{&LargestFirst{}, &SmallestFirst{},
+		&Random{}} {
+		_, err = strategy.Select(candidates, 130)
+		assert.Equal(t, ErrInsufficientFunds, err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	assert.Equal(t, []string{"bnb", "largest", "random", "smallest"}, Names())
+	strategy, err := GetStrategy("Largest")
+	assert.NoError(t, err)
+	assert.IsType(t, &LargestFirst{}, strategy)
+	_, err = GetStrategy("knapsack")
+	assert.Error(t, err)
+
+	Register("knapsack", &SmallestFirst{})
+	defer func() {
+		mtx.Lock()
+		delete(strategies, "knapsack")
+		mtx.Unlock()
+	}()
+	strategy, err = GetStrategy("knapsack")
+	assert.NoError(t, err)
+	assert.IsType(t, &SmallestFirst{}, strategy)
+}
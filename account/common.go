@@ -18,6 +18,7 @@ const (
 	SUBACCOUNT       = "sub-account"
 	KeystoreFileName = "keystore.dat"
 	KeystoreVersion  = "1.0.0"
+	KeystoreVersion2 = "2.0.0"
 
 	MaxSignalQueueLen = 5
 )
@@ -22,6 +22,7 @@ type AccountData struct {
 	RedeemScript        string
 	PrivateKeyEncrypted string
 	Type                string
+	Path                string `json:",omitempty"`
 }
 
 type FileData struct {
@@ -29,6 +30,8 @@ type FileData struct {
 	PasswordHash string
 	IV           string
 	MasterKey    string
+	KDF          *KDFParams `json:",omitempty"`
+	HD           *HDData    `json:",omitempty"`
 	Account      []AccountData
 }
 
@@ -238,6 +241,54 @@ func (cs *FileStore) LoadStoredData(name string) ([]byte, error) {
 	return nil, errors.New("can't find the key: " + name)
 }
 
+// LoadFileData loads the whole data of the keystore.
+func (cs *FileStore) LoadFileData() (*FileData, error) {
+	JSONData, err := cs.readDB()
+	if err != nil {
+		return nil, errors.New("error: reading db")
+	}
+	var data FileData
+	if err := json.Unmarshal(JSONData, &data); err != nil {
+		return nil, errors.New("error: unmarshal db")
+	}
+	if data.KDF != nil {
+		if err := data.KDF.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	cs.Lock()
+	cs.data = data
+	cs.Unlock()
+
+	data.Account = append([]AccountData(nil), data.Account...)
+	return &data, nil
+}
+
+// SaveFileData replaces the whole data of the keystore.  The data is written
+// to a temporary file which is renamed to the keystore then, so the keystore
+// will not be broken if the writing is interrupted.
+func (cs *FileStore) SaveFileData(data *FileData) error {
+	JSONBlob, err := json.Marshal(data)
+	if err != nil {
+		return errors.New("error: marshal db")
+	}
+
+	cs.Lock()
+	defer cs.Unlock()
+
+	tmpPath := cs.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, JSONBlob, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, cs.path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	cs.data = *data
+	return nil
+}
+
 func (cs *FileStore) SetPath(path string) {
 	cs.Lock()
 	defer cs.Unlock()
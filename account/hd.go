@@ -0,0 +1,261 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package account
+
+import (
+	"errors"
+
+	"github.com/elastos/Elastos.ELA/account/hdwallet"
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/crypto"
+)
+
+// DefaultGapLimit is the number of consecutive unused addresses to stop
+// scanning the accounts of HD wallet.
+const DefaultGapLimit = 20
+
+// HDData is the HD wallet data of the keystore, the mnemonic and the seed
+// are encrypted by AES-GCM with the master key.
+type HDData struct {
+	Mnemonic  string
+	Seed      string
+	Account   uint32
+	NextIndex uint32
+}
+
+// CreateHD creates a HD wallet from the mnemonic, or a new mnemonic of 12
+// words if it is empty, with an optional BIP39 passphrase.  The account of
+// index 0 on the external chain is the main account, and the mnemonic is
+// returned to be written down by user.
+func CreateHD(path string, password []byte, mnemonic string,
+	passphrase string) (*Client, string, error) {
+	if mnemonic == "" {
+		var err error
+		mnemonic, err = hdwallet.NewMnemonic(hdwallet.DefaultEntropyBits)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	mnemonic = hdwallet.NormalizeMnemonic(mnemonic)
+	seed, err := hdwallet.MnemonicToSeed(mnemonic, passphrase)
+	if err != nil {
+		return nil, "", err
+	}
+	defer common.ClearBytes(seed)
+
+	client, err := createClient(path, password, func(cl *Client) (*Account, error) {
+		if err := cl.initHD(mnemonic, seed); err != nil {
+			return nil, err
+		}
+		return cl.CreateHDAccount()
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return client, mnemonic, nil
+}
+
+// IsHD returns if the wallet is a HD wallet.
+func (cl *Client) IsHD() bool {
+	return cl.hd != nil
+}
+
+// Mnemonic returns the mnemonic of the HD wallet.
+func (cl *Client) Mnemonic() (string, error) {
+	mnemonic, err := cl.decryptHDData(func(hd *HDData) string {
+		return hd.Mnemonic
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(mnemonic), nil
+}
+
+// DeriveHDAccount derives the account of the given chain and address index
+// from the seed of the HD wallet, without saving it.
+func (cl *Client) DeriveHDAccount(change, index uint32) (*Account, error) {
+	seed, err := cl.decryptHDData(func(hd *HDData) string {
+		return hd.Seed
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer common.ClearBytes(seed)
+
+	master, err := hdwallet.NewMaster(seed)
+	if err != nil {
+		return nil, err
+	}
+	key, err := master.DerivePath(hdwallet.NewPath(cl.hd.Account, change, index))
+	if err != nil {
+		return nil, err
+	}
+	return NewAccountWithPrivateKey(key.PrivateKey())
+}
+
+// CreateHDAccount derives the account of the next address index on the
+// external chain then save it.
+func (cl *Client) CreateHDAccount() (*Account, error) {
+	if !cl.IsHD() {
+		return nil, errors.New("not a HD wallet")
+	}
+	for index := cl.hd.NextIndex; ; index++ {
+		account, err := cl.DeriveHDAccount(hdwallet.ExternalChain, index)
+		if err == hdwallet.ErrInvalidKey {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := cl.saveHDAccount(account, hdwallet.ExternalChain, index); err != nil {
+			return nil, err
+		}
+		return account, nil
+	}
+}
+
+// ScanHDAccounts derives the accounts on both of the external and internal
+// chains in batches of the gap limit, and asks isUsed which addresses of a
+// batch have been used.  Scanning of a chain stops when gap limit of
+// consecutive addresses are unused, and the used accounts not in the wallet
+// are saved and returned.
+func (cl *Client) ScanHDAccounts(gapLimit uint32,
+	isUsed func(addresses []string) (map[string]bool, error)) ([]*Account, error) {
+	if !cl.IsHD() {
+		return nil, errors.New("not a HD wallet")
+	}
+	if gapLimit == 0 {
+		return nil, errors.New("gap limit must be positive")
+	}
+
+	var found []*Account
+	for _, change := range []uint32{hdwallet.ExternalChain, hdwallet.InternalChain} {
+		var index, gap uint32
+		for gap < gapLimit {
+			accounts := make([]*Account, 0, gapLimit)
+			indexes := make([]uint32, 0, gapLimit)
+			addresses := make([]string, 0, gapLimit)
+			for uint32(len(accounts)) < gapLimit {
+				account, err := cl.DeriveHDAccount(change, index)
+				if err == nil {
+					accounts = append(accounts, account)
+					indexes = append(indexes, index)
+					addresses = append(addresses, account.Address)
+				} else if err != hdwallet.ErrInvalidKey {
+					return nil, err
+				}
+				index++
+			}
+
+			used, err := isUsed(addresses)
+			if err != nil {
+				return nil, err
+			}
+			for i, account := range accounts {
+				if !used[account.Address] {
+					gap++
+					if gap >= gapLimit {
+						break
+					}
+					continue
+				}
+				gap = 0
+				if cl.GetAccountByCodeHash(account.ProgramHash.ToCodeHash()) != nil {
+					continue
+				}
+				if err := cl.saveHDAccount(account, change, indexes[i]); err != nil {
+					return nil, err
+				}
+				found = append(found, account)
+			}
+		}
+	}
+	return found, nil
+}
+
+// initHD encrypts the mnemonic and the seed and saves them into keystore.
+func (cl *Client) initHD(mnemonic string, seed []byte) error {
+	encryptedMnemonic, err := crypto.AesGcmEncrypt([]byte(mnemonic), cl.masterKey)
+	if err != nil {
+		return err
+	}
+	encryptedSeed, err := crypto.AesGcmEncrypt(seed, cl.masterKey)
+	if err != nil {
+		return err
+	}
+
+	data, err := cl.LoadFileData()
+	if err != nil {
+		return err
+	}
+	data.HD = &HDData{
+		Mnemonic: common.BytesToHexString(encryptedMnemonic),
+		Seed:     common.BytesToHexString(encryptedSeed),
+	}
+	if err := cl.SaveFileData(data); err != nil {
+		return err
+	}
+	cl.hd = data.HD
+	return nil
+}
+
+// decryptHDData decrypts the field of HD wallet data selected by field.
+func (cl *Client) decryptHDData(field func(*HDData) string) ([]byte, error) {
+	if !cl.IsHD() {
+		return nil, errors.New("not a HD wallet")
+	}
+	encrypted, err := common.HexStringToBytes(field(cl.hd))
+	if err != nil {
+		return nil, err
+	}
+	return crypto.AesGcmDecrypt(encrypted, cl.masterKey)
+}
+
+// saveHDAccount saves the account derived of the given chain and address
+// index along with its derivation path, and moves the next index forward
+// if the account is on the external chain.
+func (cl *Client) saveHDAccount(ac *Account, change, index uint32) error {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	encryptedPrivateKey, err := cl.encryptKeyPair(ac)
+	if err != nil {
+		return err
+	}
+	data, err := cl.LoadFileData()
+	if err != nil {
+		return err
+	}
+	programHash := common.BytesToHexString(ac.ProgramHash.Bytes())
+	for _, a := range data.Account {
+		if a.ProgramHash == programHash {
+			return errors.New("account already exists")
+		}
+	}
+
+	accountType := SUBACCOUNT
+	if len(data.Account) == 0 {
+		accountType = MAINACCOUNT
+	}
+	data.Account = append(data.Account, AccountData{
+		Address:             ac.Address,
+		ProgramHash:         programHash,
+		RedeemScript:        common.BytesToHexString(ac.RedeemScript),
+		PrivateKeyEncrypted: common.BytesToHexString(encryptedPrivateKey),
+		Type:                accountType,
+		Path:                hdwallet.NewPath(data.HD.Account, change, index).String(),
+	})
+	if change == hdwallet.ExternalChain && index >= data.HD.NextIndex {
+		data.HD.NextIndex = index + 1
+	}
+	if err := cl.SaveFileData(data); err != nil {
+		return err
+	}
+
+	cl.accounts[ac.ProgramHash.ToCodeHash()] = ac
+	cl.hd = data.HD
+	return nil
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package hdwallet
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/elastos/Elastos.ELA/crypto"
+)
+
+const (
+	// HardenedKeyStart is the index of the first hardened child key.
+	HardenedKeyStart = uint32(0x80000000)
+
+	// minSeedLength and maxSeedLength are the length limits of the seed of
+	// master key.
+	minSeedLength = 16
+	maxSeedLength = 64
+)
+
+var (
+	// masterKeySalt is the HMAC key deriving the master key from seed.
+	masterKeySalt = []byte("Bitcoin seed")
+
+	// ErrInvalidSeed indicates the seed length is out of [16, 64] bytes.
+	ErrInvalidSeed = errors.New("seed length must be within [16, 64] bytes")
+
+	// ErrInvalidKey indicates the derived key is zero or not less than the
+	// order of the curve, in which case the next index should be used.
+	ErrInvalidKey = errors.New("derived key is invalid, use the next index")
+)
+
+// ExtendedKey is a BIP32 extended private key on the curve of ELA, which is
+// secp256r1.
+type ExtendedKey struct {
+	key        []byte
+	chainCode  []byte
+	depth      uint8
+	childIndex uint32
+}
+
+// NewMaster derives the master extended key from the seed.
+func NewMaster(seed []byte) (*ExtendedKey, error) {
+	if len(seed) < minSeedLength || len(seed) > maxSeedLength {
+		return nil, ErrInvalidSeed
+	}
+
+	mac := hmac.New(sha512.New, masterKeySalt)
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	if !isValidKey(sum[:32]) {
+		return nil, ErrInvalidKey
+	}
+	return &ExtendedKey{
+		key:       sum[:32],
+		chainCode: sum[32:],
+	}, nil
+}
+
+// Child derives the child extended key of the given index, the indexes not
+// less than HardenedKeyStart derive hardened keys.
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	data := make([]byte, 37)
+	if index >= HardenedKeyStart {
+		copy(data[1:33], k.key)
+	} else {
+		publicKey, err := k.PublicKey().EncodePoint(true)
+		if err != nil {
+			return nil, err
+		}
+		copy(data, publicKey)
+	}
+	binary.BigEndian.PutUint32(data[33:], index)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	if !isValidKey(sum[:32]) {
+		return nil, ErrInvalidKey
+	}
+	childKey := new(big.Int).SetBytes(sum[:32])
+	childKey.Add(childKey, new(big.Int).SetBytes(k.key))
+	childKey.Mod(childKey, crypto.DefaultParams.N)
+	if childKey.Sign() == 0 {
+		return nil, ErrInvalidKey
+	}
+
+	return &ExtendedKey{
+		key:        childKey.FillBytes(make([]byte, 32)),
+		chainCode:  sum[32:],
+		depth:      k.depth + 1,
+		childIndex: index,
+	}, nil
+}
+
+// DerivePath derives the extended key along the path from this key.
+func (k *ExtendedKey) DerivePath(path Path) (*ExtendedKey, error) {
+	key := k
+	for _, index := range path {
+		var err error
+		key, err = key.Child(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// PrivateKey returns the 32 bytes private key.
+func (k *ExtendedKey) PrivateKey() []byte {
+	return k.key
+}
+
+// PublicKey returns the public key of the private key.
+func (k *ExtendedKey) PublicKey() *crypto.PublicKey {
+	return crypto.NewPubKey(k.key)
+}
+
+// ChainCode returns the chain code of the extended key.
+func (k *ExtendedKey) ChainCode() []byte {
+	return k.chainCode
+}
+
+// Depth returns the depth of the extended key, which is 0 for master key.
+func (k *ExtendedKey) Depth() uint8 {
+	return k.depth
+}
+
+// ChildIndex returns the index the extended key derived from its parent.
+func (k *ExtendedKey) ChildIndex() uint32 {
+	return k.childIndex
+}
+
+// isValidKey returns if the key is not zero and less than the order of the
+// curve.
+func isValidKey(key []byte) bool {
+	keyNum := new(big.Int).SetBytes(key)
+	return keyNum.Sign() != 0 && keyNum.Cmp(crypto.DefaultParams.N) < 0
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package hdwallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/crypto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMnemonic(t *testing.T) {
+	vectors := []struct {
+		entropy  string
+		mnemonic string
+		seed     string
+	}{
+		{
+			"00000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			"legal winner thank year wave sausage worth useful legal winner thank yellow",
+			"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+		{
+			"80808080808080808080808080808080",
+			"letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+			"d71de856f81a8acc65e6fc851a38d4d7ec216fd0796d0a6827a3ad6ed5511a30fa280f12eb2e47ed2ac03b5c462a0358d18d69fe4f985ec81778c1b370b652a8",
+		},
+		{
+			"ffffffffffffffffffffffffffffffff",
+			"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+			"ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+		},
+	}
+	for _, v := range vectors {
+		entropy, _ := hex.DecodeString(v.entropy)
+		mnemonic, err := EntropyToMnemonic(entropy)
+		assert.NoError(t, err)
+		assert.Equal(t, v.mnemonic, mnemonic)
+
+		decoded, err := MnemonicToEntropy(mnemonic)
+		assert.NoError(t, err)
+		assert.Equal(t, entropy, decoded)
+
+		seed, err := MnemonicToSeed(mnemonic, "TREZOR")
+		assert.NoError(t, err)
+		assert.Equal(t, v.seed, hex.EncodeToString(seed))
+	}
+
+	for _, bits := range []int{128, 160, 192, 224, 256} {
+		mnemonic, err := NewMnemonic(bits)
+		assert.NoError(t, err)
+		assert.NoError(t, ValidateMnemonic(mnemonic))
+	}
+	_, err := NewMnemonic(100)
+	assert.Equal(t, ErrInvalidEntropy, err)
+
+	assert.Equal(t, ErrChecksumMismatch, ValidateMnemonic(
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon"))
+	assert.Equal(t, ErrInvalidMnemonic, ValidateMnemonic(
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon"))
+	assert.Equal(t, ErrInvalidMnemonic, ValidateMnemonic(
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon elastos"))
+}
+
+func TestExtendedKey(t *testing.T) {
+	// The master key and hardened children are independent of the curve
+	// unless the sum of keys exceeds the order, so the BIP32 test vector 1
+	// applies to them.
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := NewMaster(seed)
+	assert.NoError(t, err)
+	assert.Equal(t, "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35",
+		hex.EncodeToString(master.PrivateKey()))
+	assert.Equal(t, "873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508",
+		hex.EncodeToString(master.ChainCode()))
+
+	child, err := master.Child(HardenedKeyStart)
+	assert.NoError(t, err)
+	assert.Equal(t, "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea",
+		hex.EncodeToString(child.PrivateKey()))
+	assert.Equal(t, "47fdacbd0f1097043b78c63c20c34ef4ed9a111d980047ad16282c7ae6236141",
+		hex.EncodeToString(child.ChainCode()))
+	assert.Equal(t, uint8(1), child.Depth())
+	assert.Equal(t, HardenedKeyStart, child.ChildIndex())
+
+	// Normal children are derived from the public key of secp256r1.
+	normal, err := child.Child(1)
+	assert.NoError(t, err)
+	again, err := master.DerivePath(Path{HardenedKeyStart, 1})
+	assert.NoError(t, err)
+	assert.Equal(t, normal.PrivateKey(), again.PrivateKey())
+	assert.Equal(t, 32, len(normal.PrivateKey()))
+	assert.True(t, crypto.Equal(crypto.NewPubKey(normal.PrivateKey()),
+		normal.PublicKey()))
+	assert.False(t, bytes.Equal(child.PrivateKey(), normal.PrivateKey()))
+
+	_, err = NewMaster(seed[:8])
+	assert.Equal(t, ErrInvalidSeed, err)
+}
+
+func TestPath(t *testing.T) {
+	path := NewPath(0, ExternalChain, 5)
+	assert.Equal(t, "m/44'/2305'/0'/0/5", path.String())
+
+	parsed, err := ParsePath("m/44'/2305h/0'/0/5")
+	assert.NoError(t, err)
+	assert.Equal(t, path, parsed)
+
+	parsed, err = ParsePath("m")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(parsed))
+
+	for _, s := range []string{"", "44'/0", "m/a", "m/2147483648", "m//1"} {
+		_, err = ParsePath(s)
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package hdwallet
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// DefaultEntropyBits is the entropy size of new mnemonics, which gives
+	// a mnemonic of 12 words.
+	DefaultEntropyBits = 128
+
+	// seedIterations is the PBKDF2 iterations converting mnemonic to seed.
+	seedIterations = 2048
+
+	// seedLength is the length of the seed converted from mnemonic.
+	seedLength = 64
+)
+
+var (
+	// ErrInvalidEntropy indicates the entropy size is not a multiple of 32
+	// bits within [128, 256].
+	ErrInvalidEntropy = errors.New("entropy size must be a multiple of 32 bits within [128, 256]")
+
+	// ErrInvalidMnemonic indicates the mnemonic has an invalid number of
+	// words or a word out of the word list.
+	ErrInvalidMnemonic = errors.New("invalid mnemonic")
+
+	// ErrChecksumMismatch indicates the checksum of the mnemonic does not
+	// match its entropy.
+	ErrChecksumMismatch = errors.New("mnemonic checksum mismatch")
+)
+
+// wordIndex is the index of every word in the word list.
+var wordIndex = func() map[string]int {
+	index := make(map[string]int, len(englishWords))
+	for i, word := range englishWords {
+		index[word] = i
+	}
+	return index
+}()
+
+// NewEntropy returns random entropy of the given size in bits.
+func NewEntropy(bits int) ([]byte, error) {
+	if err := checkEntropyBits(bits); err != nil {
+		return nil, err
+	}
+	entropy := make([]byte, bits/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return nil, err
+	}
+	return entropy, nil
+}
+
+// NewMnemonic returns a new random mnemonic of the given entropy size.
+func NewMnemonic(bits int) (string, error) {
+	entropy, err := NewEntropy(bits)
+	if err != nil {
+		return "", err
+	}
+	return EntropyToMnemonic(entropy)
+}
+
+// EntropyToMnemonic encodes the entropy into mnemonic words, every word
+// holds 11 bits of the entropy followed by its SHA256 checksum.
+func EntropyToMnemonic(entropy []byte) (string, error) {
+	bits := len(entropy) * 8
+	if err := checkEntropyBits(bits); err != nil {
+		return "", err
+	}
+	checksumBits := uint(bits / 32)
+	hash := sha256.Sum256(entropy)
+
+	data := new(big.Int).SetBytes(entropy)
+	data.Lsh(data, checksumBits)
+	data.Or(data, big.NewInt(int64(hash[0]>>(8-checksumBits))))
+
+	count := (bits + int(checksumBits)) / 11
+	words := make([]string, count)
+	mask := big.NewInt(2047)
+	index := new(big.Int)
+	for i := count - 1; i >= 0; i-- {
+		index.And(data, mask)
+		words[i] = englishWords[index.Int64()]
+		data.Rsh(data, 11)
+	}
+	return strings.Join(words, " "), nil
+}
+
+// MnemonicToEntropy decodes the mnemonic words into entropy and verifies
+// the checksum.
+func MnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	count := len(words)
+	if count%3 != 0 || count < 12 || count > 24 {
+		return nil, ErrInvalidMnemonic
+	}
+
+	data := new(big.Int)
+	for _, word := range words {
+		index, ok := wordIndex[word]
+		if !ok {
+			return nil, ErrInvalidMnemonic
+		}
+		data.Lsh(data, 11)
+		data.Or(data, big.NewInt(int64(index)))
+	}
+
+	checksumBits := uint(count / 3)
+	checksum := new(big.Int).And(data,
+		big.NewInt(int64(1)<<checksumBits-1)).Int64()
+	data.Rsh(data, checksumBits)
+
+	entropy := make([]byte, (count*11-int(checksumBits))/8)
+	data.FillBytes(entropy)
+	hash := sha256.Sum256(entropy)
+	if int64(hash[0]>>(8-checksumBits)) != checksum {
+		return nil, ErrChecksumMismatch
+	}
+	return entropy, nil
+}
+
+// ValidateMnemonic returns an error if the mnemonic is invalid.
+func ValidateMnemonic(mnemonic string) error {
+	_, err := MnemonicToEntropy(mnemonic)
+	return err
+}
+
+// NormalizeMnemonic joins the words of the mnemonic by single spaces.
+func NormalizeMnemonic(mnemonic string) string {
+	return strings.Join(strings.Fields(mnemonic), " ")
+}
+
+// MnemonicToSeed validates the mnemonic and converts it into the seed of
+// HD wallet, with an optional passphrase.
+func MnemonicToSeed(mnemonic string, passphrase string) ([]byte, error) {
+	if err := ValidateMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+	return pbkdf2.Key([]byte(NormalizeMnemonic(mnemonic)),
+		[]byte("mnemonic"+passphrase), seedIterations, seedLength,
+		sha512.New), nil
+}
+
+func checkEntropyBits(bits int) error {
+	if bits%32 != 0 || bits < 128 || bits > 256 {
+		return ErrInvalidEntropy
+	}
+	return nil
+}
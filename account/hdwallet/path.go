@@ -0,0 +1,82 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package hdwallet
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Purpose is the BIP44 purpose of the first level of path.
+	Purpose = 44
+
+	// CoinType is the SLIP-0044 registered coin type of ELA.
+	CoinType = 2305
+
+	// ExternalChain and InternalChain are the change levels of path, for
+	// receiving addresses and change addresses respectively.
+	ExternalChain = 0
+	InternalChain = 1
+)
+
+// Path is the derivation path of an extended key from the master key.
+type Path []uint32
+
+// NewPath returns the BIP44 path of ELA with the given account, change and
+// address index, which is m/44'/2305'/account'/change/index.
+func NewPath(account, change, index uint32) Path {
+	return Path{
+		Purpose + HardenedKeyStart,
+		CoinType + HardenedKeyStart,
+		account + HardenedKeyStart,
+		change,
+		index,
+	}
+}
+
+// ParsePath parses the path in the form of "m/44'/2305'/0'/0/1", in which
+// the hardened indexes are suffixed by "'" or "h".
+func ParsePath(s string) (Path, error) {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if parts[0] != "m" {
+		return nil, errors.New("derivation path must start with m")
+	}
+
+	path := make(Path, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h")
+		if hardened {
+			part = part[:len(part)-1]
+		}
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || uint32(index) >= HardenedKeyStart {
+			return nil, errors.New("invalid derivation path index: " + part)
+		}
+		if hardened {
+			index += uint64(HardenedKeyStart)
+		}
+		path = append(path, uint32(index))
+	}
+	return path, nil
+}
+
+// String returns the path in the form of "m/44'/2305'/0'/0/1".
+func (p Path) String() string {
+	var b strings.Builder
+	b.WriteString("m")
+	for _, index := range p {
+		b.WriteString("/")
+		if index >= HardenedKeyStart {
+			b.WriteString(strconv.FormatUint(uint64(index-HardenedKeyStart), 10))
+			b.WriteString("'")
+		} else {
+			b.WriteString(strconv.FormatUint(uint64(index), 10))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,222 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package account
+
+import (
+	"crypto/rand"
+	"errors"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/crypto"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// KDFScrypt and KDFArgon2id are the names of the memory-hard key
+	// derivation functions supported to derive the key from password.
+	KDFScrypt   = "scrypt"
+	KDFArgon2id = "argon2id"
+
+	// kdfSaltLength is the length of the random salt of every file.
+	kdfSaltLength = 32
+
+	// kdfKeyLength is the length of derived keys, which are AES-256 keys.
+	kdfKeyLength = 32
+
+	// Default parameters of scrypt, which take about 32MB memory.
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+
+	// Default parameters of argon2id, which take 64MB memory.
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+
+	// Maximum parameters accepted from the stored files, so a crafted file
+	// is not able to exhaust the memory or the CPU when it is loaded.
+	maxKDFMemory  = 1 << 30 // in bytes
+	maxScryptP    = 16
+	maxArgon2Time = 16
+)
+
+// KDFParams is the parameters of the key derivation function deriving the
+// key from password, which are stored along with the encrypted data.
+type KDFParams struct {
+	Name string
+	Salt string
+
+	// Parameters of scrypt.
+	N int `json:",omitempty"`
+	R int `json:",omitempty"`
+	P int `json:",omitempty"`
+
+	// Parameters of argon2id, the memory is in KiB.
+	Time    uint32 `json:",omitempty"`
+	Memory  uint32 `json:",omitempty"`
+	Threads uint8  `json:",omitempty"`
+}
+
+// NewKDFParams returns the default parameters of the key derivation
+// function with the given name and a random salt.
+func NewKDFParams(name string) (*KDFParams, error) {
+	salt := make([]byte, kdfSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	params := &KDFParams{
+		Name: name,
+		Salt: common.BytesToHexString(salt),
+	}
+	switch name {
+	case KDFScrypt:
+		params.N, params.R, params.P = scryptN, scryptR, scryptP
+	case KDFArgon2id:
+		params.Time, params.Memory, params.Threads = argon2Time,
+			argon2Memory, argon2Threads
+	default:
+		return nil, errors.New("unknown key derivation function: " + name)
+	}
+	return params, nil
+}
+
+// Validate checks the parameters are known and within the limits.
+func (p *KDFParams) Validate() error {
+	switch p.Name {
+	case KDFScrypt:
+		// scrypt takes 128*N*r bytes memory and N must be a power of 2.
+		if p.N <= 1 || p.N&(p.N-1) != 0 || p.R <= 0 || p.P <= 0 ||
+			p.P > maxScryptP || p.N > maxKDFMemory/128/p.R {
+			return errors.New("invalid scrypt parameters")
+		}
+	case KDFArgon2id:
+		if p.Time == 0 || p.Time > maxArgon2Time || p.Memory == 0 ||
+			p.Memory > maxKDFMemory/1024 || p.Threads == 0 {
+			return errors.New("invalid argon2id parameters")
+		}
+	default:
+		return errors.New("unknown key derivation function: " + p.Name)
+	}
+	return nil
+}
+
+// DeriveKey derives the AES-256 key from the password.
+func (p *KDFParams) DeriveKey(password []byte) ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	salt, err := common.HexStringToBytes(p.Salt)
+	if err != nil || len(salt) == 0 {
+		return nil, errors.New("invalid key derivation salt")
+	}
+
+	if p.Name == KDFScrypt {
+		return scrypt.Key(password, salt, p.N, p.R, p.P, kdfKeyLength)
+	}
+	return argon2.IDKey(password, salt, p.Time, p.Memory, p.Threads,
+		kdfKeyLength), nil
+}
+
+// newMasterKey returns a random master key encrypting the private keys.
+func newMasterKey() ([]byte, error) {
+	masterKey := make([]byte, 32)
+	if _, err := rand.Read(masterKey); err != nil {
+		return nil, err
+	}
+	return masterKey, nil
+}
+
+// encryptMasterKey derives the key from password by the given parameters,
+// and encrypts the master key by AES-GCM with it.
+func encryptMasterKey(masterKey []byte, password []byte,
+	params *KDFParams) ([]byte, error) {
+	key, err := params.DeriveKey(password)
+	if err != nil {
+		return nil, err
+	}
+	defer common.ClearBytes(key)
+
+	return crypto.AesGcmEncrypt(masterKey, key)
+}
+
+// decryptMasterKey derives the key from password by the given parameters,
+// and decrypts the master key by AES-GCM with it.  The authentication of
+// AES-GCM fails if the password is wrong.
+func decryptMasterKey(encryptedMasterKey []byte, password []byte,
+	params *KDFParams) ([]byte, error) {
+	key, err := params.DeriveKey(password)
+	if err != nil {
+		return nil, err
+	}
+	defer common.ClearBytes(key)
+
+	masterKey, err := crypto.AesGcmDecrypt(encryptedMasterKey, key)
+	if err != nil {
+		return nil, errors.New("password wrong")
+	}
+	return masterKey, nil
+}
+
+// migrate upgrades the keystore of version 1 to version 2.  The private
+// keys are decrypted by the legacy master key and encrypted again by a new
+// random master key with AES-GCM, and the new master key is protected by
+// the key derived from the password by scrypt.
+func (cl *Client) migrate(password []byte) error {
+	data, err := cl.LoadFileData()
+	if err != nil {
+		return err
+	}
+
+	masterKey, err := newMasterKey()
+	if err != nil {
+		return err
+	}
+	for i, a := range data.Account {
+		if a.PrivateKeyEncrypted == "" {
+			continue
+		}
+		encrypted, err := common.HexStringToBytes(a.PrivateKeyEncrypted)
+		if err != nil {
+			return err
+		}
+		keyPair, err := cl.DecryptPrivateKey(encrypted)
+		if err != nil {
+			return err
+		}
+		encrypted, err = crypto.AesGcmEncrypt(keyPair, masterKey)
+		common.ClearBytes(keyPair)
+		if err != nil {
+			return err
+		}
+		data.Account[i].PrivateKeyEncrypted = common.BytesToHexString(encrypted)
+	}
+
+	params, err := NewKDFParams(KDFScrypt)
+	if err != nil {
+		return err
+	}
+	encryptedMasterKey, err := encryptMasterKey(masterKey, password, params)
+	if err != nil {
+		return err
+	}
+	data.Version = KeystoreVersion2
+	data.PasswordHash = ""
+	data.IV = ""
+	data.KDF = params
+	data.MasterKey = common.BytesToHexString(encryptedMasterKey)
+	if err := cl.SaveFileData(data); err != nil {
+		return err
+	}
+
+	common.ClearBytes(cl.masterKey)
+	cl.version = KeystoreVersion2
+	cl.masterKey = masterKey
+	cl.iv = nil
+	return nil
+}
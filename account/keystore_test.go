@@ -0,0 +1,310 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package account
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/account/hdwallet"
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/crypto"
+	"github.com/elastos/Elastos.ELA/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeKeystoreV1 writes a keystore of version 1 with the given account.
+func writeKeystoreV1(t *testing.T, path string, password []byte,
+	ac *Account) {
+	passwordKey := crypto.ToAesKey(password)
+	passwordHash := sha256.Sum256(passwordKey)
+	iv := make([]byte, 16)
+	masterKey := make([]byte, 32)
+	masterKey[0] = 1
+	encryptedMasterKey, err := crypto.AesEncrypt(masterKey, passwordKey, iv)
+	assert.NoError(t, err)
+
+	keyPair := make([]byte, 96)
+	publicKey, err := ac.PublicKey.EncodePoint(false)
+	assert.NoError(t, err)
+	copy(keyPair, publicKey[1:])
+	copy(keyPair[64:], ac.PrivateKey)
+	encryptedKeyPair, err := crypto.AesEncrypt(keyPair, masterKey, iv)
+	assert.NoError(t, err)
+
+	data, err := json.Marshal(&FileData{
+		Version:      KeystoreVersion,
+		PasswordHash: common.BytesToHexString(passwordHash[:]),
+		IV:           common.BytesToHexString(iv),
+		MasterKey:    common.BytesToHexString(encryptedMasterKey),
+		Account: []AccountData{{
+			Address:             ac.Address,
+			ProgramHash:         common.BytesToHexString(ac.ProgramHash.Bytes()),
+			RedeemScript:        common.BytesToHexString(ac.RedeemScript),
+			PrivateKeyEncrypted: common.BytesToHexString(encryptedKeyPair),
+			Type:                MAINACCOUNT,
+		}},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(path, data, 0600))
+}
+
+func TestClient_KeystoreV2(t *testing.T) {
+	path := filepath.Join(t.TempDir(), KeystoreFileName)
+	password := []byte("password")
+
+	client, err := Create(path, password)
+	assert.NoError(t, err)
+	main := client.GetMainAccount()
+
+	data, err := client.LoadFileData()
+	assert.NoError(t, err)
+	assert.Equal(t, KeystoreVersion2, data.Version)
+	assert.Equal(t, KDFScrypt, data.KDF.Name)
+	assert.Equal(t, "", data.PasswordHash)
+
+	client, err = Open(path, password)
+	assert.NoError(t, err)
+	assert.Equal(t, main.PrivateKey, client.GetMainAccount().PrivateKey)
+
+	_, err = Open(path, []byte("wrong"))
+	assert.Error(t, err)
+}
+
+func TestKDFParams_Validate(t *testing.T) {
+	for _, name := range []string{KDFScrypt, KDFArgon2id} {
+		params, err := NewKDFParams(name)
+		assert.NoError(t, err)
+		assert.NoError(t, params.Validate())
+	}
+
+	tests := []KDFParams{
+		{Name: "pbkdf2"},
+		{Name: KDFScrypt, N: 1 << 15, R: 8, P: 0},
+		{Name: KDFScrypt, N: 3, R: 8, P: 1},
+		{Name: KDFScrypt, N: 1 << 21, R: 8, P: 1},
+		{Name: KDFScrypt, N: 1 << 15, R: 1 << 20, P: 1},
+		{Name: KDFScrypt, N: 1 << 15, R: 8, P: 1 << 20},
+		{Name: KDFArgon2id, Time: 3, Memory: 64 * 1024, Threads: 0},
+		{Name: KDFArgon2id, Time: 1 << 20, Memory: 64 * 1024, Threads: 4},
+		{Name: KDFArgon2id, Time: 3, Memory: 1 << 30, Threads: 4},
+	}
+	for _, params := range tests {
+		assert.Error(t, params.Validate(), "%+v", params)
+	}
+
+	// The keystore with oversized parameters is refused to load.
+	path := filepath.Join(t.TempDir(), KeystoreFileName)
+	client, err := Create(path, []byte("password"))
+	assert.NoError(t, err)
+	data, err := client.LoadFileData()
+	assert.NoError(t, err)
+	data.KDF.N = 1 << 30
+	assert.NoError(t, client.SaveFileData(data))
+	_, err = client.LoadFileData()
+	assert.Error(t, err)
+	_, err = Open(path, []byte("password"))
+	assert.Error(t, err)
+}
+
+func TestClient_Migrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), KeystoreFileName)
+	password := []byte("password")
+	ac, err := NewAccount()
+	assert.NoError(t, err)
+	writeKeystoreV1(t, path, password, ac)
+
+	_, err = Open(path, []byte("wrong"))
+	assert.Error(t, err)
+
+	client, err := Open(path, password)
+	assert.NoError(t, err)
+	assert.Equal(t, ac.PrivateKey, client.GetMainAccount().PrivateKey)
+
+	data, err := client.LoadFileData()
+	assert.NoError(t, err)
+	assert.Equal(t, KeystoreVersion2, data.Version)
+	assert.Equal(t, "", data.IV)
+
+	client, err = Open(path, password)
+	assert.NoError(t, err)
+	assert.Equal(t, ac.PrivateKey, client.GetMainAccount().PrivateKey)
+	assert.Equal(t, ac.Address, client.GetMainAccount().Address)
+}
+
+func TestClient_BackupRestore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, KeystoreFileName)
+	password := []byte("password")
+
+	client, err := Create(path, password)
+	assert.NoError(t, err)
+	sub, err := client.CreateAccount()
+	assert.NoError(t, err)
+	main := client.GetMainAccount()
+	multiSig, err := client.CreateMultiSigAccount(1,
+		[]*crypto.PublicKey{main.PublicKey, sub.PublicKey})
+	assert.NoError(t, err)
+	schnorr, err := client.CreateSchnorrAggregateAccount(
+		[]*Account{main, sub})
+	assert.NoError(t, err)
+
+	backupPath := filepath.Join(dir, "backup.dat")
+	assert.NoError(t, client.Backup(backupPath, password, KDFArgon2id))
+	assert.Error(t, client.Backup(backupPath, password, KDFScrypt))
+
+	_, err = ReadBackup(backupPath, []byte("wrong"))
+	assert.Error(t, err)
+
+	restored, err := Restore(filepath.Join(dir, "restored.dat"), backupPath,
+		password)
+	assert.NoError(t, err)
+	assert.Equal(t, main.PrivateKey, restored.GetMainAccount().PrivateKey)
+	assert.Equal(t, sub.PrivateKey,
+		restored.GetAccountByCodeHash(sub.ProgramHash.ToCodeHash()).PrivateKey)
+	assert.Equal(t, multiSig.RedeemScript, restored.GetAccountByCodeHash(
+		multiSig.ProgramHash.ToCodeHash()).RedeemScript)
+	assert.Equal(t, schnorr.RedeemScript, restored.GetAccountByCodeHash(
+		schnorr.ProgramHash.ToCodeHash()).RedeemScript)
+	assert.Equal(t, 4, len(restored.GetAccounts()))
+
+	// Accounts already in the wallet are skipped.
+	restored, err = Restore(path, backupPath, password)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(restored.GetAccounts()))
+}
+
+func TestClient_HD(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, KeystoreFileName)
+	password := []byte("password")
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+	_, _, err := CreateHD(path, password, "abandon about", "")
+	assert.Error(t, err)
+	assert.False(t, utils.FileExisted(path))
+
+	client, words, err := CreateHD(path, password, " "+mnemonic+" ", "")
+	assert.NoError(t, err)
+	assert.Equal(t, mnemonic, words)
+	assert.True(t, client.IsHD())
+	first, err := client.DeriveHDAccount(hdwallet.ExternalChain, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, first.Address, client.GetMainAccount().Address)
+
+	client, err = Add(path, password)
+	assert.NoError(t, err)
+	second, err := client.DeriveHDAccount(hdwallet.ExternalChain, 1)
+	assert.NoError(t, err)
+	assert.NotNil(t, client.GetAccountByCodeHash(second.ProgramHash.ToCodeHash()))
+
+	// The same mnemonic with another passphrase derives other accounts.
+	other, _, err := CreateHD(filepath.Join(dir, "other.dat"), password,
+		mnemonic, "passphrase")
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.Address, other.GetMainAccount().Address)
+
+	client, err = Open(path, password)
+	assert.NoError(t, err)
+	assert.True(t, client.IsHD())
+	words, err = client.Mnemonic()
+	assert.NoError(t, err)
+	assert.Equal(t, mnemonic, words)
+	data, err := client.LoadFileData()
+	assert.NoError(t, err)
+	assert.Equal(t, "m/44'/2305'/0'/0/1", data.Account[1].Path)
+	assert.Equal(t, uint32(2), data.HD.NextIndex)
+
+	external, err := client.DeriveHDAccount(hdwallet.ExternalChain, 5)
+	assert.NoError(t, err)
+	internal, err := client.DeriveHDAccount(hdwallet.InternalChain, 0)
+	assert.NoError(t, err)
+	used := map[string]bool{
+		first.Address:    true,
+		external.Address: true,
+		internal.Address: true,
+	}
+	var queries int
+	found, err := client.ScanHDAccounts(5, func(addresses []string) (map[string]bool, error) {
+		queries++
+		assert.Equal(t, 5, len(addresses))
+		return used, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(found))
+	assert.Equal(t, 5, queries)
+	assert.Equal(t, 4, len(client.GetAccounts()))
+	data, err = client.LoadFileData()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(6), data.HD.NextIndex)
+	assert.Equal(t, "m/44'/2305'/0'/1/0", data.Account[3].Path)
+
+	// Wallets of random keys are not HD wallets.
+	client, err = Create(filepath.Join(dir, "random.dat"), password)
+	assert.NoError(t, err)
+	assert.False(t, client.IsHD())
+	_, err = client.Mnemonic()
+	assert.Error(t, err)
+}
+
+func TestClient_BackupRestoreHD(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, KeystoreFileName)
+	password := []byte("password")
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+	// The seed of a BIP39 passphrase can not be recovered from the mnemonic.
+	client, _, err := CreateHD(path, password, mnemonic, "passphrase")
+	assert.NoError(t, err)
+	client, err = Add(path, password)
+	assert.NoError(t, err)
+	random, err := client.CreateAccount()
+	assert.NoError(t, err)
+	client, err = Open(path, password)
+	assert.NoError(t, err)
+	data, err := client.LoadFileData()
+	assert.NoError(t, err)
+
+	backupPath := filepath.Join(dir, "backup.dat")
+	assert.NoError(t, client.Backup(backupPath, password, KDFScrypt))
+	backup, err := ReadBackup(backupPath, password)
+	assert.NoError(t, err)
+	assert.Equal(t, mnemonic, backup.HD.Mnemonic)
+	assert.Equal(t, 3, len(backup.Accounts))
+
+	restoredPath := filepath.Join(dir, "restored.dat")
+	restored, err := Restore(restoredPath, backupPath, password)
+	assert.NoError(t, err)
+	assert.True(t, restored.IsHD())
+	words, err := restored.Mnemonic()
+	assert.NoError(t, err)
+	assert.Equal(t, mnemonic, words)
+	assert.Equal(t, client.GetMainAccount().Address,
+		restored.GetMainAccount().Address)
+	assert.Equal(t, random.PrivateKey, restored.GetAccountByCodeHash(
+		random.ProgramHash.ToCodeHash()).PrivateKey)
+	restoredData, err := restored.LoadFileData()
+	assert.NoError(t, err)
+	assert.Equal(t, data.HD.NextIndex, restoredData.HD.NextIndex)
+	assert.Equal(t, len(data.Account), len(restoredData.Account))
+	for i := range data.Account {
+		assert.Equal(t, data.Account[i].Address, restoredData.Account[i].Address)
+		assert.Equal(t, data.Account[i].Path, restoredData.Account[i].Path)
+		assert.Equal(t, data.Account[i].Type, restoredData.Account[i].Type)
+	}
+
+	// The restored wallet derives the same accounts as the original one.
+	expected, err := client.DeriveHDAccount(hdwallet.ExternalChain, 2)
+	assert.NoError(t, err)
+	restored, err = Add(restoredPath, password)
+	assert.NoError(t, err)
+	assert.NotNil(t, restored.GetAccountByCodeHash(
+		expected.ProgramHash.ToCodeHash()))
+}
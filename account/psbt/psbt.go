@@ -0,0 +1,630 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package psbt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/elastos/Elastos.ELA/account"
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core"
+	pg "github.com/elastos/Elastos.ELA/core/contract/program"
+	common2 "github.com/elastos/Elastos.ELA/core/types/common"
+	"github.com/elastos/Elastos.ELA/core/types/functions"
+	"github.com/elastos/Elastos.ELA/core/types/interfaces"
+	"github.com/elastos/Elastos.ELA/crypto"
+	"github.com/elastos/Elastos.ELA/elanet/pact"
+)
+
+// Version is the version of the packet format.
+const Version = 1
+
+// magic is the leading bytes of a serialized packet.
+var magic = [5]byte{'p', 's', 'b', 't', 0xff}
+
+// UTXO is the output referenced by an input of the transaction, along with
+// the version of the transaction it belongs to.
+type UTXO struct {
+	TxVersion common2.TransactionVersion
+	Output    *common2.Output
+}
+
+// Signature is a signature collected for a program.
+type Signature struct {
+	PublicKey []byte
+	Signature []byte
+}
+
+// Program is the redeem script of a program hash the transaction needs to
+// be signed by, and the signatures collected for it.
+type Program struct {
+	Code       []byte
+	Signatures []*Signature
+}
+
+// Packet is a partially signed transaction, which carries the unsigned
+// transaction, the UTXOs referenced by its inputs, and the programs to be
+// signed.  A packet is created by the coordinator, passed to each signer to
+// add signatures offline, combined and finalized into a signed transaction.
+type Packet struct {
+	Tx       interfaces.Transaction
+	UTXOs    []*UTXO
+	Programs []*Program
+}
+
+// New creates a packet of the transaction with the UTXOs referenced by its
+// inputs in order.  The redeem scripts are given by codes and the programs
+// of the transaction, the ones not needed by the transaction are ignored,
+// and the signatures already in the programs are collected.
+func New(txn interfaces.Transaction, utxos []*UTXO, codes [][]byte) (*Packet, error) {
+	if len(utxos) != len(txn.Inputs()) {
+		return nil, errors.New("the number of UTXOs is different with number of inputs")
+	}
+
+	// the program hashes the transaction needs to be signed by
+	required := make(map[common.Uint160]common.Uint168)
+	for _, utxo := range utxos {
+		if utxo == nil || utxo.Output == nil {
+			return nil, errors.New("UTXO of input not found")
+		}
+		required[utxo.Output.ProgramHash.ToCodeHash()] = utxo.Output.ProgramHash
+	}
+	for _, attribute := range txn.Attributes() {
+		if attribute.Usage == common2.Script {
+			programHash, err := common.Uint168FromBytes(attribute.Data)
+			if err != nil {
+				return nil, errors.New("invalid script attribute")
+			}
+			required[programHash.ToCodeHash()] = *programHash
+		}
+	}
+
+	unsigned, err := copyTransaction(txn)
+	if err != nil {
+		return nil, err
+	}
+	unsigned.SetPrograms([]*pg.Program{})
+	packet := &Packet{Tx: unsigned, UTXOs: utxos}
+
+	programs := make(map[common.Uint160]*Program)
+	for _, code := range codes {
+		codeHash := *common.ToCodeHash(code)
+		if _, ok := required[codeHash]; !ok {
+			continue
+		}
+		if _, ok := programs[codeHash]; ok {
+			continue
+		}
+		if _, _, err := getSigners(code); err != nil {
+			return nil, err
+		}
+		programs[codeHash] = &Program{Code: code}
+	}
+	for _, p := range txn.Programs() {
+		codeHash := *common.ToCodeHash(p.Code)
+		if _, ok := required[codeHash]; !ok {
+			continue
+		}
+		if _, ok := programs[codeHash]; !ok {
+			if _, _, err := getSigners(p.Code); err != nil {
+				return nil, err
+			}
+			programs[codeHash] = &Program{Code: p.Code}
+		}
+	}
+	for codeHash, programHash := range required {
+		program, ok := programs[codeHash]
+		if !ok {
+			address, _ := programHash.ToAddress()
+			return nil, errors.New("redeem script of " + address + " not found")
+		}
+		packet.Programs = append(packet.Programs, program)
+	}
+	sort.Slice(packet.Programs, func(i, j int) bool {
+		hashi := common.ToCodeHash(packet.Programs[i].Code)
+		hashj := common.ToCodeHash(packet.Programs[j].Code)
+		return hashi.Compare(*hashj) < 0
+	})
+
+	// collect the signatures of the partially signed transaction
+	data, err := packet.signData()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range txn.Programs() {
+		program := packet.getProgram(p.Code)
+		if program == nil {
+			continue
+		}
+		publicKeys, _, err := getSigners(program.Code)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i+crypto.SignatureScriptLength <= len(p.Parameter); i += crypto.SignatureScriptLength {
+			signature := p.Parameter[i+1 : i+crypto.SignatureScriptLength]
+			for _, publicKey := range publicKeys {
+				if program.addSignature(publicKey, signature, data) == nil {
+					break
+				}
+			}
+		}
+	}
+
+	return packet, nil
+}
+
+// Sign signs the programs by the accounts returned by getAccount with the
+// code hash of a signer, and returns the number of new signatures.  The
+// programs having enough signatures are skipped.
+func (p *Packet) Sign(getAccount func(codeHash common.Uint160) *account.Account) (int, error) {
+	data, err := p.signData()
+	if err != nil {
+		return 0, err
+	}
+
+	var signed int
+	for _, program := range p.Programs {
+		publicKeys, codeHashes, err := getSigners(program.Code)
+		if err != nil {
+			return signed, err
+		}
+		for i, codeHash := range codeHashes {
+			have, need, _ := program.Status()
+			if have >= need {
+				break
+			}
+			if program.hasSigned(publicKeys[i]) {
+				continue
+			}
+			acc := getAccount(codeHash)
+			if acc == nil || acc.PrivateKey == nil {
+				continue
+			}
+			signature, err := crypto.Sign(acc.PrivKey(), data)
+			if err != nil {
+				return signed, err
+			}
+			if err := program.addSignature(publicKeys[i], signature, data); err != nil {
+				return signed, err
+			}
+			signed++
+		}
+	}
+	return signed, nil
+}
+
+// Combine merges the signatures of the packets of the same transaction into
+// a new packet.
+func Combine(packets ...*Packet) (*Packet, error) {
+	if len(packets) == 0 {
+		return nil, errors.New("no packet to combine")
+	}
+	combined, err := packets[0].copy()
+	if err != nil {
+		return nil, err
+	}
+	data, err := combined.signData()
+	if err != nil {
+		return nil, err
+	}
+
+	txHash := combined.Tx.Hash()
+	for _, packet := range packets[1:] {
+		if !packet.Tx.Hash().IsEqual(txHash) {
+			return nil, errors.New("packets of different transactions")
+		}
+		if len(packet.Programs) != len(combined.Programs) {
+			return nil, errors.New("packets of different programs")
+		}
+		for i, program := range packet.Programs {
+			if !bytes.Equal(program.Code, combined.Programs[i].Code) {
+				return nil, errors.New("packets of different programs")
+			}
+			for _, s := range program.Signatures {
+				if combined.Programs[i].hasSigned(s.PublicKey) {
+					continue
+				}
+				err := combined.Programs[i].addSignature(s.PublicKey,
+					s.Signature, data)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return combined, nil
+}
+
+// IsComplete returns if all programs have enough signatures.
+func (p *Packet) IsComplete() bool {
+	for _, program := range p.Programs {
+		have, need, err := program.Status()
+		if err != nil || have < need {
+			return false
+		}
+	}
+	return true
+}
+
+// Finalize returns the signed transaction of the packet, the signatures of
+// a multi-signature program are ordered by the public keys in redeem script.
+func (p *Packet) Finalize() (interfaces.Transaction, error) {
+	if !p.IsComplete() {
+		return nil, errors.New("transaction is not fully signed")
+	}
+
+	programs := make([]*pg.Program, 0, len(p.Programs))
+	for _, program := range p.Programs {
+		publicKeys, _, err := getSigners(program.Code)
+		if err != nil {
+			return nil, err
+		}
+		_, need, err := program.Status()
+		if err != nil {
+			return nil, err
+		}
+		var parameter []byte
+		for _, publicKey := range publicKeys {
+			if need == 0 {
+				break
+			}
+			for _, s := range program.Signatures {
+				if bytes.Equal(s.PublicKey, publicKey) {
+					parameter = append(parameter, byte(len(s.Signature)))
+					parameter = append(parameter, s.Signature...)
+					need--
+					break
+				}
+			}
+		}
+		programs = append(programs, &pg.Program{
+			Code:      program.Code,
+			Parameter: parameter,
+		})
+	}
+
+	txn, err := copyTransaction(p.Tx)
+	if err != nil {
+		return nil, err
+	}
+	txn.SetPrograms(programs)
+	return txn, nil
+}
+
+// Fee returns the fee of the transaction in ELA.
+func (p *Packet) Fee() common.Fixed64 {
+	var fee common.Fixed64
+	for _, utxo := range p.UTXOs {
+		if utxo.Output.AssetID.IsEqual(core.ELAAssetID) {
+			fee += utxo.Output.Value
+		}
+	}
+	for _, output := range p.Tx.Outputs() {
+		if output.AssetID.IsEqual(core.ELAAssetID) {
+			fee -= output.Value
+		}
+	}
+	return fee
+}
+
+// Status returns the number of collected and needed signatures of the
+// program.
+func (p *Program) Status() (have, need int, err error) {
+	publicKeys, _, err := getSigners(p.Code)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(publicKeys) == 1 {
+		return len(p.Signatures), 1, nil
+	}
+	m, err := crypto.GetM(p.Code)
+	return len(p.Signatures), int(m), err
+}
+
+func (p *Program) hasSigned(publicKey []byte) bool {
+	for _, s := range p.Signatures {
+		if bytes.Equal(s.PublicKey, publicKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// addSignature verifies the signature of the public key and adds it to the
+// program.
+func (p *Program) addSignature(publicKey, signature, data []byte) error {
+	publicKeys, _, err := getSigners(p.Code)
+	if err != nil {
+		return err
+	}
+	var found bool
+	for _, pk := range publicKeys {
+		if bytes.Equal(pk, publicKey) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return errors.New("public key is not a signer of the program")
+	}
+	if p.hasSigned(publicKey) {
+		return errors.New("public key already signed")
+	}
+	pubKey, err := crypto.DecodePoint(publicKey)
+	if err != nil {
+		return err
+	}
+	if err := crypto.Verify(*pubKey, data, signature); err != nil {
+		return errors.New("invalid signature")
+	}
+
+	p.Signatures = append(p.Signatures, &Signature{
+		PublicKey: publicKey,
+		Signature: signature,
+	})
+	return nil
+}
+
+func (p *Packet) getProgram(code []byte) *Program {
+	for _, program := range p.Programs {
+		if bytes.Equal(program.Code, code) {
+			return program
+		}
+	}
+	return nil
+}
+
+func (p *Packet) signData() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := p.Tx.SerializeUnsigned(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (p *Packet) copy() (*Packet, error) {
+	buf := new(bytes.Buffer)
+	if err := p.Serialize(buf); err != nil {
+		return nil, err
+	}
+	packet := new(Packet)
+	if err := packet.Deserialize(buf); err != nil {
+		return nil, err
+	}
+	return packet, nil
+}
+
+func (p *Packet) Serialize(w io.Writer) error {
+	if _, err := w.Write(magic[:]); err != nil {
+		return err
+	}
+	if err := common.WriteUint8(w, Version); err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	if err := p.Tx.Serialize(buf); err != nil {
+		return err
+	}
+	if err := common.WriteVarBytes(w, buf.Bytes()); err != nil {
+		return err
+	}
+
+	if err := common.WriteVarUint(w, uint64(len(p.UTXOs))); err != nil {
+		return err
+	}
+	for _, utxo := range p.UTXOs {
+		if err := common.WriteUint8(w, uint8(utxo.TxVersion)); err != nil {
+			return err
+		}
+		if err := utxo.Output.Serialize(w, utxo.TxVersion); err != nil {
+			return err
+		}
+	}
+
+	if err := common.WriteVarUint(w, uint64(len(p.Programs))); err != nil {
+		return err
+	}
+	for _, program := range p.Programs {
+		if err := common.WriteVarBytes(w, program.Code); err != nil {
+			return err
+		}
+		if err := common.WriteVarUint(w, uint64(len(program.Signatures))); err != nil {
+			return err
+		}
+		for _, s := range program.Signatures {
+			if err := common.WriteVarBytes(w, s.PublicKey); err != nil {
+				return err
+			}
+			if err := common.WriteVarBytes(w, s.Signature); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Packet) Deserialize(r io.Reader) error {
+	var m [5]byte
+	if _, err := io.ReadFull(r, m[:]); err != nil || m != magic {
+		return errors.New("not a partially signed transaction")
+	}
+	version, err := common.ReadUint8(r)
+	if err != nil {
+		return err
+	}
+	if version != Version {
+		return errors.New("unknown partially signed transaction version")
+	}
+
+	txBytes, err := common.ReadVarBytes(r, pact.MaxBlockContextSize,
+		"transaction")
+	if err != nil {
+		return err
+	}
+	txReader := bytes.NewReader(txBytes)
+	p.Tx, err = functions.GetTransactionByBytes(txReader)
+	if err != nil {
+		return err
+	}
+	if err := p.Tx.Deserialize(txReader); err != nil {
+		return err
+	}
+
+	count, err := common.ReadVarUint(r, 0)
+	if err != nil {
+		return err
+	}
+	if count != uint64(len(p.Tx.Inputs())) {
+		return errors.New("the number of UTXOs is different with number of inputs")
+	}
+	p.UTXOs = make([]*UTXO, 0, count)
+	for i := uint64(0); i < count; i++ {
+		txVersion, err := common.ReadUint8(r)
+		if err != nil {
+			return err
+		}
+		utxo := &UTXO{
+			TxVersion: common2.TransactionVersion(txVersion),
+			Output:    new(common2.Output),
+		}
+		if err := utxo.Output.Deserialize(r, utxo.TxVersion); err != nil {
+			return err
+		}
+		p.UTXOs = append(p.UTXOs, utxo)
+	}
+
+	// A program is carried for each program hash the transaction needs to be
+	// signed by, which are referenced by the UTXOs and script attributes.
+	maxPrograms := count
+	for _, attribute := range p.Tx.Attributes() {
+		if attribute.Usage == common2.Script {
+			maxPrograms++
+		}
+	}
+	count, err = common.ReadVarUint(r, 0)
+	if err != nil {
+		return err
+	}
+	if count > maxPrograms {
+		return errors.New("too many programs")
+	}
+	p.Programs = make([]*Program, 0, count)
+	for i := uint64(0); i < count; i++ {
+		code, err := common.ReadVarBytes(r, pg.MaxProgramCodeSize, "code")
+		if err != nil {
+			return err
+		}
+		publicKeys, _, err := getSigners(code)
+		if err != nil {
+			return err
+		}
+		signatures, err := common.ReadVarUint(r, 0)
+		if err != nil {
+			return err
+		}
+		if signatures > uint64(len(publicKeys)) {
+			return errors.New("too many signatures")
+		}
+		program := &Program{
+			Code:       code,
+			Signatures: make([]*Signature, 0, signatures),
+		}
+		for j := uint64(0); j < signatures; j++ {
+			publicKey, err := common.ReadVarBytes(r, crypto.COMPRESSEDLEN,
+				"public key")
+			if err != nil {
+				return err
+			}
+			signature, err := common.ReadVarBytes(r, crypto.SignatureLength,
+				"signature")
+			if err != nil {
+				return err
+			}
+			program.Signatures = append(program.Signatures, &Signature{
+				PublicKey: publicKey,
+				Signature: signature,
+			})
+		}
+		p.Programs = append(p.Programs, program)
+	}
+	return nil
+}
+
+// Encode returns the hex string of the serialized packet.
+func (p *Packet) Encode() (string, error) {
+	buf := new(bytes.Buffer)
+	if err := p.Serialize(buf); err != nil {
+		return "", err
+	}
+	return common.BytesToHexString(buf.Bytes()), nil
+}
+
+// Decode parses the packet from hex string.
+func Decode(s string) (*Packet, error) {
+	data, err := common.HexStringToBytes(s)
+	if err != nil {
+		return nil, errors.New("invalid partially signed transaction hex string")
+	}
+	packet := new(Packet)
+	if err := packet.Deserialize(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return packet, nil
+}
+
+// getSigners returns the public keys and the code hashes of the signers of
+// a standard or multi-signature redeem script.
+func getSigners(code []byte) ([][]byte, []common.Uint160, error) {
+	scriptType, err := crypto.GetScriptType(code)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch scriptType {
+	case common.STANDARD:
+		if len(code) != crypto.PublicKeyScriptLength {
+			return nil, nil, errors.New("invalid standard redeem script")
+		}
+		return [][]byte{code[1 : len(code)-1]}, []common.Uint160{*common.ToCodeHash(code)}, nil
+	case common.MULTISIG:
+		publicKeys, err := crypto.ParseMultisigScript(code)
+		if err != nil {
+			return nil, nil, err
+		}
+		codeHashes, err := account.GetSigners(code)
+		if err != nil {
+			return nil, nil, err
+		}
+		keys := make([][]byte, 0, len(publicKeys))
+		hashes := make([]common.Uint160, 0, len(codeHashes))
+		for i, publicKey := range publicKeys {
+			keys = append(keys, publicKey[1:])
+			hashes = append(hashes, *codeHashes[i])
+		}
+		return keys, hashes, nil
+	default:
+		return nil, nil, errors.New("only standard and multi-signature redeem scripts are supported")
+	}
+}
+
+// copyTransaction returns a deep copy of the transaction.
+func copyTransaction(txn interfaces.Transaction) (interfaces.Transaction, error) {
+	buf := new(bytes.Buffer)
+	if err := txn.Serialize(buf); err != nil {
+		return nil, err
+	}
+	copied, err := functions.GetTransactionByBytes(buf)
+	if err != nil {
+		return nil, err
+	}
+	if err := copied.Deserialize(buf); err != nil {
+		return nil, err
+	}
+	return copied, nil
+}
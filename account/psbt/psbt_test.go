@@ -0,0 +1,272 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package psbt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/account"
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core"
+	pg "github.com/elastos/Elastos.ELA/core/contract/program"
+	"github.com/elastos/Elastos.ELA/core/transaction"
+	common2 "github.com/elastos/Elastos.ELA/core/types/common"
+	"github.com/elastos/Elastos.ELA/core/types/functions"
+	"github.com/elastos/Elastos.ELA/core/types/outputpayload"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/crypto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	functions.GetTransactionByTxType = transaction.GetTransaction
+	functions.GetTransactionByBytes = transaction.GetTransactionByBytes
+	functions.CreateTransaction = transaction.CreateTransaction
+}
+
+func newAccounts(t *testing.T, n int) []*account.Account {
+	accounts := make([]*account.Account, 0, n)
+	for i := 0; i < n; i++ {
+		ac, err := account.NewAccount()
+		assert.NoError(t, err)
+		accounts = append(accounts, ac)
+	}
+	return accounts
+}
+
+func getAccountOf(accounts ...*account.Account) func(common.Uint160) *account.Account {
+	return func(codeHash common.Uint160) *account.Account {
+		for _, ac := range accounts {
+			if ac.ProgramHash.ToCodeHash() == codeHash {
+				return ac
+			}
+		}
+		return nil
+	}
+}
+
+func newOutput(programHash common.Uint168, value common.Fixed64) *common2.Output {
+	return &common2.Output{
+		AssetID:     core.ELAAssetID,
+		Value:       value,
+		ProgramHash: programHash,
+		Type:        common2.OTNone,
+		Payload:     &outputpayload.DefaultOutput{},
+	}
+}
+
+func TestPacket(t *testing.T) {
+	signers := newAccounts(t, 3)
+	multiSig, err := account.NewMultiSigAccount(2, []*crypto.PublicKey{
+		signers[0].PublicKey, signers[1].PublicKey, signers[2].PublicKey})
+	assert.NoError(t, err)
+	standard := newAccounts(t, 1)[0]
+
+	txn := functions.CreateTransaction(
+		common2.TxVersion09,
+		common2.TransferAsset,
+		0,
+		&payload.TransferAsset{},
+		[]*common2.Attribute{},
+		[]*common2.Input{
+			{Previous: common2.OutPoint{TxID: common.Uint256{1}, Index: 0}},
+			{Previous: common2.OutPoint{TxID: common.Uint256{2}, Index: 1}},
+		},
+		[]*common2.Output{newOutput(standard.ProgramHash, 250)},
+		0,
+		[]*pg.Program{},
+	)
+	utxos := []*UTXO{
+		{TxVersion: common2.TxVersion09, Output: newOutput(multiSig.ProgramHash, 200)},
+		{TxVersion: common2.TxVersionDefault, Output: &common2.Output{
+			AssetID:     core.ELAAssetID,
+			Value:       100,
+			ProgramHash: standard.ProgramHash,
+		}},
+	}
+
+	// the redeem script of multi-signature account is required
+	_, err = New(txn, utxos, [][]byte{standard.RedeemScript})
+	assert.Error(t, err)
+	_, err = New(txn, utxos[:1], [][]byte{multiSig.RedeemScript})
+	assert.Error(t, err)
+
+	packet, err := New(txn, utxos, [][]byte{multiSig.RedeemScript,
+		standard.RedeemScript, signers[0].RedeemScript})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(packet.Programs))
+	assert.Equal(t, common.Fixed64(50), packet.Fee())
+	assert.False(t, packet.IsComplete())
+
+	encoded, err := packet.Encode()
+	assert.NoError(t, err)
+	decoded, err := Decode(encoded)
+	assert.NoError(t, err)
+	reencoded, err := decoded.Encode()
+	assert.NoError(t, err)
+	assert.Equal(t, encoded, reencoded)
+	_, err = Decode(encoded[2:])
+	assert.Error(t, err)
+
+	// signers sign their own copies offline
+	first, _ := Decode(encoded)
+	signed, err := first.Sign(getAccountOf(signers[0], standard))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, signed)
+	second, _ := Decode(encoded)
+	signed, err = second.Sign(getAccountOf(signers[2]))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, signed)
+	assert.False(t, first.IsComplete())
+	_, err = first.Finalize()
+	assert.Error(t, err)
+
+	combined, err := Combine(first, second, first)
+	assert.NoError(t, err)
+	assert.True(t, combined.IsComplete())
+	signed, err = combined.Sign(getAccountOf(signers...))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, signed)
+
+	final, err := combined.Finalize()
+	assert.NoError(t, err)
+	assert.Equal(t, txn.Hash(), final.Hash())
+	data := new(bytes.Buffer)
+	assert.NoError(t, final.SerializeUnsigned(data))
+	for _, program := range final.Programs() {
+		if bytes.Equal(program.Code, multiSig.RedeemScript) {
+			publicKeys, err := crypto.ParseMultisigScript(program.Code)
+			assert.NoError(t, err)
+			assert.NoError(t, crypto.VerifyMultisigSignatures(2, 3,
+				publicKeys, program.Parameter, data.Bytes()))
+		} else {
+			assert.NoError(t, crypto.Verify(*standard.PublicKey, data.Bytes(),
+				program.Parameter[1:]))
+		}
+	}
+
+	// signatures of a partially signed transaction are collected
+	txn.SetPrograms([]*pg.Program{{Code: multiSig.RedeemScript}})
+	multiSigProgram, err := account.SignMultiSignTransaction(txn,
+		txn.Programs()[0], map[common.Uint160]*account.Account{
+			signers[1].ProgramHash.ToCodeHash(): signers[1],
+		})
+	assert.NoError(t, err)
+	txn.SetPrograms([]*pg.Program{multiSigProgram})
+	imported, err := New(txn, utxos, [][]byte{standard.RedeemScript})
+	assert.NoError(t, err)
+	index := programIndex(imported, multiSig.RedeemScript)
+	assert.Equal(t, 1, len(imported.Programs[index].Signatures))
+	combined, err = Combine(imported, second)
+	assert.NoError(t, err)
+	have, need, err := combined.Programs[index].Status()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, have)
+	assert.Equal(t, 2, need)
+
+	// packets of other transactions can not be combined
+	other, err := New(functions.CreateTransaction(
+		common2.TxVersion09,
+		common2.TransferAsset,
+		0,
+		&payload.TransferAsset{},
+		[]*common2.Attribute{},
+		txn.Inputs(),
+		[]*common2.Output{newOutput(standard.ProgramHash, 200)},
+		0,
+		[]*pg.Program{},
+	), utxos, [][]byte{multiSig.RedeemScript, standard.RedeemScript})
+	assert.NoError(t, err)
+	_, err = Combine(first, other)
+	assert.Error(t, err)
+
+	// forged signatures are rejected
+	forged, _ := Decode(encoded)
+	forged.Programs[index].Signatures = []*Signature{{
+		PublicKey: second.Programs[index].Signatures[0].PublicKey,
+		Signature: make([]byte, crypto.SignatureLength),
+	}}
+	_, err = Combine(first, forged)
+	assert.Error(t, err)
+}
+
+func TestPacket_DeserializeGarbage(t *testing.T) {
+	signers := newAccounts(t, 2)
+	txn := functions.CreateTransaction(
+		common2.TxVersion09,
+		common2.TransferAsset,
+		0,
+		&payload.TransferAsset{},
+		[]*common2.Attribute{},
+		[]*common2.Input{
+			{Previous: common2.OutPoint{TxID: common.Uint256{1}, Index: 0}},
+		},
+		[]*common2.Output{newOutput(signers[1].ProgramHash, 50)},
+		0,
+		[]*pg.Program{},
+	)
+	utxos := []*UTXO{{TxVersion: common2.TxVersion09,
+		Output: newOutput(signers[0].ProgramHash, 100)}}
+	packet, err := New(txn, utxos, [][]byte{signers[0].RedeemScript})
+	assert.NoError(t, err)
+	_, err = packet.Sign(getAccountOf(signers[0]))
+	assert.NoError(t, err)
+	buf := new(bytes.Buffer)
+	assert.NoError(t, packet.Serialize(buf))
+	data := buf.Bytes()
+
+	// the packet without programs ends with the count of programs
+	buf = new(bytes.Buffer)
+	assert.NoError(t, (&Packet{Tx: packet.Tx, UTXOs: packet.UTXOs}).Serialize(buf))
+	prefix := buf.Bytes()[:buf.Len()-1]
+
+	// counts larger than needed are refused before allocating
+	var huge [9]byte
+	huge[0] = 0xff
+	binary.LittleEndian.PutUint64(huge[1:], math.MaxUint64)
+	garbage := append(append([]byte{}, prefix...), huge[:]...)
+	err = new(Packet).Deserialize(bytes.NewReader(garbage))
+	assert.EqualError(t, err, "too many programs")
+	garbage = append(append([]byte{}, prefix...), 2)
+	err = new(Packet).Deserialize(bytes.NewReader(garbage))
+	assert.EqualError(t, err, "too many programs")
+
+	garbage = append(append([]byte{}, prefix...), 1)
+	w := bytes.NewBuffer(garbage)
+	assert.NoError(t, common.WriteVarBytes(w, signers[0].RedeemScript))
+	w.Write(huge[:])
+	err = new(Packet).Deserialize(bytes.NewReader(w.Bytes()))
+	assert.EqualError(t, err, "too many signatures")
+
+	// truncated and corrupted packets fail without panic
+	for i := 0; i < len(data); i++ {
+		assert.Error(t, new(Packet).Deserialize(bytes.NewReader(data[:i])))
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		corrupted := append([]byte{}, data...)
+		for j := 0; j < 1+rnd.Intn(4); j++ {
+			corrupted[rnd.Intn(len(corrupted))] = byte(rnd.Intn(256))
+		}
+		assert.NotPanics(t, func() {
+			new(Packet).Deserialize(bytes.NewReader(corrupted))
+		})
+	}
+}
+
+func programIndex(packet *Packet, code []byte) int {
+	for i, program := range packet.Programs {
+		if bytes.Equal(program.Code, code) {
+			return i
+		}
+	}
+	return -1
+}
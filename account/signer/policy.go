@@ -0,0 +1,275 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package signer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/elastos/Elastos.ELA/common"
+	common2 "github.com/elastos/Elastos.ELA/core/types/common"
+	"github.com/elastos/Elastos.ELA/core/types/functions"
+	"github.com/elastos/Elastos.ELA/core/types/interfaces"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/crypto"
+)
+
+var (
+	// ErrDoubleSign is returned when signing a proposal or vote conflicting
+	// with the one signed before at the same or a higher round.
+	ErrDoubleSign = errors.New("conflicts with the proposal or vote signed before")
+
+	// ErrTxNotAllowed is returned when signing a transaction type which is
+	// not allowed by the policy.
+	ErrTxNotAllowed = errors.New("transaction type is not allowed to sign")
+)
+
+// Policy is the policy of the requests the signer daemon signs.
+type Policy struct {
+	// AllowTx allows to sign transactions of any type, otherwise only the
+	// RevertToDPOS and InactiveArbitrators transactions signed by arbiters
+	// are signed.
+	AllowTx bool
+
+	// StatePath is the file saving the rounds signed last, so the double
+	// sign protection survives restarts of the daemon.  The rounds are kept
+	// in memory only if it is empty.
+	StatePath string
+}
+
+// round is the consensus round of a signed proposal or vote.
+type round struct {
+	Height uint32
+	View   uint32
+	Hash   common.Uint256
+	Accept bool
+}
+
+// compare returns -1, 0 or 1 if the height and view of r is lower, equal or
+// higher than the one of o.
+func (r *round) compare(o *round) int {
+	switch {
+	case r.Height < o.Height:
+		return -1
+	case r.Height > o.Height:
+		return 1
+	case r.View < o.View:
+		return -1
+	case r.View > o.View:
+		return 1
+	}
+	return 0
+}
+
+// signedRounds is the rounds of proposal and vote signed last by a key.
+type signedRounds struct {
+	Proposal *round `json:",omitempty"`
+	Vote     *round `json:",omitempty"`
+}
+
+// guard refuses to sign proposals and votes conflicting with the ones signed
+// before by the same key, which is a double sign punished by the consensus.
+type guard struct {
+	mtx    sync.Mutex
+	path   string
+	rounds map[string]*signedRounds
+}
+
+func newGuard(path string) (*guard, error) {
+	g := &guard{path: path, rounds: make(map[string]*signedRounds)}
+	if path == "" {
+		return g, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return g, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &g.rounds); err != nil {
+		return nil, errors.New("invalid signer state " + path + ": " + err.Error())
+	}
+	return g, nil
+}
+
+// sign calls signFunc if r does not conflict with the round signed last of
+// the key, and records r as the round signed last on success.  Signing the
+// same round again is allowed, so a request retried after a lost reply is
+// not refused.
+func (g *guard) sign(publicKey *crypto.PublicKey, vote bool, r *round,
+	signFunc func() ([]byte, error)) ([]byte, error) {
+	encoded, err := publicKey.EncodePoint(true)
+	if err != nil {
+		return nil, err
+	}
+	key := common.BytesToHexString(encoded)
+
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	rounds, ok := g.rounds[key]
+	if !ok {
+		rounds = &signedRounds{}
+	}
+	last := rounds.Proposal
+	if vote {
+		last = rounds.Vote
+	}
+	if last != nil {
+		switch r.compare(last) {
+		case -1:
+			return nil, ErrDoubleSign
+		case 0:
+			if !r.Hash.IsEqual(last.Hash) || r.Accept != last.Accept {
+				return nil, ErrDoubleSign
+			}
+		}
+	}
+
+	signature, err := signFunc()
+	if err != nil {
+		return nil, err
+	}
+	if last != nil && r.compare(last) == 0 {
+		return signature, nil
+	}
+
+	updated := *rounds
+	if vote {
+		updated.Vote = r
+	} else {
+		updated.Proposal = r
+	}
+	g.rounds[key] = &updated
+	if err := g.save(); err != nil {
+		// the signature is not returned if the round can not be saved,
+		// otherwise it may be signed again after the daemon restarts.
+		g.rounds[key] = rounds
+		return nil, err
+	}
+	return signature, nil
+}
+
+func (g *guard) save() error {
+	if g.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(g.rounds)
+	if err != nil {
+		return err
+	}
+	tmp := g.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, g.path)
+}
+
+// parseHeader returns the header of which hash is the block hash of the
+// proposal.
+func parseHeader(data []byte, proposal *payload.DPOSProposal) (
+	*common2.Header, error) {
+	var header common2.Header
+	r := bytes.NewReader(data)
+	if err := header.Deserialize(r); err != nil || r.Len() != 0 {
+		return nil, errors.New("invalid block header")
+	}
+	if !header.Hash().IsEqual(proposal.BlockHash) {
+		return nil, errors.New("block header mismatches the proposal")
+	}
+	return &header, nil
+}
+
+// parseProposal returns the unsigned proposal of the data.
+func parseProposal(data []byte) (*payload.DPOSProposal, error) {
+	var proposal payload.DPOSProposal
+	r := bytes.NewReader(data)
+	if err := proposal.DeserializeUnSigned(r); err != nil || r.Len() != 0 {
+		return nil, errors.New("invalid proposal")
+	}
+	return &proposal, nil
+}
+
+// parseVote returns the unsigned vote of the data.
+func parseVote(data []byte) (*payload.DPOSProposalVote, error) {
+	var vote payload.DPOSProposalVote
+	r := bytes.NewReader(data)
+	if err := vote.DeserializeUnsigned(r); err != nil || r.Len() != 0 {
+		return nil, errors.New("invalid vote")
+	}
+	return &vote, nil
+}
+
+// parseTransaction returns the signed or unsigned transaction of the data.
+func parseTransaction(data []byte, unsigned bool) (interfaces.Transaction,
+	error) {
+	r := bytes.NewReader(data)
+	txn, err := functions.GetTransactionByBytes(r)
+	if err != nil {
+		return nil, err
+	}
+	if unsigned {
+		err = txn.DeserializeUnsigned(r)
+	} else {
+		err = txn.Deserialize(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		return nil, errors.New("unexpected data after transaction")
+	}
+	return txn, nil
+}
+
+// checkTx returns if the policy allows to sign the transaction.
+func (p *Policy) checkTx(txn interfaces.Transaction) error {
+	if p.AllowTx {
+		return nil
+	}
+	switch txn.TxType() {
+	case common2.RevertToDPOS, common2.InactiveArbitrators:
+		return nil
+	}
+	return ErrTxNotAllowed
+}
+
+// checkMessage refuses to sign the data of a message which is able to be
+// used as a proposal, vote or transaction, so the messages such as the peer
+// handshake are not a way around the checks of the typed requests.
+func checkMessage(data []byte) error {
+	if _, err := parseProposal(data); err == nil {
+		return errors.New("message is a proposal")
+	}
+	if _, err := parseVote(data); err == nil {
+		return errors.New("message is a vote")
+	}
+	if _, err := parseTransaction(data, true); err == nil {
+		return errors.New("message is a transaction")
+	}
+	return nil
+}
+
+// checkAddr returns if the decrypted data is a network address, so the
+// daemon is not a way to decrypt anything encrypted to the key.
+func checkAddr(plain []byte) error {
+	if _, _, err := net.SplitHostPort(string(plain)); err != nil {
+		return errors.New("decrypted data is not an address")
+	}
+	return nil
+}
+
+// isSignedBy returns if the public key is the encoded one.
+func isSignedBy(publicKey *crypto.PublicKey, encoded []byte) bool {
+	pk, err := crypto.DecodePoint(encoded)
+	return err == nil && crypto.Equal(pk, publicKey)
+}
@@ -0,0 +1,269 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package signer
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/common"
+	common2 "github.com/elastos/Elastos.ELA/core/types/common"
+	"github.com/elastos/Elastos.ELA/core/types/interfaces"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/crypto"
+)
+
+const (
+	// dialTimeout is the timeout to connect to the signer daemon.
+	dialTimeout = 5 * time.Second
+
+	// DefaultCallTimeout is the default timeout of a request to the signer
+	// daemon.
+	DefaultCallTimeout = 10 * time.Second
+)
+
+// ErrTimeout is returned when the signer daemon does not reply in time.
+var ErrTimeout = errors.New("signer request timeout")
+
+// Client is the client of signer daemon, it reconnects to the daemon on the
+// next request if the connection is broken.
+type Client struct {
+	address string
+	token   string
+
+	// Timeout is the timeout of a request to the signer daemon.
+	Timeout time.Duration
+
+	mtx    sync.Mutex
+	client *rpc.Client
+}
+
+// Dial connects to the signer daemon of the address, which is a Unix socket
+// path optionally prefixed by "unix://".
+func Dial(address, token string) (*Client, error) {
+	c := &Client{
+		address: address,
+		token:   token,
+		Timeout: DefaultCallTimeout,
+	}
+	if _, err := c.connect(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// PublicKeys returns the public keys held by the signer daemon.
+func (c *Client) PublicKeys() ([]*crypto.PublicKey, error) {
+	var reply PublicKeysReply
+	if err := c.call("PublicKeys", &PublicKeysArgs{Token: c.token},
+		&reply); err != nil {
+		return nil, err
+	}
+	publicKeys := make([]*crypto.PublicKey, 0, len(reply.PublicKeys))
+	for _, pk := range reply.PublicKeys {
+		pkBytes, err := common.HexStringToBytes(pk)
+		if err != nil {
+			return nil, err
+		}
+		publicKey, err := crypto.DecodePoint(pkBytes)
+		if err != nil {
+			return nil, err
+		}
+		publicKeys = append(publicKeys, publicKey)
+	}
+	return publicKeys, nil
+}
+
+// Signer returns the signer of the public key held by the signer daemon.
+func (c *Client) Signer(publicKey *crypto.PublicKey) (Signer, error) {
+	encoded, err := publicKey.EncodePoint(true)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteSigner{
+		client:    c,
+		publicKey: publicKey,
+		encoded:   common.BytesToHexString(encoded),
+	}, nil
+}
+
+// Signers returns the signers of all public keys held by the signer daemon.
+func (c *Client) Signers() ([]Signer, error) {
+	publicKeys, err := c.PublicKeys()
+	if err != nil {
+		return nil, err
+	}
+	signers := make([]Signer, 0, len(publicKeys))
+	for _, pk := range publicKeys {
+		s, err := c.Signer(pk)
+		if err != nil {
+			return nil, err
+		}
+		signers = append(signers, s)
+	}
+	return signers, nil
+}
+
+// Close closes the connection to the signer daemon.
+func (c *Client) Close() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.client == nil {
+		return nil
+	}
+	err := c.client.Close()
+	c.client = nil
+	return err
+}
+
+func (c *Client) connect() (*rpc.Client, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.client != nil {
+		return c.client, nil
+	}
+	addr, err := parseAddress(c.address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout("unix", addr, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c.client = jsonrpc.NewClient(conn)
+	return c.client, nil
+}
+
+// reset drops the broken connection, so the next request reconnects.
+func (c *Client) reset(client *rpc.Client) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.client == client {
+		c.client.Close()
+		c.client = nil
+	}
+}
+
+func (c *Client) call(method string, args interface{},
+	reply interface{}) error {
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+
+	call := client.Go(serviceName+"."+method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		err = call.Error
+	case <-time.After(c.Timeout):
+		c.reset(client)
+		return ErrTimeout
+	}
+
+	// errors replied by the daemon are rpc.ServerError, others are errors
+	// of the connection.
+	if _, ok := err.(rpc.ServerError); err != nil && !ok {
+		c.reset(client)
+	}
+	return err
+}
+
+// remoteSigner is the signer of a public key held by the signer daemon.
+type remoteSigner struct {
+	client    *Client
+	publicKey *crypto.PublicKey
+	encoded   string
+}
+
+func (s *remoteSigner) PublicKey() *crypto.PublicKey {
+	return s.publicKey
+}
+
+// SignProposal requests the signer daemon to sign the proposal of the block
+// header, and the signature is verified before returned.
+func (s *remoteSigner) SignProposal(proposal *payload.DPOSProposal,
+	header *common2.Header) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := header.Serialize(buf); err != nil {
+		return nil, err
+	}
+	return s.sign("SignProposal", proposal.Data(), &SignArgs{
+		Data:   proposal.Data(),
+		Header: buf.Bytes(),
+	})
+}
+
+// SignVote requests the signer daemon to sign the vote on the proposal of
+// the block header, and the signature is verified before returned.
+func (s *remoteSigner) SignVote(vote *payload.DPOSProposalVote,
+	proposal *payload.DPOSProposal, header *common2.Header) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := header.Serialize(buf); err != nil {
+		return nil, err
+	}
+	return s.sign("SignVote", vote.Data(), &SignArgs{
+		Data:     vote.Data(),
+		Proposal: proposal.Data(),
+		Header:   buf.Bytes(),
+	})
+}
+
+// SignTx requests the signer daemon to sign the transaction, and the
+// signature is verified before returned.
+func (s *remoteSigner) SignTx(txn interfaces.Transaction) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := txn.SerializeUnsigned(buf); err != nil {
+		return nil, err
+	}
+	data := buf.Bytes()
+	buf = new(bytes.Buffer)
+	if err := txn.Serialize(buf); err != nil {
+		return nil, err
+	}
+	return s.sign("SignTx", data, &SignArgs{Data: buf.Bytes()})
+}
+
+// SignMessage requests the signer daemon to sign the message, and the
+// signature is verified before returned.
+func (s *remoteSigner) SignMessage(data []byte) ([]byte, error) {
+	return s.sign("SignMessage", data, &SignArgs{Data: data})
+}
+
+func (s *remoteSigner) Decrypt(cipher []byte) ([]byte, error) {
+	var reply SignReply
+	if err := s.client.call("Decrypt", &SignArgs{
+		Token:     s.client.token,
+		PublicKey: s.encoded,
+		Data:      cipher,
+	}, &reply); err != nil {
+		return nil, err
+	}
+	return reply.Data, nil
+}
+
+// sign sends the signing request of the method, and verifies the replied
+// signature of the data.
+func (s *remoteSigner) sign(method string, data []byte,
+	args *SignArgs) ([]byte, error) {
+	args.Token = s.client.token
+	args.PublicKey = s.encoded
+	var reply SignReply
+	if err := s.client.call(method, args, &reply); err != nil {
+		return nil, err
+	}
+	if err := crypto.Verify(*s.publicKey, data, reply.Data); err != nil {
+		return nil, errors.New("invalid signature from signer daemon")
+	}
+	return reply.Data, nil
+}
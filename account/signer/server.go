@@ -0,0 +1,417 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package signer
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/elastos/Elastos.ELA/account"
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/crypto"
+)
+
+const (
+	// serviceName is the name of RPC service of the signer daemon.
+	serviceName = "Signer"
+
+	unixPrefix = "unix://"
+	tcpPrefix  = "tcp://"
+)
+
+var (
+	// ErrUnauthorized is returned when the token of request mismatches.
+	ErrUnauthorized = errors.New("unauthorized signer request")
+
+	// ErrUnknownKey is returned when the signer daemon does not hold the
+	// private key of the public key.
+	ErrUnknownKey = errors.New("unknown public key")
+
+	// ErrTCPUnsupported is returned when the address of signer daemon is a
+	// TCP address.
+	ErrTCPUnsupported = errors.New("signer daemon listens on Unix socket " +
+		"only, forward the Unix socket by SSH to reach it from another host")
+)
+
+// Backend holds the private keys of the signer daemon.
+type Backend interface {
+	// PublicKeys returns the public keys of which private keys are held.
+	PublicKeys() []*crypto.PublicKey
+
+	// Sign signs the data by the private key of the public key.
+	Sign(publicKey *crypto.PublicKey, data []byte) ([]byte, error)
+
+	// Decrypt decrypts the cipher by the private key of the public key.
+	Decrypt(publicKey *crypto.PublicKey, cipher []byte) ([]byte, error)
+}
+
+// fileBackend is the backend of accounts loaded from a keystore file.
+type fileBackend struct {
+	accounts []*account.Account
+}
+
+func (b *fileBackend) PublicKeys() []*crypto.PublicKey {
+	publicKeys := make([]*crypto.PublicKey, 0, len(b.accounts))
+	for _, ac := range b.accounts {
+		publicKeys = append(publicKeys, ac.PublicKey)
+	}
+	return publicKeys
+}
+
+func (b *fileBackend) Sign(publicKey *crypto.PublicKey,
+	data []byte) ([]byte, error) {
+	ac := b.getAccount(publicKey)
+	if ac == nil {
+		return nil, ErrUnknownKey
+	}
+	return crypto.Sign(ac.PrivateKey, data)
+}
+
+func (b *fileBackend) Decrypt(publicKey *crypto.PublicKey,
+	cipher []byte) ([]byte, error) {
+	ac := b.getAccount(publicKey)
+	if ac == nil {
+		return nil, ErrUnknownKey
+	}
+	return crypto.Decrypt(ac.PrivateKey, cipher)
+}
+
+func (b *fileBackend) getAccount(publicKey *crypto.PublicKey) *account.Account {
+	for _, ac := range b.accounts {
+		if crypto.Equal(ac.PublicKey, publicKey) {
+			return ac
+		}
+	}
+	return nil
+}
+
+// NewFileBackend returns the backend of standard accounts in the keystore
+// file, the public key of main account is the first one.
+func NewFileBackend(path string, password []byte) (Backend, error) {
+	client, err := account.Open(path, password)
+	if err != nil {
+		return nil, err
+	}
+	main := client.GetMainAccount()
+	var accounts []*account.Account
+	if main != nil && main.PrivateKey != nil {
+		accounts = append(accounts, main)
+	}
+	for _, ac := range client.GetAccounts() {
+		if ac != main && ac.PrivateKey != nil {
+			accounts = append(accounts, ac)
+		}
+	}
+	if len(accounts) == 0 {
+		return nil, errors.New("no private key in keystore " + path)
+	}
+	return &fileBackend{accounts: accounts}, nil
+}
+
+// PublicKeysArgs is the arguments of Signer.PublicKeys.
+type PublicKeysArgs struct {
+	Token string
+}
+
+// PublicKeysReply is the reply of Signer.PublicKeys.
+type PublicKeysReply struct {
+	PublicKeys []string
+}
+
+// SignArgs is the arguments of the signing requests and Signer.Decrypt.
+type SignArgs struct {
+	Token     string
+	PublicKey string
+
+	// Data is the unsigned proposal of Signer.SignProposal, the unsigned
+	// vote of Signer.SignVote, the transaction of Signer.SignTx, the message
+	// of Signer.SignMessage, or the cipher of Signer.Decrypt.
+	Data []byte
+
+	// Proposal is the unsigned proposal voted by Signer.SignVote.
+	Proposal []byte
+
+	// Header is the header of the proposed block of Signer.SignProposal and
+	// Signer.SignVote, which is the height of the round signed.
+	Header []byte
+}
+
+// SignReply is the reply of the signing requests and Signer.Decrypt.
+type SignReply struct {
+	Data []byte
+}
+
+// Service is the RPC service of the signer daemon, which checks the requests
+// by the policy before using the private keys of the backend.
+type Service struct {
+	backend Backend
+	token   string
+	policy  Policy
+	guard   *guard
+}
+
+func (s *Service) PublicKeys(args *PublicKeysArgs,
+	reply *PublicKeysReply) error {
+	if !s.authorized(args.Token) {
+		return ErrUnauthorized
+	}
+	for _, pk := range s.backend.PublicKeys() {
+		encoded, err := pk.EncodePoint(true)
+		if err != nil {
+			return err
+		}
+		reply.PublicKeys = append(reply.PublicKeys,
+			common.BytesToHexString(encoded))
+	}
+	return nil
+}
+
+// SignProposal signs the proposal sponsored by the key, unless another block
+// is proposed at the same height and view, or a higher round is proposed.
+func (s *Service) SignProposal(args *SignArgs, reply *SignReply) error {
+	publicKey, err := s.parseArgs(args)
+	if err != nil {
+		return err
+	}
+	proposal, err := parseProposal(args.Data)
+	if err != nil {
+		return err
+	}
+	if !isSignedBy(publicKey, proposal.Sponsor) {
+		return errors.New("proposal is not sponsored by the key")
+	}
+	header, err := parseHeader(args.Header, proposal)
+	if err != nil {
+		return err
+	}
+	reply.Data, err = s.guard.sign(publicKey, false, &round{
+		Height: header.Height,
+		View:   proposal.ViewOffset,
+		Hash:   proposal.BlockHash,
+	}, func() ([]byte, error) {
+		return s.backend.Sign(publicKey, args.Data)
+	})
+	return err
+}
+
+// SignVote signs the vote of the key on the proposal, unless another
+// proposal is voted or the vote is changed at the same height and view, or a
+// higher round is voted.
+func (s *Service) SignVote(args *SignArgs, reply *SignReply) error {
+	publicKey, err := s.parseArgs(args)
+	if err != nil {
+		return err
+	}
+	vote, err := parseVote(args.Data)
+	if err != nil {
+		return err
+	}
+	if !isSignedBy(publicKey, vote.Signer) {
+		return errors.New("vote is not signed by the key")
+	}
+	proposal, err := parseProposal(args.Proposal)
+	if err != nil {
+		return err
+	}
+	if !vote.ProposalHash.IsEqual(proposal.Hash()) {
+		return errors.New("vote mismatches the proposal")
+	}
+	header, err := parseHeader(args.Header, proposal)
+	if err != nil {
+		return err
+	}
+	reply.Data, err = s.guard.sign(publicKey, true, &round{
+		Height: header.Height,
+		View:   proposal.ViewOffset,
+		Hash:   vote.ProposalHash,
+		Accept: vote.Accept,
+	}, func() ([]byte, error) {
+		return s.backend.Sign(publicKey, args.Data)
+	})
+	return err
+}
+
+// SignTx signs the unsigned data of the transaction if the transaction type
+// is allowed by the policy.
+func (s *Service) SignTx(args *SignArgs, reply *SignReply) error {
+	publicKey, err := s.parseArgs(args)
+	if err != nil {
+		return err
+	}
+	txn, err := parseTransaction(args.Data, false)
+	if err != nil {
+		return err
+	}
+	if err := s.policy.checkTx(txn); err != nil {
+		return err
+	}
+	buf := new(bytes.Buffer)
+	if err := txn.SerializeUnsigned(buf); err != nil {
+		return err
+	}
+	reply.Data, err = s.backend.Sign(publicKey, buf.Bytes())
+	return err
+}
+
+// SignMessage signs the data of a peer message, such as the nonce of the
+// handshake and the address of arbiter, which is not a proposal, vote or
+// transaction.
+func (s *Service) SignMessage(args *SignArgs, reply *SignReply) error {
+	publicKey, err := s.parseArgs(args)
+	if err != nil {
+		return err
+	}
+	if err := checkMessage(args.Data); err != nil {
+		return err
+	}
+	reply.Data, err = s.backend.Sign(publicKey, args.Data)
+	return err
+}
+
+// Decrypt decrypts the address of an arbiter encrypted to the key, other
+// data is not replied.
+func (s *Service) Decrypt(args *SignArgs, reply *SignReply) error {
+	publicKey, err := s.parseArgs(args)
+	if err != nil {
+		return err
+	}
+	plain, err := s.backend.Decrypt(publicKey, args.Data)
+	if err != nil {
+		return err
+	}
+	if err := checkAddr(plain); err != nil {
+		return err
+	}
+	reply.Data = plain
+	return nil
+}
+
+func (s *Service) parseArgs(args *SignArgs) (*crypto.PublicKey, error) {
+	if !s.authorized(args.Token) {
+		return nil, ErrUnauthorized
+	}
+	publicKey, err := common.HexStringToBytes(args.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.DecodePoint(publicKey)
+}
+
+func (s *Service) authorized(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(s.token), []byte(token)) == 1
+}
+
+// Server serves the signer daemon by JSON-RPC over Unix socket.
+type Server struct {
+	server   *rpc.Server
+	mtx      sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+}
+
+// NewServer returns the server of the backend signing the requests allowed
+// by the policy, requests without the token are rejected if it is not empty.
+func NewServer(backend Backend, token string, policy Policy) (*Server, error) {
+	g, err := newGuard(policy.StatePath)
+	if err != nil {
+		return nil, err
+	}
+	server := rpc.NewServer()
+	err = server.RegisterName(serviceName, &Service{
+		backend: backend,
+		token:   token,
+		policy:  policy,
+		guard:   g,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Server{server: server, conns: make(map[net.Conn]struct{})}, nil
+}
+
+// Serve accepts connections of the listener until it is closed.
+func (s *Server) Serve(listener net.Listener) error {
+	s.mtx.Lock()
+	s.listener = listener
+	s.mtx.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		s.mtx.Lock()
+		s.conns[conn] = struct{}{}
+		s.mtx.Unlock()
+
+		go func() {
+			s.server.ServeCodec(jsonrpc.NewServerCodec(conn))
+			s.mtx.Lock()
+			delete(s.conns, conn)
+			s.mtx.Unlock()
+		}()
+	}
+}
+
+// Close closes the listener and the connections.
+func (s *Server) Close() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	return err
+}
+
+// Listen listens on the Unix socket of signer daemon, of which path is
+// optionally prefixed by "unix://".  The stale Unix socket file is removed,
+// and the new one is accessible by the owner only.  TCP is not supported as
+// the requests are not encrypted, the Unix socket is forwarded by SSH to
+// reach the daemon from another host.
+func Listen(address string) (net.Listener, error) {
+	addr, err := parseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(addr); err == nil {
+		if conn, err := net.Dial("unix", addr); err == nil {
+			conn.Close()
+			return nil, errors.New("signer daemon is already listening on " + addr)
+		}
+		if err := os.Remove(addr); err != nil {
+			return nil, err
+		}
+	}
+	listener, err := net.Listen("unix", addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(addr, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// parseAddress returns the path of the Unix socket of the address.
+func parseAddress(address string) (string, error) {
+	if strings.HasPrefix(address, tcpPrefix) {
+		return "", ErrTCPUnsupported
+	}
+	return strings.TrimPrefix(address, unixPrefix), nil
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package signer
+
+import (
+	"bytes"
+
+	"github.com/elastos/Elastos.ELA/account"
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/contract"
+	pg "github.com/elastos/Elastos.ELA/core/contract/program"
+	common2 "github.com/elastos/Elastos.ELA/core/types/common"
+	"github.com/elastos/Elastos.ELA/core/types/interfaces"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/crypto"
+	"github.com/elastos/Elastos.ELA/vm"
+)
+
+// Signer signs by the private key of a public key, the private key may be
+// kept in process or by an external signer daemon.
+type Signer interface {
+	// PublicKey returns the public key of the signer.
+	PublicKey() *crypto.PublicKey
+
+	// SignProposal signs the unsigned data of the proposal of the block
+	// header.
+	SignProposal(proposal *payload.DPOSProposal,
+		header *common2.Header) ([]byte, error)
+
+	// SignVote signs the unsigned data of the vote on the proposal of the
+	// block header.
+	SignVote(vote *payload.DPOSProposalVote, proposal *payload.DPOSProposal,
+		header *common2.Header) ([]byte, error)
+
+	// SignTx signs the unsigned data of the transaction.
+	SignTx(txn interfaces.Transaction) ([]byte, error)
+
+	// SignMessage signs the data of a peer message, which is not a
+	// proposal, vote or transaction.
+	SignMessage(data []byte) ([]byte, error)
+
+	// Decrypt decrypts the address encrypted to the public key.
+	Decrypt(cipher []byte) ([]byte, error)
+}
+
+// localSigner is the signer keeping the private key in process.
+type localSigner struct {
+	account *account.Account
+}
+
+func (s *localSigner) PublicKey() *crypto.PublicKey {
+	return s.account.PublicKey
+}
+
+func (s *localSigner) SignProposal(proposal *payload.DPOSProposal,
+	header *common2.Header) ([]byte, error) {
+	return crypto.Sign(s.account.PrivateKey, proposal.Data())
+}
+
+func (s *localSigner) SignVote(vote *payload.DPOSProposalVote,
+	proposal *payload.DPOSProposal, header *common2.Header) ([]byte, error) {
+	return crypto.Sign(s.account.PrivateKey, vote.Data())
+}
+
+func (s *localSigner) SignTx(txn interfaces.Transaction) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := txn.SerializeUnsigned(buf); err != nil {
+		return nil, err
+	}
+	return crypto.Sign(s.account.PrivateKey, buf.Bytes())
+}
+
+func (s *localSigner) SignMessage(data []byte) ([]byte, error) {
+	return crypto.Sign(s.account.PrivateKey, data)
+}
+
+func (s *localSigner) Decrypt(cipher []byte) ([]byte, error) {
+	return crypto.Decrypt(s.account.PrivateKey, cipher)
+}
+
+// NewLocal returns a signer by the private key of the account in process.
+func NewLocal(ac *account.Account) Signer {
+	return &localSigner{account: ac}
+}
+
+// SignTransaction signs the standard and multi-signature programs of the
+// transaction by the signers of which public key is in the redeem script,
+// and returns the number of signatures added.  Signers that have already
+// signed a multi-signature program are skipped.
+func SignTransaction(txn interfaces.Transaction, signers []Signer) (int, error) {
+	buf := new(bytes.Buffer)
+	if err := txn.SerializeUnsigned(buf); err != nil {
+		return 0, err
+	}
+	data := buf.Bytes()
+
+	byCodeHash := make(map[common.Uint160]Signer)
+	for _, s := range signers {
+		code, err := contract.CreateStandardRedeemScript(s.PublicKey())
+		if err != nil {
+			return 0, err
+		}
+		byCodeHash[*common.ToCodeHash(code)] = s
+	}
+
+	var signed int
+	programs := make([]*pg.Program, 0, len(txn.Programs()))
+	for _, program := range txn.Programs() {
+		signType, err := crypto.GetScriptType(program.Code)
+		if err != nil {
+			return 0, err
+		}
+		parameter := program.Parameter
+		switch signType {
+		case vm.CHECKSIG:
+			s, ok := byCodeHash[*common.ToCodeHash(program.Code)]
+			if !ok || len(parameter) > 0 {
+				break
+			}
+			signature, err := s.SignTx(txn)
+			if err != nil {
+				return 0, err
+			}
+			parameter = append([]byte{byte(len(signature))}, signature...)
+			signed++
+
+		case vm.CHECKMULTISIG:
+			codeHashes, err := account.GetSigners(program.Code)
+			if err != nil {
+				return 0, err
+			}
+			have, need, err := crypto.GetSignStatus(program.Code, parameter)
+			if err != nil {
+				return 0, err
+			}
+			for i, hash := range codeHashes {
+				if have >= need {
+					break
+				}
+				s, ok := byCodeHash[*hash]
+				if !ok || hasSigned(s.PublicKey(), data, parameter) {
+					continue
+				}
+				signature, err := s.SignTx(txn)
+				if err != nil {
+					return 0, err
+				}
+				parameter, err = crypto.AppendSignature(i, signature, data,
+					program.Code, parameter)
+				if err != nil {
+					return 0, err
+				}
+				have++
+				signed++
+			}
+		}
+		programs = append(programs, &pg.Program{
+			Code:      program.Code,
+			Parameter: parameter,
+		})
+	}
+	txn.SetPrograms(programs)
+
+	return signed, nil
+}
+
+// hasSigned returns if a signature of the parameter is signed by the public
+// key.
+func hasSigned(publicKey *crypto.PublicKey, data []byte, parameter []byte) bool {
+	for i := 0; i+crypto.SignatureScriptLength <= len(parameter); i += crypto.SignatureScriptLength {
+		signature := parameter[i+1 : i+crypto.SignatureScriptLength]
+		if crypto.Verify(*publicKey, data, signature) == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,391 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package signer_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/account"
+	"github.com/elastos/Elastos.ELA/account/signer"
+	"github.com/elastos/Elastos.ELA/account/signer/signertest"
+	"github.com/elastos/Elastos.ELA/common"
+	pg "github.com/elastos/Elastos.ELA/core/contract/program"
+	"github.com/elastos/Elastos.ELA/core/transaction"
+	common2 "github.com/elastos/Elastos.ELA/core/types/common"
+	"github.com/elastos/Elastos.ELA/core/types/functions"
+	"github.com/elastos/Elastos.ELA/core/types/interfaces"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/crypto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	functions.GetTransactionByTxType = transaction.GetTransaction
+	functions.GetTransactionByBytes = transaction.GetTransactionByBytes
+	functions.CreateTransaction = transaction.CreateTransaction
+}
+
+func newAccounts(t *testing.T, n int) []*account.Account {
+	accounts := make([]*account.Account, 0, n)
+	for i := 0; i < n; i++ {
+		ac, err := account.NewAccount()
+		assert.NoError(t, err)
+		accounts = append(accounts, ac)
+	}
+	return accounts
+}
+
+func newTransaction(programs ...*pg.Program) interfaces.Transaction {
+	return functions.CreateTransaction(
+		common2.TxVersion09,
+		common2.TransferAsset,
+		0,
+		&payload.TransferAsset{},
+		[]*common2.Attribute{},
+		[]*common2.Input{{Previous: common2.OutPoint{TxID: common.Uint256{1}}}},
+		[]*common2.Output{},
+		0,
+		programs,
+	)
+}
+
+func unsignedData(t *testing.T, txn interfaces.Transaction) []byte {
+	buf := new(bytes.Buffer)
+	assert.NoError(t, txn.SerializeUnsigned(buf))
+	return buf.Bytes()
+}
+
+func TestRemoteSigner(t *testing.T) {
+	accounts := newAccounts(t, 2)
+	server, err := signertest.NewServer("secret", signer.Policy{}, accounts...)
+	assert.NoError(t, err)
+	defer server.Close()
+
+	// requests without the token are rejected
+	client, err := signer.Dial(server.Address, "wrong")
+	assert.NoError(t, err)
+	_, err = client.PublicKeys()
+	assert.Error(t, err)
+	client.Close()
+
+	client, err = signer.Dial("unix://"+server.Address, "secret")
+	assert.NoError(t, err)
+	defer client.Close()
+	publicKeys, err := client.PublicKeys()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(publicKeys))
+	assert.True(t, crypto.Equal(accounts[0].PublicKey, publicKeys[0]))
+
+	s, err := client.Signer(publicKeys[1])
+	assert.NoError(t, err)
+	data := []byte("nonce of handshake")
+	signature, err := s.SignMessage(data)
+	assert.NoError(t, err)
+	assert.NoError(t, crypto.Verify(*accounts[1].PublicKey, data, signature))
+
+	cipher, err := crypto.Encrypt(accounts[1].PublicKey, []byte("127.0.0.1:20339"))
+	assert.NoError(t, err)
+	plain, err := s.Decrypt(cipher)
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:20339", string(plain))
+
+	requests := server.Requests()
+	assert.Equal(t, 2, len(requests))
+	assert.Equal(t, "Sign", requests[0].Method)
+	assert.Equal(t, data, requests[0].Data)
+	assert.Equal(t, "Decrypt", requests[1].Method)
+
+	// errors of the daemon are returned
+	server.SetError(errors.New("key locked"))
+	_, err = s.SignMessage(data)
+	assert.EqualError(t, err, "key locked")
+	server.SetError(nil)
+	_, err = s.SignMessage(data)
+	assert.NoError(t, err)
+
+	// keys not held by the daemon
+	other := newAccounts(t, 1)[0]
+	s, err = client.Signer(other.PublicKey)
+	assert.NoError(t, err)
+	_, err = s.SignMessage(data)
+	assert.EqualError(t, err, signer.ErrUnknownKey.Error())
+
+	// the daemon is not reachable
+	_, err = signer.Dial(server.Address+".none", "secret")
+	assert.Error(t, err)
+}
+
+func TestSignTransaction(t *testing.T) {
+	accounts := newAccounts(t, 4)
+	multiSig, err := account.NewMultiSigAccount(2, []*crypto.PublicKey{
+		accounts[0].PublicKey, accounts[1].PublicKey, accounts[2].PublicKey})
+	assert.NoError(t, err)
+
+	server, err := signertest.NewServer("", signer.Policy{AllowTx: true},
+		accounts[0], accounts[3])
+	assert.NoError(t, err)
+	defer server.Close()
+	client, err := signer.Dial(server.Address, "")
+	assert.NoError(t, err)
+	defer client.Close()
+	signers, err := client.Signers()
+	assert.NoError(t, err)
+
+	txn := newTransaction(
+		&pg.Program{Code: multiSig.RedeemScript},
+		&pg.Program{Code: accounts[3].RedeemScript},
+		&pg.Program{Code: accounts[2].RedeemScript},
+	)
+	signed, err := signer.SignTransaction(txn, signers)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, signed)
+	data := unsignedData(t, txn)
+	programs := txn.Programs()
+	assert.Equal(t, crypto.SignatureScriptLength, len(programs[0].Parameter))
+	assert.NoError(t, crypto.Verify(*accounts[3].PublicKey, data,
+		programs[1].Parameter[1:]))
+	assert.Equal(t, 0, len(programs[2].Parameter))
+
+	// signed keys are skipped
+	signed, err = signer.SignTransaction(txn, signers)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, signed)
+
+	// the multi-signature program is completed by local signer
+	signed, err = signer.SignTransaction(txn, []signer.Signer{
+		signer.NewLocal(accounts[1]), signer.NewLocal(accounts[2])})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, signed)
+	programs = txn.Programs()
+	publicKeys, err := crypto.ParseMultisigScript(multiSig.RedeemScript)
+	assert.NoError(t, err)
+	assert.NoError(t, crypto.VerifyMultisigSignatures(2, 3, publicKeys,
+		programs[0].Parameter, data))
+	assert.NoError(t, crypto.Verify(*accounts[2].PublicKey, data,
+		programs[2].Parameter[1:]))
+}
+
+func newProposal(t *testing.T, sponsor *account.Account, height,
+	view, nonce uint32) (*payload.DPOSProposal, *common2.Header) {
+	header := &common2.Header{Height: height, Nonce: nonce}
+	sponsorKey, err := sponsor.PublicKey.EncodePoint(true)
+	assert.NoError(t, err)
+	return &payload.DPOSProposal{
+		Sponsor:    sponsorKey,
+		BlockHash:  header.Hash(),
+		ViewOffset: view,
+	}, header
+}
+
+func newVote(t *testing.T, voter *account.Account,
+	proposal *payload.DPOSProposal, accept bool) *payload.DPOSProposalVote {
+	voterKey, err := voter.PublicKey.EncodePoint(true)
+	assert.NoError(t, err)
+	return &payload.DPOSProposalVote{
+		ProposalHash: proposal.Hash(),
+		Signer:       voterKey,
+		Accept:       accept,
+	}
+}
+
+func dialSigner(t *testing.T, server *signertest.Server,
+	ac *account.Account) (*signer.Client, signer.Signer) {
+	client, err := signer.Dial(server.Address, "")
+	assert.NoError(t, err)
+	s, err := client.Signer(ac.PublicKey)
+	assert.NoError(t, err)
+	return client, s
+}
+
+func TestPolicy_Proposal(t *testing.T) {
+	accounts := newAccounts(t, 2)
+	server, err := signertest.NewServer("", signer.Policy{}, accounts...)
+	assert.NoError(t, err)
+	defer server.Close()
+	client, s := dialSigner(t, server, accounts[0])
+	defer client.Close()
+
+	proposal, header := newProposal(t, accounts[0], 10, 1, 0)
+	signature, err := s.SignProposal(proposal, header)
+	assert.NoError(t, err)
+	assert.NoError(t, crypto.Verify(*accounts[0].PublicKey, proposal.Data(),
+		signature))
+
+	// the same proposal is signed again
+	_, err = s.SignProposal(proposal, header)
+	assert.NoError(t, err)
+
+	// another block at the same round
+	other, otherHeader := newProposal(t, accounts[0], 10, 1, 1)
+	_, err = s.SignProposal(other, otherHeader)
+	assert.EqualError(t, err, signer.ErrDoubleSign.Error())
+
+	// a lower round
+	other, otherHeader = newProposal(t, accounts[0], 10, 0, 1)
+	_, err = s.SignProposal(other, otherHeader)
+	assert.EqualError(t, err, signer.ErrDoubleSign.Error())
+	other, otherHeader = newProposal(t, accounts[0], 9, 2, 1)
+	_, err = s.SignProposal(other, otherHeader)
+	assert.EqualError(t, err, signer.ErrDoubleSign.Error())
+
+	// a higher view and a higher height
+	other, otherHeader = newProposal(t, accounts[0], 10, 2, 1)
+	_, err = s.SignProposal(other, otherHeader)
+	assert.NoError(t, err)
+	other, otherHeader = newProposal(t, accounts[0], 11, 0, 1)
+	_, err = s.SignProposal(other, otherHeader)
+	assert.NoError(t, err)
+
+	// the header of another block
+	other, _ = newProposal(t, accounts[0], 12, 0, 1)
+	_, err = s.SignProposal(other, header)
+	assert.Error(t, err)
+
+	// the proposal sponsored by another key
+	other, otherHeader = newProposal(t, accounts[1], 12, 0, 1)
+	_, err = s.SignProposal(other, otherHeader)
+	assert.Error(t, err)
+
+	// rounds of other keys are not affected
+	_, s = dialSigner(t, server, accounts[1])
+	_, err = s.SignProposal(other, otherHeader)
+	assert.NoError(t, err)
+}
+
+func TestPolicy_Vote(t *testing.T) {
+	accounts := newAccounts(t, 2)
+	server, err := signertest.NewServer("", signer.Policy{}, accounts[1])
+	assert.NoError(t, err)
+	defer server.Close()
+	client, s := dialSigner(t, server, accounts[1])
+	defer client.Close()
+
+	proposal, header := newProposal(t, accounts[0], 10, 1, 0)
+	vote := newVote(t, accounts[1], proposal, true)
+	signature, err := s.SignVote(vote, proposal, header)
+	assert.NoError(t, err)
+	assert.NoError(t, crypto.Verify(*accounts[1].PublicKey, vote.Data(),
+		signature))
+	_, err = s.SignVote(vote, proposal, header)
+	assert.NoError(t, err)
+
+	// the vote changed at the same round
+	_, err = s.SignVote(newVote(t, accounts[1], proposal, false), proposal,
+		header)
+	assert.EqualError(t, err, signer.ErrDoubleSign.Error())
+
+	// another proposal at the same round
+	other, otherHeader := newProposal(t, accounts[0], 10, 1, 1)
+	_, err = s.SignVote(newVote(t, accounts[1], other, true), other,
+		otherHeader)
+	assert.EqualError(t, err, signer.ErrDoubleSign.Error())
+
+	// the vote on another proposal
+	other, otherHeader = newProposal(t, accounts[0], 10, 2, 1)
+	_, err = s.SignVote(vote, other, otherHeader)
+	assert.Error(t, err)
+
+	// the vote of another key
+	_, err = s.SignVote(newVote(t, accounts[0], other, true), other,
+		otherHeader)
+	assert.Error(t, err)
+
+	// a higher round
+	_, err = s.SignVote(newVote(t, accounts[1], other, true), other,
+		otherHeader)
+	assert.NoError(t, err)
+}
+
+func TestPolicy_State(t *testing.T) {
+	accounts := newAccounts(t, 1)
+	dir, err := ioutil.TempDir("", "signerstate")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	policy := signer.Policy{StatePath: filepath.Join(dir, "state.json")}
+
+	server, err := signertest.NewServer("", policy, accounts...)
+	assert.NoError(t, err)
+	client, s := dialSigner(t, server, accounts[0])
+	proposal, header := newProposal(t, accounts[0], 10, 0, 0)
+	_, err = s.SignProposal(proposal, header)
+	assert.NoError(t, err)
+	client.Close()
+	server.Close()
+
+	// the signed round is kept after the daemon restarts
+	server, err = signertest.NewServer("", policy, accounts...)
+	assert.NoError(t, err)
+	defer server.Close()
+	client, s = dialSigner(t, server, accounts[0])
+	defer client.Close()
+	other, otherHeader := newProposal(t, accounts[0], 10, 0, 1)
+	_, err = s.SignProposal(other, otherHeader)
+	assert.EqualError(t, err, signer.ErrDoubleSign.Error())
+	_, err = s.SignProposal(proposal, header)
+	assert.NoError(t, err)
+}
+
+func TestPolicy_Tx(t *testing.T) {
+	accounts := newAccounts(t, 1)
+	server, err := signertest.NewServer("", signer.Policy{}, accounts...)
+	assert.NoError(t, err)
+	defer server.Close()
+	client, s := dialSigner(t, server, accounts[0])
+	defer client.Close()
+
+	// transactions are not signed unless allowed
+	txn := newTransaction(&pg.Program{Code: accounts[0].RedeemScript})
+	_, err = s.SignTx(txn)
+	assert.EqualError(t, err, signer.ErrTxNotAllowed.Error())
+	_, err = signer.SignTransaction(txn, []signer.Signer{s})
+	assert.Error(t, err)
+
+	// except the transactions signed by arbiters
+	revert := functions.CreateTransaction(
+		common2.TxVersion09,
+		common2.RevertToDPOS,
+		payload.RevertToDPOSVersion,
+		&payload.RevertToDPOS{WorkHeightInterval: 10},
+		[]*common2.Attribute{},
+		[]*common2.Input{},
+		[]*common2.Output{},
+		0,
+		[]*pg.Program{},
+	)
+	signature, err := s.SignTx(revert)
+	assert.NoError(t, err)
+	assert.NoError(t, crypto.Verify(*accounts[0].PublicKey,
+		unsignedData(t, revert), signature))
+
+	// messages are not a way around the typed requests
+	proposal, _ := newProposal(t, accounts[0], 10, 0, 0)
+	_, err = s.SignMessage(proposal.Data())
+	assert.Error(t, err)
+	_, err = s.SignMessage(newVote(t, accounts[0], proposal, true).Data())
+	assert.Error(t, err)
+	_, err = s.SignMessage(unsignedData(t, txn))
+	assert.Error(t, err)
+
+	// only addresses are decrypted
+	cipher, err := crypto.Encrypt(accounts[0].PublicKey, []byte("secret"))
+	assert.NoError(t, err)
+	_, err = s.Decrypt(cipher)
+	assert.Error(t, err)
+}
+
+func TestListen(t *testing.T) {
+	// TCP is refused, the Unix socket is forwarded by SSH instead.
+	_, err := signer.Listen("tcp://127.0.0.1:0")
+	assert.Equal(t, signer.ErrTCPUnsupported, err)
+	_, err = signer.Dial("tcp://127.0.0.1:20339", "token")
+	assert.Equal(t, signer.ErrTCPUnsupported, err)
+}
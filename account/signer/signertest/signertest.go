@@ -0,0 +1,134 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+// Package signertest provides a remote signer daemon for testing.
+package signertest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/elastos/Elastos.ELA/account"
+	"github.com/elastos/Elastos.ELA/account/signer"
+	"github.com/elastos/Elastos.ELA/crypto"
+)
+
+// Request is a request received by the test signer daemon.
+type Request struct {
+	Method    string
+	PublicKey *crypto.PublicKey
+	Data      []byte
+}
+
+// Server is a signer daemon listening on a temporary Unix socket, which
+// records the requests and fails them with Err if set.
+type Server struct {
+	// Address is the address to dial the signer daemon.
+	Address string
+
+	mtx      sync.Mutex
+	accounts []*account.Account
+	requests []Request
+	err      error
+
+	server *signer.Server
+	dir    string
+}
+
+// NewServer starts a signer daemon holding the private keys of accounts and
+// signing the requests allowed by the policy, requests without the token are
+// rejected if it is not empty.
+func NewServer(token string, policy signer.Policy,
+	accounts ...*account.Account) (*Server, error) {
+	dir, err := ioutil.TempDir("", "signertest")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Address:  filepath.Join(dir, "signer.sock"),
+		accounts: accounts,
+		dir:      dir,
+	}
+	s.server, err = signer.NewServer(s, token, policy)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	listener, err := signer.Listen(s.Address)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	go s.server.Serve(listener)
+	return s, nil
+}
+
+// SetError makes the following requests fail with err, or succeed if err is
+// nil.
+func (s *Server) SetError(err error) {
+	s.mtx.Lock()
+	s.err = err
+	s.mtx.Unlock()
+}
+
+// Requests returns the requests received in order.
+func (s *Server) Requests() []Request {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Close stops the signer daemon and removes the Unix socket.
+func (s *Server) Close() {
+	s.server.Close()
+	os.RemoveAll(s.dir)
+}
+
+func (s *Server) PublicKeys() []*crypto.PublicKey {
+	publicKeys := make([]*crypto.PublicKey, 0, len(s.accounts))
+	for _, ac := range s.accounts {
+		publicKeys = append(publicKeys, ac.PublicKey)
+	}
+	return publicKeys
+}
+
+func (s *Server) Sign(publicKey *crypto.PublicKey, data []byte) ([]byte, error) {
+	ac, err := s.request("Sign", publicKey, data)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Sign(ac.PrivateKey, data)
+}
+
+func (s *Server) Decrypt(publicKey *crypto.PublicKey, cipher []byte) ([]byte, error) {
+	ac, err := s.request("Decrypt", publicKey, cipher)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Decrypt(ac.PrivateKey, cipher)
+}
+
+func (s *Server) request(method string, publicKey *crypto.PublicKey,
+	data []byte) (*account.Account, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.requests = append(s.requests, Request{
+		Method:    method,
+		PublicKey: publicKey,
+		Data:      data,
+	})
+	if s.err != nil {
+		return nil, s.err
+	}
+	for _, ac := range s.accounts {
+		if crypto.Equal(ac.PublicKey, publicKey) {
+			return ac, nil
+		}
+	}
+	return nil, signer.ErrUnknownKey
+}
@@ -35,7 +35,7 @@ var checkpointPath = "checkpoints"
 
 func newBlockChain(path string, params *config.Configuration,
 	interrupt <-chan struct{}) (*blockchain.BlockChain, error) {
-	log.NewDefault(test.NodeLogPath, 1, 0, 0)
+	blockchain.UseLogger(log.NewDefault(test.NodeLogPath, 1, 0, 0))
 	ckpManager := checkpoint.NewManager(params)
 	ckpManager.SetDataPath(filepath.Join(params.DataDir, checkpointPath))
 	committee := crstate.NewCommittee(params, ckpManager)
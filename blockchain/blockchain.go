@@ -20,7 +20,6 @@ import (
 	elaact "github.com/elastos/Elastos.ELA/account"
 	. "github.com/elastos/Elastos.ELA/common"
 	"github.com/elastos/Elastos.ELA/common/config"
-	"github.com/elastos/Elastos.ELA/common/log"
 	"github.com/elastos/Elastos.ELA/core/checkpoint"
 	"github.com/elastos/Elastos.ELA/core/contract/program"
 	. "github.com/elastos/Elastos.ELA/core/types"
@@ -36,6 +35,7 @@ import (
 	"github.com/elastos/Elastos.ELA/events"
 	"github.com/elastos/Elastos.ELA/p2p/msg"
 	"github.com/elastos/Elastos.ELA/utils"
+	"github.com/elastos/Elastos.ELA/utils/elalog"
 )
 
 const (
@@ -87,6 +87,18 @@ type BlockChain struct {
 	MedianTimePast time.Time
 	mutex          sync.RWMutex
 
+	historyMtx    sync.Mutex
+	historyStates []*HistoryState
+	historyBuilds map[uint32]*historyBuild
+
+	// referencedTxs maps the transactions referenced by the main chain
+	// blocks above the prune height to the height of the block referencing
+	// them, the blocks up to referencedHeight have been scanned.  They are
+	// only accessed while pruning or disconnecting blocks under the chain
+	// lock.
+	referencedTxs    map[Uint256]uint32
+	referencedHeight uint32
+
 	AncestorBlock Block
 }
 
@@ -127,6 +139,7 @@ func New(db IChainStore, chainParams *config.Configuration, state *state.State,
 	if err := chain.initChainState(); err != nil {
 		return nil, err
 	}
+	chain.registerMetrics()
 
 	return &chain, nil
 }
@@ -710,7 +723,10 @@ func (b *BlockChain) ProcessBlock(block *Block, confirm *payload.Confirm) (bool,
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 
-	return b.processBlock(block, confirm)
+	start := time.Now()
+	inMainChain, isOrphan, err := b.processBlock(block, confirm)
+	observeBlockProcessTime(start, isOrphan, err)
+	return inMainChain, isOrphan, err
 }
 
 func (b *BlockChain) GetHeader(hash Uint256) (*common.Header, error) {
@@ -1318,7 +1334,8 @@ func (b *BlockChain) reorganizeChain2(detachNodes *list.List) error {
 			continue
 		}
 
-		log.Info("disconnect block:", block.Height, "hash:", block.Hash())
+		log.WithFields(elalog.Height(block.Height)).Info("disconnect block:",
+			block.Height, "hash:", block.Hash())
 		err = b.disconnectBlock2(n, block.Block, block.Confirm)
 		if err != nil {
 			continue
@@ -1362,7 +1379,8 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 			return err
 		}
 
-		log.Info("disconnect block:", block.Height)
+		log.WithFields(elalog.Height(block.Height)).Info("disconnect block:",
+			block.Height)
 		DefaultLedger.Arbitrators.DumpInfo(block.Height - 1)
 
 		// roll back state about the last block before disconnect
@@ -1394,7 +1412,8 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 		block := b.blockCache[*n.Hash]
 		confirm := b.confirmCache[*n.Hash]
 
-		log.Info("connect block:", block.Height)
+		log.WithFields(elalog.Height(block.Height)).Info("connect block:",
+			block.Height)
 		err := b.connectBlock(n, block, confirm)
 		if err != nil {
 			return err
@@ -1417,7 +1436,8 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 // // disconnectBlock handles disconnecting the passed node/block from the end of
 // // the main (best) chain.
 func (b *BlockChain) disconnectBlock2(node *BlockNode, block *Block, confirm *payload.Confirm) error {
-	log.Info("disconnect block:", block.Height, "hash:", block.Hash())
+	log.WithFields(elalog.Height(block.Height)).Info("disconnect block:",
+		block.Height, "hash:", block.Hash())
 
 	// Remove the block from the database which houses the main chain.
 	prevNode, err := b.getPrevNodeFromNode(node)
@@ -1434,6 +1454,7 @@ func (b *BlockChain) disconnectBlock2(node *BlockNode, block *Block, confirm *pa
 	if err != nil {
 		return err
 	}
+	b.resetReferencedTxs(node.Height)
 
 	return nil
 }
@@ -1457,6 +1478,7 @@ func (b *BlockChain) disconnectBlock(node *BlockNode, block *Block, confirm *pay
 	if err != nil {
 		return err
 	}
+	b.resetReferencedTxs(node.Height)
 
 	// Put block in the side chain cache.
 	node.InMainChain = false
@@ -1550,6 +1572,14 @@ func (b *BlockChain) connectBlock(node *BlockNode, block *Block, confirm *payloa
 	b.BestChain = node
 	b.MedianTimePast = medianTime
 
+	// Remove the block files no longer needed periodically when pruning
+	// is enabled, failing to prune does not affect the chain.
+	if b.IsPruned() && node.Height%pruneInterval == 0 {
+		if err := b.pruneBlocks(); err != nil {
+			log.Warn("prune blocks failed:", err)
+		}
+	}
+
 	// Notify the caller that the block was connected to the main chain.
 	// The caller would typically want to react with actions such as
 	// updating wallets.
@@ -2137,6 +2167,34 @@ func (b *BlockChain) LocateBlocks(locator []*Uint256, hashStop *Uint256, maxHash
 	return blocks
 }
 
+// LocateHeaders returns the headers of the blocks after the first known block
+// in the locator until the provided stop hash is reached, or up to the
+// provided max number of block headers.
+//
+// The special cases are the same as LocateBlocks.  The headers include the aux
+// pow so the receiver is able to validate them, no headers are returned from
+// the blocks removed by pruning or below the imported snapshot, of which the
+// aux pow is not kept.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) LocateHeaders(locator []*Uint256, hashStop *Uint256,
+	maxHeaders uint32) []*common.Header {
+	hashes := b.LocateBlocks(locator, hashStop, maxHeaders)
+	headers := make([]*common.Header, 0, len(hashes))
+	for _, hash := range hashes {
+		if b.IsBlockPruned(hash) {
+			break
+		}
+		header, err := b.db.GetFFLDB().GetFullHeader(*hash)
+		if err != nil {
+			log.Errorf("LocateHeaders error %s", err)
+			break
+		}
+		headers = append(headers, header)
+	}
+	return headers
+}
+
 func (b *BlockChain) MedianAdjustedTime() time.Time {
 	newTimestamp := b.TimeSource.AdjustedTime()
 	minTimestamp := b.MedianTimePast.Add(time.Second)
@@ -0,0 +1,188 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/auxpow"
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/common/config"
+	"github.com/elastos/Elastos.ELA/core/types"
+	common2 "github.com/elastos/Elastos.ELA/core/types/common"
+	"github.com/elastos/Elastos.ELA/database"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newLocateTestBlock returns a block on top of the previous block with a
+// valid aux pow.
+func newLocateTestBlock(previous common.Uint256, height uint32) *types.Block {
+	block := &types.Block{
+		Header: common2.Header{
+			Version:   0,
+			Previous:  previous,
+			Timestamp: uint32(time.Now().Unix()),
+			Bits:      0x207fffff,
+			Height:    height,
+		},
+	}
+	hash := block.Hash()
+	auxPow := auxpow.GenerateAuxPow(hash)
+	target := CompactToBig(block.Bits)
+	for nonce := uint32(0); ; nonce++ {
+		auxPow.ParBlockHeader.Nonce = nonce
+		parHash := auxPow.ParBlockHeader.Hash()
+		if HashToBig(&parHash).Cmp(target) <= 0 {
+			break
+		}
+	}
+	block.AuxPow = *auxPow
+	return block
+}
+
+func TestLocateHeaders(t *testing.T) {
+	params := config.DefaultParams
+	store, err := NewChainStore(t.TempDir(), &params)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer store.Close()
+
+	params.GenesisBlock = newLocateTestBlock(common.EmptyHash, 0)
+	chain := &BlockChain{
+		db:          store,
+		chainParams: &params,
+		TimeSource:  NewMedianTime(),
+		GenesisHash: params.GenesisBlock.Hash(),
+		index:       newBlockIndex(store, &params),
+	}
+	if !assert.NoError(t, chain.createChainState()) {
+		return
+	}
+	chain.index.addNode(chain.Nodes[0])
+	previous := *chain.Nodes[0].Hash
+	for height := uint32(1); height < 3; height++ {
+		block := newLocateTestBlock(previous, height)
+		hash := block.Hash()
+		node := NewBlockNode(&block.Header, &hash)
+		node.Height = height
+		node.Status = statusDataStored | statusValid
+		err := store.GetFFLDB().Update(func(dbTx database.Tx) error {
+			if err := dbStoreBlock(dbTx, &types.DposBlock{Block: block}); err != nil {
+				return err
+			}
+			return dbPutBlockIndex(dbTx, &hash, height)
+		})
+		assert.NoError(t, err)
+		chain.Nodes = append(chain.Nodes, node)
+		chain.index.addNode(node)
+		previous = hash
+	}
+
+	// The headers sent to peers pass the header validation of the
+	// receiver, which requires the aux pow.
+	genesis := *chain.Nodes[0].Hash
+	headers := chain.LocateHeaders([]*common.Uint256{&genesis},
+		&common.EmptyHash, 2000)
+	assert.Equal(t, 2, len(headers))
+	for i, header := range headers {
+		assert.Equal(t, *chain.Nodes[i+1].Hash, header.Hash())
+		assert.NoError(t, chain.CheckHeaderSanity(header))
+	}
+
+	// The header without the aux pow is rejected.
+	header, err := chain.GetHeader(*chain.Nodes[1].Hash)
+	assert.NoError(t, err)
+	assert.Error(t, chain.CheckHeaderSanity(header))
+
+	// No headers are served from the blocks without their aux pow, such as
+	// the blocks below the imported snapshot.
+	assert.True(t, chain.HaveFullHistory())
+	for _, node := range chain.Nodes[:2] {
+		node.Status = statusValid
+	}
+	assert.False(t, chain.HaveFullHistory())
+	headers = chain.LocateHeaders([]*common.Uint256{&genesis},
+		&common.EmptyHash, 2000)
+	assert.Equal(t, 0, len(headers))
+}
+
+func TestCheckSnapshotImport(t *testing.T) {
+	params := config.DefaultParams
+	store, err := NewChainStore(t.TempDir(), &params)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer store.Close()
+
+	snapshotHash := common.Uint256{1}
+	check := func() (resume bool, err error) {
+		err = store.GetFFLDB().View(func(dbTx database.Tx) error {
+			var err error
+			resume, err = dbCheckSnapshotImport(dbTx, &snapshotHash)
+			return err
+		})
+		return
+	}
+
+	// Import into the empty database.
+	resume, err := check()
+	assert.NoError(t, err)
+	assert.False(t, resume)
+
+	// The interrupted import of the same snapshot is resumed.
+	err = store.GetFFLDB().Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		if _, err := meta.CreateBucket(blockIndexBucketName); err != nil {
+			return err
+		}
+		hash := snapshotHash
+		return meta.Put(snapshotImportKeyName, hash[:])
+	})
+	assert.NoError(t, err)
+	resume, err = check()
+	assert.NoError(t, err)
+	assert.True(t, resume)
+
+	// The chain data of another snapshot is not imported over.
+	snapshotHash = common.Uint256{2}
+	_, err = check()
+	assert.Error(t, err)
+}
+
+func TestChainStoreFFLDB_GetFullHeader(t *testing.T) {
+	params := config.DefaultParams
+	store, err := NewChainStore(t.TempDir(), &params)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer store.Close()
+
+	// The header is read from the beginning of a block larger than the
+	// header region.
+	block := newLocateTestBlock(common.EmptyHash, 1)
+	hash := block.Hash()
+	buf := new(bytes.Buffer)
+	assert.NoError(t, block.Header.Serialize(buf))
+	buf.Write(make([]byte, fullHeaderRegionSize*2))
+	err = store.GetFFLDB().Update(func(dbTx database.Tx) error {
+		return dbTx.StoreBlock(hash, buf.Bytes())
+	})
+	assert.NoError(t, err)
+
+	header, err := store.GetFFLDB().GetFullHeader(hash)
+	if assert.NoError(t, err) {
+		assert.Equal(t, hash, header.Hash())
+		assert.Equal(t, block.AuxPow.ParBlockHeader.Hash(),
+			header.AuxPow.ParBlockHeader.Hash())
+	}
+
+	_, err = store.GetFFLDB().GetFullHeader(common.Uint256{1})
+	assert.Error(t, err)
+}
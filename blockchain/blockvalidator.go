@@ -16,7 +16,6 @@ import (
 	. "github.com/elastos/Elastos.ELA/auxpow"
 	. "github.com/elastos/Elastos.ELA/common"
 	"github.com/elastos/Elastos.ELA/common/config"
-	"github.com/elastos/Elastos.ELA/common/log"
 	"github.com/elastos/Elastos.ELA/core"
 	. "github.com/elastos/Elastos.ELA/core/types"
 	"github.com/elastos/Elastos.ELA/core/types/common"
@@ -32,13 +31,15 @@ const (
 	MaxTimeOffsetSeconds = 2 * 60 * 60
 )
 
-func (b *BlockChain) CheckBlockSanity(block *Block) error {
-	header := block.Header
+// CheckHeaderSanity performs the context free checks on the block header,
+// including the aux pow, proof of work and timestamp.  It is also used to
+// validate headers downloaded before their block bodies.
+func (b *BlockChain) CheckHeaderSanity(header *common.Header) error {
 	hash := header.Hash()
 	if !header.AuxPow.Check(&hash, AuxPowChainID) {
 		return errors.New("[PowCheckBlockSanity] block check aux pow failed")
 	}
-	if CheckProofOfWork(&header, b.chainParams.PowConfiguration.PowLimit) != nil {
+	if CheckProofOfWork(header, b.chainParams.PowConfiguration.PowLimit) != nil {
 		return errors.New("[PowCheckBlockSanity] block check proof of work failed")
 	}
 
@@ -54,6 +55,22 @@ func (b *BlockChain) CheckBlockSanity(block *Block) error {
 		return errors.New("[PowCheckBlockSanity] block timestamp of is too far in the future")
 	}
 
+	// A block header must not exceed the maximum allowed block payload when
+	//serialized.
+	headerSize := header.GetSize()
+	if headerSize > int(pact.MaxBlockHeaderSize) {
+		return errors.New(
+			"[PowCheckBlockSanity] serialized block header is too big")
+	}
+
+	return nil
+}
+
+func (b *BlockChain) CheckBlockSanity(block *Block) error {
+	if err := b.CheckHeaderSanity(&block.Header); err != nil {
+		return err
+	}
+
 	// A block must have at least one transaction.
 	numTx := len(block.Transactions)
 	if numTx == 0 {
@@ -66,14 +83,6 @@ func (b *BlockChain) CheckBlockSanity(block *Block) error {
 			" transactions, tx count: " + strconv.FormatInt(int64(numTx), 10))
 	}
 
-	// A block header must not exceed the maximum allowed block payload when
-	//serialized.
-	headerSize := block.Header.GetSize()
-	if headerSize > int(pact.MaxBlockHeaderSize) {
-		return errors.New(
-			"[PowCheckBlockSanity] serialized block header is too big")
-	}
-
 	// A block must not exceed the maximum allowed block payload when serialized.
 	blockSize := block.GetSize()
 	if blockSize > int(pact.MaxBlockContextSize+pact.MaxBlockHeaderSize) {
@@ -129,7 +138,7 @@ func (b *BlockChain) CheckBlockSanity(block *Block) error {
 	if err != nil {
 		return errors.New("[PowCheckBlockSanity] merkleTree compute failed")
 	}
-	if !header.MerkleRoot.IsEqual(calcTransactionsRoot) {
+	if !block.Header.MerkleRoot.IsEqual(calcTransactionsRoot) {
 		return errors.New("[PowCheckBlockSanity] block merkle root is invalid")
 	}
 
@@ -20,7 +20,6 @@ import (
 	"time"
 
 	"github.com/elastos/Elastos.ELA/common"
-	"github.com/elastos/Elastos.ELA/common/log"
 	"github.com/elastos/Elastos.ELA/core/types"
 	common2 "github.com/elastos/Elastos.ELA/core/types/common"
 	"github.com/elastos/Elastos.ELA/database"
@@ -486,7 +485,13 @@ func (b *BlockChain) initChainState() error {
 			}
 
 			exists, err := dbTx.HasBlock(curHash)
-			if err != nil || !exists {
+			if err != nil {
+				continue
+			}
+
+			// Blocks below the height of an imported chain snapshot are
+			// kept as valid headers without their block data.
+			if !exists && (status.HaveData() || !status.KnownValid()) {
 				continue
 			}
 
@@ -606,6 +611,25 @@ func dbFetchHeightByHash(dbTx database.Tx, hash *common.Uint256) (uint32, error)
 	return uint32(byteOrder.Uint32(serializedHeight)), nil
 }
 
+// dbFetchIndexedHeader uses an existing database transaction to retrieve the
+// serialized header of a main chain block from the block index.
+func dbFetchIndexedHeader(dbTx database.Tx, hash *common.Uint256) ([]byte, error) {
+	blockIndexBucket := dbTx.Metadata().Bucket(blockIndexBucketName)
+	if blockIndexBucket == nil {
+		return nil, fmt.Errorf("block %s is not in the block index", hash)
+	}
+	height, err := dbFetchHeightByHash(dbTx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	blockRow := blockIndexBucket.Get(blockIndexKey(hash, height))
+	if blockRow == nil {
+		return nil, fmt.Errorf("block %s is not in the block index", hash)
+	}
+	return blockRow, nil
+}
+
 // dbFetchBlockByNode uses an existing database transaction to retrieve the
 // raw block for the provided node, deserialize it, and return a btcutil.Block
 // with the height set.
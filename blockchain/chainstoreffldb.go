@@ -16,7 +16,6 @@ import (
 	"github.com/elastos/Elastos.ELA/blockchain/indexers"
 	. "github.com/elastos/Elastos.ELA/common"
 	"github.com/elastos/Elastos.ELA/common/config"
-	"github.com/elastos/Elastos.ELA/common/log"
 	. "github.com/elastos/Elastos.ELA/core/types"
 	"github.com/elastos/Elastos.ELA/core/types/common"
 	"github.com/elastos/Elastos.ELA/core/types/interfaces"
@@ -34,6 +33,11 @@ const (
 	oldBlockDbName = "blocks_ffldb"
 
 	BlocksCacheSize = 2
+
+	// fullHeaderRegionSize is the size of the block region read to get the
+	// full header, which covers the aux pow in practice.  The whole block is
+	// read only if the header is larger.
+	fullHeaderRegionSize = 16 * 1024
 )
 
 type ChainStoreFFLDB struct {
@@ -316,9 +320,11 @@ func (c *ChainStoreFFLDB) GetHeader(hash Uint256) (*common.Header, error) {
 		var e error
 		headerBytes, e = tx.FetchBlockHeader(&hash)
 		if e != nil {
-			return e
+			// Blocks below the height of an imported chain snapshot
+			// only have their headers kept in the block index.
+			headerBytes, e = dbFetchIndexedHeader(tx, &hash)
 		}
-		return nil
+		return e
 	})
 	if err != nil {
 		return nil, errors.New("[BlockChain], GetHeader failed")
@@ -333,6 +339,49 @@ func (c *ChainStoreFFLDB) GetHeader(hash Uint256) (*common.Header, error) {
 	return &header, nil
 }
 
+// GetFullHeader returns the block header including the aux pow, which is
+// required by others to validate the header.  Unlike GetHeader the header is
+// read from the block, so it fails for blocks without their bodies below the
+// height of an imported chain snapshot.  Only the beginning of the block is
+// read, the block is serialized starting with the full header.
+func (c *ChainStoreFFLDB) GetFullHeader(hash Uint256) (*common.Header, error) {
+	var header common.Header
+	err := c.db.View(func(dbTx database.Tx) error {
+		data, err := dbTx.FetchBlockRegion(&database.BlockRegion{
+			Hash:   &hash,
+			Offset: 0,
+			Len:    fullHeaderRegionSize,
+		})
+		if dbErr, ok := err.(database.Error); ok &&
+			dbErr.ErrorCode == database.ErrBlockRegionInvalid {
+			// The block is smaller than the region.
+			data, err = dbTx.FetchBlock(&hash)
+		}
+		if err != nil {
+			return err
+		}
+		if header.Deserialize(bytes.NewReader(data)) == nil {
+			return nil
+		}
+
+		// The header is larger than the region.
+		if len(data) < fullHeaderRegionSize {
+			return errors.New("invalid header")
+		}
+		data, err = dbTx.FetchBlock(&hash)
+		if err != nil {
+			return err
+		}
+		header = common.Header{}
+		return header.Deserialize(bytes.NewReader(data))
+	})
+	if err != nil {
+		return nil, errors.New("[BlockChain], GetFullHeader failed")
+	}
+
+	return &header, nil
+}
+
 func (c *ChainStoreFFLDB) IsBlockInStore(hash *Uint256) bool {
 	var hasBlock bool
 	err := c.db.View(func(dbTx database.Tx) error {
@@ -378,6 +427,18 @@ func (c *ChainStoreFFLDB) BlockExists(hash *Uint256) (bool, uint32, error) {
 		}
 		return err
 	})
+	if err == nil && !exists {
+		// Blocks removed by pruning, or below the height of an imported
+		// chain snapshot, only have their headers kept in the block
+		// index.
+		err = c.db.View(func(dbTx database.Tx) error {
+			if _, e := dbFetchIndexedHeader(dbTx, hash); e == nil {
+				height, _ = dbFetchHeightByHash(dbTx, hash)
+				exists = true
+			}
+			return nil
+		})
+	}
 	return exists, height, err
 }
 
@@ -408,6 +469,22 @@ func (c *ChainStoreFFLDB) GetUTXO(programHash *Uint168) ([]*common.UTXO, error)
 	return c.indexManager.FetchUTXO(programHash)
 }
 
+func (c *ChainStoreFFLDB) GetAddressHistory(programHash *Uint168,
+	cursor []byte, limit uint32) ([]*indexers.AddressHistoryEntry, []byte, error) {
+	return c.indexManager.FetchAddressHistory(programHash, cursor, limit)
+}
+
+func (c *ChainStoreFFLDB) GetIllegalEvidences(nodePublicKey []byte,
+	startHeight, endHeight uint32) ([]*indexers.IllegalEvidenceEntry, error) {
+	return c.indexManager.FetchIllegalEvidences(nodePublicKey, startHeight,
+		endHeight)
+}
+
+func (c *ChainStoreFFLDB) GetCRProposalEvents(
+	proposalHash Uint256) ([]*indexers.CRProposalEvent, error) {
+	return c.indexManager.FetchCRProposalEvents(proposalHash)
+}
+
 func DBFetchTx3IndexEntry(dbTx database.Tx, txHash *Uint256) bool {
 	hashIndex := dbTx.Metadata().Bucket(Tx3IndexBucketName)
 	if hashIndex == nil {
@@ -11,7 +11,6 @@ import (
 	"time"
 
 	. "github.com/elastos/Elastos.ELA/common"
-	"github.com/elastos/Elastos.ELA/common/log"
 )
 
 func getNetworkHashPS(tipNode *BlockNode) *big.Int {
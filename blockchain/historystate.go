@@ -0,0 +1,256 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/checkpoint"
+	common2 "github.com/elastos/Elastos.ELA/core/types/common"
+	crstate "github.com/elastos/Elastos.ELA/cr/state"
+	"github.com/elastos/Elastos.ELA/dpos/state"
+)
+
+// maxHistoryStates is the max number of history states kept in memory.
+const maxHistoryStates = 8
+
+// errHistoryUTXO is returned by the UTXO lookups of the history states.  The
+// UTXOs at the best height differ from those at the history height, so the
+// replay must not depend on them.  The outputs referenced by the replayed
+// transactions are resolved from the historical transactions instead.
+var errHistoryUTXO = errors.New("UTXOs are not available in history states")
+
+// HistoryState houses the DPoS and CR states at a certain height.
+type HistoryState struct {
+	Height    uint32
+	Hash      common.Uint256
+	Arbiters  state.Arbitrators
+	State     *state.State
+	Committee *crstate.Committee
+}
+
+// historyBuild is a history state being rebuilt.  Concurrent requests of the
+// same block wait for it instead of rebuilding the state again.
+type historyBuild struct {
+	hash  common.Uint256
+	done  chan struct{}
+	state *HistoryState
+	err   error
+}
+
+// GetHistoryState returns the DPoS and CR states at the given height, the
+// current states will be returned if the height is not lower than the best
+// height.  History states are rebuilt from the nearest checkpoint files not
+// higher than the height, along with the blocks after them, and the recently
+// used ones are cached.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) GetHistoryState(height uint32) (*HistoryState, error) {
+	if height >= b.GetHeight() {
+		return &HistoryState{
+			Height:    b.GetHeight(),
+			Hash:      *b.GetBestBlockHash(),
+			Arbiters:  DefaultLedger.Arbitrators,
+			State:     b.state,
+			Committee: b.crCommittee,
+		}, nil
+	}
+
+	hash, err := b.GetBlockHash(height)
+	if err != nil {
+		return nil, err
+	}
+
+	b.historyMtx.Lock()
+	for i, s := range b.historyStates {
+		if s.Height != height {
+			continue
+		}
+		// The cached state is out of date after the chain reorganized.
+		if !s.Hash.IsEqual(hash) {
+			b.historyStates = append(b.historyStates[:i],
+				b.historyStates[i+1:]...)
+			break
+		}
+		// Move the state to the end as the most recently used one.
+		b.historyStates = append(append(b.historyStates[:i],
+			b.historyStates[i+1:]...), s)
+		b.historyMtx.Unlock()
+		return s, nil
+	}
+
+	// Wait for the state being rebuilt by another request.
+	if build, ok := b.historyBuilds[height]; ok && build.hash.IsEqual(hash) {
+		b.historyMtx.Unlock()
+		<-build.done
+		return build.state, build.err
+	}
+	build := &historyBuild{hash: hash, done: make(chan struct{})}
+	if b.historyBuilds == nil {
+		b.historyBuilds = make(map[uint32]*historyBuild)
+	}
+	b.historyBuilds[height] = build
+	b.historyMtx.Unlock()
+
+	// Replaying the blocks takes a while, so it is done without holding
+	// the lock to not block the requests of other heights.
+	build.state, build.err = b.rebuildHistoryState(height)
+	if build.err == nil {
+		build.state.Hash = hash
+	}
+
+	b.historyMtx.Lock()
+	if b.historyBuilds[height] == build {
+		delete(b.historyBuilds, height)
+	}
+	if build.err == nil {
+		b.cacheHistoryState(build.state)
+	}
+	b.historyMtx.Unlock()
+	close(build.done)
+
+	return build.state, build.err
+}
+
+// cacheHistoryState adds the state to the cache as the most recently used
+// one, replacing the cached state of the same height.
+//
+// This function MUST be called with the history lock held.
+func (b *BlockChain) cacheHistoryState(s *HistoryState) {
+	for i := range b.historyStates {
+		if b.historyStates[i].Height == s.Height {
+			b.historyStates = append(b.historyStates[:i],
+				b.historyStates[i+1:]...)
+			break
+		}
+	}
+	if len(b.historyStates) >= maxHistoryStates {
+		b.historyStates = b.historyStates[1:]
+	}
+	b.historyStates = append(b.historyStates, s)
+}
+
+// historyAmount is the deposit amount lookup of the history states.
+func historyAmount(common.Uint168) (common.Fixed64, error) {
+	return 0, errHistoryUTXO
+}
+
+// historyUTXO is the UTXO lookup of the history states.
+func historyUTXO(*common.Uint168) ([]*common2.UTXO, error) {
+	return nil, errHistoryUTXO
+}
+
+// rebuildHistoryState creates a new pair of DPoS and CR states isolated from
+// the live ones, restores them from the checkpoint files and replays the
+// blocks up to the given height.
+func (b *BlockChain) rebuildHistoryState(height uint32) (*HistoryState, error) {
+	if !b.chainParams.CheckPointConfiguration.EnableHistory {
+		return nil, errors.New("history checkpoints are not enabled")
+	}
+	files, err := checkpoint.LoadSnapshotFiles(
+		b.chainParams.CheckPointConfiguration.DataPath, height)
+	if err != nil {
+		return nil, err
+	}
+
+	params := *b.chainParams
+	params.CheckPointConfiguration.NeedSave = false
+	ckpManager := checkpoint.NewManager(&params)
+	defer ckpManager.Close()
+
+	committee := crstate.NewCommittee(&params, ckpManager)
+	arbiters, err := state.NewHistoryArbitrators(&params, committee,
+		historyAmount, ckpManager)
+	if err != nil {
+		return nil, err
+	}
+
+	// The live best height is registered during replaying, so no
+	// transaction will be created by the replayed blocks.
+	arbiters.RegisterFunction(b.GetHeight, b.GetBestBlockHash, b.GetBlock,
+		b.UTXOCache.GetTxReference)
+	committee.RegisterFuncitons(&crstate.CommitteeFuncsConfig{
+		GetTxReference:     b.UTXOCache.GetTxReference,
+		GetUTXO:            historyUTXO,
+		GetHeight:          b.GetHeight,
+		GetCurrentArbiters: arbiters.GetCurrentArbitratorKeys,
+	})
+
+	if err := ckpManager.RestoreSnapshotFiles(files); err != nil {
+		return nil, err
+	}
+
+	startHeight := height
+	for _, f := range files {
+		if f.Height < startHeight {
+			startHeight = f.Height
+		}
+	}
+	log.Debugf("rebuild history state from height %d to %d",
+		startHeight, height)
+
+	for i := startHeight + 1; i <= height; i++ {
+		hash, err := b.GetBlockHash(i)
+		if err != nil {
+			return nil, err
+		}
+		block, err := b.db.GetFFLDB().GetBlock(hash)
+		if err != nil {
+			return nil, fmt.Errorf("fetch block at height %d failed: %s",
+				i, err)
+		}
+
+		if block.Height >= b.chainParams.DPoSV2StartHeight ||
+			block.Height >= height-uint32(
+				b.chainParams.DPoSConfiguration.NormalArbitratorsCount+
+					len(b.chainParams.DPoSConfiguration.CRCArbiters)) {
+			CalculateTxsFee(block.Block)
+		}
+
+		for _, tx := range block.Transactions {
+			if tx.TxType() != common2.InactiveArbitrators {
+				continue
+			}
+			if err := arbiters.ProcessSpecialTxPayload(tx.Payload(),
+				block.Height-1); err != nil {
+				return nil, errors.New("force change fail when finding " +
+					"an inactive arbitrators transaction")
+			}
+		}
+
+		ckpManager.OnBlockSaved(block, nil,
+			arbiters.State.ConsensusAlgorithm == state.POW,
+			arbiters.State.RevertToPOWBlockHeight, true)
+	}
+
+	// Take the history height as the best height of the arbiters, so the
+	// on duty arbiter and the turn heights are relative to it.
+	bestHeight := func() uint32 { return height }
+	bestBlockHash := func() *common.Uint256 {
+		hash, err := b.GetBlockHash(height)
+		if err != nil {
+			return nil
+		}
+		return &hash
+	}
+	arbiters.RegisterFunction(bestHeight, bestBlockHash, b.GetBlock,
+		b.UTXOCache.GetTxReference)
+	committee.RegisterFuncitons(&crstate.CommitteeFuncsConfig{
+		GetTxReference:     b.UTXOCache.GetTxReference,
+		GetUTXO:            historyUTXO,
+		GetHeight:          bestHeight,
+		GetCurrentArbiters: arbiters.GetCurrentArbitratorKeys,
+	})
+
+	return &HistoryState{
+		Height:    height,
+		Arbiters:  arbiters,
+		State:     arbiters.State,
+		Committee: committee,
+	}, nil
+}
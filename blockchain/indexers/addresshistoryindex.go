@@ -0,0 +1,347 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package indexers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types"
+	"github.com/elastos/Elastos.ELA/core/types/interfaces"
+	"github.com/elastos/Elastos.ELA/database"
+)
+
+const (
+	// addrHistoryIndexName is the human-readable name for the index.
+	addrHistoryIndexName = "address history index"
+
+	// addrHistoryKeySize is the size of the key of an address history
+	// entry, which is made of the block height and the position of the
+	// transaction inside the block.
+	addrHistoryKeySize = 8
+
+	// addrHistoryValueSize is the size of the serialized value of an
+	// address history entry.
+	addrHistoryValueSize = common.UINT256SIZE + 8 + 8
+)
+
+var (
+	// AddrHistoryIndexKey is the key of the address history index and the
+	// DB bucket used to house it.
+	AddrHistoryIndexKey = []byte("addrhistoryidx")
+
+	// ErrAddrHistoryIndexDisabled is returned when the address history is
+	// requested but the index is not enabled.
+	ErrAddrHistoryIndexDisabled = errors.New("address history index is not enabled")
+)
+
+// -----------------------------------------------------------------------------
+// The address history index maps every program hash to the transactions that
+// touched it, either by paying to it or by spending one of its outputs.  Each
+// program hash has its own sub bucket, so iterating it with a cursor yields
+// the history of the address in chain order.
+//
+// The serialized key format is:
+//
+//   <block height><tx position>
+//
+//   Field           Type             Size
+//   block height    uint32           4 bytes (big endian)
+//   tx position     uint32           4 bytes (big endian)
+//
+// The serialized value format is:
+//
+//   <tx hash><received><sent>
+//
+//   Field           Type             Size
+//   tx hash         common.Uint256   common.UINT256SIZE
+//   received        common.Fixed64   8 bytes
+//   sent            common.Fixed64   8 bytes
+//
+// Keys are big endian so the lexicographical order of the database matches
+// the order of the chain.
+// -----------------------------------------------------------------------------
+
+// AddressHistoryEntry represents a transaction that touched an address along
+// with the amounts it moved in or out of the address.
+type AddressHistoryEntry struct {
+	TxID     common.Uint256
+	Height   uint32
+	Position uint32
+	Received common.Fixed64
+	Sent     common.Fixed64
+}
+
+// Key returns the database key of the entry, which can also be used as a
+// paging cursor.
+func (e *AddressHistoryEntry) Key() []byte {
+	return addrHistoryKey(e.Height, e.Position)
+}
+
+func addrHistoryKey(height uint32, position uint32) []byte {
+	key := make([]byte, addrHistoryKeySize)
+	binary.BigEndian.PutUint32(key[0:4], height)
+	binary.BigEndian.PutUint32(key[4:8], position)
+	return key
+}
+
+func serializeAddrHistoryEntry(entry *AddressHistoryEntry) []byte {
+	serialized := make([]byte, addrHistoryValueSize)
+	copy(serialized, entry.TxID[:])
+	offset := common.UINT256SIZE
+	byteOrder.PutUint64(serialized[offset:], uint64(entry.Received))
+	offset += 8
+	byteOrder.PutUint64(serialized[offset:], uint64(entry.Sent))
+	return serialized
+}
+
+func deserializeAddrHistoryEntry(key []byte,
+	serialized []byte) (*AddressHistoryEntry, error) {
+	if len(key) != addrHistoryKeySize {
+		return nil, errDeserialize("unexpected address history key size")
+	}
+	if len(serialized) != addrHistoryValueSize {
+		return nil, errDeserialize("unexpected address history value size")
+	}
+
+	var entry AddressHistoryEntry
+	entry.Height = binary.BigEndian.Uint32(key[0:4])
+	entry.Position = binary.BigEndian.Uint32(key[4:8])
+	copy(entry.TxID[:], serialized[:common.UINT256SIZE])
+	offset := common.UINT256SIZE
+	entry.Received = common.Fixed64(byteOrder.Uint64(serialized[offset:]))
+	offset += 8
+	entry.Sent = common.Fixed64(byteOrder.Uint64(serialized[offset:]))
+	return &entry, nil
+}
+
+// dbPutAddrHistoryEntry uses an existing database transaction to add an
+// address history entry for the given program hash.
+func dbPutAddrHistoryEntry(dbTx database.Tx, programHash *common.Uint168,
+	entry *AddressHistoryEntry) error {
+	addrHistoryIndex := dbTx.Metadata().Bucket(AddrHistoryIndexKey)
+	programHashIndex, err := addrHistoryIndex.CreateBucketIfNotExists(
+		programHash.Bytes())
+	if err != nil {
+		return err
+	}
+	return programHashIndex.Put(entry.Key(), serializeAddrHistoryEntry(entry))
+}
+
+// dbRemoveAddrHistoryEntry uses an existing database transaction to remove
+// the address history entry of the transaction at the given height and
+// position for the given program hash.
+func dbRemoveAddrHistoryEntry(dbTx database.Tx, programHash *common.Uint168,
+	height uint32, position uint32) error {
+	programHashIndex := dbTx.Metadata().Bucket(AddrHistoryIndexKey).
+		Bucket(programHash.Bytes())
+	if programHashIndex == nil {
+		return nil
+	}
+	return programHashIndex.Delete(addrHistoryKey(height, position))
+}
+
+// DBFetchAddrHistoryEntries uses an existing database transaction to fetch at
+// most limit history entries of the given program hash in chain order.  The
+// entries start right after the entry identified by cursor, or at the
+// beginning of the history when cursor is empty.  The returned cursor points
+// to the last returned entry and is nil when there are no more entries.
+func DBFetchAddrHistoryEntries(dbTx database.Tx, programHash *common.Uint168,
+	cursor []byte, limit uint32) ([]*AddressHistoryEntry, []byte, error) {
+	if len(cursor) != 0 && len(cursor) != addrHistoryKeySize {
+		return nil, nil, errors.New("invalid address history cursor")
+	}
+
+	entries := make([]*AddressHistoryEntry, 0)
+	programHashIndex := dbTx.Metadata().Bucket(AddrHistoryIndexKey).
+		Bucket(programHash.Bytes())
+	if programHashIndex == nil || limit == 0 {
+		return entries, nil, nil
+	}
+
+	c := programHashIndex.Cursor()
+	var ok bool
+	if len(cursor) == 0 {
+		ok = c.First()
+	} else {
+		ok = c.Seek(cursor)
+		if ok && bytes.Equal(c.Key(), cursor) {
+			ok = c.Next()
+		}
+	}
+	for ; ok; ok = c.Next() {
+		if uint32(len(entries)) == limit {
+			last := entries[len(entries)-1]
+			return entries, last.Key(), nil
+		}
+		entry, err := deserializeAddrHistoryEntry(c.Key(), c.Value())
+		if err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil, nil
+}
+
+// AddrHistoryIndex implements an address to transaction history index.
+type AddrHistoryIndex struct {
+	db      database.DB
+	txStore ITxStore
+}
+
+// Init initializes the address history index. This is part of the Indexer
+// interface.
+func (idx *AddrHistoryIndex) Init() error {
+	return nil // Nothing to do.
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *AddrHistoryIndex) Key() []byte {
+	return AddrHistoryIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *AddrHistoryIndex) Name() string {
+	return addrHistoryIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the address
+// history index.
+//
+// This is part of the Indexer interface.
+func (idx *AddrHistoryIndex) Create(dbTx database.Tx) error {
+	meta := dbTx.Metadata()
+	_, err := meta.CreateBucket(AddrHistoryIndexKey)
+	return err
+}
+
+// blockEntries collects the address history entries of every transaction in
+// the passed block, keyed by the program hash they belong to.
+func (idx *AddrHistoryIndex) blockEntries(block *types.Block) (
+	map[common.Uint168][]*AddressHistoryEntry, error) {
+	// Transactions may spend outputs created earlier in the same block, so
+	// look them up in the block before going to the tx store.
+	blockTxs := make(map[common.Uint256]interfaces.Transaction,
+		len(block.Transactions))
+	for _, txn := range block.Transactions {
+		blockTxs[txn.Hash()] = txn
+	}
+
+	entries := make(map[common.Uint168][]*AddressHistoryEntry)
+	for i, txn := range block.Transactions {
+		txEntries := make(map[common.Uint168]*AddressHistoryEntry)
+		getEntry := func(programHash common.Uint168) *AddressHistoryEntry {
+			entry, ok := txEntries[programHash]
+			if !ok {
+				entry = &AddressHistoryEntry{
+					TxID:     txn.Hash(),
+					Height:   block.Height,
+					Position: uint32(i),
+				}
+				txEntries[programHash] = entry
+				entries[programHash] = append(entries[programHash], entry)
+			}
+			return entry
+		}
+
+		for _, output := range txn.Outputs() {
+			getEntry(output.ProgramHash).Received += output.Value
+		}
+
+		if txn.IsCoinBaseTx() {
+			continue
+		}
+		for _, input := range txn.Inputs() {
+			referTx, ok := blockTxs[input.Previous.TxID]
+			if !ok {
+				var err error
+				referTx, _, err = idx.txStore.FetchTx(input.Previous.TxID)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if int(input.Previous.Index) >= len(referTx.Outputs()) {
+				return nil, AssertError("address history index refers to " +
+					"a non-existent output of " + input.Previous.TxID.String())
+			}
+			referOutput := referTx.Outputs()[input.Previous.Index]
+			getEntry(referOutput.ProgramHash).Sent += referOutput.Value
+		}
+	}
+
+	return entries, nil
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds a history entry for every
+// address touched by the transactions in the passed block.
+//
+// This is part of the Indexer interface.
+func (idx *AddrHistoryIndex) ConnectBlock(dbTx database.Tx, block *types.Block) error {
+	entries, err := idx.blockEntries(block)
+	if err != nil {
+		return err
+	}
+
+	for programHash, addrEntries := range entries {
+		for _, entry := range addrEntries {
+			if err := dbPutAddrHistoryEntry(dbTx, &programHash, entry); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the history entries
+// added for the transactions in the passed block.
+//
+// This is part of the Indexer interface.
+func (idx *AddrHistoryIndex) DisconnectBlock(dbTx database.Tx, block *types.Block) error {
+	entries, err := idx.blockEntries(block)
+	if err != nil {
+		return err
+	}
+
+	for programHash, addrEntries := range entries {
+		for _, entry := range addrEntries {
+			err := dbRemoveAddrHistoryEntry(dbTx, &programHash, entry.Height,
+				entry.Position)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// NewAddrHistoryIndex returns a new instance of an indexer that is used to
+// create a mapping of the program hashes of all addresses used in the
+// blockchain to the transactions that touched them.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewAddrHistoryIndex(db database.DB, store ITxStore) *AddrHistoryIndex {
+	return &AddrHistoryIndex{db, store}
+}
+
+// DropAddrHistoryIndex drops the address history index from the provided
+// database if it exists.
+func DropAddrHistoryIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, AddrHistoryIndexKey, addrHistoryIndexName, interrupt)
+}
@@ -69,6 +69,20 @@ type IndexManager interface {
 	// FetchUTXO retrieval the utxo set of a account address
 	FetchUTXO(programHash *common.Uint168) ([]*common2.UTXO, error)
 
+	// FetchAddressHistory retrieval a page of the transaction history of a
+	// account address, starting after the given cursor
+	FetchAddressHistory(programHash *common.Uint168, cursor []byte,
+		limit uint32) ([]*AddressHistoryEntry, []byte, error)
+
+	// FetchIllegalEvidences retrieval the transactions punishing arbiters
+	// within the height range, punishing the node public key if not empty
+	FetchIllegalEvidences(nodePublicKey []byte, startHeight,
+		endHeight uint32) ([]*IllegalEvidenceEntry, error)
+
+	// FetchCRProposalEvents retrieval the transactions changing the CR
+	// proposal in chain order
+	FetchCRProposalEvents(proposalHash common.Uint256) ([]*CRProposalEvent, error)
+
 	// IsSideChainReturnDepositExist use to find if return deposit exist in DB
 	IsSideChainReturnDepositExist(txHash *common.Uint256) bool
 }
@@ -0,0 +1,485 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package indexers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types"
+	common2 "github.com/elastos/Elastos.ELA/core/types/common"
+	"github.com/elastos/Elastos.ELA/core/types/interfaces"
+	"github.com/elastos/Elastos.ELA/core/types/outputpayload"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/database"
+)
+
+const (
+	// crProposalTimelineIndexName is the human-readable name for the index.
+	crProposalTimelineIndexName = "CR proposal timeline index"
+
+	// crProposalEventKeySize is the size of the key of a CR proposal event,
+	// which is made of the proposal hash, the block height, the position of
+	// the transaction inside the block and the sequence of the event inside
+	// the transaction.
+	crProposalEventKeySize = common.UINT256SIZE + 10
+
+	// crProposalEventSize is the size of a serialized CR proposal event.
+	crProposalEventSize = 1 + common.UINT256SIZE + 21 + 1 + 1 + 1 + 8
+)
+
+var (
+	// CRProposalTimelineIndexKey is the key of the CR proposal timeline index
+	// and the DB bucket used to house it.
+	CRProposalTimelineIndexKey = []byte("crproposaltimelineidx")
+
+	// crProposalWithdrawBucketName is the name of the bucket inside the index
+	// mapping the withdraw transactions to their proposals, which is needed
+	// to index the real withdraw transactions.
+	crProposalWithdrawBucketName = []byte("withdraws")
+
+	// ErrCRProposalTimelineIndexDisabled is returned when the timeline of a
+	// proposal is requested but the index is not enabled.
+	ErrCRProposalTimelineIndexDisabled = errors.New("CR proposal timeline index is not enabled")
+)
+
+// CRProposalEventType represents the type of an event of a CR proposal.
+type CRProposalEventType byte
+
+const (
+	// CRProposalRegistered indicates the proposal is registered by a CR
+	// member.
+	CRProposalRegistered CRProposalEventType = 0x00
+
+	// CRProposalReviewed indicates a CR member reviewed the proposal.
+	CRProposalReviewed CRProposalEventType = 0x01
+
+	// CRProposalRejectVoted indicates a voter voted to reject the proposal.
+	CRProposalRejectVoted CRProposalEventType = 0x02
+
+	// CRProposalTracked indicates the secretary general tracked the proposal.
+	CRProposalTracked CRProposalEventType = 0x03
+
+	// CRProposalWithdrawn indicates the owner withdrew the budgets of the
+	// proposal.
+	CRProposalWithdrawn CRProposalEventType = 0x04
+
+	// CRProposalRealWithdrawn indicates the withdrawn budgets are paid to
+	// the recipient of the proposal.
+	CRProposalRealWithdrawn CRProposalEventType = 0x05
+)
+
+// Name returns the name of CR proposal event type.
+func (t CRProposalEventType) Name() string {
+	switch t {
+	case CRProposalRegistered:
+		return "Registered"
+	case CRProposalReviewed:
+		return "Reviewed"
+	case CRProposalRejectVoted:
+		return "RejectVoted"
+	case CRProposalTracked:
+		return "Tracked"
+	case CRProposalWithdrawn:
+		return "Withdrawn"
+	case CRProposalRealWithdrawn:
+		return "RealWithdrawn"
+	default:
+		return fmt.Sprintf("Unknown CRProposalEventType (%d)", t)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// The CR proposal timeline index houses every transaction changing a CR
+// proposal, which are the proposal, review, reject vote, tracking, withdraw
+// and real withdraw transactions.
+//
+// The serialized key format is:
+//
+//   <proposal hash><block height><tx position><sequence>
+//
+//   Field           Type             Size
+//   proposal hash   common.Uint256   common.UINT256SIZE
+//   block height    uint32           4 bytes (big endian)
+//   tx position     uint32           4 bytes (big endian)
+//   sequence        uint16           2 bytes (big endian)
+//
+// The serialized value format is:
+//
+//   <event type><tx hash><did><opinion><tracking type><stage><amount>
+//
+//   Field           Type             Size
+//   event type      byte             1 byte
+//   tx hash         common.Uint256   common.UINT256SIZE
+//   did             common.Uint168   21 bytes
+//   opinion         byte             1 byte
+//   tracking type   byte             1 byte
+//   stage           uint8            1 byte
+//   amount          common.Fixed64   8 bytes
+//
+// Keys are big endian so the events of a proposal are in the order of the
+// chain.  The withdraws bucket maps the hash of every withdraw transaction
+// to the hash of its proposal.
+// -----------------------------------------------------------------------------
+
+// CRProposalEvent represents a transaction changing a CR proposal.  Fields
+// not related to the type of the event are left zero.
+type CRProposalEvent struct {
+	ProposalHash common.Uint256
+	Type         CRProposalEventType
+	TxID         common.Uint256
+	Height       uint32
+	Position     uint32
+	Sequence     uint16
+
+	// DID is the DID of the CR member registering or reviewing the proposal.
+	DID common.Uint168
+
+	// Opinion is the vote result of the review.
+	Opinion payload.VoteResult
+
+	// TrackingType and Stage are the type and the budget stage of the
+	// tracking.
+	TrackingType payload.CRCProposalTrackingType
+	Stage        uint8
+
+	// Amount is the reject votes of the voter or the amount withdrawn.
+	Amount common.Fixed64
+}
+
+// CRProposalEvents returns the events of the CR proposals changed by the
+// given transaction.  The real withdraw transactions do not carry the hash
+// of proposals, so they are resolved by the given function with the hash of
+// the withdraw transactions.
+func CRProposalEvents(txn interfaces.Transaction,
+	proposalOf func(withdrawTx common.Uint256) (common.Uint256, bool)) []*CRProposalEvent {
+	var events []*CRProposalEvent
+	switch p := txn.Payload().(type) {
+	case *payload.CRCProposal:
+		events = append(events, &CRProposalEvent{
+			ProposalHash: p.Hash(txn.PayloadVersion()),
+			Type:         CRProposalRegistered,
+			DID:          p.CRCouncilMemberDID,
+		})
+
+	case *payload.CRCProposalReview:
+		events = append(events, &CRProposalEvent{
+			ProposalHash: p.ProposalHash,
+			Type:         CRProposalReviewed,
+			DID:          p.DID,
+			Opinion:      p.VoteResult,
+		})
+
+	case *payload.CRCProposalTracking:
+		events = append(events, &CRProposalEvent{
+			ProposalHash: p.ProposalHash,
+			Type:         CRProposalTracked,
+			TrackingType: p.ProposalTrackingType,
+			Stage:        p.Stage,
+		})
+
+	case *payload.CRCProposalWithdraw:
+		amount := p.Amount
+		if txn.PayloadVersion() == payload.CRCProposalWithdrawDefault &&
+			len(txn.Outputs()) > 0 {
+			amount = txn.Outputs()[0].Value
+		}
+		events = append(events, &CRProposalEvent{
+			ProposalHash: p.ProposalHash,
+			Type:         CRProposalWithdrawn,
+			Amount:       amount,
+		})
+
+	case *payload.CRCProposalRealWithdraw:
+		for i, hash := range p.WithdrawTransactionHashes {
+			proposalHash, ok := proposalOf(hash)
+			if !ok || i >= len(txn.Outputs()) {
+				continue
+			}
+			events = append(events, &CRProposalEvent{
+				ProposalHash: proposalHash,
+				Type:         CRProposalRealWithdrawn,
+				Amount:       txn.Outputs()[i].Value,
+			})
+		}
+
+	case *payload.Voting:
+		for _, content := range p.Contents {
+			if content.VoteType != outputpayload.CRCProposal {
+				continue
+			}
+			for _, v := range content.VotesInfo {
+				events = appendRejectVoteEvent(events, v.Candidate, v.Votes)
+			}
+		}
+	}
+
+	if txn.Version() >= common2.TxVersion09 {
+		for _, output := range txn.Outputs() {
+			if output.Type != common2.OTVote {
+				continue
+			}
+			p, ok := output.Payload.(*outputpayload.VoteOutput)
+			if !ok || p.Version < outputpayload.VoteProducerAndCRVersion {
+				continue
+			}
+			for _, content := range p.Contents {
+				if content.VoteType != outputpayload.CRCProposal {
+					continue
+				}
+				for _, cv := range content.CandidateVotes {
+					events = appendRejectVoteEvent(events, cv.Candidate,
+						cv.Votes)
+				}
+			}
+		}
+	}
+
+	txHash := txn.Hash()
+	for i, e := range events {
+		e.TxID = txHash
+		e.Sequence = uint16(i)
+	}
+	return events
+}
+
+func appendRejectVoteEvent(events []*CRProposalEvent, candidate []byte,
+	votes common.Fixed64) []*CRProposalEvent {
+	proposalHash, err := common.Uint256FromBytes(candidate)
+	if err != nil {
+		return events
+	}
+	return append(events, &CRProposalEvent{
+		ProposalHash: *proposalHash,
+		Type:         CRProposalRejectVoted,
+		Amount:       votes,
+	})
+}
+
+func crProposalEventKey(proposalHash common.Uint256, height uint32,
+	position uint32, sequence uint16) []byte {
+	key := make([]byte, crProposalEventKeySize)
+	copy(key, proposalHash[:])
+	offset := common.UINT256SIZE
+	binary.BigEndian.PutUint32(key[offset:offset+4], height)
+	binary.BigEndian.PutUint32(key[offset+4:offset+8], position)
+	binary.BigEndian.PutUint16(key[offset+8:offset+10], sequence)
+	return key
+}
+
+func serializeCRProposalEvent(event *CRProposalEvent) ([]byte, error) {
+	w := new(bytes.Buffer)
+	if err := common.WriteUint8(w, uint8(event.Type)); err != nil {
+		return nil, err
+	}
+	if err := event.TxID.Serialize(w); err != nil {
+		return nil, err
+	}
+	if err := event.DID.Serialize(w); err != nil {
+		return nil, err
+	}
+	err := common.WriteElements(w, uint8(event.Opinion),
+		uint8(event.TrackingType), event.Stage)
+	if err != nil {
+		return nil, err
+	}
+	if err := event.Amount.Serialize(w); err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+func deserializeCRProposalEvent(key []byte,
+	serialized []byte) (*CRProposalEvent, error) {
+	if len(key) != crProposalEventKeySize {
+		return nil, errDeserialize("unexpected CR proposal event key size")
+	}
+	if len(serialized) != crProposalEventSize {
+		return nil, errDeserialize("unexpected CR proposal event size")
+	}
+
+	var event CRProposalEvent
+	copy(event.ProposalHash[:], key[:common.UINT256SIZE])
+	offset := common.UINT256SIZE
+	event.Height = binary.BigEndian.Uint32(key[offset : offset+4])
+	event.Position = binary.BigEndian.Uint32(key[offset+4 : offset+8])
+	event.Sequence = binary.BigEndian.Uint16(key[offset+8 : offset+10])
+
+	r := bytes.NewReader(serialized)
+	eventType, _ := common.ReadUint8(r)
+	event.Type = CRProposalEventType(eventType)
+	_ = event.TxID.Deserialize(r)
+	_ = event.DID.Deserialize(r)
+	var opinion, trackingType uint8
+	_ = common.ReadElements(r, &opinion, &trackingType, &event.Stage)
+	event.Opinion = payload.VoteResult(opinion)
+	event.TrackingType = payload.CRCProposalTrackingType(trackingType)
+	_ = event.Amount.Deserialize(r)
+	return &event, nil
+}
+
+// DBFetchCRProposalEvents uses an existing database transaction to fetch the
+// events of the given CR proposal in chain order.
+func DBFetchCRProposalEvents(dbTx database.Tx,
+	proposalHash common.Uint256) ([]*CRProposalEvent, error) {
+	events := make([]*CRProposalEvent, 0)
+	index := dbTx.Metadata().Bucket(CRProposalTimelineIndexKey)
+	if index == nil {
+		return events, nil
+	}
+
+	c := index.Cursor()
+	for ok := c.Seek(proposalHash[:]); ok; ok = c.Next() {
+		if !bytes.HasPrefix(c.Key(), proposalHash[:]) {
+			break
+		}
+		event, err := deserializeCRProposalEvent(c.Key(), c.Value())
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// withdrawProposalOf returns a function resolving the proposal of withdraw
+// transactions by the given withdraws bucket.
+func withdrawProposalOf(withdraws database.Bucket) func(common.Uint256) (common.Uint256, bool) {
+	return func(withdrawTx common.Uint256) (common.Uint256, bool) {
+		var hash common.Uint256
+		value := withdraws.Get(withdrawTx[:])
+		if len(value) != common.UINT256SIZE {
+			return hash, false
+		}
+		copy(hash[:], value)
+		return hash, true
+	}
+}
+
+// CRProposalTimelineIndex implements an index of the transactions changing
+// CR proposals.
+type CRProposalTimelineIndex struct {
+	db database.DB
+}
+
+// Init initializes the CR proposal timeline index. This is part of the
+// Indexer interface.
+func (idx *CRProposalTimelineIndex) Init() error {
+	return nil // Nothing to do.
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *CRProposalTimelineIndex) Key() []byte {
+	return CRProposalTimelineIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *CRProposalTimelineIndex) Name() string {
+	return crProposalTimelineIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the CR
+// proposal timeline index along with the bucket of withdraw transactions.
+//
+// This is part of the Indexer interface.
+func (idx *CRProposalTimelineIndex) Create(dbTx database.Tx) error {
+	meta := dbTx.Metadata()
+	index, err := meta.CreateBucket(CRProposalTimelineIndexKey)
+	if err != nil {
+		return err
+	}
+	_, err = index.CreateBucket(crProposalWithdrawBucketName)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds an entry for every event
+// of CR proposals in the passed block.
+//
+// This is part of the Indexer interface.
+func (idx *CRProposalTimelineIndex) ConnectBlock(dbTx database.Tx, block *types.Block) error {
+	index := dbTx.Metadata().Bucket(CRProposalTimelineIndexKey)
+	withdraws := index.Bucket(crProposalWithdrawBucketName)
+	proposalOf := withdrawProposalOf(withdraws)
+
+	for i, txn := range block.Transactions {
+		events := CRProposalEvents(txn, proposalOf)
+		for _, e := range events {
+			serialized, err := serializeCRProposalEvent(e)
+			if err != nil {
+				return err
+			}
+			key := crProposalEventKey(e.ProposalHash, block.Height, uint32(i),
+				e.Sequence)
+			if err := index.Put(key, serialized); err != nil {
+				return err
+			}
+			if e.Type == CRProposalWithdrawn {
+				err := withdraws.Put(e.TxID[:], e.ProposalHash[:])
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the entries added
+// for the events in the passed block.
+//
+// This is part of the Indexer interface.
+func (idx *CRProposalTimelineIndex) DisconnectBlock(dbTx database.Tx, block *types.Block) error {
+	index := dbTx.Metadata().Bucket(CRProposalTimelineIndexKey)
+	withdraws := index.Bucket(crProposalWithdrawBucketName)
+	proposalOf := withdrawProposalOf(withdraws)
+
+	// Remove the events in reverse order, so the withdraw transactions are
+	// still there while removing the real withdraw transactions of them.
+	for i := len(block.Transactions) - 1; i >= 0; i-- {
+		events := CRProposalEvents(block.Transactions[i], proposalOf)
+		for _, e := range events {
+			key := crProposalEventKey(e.ProposalHash, block.Height, uint32(i),
+				e.Sequence)
+			if err := index.Delete(key); err != nil {
+				return err
+			}
+			if e.Type == CRProposalWithdrawn {
+				if err := withdraws.Delete(e.TxID[:]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// NewCRProposalTimelineIndex returns a new instance of an indexer that is
+// used to create an index of the transactions changing CR proposals.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewCRProposalTimelineIndex(db database.DB) *CRProposalTimelineIndex {
+	return &CRProposalTimelineIndex{db}
+}
+
+// DropCRProposalTimelineIndex drops the CR proposal timeline index from the
+// provided database if it exists.
+func DropCRProposalTimelineIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, CRProposalTimelineIndexKey,
+		crProposalTimelineIndexName, interrupt)
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package indexers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCRProposalEvent_Serialize(t *testing.T) {
+	event := &CRProposalEvent{
+		ProposalHash: common.Uint256{1, 2, 3},
+		Type:         CRProposalTracked,
+		TxID:         common.Uint256{4, 5, 6},
+		Height:       1024,
+		Position:     3,
+		Sequence:     1,
+		DID:          common.Uint168{7, 8, 9},
+		Opinion:      payload.Abstain,
+		TrackingType: payload.Progress,
+		Stage:        2,
+		Amount:       common.Fixed64(100000000),
+	}
+
+	serialized, err := serializeCRProposalEvent(event)
+	assert.NoError(t, err)
+	assert.Equal(t, crProposalEventSize, len(serialized))
+	key := crProposalEventKey(event.ProposalHash, event.Height,
+		event.Position, event.Sequence)
+	result, err := deserializeCRProposalEvent(key, serialized)
+	assert.NoError(t, err)
+	assert.Equal(t, event, result)
+
+	_, err = deserializeCRProposalEvent(key[1:], serialized)
+	assert.Error(t, err)
+	_, err = deserializeCRProposalEvent(key, serialized[:40])
+	assert.Error(t, err)
+}
+
+func TestCRProposalEventKey_Order(t *testing.T) {
+	hash := common.Uint256{1}
+	keys := [][]byte{
+		crProposalEventKey(hash, 1, 5, 0),
+		crProposalEventKey(hash, 2, 0, 0),
+		crProposalEventKey(hash, 2, 0, 1),
+		crProposalEventKey(hash, 256, 0, 0),
+		crProposalEventKey(common.Uint256{2}, 0, 0, 0),
+	}
+	for i := 1; i < len(keys); i++ {
+		assert.Equal(t, -1, bytes.Compare(keys[i-1], keys[i]))
+	}
+	assert.True(t, bytes.HasPrefix(keys[3], hash[:]))
+	assert.False(t, bytes.HasPrefix(keys[4], hash[:]))
+}
@@ -0,0 +1,313 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package indexers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types"
+	common2 "github.com/elastos/Elastos.ELA/core/types/common"
+	"github.com/elastos/Elastos.ELA/core/types/interfaces"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/crypto"
+	"github.com/elastos/Elastos.ELA/database"
+)
+
+const (
+	// illegalEvidenceIndexName is the human-readable name for the index.
+	illegalEvidenceIndexName = "illegal evidence index"
+
+	// illegalEvidenceKeySize is the size of the key of an illegal evidence
+	// entry, which is made of the block height and the position of the
+	// transaction inside the block.
+	illegalEvidenceKeySize = 8
+)
+
+var (
+	// IllegalEvidenceIndexKey is the key of the illegal evidence index and
+	// the DB bucket used to house it.
+	IllegalEvidenceIndexKey = []byte("illegalevidenceidx")
+
+	// ErrIllegalEvidenceIndexDisabled is returned when the illegal evidences
+	// are requested but the index is not enabled.
+	ErrIllegalEvidenceIndexDisabled = errors.New("illegal evidence index is not enabled")
+)
+
+// -----------------------------------------------------------------------------
+// The illegal evidence index houses every transaction that punishes
+// arbiters, which are the illegal proposal, vote, block and sidechain
+// evidences along with the inactive arbitrators transactions.
+//
+// The serialized key format is:
+//
+//   <block height><tx position>
+//
+//   Field           Type             Size
+//   block height    uint32           4 bytes (big endian)
+//   tx position     uint32           4 bytes (big endian)
+//
+// The serialized value format is:
+//
+//   <tx hash><tx type><offenders count><offender>...
+//
+//   Field           Type             Size
+//   tx hash         common.Uint256   common.UINT256SIZE
+//   tx type         byte             1 byte
+//   offenders count uint64           variable
+//   offender        []byte           variable, node public key
+//
+// Keys are big endian so the lexicographical order of the database matches
+// the order of the chain.
+// -----------------------------------------------------------------------------
+
+// IllegalEvidenceEntry represents a transaction that punishes arbiters along
+// with the node public keys of the punished arbiters.
+type IllegalEvidenceEntry struct {
+	TxID      common.Uint256
+	TxType    common2.TxType
+	Height    uint32
+	Position  uint32
+	Offenders [][]byte
+}
+
+// HasOffender returns if the given node public key is one of the offenders
+// of the entry.
+func (e *IllegalEvidenceEntry) HasOffender(nodePublicKey []byte) bool {
+	for _, o := range e.Offenders {
+		if bytes.Equal(o, nodePublicKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// IllegalEvidenceOffenders returns the node public keys of the arbiters
+// punished by the given transaction, and whether the transaction is a
+// punishment.
+func IllegalEvidenceOffenders(txn interfaces.Transaction) ([][]byte, bool) {
+	switch p := txn.Payload().(type) {
+	case *payload.DPOSIllegalProposals:
+		return [][]byte{p.Evidence.Proposal.Sponsor}, true
+
+	case *payload.DPOSIllegalVotes:
+		return [][]byte{p.Evidence.Vote.Signer}, true
+
+	case *payload.DPOSIllegalBlocks:
+		signers := make(map[string]interface{})
+		for _, pk := range p.Evidence.Signers {
+			signers[hex.EncodeToString(pk)] = nil
+		}
+		var offenders [][]byte
+		for _, pk := range p.CompareEvidence.Signers {
+			if _, ok := signers[hex.EncodeToString(pk)]; ok {
+				offenders = append(offenders, pk)
+			}
+		}
+		return offenders, true
+
+	case *payload.SidechainIllegalData:
+		return [][]byte{p.IllegalSigner}, true
+
+	case *payload.InactiveArbitrators:
+		return p.Arbitrators, true
+	}
+	return nil, false
+}
+
+func illegalEvidenceKey(height uint32, position uint32) []byte {
+	key := make([]byte, illegalEvidenceKeySize)
+	binary.BigEndian.PutUint32(key[0:4], height)
+	binary.BigEndian.PutUint32(key[4:8], position)
+	return key
+}
+
+func serializeIllegalEvidenceEntry(entry *IllegalEvidenceEntry) ([]byte, error) {
+	w := new(bytes.Buffer)
+	if err := entry.TxID.Serialize(w); err != nil {
+		return nil, err
+	}
+	if err := common.WriteUint8(w, uint8(entry.TxType)); err != nil {
+		return nil, err
+	}
+	if err := common.WriteVarUint(w, uint64(len(entry.Offenders))); err != nil {
+		return nil, err
+	}
+	for _, o := range entry.Offenders {
+		if err := common.WriteVarBytes(w, o); err != nil {
+			return nil, err
+		}
+	}
+	return w.Bytes(), nil
+}
+
+func deserializeIllegalEvidenceEntry(key []byte,
+	serialized []byte) (*IllegalEvidenceEntry, error) {
+	if len(key) != illegalEvidenceKeySize {
+		return nil, errDeserialize("unexpected illegal evidence key size")
+	}
+
+	var entry IllegalEvidenceEntry
+	entry.Height = binary.BigEndian.Uint32(key[0:4])
+	entry.Position = binary.BigEndian.Uint32(key[4:8])
+
+	r := bytes.NewReader(serialized)
+	if err := entry.TxID.Deserialize(r); err != nil {
+		return nil, errDeserialize("unexpected illegal evidence tx hash")
+	}
+	txType, err := common.ReadUint8(r)
+	if err != nil {
+		return nil, errDeserialize("unexpected illegal evidence tx type")
+	}
+	entry.TxType = common2.TxType(txType)
+	count, err := common.ReadVarUint(r, 0)
+	if err != nil {
+		return nil, errDeserialize("unexpected illegal evidence offenders")
+	}
+	for i := uint64(0); i < count; i++ {
+		o, err := common.ReadVarBytes(r, crypto.NegativeBigLength,
+			"offender")
+		if err != nil {
+			return nil, errDeserialize("unexpected illegal evidence offender")
+		}
+		entry.Offenders = append(entry.Offenders, o)
+	}
+	return &entry, nil
+}
+
+// DBFetchIllegalEvidenceEntries uses an existing database transaction to
+// fetch the illegal evidence entries within the given height range in chain
+// order.  Only the entries punishing the given node public key are returned
+// if it is not empty.
+func DBFetchIllegalEvidenceEntries(dbTx database.Tx, nodePublicKey []byte,
+	startHeight, endHeight uint32) ([]*IllegalEvidenceEntry, error) {
+	entries := make([]*IllegalEvidenceEntry, 0)
+	index := dbTx.Metadata().Bucket(IllegalEvidenceIndexKey)
+	if index == nil || startHeight > endHeight {
+		return entries, nil
+	}
+
+	c := index.Cursor()
+	for ok := c.Seek(illegalEvidenceKey(startHeight, 0)); ok; ok = c.Next() {
+		entry, err := deserializeIllegalEvidenceEntry(c.Key(), c.Value())
+		if err != nil {
+			return nil, err
+		}
+		if entry.Height > endHeight {
+			break
+		}
+		if len(nodePublicKey) != 0 && !entry.HasOffender(nodePublicKey) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// IllegalEvidenceIndex implements an index of the transactions punishing
+// arbiters.
+type IllegalEvidenceIndex struct {
+	db database.DB
+}
+
+// Init initializes the illegal evidence index. This is part of the Indexer
+// interface.
+func (idx *IllegalEvidenceIndex) Init() error {
+	return nil // Nothing to do.
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *IllegalEvidenceIndex) Key() []byte {
+	return IllegalEvidenceIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *IllegalEvidenceIndex) Name() string {
+	return illegalEvidenceIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the illegal
+// evidence index.
+//
+// This is part of the Indexer interface.
+func (idx *IllegalEvidenceIndex) Create(dbTx database.Tx) error {
+	meta := dbTx.Metadata()
+	_, err := meta.CreateBucket(IllegalEvidenceIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds an entry for every
+// transaction punishing arbiters in the passed block.
+//
+// This is part of the Indexer interface.
+func (idx *IllegalEvidenceIndex) ConnectBlock(dbTx database.Tx, block *types.Block) error {
+	index := dbTx.Metadata().Bucket(IllegalEvidenceIndexKey)
+	for i, txn := range block.Transactions {
+		offenders, ok := IllegalEvidenceOffenders(txn)
+		if !ok {
+			continue
+		}
+		serialized, err := serializeIllegalEvidenceEntry(&IllegalEvidenceEntry{
+			TxID:      txn.Hash(),
+			TxType:    txn.TxType(),
+			Offenders: offenders,
+		})
+		if err != nil {
+			return err
+		}
+		err = index.Put(illegalEvidenceKey(block.Height, uint32(i)), serialized)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the entries added
+// for the transactions in the passed block.
+//
+// This is part of the Indexer interface.
+func (idx *IllegalEvidenceIndex) DisconnectBlock(dbTx database.Tx, block *types.Block) error {
+	index := dbTx.Metadata().Bucket(IllegalEvidenceIndexKey)
+	for i, txn := range block.Transactions {
+		if _, ok := IllegalEvidenceOffenders(txn); !ok {
+			continue
+		}
+		err := index.Delete(illegalEvidenceKey(block.Height, uint32(i)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewIllegalEvidenceIndex returns a new instance of an indexer that is used
+// to create an index of the transactions punishing arbiters.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewIllegalEvidenceIndex(db database.DB) *IllegalEvidenceIndex {
+	return &IllegalEvidenceIndex{db}
+}
+
+// DropIllegalEvidenceIndex drops the illegal evidence index from the provided
+// database if it exists.
+func DropIllegalEvidenceIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, IllegalEvidenceIndexKey, illegalEvidenceIndexName,
+		interrupt)
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package indexers
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/common"
+	common2 "github.com/elastos/Elastos.ELA/core/types/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIllegalEvidenceEntry_Serialize(t *testing.T) {
+	offender1, _ := common.HexStringToBytes(
+		"03c3bd59e853ab20b56e5e379f333a9fbc650db536a39e37c1340ce54dc74a39ea")
+	offender2, _ := common.HexStringToBytes(
+		"03065bcbdd897e654bcee27afac10c7be9c9fe40e13da3e4240923d24631b06a7b")
+	entry := &IllegalEvidenceEntry{
+		TxID:      common.Uint256{1, 2, 3},
+		TxType:    common2.IllegalBlockEvidence,
+		Height:    1024,
+		Position:  3,
+		Offenders: [][]byte{offender1, offender2},
+	}
+
+	serialized, err := serializeIllegalEvidenceEntry(entry)
+	assert.NoError(t, err)
+	result, err := deserializeIllegalEvidenceEntry(
+		illegalEvidenceKey(entry.Height, entry.Position), serialized)
+	assert.NoError(t, err)
+	assert.Equal(t, entry, result)
+	assert.True(t, result.HasOffender(offender2))
+	assert.False(t, result.HasOffender(offender1[1:]))
+
+	_, err = deserializeIllegalEvidenceEntry([]byte{1}, serialized)
+	assert.Error(t, err)
+	_, err = deserializeIllegalEvidenceEntry(
+		illegalEvidenceKey(entry.Height, entry.Position), serialized[:40])
+	assert.Error(t, err)
+}
@@ -15,7 +15,6 @@ import (
 
 	"github.com/elastos/Elastos.ELA/common"
 	"github.com/elastos/Elastos.ELA/common/config"
-	"github.com/elastos/Elastos.ELA/common/log"
 	"github.com/elastos/Elastos.ELA/core/types"
 	common2 "github.com/elastos/Elastos.ELA/core/types/common"
 	"github.com/elastos/Elastos.ELA/core/types/interfaces"
@@ -136,9 +135,12 @@ func dbIndexDisconnectBlock(dbTx database.Tx, indexer Indexer, block *types.Bloc
 // implements the blockchain.IndexManager interface so it can be seamlessly
 // plugged into normal chain processing.
 type Manager struct {
-	db             database.DB
-	enabledIndexes []Indexer
-	txStore        ITxStore
+	db               database.DB
+	enabledIndexes   []Indexer
+	txStore          ITxStore
+	addrHistoryIndex *AddrHistoryIndex
+	evidenceIndex    *IllegalEvidenceIndex
+	timelineIndex    *CRProposalTimelineIndex
 }
 
 // Ensure the Manager type implements the blockchain.IndexManager interface.
@@ -255,6 +257,31 @@ func (m *Manager) Init(chain IChain, interrupt <-chan struct{}) error {
 		return err
 	}
 
+	// Drop the address history index if it has been disabled, so it will
+	// be rebuilt from the genesis block once it is enabled again.
+	if m.addrHistoryIndex == nil {
+		if err := m.maybeDropIndex(AddrHistoryIndexKey,
+			DropAddrHistoryIndex, interrupt); err != nil {
+			return err
+		}
+	}
+
+	// So does the illegal evidence index.
+	if m.evidenceIndex == nil {
+		if err := m.maybeDropIndex(IllegalEvidenceIndexKey,
+			DropIllegalEvidenceIndex, interrupt); err != nil {
+			return err
+		}
+	}
+
+	// And the CR proposal timeline index.
+	if m.timelineIndex == nil {
+		if err := m.maybeDropIndex(CRProposalTimelineIndexKey,
+			DropCRProposalTimelineIndex, interrupt); err != nil {
+			return err
+		}
+	}
+
 	// Create the initial state for the indexes as needed.
 	err := m.db.Update(func(dbTx database.Tx) error {
 		// Create the bucket for the current tips as needed.
@@ -507,6 +534,66 @@ func (m *Manager) FetchUTXO(programHash *common.Uint168) ([]*common2.UTXO, error
 	return utxos, nil
 }
 
+func (m *Manager) FetchAddressHistory(programHash *common.Uint168,
+	cursor []byte, limit uint32) ([]*AddressHistoryEntry, []byte, error) {
+	if m.addrHistoryIndex == nil {
+		return nil, nil, ErrAddrHistoryIndexDisabled
+	}
+
+	var entries []*AddressHistoryEntry
+	var next []byte
+	err := m.db.View(func(dbTx database.Tx) error {
+		var err error
+		entries, next, err = DBFetchAddrHistoryEntries(dbTx, programHash,
+			cursor, limit)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return entries, next, nil
+}
+
+func (m *Manager) FetchIllegalEvidences(nodePublicKey []byte,
+	startHeight, endHeight uint32) ([]*IllegalEvidenceEntry, error) {
+	if m.evidenceIndex == nil {
+		return nil, ErrIllegalEvidenceIndexDisabled
+	}
+
+	var entries []*IllegalEvidenceEntry
+	err := m.db.View(func(dbTx database.Tx) error {
+		var err error
+		entries, err = DBFetchIllegalEvidenceEntries(dbTx, nodePublicKey,
+			startHeight, endHeight)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (m *Manager) FetchCRProposalEvents(
+	proposalHash common.Uint256) ([]*CRProposalEvent, error) {
+	if m.timelineIndex == nil {
+		return nil, ErrCRProposalTimelineIndexDisabled
+	}
+
+	var events []*CRProposalEvent
+	err := m.db.View(func(dbTx database.Tx) error {
+		var err error
+		events, err = DBFetchCRProposalEvents(dbTx, proposalHash)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
 func (m *Manager) IsSideChainReturnDepositExist(txHash *common.Uint256) bool {
 	exist := false
 	_ = m.db.View(func(dbTx database.Tx) error {
@@ -528,11 +615,47 @@ func NewManager(db database.DB, params *config.Configuration) *Manager {
 	returnDepositIndex := NewReturnDepositIndex(db)
 	var enabledIndexes []Indexer
 	enabledIndexes = append(enabledIndexes, txIndex, unspentIndex, utxoIndex, returnDepositIndex)
+	var addrHistoryIndex *AddrHistoryIndex
+	if params.EnableAddressHistory {
+		addrHistoryIndex = NewAddrHistoryIndex(db, unspentIndex)
+		enabledIndexes = append(enabledIndexes, addrHistoryIndex)
+	}
+	var evidenceIndex *IllegalEvidenceIndex
+	if params.EnableIllegalEvidenceIndex {
+		evidenceIndex = NewIllegalEvidenceIndex(db)
+		enabledIndexes = append(enabledIndexes, evidenceIndex)
+	}
+	var timelineIndex *CRProposalTimelineIndex
+	if params.EnableCRProposalTimelineIndex {
+		timelineIndex = NewCRProposalTimelineIndex(db)
+		enabledIndexes = append(enabledIndexes, timelineIndex)
+	}
 	return &Manager{
-		db:             db,
-		enabledIndexes: enabledIndexes,
-		txStore:        unspentIndex,
+		db:               db,
+		enabledIndexes:   enabledIndexes,
+		txStore:          unspentIndex,
+		addrHistoryIndex: addrHistoryIndex,
+		evidenceIndex:    evidenceIndex,
+		timelineIndex:    timelineIndex,
+	}
+}
+
+// maybeDropIndex drops the optional index with the given key by the drop
+// function when it exists in the database.
+func (m *Manager) maybeDropIndex(idxKey []byte,
+	drop func(database.DB, <-chan struct{}) error,
+	interrupt <-chan struct{}) error {
+	var exists bool
+	err := m.db.View(func(dbTx database.Tx) error {
+		indexesBucket := dbTx.Metadata().Bucket(indexTipsBucketName)
+		exists = indexesBucket != nil && indexesBucket.Get(idxKey) != nil
+		return nil
+	})
+	if err != nil || !exists {
+		return err
 	}
+
+	return drop(m.db, interrupt)
 }
 
 // dropIndex drops the passed index from the database.  Since indexes can be
@@ -0,0 +1,162 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package indexers
+
+import (
+	"bytes"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/common/config"
+	"github.com/elastos/Elastos.ELA/core/types/functions"
+	"github.com/elastos/Elastos.ELA/core/types/interfaces"
+	"github.com/elastos/Elastos.ELA/database"
+)
+
+var (
+	// snapshotTxBucketName is the name of the DB bucket used to house the
+	// transactions imported from a chain snapshot, whose blocks are not
+	// stored in the database.
+	snapshotTxBucketName = []byte("snapshottxidx")
+)
+
+// -----------------------------------------------------------------------------
+// The snapshot transaction bucket houses every transaction that still has
+// unspent outputs at the height of an imported chain snapshot, or that is
+// referenced by the blocks carried by the snapshot.  A pruned node also keeps
+// the transactions of the pruned blocks that are still needed in it.
+//
+// The serialized format for values in the bucket is:
+//
+//   <block height><transaction>
+//
+//   Field           Type             Size
+//   block height    uint32           4 bytes
+//   transaction     Transaction      variable
+// -----------------------------------------------------------------------------
+
+// DBPutSnapshotTx uses an existing database transaction to store a
+// transaction imported from a chain snapshot or kept from a pruned block, along
+// with the height of the block it was packed in.
+func DBPutSnapshotTx(dbTx database.Tx, txn interfaces.Transaction,
+	height uint32) error {
+	bucket, err := dbTx.Metadata().CreateBucketIfNotExists(snapshotTxBucketName)
+	if err != nil {
+		return err
+	}
+
+	w := new(bytes.Buffer)
+	if err := common.WriteUint32(w, height); err != nil {
+		return err
+	}
+	if err := txn.Serialize(w); err != nil {
+		return err
+	}
+	txHash := txn.Hash()
+	return bucket.Put(txHash[:], w.Bytes())
+}
+
+// dbFetchSnapshotTx uses an existing database transaction to fetch a
+// transaction imported from a chain snapshot.  When there is no entry for
+// the provided hash, nil will be returned for the both the transaction and
+// the error.
+func dbFetchSnapshotTx(dbTx database.Tx, txHash *common.Uint256) (
+	interfaces.Transaction, uint32, error) {
+	bucket := dbTx.Metadata().Bucket(snapshotTxBucketName)
+	if bucket == nil {
+		return nil, 0, nil
+	}
+	serializedData := bucket.Get(txHash[:])
+	if len(serializedData) == 0 {
+		return nil, 0, nil
+	}
+
+	r := bytes.NewReader(serializedData)
+	height, err := common.ReadUint32(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	txn, err := functions.GetTransactionByBytes(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := txn.Deserialize(r); err != nil {
+		return nil, 0, err
+	}
+	return txn, height, nil
+}
+
+// DBForEachSnapshotTx uses an existing database transaction to walk through
+// the transactions in the snapshot bucket along with the heights of the
+// blocks they were packed in.
+func DBForEachSnapshotTx(dbTx database.Tx,
+	fn func(txHash common.Uint256, height uint32) error) error {
+	bucket := dbTx.Metadata().Bucket(snapshotTxBucketName)
+	if bucket == nil {
+		return nil
+	}
+	return bucket.ForEach(func(k, v []byte) error {
+		var txHash common.Uint256
+		copy(txHash[:], k)
+		height, err := common.ReadUint32(bytes.NewReader(v))
+		if err != nil {
+			return err
+		}
+		return fn(txHash, height)
+	})
+}
+
+// DBRemoveSnapshotTx uses an existing database transaction to remove a
+// transaction from the snapshot bucket.
+func DBRemoveSnapshotTx(dbTx database.Tx, txHash *common.Uint256) error {
+	bucket := dbTx.Metadata().Bucket(snapshotTxBucketName)
+	if bucket == nil {
+		return nil
+	}
+	return bucket.Delete(txHash[:])
+}
+
+// DBForEachUnspentIndexEntry uses an existing database transaction to walk
+// through the whole unspent index.
+func DBForEachUnspentIndexEntry(dbTx database.Tx,
+	fn func(txHash common.Uint256, indexes []uint16) error) error {
+	unspentIndex := dbTx.Metadata().Bucket(UnspentIndexKey)
+	if unspentIndex == nil {
+		return nil
+	}
+	return unspentIndex.ForEach(func(k, v []byte) error {
+		var txHash common.Uint256
+		copy(txHash[:], k)
+		indexes, err := getUint16Array(v)
+		if err != nil {
+			return err
+		}
+		return fn(txHash, indexes)
+	})
+}
+
+// DBInitSnapshotIndexes uses an existing database transaction to create the
+// indexes enabled by the given configuration and set their tips to the
+// block of an imported chain snapshot, so the index manager will not try to
+// catch them up from the genesis block.
+func DBInitSnapshotIndexes(dbTx database.Tx, params *config.Configuration,
+	hash *common.Uint256, height uint32) error {
+	meta := dbTx.Metadata()
+	if _, err := meta.CreateBucketIfNotExists(indexTipsBucketName); err != nil {
+		return err
+	}
+
+	m := NewManager(nil, params)
+	for _, indexer := range m.enabledIndexes {
+		if err := indexer.Create(dbTx); err != nil {
+			return err
+		}
+		err := dbPutIndexerTip(dbTx, indexer.Key(), hash, int32(height))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -255,7 +255,16 @@ func (idx *UnspentIndex) FetchTx(txID common.Uint256) (interfaces.Transaction, u
 		var blockHash *common.Uint256
 		txn, blockHash, err = dbFetchTx(dbTx, &txID)
 		if err != nil {
-			return err
+			// Transactions packed below the height of an imported
+			// chain snapshot, or in the pruned blocks, are only kept
+			// in the snapshot bucket.
+			snapshotTxn, snapshotHeight, snapshotErr :=
+				dbFetchSnapshotTx(dbTx, &txID)
+			if snapshotErr != nil || snapshotTxn == nil {
+				return err
+			}
+			txn, height = snapshotTxn, snapshotHeight
+			return nil
 		}
 		height, err = dbFetchHeightByHash(dbTx, blockHash)
 		return err
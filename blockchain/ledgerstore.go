@@ -72,6 +72,9 @@ type IFFLDBChainStore interface {
 	// Get block header from file DB.
 	GetHeader(hash Uint256) (*common.Header, error)
 
+	// Get block header with the aux pow from file DB.
+	GetFullHeader(hash Uint256) (*common.Header, error)
+
 	// If already exist in main chain(exist in file DB and exist block index),
 	// will return true.
 	BlockExists(hash *Uint256) (bool, uint32, error)
@@ -92,6 +95,17 @@ type IFFLDBChainStore interface {
 	// Get utxo by program hash.
 	GetUTXO(programHash *Uint168) ([]*common.UTXO, error)
 
+	// Get a page of the transaction history by program hash.
+	GetAddressHistory(programHash *Uint168, cursor []byte,
+		limit uint32) ([]*indexers.AddressHistoryEntry, []byte, error)
+
+	// Get the transactions punishing arbiters within the height range.
+	GetIllegalEvidences(nodePublicKey []byte, startHeight,
+		endHeight uint32) ([]*indexers.IllegalEvidenceEntry, error)
+
+	// Get the transactions changing the CR proposal in chain order.
+	GetCRProposalEvents(proposalHash Uint256) ([]*indexers.CRProposalEvent, error)
+
 	// IsTx3Exist use to find if tx3 exist in DB.
 	IsTx3Exist(txHash *Uint256) bool
 
@@ -11,8 +11,6 @@ import (
 	"sort"
 	"sync"
 	"time"
-
-	"github.com/elastos/Elastos.ELA/common/log"
 )
 
 const (
@@ -11,7 +11,7 @@ import (
 	"testing"
 	"time"
 
-	"github.com/elastos/Elastos.ELA/common/log"
+	elaLog "github.com/elastos/Elastos.ELA/common/log"
 	"github.com/elastos/Elastos.ELA/utils/test"
 )
 
@@ -21,7 +21,7 @@ func init() {
 
 // TestMedianTime tests the medianTime implementation.
 func TestMedianTime(t *testing.T) {
-	log.NewDefault(test.NodeLogPath, 5, 0, 0)
+	elaLog.NewDefault(test.NodeLogPath, 5, 0, 0)
 
 	tests := []struct {
 		in         []int64
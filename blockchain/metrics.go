@@ -0,0 +1,41 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package blockchain
+
+import (
+	"time"
+
+	"github.com/elastos/Elastos.ELA/utils/metrics"
+)
+
+// blockProcessTime is the time to process blocks by result, which is one of
+// "accepted", "orphan" and "rejected".
+var blockProcessTime = metrics.NewHistogram(
+	"ela_blockchain_block_process_seconds",
+	"Time to process a block.", metrics.DefaultBuckets, "result")
+
+func init() {
+	metrics.Register(blockProcessTime)
+}
+
+// registerMetrics registers the metrics collected from the chain.
+func (b *BlockChain) registerMetrics() {
+	metrics.Register(metrics.NewGaugeFunc("ela_blockchain_best_height",
+		"Height of the best chain.", func() float64 {
+			return float64(b.GetHeight())
+		}))
+}
+
+// observeBlockProcessTime records the time to process a block since start.
+func observeBlockProcessTime(start time.Time, isOrphan bool, err error) {
+	result := "accepted"
+	if err != nil {
+		result = "rejected"
+	} else if isOrphan {
+		result = "orphan"
+	}
+	blockProcessTime.ObserveDuration(start, result)
+}
@@ -0,0 +1,256 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package blockchain
+
+import (
+	"github.com/elastos/Elastos.ELA/blockchain/indexers"
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/database"
+)
+
+const (
+	// MaxRollback is the max number of blocks the chain state can be rolled
+	// back, which is bounded by the change history capacity of the DPoS
+	// and CR states.
+	MaxRollback = 720
+
+	// MinPruneDepth is the minimum number of recent blocks a pruned node
+	// keeps in the block files.
+	MinPruneDepth = 2 * MaxRollback
+
+	// pruneInterval is the number of blocks between two attempts to prune
+	// the block files.
+	pruneInterval = 144
+)
+
+// IsPruned returns whether the node prunes the block files.
+func (b *BlockChain) IsPruned() bool {
+	return b.chainParams.PruneDepth > 0
+}
+
+// HaveFullHistory returns whether the node keeps all blocks of the chain in the
+// block files.  The old blocks are removed by pruning, or are never downloaded
+// when the chain is imported from a snapshot, so the node must not advertise
+// itself as a full node.
+func (b *BlockChain) HaveFullHistory() bool {
+	return !b.IsPruned() && !b.IsBlockPruned(&b.GenesisHash)
+}
+
+// IsBlockPruned returns whether the block of the provided hash is known to be
+// valid, but has been removed from the block files by pruning.
+func (b *BlockChain) IsBlockPruned(hash *common.Uint256) bool {
+	node, ok := b.index.LookupNode(hash)
+	if !ok {
+		return false
+	}
+	status := b.index.NodeStatus(node)
+	return status.KnownValid() && !status.HaveData()
+}
+
+// pruneHeight returns the height at and below which the blocks are no longer
+// needed.  The blocks within the prune depth are kept, along with the blocks
+// needed to roll back the chain, and to replay the checkpoints restored from
+// the checkpoint files.
+func (b *BlockChain) pruneHeight() uint32 {
+	depth := b.chainParams.PruneDepth
+	if depth < MinPruneDepth {
+		depth = MinPruneDepth
+	}
+
+	bestHeight := b.GetHeight()
+	if bestHeight <= depth {
+		return 0
+	}
+	height := bestHeight - depth
+	if safeHeight := b.CkpManager.RestoredSafeHeight(); safeHeight < height {
+		height = safeHeight
+	}
+	return height
+}
+
+// pruneBlocks removes the block files which only house blocks at or below the
+// prune height.  The transactions of the removed blocks which still have
+// unspent outputs, or which are referenced by the kept blocks, are moved into
+// the snapshot transaction bucket so they can still be fetched.  The
+// transactions kept from the former pruned blocks are removed once the blocks
+// spending them are pruned too.
+func (b *BlockChain) pruneBlocks() error {
+	if b.CkpManager == nil {
+		return nil
+	}
+	height := b.pruneHeight()
+	if height == 0 {
+		return nil
+	}
+
+	prunable := func(hash common.Uint256) bool {
+		node, ok := b.index.LookupNode(&hash)
+		return !ok || node.Height <= height
+	}
+
+	var pruned []*BlockNode
+	err := b.db.GetFFLDB().Update(func(dbTx database.Tx) error {
+		hashes, err := dbTx.PruneBlocks(prunable)
+		if err != nil || len(hashes) == 0 {
+			return err
+		}
+
+		if err := b.dbUpdateReferencedTxs(dbTx, height); err != nil {
+			return err
+		}
+
+		for i := range hashes {
+			node, ok := b.index.LookupNode(&hashes[i])
+			if !ok {
+				continue
+			}
+			pruned = append(pruned, node)
+
+			// Blocks of side chains have nothing to keep.
+			if h, err := dbFetchHeightByHash(dbTx, node.Hash); err != nil ||
+				h != node.Height {
+				continue
+			}
+
+			block, err := dbFetchBlockByNode(dbTx, node)
+			if err != nil {
+				return err
+			}
+			for _, txn := range block.Transactions {
+				txHash := txn.Hash()
+				keep, err := dbNeedsTx(dbTx, &txHash, b.referencedTxs)
+				if err != nil {
+					return err
+				}
+				if keep {
+					err = indexers.DBPutSnapshotTx(dbTx, txn, node.Height)
+					if err != nil {
+						return err
+					}
+				}
+
+				// The transactions spent by the pruned block may have
+				// been kept from the former pruned blocks, and are no
+				// longer needed once fully spent.
+				for _, input := range txn.Inputs() {
+					prevHash := input.Previous.TxID
+					keep, err := dbNeedsTx(dbTx, &prevHash, b.referencedTxs)
+					if err != nil {
+						return err
+					}
+					if keep {
+						continue
+					}
+					err = indexers.DBRemoveSnapshotTx(dbTx, &prevHash)
+					if err != nil {
+						return err
+					}
+				}
+			}
+			if err := dbPutBlockPruned(dbTx, node); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	b.index.Lock()
+	for _, node := range pruned {
+		node.Status &^= statusDataStored
+	}
+	b.index.Unlock()
+
+	if len(pruned) > 0 {
+		log.Infof("Pruned %d blocks at and below height %d", len(pruned),
+			height)
+	}
+	return nil
+}
+
+// dbUpdateReferencedTxs uses an existing database transaction to update the
+// transactions referenced by the inputs of the main chain blocks above the
+// provided height.  Only the blocks connected since the last update are
+// scanned, and the references of the blocks at or below the height are
+// dropped.
+func (b *BlockChain) dbUpdateReferencedTxs(dbTx database.Tx,
+	height uint32) error {
+	if b.referencedTxs == nil {
+		b.referencedTxs = make(map[common.Uint256]uint32)
+	}
+
+	from := b.referencedHeight + 1
+	if from <= height {
+		from = height + 1
+	}
+	bestHeight := b.GetHeight()
+	for h := from; h <= bestHeight; h++ {
+		node := b.GetBlockNode(h)
+		if node == nil || !b.index.NodeStatus(node).HaveData() {
+			continue
+		}
+		block, err := dbFetchBlockByNode(dbTx, node)
+		if err != nil {
+			return err
+		}
+		for _, txn := range block.Transactions {
+			for _, input := range txn.Inputs() {
+				b.referencedTxs[input.Previous.TxID] = h
+			}
+		}
+	}
+	b.referencedHeight = bestHeight
+
+	for txHash, h := range b.referencedTxs {
+		if h <= height {
+			delete(b.referencedTxs, txHash)
+		}
+	}
+	return nil
+}
+
+// resetReferencedTxs drops the referenced transactions collected for pruning
+// when the block of the provided height has been scanned, and is disconnected
+// from the main chain, so the kept blocks are scanned again on the next run.
+func (b *BlockChain) resetReferencedTxs(height uint32) {
+	if height <= b.referencedHeight {
+		b.referencedTxs = nil
+		b.referencedHeight = 0
+	}
+}
+
+// dbNeedsTx uses an existing database transaction to determine whether the
+// transaction of the provided hash still has unspent outputs, or is referenced
+// by the kept blocks.
+func dbNeedsTx(dbTx database.Tx, txHash *common.Uint256,
+	referenced map[common.Uint256]uint32) (bool, error) {
+	if _, ok := referenced[*txHash]; ok {
+		return true, nil
+	}
+	indexes, err := indexers.DBFetchUnspentIndexEntry(dbTx, txHash)
+	if err != nil {
+		return false, err
+	}
+	return len(indexes) > 0, nil
+}
+
+// dbPutBlockPruned uses an existing database transaction to clear the data
+// stored flag of the provided node in the block index.
+func dbPutBlockPruned(dbTx database.Tx, node *BlockNode) error {
+	blockIndexBucket := dbTx.Metadata().Bucket(blockIndexBucketName)
+	key := blockIndexKey(node.Hash, node.Height)
+	blockRow := blockIndexBucket.Get(key)
+	if len(blockRow) == 0 {
+		return nil
+	}
+
+	row := make([]byte, len(blockRow))
+	copy(row, blockRow)
+	row[len(row)-1] = byte(blockStatus(row[len(row)-1]) &^ statusDataStored)
+	return blockIndexBucket.Put(key, row)
+}
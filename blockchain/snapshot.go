@@ -0,0 +1,677 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package blockchain
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/elastos/Elastos.ELA/blockchain/indexers"
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/common/config"
+	"github.com/elastos/Elastos.ELA/core/checkpoint"
+	"github.com/elastos/Elastos.ELA/core/types"
+	common2 "github.com/elastos/Elastos.ELA/core/types/common"
+	"github.com/elastos/Elastos.ELA/core/types/functions"
+	"github.com/elastos/Elastos.ELA/database"
+)
+
+const (
+	// snapshotMagic identifies a chain snapshot file.
+	snapshotMagic = uint32(0x50414e53)
+
+	// snapshotVersion is the version of the chain snapshot format.
+	snapshotVersion = uint32(1)
+
+	// snapshotBatchSize is the number of entries written to the database
+	// in a single database transaction while importing a chain snapshot.
+	snapshotBatchSize = 10000
+)
+
+var (
+	// ErrChainStateExists indicates a chain snapshot can not be imported
+	// because the database already contains chain data.
+	ErrChainStateExists = errors.New("chain data already exists")
+
+	// snapshotImportKeyName is the name of the DB key used to store the hash
+	// of the chain snapshot being imported, it is removed when the chain
+	// state is stored at the end of the import.
+	snapshotImportKeyName = []byte("snapshotimport")
+)
+
+// -----------------------------------------------------------------------------
+// A chain snapshot carries everything a fresh node needs to continue syncing
+// from the snapshot height: the headers of the main chain, the blocks needed
+// to replay the checkpoints up to the snapshot height, the transactions with
+// unspent outputs at the snapshot height and the checkpoint files.
+//
+// The serialized format is:
+//
+//   <magic><version><height><block hash>
+//   <header count><headers>
+//   <block count><blocks>
+//   <transaction count>[<height><transaction><unspent count><unspents>],...
+//   <checkpoint count><checkpoints>
+//   <snapshot hash>
+//
+// The snapshot hash is the sha256 of everything before it, and is the value
+// operators pin with the TrustedSnapshotHash configuration.
+// -----------------------------------------------------------------------------
+
+// ExportSnapshot writes a chain snapshot at the given height to w and returns
+// the snapshot hash.  The blocks from replayFrom to the snapshot height are
+// carried in full so the given checkpoint files can be replayed to the
+// snapshot height by the importing node.
+func (b *BlockChain) ExportSnapshot(w io.Writer, height, replayFrom uint32,
+	files []*checkpoint.SnapshotFile) (common.Uint256, error) {
+	bestHeight := b.GetHeight()
+	if height > bestHeight {
+		return common.EmptyHash, fmt.Errorf("snapshot height %d is higher "+
+			"than the best height %d", height, bestHeight)
+	}
+	if replayFrom > height {
+		return common.EmptyHash, fmt.Errorf("replay height %d is higher "+
+			"than the snapshot height %d", replayFrom, height)
+	}
+
+	hasher := sha256.New()
+	mw := io.MultiWriter(w, hasher)
+
+	hash, err := b.GetBlockHash(height)
+	if err != nil {
+		return common.EmptyHash, err
+	}
+	if err := common.WriteUint32(mw, snapshotMagic); err != nil {
+		return common.EmptyHash, err
+	}
+	if err := common.WriteUint32(mw, snapshotVersion); err != nil {
+		return common.EmptyHash, err
+	}
+	if err := common.WriteUint32(mw, height); err != nil {
+		return common.EmptyHash, err
+	}
+	if err := hash.Serialize(mw); err != nil {
+		return common.EmptyHash, err
+	}
+
+	log.Infof("Exporting block headers to height %d", height)
+	if err := common.WriteVarUint(mw, uint64(height)+1); err != nil {
+		return common.EmptyHash, err
+	}
+	for i := uint32(0); i <= height; i++ {
+		blockHash, err := b.GetBlockHash(i)
+		if err != nil {
+			return common.EmptyHash, err
+		}
+		header, err := b.db.GetFFLDB().GetHeader(blockHash)
+		if err != nil {
+			return common.EmptyHash, err
+		}
+		if err := header.SerializeNoAux(mw); err != nil {
+			return common.EmptyHash, err
+		}
+	}
+
+	log.Infof("Exporting blocks from height %d to %d", replayFrom, height)
+	if err := common.WriteVarUint(mw, uint64(height-replayFrom)+1); err != nil {
+		return common.EmptyHash, err
+	}
+	for i := replayFrom; i <= height; i++ {
+		block, err := b.getDposBlockByHeight(i)
+		if err != nil {
+			return common.EmptyHash, err
+		}
+		if err := block.Serialize(mw); err != nil {
+			return common.EmptyHash, err
+		}
+	}
+
+	log.Infof("Exporting unspent transactions at height %d", height)
+	unspents, err := b.snapshotUnspents(height, replayFrom)
+	if err != nil {
+		return common.EmptyHash, err
+	}
+	txHashes := make([]common.Uint256, 0, len(unspents))
+	for txHash := range unspents {
+		txHashes = append(txHashes, txHash)
+	}
+	sort.Slice(txHashes, func(i, j int) bool {
+		return bytes.Compare(txHashes[i][:], txHashes[j][:]) < 0
+	})
+	if err := common.WriteVarUint(mw, uint64(len(txHashes))); err != nil {
+		return common.EmptyHash, err
+	}
+	for _, txHash := range txHashes {
+		txn, txHeight, err := b.db.GetTransaction(txHash)
+		if err != nil {
+			return common.EmptyHash, err
+		}
+		if err := common.WriteUint32(mw, txHeight); err != nil {
+			return common.EmptyHash, err
+		}
+		if err := txn.Serialize(mw); err != nil {
+			return common.EmptyHash, err
+		}
+		indexes := unspents[txHash]
+		sort.Slice(indexes, func(i, j int) bool {
+			return indexes[i] < indexes[j]
+		})
+		if err := common.WriteVarUint(mw, uint64(len(indexes))); err != nil {
+			return common.EmptyHash, err
+		}
+		for _, index := range indexes {
+			if err := common.WriteUint16(mw, index); err != nil {
+				return common.EmptyHash, err
+			}
+		}
+	}
+
+	if err := common.WriteVarUint(mw, uint64(len(files))); err != nil {
+		return common.EmptyHash, err
+	}
+	for _, f := range files {
+		if err := f.Serialize(mw); err != nil {
+			return common.EmptyHash, err
+		}
+	}
+
+	var snapshotHash common.Uint256
+	copy(snapshotHash[:], hasher.Sum(nil))
+	if _, err := w.Write(snapshotHash[:]); err != nil {
+		return common.EmptyHash, err
+	}
+	return snapshotHash, nil
+}
+
+// snapshotUnspents returns the unspent outputs of every transaction at the
+// snapshot height, along with the transactions referenced by the blocks from
+// replayFrom to the snapshot height which have no unspent outputs left.
+func (b *BlockChain) snapshotUnspents(height, replayFrom uint32) (
+	map[common.Uint256][]uint16, error) {
+	unspents := make(map[common.Uint256][]uint16)
+	err := b.db.GetFFLDB().View(func(dbTx database.Tx) error {
+		return indexers.DBForEachUnspentIndexEntry(dbTx,
+			func(txHash common.Uint256, indexes []uint16) error {
+				unspents[txHash] = indexes
+				return nil
+			})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Rewind the unspent set from the best block to the snapshot height the
+	// same way the unspent index disconnects blocks.
+	for i := b.GetHeight(); i > height; i-- {
+		block, err := b.getDposBlockByHeight(i)
+		if err != nil {
+			return nil, err
+		}
+		for _, txn := range block.Transactions {
+			if txn.TxType() == common2.RegisterAsset || txn.IsCoinBaseTx() {
+				continue
+			}
+			for _, input := range txn.Inputs() {
+				referTxnHash := input.Previous.TxID
+				unspents[referTxnHash] = append(unspents[referTxnHash],
+					input.Previous.Index)
+			}
+		}
+		for _, txn := range block.Transactions {
+			if txn.TxType() == common2.RegisterAsset {
+				continue
+			}
+			delete(unspents, txn.Hash())
+		}
+	}
+
+	// Transactions referenced by the replayed blocks are needed to replay
+	// the checkpoints even if they have been spent.
+	for i := replayFrom; i <= height; i++ {
+		block, err := b.getDposBlockByHeight(i)
+		if err != nil {
+			return nil, err
+		}
+		for _, txn := range block.Transactions {
+			if txn.IsCoinBaseTx() {
+				continue
+			}
+			for _, input := range txn.Inputs() {
+				if _, ok := unspents[input.Previous.TxID]; !ok {
+					unspents[input.Previous.TxID] = nil
+				}
+			}
+		}
+	}
+
+	return unspents, nil
+}
+
+func (b *BlockChain) getDposBlockByHeight(height uint32) (*types.DposBlock, error) {
+	hash, err := b.GetBlockHash(height)
+	if err != nil {
+		return nil, err
+	}
+	return b.db.GetFFLDB().GetBlock(hash)
+}
+
+// VerifySnapshotFile checks the snapshot hash at the end of a chain snapshot
+// file and returns it.
+func VerifySnapshotFile(path string) (common.Uint256, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return common.EmptyHash, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return common.EmptyHash, err
+	}
+	if info.Size() < HashSize {
+		return common.EmptyHash, errors.New("invalid snapshot file")
+	}
+
+	hasher := sha256.New()
+	if _, err := io.CopyN(hasher, file, info.Size()-HashSize); err != nil {
+		return common.EmptyHash, err
+	}
+	var snapshotHash, hash common.Uint256
+	if _, err := io.ReadFull(file, snapshotHash[:]); err != nil {
+		return common.EmptyHash, err
+	}
+	copy(hash[:], hasher.Sum(nil))
+	if !hash.IsEqual(snapshotHash) {
+		return common.EmptyHash, errors.New("snapshot file is corrupted")
+	}
+	return snapshotHash, nil
+}
+
+// ImportSnapshot bootstraps an empty chain database and the checkpoints under
+// checkpointPath from a chain snapshot file, and returns the snapshot height.
+// The snapshot hash must match the TrustedSnapshotHash configuration.
+//
+// The blocks below the snapshot height are kept as headers only, so the node
+// can not serve them to other peers nor reorganize below the snapshot height.
+func ImportSnapshot(db IChainStore, params *config.Configuration, path,
+	checkpointPath string) (uint32, error) {
+	fflDB := db.GetFFLDB()
+	var initialized bool
+	err := fflDB.View(func(dbTx database.Tx) error {
+		initialized = dbTx.Metadata().Get(chainStateKeyName) != nil
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if initialized {
+		return 0, ErrChainStateExists
+	}
+	if params.EnableAddressHistory {
+		return 0, errors.New("address history index can not be built " +
+			"from a chain snapshot")
+	}
+	if params.EnableIllegalEvidenceIndex {
+		return 0, errors.New("illegal evidence index can not be built " +
+			"from a chain snapshot")
+	}
+	if params.EnableCRProposalTimelineIndex {
+		return 0, errors.New("CR proposal timeline index can not be built " +
+			"from a chain snapshot")
+	}
+
+	if params.TrustedSnapshotHash == "" {
+		return 0, errors.New("no trusted snapshot hash configured")
+	}
+	trustedHash, err := common.Uint256FromHexString(params.TrustedSnapshotHash)
+	if err != nil {
+		return 0, fmt.Errorf("invalid trusted snapshot hash: %s", err)
+	}
+	snapshotHash, err := VerifySnapshotFile(path)
+	if err != nil {
+		return 0, err
+	}
+	if !snapshotHash.IsEqual(*trustedHash) {
+		return 0, fmt.Errorf("snapshot hash %s does not match the trusted "+
+			"snapshot hash %s", snapshotHash, trustedHash)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	r := bufio.NewReader(io.LimitReader(file, info.Size()-HashSize))
+
+	magic, err := common.ReadUint32(r)
+	if err != nil {
+		return 0, err
+	}
+	if magic != snapshotMagic {
+		return 0, errors.New("invalid snapshot file")
+	}
+	version, err := common.ReadUint32(r)
+	if err != nil {
+		return 0, err
+	}
+	if version != snapshotVersion {
+		return 0, fmt.Errorf("unsupported snapshot version %d", version)
+	}
+	height, err := common.ReadUint32(r)
+	if err != nil {
+		return 0, err
+	}
+	var bestHash common.Uint256
+	if err := bestHash.Deserialize(r); err != nil {
+		return 0, err
+	}
+	// Resume the import interrupted before, the entries are written again
+	// with the same values.
+	var resume bool
+	err = fflDB.View(func(dbTx database.Tx) error {
+		var err error
+		resume, err = dbCheckSnapshotImport(dbTx, &snapshotHash)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if resume {
+		log.Infof("Resuming the import of chain snapshot %s at height %d",
+			snapshotHash, height)
+	} else {
+		log.Infof("Importing chain snapshot %s at height %d", snapshotHash,
+			height)
+		err = fflDB.Update(func(dbTx database.Tx) error {
+			meta := dbTx.Metadata()
+			for _, name := range [][]byte{blockIndexBucketName,
+				hashIndexBucketName, heightIndexBucketName,
+				spendJournalBucketName, utxoSetBucketName} {
+				if _, err := meta.CreateBucket(name); err != nil {
+					return err
+				}
+			}
+			err := meta.Put(snapshotImportKeyName, snapshotHash[:])
+			if err != nil {
+				return err
+			}
+			return indexers.DBInitSnapshotIndexes(dbTx, params, &bestHash,
+				height)
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	workSum, err := importSnapshotHeaders(fflDB, r, params, height, &bestHash)
+	if err != nil {
+		return 0, err
+	}
+	if err := importSnapshotBlocks(fflDB, r); err != nil {
+		return 0, err
+	}
+	if err := importSnapshotTxs(fflDB, r); err != nil {
+		return 0, err
+	}
+
+	count, err := common.ReadVarUint(r, 0)
+	if err != nil {
+		return 0, err
+	}
+	files := make([]*checkpoint.SnapshotFile, 0, count)
+	for i := uint64(0); i < count; i++ {
+		var f checkpoint.SnapshotFile
+		if err := f.Deserialize(r); err != nil {
+			return 0, err
+		}
+		files = append(files, &f)
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		return 0, errors.New("unexpected data at the end of snapshot file")
+	}
+	if err := checkpoint.SaveSnapshotFiles(checkpointPath, files); err != nil {
+		return 0, err
+	}
+
+	// Store the chain state at last, the database will not be treated as
+	// initialized until the whole snapshot has been imported.
+	err = fflDB.Update(func(dbTx database.Tx) error {
+		if err := dbTx.Metadata().Delete(snapshotImportKeyName); err != nil {
+			return err
+		}
+		return dbPutBestState(dbTx, &BestState{
+			Hash:   bestHash,
+			Height: height,
+		}, workSum)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	log.Infof("Chain snapshot imported at height %d", height)
+	return height, nil
+}
+
+// dbCheckSnapshotImport uses an existing database transaction to check
+// whether the database has chain data without the chain state, which is left
+// by an interrupted import.  It returns true if the interrupted import is of
+// the same snapshot so it can be resumed, and an error if the database has
+// other chain data.
+func dbCheckSnapshotImport(dbTx database.Tx, snapshotHash *common.Uint256) (
+	bool, error) {
+	meta := dbTx.Metadata()
+	if meta.Bucket(blockIndexBucketName) == nil {
+		return false, nil
+	}
+	if !bytes.Equal(meta.Get(snapshotImportKeyName), snapshotHash[:]) {
+		return false, errors.New("database has incomplete chain data of " +
+			"another snapshot or node, remove the data directory and " +
+			"import again")
+	}
+	return true, nil
+}
+
+func importSnapshotHeaders(fflDB IFFLDBChainStore, r io.Reader,
+	params *config.Configuration, height uint32,
+	bestHash *common.Uint256) (*big.Int, error) {
+	count, err := common.ReadVarUint(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	if count != uint64(height)+1 {
+		return nil, fmt.Errorf("expect %d headers in snapshot, got %d",
+			uint64(height)+1, count)
+	}
+
+	workSum := big.NewInt(0)
+	var prevHash common.Uint256
+	for start := uint64(0); start < count; start += snapshotBatchSize {
+		err := fflDB.Update(func(dbTx database.Tx) error {
+			for i := start; i < start+snapshotBatchSize && i < count; i++ {
+				var header common2.Header
+				if err := header.DeserializeNoAux(r); err != nil {
+					return err
+				}
+				hash := header.Hash()
+				if uint64(header.Height) != i {
+					return fmt.Errorf("unexpected header height %d, "+
+						"expect %d", header.Height, i)
+				}
+				if i == 0 && !hash.IsEqual(params.GenesisBlock.Hash()) {
+					return errors.New("snapshot does not start with " +
+						"the genesis block")
+				}
+				if i > 0 && !header.Previous.IsEqual(prevHash) {
+					return fmt.Errorf("header at height %d does not "+
+						"connect to the previous header", i)
+				}
+				if err := DBStoreBlockNode(dbTx, &header,
+					statusValid); err != nil {
+					return err
+				}
+				if err := dbPutBlockIndex(dbTx, &hash,
+					header.Height); err != nil {
+					return err
+				}
+				workSum.Add(workSum, CalcWork(header.Bits))
+				prevHash = hash
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !prevHash.IsEqual(*bestHash) {
+		return nil, errors.New("snapshot headers do not end with the " +
+			"snapshot block")
+	}
+
+	return workSum, nil
+}
+
+func importSnapshotBlocks(fflDB IFFLDBChainStore, r io.Reader) error {
+	count, err := common.ReadVarUint(r, 0)
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < count; i++ {
+		var block types.DposBlock
+		if err := block.Deserialize(r); err != nil {
+			return err
+		}
+		err := fflDB.Update(func(dbTx database.Tx) error {
+			hash := block.Hash()
+			height, err := dbFetchHeightByHash(dbTx, &hash)
+			if err != nil {
+				return err
+			}
+			if height != block.Height {
+				return fmt.Errorf("unexpected block %s at height %d",
+					hash, block.Height)
+			}
+			if err := dbStoreBlock(dbTx, &block); err != nil {
+				return err
+			}
+			return DBStoreBlockNode(dbTx, &block.Header,
+				statusDataStored|statusValid)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func importSnapshotTxs(fflDB IFFLDBChainStore, r io.Reader) error {
+	count, err := common.ReadVarUint(r, 0)
+	if err != nil {
+		return err
+	}
+
+	utxos := make(map[common.Uint168]map[uint32][]*common2.UTXO)
+	for start := uint64(0); start < count; start += snapshotBatchSize {
+		err := fflDB.Update(func(dbTx database.Tx) error {
+			for i := start; i < start+snapshotBatchSize && i < count; i++ {
+				height, err := common.ReadUint32(r)
+				if err != nil {
+					return err
+				}
+				txn, err := functions.GetTransactionByBytes(r)
+				if err != nil {
+					return err
+				}
+				if err := txn.Deserialize(r); err != nil {
+					return err
+				}
+				indexCount, err := common.ReadVarUint(r, 0)
+				if err != nil {
+					return err
+				}
+				indexes := make([]uint16, 0, indexCount)
+				for j := uint64(0); j < indexCount; j++ {
+					index, err := common.ReadUint16(r)
+					if err != nil {
+						return err
+					}
+					if int(index) >= len(txn.Outputs()) {
+						return fmt.Errorf("invalid unspent output %d "+
+							"of transaction %s", index, txn.Hash())
+					}
+					indexes = append(indexes, index)
+				}
+
+				if err := indexers.DBPutSnapshotTx(dbTx, txn,
+					height); err != nil {
+					return err
+				}
+				if len(indexes) == 0 {
+					continue
+				}
+				txHash := txn.Hash()
+				if err := indexers.DBPutUnspentIndexEntry(dbTx, &txHash,
+					indexes); err != nil {
+					return err
+				}
+				for _, index := range indexes {
+					output := txn.Outputs()[index]
+					if output.Value == 0 {
+						continue
+					}
+					if _, ok := utxos[output.ProgramHash]; !ok {
+						utxos[output.ProgramHash] =
+							make(map[uint32][]*common2.UTXO)
+					}
+					utxos[output.ProgramHash][height] = append(
+						utxos[output.ProgramHash][height], &common2.UTXO{
+							TxID:  txHash,
+							Index: index,
+							Value: output.Value,
+						})
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	programHashes := make([]common.Uint168, 0, len(utxos))
+	for programHash := range utxos {
+		programHashes = append(programHashes, programHash)
+	}
+	for start := 0; start < len(programHashes); start += snapshotBatchSize {
+		err := fflDB.Update(func(dbTx database.Tx) error {
+			for i := start; i < start+snapshotBatchSize &&
+				i < len(programHashes); i++ {
+				programHash := programHashes[i]
+				for height, entries := range utxos[programHash] {
+					if err := indexers.DBPutUtxoIndexEntry(dbTx,
+						&programHash, height, entries); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
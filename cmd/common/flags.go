@@ -34,6 +34,32 @@ var (
 		Name:  "pubkeys, pks",
 		Usage: "public key list of multi signature address, separate public keys with comma `,`",
 	}
+	AccountKDFFlag = cli.StringFlag{
+		Name:  "kdf",
+		Usage: "key derivation function of the backup file, scrypt or argon2id",
+		Value: account.KDFScrypt,
+	}
+	AccountHDFlag = cli.BoolFlag{
+		Name:  "hd",
+		Usage: "create a HD wallet with a new BIP39 mnemonic",
+	}
+	AccountMnemonicFlag = cli.StringFlag{
+		Name:  "mnemonic",
+		Usage: "create a HD wallet from the BIP39 `<words>`, separate words with space",
+	}
+	AccountPassphraseFlag = cli.StringFlag{
+		Name:  "passphrase",
+		Usage: "optional BIP39 passphrase of the mnemonic",
+	}
+	AccountIndexFlag = cli.UintFlag{
+		Name:  "index",
+		Usage: "derive from the address `<index>` of HD wallet instead",
+	}
+	AccountGapLimitFlag = cli.UintFlag{
+		Name:  "gaplimit",
+		Usage: "stop scanning after `<number>` consecutive unused addresses",
+		Value: account.DefaultGapLimit,
+	}
 
 	// Transaction flags
 	TransactionFromFlag = cli.StringFlag{
@@ -73,6 +99,32 @@ var (
 		Name:  "txlock",
 		Usage: "the `<lock height>` to specify when the transaction can be packaged",
 	}
+	TransactionStrategyFlag = cli.StringFlag{
+		Name:  "strategy",
+		Usage: "the coin selection `<strategy>` of inputs: bnb, largest, smallest or random",
+	}
+	TransactionConfirmationsFlag = cli.IntFlag{
+		Name:  "confirmations",
+		Usage: "estimate the fee to be packed in `<number>` blocks",
+		Value: 6,
+	}
+	TransactionMaxInputsFlag = cli.IntFlag{
+		Name:  "maxinputs",
+		Usage: "the max `<number>` of inputs to be merged in one transaction",
+		Value: 1000,
+	}
+	TransactionThresholdFlag = cli.StringFlag{
+		Name:  "threshold",
+		Usage: "merge only the utxos of which amount is less than `<amount>`",
+	}
+	SignerFlag = cli.StringFlag{
+		Name:  "signer",
+		Usage: "the `<address>` of signer daemon, unix:///path/to/signer.sock",
+	}
+	SignerTokenFlag = cli.StringFlag{
+		Name:  "signertoken",
+		Usage: "the `<token>` to request the signer daemon",
+	}
 	TransactionHexFlag = cli.StringFlag{
 		Name:  "hex",
 		Usage: "the transaction content in hex string format to be sign or send",
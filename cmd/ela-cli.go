@@ -15,6 +15,8 @@ import (
 	"github.com/elastos/Elastos.ELA/cmd/mine"
 	"github.com/elastos/Elastos.ELA/cmd/rollback"
 	"github.com/elastos/Elastos.ELA/cmd/script"
+	"github.com/elastos/Elastos.ELA/cmd/signer"
+	"github.com/elastos/Elastos.ELA/cmd/snapshot"
 	"github.com/elastos/Elastos.ELA/cmd/wallet"
 	"github.com/elastos/Elastos.ELA/common/config"
 	transaction2 "github.com/elastos/Elastos.ELA/core/transaction"
@@ -57,6 +59,8 @@ func main() {
 		*mine.NewCommand(),
 		*script.NewCommand(),
 		*rollback.NewCommand(),
+		*snapshot.NewCommand(),
+		*signer.NewCommand(),
 	}
 
 	//sort.Sort(cli.CommandsByName(app.Commands))
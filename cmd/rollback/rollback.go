@@ -65,7 +65,7 @@ func rollbackAction(c *cli.Context) error {
 		return nil
 	}
 
-	log.NewDefault("logs/node", 0, 0, 0)
+	blockchain.UseLogger(log.NewDefault("logs/node", 0, 0, 0))
 	chainStore, err := blockchain.NewChainStore(dataDir, config)
 	if err != nil {
 		fmt.Println("create chain store failed, ", err)
@@ -170,8 +170,8 @@ func initLedger(L *lua.LState) int {
 	logLevel := uint8(L.ToInt(1))
 	ckpManager := checkpoint.NewManager(chainParams)
 
-	log.NewDefault(test.NodeLogPath, logLevel, 0, 0)
-	dlog.Init("elastos", logLevel, 0, 0)
+	blockchain.UseLogger(log.NewDefault(test.NodeLogPath, logLevel, 0, 0))
+	dlog.Init("elastos", log.Config{Level: logLevel})
 
 	ledger := blockchain.Ledger{}
 	chainStore, err := blockchain.NewChainStore(test.DataPath, chainParams)
@@ -301,8 +301,10 @@ func dposManagerSignProposal(L *lua.LState) int {
 	m := checkDposManager(L, 1)
 	p := checkProposal(L, 2)
 
+	// managers of scripts sign by the local accounts, which do not check
+	// the round of the block header.
 	result := false
-	if sign, err := m.Account.SignProposal(p); err == nil {
+	if sign, err := m.Account.SignProposal(p, nil); err == nil {
 		p.Sign = sign
 		result = true
 	}
@@ -315,8 +317,10 @@ func dposManagerSignVote(L *lua.LState) int {
 	m := checkDposManager(L, 1)
 	v := checkVote(L, 2)
 
+	// managers of scripts sign by the local accounts, which do not check
+	// the proposal and the round of the block header.
 	result := false
-	if sign, err := m.Account.SignVote(v); err == nil {
+	if sign, err := m.Account.SignVote(v, nil, nil); err == nil {
 		v.Sign = sign
 		result = true
 	}
@@ -0,0 +1,181 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package signer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/account/signer"
+	cmdcom "github.com/elastos/Elastos.ELA/cmd/common"
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/crypto"
+
+	"github.com/urfave/cli"
+)
+
+var (
+	listenFlag = cli.StringFlag{
+		Name:  "listen",
+		Usage: "the Unix socket `<address>` to listen on, unix:///path/to/signer.sock",
+		Value: "signer.sock",
+	}
+	allowTxFlag = cli.BoolFlag{
+		Name: "allowtx",
+		Usage: "sign transactions of any type, otherwise only the RevertToDPOS " +
+			"and InactiveArbitrators transactions of arbiters are signed",
+	}
+	stateFlag = cli.StringFlag{
+		Name:  "state",
+		Usage: "the `<file>` saving the proposals and votes signed last to refuse double signs",
+		Value: "signer.state",
+	}
+)
+
+func NewCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "signer",
+		Usage: "External signer daemon holding private keys",
+		Description: "With ela-cli signer command, you could keep the private keys " +
+			"such as the arbiter key out of the node, and the node requests the " +
+			"signer daemon to sign proposals, votes and transactions.",
+		ArgsUsage: "[args]",
+		Subcommands: []cli.Command{
+			{
+				Name:  "serve",
+				Usage: "Serve the private keys of a keystore file",
+				Description: "The Unix socket is accessible by the owner only. TCP is not " +
+					"supported, forward the Unix socket by SSH to reach the daemon from " +
+					"another host. Proposals and votes conflicting with the ones signed " +
+					"before are refused, and transactions other than the ones of " +
+					"arbiters are signed with --allowtx only.",
+				Flags: []cli.Flag{
+					listenFlag,
+					allowTxFlag,
+					stateFlag,
+					cmdcom.SignerTokenFlag,
+					cmdcom.AccountWalletFlag,
+					cmdcom.AccountPasswordFlag,
+				},
+				Action: serveAction,
+			},
+			{
+				Name:  "list",
+				Usage: "List the public keys held by a signer daemon",
+				Flags: []cli.Flag{
+					cmdcom.SignerFlag,
+					cmdcom.SignerTokenFlag,
+				},
+				Action: listAction,
+			},
+		},
+	}
+}
+
+func serveAction(c *cli.Context) error {
+	password, err := cmdcom.GetFlagPassword(c)
+	if err != nil {
+		return err
+	}
+	backend, err := signer.NewFileBackend(c.String("wallet"), password)
+	if err != nil {
+		return err
+	}
+	server, err := signer.NewServer(&auditBackend{Backend: backend},
+		c.String("signertoken"), signer.Policy{
+			AllowTx:   c.Bool("allowtx"),
+			StatePath: c.String("state"),
+		})
+	if err != nil {
+		return err
+	}
+	listener, err := signer.Listen(c.String("listen"))
+	if err != nil {
+		return err
+	}
+
+	for _, pk := range backend.PublicKeys() {
+		fmt.Println("Serving public key", encodePublicKey(pk))
+	}
+	fmt.Println("Listening on", listener.Addr().String())
+
+	interrupt := make(chan os.Signal, 1)
+	closed := make(chan struct{})
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupt
+		close(closed)
+		server.Close()
+	}()
+
+	err = server.Serve(listener)
+	select {
+	case <-closed:
+		return nil
+	default:
+		return err
+	}
+}
+
+func listAction(c *cli.Context) error {
+	address := c.String("signer")
+	if address == "" {
+		return errors.New("use --signer to specify the address of signer daemon")
+	}
+	client, err := signer.Dial(address, c.String("signertoken"))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	publicKeys, err := client.PublicKeys()
+	if err != nil {
+		return err
+	}
+	for i, pk := range publicKeys {
+		fmt.Printf("%5d %s\n", i, encodePublicKey(pk))
+	}
+	return nil
+}
+
+// auditBackend prints every request to the backend.
+type auditBackend struct {
+	signer.Backend
+}
+
+func (b *auditBackend) Sign(publicKey *crypto.PublicKey,
+	data []byte) ([]byte, error) {
+	signature, err := b.Backend.Sign(publicKey, data)
+	b.audit("sign", publicKey, data, err)
+	return signature, err
+}
+
+func (b *auditBackend) Decrypt(publicKey *crypto.PublicKey,
+	cipher []byte) ([]byte, error) {
+	plain, err := b.Backend.Decrypt(publicKey, cipher)
+	b.audit("decrypt", publicKey, cipher, err)
+	return plain, err
+}
+
+func (b *auditBackend) audit(method string, publicKey *crypto.PublicKey,
+	data []byte, err error) {
+	result := "ok"
+	if err != nil {
+		result = err.Error()
+	}
+	hash := common.Sha256D(data)
+	fmt.Println(time.Now().Format("2006-01-02 15:04:05"), method,
+		encodePublicKey(publicKey), common.BytesToHexString(hash[:]), result)
+}
+
+func encodePublicKey(publicKey *crypto.PublicKey) string {
+	encoded, _ := publicKey.EncodePoint(true)
+	return common.BytesToHexString(encoded)
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package snapshot
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/elastos/Elastos.ELA/blockchain"
+	cmdcom "github.com/elastos/Elastos.ELA/cmd/common"
+	"github.com/elastos/Elastos.ELA/common/config/settings"
+	"github.com/elastos/Elastos.ELA/common/log"
+	"github.com/elastos/Elastos.ELA/core/checkpoint"
+	crstate "github.com/elastos/Elastos.ELA/cr/state"
+	"github.com/elastos/Elastos.ELA/dpos/state"
+
+	"github.com/urfave/cli"
+)
+
+const (
+	// dataPath indicates the path storing the chain data.
+	dataPath = "data"
+
+	// checkpointPath indicates the path storing the checkpoint data.
+	checkpointPath = "checkpoints"
+)
+
+var (
+	appSettings = settings.NewSettings()
+
+	fileFlag = cli.StringFlag{
+		Name:  "file",
+		Usage: "the chain snapshot `<file>`",
+	}
+)
+
+func NewCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "snapshot",
+		Usage: "Export or import chain snapshot",
+		Description: "With ela-cli snapshot command, you could export the chain " +
+			"state at a height into a snapshot file, and bootstrap a fresh node " +
+			"from it. The node must be stopped while running this command.",
+		ArgsUsage: "[args]",
+		Subcommands: []cli.Command{
+			{
+				Name:  "export",
+				Usage: "Export the chain state at a height into a snapshot file",
+				Flags: []cli.Flag{
+					cli.IntFlag{
+						Name:  "height",
+						Usage: "the snapshot height, default is the best height",
+						Value: -1,
+					},
+					fileFlag,
+					cmdcom.ConfigFileFlag,
+					cmdcom.DataDirFlag,
+				},
+				Action: exportAction,
+			},
+			{
+				Name:  "import",
+				Usage: "Bootstrap a fresh node from a trusted snapshot file",
+				Flags: []cli.Flag{
+					fileFlag,
+					cmdcom.ConfigFileFlag,
+					cmdcom.DataDirFlag,
+				},
+				Action: importAction,
+			},
+		},
+	}
+}
+
+func exportAction(c *cli.Context) error {
+	path := c.String("file")
+	if path == "" {
+		cli.ShowSubcommandHelp(c)
+		return nil
+	}
+	config := appSettings.SetupConfig(false, "", "")
+
+	blockchain.UseLogger(log.NewDefault("logs/node", 0, 0, 0))
+	dataDir := filepath.Join(c.String("datadir"), dataPath)
+	chainStore, err := blockchain.NewChainStore(dataDir, config)
+	if err != nil {
+		fmt.Println("create chain store failed, ", err)
+		return err
+	}
+	defer chainStore.Close()
+	ckpManager := checkpoint.NewManager(config)
+	chain, err := blockchain.New(chainStore, config, nil, nil, ckpManager)
+	if err != nil {
+		fmt.Println("create blockchain failed, ", err)
+		return err
+	}
+
+	height := chain.GetHeight()
+	if c.Int("height") >= 0 {
+		height = uint32(c.Int("height"))
+	}
+	files, err := checkpoint.LoadSnapshotFiles(
+		filepath.Join(dataDir, checkpointPath), height)
+	if err != nil {
+		fmt.Println("load checkpoints failed, ", err)
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+	hash, err := chain.ExportSnapshot(w, height, replayHeight(files), files)
+	if err != nil {
+		fmt.Println("export snapshot failed, ", err)
+		os.Remove(path)
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println("snapshot height:", height)
+	fmt.Println("snapshot hash:", hash)
+	return nil
+}
+
+// replayHeight returns the height from which the blocks are needed to
+// replay the checkpoints to the snapshot height.
+func replayHeight(files []*checkpoint.SnapshotFile) uint32 {
+	periods := make(map[string]uint32)
+	for _, c := range []checkpoint.ICheckPoint{
+		&state.CheckPoint{}, &crstate.Checkpoint{}} {
+		periods[c.Key()] = c.EffectivePeriod()
+	}
+
+	height := uint32(math.MaxUint32)
+	for _, f := range files {
+		period, ok := periods[f.Key]
+		if !ok {
+			continue
+		}
+		var start uint32
+		if f.Height > period {
+			start = f.Height - period
+		}
+		if start < height {
+			height = start
+		}
+	}
+	return height
+}
+
+func importAction(c *cli.Context) error {
+	path := c.String("file")
+	if path == "" {
+		cli.ShowSubcommandHelp(c)
+		return nil
+	}
+	config := appSettings.SetupConfig(false, "", "")
+
+	blockchain.UseLogger(log.NewDefault("logs/node", 0, 0, 0))
+	dataDir := filepath.Join(c.String("datadir"), dataPath)
+	chainStore, err := blockchain.NewChainStore(dataDir, config)
+	if err != nil {
+		fmt.Println("create chain store failed, ", err)
+		return err
+	}
+	defer chainStore.Close()
+
+	height, err := blockchain.ImportSnapshot(chainStore, config, path,
+		filepath.Join(dataDir, checkpointPath))
+	if err == blockchain.ErrChainStateExists {
+		return errors.New("chain data already exists in " + dataDir)
+	}
+	if err != nil {
+		fmt.Println("import snapshot failed, ", err)
+		return err
+	}
+
+	fmt.Println("snapshot imported at height", height)
+	return nil
+}
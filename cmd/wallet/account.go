@@ -13,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/elastos/Elastos.ELA/account"
+	"github.com/elastos/Elastos.ELA/account/hdwallet"
 	cmdcom "github.com/elastos/Elastos.ELA/cmd/common"
 	"github.com/elastos/Elastos.ELA/common"
 	"github.com/elastos/Elastos.ELA/core/contract"
@@ -31,6 +32,9 @@ var accountCommand = []cli.Command{
 		Flags: []cli.Flag{
 			cmdcom.AccountWalletFlag,
 			cmdcom.AccountPasswordFlag,
+			cmdcom.AccountHDFlag,
+			cmdcom.AccountMnemonicFlag,
+			cmdcom.AccountPassphraseFlag,
 		},
 		Action: createAccount,
 	},
@@ -77,6 +81,17 @@ var accountCommand = []cli.Command{
 		},
 		Action: addMultiSigAccount,
 	},
+	{
+		Category:  "Account",
+		Name:      "addschnorr",
+		Usage:     "Add a Schnorr aggregate account of standard accounts in wallet",
+		ArgsUsage: "<address> <address>...",
+		Flags: []cli.Flag{
+			cmdcom.AccountWalletFlag,
+			cmdcom.AccountPasswordFlag,
+		},
+		Action: addSchnorrAccount,
+	},
 	{
 		Category: "Account",
 		Name:     "delete",
@@ -114,23 +129,82 @@ var accountCommand = []cli.Command{
 		},
 		Action: exportAccount,
 	},
+	{
+		Category:  "Account",
+		Name:      "backup",
+		Usage:     "Backup all accounts into an encrypted file",
+		ArgsUsage: "<file>",
+		Flags: []cli.Flag{
+			cmdcom.AccountWalletFlag,
+			cmdcom.AccountPasswordFlag,
+			cmdcom.AccountKDFFlag,
+		},
+		Action: backupAccounts,
+	},
+	{
+		Category:  "Account",
+		Name:      "restore",
+		Usage:     "Restore accounts from an encrypted backup file",
+		ArgsUsage: "<file>",
+		Flags: []cli.Flag{
+			cmdcom.AccountWalletFlag,
+			cmdcom.AccountPasswordFlag,
+		},
+		Action: restoreAccounts,
+	},
+	{
+		Category: "Account",
+		Name:     "mnemonic",
+		Usage:    "Show the mnemonic of HD wallet",
+		Flags: []cli.Flag{
+			cmdcom.AccountWalletFlag,
+			cmdcom.AccountPasswordFlag,
+		},
+		Action: showMnemonic,
+	},
+	{
+		Category: "Account",
+		Name:     "scan",
+		Usage:    "Scan used addresses of HD wallet and add them to wallet",
+		Flags: []cli.Flag{
+			cmdcom.AccountWalletFlag,
+			cmdcom.AccountPasswordFlag,
+			cmdcom.AccountGapLimitFlag,
+		},
+		Action: scanAccounts,
+	},
 	{
 		Category: "Account",
 		Name:     "depositaddr",
 		Usage:    "Generate deposit address",
-		Action:   generateDepositAddress,
+		Flags: []cli.Flag{
+			cmdcom.AccountWalletFlag,
+			cmdcom.AccountPasswordFlag,
+			cmdcom.AccountIndexFlag,
+		},
+		Action: generateDepositAddress,
 	},
 	{
 		Category: "Account",
 		Name:     "stakeaddress",
 		Usage:    "Generate DPoS 2.0 stake address",
-		Action:   generateDposV2Address,
+		Flags: []cli.Flag{
+			cmdcom.AccountWalletFlag,
+			cmdcom.AccountPasswordFlag,
+			cmdcom.AccountIndexFlag,
+		},
+		Action: generateDposV2Address,
 	},
 	{
 		Category: "Account",
 		Name:     "didaddr",
 		Usage:    "Generate did address",
-		Action:   generateDIDAddress,
+		Flags: []cli.Flag{
+			cmdcom.AccountWalletFlag,
+			cmdcom.AccountPasswordFlag,
+			cmdcom.AccountIndexFlag,
+		},
+		Action: generateDIDAddress,
 	},
 	{
 		Category: "Account",
@@ -155,6 +229,20 @@ func createAccount(c *cli.Context) error {
 		p = []byte(password)
 	}
 
+	if c.Bool("hd") || c.String("mnemonic") != "" {
+		client, mnemonic, err := account.CreateHD(walletPath, p,
+			c.String("mnemonic"), c.String("passphrase"))
+		if err != nil {
+			return err
+		}
+		if c.String("mnemonic") == "" {
+			fmt.Println("Write down the mnemonic and keep it safe, it is the only way to recover the wallet:")
+			fmt.Println(mnemonic)
+			fmt.Println()
+		}
+		return ShowAccountInfo(client)
+	}
+
 	client, err := account.Create(walletPath, p)
 	if err != nil {
 		return err
@@ -248,6 +336,47 @@ func addMultiSigAccount(c *cli.Context) error {
 	return nil
 }
 
+func addSchnorrAccount(c *cli.Context) error {
+	walletPath := c.String("wallet")
+	password, err := cmdcom.GetFlagPassword(c)
+	if err != nil {
+		return err
+	}
+	if c.NArg() < 2 {
+		cmdcom.PrintErrorMsg("Missing argument. At least two addresses expected.")
+		cli.ShowCommandHelpAndExit(c, "addschnorr", 1)
+	}
+
+	client, err := account.Open(walletPath, password)
+	if err != nil {
+		return err
+	}
+	var accounts []*account.Account
+	for _, addr := range c.Args() {
+		programHash, err := common.Uint168FromAddress(addr)
+		if err != nil {
+			return err
+		}
+		acc := client.GetAccountByCodeHash(programHash.ToCodeHash())
+		if acc == nil || acc.PrivateKey == nil {
+			return errors.New("no private key of " + addr + " in wallet")
+		}
+		accounts = append(accounts, acc)
+	}
+
+	sa, err := client.CreateSchnorrAggregateAccount(accounts)
+	if err != nil {
+		return err
+	}
+	address, err := sa.ProgramHash.ToAddress()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(address)
+	return nil
+}
+
 func delAccount(c *cli.Context) error {
 	walletPath := c.String("wallet")
 	password, err := cmdcom.GetFlagPassword(c)
@@ -358,16 +487,125 @@ func exportAccount(c *cli.Context) error {
 	return nil
 }
 
-func generateDposV2Address(c *cli.Context) error {
+func backupAccounts(c *cli.Context) error {
+	walletPath := c.String("wallet")
+	if c.NArg() < 1 {
+		cmdcom.PrintErrorMsg("Missing argument. Backup file path expected.")
+		cli.ShowCommandHelpAndExit(c, "backup", 1)
+	}
+	backupPath := c.Args().First()
+	password, err := cmdcom.GetFlagPassword(c)
+	if err != nil {
+		return err
+	}
+
+	client, err := account.Open(walletPath, password)
+	if err != nil {
+		return err
+	}
+	if err := client.Backup(backupPath, password, c.String("kdf")); err != nil {
+		return err
+	}
+
+	fmt.Println("accounts are backed up to", backupPath)
+	return nil
+}
+
+func restoreAccounts(c *cli.Context) error {
+	walletPath := c.String("wallet")
 	if c.NArg() < 1 {
+		cmdcom.PrintErrorMsg("Missing argument. Backup file path expected.")
+		cli.ShowCommandHelpAndExit(c, "restore", 1)
+	}
+	backupPath := c.Args().First()
+	password, err := cmdcom.GetFlagPassword(c)
+	if err != nil {
+		return err
+	}
+
+	client, err := account.Restore(walletPath, backupPath, password)
+	if err != nil {
+		return err
+	}
+
+	return ShowAccountInfo(client)
+}
+
+func showMnemonic(c *cli.Context) error {
+	walletPath := c.String("wallet")
+	password, err := cmdcom.GetFlagPassword(c)
+	if err != nil {
+		return err
+	}
+
+	client, err := account.Open(walletPath, password)
+	if err != nil {
+		return err
+	}
+	mnemonic, err := client.Mnemonic()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(mnemonic)
+	return nil
+}
+
+func scanAccounts(c *cli.Context) error {
+	walletPath := c.String("wallet")
+	password, err := cmdcom.GetFlagPassword(c)
+	if err != nil {
+		return err
+	}
+
+	client, err := account.Open(walletPath, password)
+	if err != nil {
+		return err
+	}
+	found, err := client.ScanHDAccounts(uint32(c.Uint("gaplimit")),
+		getUsedAddresses)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("found", len(found), "new used addresses")
+	return ShowAccountInfo(client)
+}
+
+// getHDAccount returns the account of the address index given by the index
+// flag from the HD wallet, or nil if the flag is not set.
+func getHDAccount(c *cli.Context) (*account.Account, error) {
+	if !c.IsSet("index") {
+		return nil, nil
+	}
+	walletPath := c.String("wallet")
+	password, err := cmdcom.GetFlagPassword(c)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := account.Open(walletPath, password)
+	if err != nil {
+		return nil, err
+	}
+	return client.DeriveHDAccount(hdwallet.ExternalChain, uint32(c.Uint("index")))
+}
+
+func generateDposV2Address(c *cli.Context) error {
+	hdAccount, err := getHDAccount(c)
+	if err != nil {
+		return err
+	}
+	if hdAccount == nil && c.NArg() < 1 {
 		cmdcom.PrintErrorMsg("Missing argument. Standard address expected.")
 		cli.ShowCommandHelpAndExit(c, "depositaddress", 1)
 	}
 	addr := c.Args().First()
 
 	var programHash *common.Uint168
-	var err error
-	if addr == "" {
+	if hdAccount != nil {
+		programHash = &hdAccount.ProgramHash
+	} else if addr == "" {
 		mainAccount, err := account.GetWalletMainAccountData(account.KeystoreFileName)
 		if err != nil {
 			return err
@@ -407,8 +645,13 @@ func generateDepositAddress(c *cli.Context) error {
 	addr := c.Args().First()
 
 	var programHash *common.Uint168
-	var err error
-	if addr == "" {
+	hdAccount, err := getHDAccount(c)
+	if err != nil {
+		return err
+	}
+	if hdAccount != nil {
+		programHash = &hdAccount.ProgramHash
+	} else if addr == "" {
 		mainAccount, err := account.GetWalletMainAccountData(account.KeystoreFileName)
 		if err != nil {
 			return err
@@ -455,17 +698,22 @@ func getDID(code []byte) *common.Uint168 {
 }
 
 func generateDIDAddress(c *cli.Context) error {
-	if c.NArg() < 1 {
+	hdAccount, err := getHDAccount(c)
+	if err != nil {
+		return err
+	}
+	if hdAccount == nil && c.NArg() < 1 {
 		cmdcom.PrintErrorMsg("Missing argument. Standard public key expected.")
 		cli.ShowCommandHelpAndExit(c, "didaddress", 1)
 	}
 	publicKey := c.Args().First()
 
 	var programHash *common.Uint168
-	var err error
 	var code []byte
 
-	if publicKey == "" {
+	if hdAccount != nil {
+		code = hdAccount.RedeemScript
+	} else if publicKey == "" {
 		mainAccount, err := account.GetWalletMainAccountData(account.KeystoreFileName)
 		if err != nil {
 			return err
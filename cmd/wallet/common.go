@@ -109,11 +109,16 @@ func ShowAccountBalance(walletPath string) error {
 	return nil
 }
 
-func getUTXOsByAmount(address string, amount common.Fixed64) ([]servers.UTXOInfo, error) {
-	result, err := cmdcom.RPCCall("getutxosbyamount", http.Params{
+func getUTXOsByAmount(address string, amount common.Fixed64,
+	strategy string) ([]servers.UTXOInfo, error) {
+	params := http.Params{
 		"address": address,
 		"amount":  amount.String(),
-	})
+	}
+	if strategy != "" {
+		params["strategy"] = strategy
+	}
+	result, err := cmdcom.RPCCall("getutxosbyamount", params)
 	if err != nil {
 		return nil, err
 	}
@@ -434,3 +439,27 @@ func parseRenewalVotesContent(referKeys string, candidates string, votes string,
 	}
 	return votesInfo, nil
 }
+
+// getUsedAddresses returns the addresses having unspent outputs.
+func getUsedAddresses(addresses []string) (map[string]bool, error) {
+	result, err := cmdcom.RPCCall("listunspent", http.Params{
+		"addresses": addresses,
+	})
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	var UTXOs []servers.UTXOInfo
+	if err := json.Unmarshal(data, &UTXOs); err != nil {
+		return nil, err
+	}
+
+	used := make(map[string]bool)
+	for _, utxo := range UTXOs {
+		used[utxo.Address] = true
+	}
+	return used, nil
+}
@@ -0,0 +1,283 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package wallet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/elastos/Elastos.ELA/account"
+	cmdcom "github.com/elastos/Elastos.ELA/cmd/common"
+	"github.com/elastos/Elastos.ELA/common"
+	pg "github.com/elastos/Elastos.ELA/core/contract/program"
+	common2 "github.com/elastos/Elastos.ELA/core/types/common"
+	"github.com/elastos/Elastos.ELA/core/types/functions"
+	"github.com/elastos/Elastos.ELA/core/types/interfaces"
+	"github.com/elastos/Elastos.ELA/core/types/outputpayload"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/crypto"
+	"github.com/elastos/Elastos.ELA/elanet/pact"
+	"github.com/elastos/Elastos.ELA/servers"
+	"github.com/elastos/Elastos.ELA/utils/http"
+
+	"github.com/urfave/cli"
+)
+
+// coinbaseMaturity is the confirmations of coinbase outputs to be spent.
+const coinbaseMaturity = 101
+
+var consolidateCommand = cli.Command{
+	Category: "Transaction",
+	Name:     "consolidate",
+	Usage:    "Build a transaction to merge small utxos of an address",
+	Description: "the smallest utxos are merged into one output until --maxinputs or the " +
+		"transaction size limit is reached, and the fee is estimated by estimatesmartfee " +
+		"unless --fee is specified. Run it again to merge the rest utxos.",
+	Flags: []cli.Flag{
+		cmdcom.TransactionFromFlag,
+		cmdcom.TransactionToFlag,
+		cmdcom.TransactionFeeFlag,
+		cmdcom.TransactionConfirmationsFlag,
+		cmdcom.TransactionMaxInputsFlag,
+		cmdcom.TransactionThresholdFlag,
+		cmdcom.AccountWalletFlag,
+	},
+	Action: consolidate,
+}
+
+func consolidate(c *cli.Context) error {
+	if err := createConsolidateTransaction(c); err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	return nil
+}
+
+func createConsolidateTransaction(c *cli.Context) error {
+	sender, err := getSender(c.String("wallet"), c.String("from"))
+	if err != nil {
+		return err
+	}
+	redeemScript, err := common.HexStringToBytes(sender.RedeemScript)
+	if err != nil {
+		return err
+	}
+	to := c.String("to")
+	if to == "" {
+		to = sender.Address
+	}
+	recipient, err := common.Uint168FromAddress(to)
+	if err != nil {
+		return errors.New("invalid receiver address: " + to)
+	}
+	maxInputs := c.Int("maxinputs")
+	if maxInputs < 2 {
+		return errors.New("at least 2 inputs to merge")
+	}
+	var threshold *common.Fixed64
+	if thresholdStr := c.String("threshold"); thresholdStr != "" {
+		threshold, err = common.StringToFixed64(thresholdStr)
+		if err != nil {
+			return errors.New("invalid threshold")
+		}
+	}
+
+	// use the fee if specified, otherwise the fee rate of sela per KB
+	var fee *common.Fixed64
+	var feeRate int64
+	if feeStr := c.String("fee"); feeStr != "" {
+		fee, err = common.StringToFixed64(feeStr)
+		if err != nil {
+			return errors.New("invalid transaction fee")
+		}
+	} else {
+		feeRate, err = estimateFeeRate(c.Int("confirmations"))
+		if err != nil {
+			return err
+		}
+	}
+
+	utxos, err := getConsolidateUTXOs(sender.Address, threshold)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(utxos, func(i, j int) bool {
+		return utxos[i].value < utxos[j].value
+	})
+
+	// the size of transaction without inputs, with signatures as many as
+	// required to estimate the size of signed transaction
+	output := &common2.Output{
+		AssetID:     *account.SystemAssetID,
+		ProgramHash: *recipient,
+		OutputLock:  0,
+		Type:        common2.OTNone,
+		Payload:     &outputpayload.DefaultOutput{},
+	}
+	program, err := newPlaceholderProgram(redeemScript)
+	if err != nil {
+		return err
+	}
+	baseSize := newConsolidateTransaction(nil, output, program).GetSize()
+	buf := new(bytes.Buffer)
+	if err := new(common2.Input).Serialize(buf); err != nil {
+		return err
+	}
+	inputSize := buf.Len()
+	estimateSize := func(n int) int {
+		// the var uint of inputs count takes at most 5 bytes
+		return baseSize + 4 + n*inputSize
+	}
+	estimateFee := func(size int) common.Fixed64 {
+		return common.Fixed64(feeRate * int64(size) / 1000)
+	}
+
+	var inputs []*common2.Input
+	var total common.Fixed64
+	var dust int
+	for _, u := range utxos {
+		if len(inputs) >= maxInputs ||
+			estimateSize(len(inputs)+1) > int(pact.MaxBlockContextSize) {
+			break
+		}
+		// skip the utxos which cost more fee than their amount to spend
+		if fee == nil && u.value <= estimateFee(inputSize) {
+			dust++
+			continue
+		}
+		input, err := newInput(u.info)
+		if err != nil {
+			return err
+		}
+		inputs = append(inputs, input)
+		total += u.value
+	}
+	if len(inputs) < 2 {
+		return errors.New("not enough utxos to merge")
+	}
+
+	if fee == nil {
+		estimated := estimateFee(estimateSize(len(inputs)))
+		fee = &estimated
+	}
+	if total <= *fee {
+		return errors.New("amount of utxos is not enough to pay the fee")
+	}
+	output.Value = total - *fee
+	program.Parameter = nil
+	txn := newConsolidateTransaction(inputs, output, program)
+
+	fmt.Println("Merged:  ", len(inputs), "of", len(utxos), "utxos")
+	if dust > 0 {
+		fmt.Println("Skipped: ", dust, "utxos of which amount is less than fee to spend")
+	}
+	fmt.Println("Amount:  ", output.Value.String())
+	fmt.Println("Fee:     ", fee.String())
+	return OutputTx(0, 1, txn)
+}
+
+type consolidateUTXO struct {
+	info  servers.UTXOInfo
+	value common.Fixed64
+}
+
+// getConsolidateUTXOs returns the spendable utxos of address, without vote
+// outputs, locked outputs or immature coinbase outputs.
+func getConsolidateUTXOs(address string,
+	threshold *common.Fixed64) ([]consolidateUTXO, error) {
+	result, err := cmdcom.RPCCall("listunspent", http.Params{
+		"addresses": []string{address},
+		"utxotype":  "normal",
+	})
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	var infos []servers.UTXOInfo
+	if err := json.Unmarshal(data, &infos); err != nil {
+		return nil, err
+	}
+
+	utxos := make([]consolidateUTXO, 0, len(infos))
+	for _, info := range infos {
+		if common2.TxType(info.TxType) == common2.CoinBase &&
+			info.Confirmations < coinbaseMaturity {
+			continue
+		}
+		if info.OutputLock > 0 {
+			continue
+		}
+		value, err := common.StringToFixed64(info.Amount)
+		if err != nil {
+			return nil, err
+		}
+		if threshold != nil && *value >= *threshold {
+			continue
+		}
+		utxos = append(utxos, consolidateUTXO{info: info, value: *value})
+	}
+	return utxos, nil
+}
+
+// estimateFeeRate returns the fee rate of sela per KB to be packed in the
+// given number of blocks.
+func estimateFeeRate(confirmations int) (int64, error) {
+	result, err := cmdcom.RPCCall("estimatesmartfee", http.Params{
+		"confirmations": confirmations,
+	})
+	if err != nil {
+		return 0, err
+	}
+	feeRate, ok := result.(float64)
+	if !ok {
+		return 0, errors.New("invalid fee rate")
+	}
+	return int64(feeRate), nil
+}
+
+// newPlaceholderProgram returns the program of redeem script with empty
+// signatures as many as required.
+func newPlaceholderProgram(redeemScript []byte) (*pg.Program, error) {
+	m := 1
+	if len(redeemScript) != crypto.PublicKeyScriptLength {
+		n, err := crypto.GetM(redeemScript)
+		if err != nil {
+			return nil, err
+		}
+		m = int(n)
+	}
+	return &pg.Program{
+		Code:      redeemScript,
+		Parameter: make([]byte, m*crypto.SignatureScriptLength),
+	}, nil
+}
+
+func newConsolidateTransaction(inputs []*common2.Input, output *common2.Output,
+	program *pg.Program) interfaces.Transaction {
+	txAttr := common2.NewAttribute(common2.Nonce,
+		[]byte(strconv.FormatInt(rand.Int63(), 10)))
+
+	return functions.CreateTransaction(
+		common2.TxVersion09,
+		common2.TransferAsset,
+		0,
+		&payload.TransferAsset{},
+		[]*common2.Attribute{&txAttr},
+		inputs,
+		[]*common2.Output{output},
+		0,
+		[]*pg.Program{program},
+	)
+}
@@ -0,0 +1,287 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/elastos/Elastos.ELA/account"
+	"github.com/elastos/Elastos.ELA/account/psbt"
+	cmdcom "github.com/elastos/Elastos.ELA/cmd/common"
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/contract"
+	"github.com/elastos/Elastos.ELA/crypto"
+	"github.com/elastos/Elastos.ELA/utils/http"
+
+	"github.com/urfave/cli"
+)
+
+var psbtCommand = cli.Command{
+	Category: "Transaction",
+	Name:     "psbt",
+	Usage:    "Partially signed transaction for multi-signature coordination",
+	Subcommands: []cli.Command{
+		{
+			Name:        "create",
+			Usage:       "Create a partially signed transaction from a raw transaction",
+			Description: "use --file or --hex to specify the transaction file path or content",
+			Flags: []cli.Flag{
+				cmdcom.TransactionHexFlag,
+				cmdcom.TransactionFileFlag,
+				cmdcom.AccountWalletFlag,
+			},
+			Action: createPSBT,
+		},
+		{
+			Name:        "sign",
+			Usage:       "Sign a partially signed transaction offline",
+			Description: "use --file or --hex to specify the partially signed transaction file path or content",
+			Flags: []cli.Flag{
+				cmdcom.TransactionHexFlag,
+				cmdcom.TransactionFileFlag,
+				cmdcom.AccountWalletFlag,
+				cmdcom.AccountPasswordFlag,
+			},
+			Action: signPSBT,
+		},
+		{
+			Name:      "combine",
+			Usage:     "Combine the signatures of partially signed transactions",
+			ArgsUsage: "<file> <file>...",
+			Action:    combinePSBT,
+		},
+		{
+			Name:        "finalize",
+			Usage:       "Finalize a fully signed transaction to be sent",
+			Description: "use --file or --hex to specify the partially signed transaction file path or content",
+			Flags: []cli.Flag{
+				cmdcom.TransactionHexFlag,
+				cmdcom.TransactionFileFlag,
+			},
+			Action: finalizePSBT,
+		},
+		{
+			Name:        "show",
+			Usage:       "Show the signing status of a partially signed transaction",
+			Description: "use --file or --hex to specify the partially signed transaction file path or content",
+			Flags: []cli.Flag{
+				cmdcom.TransactionHexFlag,
+				cmdcom.TransactionFileFlag,
+			},
+			Action: showPSBT,
+		},
+	},
+}
+
+func getPSBT(c *cli.Context) (*psbt.Packet, error) {
+	content, err := getTransactionHex(c)
+	if err != nil {
+		return nil, err
+	}
+	return psbt.Decode(content)
+}
+
+func createPSBT(c *cli.Context) error {
+	if c.NumFlags() == 0 {
+		cli.ShowSubcommandHelp(c)
+		return nil
+	}
+	txHex, err := getTransactionHex(c)
+	if err != nil {
+		return err
+	}
+
+	// the redeem scripts of multi-signature accounts are known by wallet
+	codes := make([]string, 0)
+	if storeAccounts, err := account.GetWalletAccountData(c.String("wallet")); err == nil {
+		for _, a := range storeAccounts {
+			codes = append(codes, a.RedeemScript)
+		}
+	}
+
+	result, err := cmdcom.RPCCall("createpsbt", http.Params{
+		"data":  txHex,
+		"codes": codes,
+	})
+	if err != nil {
+		return err
+	}
+	packet, err := psbt.Decode(result.(string))
+	if err != nil {
+		return err
+	}
+
+	return OutputPSBT(packet)
+}
+
+func signPSBT(c *cli.Context) error {
+	if c.NumFlags() == 0 {
+		cli.ShowSubcommandHelp(c)
+		return nil
+	}
+	packet, err := getPSBT(c)
+	if err != nil {
+		return err
+	}
+	walletPath := c.String("wallet")
+	password, err := cmdcom.GetFlagPassword(c)
+	if err != nil {
+		return err
+	}
+
+	client, err := account.Open(walletPath, password)
+	if err != nil {
+		return err
+	}
+	signed, err := packet.Sign(client.GetAccountByCodeHash)
+	if err != nil {
+		return err
+	}
+	if signed == 0 {
+		return errors.New("no signature added by accounts in wallet")
+	}
+
+	fmt.Println(signed, "signatures added")
+	return OutputPSBT(packet)
+}
+
+func combinePSBT(c *cli.Context) error {
+	if c.NArg() < 2 {
+		cmdcom.PrintErrorMsg("Missing argument. At least two partially signed transaction files expected.")
+		cli.ShowCommandHelpAndExit(c, "combine", 1)
+	}
+
+	packets := make([]*psbt.Packet, 0, c.NArg())
+	for _, path := range c.Args() {
+		content, err := cmdcom.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		packet, err := psbt.Decode(content)
+		if err != nil {
+			return errors.New(path + ": " + err.Error())
+		}
+		packets = append(packets, packet)
+	}
+
+	combined, err := psbt.Combine(packets...)
+	if err != nil {
+		return err
+	}
+	return OutputPSBT(combined)
+}
+
+func finalizePSBT(c *cli.Context) error {
+	if c.NumFlags() == 0 {
+		cli.ShowSubcommandHelp(c)
+		return nil
+	}
+	packet, err := getPSBT(c)
+	if err != nil {
+		return err
+	}
+
+	txn, err := packet.Finalize()
+	if err != nil {
+		return err
+	}
+	return OutputTx(1, 1, txn)
+}
+
+func showPSBT(c *cli.Context) error {
+	if c.NumFlags() == 0 {
+		cli.ShowSubcommandHelp(c)
+		return nil
+	}
+	packet, err := getPSBT(c)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("TxID:    ", common.ToReversedString(packet.Tx.Hash()))
+	fmt.Println("Fee:     ", packet.Fee().String())
+	fmt.Println("Complete:", packet.IsComplete())
+	fmt.Println()
+
+	fmt.Printf("%-34s %-8s %-66s\n", "ADDRESS", "SIGNED", "SIGNER")
+	fmt.Println(strings.Repeat("-", 34), strings.Repeat("-", 8), strings.Repeat("-", 66))
+	for _, program := range packet.Programs {
+		have, need, err := program.Status()
+		if err != nil {
+			return err
+		}
+		prefix := contract.PrefixStandard
+		if len(program.Code) != crypto.PublicKeyScriptLength {
+			prefix = contract.PrefixMultiSig
+		}
+		address, err := common.ToProgramHash(byte(prefix), program.Code).ToAddress()
+		if err != nil {
+			return err
+		}
+		status := fmt.Sprint(have, "/", need)
+		if len(program.Signatures) == 0 {
+			fmt.Printf("%-34s %-8s %-66s\n", address, status, "")
+		}
+		for i, s := range program.Signatures {
+			if i > 0 {
+				address, status = "", ""
+			}
+			fmt.Printf("%-34s %-8s %-66s\n", address, status,
+				common.BytesToHexString(s.PublicKey))
+		}
+		fmt.Println(strings.Repeat("-", 34), strings.Repeat("-", 8), strings.Repeat("-", 66))
+	}
+	return nil
+}
+
+// OutputPSBT prints the partially signed transaction and writes it into a
+// file named by the signing status.
+func OutputPSBT(packet *psbt.Packet) error {
+	content, err := packet.Encode()
+	if err != nil {
+		return err
+	}
+	if len(content) > maxPrintLen {
+		fmt.Println("Hex: ", content[:maxPrintLen], "... ...")
+	} else {
+		fmt.Println("Hex: ", content)
+	}
+
+	var haveSign, needSign int
+	for _, program := range packet.Programs {
+		have, need, err := program.Status()
+		if err != nil {
+			return err
+		}
+		if have > need {
+			have = need
+		}
+		haveSign += have
+		needSign += need
+	}
+	fileName := "to_be_signed"
+	if packet.IsComplete() {
+		fileName = "ready_to_finalize"
+	} else if haveSign > 0 {
+		fileName = fmt.Sprint(fileName, "_", haveSign, "_of_", needSign)
+	}
+	fileName = fileName + ".psbt"
+
+	file, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write([]byte(content)); err != nil {
+		return err
+	}
+
+	fmt.Println("File: ", fileName)
+	return nil
+}
@@ -13,11 +13,13 @@ import (
 	"strings"
 
 	"github.com/elastos/Elastos.ELA/account"
+	"github.com/elastos/Elastos.ELA/account/signer"
 	cmdcom "github.com/elastos/Elastos.ELA/cmd/common"
 	"github.com/elastos/Elastos.ELA/common"
 	"github.com/elastos/Elastos.ELA/core/contract/program"
 	common2 "github.com/elastos/Elastos.ELA/core/types/common"
 	"github.com/elastos/Elastos.ELA/core/types/functions"
+	"github.com/elastos/Elastos.ELA/core/types/interfaces"
 	"github.com/elastos/Elastos.ELA/crypto"
 	"github.com/elastos/Elastos.ELA/utils/http"
 
@@ -38,21 +40,25 @@ var txCommand = []cli.Command{
 			cmdcom.TransactionFeeFlag,
 			cmdcom.TransactionOutputLockFlag,
 			cmdcom.TransactionTxLockFlag,
+			cmdcom.TransactionStrategyFlag,
 			cmdcom.AccountWalletFlag,
 		},
 		Subcommands: buildTxCommand,
 		Action:      buildTx,
 	},
 	{
-		Category:    "Transaction",
-		Name:        "signtx",
-		Usage:       "Sign a transaction",
-		Description: "use --file or --hex to specify the transaction file path or content",
+		Category: "Transaction",
+		Name:     "signtx",
+		Usage:    "Sign a transaction",
+		Description: "use --file or --hex to specify the transaction file path or content, " +
+			"and --signer to sign by the keys held by signer daemon instead of wallet",
 		Flags: []cli.Flag{
 			cmdcom.TransactionHexFlag,
 			cmdcom.TransactionFileFlag,
 			cmdcom.AccountWalletFlag,
 			cmdcom.AccountPasswordFlag,
+			cmdcom.SignerFlag,
+			cmdcom.SignerTokenFlag,
 		},
 		Action: signTx,
 	},
@@ -100,6 +106,8 @@ var txCommand = []cli.Command{
 		},
 		Action: showTx,
 	},
+	psbtCommand,
+	consolidateCommand,
 }
 
 var buildTxCommand = []cli.Command{
@@ -145,17 +153,6 @@ func signTx(c *cli.Context) error {
 		cli.ShowSubcommandHelp(c)
 		return nil
 	}
-	walletPath := c.String("wallet")
-	password, err := cmdcom.GetFlagPassword(c)
-	if err != nil {
-		return err
-	}
-
-	client, err := account.Open(walletPath, password)
-	if err != nil {
-		return err
-	}
-
 	txHex, err := getTransactionHex(c)
 	if err != nil {
 		return err
@@ -187,9 +184,27 @@ func signTx(c *cli.Context) error {
 		return errors.New("transaction was fully signed, no need more sign")
 	}
 
-	txnSigned, err := client.Sign(txn)
-	if err != nil {
-		return err
+	var txnSigned interfaces.Transaction
+	if address := c.String("signer"); address != "" {
+		txnSigned, err = signTxBySigner(address, c.String("signertoken"), txn)
+		if err != nil {
+			return err
+		}
+	} else {
+		walletPath := c.String("wallet")
+		password, err := cmdcom.GetFlagPassword(c)
+		if err != nil {
+			return err
+		}
+
+		client, err := account.Open(walletPath, password)
+		if err != nil {
+			return err
+		}
+		txnSigned, err = client.Sign(txn)
+		if err != nil {
+			return err
+		}
 	}
 
 	haveSign, needSign, _ = crypto.GetSignStatus(txn.Programs()[0].Code, txn.Programs()[0].Parameter)
@@ -200,6 +215,29 @@ func signTx(c *cli.Context) error {
 	return nil
 }
 
+// signTxBySigner signs the transaction by the keys held by signer daemon.
+func signTxBySigner(address, token string,
+	txn interfaces.Transaction) (interfaces.Transaction, error) {
+	client, err := signer.Dial(address, token)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	signers, err := client.Signers()
+	if err != nil {
+		return nil, err
+	}
+	signed, err := signer.SignTransaction(txn, signers)
+	if err != nil {
+		return nil, err
+	}
+	if signed == 0 {
+		return nil, errors.New("no available key held by signer daemon")
+	}
+	return txn, nil
+}
+
 func signDigest(c *cli.Context) error {
 	var name string
 
@@ -15,6 +15,7 @@ import (
 	"strings"
 
 	"github.com/elastos/Elastos.ELA/account"
+	"github.com/elastos/Elastos.ELA/account/coinselect"
 	cmdcom "github.com/elastos/Elastos.ELA/cmd/common"
 	"github.com/elastos/Elastos.ELA/common"
 	"github.com/elastos/Elastos.ELA/core/contract"
@@ -24,6 +25,7 @@ import (
 	"github.com/elastos/Elastos.ELA/core/types/interfaces"
 	"github.com/elastos/Elastos.ELA/core/types/outputpayload"
 	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/servers"
 
 	"github.com/urfave/cli"
 )
@@ -101,9 +103,17 @@ func CreateTransaction(c *cli.Context) error {
 		}
 	}
 
+	strategy := c.String("strategy")
+	if strategy != "" {
+		if _, err := coinselect.GetStrategy(strategy); err != nil {
+			return err
+		}
+	}
+
 	var txn interfaces.Transaction
-	txn, err = createTransaction(walletPath, from, *fee, uint32(outputLock),
-		uint32(txLock), common2.TransferAsset, 0, &payload.TransferAsset{}, outputs...)
+	txn, err = createTransactionWithStrategy(walletPath, from, strategy, *fee,
+		uint32(outputLock), uint32(txLock), common2.TransferAsset, 0,
+		&payload.TransferAsset{}, outputs...)
 	if err != nil {
 		return errors.New("create transaction failed: " + err.Error())
 	}
@@ -185,7 +195,15 @@ func getDSender(walletPath string, from string) (*account.AccountData, error) {
 
 func createInputs(fromAddr string, totalAmount common.Fixed64) ([]*common2.Input,
 	[]*common2.Output, error) {
-	UTXOs, err := getUTXOsByAmount(fromAddr, totalAmount)
+	return createInputsWithStrategy(fromAddr, "", totalAmount)
+}
+
+// createInputsWithStrategy creates the inputs of the UTXOs selected by the
+// coin selection strategy, the first UTXOs covering the amount are used if
+// strategy is empty.
+func createInputsWithStrategy(fromAddr string, strategy string,
+	totalAmount common.Fixed64) ([]*common2.Input, []*common2.Output, error) {
+	UTXOs, err := getUTXOsByAmount(fromAddr, totalAmount, strategy)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -193,18 +211,9 @@ func createInputs(fromAddr string, totalAmount common.Fixed64) ([]*common2.Input
 	var txInputs []*common2.Input
 	var changeOutputs []*common2.Output
 	for _, utxo := range UTXOs {
-		txIDReverse, _ := hex.DecodeString(utxo.TxID)
-		txID, _ := common.Uint256FromBytes(common.BytesReverse(txIDReverse))
-		sequence := math.MaxUint32
-		if utxo.OutputLock > 0 {
-			sequence = math.MaxUint32 - 1
-		}
-		input := &common2.Input{
-			Previous: common2.OutPoint{
-				TxID:  *txID,
-				Index: utxo.VOut,
-			},
-			Sequence: uint32(sequence),
+		input, err := newInput(utxo)
+		if err != nil {
+			return nil, nil, err
 		}
 		txInputs = append(txInputs, input)
 		amount, err := common.StringToFixed64(utxo.Amount)
@@ -241,6 +250,28 @@ func createInputs(fromAddr string, totalAmount common.Fixed64) ([]*common2.Input
 	return txInputs, changeOutputs, nil
 }
 
+func newInput(utxo servers.UTXOInfo) (*common2.Input, error) {
+	txIDReverse, err := hex.DecodeString(utxo.TxID)
+	if err != nil {
+		return nil, err
+	}
+	txID, err := common.Uint256FromBytes(common.BytesReverse(txIDReverse))
+	if err != nil {
+		return nil, err
+	}
+	sequence := math.MaxUint32
+	if utxo.OutputLock > 0 {
+		sequence = math.MaxUint32 - 1
+	}
+	return &common2.Input{
+		Previous: common2.OutPoint{
+			TxID:  *txID,
+			Index: utxo.VOut,
+		},
+		Sequence: uint32(sequence),
+	}, nil
+}
+
 func createNormalOutputs(outputs []*OutputInfo, fee common.Fixed64, lockedUntil uint32) ([]*common2.Output, common.Fixed64, error) {
 	var totalAmount = common.Fixed64(0) // The total amount will be spend
 	var txOutputs []*common2.Output     // The outputs in transaction
@@ -316,6 +347,14 @@ func createVoteOutputs(output *OutputInfo, candidateList []string) ([]*common2.O
 func createTransaction(walletPath string, from string, fee common.Fixed64, outputLock uint32, txLock uint32,
 	txType common2.TxType, payloadVersion byte, payload interfaces.Payload,
 	outputs ...*OutputInfo) (interfaces.Transaction, error) {
+	return createTransactionWithStrategy(walletPath, from, "", fee, outputLock,
+		txLock, txType, payloadVersion, payload, outputs...)
+}
+
+func createTransactionWithStrategy(walletPath string, from string, strategy string,
+	fee common.Fixed64, outputLock uint32, txLock uint32, txType common2.TxType,
+	payloadVersion byte, payload interfaces.Payload,
+	outputs ...*OutputInfo) (interfaces.Transaction, error) {
 
 	// get sender in wallet by from address
 	var sender *account.AccountData
@@ -339,7 +378,8 @@ func createTransaction(walletPath string, from string, fee common.Fixed64, outpu
 	}
 
 	// create inputs
-	txInputs, changeOutputs, err := createInputs(sender.Address, totalAmount)
+	txInputs, changeOutputs, err := createInputsWithStrategy(sender.Address,
+		strategy, totalAmount)
 	if err != nil {
 		return nil, err
 	}
@@ -266,6 +266,7 @@ func GetDefaultParams() *Configuration {
 		CheckRewardHeight:               436812,
 		VoteStatisticsHeight:            512881,
 		EnableUtxoDB:                    true,
+		PersistMempool:                  true,
 		EnableCORS:                      false,
 		WalletPath:                      "keystore.dat",
 		RPCServiceLevel:                 ConfigurationPermitted.String(),
@@ -597,6 +598,8 @@ type Configuration struct {
 	HttpJsonPort  int    `screw:"--rpcport" usage:"port for the http json rpc port server"`
 	ProfilePort   uint32 `screw:"--profileport" usage:"port for the http profile port rpc server"`
 	ProfileHost   string `screw:"--profilehost" usage:"port for the http profile rpc host server"`
+	MetricsPort   uint32 `screw:"--metricsport" usage:"port for the http prometheus metrics server, 0 to disable"`
+	MetricsHost   string `screw:"--metricshost" usage:"host for the http prometheus metrics server"`
 	DisableDNS    bool   `screw:"--disabledns" usage:"disable DNS for node"`
 	EnableRPC     bool   `screw:"--enablerpc" usage:"enable RPC for node"`
 	MaxLogsSize   int64  `json:"MaxLogsSize"`
@@ -618,6 +621,14 @@ type Configuration struct {
 	DisableTxFilters bool
 	// PrintLevel defines the level to print log.
 	PrintLevel uint32 `screw:"--printlevel" usage:"level to print log"`
+	// LogLevels defines the levels to print log of subsystems, which override
+	// PrintLevel, such as {"dpos": 0, "elanet": 2}.
+	LogLevels map[string]uint32 `json:"LogLevels"`
+	// LogFormat defines the format of log messages, "text" or "json".
+	LogFormat string `screw:"--logformat" usage:"format of log messages, text or json"`
+	// LogRotateHours defines the interval in hours to create a new log file,
+	// new log files are created by size only if it is 0.
+	LogRotateHours uint32 `json:"LogRotateHours"`
 	// NodePort defines the default peer-to-peer port for the network.
 	NodePort uint16 `screw:"--nodeport" usage:"default peer-to-peer node port for the network"`
 	// Magic defines the magic number of the peer-to-peer network.
@@ -628,6 +639,25 @@ type Configuration struct {
 	PermanentPeers []string `json:"PermanentPeers"`
 	// The interface/port to listen for connections.
 	ListenAddrs []string `json:"ListenAddrs"`
+	// ExternalIPs defines the addresses to advertise to peers, such as the
+	// onion host of the node, the addresses listened on are advertised if
+	// empty.
+	ExternalIPs []string `screw:"--externalip" usage:"addresses to advertise to peers, such as the onion host of the node"`
+	// Proxy defines the address of a SOCKS5 proxy to make outbound
+	// connections through, such as "127.0.0.1:9050" of a Tor client.
+	Proxy string `screw:"--proxy" usage:"connect to peers through the SOCKS5 proxy, such as 127.0.0.1:9050"`
+	// ProxyUser and ProxyPass define the credentials of the proxies.
+	ProxyUser string `json:"ProxyUser"`
+	ProxyPass string `json:"ProxyPass"`
+	// OnionProxy defines the address of a SOCKS5 proxy to connect to onion
+	// addresses through, Proxy is used if it is empty.
+	OnionProxy string `screw:"--onion" usage:"connect to onion addresses through the SOCKS5 proxy"`
+	// TorIsolation defines whether to authenticate to the proxies with
+	// random credentials, so Tor uses a separate circuit for each peer.
+	TorIsolation bool `screw:"--torisolation" usage:"use a separate Tor circuit for each peer connection"`
+	// OnlyNet defines the networks to make automatic outbound connections
+	// to, which are ipv4, ipv6 or onion, all networks are allowed if empty.
+	OnlyNet []string `screw:"--onlynet" usage:"only connect to peers of the networks, ipv4, ipv6 or onion"`
 	// MinCrossChainTxFee defines the min fee of cross chain transaction
 	MinCrossChainTxFee common.Fixed64 `json:"MinCrossChainTxFee"`
 	// MinTransactionFee defines the minimum fee of a transaction.
@@ -660,6 +690,27 @@ type Configuration struct {
 	VoteStatisticsHeight uint32 `screw:"--votestatisticsheight" usage:"defines the height to fix vote statistics error"`
 	// EnableUtxoDB indicate whether to enable utxo database.
 	EnableUtxoDB bool `json:"EnableUtxoDB"`
+	// EnableAddressHistory indicate whether to index the transaction history
+	// of every address.
+	EnableAddressHistory bool `screw:"--addresshistory" usage:"enable the address history index"`
+	// EnableIllegalEvidenceIndex indicate whether to index the transactions
+	// punishing arbiters.
+	EnableIllegalEvidenceIndex bool `screw:"--illegalevidenceindex" usage:"enable the illegal evidence index"`
+	// EnableCRProposalTimelineIndex indicate whether to index the
+	// transactions changing CR proposals.
+	EnableCRProposalTimelineIndex bool `screw:"--crproposaltimelineindex" usage:"enable the CR proposal timeline index"`
+	// PersistMempool indicate whether to save the transaction pool on
+	// shutdown and load it on startup.
+	PersistMempool bool `json:"PersistMempool"`
+	// ImportSnapshot defines the chain snapshot file to bootstrap a fresh
+	// node from.
+	ImportSnapshot string `screw:"--importsnapshot" usage:"bootstrap a fresh node from the chain snapshot file"`
+	// TrustedSnapshotHash defines the hash of the chain snapshot which is
+	// allowed to be imported.
+	TrustedSnapshotHash string `screw:"--snapshothash" json:"TrustedSnapshotHash" usage:"hash of the trusted chain snapshot"`
+	// PruneDepth defines how many recent blocks to keep in the block files,
+	// zero means pruning is disabled.
+	PruneDepth uint32 `screw:"--prune" json:"PruneDepth" usage:"keep only the blocks within the depth in block files, 0 to disable pruning"`
 	// Enable cors for http server.
 	EnableCORS bool `json:"EnableCORS"`
 	// WalletPath defines the wallet path used by DPoS arbiters and CR members.
@@ -750,9 +801,18 @@ type CheckPointConfiguration struct {
 // DPoSConfiguration defines the DPoS consensus parameters.
 type DPoSConfiguration struct {
 	EnableArbiter bool `screw:"--arbiter" usage:"indicates where or not to enable DPoS arbiter switch"`
+	// Signer defines the Unix socket address of the external signer daemon
+	// holding the arbiter key, the keystore is not opened if it is set.
+	Signer string `screw:"--dpossigner" usage:"defines the address of the external signer daemon holding the arbiter key"`
+	// SignerToken defines the token to request the external signer daemon.
+	SignerToken string `screw:"--dpossignertoken" usage:"defines the token to request the external signer daemon"`
+	// SignerPublicKey defines the arbiter public key held by the external
+	// signer daemon, the first one held by the daemon is used if not set.
+	SignerPublicKey string `screw:"--dpossignerpublickey" usage:"defines the arbiter public key held by the external signer daemon"`
 	// Magic defines the magic number used in the DPoS network.
 	Magic uint32 `screw:"--dposmagic" usage:"defines the magic number used in the DPoS network"`
-	// DPoSIPAddress defines the IP address for the DPoS network.
+	// DPoSIPAddress defines the IP address or the onion host for the DPoS
+	// network.
 	IPAddress string `screw:"--dposipaddress" usage:"defines the default IP address for the DPoS network"`
 	// DPoSDefaultPort defines the default port for the DPoS network.
 	DPoSPort uint16 `screw:"--dposport" usage:"defines the default port for the DPoS network"`
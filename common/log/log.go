@@ -13,6 +13,10 @@ import (
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/elastos/Elastos.ELA/common"
 	"github.com/elastos/Elastos.ELA/utils/elalog"
@@ -60,6 +64,12 @@ const (
 
 var logger *Logger
 
+// subsystems holds the loggers of each subsystem created by Subsystem.
+var (
+	subsystemsMtx sync.Mutex
+	subsystems    = make(map[string][]*Logger)
+)
+
 func levelName(level uint8) string {
 	if int(level) >= len(levels) {
 		return fmt.Sprintf("LEVEL%d", level)
@@ -67,48 +77,107 @@ func levelName(level uint8) string {
 	return levels[int(level)]
 }
 
+// Config is the configuration to create a logger.
+type Config struct {
+	// Path is the folder path to put log files.
+	Path string
+
+	// Subsystem is the subsystem tag of the log messages in the JSON format,
+	// the level of the logger can be changed by SetSubsystemLevel if it is
+	// not empty.
+	Subsystem string
+
+	// Level is the log print level.
+	Level uint8
+
+	// Format is the output format of log messages.
+	Format elalog.Format
+
+	// MaxPerLogSizeMb is the max size of a log file in MB.
+	MaxPerLogSizeMb int64
+
+	// MaxLogsSizeMb is the max size of the log folder in MB.
+	MaxLogsSizeMb int64
+
+	// RotateInterval is the interval to create a new log file, new log files
+	// are created by size only if it is 0.
+	RotateInterval time.Duration
+}
+
 type Logger struct {
-	level  uint8 // The log print level
+	level  *uint32 // The log print level, atomic
+	format elalog.Format
+	tag    string
+	fields []elalog.Field
+	skip   int
 	writer io.Writer
 	logger *log.Logger
 }
 
-func NewLogger(outputPath string, level uint8, maxPerLogSizeMb,
-	maxLogsSizeMb int64) *Logger {
-	fileWriter := fileWriter(outputPath, maxPerLogSizeMb, maxLogsSizeMb)
-	logWriter := io.MultiWriter(os.Stdout, fileWriter)
+// New creates a logger writing to the standard output and the log files.
+func New(cfg *Config) *Logger {
+	return newLogger(cfg, io.MultiWriter(os.Stdout, fileWriter(cfg)))
+}
 
-	return &Logger{
-		level:  level,
-		writer: logWriter,
-		logger: log.New(logWriter, "", log.Ldate|log.Lmicroseconds),
+func newLogger(cfg *Config, writer io.Writer) *Logger {
+	flag := log.Ldate | log.Lmicroseconds
+	if cfg.Format == elalog.FormatJSON {
+		flag = 0
+	}
+	level := uint32(cfg.Level)
+	l := &Logger{
+		level:  &level,
+		format: cfg.Format,
+		tag:    cfg.Subsystem,
+		writer: writer,
+		logger: log.New(writer, "", flag),
 	}
+	if cfg.Subsystem != "" {
+		registerSubsystem(l)
+	}
+	return l
 }
 
-func NewFileLogger(outputPath string, level uint8, maxPerLogSizeMb,
+func registerSubsystem(l *Logger) {
+	subsystemsMtx.Lock()
+	subsystems[l.tag] = append(subsystems[l.tag], l)
+	subsystemsMtx.Unlock()
+}
+
+func NewLogger(outputPath string, level uint8, maxPerLogSizeMb,
 	maxLogsSizeMb int64) *Logger {
-	fileWriter := fileWriter(outputPath, maxPerLogSizeMb, maxLogsSizeMb)
+	return New(&Config{
+		Path:            outputPath,
+		Level:           level,
+		MaxPerLogSizeMb: maxPerLogSizeMb,
+		MaxLogsSizeMb:   maxLogsSizeMb,
+	})
+}
 
-	return &Logger{
-		level:  level,
-		writer: fileWriter,
-		logger: log.New(fileWriter, "", log.Ldate|log.Lmicroseconds),
+func NewFileLogger(outputPath string, level uint8, maxPerLogSizeMb,
+	maxLogsSizeMb int64) *Logger {
+	cfg := &Config{
+		Path:            outputPath,
+		Level:           level,
+		MaxPerLogSizeMb: maxPerLogSizeMb,
+		MaxLogsSizeMb:   maxLogsSizeMb,
 	}
+	return newLogger(cfg, fileWriter(cfg))
 }
 
-func fileWriter(outputPath string, maxPerLogSizeMb,
-	maxLogsSizeMb int64) io.Writer {
+func fileWriter(cfg *Config) io.Writer {
 	var perLogFileSize = defaultPerLogFileSize
 	var logsFolderSize = defaultLogsFolderSize
 
-	if maxPerLogSizeMb != 0 {
-		perLogFileSize = maxPerLogSizeMb * elalog.MBSize
+	if cfg.MaxPerLogSizeMb != 0 {
+		perLogFileSize = cfg.MaxPerLogSizeMb * elalog.MBSize
 	}
-	if maxLogsSizeMb != 0 {
-		logsFolderSize = maxLogsSizeMb * elalog.MBSize
+	if cfg.MaxLogsSizeMb != 0 {
+		logsFolderSize = cfg.MaxLogsSizeMb * elalog.MBSize
 	}
 
-	return elalog.NewFileWriter(outputPath, perLogFileSize, logsFolderSize)
+	return elalog.NewRotateFileWriter(cfg.Path, perLogFileSize,
+		logsFolderSize, cfg.RotateInterval)
 }
 
 func NewDefault(path string, level uint8, maxPerLogSizeMb, maxLogsSizeMb int64) *Logger {
@@ -116,54 +185,125 @@ func NewDefault(path string, level uint8, maxPerLogSizeMb, maxLogsSizeMb int64)
 	return logger
 }
 
+// NewDefaultWithConfig creates the default logger used by the package level
+// functions with the configuration.
+func NewDefaultWithConfig(cfg *Config) *Logger {
+	logger = New(cfg)
+	return logger
+}
+
 func (l *Logger) Writer() io.Writer {
 	return l.writer
 }
 
-func (l *Logger) Output(level uint8, a ...interface{}) {
-	if l.level <= level {
-		a = append([]interface{}{levelName(level), "GID", common.Goid() + ","}, a...)
-		l.logger.Output(calldepth, fmt.Sprintln(a...))
-	}
+// Format returns the output format of log messages.
+func (l *Logger) Format() elalog.Format {
+	return l.format
 }
 
-func (l *Logger) Outputf(level uint8, format string, v ...interface{}) {
-	if l.level <= level {
-		v = append([]interface{}{levelName(level), "GID", common.Goid()}, v...)
-		l.logger.Output(calldepth, fmt.Sprintf("%s %s %s, "+format+"\n", v...))
+// Subsystem returns a logger of the subsystem, which writes to the same output
+// as l with its own level and tags the log messages with the subsystem in the
+// JSON format.  The levels of all loggers of a subsystem can be changed by
+// SetSubsystemLevel.
+func (l *Logger) Subsystem(tag string, level elalog.Level) *Logger {
+	lvl := uint32(level)
+	s := &Logger{
+		level:  &lvl,
+		format: l.format,
+		tag:    tag,
+		writer: l.writer,
+		logger: l.logger,
 	}
-}
-
-func (l *Logger) Debug(a ...interface{}) {
-	if l.level > debugLog {
+	registerSubsystem(s)
+	return s
+}
+
+// WithFields returns a logger attaching the structured fields to the log
+// messages, which shares the level with l.
+func (l *Logger) WithFields(fields ...elalog.Field) elalog.Logger {
+	c := *l
+	c.fields = append(append([]elalog.Field(nil), l.fields...), fields...)
+	return &c
+}
+
+// CallerSkip returns a logger sharing the level with l, which skips the extra
+// stack frames to get the callsite of debug messages.  It is used by the
+// functions wrapping the logger.
+func (l *Logger) CallerSkip(skip int) *Logger {
+	c := *l
+	c.skip = skip
+	return &c
+}
+
+// output writes the log message, caller is the function name and the file and
+// line number of the callsite if it is not empty.
+func (l *Logger) output(level uint8, caller, msg string) {
+	if l.format == elalog.FormatJSON {
+		fields := append([]elalog.Field{{Key: "gid", Value: common.Goid()}},
+			l.fields...)
+		buf := elalog.AppendJSON(nil, time.Now(), elalog.Level(level), l.tag,
+			caller, msg, fields)
+		l.logger.Output(calldepth, string(buf))
 		return
 	}
 
-	pc, file, line, ok := runtime.Caller(calldepth)
+	buf := make([]byte, 0, len(msg)+64)
+	buf = append(buf, levelName(level)...)
+	buf = append(buf, " GID "...)
+	buf = append(buf, common.Goid()...)
+	buf = append(buf, ", "...)
+	if caller != "" {
+		buf = append(buf, caller...)
+		buf = append(buf, ' ')
+	}
+	buf = append(buf, msg...)
+	buf = elalog.AppendText(buf, l.fields)
+	l.logger.Output(calldepth, string(buf))
+}
+
+// debug writes the debug message with the function name and the file and line
+// number of the callsite, it must be called by the function called by the
+// callsite to get the right call depth.
+func (l *Logger) debug(msg string) {
+	pc, file, line, ok := runtime.Caller(calldepth + l.skip)
 	if !ok {
 		return
 	}
 
-	fn := runtime.FuncForPC(pc)
-	a = append([]interface{}{fn.Name(), filepath.Base(file) + ":" + strconv.Itoa(line)}, a...)
+	caller := runtime.FuncForPC(pc).Name() + " " + filepath.Base(file) + ":" +
+		strconv.Itoa(line)
+	l.output(debugLog, caller, msg)
+}
 
-	l.Output(debugLog, a...)
+// sprintln formats the operands as fmt.Sprintln without the trailing newline.
+func sprintln(a ...interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(a...), "\n")
 }
 
-func (l *Logger) Debugf(format string, a ...interface{}) {
-	if l.level > debugLog {
-		return
+func (l *Logger) Output(level uint8, a ...interface{}) {
+	if uint8(l.Level()) <= level {
+		l.output(level, "", sprintln(a...))
 	}
+}
 
-	pc, file, line, ok := runtime.Caller(calldepth)
-	if !ok {
-		return
+func (l *Logger) Outputf(level uint8, format string, v ...interface{}) {
+	if uint8(l.Level()) <= level {
+		l.output(level, "", fmt.Sprintf(format, v...))
 	}
+}
 
-	fn := runtime.FuncForPC(pc)
-	a = append([]interface{}{fn.Name(), filepath.Base(file), line}, a...)
+func (l *Logger) Debug(a ...interface{}) {
+	if l.Level() > elalog.LevelDebug {
+		return
+	}
+	l.debug(sprintln(a...))
+}
 
-	l.Outputf(debugLog, "%s %s:%d "+format, a...)
+func (l *Logger) Debugf(format string, a ...interface{}) {
+	if l.Level() > elalog.LevelDebug {
+		return
+	}
+	l.debug(fmt.Sprintf(format, a...))
 }
 
 func (l *Logger) Info(a ...interface{}) {
@@ -183,9 +323,7 @@ func (l *Logger) Warnf(format string, a ...interface{}) {
 }
 
 func (l *Logger) Error(a ...interface{}) {
-	if l.level <= errorLog {
-		l.Output(errorLog, a...)
-	}
+	l.Output(errorLog, a...)
 }
 
 func (l *Logger) Errorf(format string, a ...interface{}) {
@@ -202,20 +340,54 @@ func (l *Logger) Fatalf(format string, a ...interface{}) {
 
 // Level returns the current logging level.
 func (l *Logger) Level() elalog.Level {
-	return elalog.Level(l.level)
+	return elalog.Level(atomic.LoadUint32(l.level))
 }
 
 // SetLevel changes the logging level to the passed level.
 func (l *Logger) SetLevel(level elalog.Level) {
-	l.level = uint8(level)
+	atomic.StoreUint32(l.level, uint32(level))
+}
+
+// SetSubsystemLevel changes the logging level of all loggers of the subsystem
+// to the passed level.
+func SetSubsystemLevel(tag string, level elalog.Level) error {
+	subsystemsMtx.Lock()
+	defer subsystemsMtx.Unlock()
+
+	loggers, ok := subsystems[tag]
+	if !ok {
+		return fmt.Errorf("unknown subsystem %s", tag)
+	}
+	for _, l := range loggers {
+		l.SetLevel(level)
+	}
+	return nil
+}
+
+// SubsystemLevels returns the logging levels of the subsystems.
+func SubsystemLevels() map[string]elalog.Level {
+	subsystemsMtx.Lock()
+	defer subsystemsMtx.Unlock()
+
+	levels := make(map[string]elalog.Level, len(subsystems))
+	for tag, loggers := range subsystems {
+		levels[tag] = loggers[0].Level()
+	}
+	return levels
 }
 
 func Debug(a ...interface{}) {
-	logger.Debug(a...)
+	if logger.Level() > elalog.LevelDebug {
+		return
+	}
+	logger.debug(sprintln(a...))
 }
 
 func Debugf(format string, a ...interface{}) {
-	logger.Debugf(format, a...)
+	if logger.Level() > elalog.LevelDebug {
+		return
+	}
+	logger.debug(fmt.Sprintf(format, a...))
 }
 
 func Info(a ...interface{}) {
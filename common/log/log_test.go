@@ -0,0 +1,82 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/utils/elalog"
+)
+
+func TestLogger_Subsystem(t *testing.T) {
+	var buf bytes.Buffer
+	l := newLogger(&Config{Format: elalog.FormatJSON, Level: infoLog}, &buf)
+	pow := l.Subsystem("test_pow", elalog.LevelInfo)
+	pow2 := l.Subsystem("test_pow", elalog.LevelInfo)
+
+	pow.WithFields(elalog.Height(10)).Info("block mined")
+	pow.Debugf("filtered %d", 1)
+	if err := SetSubsystemLevel("test_pow", elalog.LevelDebug); err != nil {
+		t.Fatal(err)
+	}
+	if pow2.Level() != elalog.LevelDebug {
+		t.Errorf("level of pow2 is %s, want DBG", pow2.Level())
+	}
+	pow.Debugf("written %d", 2)
+	l.Debug("filtered")
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), buf.String())
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["subsystem"] != "test_pow" || m["msg"] != "block mined" ||
+		m["height"] != float64(10) || m["level"] != "info" {
+		t.Errorf("unexpected message %s", lines[0])
+	}
+	m = nil
+	if err := json.Unmarshal([]byte(lines[1]), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["msg"] != "written 2" ||
+		!strings.Contains(m["caller"].(string), "log_test.go:") {
+		t.Errorf("unexpected message %s", lines[1])
+	}
+
+	if SubsystemLevels()["test_pow"] != elalog.LevelDebug {
+		t.Errorf("SubsystemLevels: got %v", SubsystemLevels())
+	}
+	if err := SetSubsystemLevel("unknown", elalog.LevelDebug); err == nil {
+		t.Errorf("SetSubsystemLevel: expected error for unknown subsystem")
+	}
+}
+
+func TestLogger_Text(t *testing.T) {
+	var buf bytes.Buffer
+	l := newLogger(&Config{Level: debugLog}, &buf)
+	l.WithFields(elalog.Peer("127.0.0.1:20338")).Warn("misbehaving", 1)
+	l.Debugf("debug %d", 2)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), buf.String())
+	}
+	if !strings.HasSuffix(lines[0],
+		", misbehaving 1 peer=127.0.0.1:20338") ||
+		!strings.Contains(lines[0], levelName(warnLog)+" GID ") {
+		t.Errorf("unexpected line %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "TestLogger_Text log_test.go:") ||
+		!strings.HasSuffix(lines[1], " debug 2") {
+		t.Errorf("unexpected line %q", lines[1])
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/elastos/Elastos.ELA/common/config"
 	"github.com/elastos/Elastos.ELA/utils"
@@ -111,6 +112,7 @@ func (c *fileChannels) messageLoop() {
 
 func (c *fileChannels) saveCheckpoint(msg *fileMsg) (err error) {
 	defer c.replyMsg(msg)
+	defer saveDuration.ObserveDuration(time.Now(), msg.checkpoint.Key())
 
 	dir := getCheckpointDirectory(c.cfg.DataPath, msg.checkpoint)
 	if !utils.FileExisted(dir) {
@@ -26,9 +26,11 @@ import (
 
 // todo remove this
 const (
-	txpoolCheckpointKey = "cp_txPool"
-	dposCheckpointKey   = "cp_dpos"
-	crCheckpointKey     = "cp_cr"
+	txpoolCheckpointKey       = "cp_txPool"
+	feeEstimatorCheckpointKey = "cp_feeEstimator"
+	performanceCheckpointKey  = "cp_performance"
+	dposCheckpointKey         = "cp_dpos"
+	crCheckpointKey           = "cp_cr"
 
 	MaxCheckPointFilesCount int = 36
 )
@@ -151,7 +153,7 @@ func (m *Manager) OnRollbackTo(height uint32, isPow bool) error {
 	sortedPoints := m.getOrderedCheckpoints()
 	for _, v := range sortedPoints {
 		if err := v.OnRollbackTo(height); err != nil {
-			log.Errorf("manager rollback failed,", err)
+			log.Errorf("manager rollback failed, %s", err)
 			return err
 		}
 	}
@@ -266,17 +268,33 @@ func (m *Manager) Reset(filter func(point ICheckPoint) bool) {
 // SafeHeight returns the minimum height of all checkpoints from which we can
 // rescan block chain data.
 func (m *Manager) SafeHeight() uint32 {
+	return m.safeHeight(false)
+}
+
+// RestoredSafeHeight returns the minimum height of all checkpoints from which
+// we can rescan block chain data after they are restored from the default
+// checkpoint files, which lag behind the saved checkpoints by a save period.
+func (m *Manager) RestoredSafeHeight() uint32 {
+	return m.safeHeight(true)
+}
+
+func (m *Manager) safeHeight(restored bool) uint32 {
 	m.mtx.RLock()
 	defer m.mtx.RUnlock()
 
 	height := uint32(math.MaxUint32)
 	for _, v := range m.checkpoints {
-		if v.Key() == "cp_txPool" {
+		if v.Key() == "cp_txPool" || v.Key() == feeEstimatorCheckpointKey ||
+			v.Key() == performanceCheckpointKey {
 			continue
 		}
+		period := v.EffectivePeriod()
+		if restored {
+			period += v.SavePeriod()
+		}
 		var recordHeight uint32
-		if v.GetHeight() >= v.EffectivePeriod() {
-			recordHeight = v.GetHeight() - v.EffectivePeriod()
+		if v.GetHeight() >= period {
+			recordHeight = v.GetHeight() - period
 		} else {
 			recordHeight = 0
 		}
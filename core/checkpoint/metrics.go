@@ -0,0 +1,18 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package checkpoint
+
+import (
+	"github.com/elastos/Elastos.ELA/utils/metrics"
+)
+
+// saveDuration is the time to save checkpoints to files by checkpoint key.
+var saveDuration = metrics.NewHistogram("ela_checkpoint_save_seconds",
+	"Time to save a checkpoint to file.", metrics.DefaultBuckets, "key")
+
+func init() {
+	metrics.Register(saveDuration)
+}
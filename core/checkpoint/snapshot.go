@@ -0,0 +1,170 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package checkpoint
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/elastos/Elastos.ELA/common"
+)
+
+// snapshotCheckpoints defines the checkpoints carried by a chain snapshot,
+// the DPoS and CR checkpoints are required to rebuild the consensus state
+// at the snapshot height.
+var snapshotCheckpoints = []struct {
+	key      string
+	required bool
+}{
+	{dposCheckpointKey, true},
+	{crCheckpointKey, true},
+	{txpoolCheckpointKey, false},
+}
+
+// SnapshotFile represents a checkpoint file carried by a chain snapshot.
+type SnapshotFile struct {
+	Key       string
+	Extension string
+	Height    uint32
+	Data      []byte
+}
+
+func (f *SnapshotFile) Serialize(w io.Writer) error {
+	if err := common.WriteVarString(w, f.Key); err != nil {
+		return err
+	}
+	if err := common.WriteVarString(w, f.Extension); err != nil {
+		return err
+	}
+	if err := common.WriteUint32(w, f.Height); err != nil {
+		return err
+	}
+	return common.WriteVarBytes(w, f.Data)
+}
+
+func (f *SnapshotFile) Deserialize(r io.Reader) (err error) {
+	if f.Key, err = common.ReadVarString(r); err != nil {
+		return
+	}
+	if f.Extension, err = common.ReadVarString(r); err != nil {
+		return
+	}
+	if f.Height, err = common.ReadUint32(r); err != nil {
+		return
+	}
+	f.Data, err = common.ReadVarBytes(r, math.MaxUint32, "checkpoint data")
+	return
+}
+
+// LoadSnapshotFiles returns the newest checkpoint file not higher than the
+// given height of each checkpoint carried by a chain snapshot.
+func LoadSnapshotFiles(root string, height uint32) ([]*SnapshotFile, error) {
+	files := make([]*SnapshotFile, 0, len(snapshotCheckpoints))
+	for _, c := range snapshotCheckpoints {
+		file, err := loadSnapshotFile(filepath.Join(root, c.key), height)
+		if err != nil {
+			return nil, err
+		}
+		if file == nil {
+			if c.required {
+				return nil, fmt.Errorf("no %s checkpoint found at or "+
+					"below height %d", c.key, height)
+			}
+			continue
+		}
+		file.Key = c.key
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func loadSnapshotFile(dir string, height uint32) (*SnapshotFile, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var result *SnapshotFile
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		ext := filepath.Ext(entry.Name())
+		name := strings.TrimSuffix(entry.Name(), ext)
+		if name != DefaultCheckpoint {
+			if _, err := strconv.ParseUint(name, 10, 32); err != nil {
+				continue
+			}
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		// Every checkpoint is serialized starting with its height.
+		fileHeight, err := common.ReadUint32(bytes.NewReader(data))
+		if err != nil || fileHeight > height {
+			continue
+		}
+		if result == nil || fileHeight > result.Height {
+			result = &SnapshotFile{
+				Extension: ext,
+				Height:    fileHeight,
+				Data:      data,
+			}
+		}
+	}
+	return result, nil
+}
+
+// SaveSnapshotFiles stores the checkpoint files of a chain snapshot as the
+// default checkpoints under the given root path, so they will be restored
+// by the Restore method of the checkpoint manager.
+func SaveSnapshotFiles(root string, files []*SnapshotFile) error {
+	for _, f := range files {
+		dir := filepath.Join(root, f.Key)
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+		path := filepath.Join(dir, DefaultCheckpoint+f.Extension)
+		if err := ioutil.WriteFile(path, f.Data, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreSnapshotFiles loads the data of the given checkpoint files into the
+// registered checkpoints of the same keys.
+func (m *Manager) RestoreSnapshotFiles(files []*SnapshotFile) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for _, v := range m.getOrderedCheckpoints() {
+		for _, f := range files {
+			if f.Key != v.Key() {
+				continue
+			}
+			if err := v.Deserialize(bytes.NewReader(f.Data)); err != nil {
+				return fmt.Errorf("restore %s checkpoint failed: %s",
+					f.Key, err)
+			}
+			v.OnInit()
+		}
+	}
+	return nil
+}
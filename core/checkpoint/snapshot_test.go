@@ -0,0 +1,103 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package checkpoint
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/common/config"
+	"github.com/elastos/Elastos.ELA/utils/test"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeSnapshotTestFile(t *testing.T, root, key, name string,
+	height uint32) {
+	dir := filepath.Join(root, key)
+	assert.NoError(t, os.MkdirAll(dir, 0700))
+	buf := new(bytes.Buffer)
+	assert.NoError(t, common.WriteUint32(buf, height))
+	buf.WriteString(key)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name),
+		buf.Bytes(), 0600))
+}
+
+func TestLoadSnapshotFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "snapshot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	writeSnapshotTestFile(t, root, dposCheckpointKey, "default.dcp", 720)
+	writeSnapshotTestFile(t, root, dposCheckpointKey, "1440.dcp", 1440)
+	writeSnapshotTestFile(t, root, dposCheckpointKey, "2160.dcp", 2160)
+	writeSnapshotTestFile(t, root, dposCheckpointKey, "history", 100)
+
+	// CR checkpoint is required.
+	_, err = LoadSnapshotFiles(root, 2000)
+	assert.Error(t, err)
+
+	writeSnapshotTestFile(t, root, crCheckpointKey, "default.ccp", 1000)
+	files, err := LoadSnapshotFiles(root, 2000)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(files))
+	assert.Equal(t, dposCheckpointKey, files[0].Key)
+	assert.Equal(t, ".dcp", files[0].Extension)
+	assert.Equal(t, uint32(1440), files[0].Height)
+	assert.Equal(t, crCheckpointKey, files[1].Key)
+	assert.Equal(t, uint32(1000), files[1].Height)
+
+	// No checkpoint at or below the height.
+	_, err = LoadSnapshotFiles(root, 500)
+	assert.Error(t, err)
+
+	// Serialize and save as default checkpoints.
+	buf := new(bytes.Buffer)
+	assert.NoError(t, files[0].Serialize(buf))
+	var file SnapshotFile
+	assert.NoError(t, file.Deserialize(buf))
+	assert.Equal(t, *files[0], file)
+
+	target, err := ioutil.TempDir("", "snapshot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(target)
+	assert.NoError(t, SaveSnapshotFiles(target, files))
+	data, err := ioutil.ReadFile(filepath.Join(target, dposCheckpointKey,
+		"default.dcp"))
+	assert.NoError(t, err)
+	assert.Equal(t, files[0].Data, data)
+	restored, err := LoadSnapshotFiles(target, 2000)
+	assert.NoError(t, err)
+	assert.Equal(t, files, restored)
+}
+
+func TestManager_RestoreSnapshotFiles(t *testing.T) {
+	data := uint64(42)
+	buf := new(bytes.Buffer)
+	assert.NoError(t, (&checkpoint{data: &data, height: 1440}).Serialize(buf))
+
+	pt := &checkpoint{}
+	manager := NewManager(&config.Configuration{})
+	manager.Register(pt)
+	defer manager.Close()
+
+	// Files of other checkpoints are ignored.
+	files := []*SnapshotFile{
+		{Key: crCheckpointKey, Data: []byte{1}},
+		{Key: test.DataDir, Extension: checkpointExtension, Height: 1440,
+			Data: buf.Bytes()},
+	}
+	assert.NoError(t, manager.RestoreSnapshotFiles(files))
+	assert.Equal(t, uint32(1440), pt.GetHeight())
+	assert.Equal(t, data, *pt.data)
+
+	files[1].Data = files[1].Data[:4]
+	assert.Error(t, manager.RestoreSnapshotFiles(files))
+}
@@ -57,6 +57,7 @@ type Committee struct {
 		outputs []*common2.OutputInfo) (interfaces.Transaction, error)
 	getUTXO            func(programHash *common.Uint168) ([]*common2.UTXO, error)
 	getCurrentArbiters func() [][]byte
+	committeeChanged   func(block *types.Block)
 	CkpManager         *checkpoint.Manager
 }
 
@@ -483,7 +484,11 @@ func (c *Committee) ProcessBlock(block *types.Block, confirm *payload.Confirm) {
 	c.mtx.Unlock()
 
 	if needChg {
-		events.Notify(events.ETCRCChangeCommittee, block)
+		if c.committeeChanged != nil {
+			c.committeeChanged(block)
+		} else {
+			events.Notify(events.ETCRCChangeCommittee, block)
+		}
 	}
 }
 
@@ -2143,6 +2148,13 @@ func (c *Committee) TryRevertCRMemberIllegal(did common.Uint168, oriState Member
 	c.state.RevertUpdateCRIllegalPenalty(crMember.Info.CID, illegalPenalty)
 }
 
+// SetCommitteeChangedHandler sets the function to be called instead of
+// notifying the ETCRCChangeCommittee event when the committee changed, so a
+// committee rebuilt at a history height will not affect the others.
+func (c *Committee) SetCommitteeChangedHandler(handler func(block *types.Block)) {
+	c.committeeChanged = handler
+}
+
 func (c *Committee) Snapshot() *CommitteeKeyFrame {
 	keyFrame := &CommitteeKeyFrame{
 		KeyFrame:         c.KeyFrame.Snapshot(),
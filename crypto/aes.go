@@ -8,6 +8,7 @@ package crypto
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
 	"crypto/sha256"
 	"errors"
 )
@@ -55,3 +56,48 @@ func AesDecrypt(cipherText []byte, key []byte, iv []byte) ([]byte, error) {
 
 	return plaintext, nil
 }
+
+// AesGcmEncrypt encrypts and authenticates the plaintext by AES-GCM with a
+// random nonce, the nonce is prepended to the returned cipher text.
+func AesGcmEncrypt(plaintext []byte, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.New("invalid encrypt key")
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// AesGcmDecrypt decrypts the cipher text returned by AesGcmEncrypt, an error
+// is returned if the key is wrong or the cipher text has been modified.
+func AesGcmDecrypt(cipherText []byte, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.New("invalid decrypt key")
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cipherText) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, errors.New("cipherText too short")
+	}
+
+	nonce := cipherText[:gcm.NonceSize()]
+	plaintext, err := gcm.Open(nil, nonce, cipherText[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("cipherText authentication failed")
+	}
+
+	return plaintext, nil
+}
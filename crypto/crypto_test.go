@@ -73,3 +73,30 @@ func TestEncryptDecrypt(t *testing.T) {
 
 
 }
+
+func TestAesGcmEncryptDecrypt(t *testing.T) {
+	key := make([]byte, 32)
+	key[0] = 1
+	message := []byte("Hello World!")
+
+	cipherText, err := AesGcmEncrypt(message, key)
+	assert.NoError(t, err)
+	m, err := AesGcmDecrypt(cipherText, key)
+	assert.NoError(t, err)
+	assert.Equal(t, message, m)
+
+	// Nonces are random, so the same message is encrypted differently.
+	cipherText2, err := AesGcmEncrypt(message, key)
+	assert.NoError(t, err)
+	assert.NotEqual(t, cipherText, cipherText2)
+
+	cipherText[len(cipherText)-1] ^= 1
+	_, err = AesGcmDecrypt(cipherText, key)
+	assert.Error(t, err)
+
+	key[0] = 2
+	_, err = AesGcmDecrypt(cipherText2, key)
+	assert.Error(t, err)
+	_, err = AesGcmDecrypt(cipherText2[:10], key)
+	assert.Error(t, err)
+}
@@ -311,6 +311,33 @@ func (s *blockStore) deleteFile(fileNum uint32) error {
 	return nil
 }
 
+// pruneFile closes the block file for the passed flat file number when it is
+// open and removes it.  A block file which has already been removed is
+// ignored.
+func (s *blockStore) pruneFile(fileNum uint32) error {
+	s.obfMutex.Lock()
+	defer s.obfMutex.Unlock()
+
+	if blockFile, ok := s.openBlockFiles[fileNum]; ok {
+		s.lruMutex.Lock()
+		s.openBlocksLRU.Remove(s.fileNumToLRUElem[fileNum])
+		delete(s.fileNumToLRUElem, fileNum)
+		s.lruMutex.Unlock()
+
+		// Close the file under the write lock for the file in case any
+		// readers are currently reading from it.
+		blockFile.Lock()
+		_ = blockFile.file.Close()
+		blockFile.Unlock()
+		delete(s.openBlockFiles, fileNum)
+	}
+
+	if !fileExists(blockFilePath(s.basePath, fileNum)) {
+		return nil
+	}
+	return s.deleteFileFunc(fileNum)
+}
+
 // blockFile attempts to return an existing file handle for the passed flat file
 // number if it is already open as well as marking it as most recently used.  It
 // will also open the file when it's not already open subject to the rules
@@ -724,7 +751,7 @@ func (s *blockStore) handleRollback(oldBlockFileNum, oldBlockOffset uint32) {
 func scanBlockFiles(dbPath string) (int, uint32) {
 	lastFile := -1
 	fileLen := uint32(0)
-	for i := 0; ; i++ {
+	for i := firstBlockFile(dbPath); ; i++ {
 		filePath := blockFilePath(dbPath, uint32(i))
 		st, err := os.Stat(filePath)
 		if err != nil {
@@ -740,6 +767,32 @@ func scanBlockFiles(dbPath string) (int, uint32) {
 	return lastFile, fileLen
 }
 
+// firstBlockFile returns the lowest number of the block files in the passed
+// path, the block files below it may have been removed by pruning.
+func firstBlockFile(dbPath string) int {
+	paths, err := filepath.Glob(filepath.Join(dbPath, "*.fdb"))
+	if err != nil {
+		return 0
+	}
+
+	first := -1
+	for _, path := range paths {
+		var fileNum int
+		_, err := fmt.Sscanf(filepath.Base(path), blockFilenameTemplate,
+			&fileNum)
+		if err != nil {
+			continue
+		}
+		if first == -1 || fileNum < first {
+			first = fileNum
+		}
+	}
+	if first == -1 {
+		return 0
+	}
+	return first
+}
+
 // newBlockStore returns a new block store with the current block file number
 // and offset set and all fields initialized.
 func newBlockStore(basePath string, network wire.BitcoinNet) *blockStore {
@@ -967,6 +967,10 @@ type transaction struct {
 	pendingBlocks    map[common.Uint256]int
 	pendingBlockData []pendingBlock
 
+	// Blocks and block files that need to be removed on commit.
+	pendingPrunedBlocks []common.Uint256
+	pendingPrunedFiles  []uint32
+
 	// Keys that need to be stored or deleted on commit.
 	pendingKeys   *treap.Mutable
 	pendingRemove *treap.Mutable
@@ -1576,6 +1580,79 @@ func (tx *transaction) FetchBlockRegions(regions []database.BlockRegion) ([][]by
 	return blockRegions, nil
 }
 
+// PruneBlocks removes the flat block files which only house blocks accepted by
+// the prunable function, along with the entries of their blocks in the block
+// index.  The current write file is never removed.  The hashes of the removed
+// blocks are returned.
+//
+// The removed blocks stay readable through the transaction, and the block
+// files are only deleted once the transaction is committed and the metadata
+// has been flushed to persistent storage.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) PruneBlocks(prunable func(hash common.Uint256) bool) (
+	[]common.Uint256, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	// Ensure the transaction is writable.
+	if !tx.writable {
+		str := "prune blocks requires a writable database transaction"
+		return nil, makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	wc := tx.db.store.writeCursor
+	wc.RLock()
+	curFileNum := wc.curFileNum
+	wc.RUnlock()
+
+	// Group the blocks by the files they are stored in, and keep the files
+	// which house the current write position or any block that should not
+	// be pruned.
+	fileBlocks := make(map[uint32][]common.Uint256)
+	keptFiles := make(map[uint32]struct{})
+	err := tx.blockIdxBucket.ForEach(func(k, v []byte) error {
+		var hash common.Uint256
+		copy(hash[:], k)
+		location := deserializeBlockLoc(v)
+		if location.blockFileNum >= curFileNum || !prunable(hash) {
+			keptFiles[location.blockFileNum] = struct{}{}
+			return nil
+		}
+		fileBlocks[location.blockFileNum] = append(
+			fileBlocks[location.blockFileNum], hash)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fileNums := make([]uint32, 0, len(fileBlocks))
+	for fileNum := range fileBlocks {
+		if _, ok := keptFiles[fileNum]; !ok {
+			fileNums = append(fileNums, fileNum)
+		}
+	}
+	sort.Slice(fileNums, func(i, j int) bool {
+		return fileNums[i] < fileNums[j]
+	})
+
+	var pruned []common.Uint256
+	for _, fileNum := range fileNums {
+		pruned = append(pruned, fileBlocks[fileNum]...)
+		tx.pendingPrunedFiles = append(tx.pendingPrunedFiles, fileNum)
+	}
+	tx.pendingPrunedBlocks = append(tx.pendingPrunedBlocks, pruned...)
+
+	return pruned, nil
+}
+
 // close marks the transaction closed then releases any pending data, the
 // underlying snapshot, the transaction read lock, and the write lock when the
 // transaction is writable.
@@ -1586,6 +1663,11 @@ func (tx *transaction) close() {
 	tx.pendingBlocks = nil
 	tx.pendingBlockData = nil
 
+	// Clear pending blocks and block files that would have been removed on
+	// commit.
+	tx.pendingPrunedBlocks = nil
+	tx.pendingPrunedFiles = nil
+
 	// Clear pending keys that would have been written or deleted on commit.
 	tx.pendingKeys = nil
 	tx.pendingRemove = nil
@@ -1650,6 +1732,15 @@ func (tx *transaction) writePendingAndCommit() error {
 		}
 	}
 
+	// Remove the records of the pruned blocks from the block index.
+	for i := range tx.pendingPrunedBlocks {
+		hash := tx.pendingPrunedBlocks[i]
+		if err := tx.blockIdxBucket.Delete(hash[:]); err != nil {
+			rollback()
+			return err
+		}
+	}
+
 	// Update the metadata for the current write file and offset.
 	writeRow := serializeWriteRow(wc.curFileNum, wc.curOffset)
 	if err := tx.metaBucket.Put(writeLocKeyName, writeRow); err != nil {
@@ -1659,7 +1750,25 @@ func (tx *transaction) writePendingAndCommit() error {
 
 	// Atomically update the database cache.  The cache automatically
 	// handles flushing to the underlying persistent storage database.
-	return tx.db.cache.commitTx(tx)
+	if err := tx.db.cache.commitTx(tx); err != nil {
+		return err
+	}
+	if len(tx.pendingPrunedFiles) == 0 {
+		return nil
+	}
+
+	// Flush the metadata before deleting the pruned block files, so the
+	// block index will never refer to a block file that no longer exists
+	// in unexpected shutdown scenarios.
+	if err := tx.db.cache.flush(); err != nil {
+		return err
+	}
+	for _, fileNum := range tx.pendingPrunedFiles {
+		if err := tx.db.store.pruneFile(fileNum); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Commit commits all changes that have been made to the root metadata bucket
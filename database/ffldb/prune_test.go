@@ -0,0 +1,109 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package ffldb
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/database"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPruneBlocks(t *testing.T) {
+	dbPath, err := ioutil.TempDir("", "ffldb-prune")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dbPath)
+
+	pdb, err := openDB(dbPath, wire.MainNet, true)
+	assert.NoError(t, err)
+
+	// Store every two blocks into one block file.
+	pdb.(*db).store.maxBlockFileSize = 2 * (100 + 12)
+	hashes := make([]common.Uint256, 7)
+	for i := range hashes {
+		hashes[i] = common.Uint256{byte(i + 1)}
+		data := make([]byte, 100)
+		data[0] = byte(i + 1)
+		err := pdb.Update(func(dbTx database.Tx) error {
+			return dbTx.StoreBlock(hashes[i], data)
+		})
+		assert.NoError(t, err)
+	}
+	for i := uint32(0); i < 4; i++ {
+		assert.True(t, fileExists(blockFilePath(dbPath, i)))
+	}
+
+	// Read-only transactions can not prune blocks.
+	err = pdb.View(func(dbTx database.Tx) error {
+		_, err := dbTx.PruneBlocks(func(common.Uint256) bool { return true })
+		return err
+	})
+	assert.Error(t, err)
+
+	// Block 3 is not prunable, so the second file should be kept.
+	prunable := func(hash common.Uint256) bool {
+		return hash[0] <= 5 && hash[0] != 3
+	}
+	var pruned []common.Uint256
+	err = pdb.Update(func(dbTx database.Tx) error {
+		var err error
+		pruned, err = dbTx.PruneBlocks(prunable)
+		if err != nil {
+			return err
+		}
+
+		// Pruned blocks are still readable before commit.
+		data, err := dbTx.FetchBlock(&hashes[0])
+		assert.NoError(t, err)
+		assert.Equal(t, byte(1), data[0])
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []common.Uint256{hashes[0], hashes[1]}, pruned)
+	assert.False(t, fileExists(blockFilePath(dbPath, 0)))
+	assert.True(t, fileExists(blockFilePath(dbPath, 1)))
+	assert.True(t, fileExists(blockFilePath(dbPath, 2)))
+
+	err = pdb.View(func(dbTx database.Tx) error {
+		exists, err := dbTx.HasBlock(hashes[0])
+		assert.NoError(t, err)
+		assert.False(t, exists)
+		_, err = dbTx.FetchBlock(&hashes[1])
+		assert.Error(t, err)
+
+		data, err := dbTx.FetchBlock(&hashes[4])
+		assert.NoError(t, err)
+		assert.Equal(t, byte(5), data[0])
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, pdb.Close())
+
+	// Reopen the database and make sure new blocks are appended to the
+	// latest block file.
+	pdb, err = openDB(dbPath, wire.MainNet, false)
+	assert.NoError(t, err)
+	defer pdb.Close()
+	assert.Equal(t, uint32(3), pdb.(*db).store.writeCursor.curFileNum)
+
+	hash := common.Uint256{8}
+	err = pdb.Update(func(dbTx database.Tx) error {
+		return dbTx.StoreBlock(hash, make([]byte, 50))
+	})
+	assert.NoError(t, err)
+	err = pdb.View(func(dbTx database.Tx) error {
+		_, err := dbTx.FetchBlock(&hashes[6])
+		assert.NoError(t, err)
+		_, err = dbTx.FetchBlock(&hash)
+		return err
+	})
+	assert.NoError(t, err)
+}
@@ -393,6 +393,24 @@ type Tx interface {
 	// implementations.
 	FetchBlockRegions(regions []BlockRegion) ([][]byte, error)
 
+	// PruneBlocks removes the stored blocks which are accepted by the
+	// prunable function.  Depending on the backend implementation, blocks
+	// may be stored in groups, such as flat files, which are only removed
+	// when all blocks of the group are prunable, so it is possible that
+	// some prunable blocks are kept.  The hashes of the removed blocks are
+	// returned.
+	//
+	// The removed blocks stay readable through the transaction and are
+	// only removed from the database when it is committed.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrTxNotWritable if attempted against a read-only transaction
+	//   - ErrTxClosed if the transaction has already been closed
+	//
+	// Other errors are possible depending on the implementation.
+	PruneBlocks(prunable func(hash common.Uint256) bool) ([]common.Uint256, error)
+
 	// ******************************************************************
 	// Methods related to both atomic metadata storage and block storage.
 	// ******************************************************************
@@ -7,8 +7,12 @@ package account
 
 import (
 	"bytes"
+	"errors"
 
 	"github.com/elastos/Elastos.ELA/account"
+	"github.com/elastos/Elastos.ELA/account/signer"
+	"github.com/elastos/Elastos.ELA/common"
+	common2 "github.com/elastos/Elastos.ELA/core/types/common"
 	"github.com/elastos/Elastos.ELA/core/types/interfaces"
 	"github.com/elastos/Elastos.ELA/core/types/payload"
 	"github.com/elastos/Elastos.ELA/crypto"
@@ -17,31 +21,31 @@ import (
 type Account interface {
 	PublicKey() *crypto.PublicKey
 	PublicKeyBytes() []byte
-	SignProposal(proposal *payload.DPOSProposal) ([]byte, error)
-	SignVote(vote *payload.DPOSProposalVote) ([]byte, error)
+	SignProposal(proposal *payload.DPOSProposal,
+		header *common2.Header) ([]byte, error)
+	SignVote(vote *payload.DPOSProposalVote, proposal *payload.DPOSProposal,
+		header *common2.Header) ([]byte, error)
 	Sign(data []byte) []byte
 	SignTx(tx interfaces.Transaction) ([]byte, error)
 	DecryptAddr(cipher []byte) (addr string, err error)
 }
 
 type dAccount struct {
-	*account.Account
+	signer signer.Signer
 	pubKey []byte
 }
 
 func (a *dAccount) PublicKey() *crypto.PublicKey {
-	return a.Account.PublicKey
+	return a.signer.PublicKey()
 }
 
 func (a *dAccount) PublicKeyBytes() []byte {
 	return a.pubKey
 }
 
-func (a *dAccount) SignProposal(proposal *payload.DPOSProposal) ([]byte,
-	error) {
-	privateKey := a.PrivKey()
-
-	signature, err := crypto.Sign(privateKey, proposal.Data())
+func (a *dAccount) SignProposal(proposal *payload.DPOSProposal,
+	header *common2.Header) ([]byte, error) {
+	signature, err := a.signer.SignProposal(proposal, header)
 	if err != nil {
 		return []byte{0}, err
 	}
@@ -49,10 +53,9 @@ func (a *dAccount) SignProposal(proposal *payload.DPOSProposal) ([]byte,
 	return signature, nil
 }
 
-func (a *dAccount) SignVote(vote *payload.DPOSProposalVote) ([]byte, error) {
-	privateKey := a.PrivKey()
-
-	signature, err := crypto.Sign(privateKey, vote.Data())
+func (a *dAccount) SignVote(vote *payload.DPOSProposalVote,
+	proposal *payload.DPOSProposal, header *common2.Header) ([]byte, error) {
+	signature, err := a.signer.SignVote(vote, proposal, header)
 	if err != nil {
 		return []byte{0}, err
 	}
@@ -61,8 +64,7 @@ func (a *dAccount) SignVote(vote *payload.DPOSProposalVote) ([]byte, error) {
 }
 
 func (a *dAccount) Sign(data []byte) []byte {
-	privateKey := a.PrivKey()
-	sign, err := crypto.Sign(privateKey, data)
+	sign, err := a.signer.SignMessage(data)
 	if err != nil {
 		return nil
 	}
@@ -70,17 +72,11 @@ func (a *dAccount) Sign(data []byte) []byte {
 }
 
 func (a *dAccount) SignTx(tx interfaces.Transaction) ([]byte, error) {
-	buf := new(bytes.Buffer)
-	if err := tx.SerializeUnsigned(buf); err != nil {
-		return nil, err
-	}
-
-	privateKey := a.PrivKey()
-	return crypto.Sign(privateKey, buf.Bytes())
+	return a.signer.SignTx(tx)
 }
 
 func (a *dAccount) DecryptAddr(cipher []byte) (addr string, err error) {
-	data, err := crypto.Decrypt(a.PrivateKey, cipher)
+	data, err := a.signer.Decrypt(cipher)
 	return string(data), err
 }
 
@@ -89,16 +85,67 @@ func Open(password []byte, walletPath string) (Account, error) {
 	if err != nil {
 		return nil, err
 	}
-	a := client.GetMainAccount()
-	pk, err := a.PublicKey.EncodePoint(true)
+	return NewWithSigner(signer.NewLocal(client.GetMainAccount()))
+}
+
+// OpenSigner returns the account of which private key is held by the
+// external signer daemon of the address.  The first public key held by the
+// daemon is used if publicKey is empty.
+func OpenSigner(address, token, publicKey string) (Account, error) {
+	client, err := signer.Dial(address, token)
+	if err != nil {
+		return nil, err
+	}
+	publicKeys, err := client.PublicKeys()
 	if err != nil {
+		client.Close()
 		return nil, err
 	}
 
-	return &dAccount{Account: a, pubKey: pk}, nil
+	var pk *crypto.PublicKey
+	if publicKey == "" {
+		if len(publicKeys) == 0 {
+			client.Close()
+			return nil, errors.New("no public key held by signer daemon")
+		}
+		pk = publicKeys[0]
+	} else {
+		pkBytes, err := common.HexStringToBytes(publicKey)
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+		for _, k := range publicKeys {
+			if encoded, _ := k.EncodePoint(true); bytes.Equal(encoded, pkBytes) {
+				pk = k
+				break
+			}
+		}
+		if pk == nil {
+			client.Close()
+			return nil, errors.New("public key " + publicKey +
+				" is not held by signer daemon")
+		}
+	}
+
+	s, err := client.Signer(pk)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return NewWithSigner(s)
 }
 
 func New(a *account.Account) Account {
 	pubKey, _ := a.PublicKey.EncodePoint(true)
-	return &dAccount{Account: a, pubKey: pubKey}
+	return &dAccount{signer: signer.NewLocal(a), pubKey: pubKey}
+}
+
+// NewWithSigner returns the account signing by the signer.
+func NewWithSigner(s signer.Signer) (Account, error) {
+	pubKey, err := s.PublicKey().EncodePoint(true)
+	if err != nil {
+		return nil, err
+	}
+	return &dAccount{signer: s, pubKey: pubKey}, nil
 }
@@ -0,0 +1,73 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package account
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/account"
+	"github.com/elastos/Elastos.ELA/account/signer"
+	"github.com/elastos/Elastos.ELA/account/signer/signertest"
+	"github.com/elastos/Elastos.ELA/common"
+	common2 "github.com/elastos/Elastos.ELA/core/types/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/crypto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenSigner(t *testing.T) {
+	arbiter, err := account.NewAccount()
+	assert.NoError(t, err)
+	other, err := account.NewAccount()
+	assert.NoError(t, err)
+	server, err := signertest.NewServer("token", signer.Policy{}, other,
+		arbiter)
+	assert.NoError(t, err)
+	defer server.Close()
+
+	pubKey, err := arbiter.PublicKey.EncodePoint(true)
+	assert.NoError(t, err)
+	acc, err := OpenSigner(server.Address, "token",
+		common.BytesToHexString(pubKey))
+	assert.NoError(t, err)
+	assert.Equal(t, pubKey, acc.PublicKeyBytes())
+
+	// proposals and votes are signed by the signer daemon
+	header := &common2.Header{Height: 10}
+	proposal := &payload.DPOSProposal{Sponsor: pubKey,
+		BlockHash: header.Hash(), ViewOffset: 1}
+	proposal.Sign, err = acc.SignProposal(proposal, header)
+	assert.NoError(t, err)
+	assert.NoError(t, crypto.Verify(*arbiter.PublicKey, proposal.Data(),
+		proposal.Sign))
+	vote := &payload.DPOSProposalVote{ProposalHash: proposal.Hash(),
+		Signer: pubKey, Accept: true}
+	vote.Sign, err = acc.SignVote(vote, proposal, header)
+	assert.NoError(t, err)
+	assert.NoError(t, crypto.Verify(*arbiter.PublicKey, vote.Data(), vote.Sign))
+	assert.Equal(t, 2, len(server.Requests()))
+
+	cipher, err := crypto.Encrypt(arbiter.PublicKey, []byte("127.0.0.1:20339"))
+	assert.NoError(t, err)
+	addr, err := acc.DecryptAddr(cipher)
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:20339", addr)
+
+	// the first public key held by daemon is used by default
+	acc, err = OpenSigner(server.Address, "token", "")
+	assert.NoError(t, err)
+	assert.True(t, crypto.Equal(other.PublicKey, acc.PublicKey()))
+
+	notHeld, err := account.NewAccount()
+	assert.NoError(t, err)
+	notHeldKey, _ := notHeld.PublicKey.EncodePoint(true)
+	_, err = OpenSigner(server.Address, "token",
+		common.BytesToHexString(notHeldKey))
+	assert.Error(t, err)
+	_, err = OpenSigner(server.Address, "", "")
+	assert.Error(t, err)
+}
@@ -43,6 +43,7 @@ type Config struct {
 	AnnounceAddr   func()
 	NodeVersion    string
 	Addr           string
+	Performance    *state.PerformanceTracker
 }
 
 type Arbitrator struct {
@@ -257,6 +258,7 @@ func NewArbitrator(account account.Account, cfg Config) (*Arbitrator, error) {
 		ChainParams: cfg.ChainParams,
 		TimeSource:  medianTime,
 		Server:      cfg.Server,
+		Performance: cfg.Performance,
 	})
 
 	network, err := NewDposNetwork(NetworkConfig{
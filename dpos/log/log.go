@@ -16,12 +16,18 @@ const (
 	dposLogPath = "logs/dpos/"
 )
 
+// logger is used by the package level functions, which skips the stack frame
+// of the functions to get the callsite.
 var logger *elaLog.Logger
 
-func Init(dir string, level uint8, maxPerLogSizeMb, maxLogsSizeMb int64) {
-	path := filepath.Join(dir, dposLogPath)
-	logger = elaLog.NewLogger(path, level, maxPerLogSizeMb, maxLogsSizeMb)
-	p2p.UseLogger(logger)
+// Init creates the DPoS logger writing to the dpos log folder in dir with the
+// configuration, the logger is the "dpos" subsystem.
+func Init(dir string, cfg elaLog.Config) {
+	cfg.Path = filepath.Join(dir, dposLogPath)
+	cfg.Subsystem = "dpos"
+	l := elaLog.New(&cfg)
+	logger = l.CallerSkip(1)
+	p2p.UseLogger(l)
 }
 
 func Debug(a ...interface{}) {
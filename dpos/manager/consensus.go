@@ -43,6 +43,7 @@ func NewConsensus(manager *DPOSManager, tolerance time.Duration,
 			signTolerance:      tolerance,
 			listener:           viewListener,
 			arbitrators:        manager.arbitrators,
+			performance:        manager.performance,
 			changeViewV1Height: changeViewV1Height,
 		},
 	}
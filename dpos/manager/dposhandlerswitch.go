@@ -108,10 +108,21 @@ func (h *DPOSHandlerSwitch) ProcessProposal(id peer.PID, p *payload.DPOSProposal
 		Result:       false,
 	}
 	h.cfg.Monitor.OnProposalArrived(&proposalEvent)
+	if handled && h.isPerformanceTracked(p.Sponsor) {
+		h.cfg.Manager.performance.OnProposalReceived(p.Sponsor)
+	}
 
 	return handled
 }
 
+// isPerformanceTracked returns whether the proposals and votes of the node
+// public key are counted to the performance tracker, only the current arbiters
+// are tracked so the peers are not able to add producers with fake keys.
+func (h *DPOSHandlerSwitch) isPerformanceTracked(nodePublicKey []byte) bool {
+	return h.cfg.Manager.performance != nil &&
+		h.cfg.Manager.GetArbitrators().IsArbitrator(nodePublicKey)
+}
+
 func (h *DPOSHandlerSwitch) ChangeView(firstBlockHash *common.Uint256) {
 	h.currentHandler.ChangeView(firstBlockHash)
 	h.proposalDispatcher.eventAnalyzer.IncreaseLastConsensusViewCount()
@@ -150,6 +161,9 @@ func (h *DPOSHandlerSwitch) ProcessAcceptVote(id peer.PID, p *payload.DPOSPropos
 		ReceivedTime: h.cfg.TimeSource.AdjustedTime(), Result: true, RawData: p}
 	h.cfg.Monitor.OnVoteArrived(&voteEvent)
 	h.proposalDispatcher.eventAnalyzer.AppendConsensusVote(p)
+	if succeed && h.isPerformanceTracked(p.Signer) {
+		h.cfg.Manager.performance.OnVoteReceived(p.Signer)
+	}
 
 	return succeed, finished
 }
@@ -161,6 +175,9 @@ func (h *DPOSHandlerSwitch) ProcessRejectVote(id peer.PID, p *payload.DPOSPropos
 		ReceivedTime: h.cfg.TimeSource.AdjustedTime(), Result: false, RawData: p}
 	h.cfg.Monitor.OnVoteArrived(&voteEvent)
 	h.proposalDispatcher.eventAnalyzer.AppendConsensusVote(p)
+	if succeed && h.isPerformanceTracked(p.Signer) {
+		h.cfg.Manager.performance.OnVoteReceived(p.Signer)
+	}
 
 	return succeed, finished
 }
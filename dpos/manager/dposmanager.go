@@ -110,6 +110,7 @@ type DPOSManagerConfig struct {
 	ChainParams *config.Configuration
 	TimeSource  dtime.MedianTimeSource
 	Server      elanet.Server
+	Performance *state.PerformanceTracker
 }
 
 type DPOSManager struct {
@@ -131,6 +132,7 @@ type DPOSManager struct {
 	timeSource  dtime.MedianTimeSource
 	server      elanet.Server
 	broadcast   func(p2p.Message)
+	performance *state.PerformanceTracker
 
 	recoverStarted     bool
 	notHandledProposal map[string]struct{}
@@ -155,6 +157,7 @@ func NewManager(cfg DPOSManagerConfig) *DPOSManager {
 		chainParams:        cfg.ChainParams,
 		timeSource:         cfg.TimeSource,
 		server:             cfg.Server,
+		performance:        cfg.Performance,
 		notHandledProposal: make(map[string]struct{}),
 		statusMap:          make(map[uint32]map[string]*dmsg.ConsensusStatus),
 		requestedBlocks:    make(map[common.Uint256]struct{}),
@@ -0,0 +1,48 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package manager
+
+import (
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/utils/metrics"
+)
+
+var (
+	// viewChanges is the count of views changed without a block confirmed.
+	viewChanges = metrics.NewCounter("ela_dpos_view_changes_total",
+		"Number of DPoS views changed without a block confirmed.")
+
+	// proposalLatency is the time from the start of a view to the proposal
+	// of the view being processed.
+	proposalLatency = metrics.NewHistogram("ela_dpos_proposal_latency_seconds",
+		"Time from the start of a view to its proposal being processed.",
+		metrics.DefaultBuckets)
+
+	// voteLatency is the time from a proposal being processed to its votes
+	// being counted.
+	voteLatency = metrics.NewHistogram("ela_dpos_vote_latency_seconds",
+		"Time from a proposal being processed to its votes being counted.",
+		metrics.DefaultBuckets, "accept")
+)
+
+func init() {
+	metrics.Register(viewChanges, proposalLatency, voteLatency)
+}
+
+// observeVoteLatency records the time from the processing proposal being
+// set to the vote of it being counted.
+func (p *ProposalDispatcher) observeVoteLatency(v *payload.DPOSProposalVote) {
+	if p.processingProposal == nil ||
+		!v.ProposalHash.IsEqual(p.processingProposal.Hash()) {
+		return
+	}
+	accept := "false"
+	if v.Accept {
+		accept = "true"
+	}
+	voteLatency.Observe(p.cfg.TimeSource.AdjustedTime().Sub(
+		p.proposalTime).Seconds(), accept)
+}
@@ -8,6 +8,7 @@ package manager
 import (
 	"bytes"
 	"errors"
+	"time"
 
 	"github.com/elastos/Elastos.ELA/benchmark/common/utils"
 
@@ -55,6 +56,7 @@ type ProposalDispatcher struct {
 	finishedBlockHash   common.Uint256
 	processingBlock     *types.Block
 	processingProposal  *payload.DPOSProposal
+	proposalTime        time.Time
 	acceptVotes         map[common.Uint256]*payload.DPOSProposalVote
 	rejectedVotes       map[common.Uint256]*payload.DPOSProposalVote
 	pendingProposals    map[common.Uint256]*payload.DPOSProposal
@@ -147,7 +149,7 @@ func (p *ProposalDispatcher) StartProposal(b *types.Block) {
 	proposal := &payload.DPOSProposal{Sponsor: p.cfg.Manager.GetPublicKey(),
 		BlockHash: b.Hash(), ViewOffset: p.cfg.Consensus.GetViewOffset()}
 	var err error
-	proposal.Sign, err = p.cfg.Account.SignProposal(proposal)
+	proposal.Sign, err = p.cfg.Account.SignProposal(proposal, &b.Header)
 	if err != nil {
 		log.Error("[StartProposal] start proposal failed:", err.Error())
 		return
@@ -687,7 +689,7 @@ func (p *ProposalDispatcher) OnResponseResetViewReceived(msg *dmsg.ResetView) {
 	}
 
 	if err := crypto.Verify(*pk, data.Bytes(), sign); err != nil {
-		log.Errorf("invalid message signature:", *msg)
+		log.Errorf("invalid message signature: %v", *msg)
 		return
 	}
 
@@ -817,6 +819,7 @@ func (p *ProposalDispatcher) countAcceptedVote(v *payload.DPOSProposalVote) (
 	if v.Accept {
 		log.Info("[countAcceptedVote] Received needed sign, collect it into AcceptVotes!")
 		p.acceptVotes[v.Hash()] = v
+		p.observeVoteLatency(v)
 
 		if p.cfg.Manager.GetArbitrators().HasArbitersMajorityCount(len(p.acceptVotes)) {
 			log.Info("Collect majority signs, finish proposal.")
@@ -836,6 +839,7 @@ func (p *ProposalDispatcher) countRejectedVote(v *payload.DPOSProposalVote) (
 	if !v.Accept {
 		log.Info("[countRejectedVote] Received invalid sign, collect it into RejectedVotes!")
 		p.rejectedVotes[v.Hash()] = v
+		p.observeVoteLatency(v)
 
 		if p.cfg.Manager.GetArbitrators().HasArbitersMinorityCount(len(p.rejectedVotes)) {
 			p.CleanProposals(true)
@@ -862,7 +866,7 @@ func (p *ProposalDispatcher) acceptProposal(d *payload.DPOSProposal) {
 	vote := &payload.DPOSProposalVote{ProposalHash: d.Hash(),
 		Signer: p.cfg.Manager.GetPublicKey(), Accept: true}
 	var err error
-	vote.Sign, err = p.cfg.Account.SignVote(vote)
+	vote.Sign, err = p.cfg.Account.SignVote(vote, d, &p.processingBlock.Header)
 	if err != nil {
 		log.Error("[acceptProposal] sign failed")
 		return
@@ -888,10 +892,15 @@ func (p *ProposalDispatcher) rejectProposal(d *payload.DPOSProposal) {
 	if p.setProcessingProposal(d) {
 		return
 	}
+	block, ok := p.cfg.Manager.GetBlockCache().TryGetValue(d.BlockHash)
+	if !ok {
+		log.Error("[rejectProposal] can't find block")
+		return
+	}
 	vote := &payload.DPOSProposalVote{ProposalHash: d.Hash(),
 		Signer: p.cfg.Manager.GetPublicKey(), Accept: false}
 	var err error
-	vote.Sign, err = p.cfg.Account.SignVote(vote)
+	vote.Sign, err = p.cfg.Account.SignVote(vote, d, &block.Header)
 	if err != nil {
 		log.Error("[rejectProposal] sign failed")
 		return
@@ -899,11 +908,6 @@ func (p *ProposalDispatcher) rejectProposal(d *payload.DPOSProposal) {
 	msg := &dmsg.Vote{Command: dmsg.CmdRejectVote, Vote: *vote}
 	log.Info("[rejectProposal] send rej_vote msg:", dmsg.GetMessageHash(msg))
 
-	_, ok := p.cfg.Manager.GetBlockCache().TryGetValue(d.BlockHash)
-	if !ok {
-		log.Error("[rejectProposal] can't find block")
-		return
-	}
 	p.ProcessVote(vote, false)
 	p.cfg.Network.BroadcastMessage(msg)
 
@@ -915,6 +919,9 @@ func (p *ProposalDispatcher) rejectProposal(d *payload.DPOSProposal) {
 
 func (p *ProposalDispatcher) setProcessingProposal(d *payload.DPOSProposal) (finished bool) {
 	p.processingProposal = d
+	p.proposalTime = p.cfg.TimeSource.AdjustedTime()
+	proposalLatency.Observe(p.proposalTime.Sub(
+		p.cfg.Consensus.currentView.GetViewStartTime()).Seconds())
 
 	for _, v := range p.pendingVotes {
 		if v.ProposalHash.IsEqual(d.Hash()) {
@@ -995,6 +1002,10 @@ func (p *ProposalDispatcher) CreateInactiveArbitrators() (
 	if len(inactivePayload.Arbitrators) == 0 {
 		return nil, errors.New("found no inactive arbiters")
 	}
+	if p.cfg.Manager.performance != nil {
+		p.cfg.Manager.performance.OnInactiveArbitersDetected(
+			inactiveArbitrators)
+	}
 
 	con := contract.Contract{Prefix: contract.PrefixMultiSig}
 	if con.Code, err = p.createArbitratorsRedeemScript(); err != nil {
@@ -31,6 +31,7 @@ type view struct {
 	isDposOnDuty       bool
 	changeViewV1Height uint32
 	arbitrators        state.Arbitrators
+	performance        *state.PerformanceTracker
 
 	listener ViewListener
 }
@@ -53,6 +54,7 @@ func (v *view) ResetView(t time.Time) {
 
 func (v *view) ChangeView(viewOffset *uint32, now time.Time) {
 	offset, offsetTime := v.calculateOffsetTimeV0(v.viewStartTime, now)
+	v.recordViewChanges(*viewOffset, *viewOffset+offset)
 	*viewOffset += offset
 
 	v.viewStartTime = now.Add(-offsetTime)
@@ -76,6 +78,7 @@ func (v *view) ChangeViewV1(viewOffset *uint32, now time.Time) bool {
 		return false
 	}
 	log.Info("ChangeView succeed, offset from:", *viewOffset, "to:", offset)
+	v.recordViewChanges(*viewOffset, offset)
 
 	*viewOffset = offset
 	v.viewStartTime = now.Add(-offsetTime)
@@ -93,6 +96,24 @@ func (v *view) ChangeViewV1(viewOffset *uint32, now time.Time) bool {
 	return true
 }
 
+// recordViewChanges counts the view changes, and records the on duty arbiters
+// of the views from the previous offset to the current one, which have been
+// changed without a block confirmed.
+func (v *view) recordViewChanges(from, to uint32) {
+	viewChanges.Add(float64(to - from))
+	if v.performance == nil {
+		return
+	}
+	count := uint32(v.arbitrators.GetArbitersCount())
+	if to-from > count {
+		from = to - count
+	}
+	for offset := from; offset < to; offset++ {
+		v.performance.OnViewChanged(
+			v.arbitrators.GetNextOnDutyArbitrator(offset))
+	}
+}
+
 func (v *view) calculateOffsetTimeV0(startTime time.Time,
 	now time.Time) (uint32, time.Duration) {
 	duration := now.Sub(startTime)
@@ -25,6 +25,7 @@ import (
 	"github.com/elastos/Elastos.ELA/dpos/p2p/peer"
 	"github.com/elastos/Elastos.ELA/mempool"
 	elap2p "github.com/elastos/Elastos.ELA/p2p"
+	"github.com/elastos/Elastos.ELA/p2p/connmgr"
 	elamsg "github.com/elastos/Elastos.ELA/p2p/msg"
 	peer2 "github.com/elastos/Elastos.ELA/p2p/peer"
 )
@@ -394,6 +395,8 @@ func NewDposNetwork(cfg NetworkConfig) (*network, error) {
 		DPoSV2StartHeight: cfg.ChainParams.DPoSV2StartHeight,
 		NodeVersion:       cfg.NodeVersion,
 		Addr:              cfg.Addr,
+		Proxy:             newProxy(cfg.ChainParams, cfg.ChainParams.Proxy),
+		OnionProxy:        newProxy(cfg.ChainParams, cfg.ChainParams.OnionProxy),
 	})
 	if err != nil {
 		return nil, err
@@ -403,6 +406,20 @@ func NewDposNetwork(cfg NetworkConfig) (*network, error) {
 	return network, nil
 }
 
+// newProxy returns the proxy of the address with the configured credentials,
+// or nil if the address is empty.
+func newProxy(params *config.Configuration, addr string) *connmgr.Proxy {
+	if addr == "" {
+		return nil
+	}
+	return &connmgr.Proxy{
+		Addr:         addr,
+		Username:     params.ProxyUser,
+		Password:     params.ProxyPass,
+		TorIsolation: params.TorIsolation,
+	}
+}
+
 func createMessage(hdr elap2p.Header, r net.Conn) (message elap2p.Message, err error) {
 	switch hdr.GetCMD() {
 	case elap2p.CmdBlock:
@@ -25,6 +25,7 @@ type AddrManager struct {
 	mtx       sync.Mutex
 	peersFile string
 	addrIndex map[[33]byte]net.Addr // address key to ka for all addrs.
+	encrypted map[[33]byte]struct{} // peers connected with encryption.
 	started   int32
 	shutdown  int32
 	wg        sync.WaitGroup
@@ -38,6 +39,7 @@ type serializedNetAddress struct {
 
 type serializedAddrManager struct {
 	Addresses []*serializedNetAddress
+	Encrypted [][33]byte `json:",omitempty"`
 }
 
 const (
@@ -95,6 +97,9 @@ func (a *AddrManager) savePeers() {
 		// and will be worked out from context on unserialisation.
 		sam.Addresses = append(sam.Addresses, ska)
 	}
+	for pid := range a.encrypted {
+		sam.Encrypted = append(sam.Encrypted, pid)
+	}
 
 	enc := json.NewEncoder(w)
 	if err := enc.Encode(&sam); err != nil {
@@ -149,6 +154,9 @@ func (a *AddrManager) deserializePeers(filePath string) error {
 		}
 		a.addrIndex[v.PID] = na
 	}
+	for _, pid := range sam.Encrypted {
+		a.encrypted[pid] = struct{}{}
+	}
 
 	return nil
 }
@@ -237,6 +245,25 @@ func (a *AddrManager) HostToNetAddress(host string, port uint16, services uint64
 	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
 }
 
+// SetEncrypted marks the peer has connected with the encrypted transport, the
+// mark is saved along with the addresses.
+func (a *AddrManager) SetEncrypted(pid [33]byte) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.encrypted[pid] = struct{}{}
+}
+
+// IsEncrypted returns whether the peer has connected with the encrypted
+// transport before.
+func (a *AddrManager) IsEncrypted(pid [33]byte) bool {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	_, ok := a.encrypted[pid]
+	return ok
+}
+
 // GetAddress returns the network address according to the given PID and Encode.
 func (a *AddrManager) GetAddress(pid [33]byte) net.Addr {
 	// Protect concurrent access.
@@ -252,6 +279,7 @@ func New(dataDir string) *AddrManager {
 	am := AddrManager{
 		peersFile: filepath.Join(dataDir, "peers.json"),
 		addrIndex: make(map[[33]byte]net.Addr),
+		encrypted: make(map[[33]byte]struct{}),
 		quit:      make(chan struct{}),
 	}
 	return &am
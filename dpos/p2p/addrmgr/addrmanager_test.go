@@ -76,3 +76,26 @@ func TestLoadPeers(t *testing.T) {
 
 	am.Start()
 }
+
+func TestEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	am := addrmgr.New(dir)
+	am.Start()
+
+	var pid, other [33]byte
+	rand.Read(pid[:])
+	rand.Read(other[:])
+	am.SetEncrypted(pid)
+	if !am.IsEncrypted(pid) || am.IsEncrypted(other) {
+		t.Fatalf("IsEncrypted: unexpected result")
+	}
+	am.Stop()
+
+	// The encrypted peers are loaded after restart.
+	am = addrmgr.New(dir)
+	am.Start()
+	defer am.Stop()
+	if !am.IsEncrypted(pid) || am.IsEncrypted(other) {
+		t.Errorf("IsEncrypted: unexpected result after restart")
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/elastos/Elastos.ELA/dpos/dtime"
 	"github.com/elastos/Elastos.ELA/dpos/p2p/peer"
 	"github.com/elastos/Elastos.ELA/p2p"
+	"github.com/elastos/Elastos.ELA/p2p/connmgr"
 )
 
 const (
@@ -85,6 +86,14 @@ type Config struct {
 
 	// connection address of myself
 	Addr string
+
+	// Proxy is the SOCKS5 proxy to connect to other arbiters through, the
+	// connections are made directly if it is nil.
+	Proxy *connmgr.Proxy
+
+	// OnionProxy is the SOCKS5 proxy to connect to onion addresses of other
+	// arbiters through, the Proxy is used if it is nil.
+	OnionProxy *connmgr.Proxy
 }
 
 // normalizeAddress returns addr with the passed default port appended if
@@ -116,6 +116,50 @@ func WrapConn(c net.Conn) (conn *Conn, err error) {
 	return
 }
 
+// disableEncryption clears the encryption service of the intercepted version
+// message.  The hub relays messages frame by frame, so the connections piped
+// through it must stay plaintext.  Both sides of a pipe see the other without
+// the encryption service and sign the legacy verack, a side requiring the
+// encryption of the remote peer refuses the pipe.
+func (c *Conn) disableEncryption() error {
+	frame, err := plainVersion(c.buf.Bytes())
+	if err != nil {
+		return err
+	}
+	c.buf = bytes.NewBuffer(frame)
+	return nil
+}
+
+// plainVersion returns the version message frame with the encryption service
+// cleared.
+func plainVersion(frame []byte) ([]byte, error) {
+	var hdr p2p.Header
+	if err := hdr.Deserialize(frame[:p2p.HeaderSize]); err != nil {
+		return nil, err
+	}
+
+	v := &msg.Version{}
+	err := v.Deserialize(bytes.NewReader(frame[p2p.HeaderSize:]))
+	if err != nil {
+		return nil, err
+	}
+	if v.Services&msg.SFNodeEncryption == 0 {
+		return frame, nil
+	}
+	v.Services &^= msg.SFNodeEncryption
+
+	buf := new(bytes.Buffer)
+	if err := v.Serialize(buf); err != nil {
+		return nil, err
+	}
+	payload := buf.Bytes()
+	header, err := p2p.BuildHeader(hdr.Magic, p2p.CmdVersion, payload).Serialize()
+	if err != nil {
+		return nil, err
+	}
+	return append(header, payload...), nil
+}
+
 // newNetAddr creates a net.Addr with the origin net.Addr and port.
 func newNetAddr(addr net.Addr, port uint16) net.Addr {
 	// addr will be a net.TCPAddr when not using a proxy.
@@ -122,6 +122,11 @@ func (p *pipe) flow(manager *addrmgr.AddrManager, from net.Conn, to net.Conn, co
 		} else {
 			hdrData := headerBytes[:]
 			data := append(hdrData, payload...)
+			if hdr.GetCMD() == p2p.CmdVersion {
+				if data, err = plainVersion(data); err != nil {
+					return err
+				}
+			}
 			_, err = to.Write(data[:])
 		}
 
@@ -384,6 +389,13 @@ func (h *Hub) Intercept(conn net.Conn) net.Conn {
 		return c
 	}
 
+	// The connection is piped to another service, do not negotiate the
+	// encrypted transport.
+	if err := c.disableEncryption(); err != nil {
+		_ = conn.Close()
+		return nil
+	}
+
 	// The connection come from our own service.
 	if h.pid.Equal(c.PID()) {
 		h.queue <- outbound(c)
@@ -52,7 +52,8 @@ func mockService(port int) (chan net.Conn, error) {
 
 // sendVersion write a version message to the connection.
 func sendVersion(conn net.Conn, magic int, pid, target [33]byte, port int) error {
-	v := msg.Version{PID: pid, Target: PIDTo16(target), Port: uint16(port)}
+	v := msg.Version{PID: pid, Target: PIDTo16(target), Port: uint16(port),
+		Services: msg.SFNodeEncryption}
 	return p2p.WriteMessage(conn, uint32(magic), &v, p2p.WriteMessageTimeOut,
 		func(m p2p.Message) (*types.DposBlock, bool) {
 			msgBlock, ok := m.(*pmsg.Block)
@@ -64,7 +65,8 @@ func sendVersion(conn net.Conn, magic int, pid, target [33]byte, port int) error
 		})
 }
 
-func readVersion(conn net.Conn, magic int, pid, target [33]byte, port int) error {
+func readVersion(conn net.Conn, magic int, pid, target [33]byte, port int,
+	services uint64) error {
 	m, err := p2p.ReadMessage(conn, uint32(magic), p2p.ReadMessageTimeOut,
 		func(hdr p2p.Header, r net.Conn) (m p2p.Message, err error) {
 			if hdr.GetCMD() != msg.CmdVersion {
@@ -85,6 +87,9 @@ func readVersion(conn net.Conn, magic int, pid, target [33]byte, port int) error
 	if v.Port != uint16(port) {
 		return fmt.Errorf("port not match")
 	}
+	if v.Services != services {
+		return fmt.Errorf("services not match")
+	}
 	return nil
 }
 
@@ -102,6 +107,7 @@ func TestPidTo16(t *testing.T) {
 // service.
 // 3. Outbound connection from sub service is intercepted and redirect to remote
 // sub service.
+// The encryption service is cleared from the intercepted connections.
 func TestHub_Intercept(t *testing.T) {
 	var mainID, subID, someID [33]byte
 	rand.Read(mainID[:])
@@ -142,7 +148,8 @@ func TestHub_Intercept(t *testing.T) {
 		t.Fatal(err)
 	}
 	mainSvrConn := hub.Intercept(<-mainSvr)
-	err = readVersion(mainSvrConn, mainMagic, someID, mainID, somePort)
+	err = readVersion(mainSvrConn, mainMagic, someID, mainID, somePort,
+		msg.SFNodeEncryption)
 	if !assert.NoError(t, err) {
 		t.Fatal(err)
 	}
@@ -160,7 +167,7 @@ func TestHub_Intercept(t *testing.T) {
 		t.Fatal(err)
 	}
 	remoteSvrConn := <-remoteSvr
-	err = readVersion(remoteSvrConn, subMagic, mainID, subID, subPort)
+	err = readVersion(remoteSvrConn, subMagic, mainID, subID, subPort, 0)
 	if !assert.NoError(t, err) {
 		t.Fatal(err)
 	}
@@ -178,7 +185,7 @@ func TestHub_Intercept(t *testing.T) {
 		t.Fatal(err)
 	}
 	localSubSvrConn := <-subSvr
-	err = readVersion(localSubSvrConn, subMagic, someID, subID, somePort)
+	err = readVersion(localSubSvrConn, subMagic, someID, subID, somePort, 0)
 	if !assert.NoError(t, err) {
 		t.Fatal(err)
 	}
@@ -189,7 +196,8 @@ func TestHub_Intercept(t *testing.T) {
 		if !assert.NoError(t, err) {
 			t.Fatal(err)
 		}
-		err = readVersion(localSubSvrConn, subMagic, someID, subID, somePort)
+		err = readVersion(localSubSvrConn, subMagic, someID, subID, somePort,
+			0)
 		if !assert.NoError(t, err) {
 			t.Fatal(err)
 		}
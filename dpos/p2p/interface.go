@@ -78,6 +78,9 @@ type PeerInfo struct {
 
 	//NodeVersion
 	NodeVersion string
+
+	// Encrypted indicates the connections to the peer are encrypted.
+	Encrypted bool
 }
 
 // StateNotifier notifies the server peer state changes.
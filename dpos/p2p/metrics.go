@@ -0,0 +1,83 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package p2p
+
+import (
+	"github.com/elastos/Elastos.ELA/utils/metrics"
+)
+
+// peerStats is the statistics of the peers known to peerState.
+type peerStats struct {
+	inbound       int
+	outbound      int
+	bytesSent     uint64
+	bytesReceived uint64
+}
+
+type getPeerStatsMsg struct {
+	reply chan peerStats
+}
+
+// stats returns the statistics of the peers, the bytes of the peers done are
+// included to keep the byte counters monotonic.
+func (ps *peerState) stats() peerStats {
+	stats := peerStats{
+		bytesSent:     ps.bytesSent,
+		bytesReceived: ps.bytesReceived,
+	}
+	ps.forAllPeers(func(sp *serverPeer) {
+		stats.bytesSent += sp.BytesSent()
+		stats.bytesReceived += sp.BytesReceived()
+		if !sp.Connected() {
+			return
+		}
+		if sp.Inbound() {
+			stats.inbound++
+		} else {
+			stats.outbound++
+		}
+	})
+	return stats
+}
+
+// peerStats returns the statistics of the peers, or empty statistics if the
+// server has been shut down.
+func (s *server) peerStats() peerStats {
+	replyChan := make(chan peerStats, 1)
+	select {
+	case s.query <- getPeerStatsMsg{reply: replyChan}:
+		return <-replyChan
+	case <-s.quit:
+		return peerStats{}
+	}
+}
+
+// registerMetrics registers the metrics of arbiter peers collected from the
+// server.
+func (s *server) registerMetrics() {
+	metrics.Register(
+		metrics.NewCollector("ela_dpos_p2p_peers",
+			"Number of connected arbiter peers.",
+			metrics.TypeGauge, []string{"direction"},
+			func() []metrics.Sample {
+				stats := s.peerStats()
+				return []metrics.Sample{
+					{LabelValues: []string{"inbound"}, Value: float64(stats.inbound)},
+					{LabelValues: []string{"outbound"}, Value: float64(stats.outbound)},
+				}
+			}),
+		metrics.NewCollector("ela_dpos_p2p_bytes_total",
+			"Total bytes sent to and received from arbiter peers.",
+			metrics.TypeCounter, []string{"direction"},
+			func() []metrics.Sample {
+				stats := s.peerStats()
+				return []metrics.Sample{
+					{LabelValues: []string{"received"}, Value: float64(stats.bytesReceived)},
+					{LabelValues: []string{"sent"}, Value: float64(stats.bytesSent)},
+				}
+			}),
+	)
+}
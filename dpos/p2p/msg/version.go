@@ -22,6 +22,10 @@ var _ p2p.Message = (*Version)(nil)
 const DPoSV1Version = 0x00
 const DPoSV2Version = 0x01
 
+// SFNodeEncryption is a services flag used to indicate a peer supports the
+// encrypted transport.
+const SFNodeEncryption uint64 = 1 << 0
+
 var PayloadVersionLock sync.RWMutex
 
 var PayloadVersion uint32
@@ -46,6 +50,10 @@ type Version struct {
 	Port        uint16
 	Timestamp   time.Time
 	NodeVersion string
+
+	// Services is appended by the peers supporting the encrypted transport,
+	// the legacy peers do not send it and ignore it.
+	Services uint64
 }
 
 func (msg *Version) CMD() string {
@@ -53,7 +61,7 @@ func (msg *Version) CMD() string {
 }
 
 func (msg *Version) MaxLength() uint32 {
-	return 128 // 33+16+16+2+8 + Version +NodeVersion so extend to 128
+	return 128 // 33+16+16+2+8 + Version +NodeVersion +Services so extend to 128
 }
 
 func (msg *Version) Serialize(w io.Writer) error {
@@ -67,7 +75,10 @@ func (msg *Version) Serialize(w io.Writer) error {
 	if GetPayloadVersion() >= DPoSV2Version {
 		err = common.WriteVarString(w, msg.NodeVersion)
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	return common.WriteUint64(w, msg.Services)
 }
 
 func (msg *Version) Deserialize(r io.Reader) error {
@@ -100,7 +111,13 @@ func (msg *Version) Deserialize(r io.Reader) error {
 			return err
 		}
 	}
-	return nil
+
+	msg.Services, err = common.ReadUint64(r)
+	if err == io.EOF {
+		// Legacy peers do not send services.
+		return nil
+	}
+	return err
 }
 
 func NewVersion(version uint32, pid [33]byte, target, nonce [16]byte, port uint16, nver string) *Version {
@@ -38,6 +38,9 @@ const (
 
 	// outputBufferSize is the number of elements the output channels use.
 	outputBufferSize = 50
+
+	// localServices is the services advertised in our version message.
+	localServices = msg.SFNodeEncryption
 )
 
 var (
@@ -85,6 +88,9 @@ type StatsSnap struct {
 	Inbound        bool
 	LastPingTime   time.Time
 	LastPingMicros int64
+	BytesSent      uint64
+	BytesRecv      uint64
+	Encrypted      bool
 }
 
 // MessageFunc is a message handler in peer's configuration
@@ -105,6 +111,10 @@ type Config struct {
 
 	DPoSV2StartHeight uint32
 	NodeVersion       string
+
+	// EncryptionRequired returns whether the connection to the peer must be
+	// encrypted, the plaintext connection is refused if it returns true.
+	EncryptionRequired func(pid PID) bool
 }
 
 // newNetAddress attempts to extract the IP address and port from the passed
@@ -138,6 +148,10 @@ func newNetAddress(addr net.Addr, services uint64) (*p2p.NetAddress, error) {
 type HostToNetAddrFunc func(host string, port uint16, services uint64) (*p2p.NetAddress, error)
 
 type Peer struct {
+	// stats counts the bytes of conn, it is the first field to keep the
+	// counters 64-bit aligned for atomic access.
+	stats p2p.StatsConn
+
 	// The following variables must only be used atomically.
 	lastRecv   int64
 	lastSend   int64
@@ -146,6 +160,14 @@ type Peer struct {
 
 	conn net.Conn
 
+	// crypt is the transport of conn, it starts encryption after the
+	// encryption is negotiated.
+	crypt *p2p.EncryptedConn
+
+	// session is the negotiated encryption session, it is set during the
+	// protocol negotiation and nil for the plaintext connections.
+	session *p2p.Session
+
 	// These fields are set at creation time and never modified, so they are
 	// safe to read from concurrently without a mutex.
 	addr    string
@@ -159,6 +181,7 @@ type Peer struct {
 	pk          *crypto.PublicKey
 	pid         PID
 	version     uint32 // negotiated protocol version
+	services    uint64 // services advertised by remote
 	NodeVersion string // protocol node version advertised by remote
 	// These fields keep track of statistics for the peer and are protected
 	// by the statsMtx mutex.
@@ -218,10 +241,13 @@ func (p *Peer) StatsSnapshot() *StatsSnap {
 		Addr:           addr,
 		LastSend:       p.LastSend(),
 		LastRecv:       p.LastRecv(),
+		BytesSent:      p.BytesSent(),
+		BytesRecv:      p.BytesReceived(),
 		ConnTime:       p.timeConnected,
 		Inbound:        p.inbound,
 		LastPingMicros: p.lastPingMicros,
 		LastPingTime:   p.lastPingTime,
+		Encrypted:      p.Encrypted(),
 	}
 
 	p.statsMtx.RUnlock()
@@ -288,6 +314,13 @@ func (p *Peer) Inbound() bool {
 	return p.inbound
 }
 
+// Encrypted returns whether the connection to the remote peer is encrypted.
+//
+// This function is safe for concurrent access.
+func (p *Peer) Encrypted() bool {
+	return p.crypt != nil && p.crypt.Encrypted()
+}
+
 // LastPingTime returns the last ping time of the remote peer.
 //
 // This function is safe for concurrent access.
@@ -324,6 +357,20 @@ func (p *Peer) LastRecv() time.Time {
 	return time.Unix(atomic.LoadInt64(&p.lastRecv), 0)
 }
 
+// BytesSent returns the total number of bytes sent by the peer.
+//
+// This function is safe for concurrent access.
+func (p *Peer) BytesSent() uint64 {
+	return p.stats.BytesWritten()
+}
+
+// BytesReceived returns the total number of bytes received by the peer.
+//
+// This function is safe for concurrent access.
+func (p *Peer) BytesReceived() uint64 {
+	return p.stats.BytesRead()
+}
+
 // LocalAddr returns the local address of the connection.
 //
 // This function is safe fo concurrent access.
@@ -371,6 +418,9 @@ func (p *Peer) createMessage(hdr p2p.Header, r net.Conn) (p2p.Message, error) {
 	case msg.CmdVerAck:
 		message = &msg.VerAck{}
 
+	case p2p.CmdEncInit:
+		message = &pmsg.EncInit{}
+
 	case msg.CmdAddr:
 		message = &msg.Addr{}
 
@@ -774,6 +824,7 @@ func (p *Peer) readRemoteVersionMsg() ([]byte, error) {
 	p.pk = pk
 	p.pid = verMsg.PID
 	p.version = verMsg.Version
+	p.services = verMsg.Services
 	p.NodeVersion = verMsg.NodeVersion
 	p.flagsMtx.Unlock()
 
@@ -801,7 +852,11 @@ func (p *Peer) readRemoteVerAckMsg(nonce []byte) error {
 
 	// Verify signature of the message nonce.
 	p.handleMessage(p, verAck)
-	return crypto.Verify(*p.pk, nonce, verAck.Signature[:])
+	p.flagsMtx.Lock()
+	services := p.services
+	p.flagsMtx.Unlock()
+	data := p.signData(nonce, services, localServices)
+	return crypto.Verify(*p.pk, data, verAck.Signature[:])
 }
 
 // writeLocalVersionMsg writes our version message to the remote peer.
@@ -819,15 +874,97 @@ func (p *Peer) writeLocalVersionMsg() ([]byte, error) {
 	// Version message.
 	localVerMsg := msg.NewVersion(0, p.cfg.PID, p.cfg.Target, nonce,
 		p.cfg.Port, p.cfg.NodeVersion)
+	localVerMsg.Services = localServices
 	return nonce[:], p.writeMessage(localVerMsg)
 }
 
 // writeLocalVerAckMsg writes our verack message to the remote peer.
 func (p *Peer) writeLocalVerAckMsg(nonce []byte) error {
-	localVarAck := msg.NewVerAck(p.cfg.Sign(nonce))
+	p.flagsMtx.Lock()
+	services := p.services
+	p.flagsMtx.Unlock()
+	data := p.signData(nonce, localServices, services)
+	localVarAck := msg.NewVerAck(p.cfg.Sign(data))
 	return p.writeMessage(localVarAck)
 }
 
+// signData returns the data signed in the verack message by the side sent
+// the services and received the remote services.  It is the nonce followed
+// by the services of both sides and the session ID when the connection is
+// encrypted, so the session is bound to the node public keys and the
+// encryption service cleared in one direction is detected.  The plaintext
+// connections sign the nonce only like the legacy peers, so clearing the
+// encryption service in both directions is not detected here.  It is refused
+// by EncryptionRequired for the peers connected with encryption before,
+// which are saved by the server, but the first contact with a peer is able
+// to be downgraded.
+func (p *Peer) signData(nonce []byte, services, remoteServices uint64) []byte {
+	if p.session == nil {
+		return nonce
+	}
+	data := make([]byte, 0, len(nonce)+16+len(p.session.ID))
+	data = append(data, nonce...)
+	data = binary.LittleEndian.AppendUint64(data, services)
+	data = binary.LittleEndian.AppendUint64(data, remoteServices)
+	return append(data, p.session.ID[:]...)
+}
+
+// negotiateEncryption exchanges the ephemeral keys and starts encrypting the
+// connection if the remote peer advertised the SFNodeEncryption service,
+// otherwise the connection stays plaintext for the legacy peers unless the
+// encryption is required for the peer.
+func (p *Peer) negotiateEncryption() error {
+	p.flagsMtx.Lock()
+	pid := p.pid
+	services := p.services
+	p.flagsMtx.Unlock()
+	if services&msg.SFNodeEncryption == 0 {
+		if p.cfg.EncryptionRequired != nil && p.cfg.EncryptionRequired(pid) {
+			return errors.New("disconnecting peer without encryption, " +
+				"which is required for the peer")
+		}
+		return nil
+	}
+
+	key, err := p2p.NewEphemeralKey()
+	if err != nil {
+		return err
+	}
+
+	// The outbound peer sends its key first, then the inbound peer replies.
+	if !p.inbound {
+		if err := p.writeMessage(pmsg.NewEncInit(key.Public)); err != nil {
+			return err
+		}
+	}
+
+	remoteMsg, err := p.readMessage()
+	if err != nil {
+		return err
+	}
+	encInit, ok := remoteMsg.(*pmsg.EncInit)
+	if !ok {
+		reason := "An encinit message must follow a version message"
+		rejectMsg := msg.NewReject(remoteMsg.CMD(), msg.RejectMalformed, reason)
+		p.writeMessage(rejectMsg)
+		return errors.New(reason)
+	}
+
+	if p.inbound {
+		if err := p.writeMessage(pmsg.NewEncInit(key.Public)); err != nil {
+			return err
+		}
+	}
+
+	session, err := p2p.NewSession(key, encInit.PubKey, p.cfg.Magic,
+		!p.inbound)
+	if err != nil {
+		return err
+	}
+	p.session = session
+	return p.crypt.Encrypt(session)
+}
+
 // negotiateInboundProtocol waits to receive a version message from the peer
 // then sends our version message. If the events do not occur in that order then
 // it returns an error.
@@ -842,6 +979,10 @@ func (p *Peer) negotiateInboundProtocol() error {
 		return err
 	}
 
+	if err := p.negotiateEncryption(); err != nil {
+		return err
+	}
+
 	if err := p.writeLocalVerAckMsg(theirNonce); err != nil {
 		return err
 	}
@@ -863,6 +1004,10 @@ func (p *Peer) negotiateOutboundProtocol() error {
 		return err
 	}
 
+	if err := p.negotiateEncryption(); err != nil {
+		return err
+	}
+
 	if err := p.readRemoteVerAckMsg(ourNonce); err != nil {
 		return err
 	}
@@ -910,7 +1055,9 @@ func (p *Peer) AssociateConnection(conn net.Conn) {
 		return
 	}
 
-	p.conn = conn
+	p.stats.Conn = conn
+	p.crypt = p2p.NewEncryptedConn(&p.stats)
+	p.conn = p.crypt
 	p.timeConnected = time.Now()
 
 	if p.inbound {
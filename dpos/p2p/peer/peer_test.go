@@ -8,9 +8,11 @@ package peer_test
 
 import (
 	"crypto/rand"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"testing"
 	"time"
@@ -30,6 +32,8 @@ func createMessage(hdr p2p.Header, r net.Conn) (message p2p.Message, err error)
 		message = &msg.Version{}
 	case msg.CmdVerAck:
 		message = &msg.VerAck{}
+	case p2p.CmdEncInit:
+		message = &pmsg.EncInit{}
 	default:
 		err = fmt.Errorf("unknown message type %s", hdr.GetCMD())
 	}
@@ -262,6 +266,9 @@ func TestPeerConnection(t *testing.T) {
 		time.Sleep(time.Millisecond)
 		testPeer(t, inPeer, wantStats2)
 		testPeer(t, outPeer, wantStats1)
+		if !inPeer.Encrypted() || !outPeer.Encrypted() {
+			t.Errorf("TestPeerConnection #%d: connection is not encrypted", i)
+		}
 
 		inPeer.Disconnect()
 		outPeer.Disconnect()
@@ -383,3 +390,172 @@ func TestUnsupportedVersionPeer(t *testing.T) {
 		t.Fatal("Timeout waiting for remote reader to close")
 	}
 }
+
+// TestPeerEncryption tests the encryption negotiation with a remote peer
+// advertising the given services, and the verack signatures are bound to the
+// encryption session.
+func TestPeerEncryption(t *testing.T) {
+	tests := []struct {
+		name      string
+		services  uint64
+		bindNonce bool
+		received  uint64
+		required  bool
+		wantErr   bool
+	}{
+		{"legacy peer", 0, false, 0, false, false},
+		{"encrypted peer", msg.SFNodeEncryption, true,
+			msg.SFNodeEncryption, false, false},
+		{"unbound signature", msg.SFNodeEncryption, false,
+			msg.SFNodeEncryption, false, true},
+		{"cleared services", msg.SFNodeEncryption, true, 0, false, true},
+		{"required encryption", msg.SFNodeEncryption, true,
+			msg.SFNodeEncryption, true, false},
+		{"required plaintext", 0, false, 0, true, true},
+	}
+	for _, test := range tests {
+		verack := make(chan struct{}, 1)
+		peerCfg := peerConfig(123123, verack)
+		required := test.required
+		peerCfg.EncryptionRequired = func(pid peer.PID) bool {
+			return required
+		}
+		localConn, remoteConn := pipe(
+			&conn{laddr: "10.0.0.1:8333", raddr: "10.0.0.2:8333"},
+			&conn{laddr: "10.0.0.2:8333", raddr: "10.0.0.1:8333"},
+		)
+		p, err := peer.NewOutboundPeer(peerCfg, "10.0.0.2:8333")
+		if err != nil {
+			t.Fatalf("NewOutboundPeer: unexpected err - %v\n", err)
+		}
+		p.AssociateConnection(localConn)
+
+		// The peer disconnects without sending verack if our signature is
+		// not accepted.
+		handshakeErr := make(chan error, 1)
+		go func(services, received uint64, bindNonce bool) {
+			handshakeErr <- remoteHandshake(remoteConn, peerCfg, services,
+				bindNonce, received)
+		}(test.services, test.received, test.bindNonce)
+
+		// The peer refusing plaintext disconnects before verack.
+		if !test.required || test.services != 0 {
+			select {
+			case <-verack:
+			case <-time.After(time.Second):
+				t.Fatalf("%s: verack timeout", test.name)
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		if p.Connected() == test.wantErr {
+			t.Errorf("%s: got connected %v", test.name, p.Connected())
+		}
+		if !test.wantErr && p.Encrypted() != (test.services != 0) {
+			t.Errorf("%s: got encrypted %v", test.name, p.Encrypted())
+		}
+		p.Disconnect()
+
+		// Drain the messages written after the peer disconnected.
+		go io.Copy(ioutil.Discard, localConn)
+		if err := <-handshakeErr; err != nil && !test.wantErr {
+			t.Errorf("%s: handshake failed %v", test.name, err)
+		}
+	}
+}
+
+// remoteHandshake plays the inbound side of the handshake with the outbound
+// peer using the given config.  If bindNonce is false, the verack signature
+// does not cover the encryption session.  The verack signature covers the
+// received services of the outbound peer.
+func remoteHandshake(c net.Conn, peerCfg *peer.Config, services uint64,
+	bindNonce bool, received uint64) error {
+	priKey, pubKey, _ := crypto.GenerateKeyPair()
+	var pid peer.PID
+	ePubKey, _ := pubKey.EncodePoint(true)
+	copy(pid[:], ePubKey)
+
+	read := func(conn net.Conn) (p2p.Message, error) {
+		return p2p.ReadMessage(conn, peerCfg.Magic, p2p.ReadMessageTimeOut,
+			createMessage)
+	}
+	write := func(conn net.Conn, m p2p.Message) error {
+		return p2p.WriteMessage(conn, peerCfg.Magic, m,
+			p2p.WriteMessageTimeOut,
+			func(m p2p.Message) (*types.DposBlock, bool) {
+				return nil, false
+			})
+	}
+
+	m, err := read(c)
+	if err != nil {
+		return err
+	}
+	theirVersion, ok := m.(*msg.Version)
+	if !ok {
+		return fmt.Errorf("expected version message, got [%s]", m.CMD())
+	}
+
+	var nonce, target [16]byte
+	rand.Read(nonce[:])
+	version := msg.NewVersion(0, pid, target, nonce, 8333, "")
+	version.Services = services
+	if err := write(c, version); err != nil {
+		return err
+	}
+
+	var sessionID []byte
+	conn := p2p.NewEncryptedConn(c)
+	if services&msg.SFNodeEncryption != 0 {
+		m, err := read(conn)
+		if err != nil {
+			return err
+		}
+		encInit, ok := m.(*pmsg.EncInit)
+		if !ok {
+			return fmt.Errorf("expected encinit message, got [%s]", m.CMD())
+		}
+		key, _ := p2p.NewEphemeralKey()
+		if err := write(conn, pmsg.NewEncInit(key.Public)); err != nil {
+			return err
+		}
+		session, err := p2p.NewSession(key, encInit.PubKey, peerCfg.Magic,
+			false)
+		if err != nil {
+			return err
+		}
+		conn.Encrypt(session)
+		sessionID = session.ID[:]
+	}
+
+	signData := func(nonce []byte, services, remoteServices uint64) []byte {
+		data := append([]byte(nil), nonce...)
+		data = binary.LittleEndian.AppendUint64(data, services)
+		data = binary.LittleEndian.AppendUint64(data, remoteServices)
+		return append(data, sessionID...)
+	}
+
+	data := theirVersion.Nonce[:]
+	if bindNonce {
+		data = signData(data, services, received)
+	}
+	sign, _ := crypto.Sign(priKey, data)
+	if err := write(conn, msg.NewVerAck(sign)); err != nil {
+		return err
+	}
+
+	// Verify the verack signature covers our nonce and the session.
+	m, err = read(conn)
+	if err != nil {
+		return err
+	}
+	verAck, ok := m.(*msg.VerAck)
+	if !ok {
+		return fmt.Errorf("expected verack message, got [%s]", m.CMD())
+	}
+	theirPK, _ := crypto.DecodePoint(peerCfg.PID[:])
+	data = nonce[:]
+	if sessionID != nil {
+		data = signData(data, theirVersion.Services, services)
+	}
+	return crypto.Verify(*theirPK, data, verAck.Signature[:])
+}
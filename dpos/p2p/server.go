@@ -91,6 +91,10 @@ type peerState struct {
 	connectPeers  map[peer.PID]struct{}
 	inboundPeers  map[uint64]*serverPeer
 	outboundPeers map[uint64]*serverPeer
+
+	// bytesSent and bytesReceived are the bytes of the peers done.
+	bytesSent     uint64
+	bytesReceived uint64
 }
 
 // havePeer returns how many connected peers matches to given PID, if no matches
@@ -246,6 +250,13 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		return false
 	}
 
+	// The peer authenticated the encryption service, do not accept the
+	// plaintext connection from it which may be downgraded.  The mark is
+	// saved by the address manager so it survives restarts.
+	if sp.Encrypted() {
+		s.addrManager.SetEncrypted(sp.PID())
+	}
+
 	// Add the new peer and start it.
 	log.Debugf("New peer %s", sp)
 	if sp.Inbound() {
@@ -272,6 +283,9 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 // handleDonePeerMsg deals with peers that have signalled they are done.  It is
 // invoked from the peerHandler goroutine.
 func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
+	state.bytesSent += sp.BytesSent()
+	state.bytesReceived += sp.BytesReceived()
+
 	var list map[uint64]*serverPeer
 	ip := common.GetIpFromAddr(sp.Addr())
 	if sp.Inbound() {
@@ -448,6 +462,9 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		})
 		msg.reply <- peers
 
+	case getPeerStatsMsg:
+		msg.reply <- state.stats()
+
 	case dumpPeersInfoMsg:
 		// DumpPeesInfo returns the peers info in connect peers list.  The peers
 		// in connect list can be in 4 states.
@@ -467,11 +484,13 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 				Addr:        sp.Addr(),
 				State:       CSOutboundOnly,
 				NodeVersion: sp.NodeVersion,
+				Encrypted:   sp.Encrypted(),
 			}
 		}
 		for _, sp := range state.inboundPeers {
 			if pi, ok := peers[sp.PID()]; ok {
 				pi.State = CS2WayConnection
+				pi.Encrypted = pi.Encrypted && sp.Encrypted()
 				continue
 			}
 			peers[sp.PID()] = &PeerInfo{
@@ -479,6 +498,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 				Addr:        sp.Addr(),
 				State:       CSInboundOnly,
 				NodeVersion: sp.NodeVersion,
+				Encrypted:   sp.Encrypted(),
 			}
 		}
 		for pid := range state.connectPeers {
@@ -526,6 +546,10 @@ func (s *server) pongNonce(pid peer.PID) uint64 {
 	return s.cfg.PongNonce(pid)
 }
 
+func (s *server) encryptionRequired(pid peer.PID) bool {
+	return s.addrManager.IsEncrypted(pid)
+}
+
 // newPeerConfig returns the configuration for the given serverPeer.
 func newPeerConfig(sp *serverPeer) *peer.Config {
 	return &peer.Config{
@@ -547,8 +571,9 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 
 			}
 		},
-		DPoSV2StartHeight: sp.server.cfg.DPoSV2StartHeight,
-		NodeVersion:       sp.server.cfg.NodeVersion,
+		DPoSV2StartHeight:  sp.server.cfg.DPoSV2StartHeight,
+		NodeVersion:        sp.server.cfg.NodeVersion,
+		EncryptionRequired: sp.server.encryptionRequired,
 	}
 }
 
@@ -821,6 +846,9 @@ func (s *server) Start() {
 	// managers.
 	s.wg.Add(1)
 	go s.peerHandler()
+
+	// Export the statistics of peers to metrics.
+	s.registerMetrics()
 }
 
 // Stop gracefully shuts down the server by stopping and disconnecting all
@@ -887,7 +915,19 @@ func (s *server) ScheduleShutdown(duration time.Duration) {
 
 func (s *server) dialTimeout(addr net.Addr) (net.Conn, error) {
 	log.Debugf("Server dial addr %s", addr)
-	addr, err := addrStringToNetAddr(addr.String())
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil, err
+	}
+	proxy := s.cfg.Proxy
+	if p2p.IsOnionHost(host) && s.cfg.OnionProxy != nil {
+		proxy = s.cfg.OnionProxy
+	}
+	if proxy != nil {
+		return proxy.DialTimeout("tcp", addr.String(), s.cfg.ConnectTimeout)
+	}
+
+	addr, err = addrStringToNetAddr(addr.String())
 	if err != nil {
 		return nil, err
 	}
@@ -115,6 +115,10 @@ type Arbiters struct {
 	forceChanged bool
 
 	History *utils.History
+
+	// Performance tracks the consensus performance of producers, it is nil
+	// for the arbiters rebuilt at history heights.
+	Performance *PerformanceTracker
 }
 
 func (a *Arbiters) Start() {
@@ -220,6 +224,17 @@ func (a *Arbiters) ProcessBlock(block *types.Block, confirm *payload.Confirm) {
 		}
 	}
 
+	if a.Performance != nil {
+		a.mtx.Lock()
+		arbiters := make([]ArbiterMember, len(a.CurrentArbitrators))
+		copy(arbiters, a.CurrentArbitrators)
+		dutyIndex := a.DutyIndex
+		a.mtx.Unlock()
+		a.Performance.ProcessBlock(block, confirm, sponsor, arbiters,
+			dutyIndex, block.Height >=
+				a.ChainParams.DPoSConfiguration.DPOSNodeCrossChainHeight)
+	}
+
 	a.State.ProcessBlock(block, sponsor, a.DutyIndex)
 	a.IncreaseChainHeight(block, confirm)
 }
@@ -3098,7 +3113,52 @@ func NewArbitrators(chainParams *config.Configuration, committee *state.Committe
 	updateCRInactivePenalty func(cid common.Uint168, height uint32),
 	revertUpdateCRInactivePenalty func(cid common.Uint168, height uint32),
 	ckpManager *checkpoint.Manager) (*Arbiters, error) {
+	a, err := newArbitrators(chainParams, committee, ckpManager)
+	if err != nil {
+		return nil, err
+	}
+	a.State = NewState(chainParams, a.GetArbitrators, a.CRCommittee.GetCurrentMembers,
+		a.CRCommittee.GetNextMembers, a.CRCommittee.IsInElectionPeriod,
+		getProducerDepositAmount, tryUpdateCRMemberInactivity, tryRevertCRMemberInactivityfunc,
+		tryUpdateCRMemberIllegal, tryRevertCRMemberIllegal,
+		updateCRInactivePenalty,
+		revertUpdateCRInactivePenalty)
+	a.Performance = NewPerformanceTracker()
+	a.CkpManager.Register(NewCheckpoint(a))
+	a.CkpManager.Register(a.Performance)
+	return a, nil
+}
 
+// NewHistoryArbitrators returns a new Arbiters instance along with the given
+// CR committee to rebuild the DPoS and CR states at a history height.  They
+// are isolated from the chain events, so the live states will not be
+// affected by them, and vice versa.
+func NewHistoryArbitrators(chainParams *config.Configuration,
+	committee *state.Committee,
+	getProducerDepositAmount func(common.Uint168) (common.Fixed64, error),
+	ckpManager *checkpoint.Manager) (*Arbiters, error) {
+	a, err := newArbitrators(chainParams, committee, ckpManager)
+	if err != nil {
+		return nil, err
+	}
+	a.State = newState(chainParams, a.GetArbitrators, committee.GetCurrentMembers,
+		committee.GetNextMembers, committee.IsInElectionPeriod,
+		getProducerDepositAmount,
+		committee.TryUpdateCRMemberInactivity,
+		committee.TryRevertCRMemberInactivity,
+		committee.TryUpdateCRMemberIllegal,
+		committee.TryRevertCRMemberIllegal,
+		committee.UpdateCRInactivePenalty,
+		committee.RevertUpdateCRInactivePenalty)
+	committee.SetCommitteeChangedHandler(func(*types.Block) {
+		a.State.ChangeCommittee()
+	})
+	a.CkpManager.Register(NewCheckpoint(a))
+	return a, nil
+}
+
+func newArbitrators(chainParams *config.Configuration, committee *state.Committee,
+	ckpManager *checkpoint.Manager) (*Arbiters, error) {
 	blockConfirmProposalSponsors := make(map[uint32][]byte)
 	sponsorsFilePath := chainParams.DPoSConfiguration.SponsorsFilePath
 	sponsors, err := os.ReadFile(sponsorsFilePath)
@@ -3150,12 +3210,5 @@ func NewArbitrators(chainParams *config.Configuration, committee *state.Committe
 	if err := a.initArbitrators(chainParams); err != nil {
 		return nil, err
 	}
-	a.State = NewState(chainParams, a.GetArbitrators, a.CRCommittee.GetCurrentMembers,
-		a.CRCommittee.GetNextMembers, a.CRCommittee.IsInElectionPeriod,
-		getProducerDepositAmount, tryUpdateCRMemberInactivity, tryRevertCRMemberInactivityfunc,
-		tryUpdateCRMemberIllegal, tryRevertCRMemberIllegal,
-		updateCRInactivePenalty,
-		revertUpdateCRInactivePenalty)
-	a.CkpManager.Register(NewCheckpoint(a))
 	return a, nil
 }
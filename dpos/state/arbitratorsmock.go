@@ -114,6 +114,11 @@ func (a *ArbitratorsMock) GetNextRewardData() RewardData {
 	return a.NextReward
 }
 
+func (a *ArbitratorsMock) SimulateDPoSV2Reward(publicKey []byte,
+	amount common.Fixed64, lockUntil uint32) (*DPoSV2RewardSimulation, error) {
+	panic("implement me")
+}
+
 func (a *ArbitratorsMock) GetSnapshot(height uint32) []*CheckPoint {
 	return a.Snapshot
 }
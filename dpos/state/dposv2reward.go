@@ -0,0 +1,155 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package state
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/common"
+)
+
+// blocksPerDPoSV2WeightUnit is the count of blocks the lock time of DPoS v2
+// votes is weighted by.
+const blocksPerDPoSV2WeightUnit = 7200
+
+// DPoSV2VoteRights returns the vote rights of DPoS v2 votes included in the
+// block at blockHeight and locked until lockTime, the rewards of voters are
+// distributed in proportion to it.
+func DPoSV2VoteRights(votes common.Fixed64, blockHeight, lockTime uint32) float64 {
+	weightF := math.Log10(float64(lockTime-blockHeight) /
+		blocksPerDPoSV2WeightUnit * 10)
+	return float64(common.Fixed64(float64(votes) * weightF))
+}
+
+// DPoSV2RewardSimulation is the projected reward of a hypothetical DPoS v2
+// vote to a producer.
+type DPoSV2RewardSimulation struct {
+	// Height is the height the vote is supposed to be included in.
+	Height uint32
+
+	// VoteRights is the vote rights of the vote.
+	VoteRights float64
+
+	// ProducerVoteRights is the total vote rights of the producer along
+	// with the vote.
+	ProducerVoteRights float64
+
+	// Effective indicates if the producer reaches the effective votes of
+	// DPoS v2 along with the vote.
+	Effective bool
+
+	// InCurrentRound indicates if the producer is a current arbiter, only
+	// current arbiters sponsor blocks and reward their voters.
+	InCurrentRound bool
+
+	// ProducerVotesInRound and TotalVotesInRound are the votes of the
+	// producer and all producers in the current round.
+	ProducerVotesInRound common.Fixed64
+	TotalVotesInRound    common.Fixed64
+
+	// RoundBlocks is the count of blocks of a round, each current arbiter
+	// sponsors one of them.
+	RoundBlocks uint32
+
+	// BlockReward is the DPoS reward of a block, the voters of the sponsor
+	// share three quarters of it by their vote rights.
+	BlockReward common.Fixed64
+
+	// RoundReward is the reward of the vote in the current round.
+	RoundReward common.Fixed64
+
+	// AnnualReward is the reward of the vote in a year, supposing the
+	// current round repeats.
+	AnnualReward common.Fixed64
+
+	// AnnualRate is the rate of the annual reward to the amount of the vote.
+	AnnualRate float64
+}
+
+// SimulateDPoSV2Reward projects the reward of voting amount of ELA locked
+// until lockUntil to the producer with the given owner or node public key,
+// based on the current arbiters, reward data and votes of the producer.
+// Transaction fees are not included in the block reward.
+func (a *Arbiters) SimulateDPoSV2Reward(publicKey []byte,
+	amount common.Fixed64, lockUntil uint32) (*DPoSV2RewardSimulation, error) {
+	if amount <= 0 {
+		return nil, errors.New("invalid vote amount")
+	}
+	height := a.bestHeight() + 1
+	if lockUntil <= height {
+		return nil, errors.New("lock time is not higher than current height")
+	}
+	lockTime := lockUntil - height
+	if lockTime < a.ChainParams.DPoSConfiguration.DPoSV2MinVotesLockTime {
+		return nil, errors.New("lock time is less than minimum lock time")
+	}
+	if lockTime > a.ChainParams.DPoSConfiguration.DPoSV2MaxVotesLockTime {
+		return nil, errors.New("lock time is more than max lock time")
+	}
+
+	producer := a.State.GetProducer(publicKey)
+	if producer == nil {
+		return nil, errors.New("unknown producer public key")
+	}
+	if producer.Identity() == DPoSV1 {
+		return nil, errors.New("producer is not a DPoS v2 producer")
+	}
+	if producer.State() != Active {
+		return nil, errors.New("producer is not active")
+	}
+	ownerHash, err := GetOwnerKeyStandardProgramHash(producer.OwnerPublicKey())
+	if err != nil {
+		return nil, err
+	}
+
+	voteRights := DPoSV2VoteRights(amount, height, lockUntil)
+	producerVoteRights := producer.GetTotalDPoSV2VoteRights() + voteRights
+	result := &DPoSV2RewardSimulation{
+		Height:             height,
+		VoteRights:         voteRights,
+		ProducerVoteRights: producerVoteRights,
+		Effective: producerVoteRights >=
+			float64(a.ChainParams.DPoSV2EffectiveVotes),
+		BlockReward: common.Fixed64(math.Ceil(float64(
+			a.ChainParams.GetBlockReward(height)) * 0.35)),
+	}
+
+	// The reward data is read along with the current arbiters under the
+	// same lock instead of by GetCurrentRewardData, which takes the lock
+	// itself, so both are of the same round.
+	a.mtx.Lock()
+	result.RoundBlocks = uint32(len(a.CurrentArbitrators))
+	for _, arbiter := range a.CurrentArbitrators {
+		if bytes.Equal(arbiter.GetNodePublicKey(), producer.NodePublicKey()) {
+			result.InCurrentRound = true
+			break
+		}
+	}
+	result.ProducerVotesInRound = a.CurrentReward.OwnerVotesInRound[*ownerHash]
+	result.TotalVotesInRound = a.CurrentReward.TotalVotesInRound
+	a.mtx.Unlock()
+
+	if !result.InCurrentRound || producerVoteRights == 0 {
+		return result, nil
+	}
+
+	votesReward := result.BlockReward * 3 / 4
+	result.RoundReward = common.Fixed64(voteRights / producerVoteRights *
+		float64(votesReward))
+
+	targetTimePerBlock := a.ChainParams.PowConfiguration.TargetTimePerBlock
+	if targetTimePerBlock > 0 && result.RoundBlocks > 0 {
+		blocksPerYear := float64(365 * 24 * time.Hour / targetTimePerBlock)
+		rounds := blocksPerYear / float64(result.RoundBlocks)
+		result.AnnualReward = common.Fixed64(float64(result.RoundReward) * rounds)
+		result.AnnualRate = float64(result.AnnualReward) / float64(amount)
+	}
+
+	return result, nil
+}
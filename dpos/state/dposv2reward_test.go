@@ -0,0 +1,214 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package state
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/common/config"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDPoSV2VoteRights(t *testing.T) {
+	// Votes locked for 7200 blocks have the weight of 1.
+	assert.Equal(t, float64(100), DPoSV2VoteRights(100, 10, 7210))
+	// Votes locked for 720000 blocks have the weight of 3.
+	assert.Equal(t, float64(300), DPoSV2VoteRights(100, 10, 720010))
+
+	// The vote rights are the same as the ones counted by producers.
+	producer := &Producer{
+		detailedDPoSV2Votes: map[common.Uint168]map[common.Uint256]payload.DetailedVoteInfo{
+			*randomUint168(): {
+				{1}: {
+					BlockHeight: 100,
+					Info: []payload.VotesWithLockTime{
+						{Votes: 12345678, LockTime: 50100},
+					},
+				},
+			},
+		},
+	}
+	assert.Equal(t, producer.GetTotalDPoSV2VoteRights(),
+		DPoSV2VoteRights(12345678, 100, 50100))
+}
+
+// newRewardTestArbiters returns arbiters at the given best height with an
+// active DPoS v2 producer, which is a current arbiter if inRound is true.
+func newRewardTestArbiters(t *testing.T, bestHeight uint32,
+	inRound bool) (*Arbiters, *Producer) {
+	params := config.DefaultParams
+	params.DPoSV2EffectiveVotes = 100
+	st := &State{StateKeyFrame: NewStateKeyFrame()}
+	a := &Arbiters{
+		State:       st,
+		ChainParams: &params,
+		bestHeight:  func() uint32 { return bestHeight },
+	}
+
+	producer := &Producer{
+		info: payload.ProducerInfo{
+			OwnerKey:      randomPublicKey(),
+			NodePublicKey: randomPublicKey(),
+			StakeUntil:    bestHeight + 100000,
+		},
+		state:    Active,
+		identity: DPoSV2,
+		detailedDPoSV2Votes: map[common.Uint168]map[common.Uint256]payload.DetailedVoteInfo{
+			*randomUint168(): {
+				{1}: {
+					BlockHeight: bestHeight - 100,
+					Info: []payload.VotesWithLockTime{
+						{Votes: 300000000, LockTime: bestHeight + 50000},
+					},
+				},
+			},
+		},
+	}
+	ownerKey := hex.EncodeToString(producer.OwnerPublicKey())
+	st.ActivityProducers[ownerKey] = producer
+	st.NodeOwnerKeys[hex.EncodeToString(producer.NodePublicKey())] = ownerKey
+
+	ownerHash, err := GetOwnerKeyStandardProgramHash(producer.OwnerPublicKey())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	a.CurrentReward = RewardData{
+		OwnerVotesInRound: map[common.Uint168]common.Fixed64{
+			*ownerHash: 300000000},
+		TotalVotesInRound: 900000000,
+	}
+
+	other, err := NewOriginArbiter(randomPublicKey())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	a.CurrentArbitrators = []ArbiterMember{other}
+	if inRound {
+		arbiter, err := NewDPoSArbiter(producer)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		a.CurrentArbitrators = append(a.CurrentArbitrators, arbiter)
+	}
+	return a, producer
+}
+
+func TestArbiters_SimulateDPoSV2Reward(t *testing.T) {
+	const bestHeight = 2000000
+	a, producer := newRewardTestArbiters(t, bestHeight, true)
+	amount := common.Fixed64(100000000)
+	lockUntil := uint32(bestHeight + 1 + 72000)
+
+	result, err := a.SimulateDPoSV2Reward(producer.NodePublicKey(), amount,
+		lockUntil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	height := uint32(bestHeight + 1)
+	assert.Equal(t, height, result.Height)
+	assert.True(t, result.InCurrentRound)
+	assert.True(t, result.Effective)
+	assert.Equal(t, uint32(2), result.RoundBlocks)
+	assert.Equal(t, common.Fixed64(300000000), result.ProducerVotesInRound)
+	assert.Equal(t, common.Fixed64(900000000), result.TotalVotesInRound)
+	assert.Equal(t, DPoSV2VoteRights(amount, height, lockUntil),
+		result.VoteRights)
+	assert.Equal(t, producer.GetTotalDPoSV2VoteRights()+result.VoteRights,
+		result.ProducerVoteRights)
+
+	// The round reward is the reward distributed to the vote if it was
+	// counted by the producer when sponsoring a block.
+	voter := randomUint168()
+	producer.detailedDPoSV2Votes[*voter] = map[common.Uint256]payload.DetailedVoteInfo{
+		{2}: {
+			BlockHeight: height,
+			Info: []payload.VotesWithLockTime{
+				{Votes: amount, LockTime: lockUntil},
+			},
+		},
+	}
+	rewards := a.getDPoSV2RewardsV2(result.BlockReward,
+		producer.NodePublicKey(), height)
+	voterAddr, _ := voter.ToAddress()
+	assert.True(t, result.RoundReward > 0)
+	assert.InDelta(t, float64(rewards[voterAddr]), float64(result.RoundReward), 1)
+	delete(producer.detailedDPoSV2Votes, *voter)
+
+	// The annual figures suppose the round repeats for a year.
+	blocksPerYear := float64(365 * 24 * time.Hour /
+		a.ChainParams.PowConfiguration.TargetTimePerBlock)
+	assert.Equal(t, common.Fixed64(float64(result.RoundReward)*
+		blocksPerYear/float64(result.RoundBlocks)), result.AnnualReward)
+	assert.Equal(t, float64(result.AnnualReward)/float64(amount),
+		result.AnnualRate)
+
+	// Producers not in the current round sponsor no blocks.
+	a, producer = newRewardTestArbiters(t, bestHeight, false)
+	result, err = a.SimulateDPoSV2Reward(producer.OwnerPublicKey(), amount,
+		lockUntil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.False(t, result.InCurrentRound)
+	assert.Equal(t, common.Fixed64(0), result.RoundReward)
+	assert.Equal(t, common.Fixed64(0), result.AnnualReward)
+	assert.Equal(t, float64(0), result.AnnualRate)
+}
+
+func TestArbiters_SimulateDPoSV2Reward_Errors(t *testing.T) {
+	const bestHeight = 2000000
+	height := uint32(bestHeight + 1)
+	minLockTime := config.DefaultParams.DPoSConfiguration.DPoSV2MinVotesLockTime
+	maxLockTime := config.DefaultParams.DPoSConfiguration.DPoSV2MaxVotesLockTime
+
+	tests := []struct {
+		name      string
+		modify    func(p *Producer)
+		publicKey func(p *Producer) []byte
+		amount    common.Fixed64
+		lockUntil uint32
+	}{
+		{"zero amount", nil, nil, 0, height + minLockTime},
+		{"lock until current height", nil, nil, 100, height},
+		{"lock too short", nil, nil, 100, height + minLockTime - 1},
+		{"lock too long", nil, nil, 100, height + maxLockTime + 1},
+		{"unknown producer", nil, func(*Producer) []byte {
+			return randomPublicKey()
+		}, 100, height + minLockTime},
+		{"DPoS v1 producer", func(p *Producer) {
+			p.identity = DPoSV1
+		}, nil, 100, height + minLockTime},
+		{"inactive producer", func(p *Producer) {
+			p.state = Inactive
+		}, nil, 100, height + minLockTime},
+	}
+	for _, test := range tests {
+		a, producer := newRewardTestArbiters(t, bestHeight, true)
+		if test.modify != nil {
+			test.modify(producer)
+		}
+		publicKey := producer.NodePublicKey()
+		if test.publicKey != nil {
+			publicKey = test.publicKey(producer)
+		}
+		_, err := a.SimulateDPoSV2Reward(publicKey, test.amount,
+			test.lockUntil)
+		assert.Error(t, err, test.name)
+	}
+
+	// The limits of the lock time are inclusive.
+	a, producer := newRewardTestArbiters(t, bestHeight, true)
+	for _, lockTime := range []uint32{minLockTime, maxLockTime} {
+		_, err := a.SimulateDPoSV2Reward(producer.NodePublicKey(), 100,
+			height+lockTime)
+		assert.NoError(t, err)
+	}
+}
@@ -39,6 +39,8 @@ type Arbitrators interface {
 
 	GetCurrentRewardData() RewardData
 	GetNextRewardData() RewardData
+	SimulateDPoSV2Reward(publicKey []byte, amount common.Fixed64,
+		lockUntil uint32) (*DPoSV2RewardSimulation, error)
 	GetArbitersRoundReward() map[common.Uint168]common.Fixed64
 	GetFinalRoundChange() common.Fixed64
 	SetNeedRevertToDPOSTX(need bool)
@@ -0,0 +1,567 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package state
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/checkpoint"
+	"github.com/elastos/Elastos.ELA/core/types"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/crypto"
+)
+
+const (
+	// PerformanceCheckpointKey defines key of producer performance
+	// checkpoint.
+	PerformanceCheckpointKey = "cp_performance"
+
+	// performanceCheckpointExtension defines checkpoint file extension of
+	// producer performance checkpoint.
+	performanceCheckpointExtension = ".pcp"
+
+	// PerformanceWindow is the count of recent blocks the rolling uptime of
+	// producers is calculated over, it also bounds how far the performance
+	// can be rolled back.
+	PerformanceWindow = uint32(720)
+)
+
+// ProducerPerformance holds the consensus performance of a producer, keyed by
+// its node public key.
+type ProducerPerformance struct {
+	NodePublicKey []byte
+
+	// The following fields are counted from the confirms of blocks.
+
+	// BlocksProposed is the count of blocks sponsored by the producer.
+	BlocksProposed uint32
+
+	// VotesCast is the count of votes of the producer included in confirms.
+	VotesCast uint32
+
+	// RoundsMissed is the count of blocks confirmed while the producer was
+	// a current arbiter, but neither sponsored it nor had a vote included in
+	// its confirm.
+	RoundsMissed uint32
+
+	// ViewChangesCaused is the count of views the producer failed to
+	// propose in while being on duty.
+	ViewChangesCaused uint32
+
+	// Rounds and RoundsParticipated are the count of blocks in the recent
+	// performance window confirmed while the producer was a current arbiter,
+	// and how many of them the producer participated in.
+	Rounds             uint32
+	RoundsParticipated uint32
+
+	// The following fields are observed by the local consensus, they are
+	// only available on arbiter nodes.
+
+	// ProposalsReceived is the count of proposals received from the
+	// producer.
+	ProposalsReceived uint32
+
+	// VotesReceived is the count of votes received from the producer.
+	VotesReceived uint32
+
+	// ViewChangesObserved is the count of view changes observed while the
+	// producer was on duty.
+	ViewChangesObserved uint32
+
+	// InactiveDetections is the count of times the producer was detected as
+	// inactive arbiter.
+	InactiveDetections uint32
+}
+
+// Uptime returns the rate of rounds the producer participated in within the
+// recent performance window.
+func (p *ProducerPerformance) Uptime() float64 {
+	if p.Rounds == 0 {
+		return 0
+	}
+	return float64(p.RoundsParticipated) / float64(p.Rounds)
+}
+
+func (p *ProducerPerformance) Serialize(w io.Writer) error {
+	if err := common.WriteVarBytes(w, p.NodePublicKey); err != nil {
+		return err
+	}
+	return common.WriteElements(w, p.BlocksProposed, p.VotesCast,
+		p.RoundsMissed, p.ViewChangesCaused, p.ProposalsReceived,
+		p.VotesReceived, p.ViewChangesObserved, p.InactiveDetections)
+}
+
+func (p *ProducerPerformance) Deserialize(r io.Reader) (err error) {
+	if p.NodePublicKey, err = common.ReadVarBytes(r, crypto.NegativeBigLength,
+		"node public key"); err != nil {
+		return
+	}
+	return common.ReadElements(r, &p.BlocksProposed, &p.VotesCast,
+		&p.RoundsMissed, &p.ViewChangesCaused, &p.ProposalsReceived,
+		&p.VotesReceived, &p.ViewChangesObserved, &p.InactiveDetections)
+}
+
+// performanceRecord records the participation of arbiters in a block.
+type performanceRecord struct {
+	Height       uint32
+	Sponsor      []byte
+	Voters       [][]byte
+	Missed       [][]byte
+	ViewChangers [][]byte
+}
+
+func (r *performanceRecord) Serialize(w io.Writer) error {
+	if err := common.WriteUint32(w, r.Height); err != nil {
+		return err
+	}
+	if err := common.WriteVarBytes(w, r.Sponsor); err != nil {
+		return err
+	}
+	for _, keys := range [][][]byte{r.Voters, r.Missed, r.ViewChangers} {
+		if err := common.WriteVarUint(w, uint64(len(keys))); err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := common.WriteVarBytes(w, k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *performanceRecord) Deserialize(reader io.Reader) (err error) {
+	if r.Height, err = common.ReadUint32(reader); err != nil {
+		return
+	}
+	if r.Sponsor, err = common.ReadVarBytes(reader, crypto.NegativeBigLength,
+		"sponsor"); err != nil {
+		return
+	}
+	for _, keys := range []*[][]byte{&r.Voters, &r.Missed, &r.ViewChangers} {
+		var count uint64
+		if count, err = common.ReadVarUint(reader, 0); err != nil {
+			return
+		}
+		*keys = make([][]byte, 0, count)
+		for i := uint64(0); i < count; i++ {
+			var k []byte
+			if k, err = common.ReadVarBytes(reader, crypto.NegativeBigLength,
+				"public key"); err != nil {
+				return
+			}
+			*keys = append(*keys, k)
+		}
+	}
+	return
+}
+
+// PerformanceTracker tracks the consensus performance of producers from the
+// confirms of blocks, along with the consensus events observed locally.
+type PerformanceTracker struct {
+	mtx        sync.RWMutex
+	bestHeight uint32
+	producers  map[string]*ProducerPerformance
+	records    []*performanceRecord
+
+	height uint32
+}
+
+// ProcessBlock records the participation of the current arbiters in the
+// block.  The arbiters and the duty index are the ones the block was
+// proposed with, and the on duty arbiters of the views before the view
+// offset of the proposal are taken as the causes of view changes.
+func (t *PerformanceTracker) ProcessBlock(block *types.Block,
+	confirm *payload.Confirm, sponsor []byte, arbiters []ArbiterMember,
+	dutyIndex int, countViewChanges bool) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if block.Height <= t.bestHeight {
+		return
+	}
+	t.bestHeight = block.Height
+	if confirm == nil || len(arbiters) == 0 {
+		return
+	}
+
+	record := &performanceRecord{Height: block.Height, Sponsor: sponsor}
+	voted := make(map[string]struct{})
+	for _, v := range confirm.Votes {
+		key := common.BytesToHexString(v.Signer)
+		if _, ok := voted[key]; ok {
+			continue
+		}
+		voted[key] = struct{}{}
+		record.Voters = append(record.Voters, v.Signer)
+	}
+	for _, a := range arbiters {
+		nodePublicKey := a.GetNodePublicKey()
+		if !a.IsNormal() || len(nodePublicKey) == 0 ||
+			bytes.Equal(nodePublicKey, sponsor) {
+			continue
+		}
+		if _, ok := voted[common.BytesToHexString(nodePublicKey)]; !ok {
+			record.Missed = append(record.Missed, nodePublicKey)
+		}
+	}
+	if countViewChanges {
+		offset := int(confirm.Proposal.ViewOffset)
+		if offset > len(arbiters) {
+			offset = len(arbiters)
+		}
+		for i := 0; i < offset; i++ {
+			a := arbiters[(dutyIndex+i)%len(arbiters)]
+			if len(a.GetNodePublicKey()) != 0 {
+				record.ViewChangers = append(record.ViewChangers,
+					a.GetNodePublicKey())
+			}
+		}
+	}
+
+	t.applyRecord(record, 1)
+	t.records = append(t.records, record)
+
+	index := 0
+	for index < len(t.records) &&
+		t.records[index].Height+PerformanceWindow <= t.bestHeight {
+		index++
+	}
+	if index > 0 {
+		t.records = append(t.records[:0], t.records[index:]...)
+	}
+}
+
+// applyRecord adds the counts of the record to the producers when delta is 1,
+// or removes them when delta is -1.
+func (t *PerformanceTracker) applyRecord(r *performanceRecord, delta int) {
+	if len(r.Sponsor) != 0 {
+		p := t.producer(r.Sponsor)
+		p.BlocksProposed = uint32(int(p.BlocksProposed) + delta)
+	}
+	for _, k := range r.Voters {
+		p := t.producer(k)
+		p.VotesCast = uint32(int(p.VotesCast) + delta)
+	}
+	for _, k := range r.Missed {
+		p := t.producer(k)
+		p.RoundsMissed = uint32(int(p.RoundsMissed) + delta)
+	}
+	for _, k := range r.ViewChangers {
+		p := t.producer(k)
+		p.ViewChangesCaused = uint32(int(p.ViewChangesCaused) + delta)
+	}
+}
+
+func (t *PerformanceTracker) producer(nodePublicKey []byte) *ProducerPerformance {
+	key := common.BytesToHexString(nodePublicKey)
+	p, ok := t.producers[key]
+	if !ok {
+		p = &ProducerPerformance{NodePublicKey: nodePublicKey}
+		t.producers[key] = p
+	}
+	return p
+}
+
+// OnProposalReceived records a proposal received from the sponsor.
+func (t *PerformanceTracker) OnProposalReceived(sponsor []byte) {
+	t.mtx.Lock()
+	t.producer(sponsor).ProposalsReceived++
+	t.mtx.Unlock()
+}
+
+// OnVoteReceived records a vote received from the signer.
+func (t *PerformanceTracker) OnVoteReceived(signer []byte) {
+	t.mtx.Lock()
+	t.producer(signer).VotesReceived++
+	t.mtx.Unlock()
+}
+
+// OnViewChanged records a view change observed while the arbiter was on
+// duty.
+func (t *PerformanceTracker) OnViewChanged(onDuty []byte) {
+	if len(onDuty) == 0 {
+		return
+	}
+	t.mtx.Lock()
+	t.producer(onDuty).ViewChangesObserved++
+	t.mtx.Unlock()
+}
+
+// OnInactiveArbitersDetected records the arbiters detected as inactive by
+// the local consensus, in hex string of node public keys.
+func (t *PerformanceTracker) OnInactiveArbitersDetected(arbiters []string) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	for _, a := range arbiters {
+		nodePublicKey, err := common.HexStringToBytes(a)
+		if err != nil {
+			continue
+		}
+		t.producer(nodePublicKey).InactiveDetections++
+	}
+}
+
+// GetPerformance returns the performance of the producer with the given node
+// public key, and whether the producer has been tracked.
+func (t *PerformanceTracker) GetPerformance(
+	nodePublicKey []byte) (ProducerPerformance, bool) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	p, ok := t.producers[common.BytesToHexString(nodePublicKey)]
+	if !ok {
+		return ProducerPerformance{}, false
+	}
+	result := *p
+	t.countRounds(&result)
+	return result, true
+}
+
+// GetPerformances returns the performance of all tracked producers, ordered
+// by node public key.
+func (t *PerformanceTracker) GetPerformances() []ProducerPerformance {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	result := make([]ProducerPerformance, 0, len(t.producers))
+	for _, p := range t.producers {
+		performance := *p
+		t.countRounds(&performance)
+		result = append(result, performance)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return bytes.Compare(result[i].NodePublicKey,
+			result[j].NodePublicKey) < 0
+	})
+	return result
+}
+
+// countRounds sets the rounds of the producer in the performance window.
+func (t *PerformanceTracker) countRounds(p *ProducerPerformance) {
+	for _, r := range t.records {
+		if bytes.Equal(r.Sponsor, p.NodePublicKey) {
+			p.Rounds++
+			p.RoundsParticipated++
+			continue
+		}
+		if containsKey(r.Missed, p.NodePublicKey) {
+			p.Rounds++
+		} else if containsKey(r.Voters, p.NodePublicKey) {
+			p.Rounds++
+			p.RoundsParticipated++
+		}
+	}
+}
+
+func containsKey(keys [][]byte, key []byte) bool {
+	for _, k := range keys {
+		if bytes.Equal(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// BestHeight returns the height of the last processed block.
+func (t *PerformanceTracker) BestHeight() uint32 {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	return t.bestHeight
+}
+
+func (t *PerformanceTracker) OnBlockSaved(block *types.DposBlock) {
+}
+
+func (t *PerformanceTracker) OnRollbackTo(height uint32) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	index := len(t.records)
+	for index > 0 && t.records[index-1].Height > height {
+		index--
+		t.applyRecord(t.records[index], -1)
+	}
+	t.records = t.records[:index]
+	if t.bestHeight > height {
+		t.bestHeight = height
+	}
+	return nil
+}
+
+func (t *PerformanceTracker) OnRollbackSeekTo(uint32) {
+	return
+}
+
+func (t *PerformanceTracker) Key() string {
+	return PerformanceCheckpointKey
+}
+
+func (t *PerformanceTracker) Snapshot() checkpoint.ICheckPoint {
+	buf := bytes.Buffer{}
+	if err := t.Serialize(&buf); err != nil {
+		t.LogError(err)
+		return nil
+	}
+	result := NewPerformanceTracker()
+	if err := result.Deserialize(&buf); err != nil {
+		t.LogError(err)
+		return nil
+	}
+	return result
+}
+
+func (t *PerformanceTracker) GetHeight() uint32 {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	return t.height
+}
+
+func (t *PerformanceTracker) OnReset() error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.bestHeight = 0
+	t.producers = make(map[string]*ProducerPerformance)
+	t.records = nil
+	return nil
+}
+
+func (t *PerformanceTracker) SetHeight(height uint32) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.height = height
+}
+
+func (t *PerformanceTracker) SavePeriod() uint32 {
+	return CheckPointInterval
+}
+
+func (t *PerformanceTracker) EffectivePeriod() uint32 {
+	return checkpointEffectiveHeight
+}
+
+func (t *PerformanceTracker) DataExtension() string {
+	return performanceCheckpointExtension
+}
+
+func (t *PerformanceTracker) Generator() func(buf []byte) checkpoint.ICheckPoint {
+	return func(buf []byte) checkpoint.ICheckPoint {
+		stream := bytes.Buffer{}
+		stream.Write(buf)
+
+		result := NewPerformanceTracker()
+		if err := result.Deserialize(&stream); err != nil {
+			t.LogError(err)
+			return nil
+		}
+		return result
+	}
+}
+
+func (t *PerformanceTracker) LogError(err error) {
+	log.Warn("[PerformanceTracker] error: ", err.Error())
+}
+
+func (t *PerformanceTracker) Priority() checkpoint.Priority {
+	return checkpoint.Low
+}
+
+func (t *PerformanceTracker) OnInit() {
+}
+
+func (t *PerformanceTracker) SaveStartHeight() uint32 {
+	return uint32(1)
+}
+
+func (t *PerformanceTracker) StartHeight() uint32 {
+	return uint32(1)
+}
+
+func (t *PerformanceTracker) Serialize(w io.Writer) (err error) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	if err = common.WriteUint32(w, t.height); err != nil {
+		return
+	}
+	if err = common.WriteUint32(w, t.bestHeight); err != nil {
+		return
+	}
+
+	keys := make([]string, 0, len(t.producers))
+	for k := range t.producers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if err = common.WriteVarUint(w, uint64(len(keys))); err != nil {
+		return
+	}
+	for _, k := range keys {
+		if err = t.producers[k].Serialize(w); err != nil {
+			return
+		}
+	}
+
+	if err = common.WriteVarUint(w, uint64(len(t.records))); err != nil {
+		return
+	}
+	for _, r := range t.records {
+		if err = r.Serialize(w); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (t *PerformanceTracker) Deserialize(r io.Reader) (err error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.height, err = common.ReadUint32(r); err != nil {
+		return
+	}
+	if t.bestHeight, err = common.ReadUint32(r); err != nil {
+		return
+	}
+
+	var count uint64
+	if count, err = common.ReadVarUint(r, 0); err != nil {
+		return
+	}
+	t.producers = make(map[string]*ProducerPerformance, count)
+	for i := uint64(0); i < count; i++ {
+		p := &ProducerPerformance{}
+		if err = p.Deserialize(r); err != nil {
+			return
+		}
+		t.producers[common.BytesToHexString(p.NodePublicKey)] = p
+	}
+
+	if count, err = common.ReadVarUint(r, 0); err != nil {
+		return
+	}
+	t.records = make([]*performanceRecord, 0, count)
+	for i := uint64(0); i < count; i++ {
+		record := &performanceRecord{}
+		if err = record.Deserialize(r); err != nil {
+			return
+		}
+		t.records = append(t.records, record)
+	}
+	return
+}
+
+// NewPerformanceTracker creates a producer performance tracker with nothing
+// tracked.
+func NewPerformanceTracker() *PerformanceTracker {
+	return &PerformanceTracker{
+		producers: make(map[string]*ProducerPerformance),
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package state
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types"
+	common2 "github.com/elastos/Elastos.ELA/core/types/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func randomPerformanceArbiters(count int) []ArbiterMember {
+	arbiters := make([]ArbiterMember, 0, count)
+	for i := 0; i < count; i++ {
+		a, _ := NewOriginArbiter(randomPublicKey())
+		arbiters = append(arbiters, a)
+	}
+	return arbiters
+}
+
+func performanceConfirm(sponsor []byte, viewOffset uint32,
+	signers ...ArbiterMember) *payload.Confirm {
+	confirm := &payload.Confirm{
+		Proposal: payload.DPOSProposal{
+			Sponsor:    sponsor,
+			ViewOffset: viewOffset,
+		},
+	}
+	for _, s := range signers {
+		confirm.Votes = append(confirm.Votes, payload.DPOSProposalVote{
+			Signer: s.GetNodePublicKey(),
+			Accept: true,
+		})
+	}
+	return confirm
+}
+
+func TestPerformanceTracker_ProcessBlock(t *testing.T) {
+	tracker := NewPerformanceTracker()
+	arbiters := randomPerformanceArbiters(4)
+	sponsor := arbiters[1].GetNodePublicKey()
+
+	// The arbiter on duty at offset 0 failed to propose, and the last arbiter
+	// did not vote.
+	block := performanceBlock(10)
+	confirm := performanceConfirm(sponsor, 1, arbiters[0], arbiters[1],
+		arbiters[2])
+	tracker.ProcessBlock(block, confirm, sponsor, arbiters, 0, true)
+
+	p, ok := tracker.GetPerformance(sponsor)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(1), p.BlocksProposed)
+	assert.Equal(t, uint32(1), p.VotesCast)
+	assert.Equal(t, float64(1), p.Uptime())
+
+	p, _ = tracker.GetPerformance(arbiters[0].GetNodePublicKey())
+	assert.Equal(t, uint32(1), p.ViewChangesCaused)
+	assert.Equal(t, uint32(0), p.RoundsMissed)
+
+	p, _ = tracker.GetPerformance(arbiters[3].GetNodePublicKey())
+	assert.Equal(t, uint32(1), p.RoundsMissed)
+	assert.Equal(t, uint32(1), p.Rounds)
+	assert.Equal(t, float64(0), p.Uptime())
+
+	// Blocks not higher than the best height are ignored.
+	tracker.ProcessBlock(block, confirm, sponsor, arbiters, 0, true)
+	p, _ = tracker.GetPerformance(sponsor)
+	assert.Equal(t, uint32(1), p.BlocksProposed)
+
+	assert.Equal(t, 4, len(tracker.GetPerformances()))
+}
+
+func TestPerformanceTracker_RollbackTo(t *testing.T) {
+	tracker := NewPerformanceTracker()
+	arbiters := randomPerformanceArbiters(4)
+
+	for i := uint32(1); i <= 4; i++ {
+		sponsor := arbiters[i%4].GetNodePublicKey()
+		tracker.ProcessBlock(performanceBlock(i),
+			performanceConfirm(sponsor, 0, arbiters...), sponsor,
+			arbiters, int(i), true)
+	}
+	p, _ := tracker.GetPerformance(arbiters[0].GetNodePublicKey())
+	assert.Equal(t, uint32(1), p.BlocksProposed)
+	assert.Equal(t, uint32(4), p.VotesCast)
+
+	// Local observations are kept after rollback.
+	tracker.OnProposalReceived(arbiters[0].GetNodePublicKey())
+	assert.NoError(t, tracker.OnRollbackTo(2))
+	assert.Equal(t, uint32(2), tracker.BestHeight())
+
+	p, _ = tracker.GetPerformance(arbiters[0].GetNodePublicKey())
+	assert.Equal(t, uint32(0), p.BlocksProposed)
+	assert.Equal(t, uint32(2), p.VotesCast)
+	assert.Equal(t, uint32(2), p.Rounds)
+	assert.Equal(t, uint32(1), p.ProposalsReceived)
+}
+
+func TestPerformanceTracker_Window(t *testing.T) {
+	tracker := NewPerformanceTracker()
+	arbiters := randomPerformanceArbiters(2)
+	sponsor := arbiters[0].GetNodePublicKey()
+
+	count := PerformanceWindow + 10
+	for i := uint32(1); i <= count; i++ {
+		tracker.ProcessBlock(performanceBlock(i),
+			performanceConfirm(sponsor, 0), sponsor, arbiters, 0, true)
+	}
+	p, _ := tracker.GetPerformance(arbiters[1].GetNodePublicKey())
+	assert.Equal(t, count, p.RoundsMissed)
+	assert.Equal(t, PerformanceWindow, p.Rounds)
+	assert.Equal(t, uint32(0), p.RoundsParticipated)
+}
+
+func TestPerformanceTracker_Deserialize(t *testing.T) {
+	tracker := NewPerformanceTracker()
+	arbiters := randomPerformanceArbiters(3)
+	sponsor := arbiters[0].GetNodePublicKey()
+	tracker.ProcessBlock(performanceBlock(5),
+		performanceConfirm(sponsor, 2, arbiters[0], arbiters[1]), sponsor,
+		arbiters, 1, true)
+	tracker.OnVoteReceived(arbiters[1].GetNodePublicKey())
+	tracker.OnInactiveArbitersDetected([]string{
+		common.BytesToHexString(arbiters[2].GetNodePublicKey())})
+	tracker.SetHeight(5)
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, tracker.Serialize(buf))
+	tracker2 := NewPerformanceTracker()
+	assert.NoError(t, tracker2.Deserialize(buf))
+
+	assert.Equal(t, tracker.GetHeight(), tracker2.GetHeight())
+	assert.Equal(t, tracker.BestHeight(), tracker2.BestHeight())
+	assert.Equal(t, tracker.GetPerformances(), tracker2.GetPerformances())
+}
+
+func performanceBlock(height uint32) *types.Block {
+	return &types.Block{Header: common2.Header{Height: height}}
+}
@@ -184,6 +184,10 @@ func (p *Producer) Penalty() common.Fixed64 {
 	return p.penalty
 }
 
+func (p *Producer) DepositHash() common.Uint168 {
+	return p.depositHash
+}
+
 func (p *Producer) InactiveSince() uint32 {
 	return p.inactiveSince
 }
@@ -2843,6 +2847,26 @@ func (s *State) RemoveSpecialTx(hash common.Uint256) {
 	delete(s.SpecialTxHashes, hash)
 }
 
+// GetIllegalPenaltyByHeight returns the penalty deducted from the deposit of
+// producers and CR members for illegal behaviors at the given height.
+func (s *State) GetIllegalPenaltyByHeight(height uint32) common.Fixed64 {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.getIllegalPenaltyByHeight(height)
+}
+
+// GetEmergencyInactivePenaltyByHeight returns the penalty deducted from the
+// deposit of producers set to inactive by an inactive arbitrators
+// transaction at the given height.
+func (s *State) GetEmergencyInactivePenaltyByHeight(height uint32) common.Fixed64 {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	if height >= s.VersionStartHeight && height < s.VersionEndHeight {
+		return 0
+	}
+	return s.ChainParams.DPoSConfiguration.EmergencyInactivePenalty
+}
+
 func (s *State) getIllegalPenaltyByHeight(height uint32) common.Fixed64 {
 	var illegalPenalty common.Fixed64
 	if height >= s.DPoSV2ActiveHeight {
@@ -3851,18 +3875,24 @@ func (s *State) IsIrreversible(curBlockHeight uint32, detachNodesLen int) bool {
 func (s *State) handleEvents(event *events.Event) {
 	switch event.Type {
 	case events.ETCRCChangeCommittee:
-		s.mtx.Lock()
-		nodePublicKeyMap := s.getAllNodePublicKey()
-		for nodePubKey := range s.NodeOwnerKeys {
-			_, ok := nodePublicKeyMap[nodePubKey]
-			if !ok {
-				delete(s.NodeOwnerKeys, nodePubKey)
-			}
+		s.ChangeCommittee()
+	}
+}
+
+// ChangeCommittee updates the node owner keys of the CR members when the CR
+// committee changed.
+func (s *State) ChangeCommittee() {
+	s.mtx.Lock()
+	nodePublicKeyMap := s.getAllNodePublicKey()
+	for nodePubKey := range s.NodeOwnerKeys {
+		_, ok := nodePublicKeyMap[nodePubKey]
+		if !ok {
+			delete(s.NodeOwnerKeys, nodePubKey)
 		}
-		s.CurrentCRNodeOwnerKeys = copyStringMap(s.NextCRNodeOwnerKeys)
-		s.NextCRNodeOwnerKeys = make(map[string]string)
-		s.mtx.Unlock()
 	}
+	s.CurrentCRNodeOwnerKeys = copyStringMap(s.NextCRNodeOwnerKeys)
+	s.NextCRNodeOwnerKeys = make(map[string]string)
+	s.mtx.Unlock()
 }
 
 // NewState returns a new State instance.
@@ -3878,7 +3908,30 @@ func NewState(chainParams *config.Configuration, getArbiters func() []*ArbiterIn
 		illegalPenalty common.Fixed64),
 	updateCRInactivePenalty func(cid common.Uint168, height uint32),
 	revertUpdateCRInactivePenalty func(cid common.Uint168, height uint32)) *State {
-	state := State{
+	state := newState(chainParams, getArbiters, getCRMembers, getNextCRMembers,
+		isInElectionPeriod, getProducerDepositAmount,
+		tryUpdateCRMemberInactivity, tryRevertCRMemberInactivityfunc,
+		tryUpdateCRMemberIllegal, tryRevertCRMemberIllegal,
+		updateCRInactivePenalty, revertUpdateCRInactivePenalty)
+	events.Subscribe(state.handleEvents)
+	return state
+}
+
+// newState returns a new State instance which does not subscribe to the
+// chain events.
+func newState(chainParams *config.Configuration, getArbiters func() []*ArbiterInfo,
+	getCRMembers func() []*state.CRMember,
+	getNextCRMembers func() []*state.CRMember,
+	isInElectionPeriod func() bool,
+	getProducerDepositAmount func(common.Uint168) (common.Fixed64, error),
+	tryUpdateCRMemberInactivity func(did common.Uint168, needReset bool, height uint32),
+	tryRevertCRMemberInactivityfunc func(did common.Uint168, oriState state.MemberState, oriInactiveCount uint32, height uint32),
+	tryUpdateCRMemberIllegal func(did common.Uint168, height uint32, illegalPenalty common.Fixed64),
+	tryRevertCRMemberIllegal func(did common.Uint168, oriState state.MemberState, height uint32,
+		illegalPenalty common.Fixed64),
+	updateCRInactivePenalty func(cid common.Uint168, height uint32),
+	revertUpdateCRInactivePenalty func(cid common.Uint168, height uint32)) *State {
+	return &State{
 		ChainParams:                   chainParams,
 		GetArbiters:                   getArbiters,
 		getCurrentCRMembers:           getCRMembers,
@@ -3894,6 +3947,4 @@ func NewState(chainParams *config.Configuration, getArbiters func() []*ArbiterIn
 		updateCRInactivePenalty:       updateCRInactivePenalty,
 		revertUpdateCRInactivePenalty: revertUpdateCRInactivePenalty,
 	}
-	events.Subscribe(state.handleEvents)
-	return &state
 }
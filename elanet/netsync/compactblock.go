@@ -0,0 +1,187 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package netsync
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types"
+	"github.com/elastos/Elastos.ELA/core/types/interfaces"
+	"github.com/elastos/Elastos.ELA/crypto"
+	"github.com/elastos/Elastos.ELA/p2p/msg"
+)
+
+const (
+	// compactBlockTimeout is the maximum time to wait for the missing
+	// transactions of a compact block before giving it up.
+	compactBlockTimeout = time.Second * 30
+
+	// maxPeerCompactBlocks is the maximum number of compact blocks waiting
+	// for missing transactions from one peer.  The full block is requested
+	// when exceeded.
+	maxPeerCompactBlocks = 8
+)
+
+// NewCompactBlock returns a compact block of the given block with the given
+// nonce.  Only the coinbase is prefilled, the receiver is supposed to have
+// other transactions in its transaction pool.
+func NewCompactBlock(block *types.DposBlock, nonce uint64) *msg.CmpctBlock {
+	header := &types.DPOSHeader{
+		Header:      block.Header,
+		HaveConfirm: block.HaveConfirm,
+	}
+	if block.HaveConfirm {
+		header.Confirm = *block.Confirm
+	}
+
+	cmpct := msg.NewCmpctBlock(header)
+	cmpct.Nonce = nonce
+	key := msg.ShortIDKey(block.Hash(), nonce)
+	for i, tx := range block.Transactions {
+		if i == 0 {
+			cmpct.PrefilledTxs = append(cmpct.PrefilledTxs,
+				&msg.PrefilledTx{Index: 0, Tx: tx})
+			continue
+		}
+		cmpct.ShortIDs = append(cmpct.ShortIDs, msg.ShortTxID(key, tx.Hash()))
+	}
+	return cmpct
+}
+
+// partialBlock is a block being rebuilt from a compact block.
+type partialBlock struct {
+	header  *types.DPOSHeader
+	txs     []interfaces.Transaction
+	missing []uint32
+
+	// requested is the time the missing transactions were requested.
+	requested time.Time
+}
+
+// newPartialBlock rebuilds the block of the given compact block with
+// transactions in the pool.  Transactions not found in the pool are recorded
+// as missing.  An error is returned if the compact block is malformed.
+func newPartialBlock(cmpct *msg.CmpctBlock,
+	poolTxs []interfaces.Transaction) (*partialBlock, error) {
+	header, ok := cmpct.Header.(*types.DPOSHeader)
+	if !ok {
+		return nil, errors.New("invalid compact block header")
+	}
+	if cmpct.TxCount() == 0 {
+		return nil, errors.New("empty compact block")
+	}
+
+	txs := make([]interfaces.Transaction, cmpct.TxCount())
+	for _, p := range cmpct.PrefilledTxs {
+		if p.Index >= uint32(len(txs)) {
+			return nil, fmt.Errorf("prefilled transaction index %d out of"+
+				" range", p.Index)
+		}
+		if txs[p.Index] != nil {
+			return nil, fmt.Errorf("duplicate prefilled transaction index"+
+				" %d", p.Index)
+		}
+		txs[p.Index] = p.Tx
+	}
+
+	// Map short IDs to the indexes of transactions in the block.
+	indexes := make(map[uint64]uint32, len(cmpct.ShortIDs))
+	next := 0
+	for i := range txs {
+		if txs[i] != nil {
+			continue
+		}
+		id := cmpct.ShortIDs[next]
+		if _, ok := indexes[id]; ok {
+			return nil, fmt.Errorf("duplicate short ID %x", id)
+		}
+		indexes[id] = uint32(i)
+		next++
+	}
+
+	// Fill in transactions from the pool, the transaction is treated as
+	// missing if more than one transaction in the pool has the short ID.
+	key := msg.ShortIDKey(header.Header.Hash(), cmpct.Nonce)
+	collisions := make(map[uint32]struct{})
+	for _, tx := range poolTxs {
+		index, ok := indexes[msg.ShortTxID(key, tx.Hash())]
+		if !ok {
+			continue
+		}
+		if _, ok := collisions[index]; ok {
+			continue
+		}
+		if txs[index] != nil {
+			txs[index] = nil
+			collisions[index] = struct{}{}
+			continue
+		}
+		txs[index] = tx
+	}
+
+	pb := &partialBlock{header: header, txs: txs}
+	for i, tx := range txs {
+		if tx == nil {
+			pb.missing = append(pb.missing, uint32(i))
+		}
+	}
+	return pb, nil
+}
+
+// hash returns the hash of the block being rebuilt.
+func (pb *partialBlock) hash() common.Uint256 {
+	return pb.header.Header.Hash()
+}
+
+// fill fills in the missing transactions, which are given in the order of
+// the missing indexes.
+func (pb *partialBlock) fill(txs []interfaces.Transaction) error {
+	if len(txs) != len(pb.missing) {
+		return fmt.Errorf("expect %d missing transactions, got %d",
+			len(pb.missing), len(txs))
+	}
+	for i, index := range pb.missing {
+		pb.txs[index] = txs[i]
+	}
+	pb.missing = nil
+	return nil
+}
+
+// block returns the rebuilt block.  An error is returned if transactions are
+// missing or do not match the merkle root of the header, which means short
+// IDs collided with wrong transactions.
+func (pb *partialBlock) block() (*types.DposBlock, error) {
+	if len(pb.missing) > 0 {
+		return nil, fmt.Errorf("%d transactions missing", len(pb.missing))
+	}
+	hashes := make([]common.Uint256, 0, len(pb.txs))
+	for _, tx := range pb.txs {
+		hashes = append(hashes, tx.Hash())
+	}
+	root, err := crypto.ComputeRoot(hashes)
+	if err != nil {
+		return nil, err
+	}
+	if !root.IsEqual(pb.header.MerkleRoot) {
+		return nil, errors.New("merkle root mismatch")
+	}
+
+	block := &types.DposBlock{
+		Block: &types.Block{
+			Header:       pb.header.Header,
+			Transactions: pb.txs,
+		},
+		HaveConfirm: pb.header.HaveConfirm,
+	}
+	if pb.header.HaveConfirm {
+		confirm := pb.header.Confirm
+		block.Confirm = &confirm
+	}
+	return block, nil
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package netsync
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/contract/program"
+	transaction2 "github.com/elastos/Elastos.ELA/core/transaction"
+	"github.com/elastos/Elastos.ELA/core/types"
+	common2 "github.com/elastos/Elastos.ELA/core/types/common"
+	"github.com/elastos/Elastos.ELA/core/types/functions"
+	"github.com/elastos/Elastos.ELA/core/types/interfaces"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/crypto"
+	"github.com/elastos/Elastos.ELA/elanet/peer"
+	"github.com/elastos/Elastos.ELA/p2p/msg"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	functions.GetTransactionByTxType = transaction2.GetTransaction
+	functions.GetTransactionByBytes = transaction2.GetTransactionByBytes
+	functions.CreateTransaction = transaction2.CreateTransaction
+}
+
+func newCompactTestTx(txType common2.TxType, p interfaces.Payload,
+	nonce uint32) interfaces.Transaction {
+	return functions.CreateTransaction(
+		0,
+		txType,
+		0,
+		p,
+		[]*common2.Attribute{},
+		[]*common2.Input{},
+		[]*common2.Output{{
+			Value:       common.Fixed64(nonce),
+			ProgramHash: common.Uint168{1},
+		}},
+		nonce,
+		[]*program.Program{},
+	)
+}
+
+func newCompactTestBlock(count int) *types.DposBlock {
+	txs := []interfaces.Transaction{
+		newCompactTestTx(common2.CoinBase, &payload.CoinBase{}, 0),
+	}
+	for i := 1; i < count; i++ {
+		txs = append(txs, newCompactTestTx(common2.TransferAsset,
+			&payload.TransferAsset{}, uint32(i)))
+	}
+	hashes := make([]common.Uint256, 0, len(txs))
+	for _, tx := range txs {
+		hashes = append(hashes, tx.Hash())
+	}
+	root, _ := crypto.ComputeRoot(hashes)
+	return &types.DposBlock{
+		Block: &types.Block{
+			Header: common2.Header{
+				Height:     100,
+				MerkleRoot: root,
+			},
+			Transactions: txs,
+		},
+	}
+}
+
+func TestCompactBlock(t *testing.T) {
+	block := newCompactTestBlock(10)
+	cmpct := NewCompactBlock(block, 12345)
+	assert.Equal(t, 10, cmpct.TxCount())
+	assert.Equal(t, 1, len(cmpct.PrefilledTxs))
+
+	// The compact block should be the same after transferred.
+	buf := new(bytes.Buffer)
+	assert.NoError(t, cmpct.Serialize(buf))
+	received := msg.NewCmpctBlock(&types.DPOSHeader{})
+	assert.NoError(t, received.Deserialize(buf))
+	assert.Equal(t, cmpct.Nonce, received.Nonce)
+	assert.Equal(t, cmpct.ShortIDs, received.ShortIDs)
+	assert.Equal(t, block.Transactions[0].Hash(),
+		received.PrefilledTxs[0].Tx.Hash())
+
+	// Rebuild the block with all transactions in the pool.
+	pool := append([]interfaces.Transaction{
+		newCompactTestTx(common2.TransferAsset, &payload.TransferAsset{}, 100),
+	}, block.Transactions[1:]...)
+	pb, err := newPartialBlock(received, pool)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(pb.missing))
+	rebuilt, err := pb.block()
+	assert.NoError(t, err)
+	assert.Equal(t, block.Hash(), rebuilt.Hash())
+	assert.Equal(t, len(block.Transactions), len(rebuilt.Transactions))
+
+	// Transactions not in the pool are missing.
+	pb, err = newPartialBlock(received, block.Transactions[4:])
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{1, 2, 3}, pb.missing)
+	_, err = pb.block()
+	assert.Error(t, err)
+	assert.Error(t, pb.fill(block.Transactions[1:3]))
+	assert.NoError(t, pb.fill(block.Transactions[1:4]))
+	rebuilt, err = pb.block()
+	assert.NoError(t, err)
+	assert.Equal(t, block.Hash(), rebuilt.Hash())
+
+	// Wrong transactions do not match the merkle root.
+	pb, err = newPartialBlock(received, block.Transactions[2:])
+	assert.NoError(t, err)
+	assert.NoError(t, pb.fill([]interfaces.Transaction{pool[0]}))
+	_, err = pb.block()
+	assert.Error(t, err)
+}
+
+func TestCompactBlock_Malformed(t *testing.T) {
+	block := newCompactTestBlock(3)
+
+	cmpct := NewCompactBlock(block, 1)
+	cmpct.PrefilledTxs[0].Index = 3
+	_, err := newPartialBlock(cmpct, nil)
+	assert.Error(t, err)
+
+	cmpct = NewCompactBlock(block, 1)
+	cmpct.ShortIDs[1] = cmpct.ShortIDs[0]
+	_, err = newPartialBlock(cmpct, nil)
+	assert.Error(t, err)
+
+	cmpct = NewCompactBlock(block, 1)
+	cmpct.PrefilledTxs = nil
+	cmpct.ShortIDs = nil
+	_, err = newPartialBlock(cmpct, nil)
+	assert.Error(t, err)
+}
+
+func TestShortTxID(t *testing.T) {
+	block := newCompactTestBlock(2)
+	txHash := block.Transactions[1].Hash()
+
+	key := msg.ShortIDKey(block.Hash(), 1)
+	id := msg.ShortTxID(key, txHash)
+	assert.Equal(t, id, msg.ShortTxID(key, txHash))
+	assert.True(t, id < 1<<(msg.ShortIDSize*8))
+
+	// Short IDs differ with different nonces.
+	assert.NotEqual(t, id, msg.ShortTxID(msg.ShortIDKey(block.Hash(), 2),
+		txHash))
+}
+
+func TestExpireCompactBlocks(t *testing.T) {
+	p := &peer.Peer{}
+	stale, fresh := common.Uint256{1}, common.Uint256{2}
+	state := &peerSyncState{
+		requestedBlocks: map[common.Uint256]struct{}{
+			stale: {}, fresh: {}},
+		requestedConfirmedBlocks: make(map[common.Uint256]struct{}),
+		compactBlocks: map[common.Uint256]*partialBlock{
+			stale: {requested: time.Now().Add(-compactBlockTimeout)},
+			fresh: {requested: time.Now()},
+		},
+	}
+	sm := &SyncManager{
+		peerStates:               map[*peer.Peer]*peerSyncState{p: state},
+		requestedBlocks:          map[common.Uint256]struct{}{stale: {}, fresh: {}},
+		requestedConfirmedBlocks: make(map[common.Uint256]struct{}),
+	}
+
+	// The stale compact block is given up so it can be requested from
+	// other peers.
+	sm.expireCompactBlocks()
+	assert.Equal(t, 1, len(state.compactBlocks))
+	assert.NotNil(t, state.compactBlocks[fresh])
+	_, ok := sm.requestedBlocks[stale]
+	assert.False(t, ok)
+	_, ok = state.requestedBlocks[stale]
+	assert.False(t, ok)
+	_, ok = sm.requestedBlocks[fresh]
+	assert.True(t, ok)
+}
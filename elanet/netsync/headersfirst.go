@@ -0,0 +1,428 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package netsync
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/blockchain"
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types"
+	common2 "github.com/elastos/Elastos.ELA/core/types/common"
+	"github.com/elastos/Elastos.ELA/elanet/pact"
+	"github.com/elastos/Elastos.ELA/elanet/peer"
+	elaerr "github.com/elastos/Elastos.ELA/errors"
+	"github.com/elastos/Elastos.ELA/p2p"
+	"github.com/elastos/Elastos.ELA/p2p/msg"
+)
+
+const (
+	// minHeadersFirstBlocks is the minimum number of blocks the sync peer
+	// must be ahead of us to sync in headers-first mode, the getblocks path
+	// is used to catch up with fewer blocks.
+	minHeadersFirstBlocks = pact.MaxBlocksPerMsg
+
+	// blockDownloadWindow is the maximum number of blocks after the best
+	// block that can be requested in headers-first mode.  Blocks are
+	// downloaded in parallel within the window, and connected in order.
+	blockDownloadWindow = 1024
+
+	// maxBlocksInFlightPerPeer is the maximum number of blocks can be
+	// requested from one peer at the same time in headers-first mode.
+	maxBlocksInFlightPerPeer = 16
+
+	// blockStallTimeout is the maximum allowable interval for the next block
+	// to connect being in flight, the peer stalling the download window is
+	// disconnected after the interval.
+	blockStallTimeout = time.Second * 30
+
+	// stallSampleInterval is the interval to check stalled block requests in
+	// headers-first mode.
+	stallSampleInterval = time.Second * 5
+)
+
+// errHeadersNotConnected is returned when the first received header does not
+// connect to the best block, which means the sync peer is on another branch.
+var errHeadersNotConnected = errors.New("headers do not connect to best block")
+
+// headerNode is a validated block header waiting for its block body.
+type headerNode struct {
+	height uint32
+	hash   common.Uint256
+}
+
+// blockRequest is a block requested in headers-first mode.
+type blockRequest struct {
+	peer   *peer.Peer
+	height uint32
+	time   time.Time
+}
+
+// receivedBlock is a block received in headers-first mode, waiting for its
+// previous blocks to connect.
+type receivedBlock struct {
+	block *types.DposBlock
+	peer  *peer.Peer
+}
+
+// connectHeaders validates the headers following the given previous header,
+// and returns the header nodes of them.  errHeadersNotConnected is returned if
+// the first header does not follow the previous header.
+func connectHeaders(prev *headerNode, headers []*common2.Header,
+	checkHeader func(*common2.Header) error) ([]*headerNode, error) {
+	nodes := make([]*headerNode, 0, len(headers))
+	for i, header := range headers {
+		if !header.Previous.IsEqual(prev.hash) ||
+			header.Height != prev.height+1 {
+			if i == 0 {
+				return nil, errHeadersNotConnected
+			}
+			return nil, fmt.Errorf("header at height %d does not connect"+
+				" to previous header", header.Height)
+		}
+		if err := checkHeader(header); err != nil {
+			return nil, err
+		}
+
+		node := &headerNode{height: header.Height, hash: header.Hash()}
+		nodes = append(nodes, node)
+		prev = node
+	}
+	return nodes, nil
+}
+
+// isHeadersFirstCandidate returns whether or not to sync from the peer in
+// headers-first mode.  Peers can not serve headers are synced from with the
+// getblocks path.
+func (sm *SyncManager) isHeadersFirstCandidate(peer *peer.Peer,
+	bestHeight uint32) bool {
+	return peer.Services()&pact.SFNodeHeaders == pact.SFNodeHeaders &&
+		peer.Height() >= bestHeight+minHeadersFirstBlocks
+}
+
+// startHeadersFirst starts downloading headers from the sync peer.
+func (sm *SyncManager) startHeadersFirst(peer *peer.Peer,
+	locator []*common.Uint256) {
+	sm.resetHeadersFirst()
+	sm.headersFirstMode = true
+	sm.lastHeader = &headerNode{
+		height: sm.chain.GetHeight(),
+		hash:   sm.chain.GetCurrentBlockHash(),
+	}
+
+	log.Infof("Downloading headers for blocks %d to %d from peer %s",
+		sm.lastHeader.height+1, peer.Height(), peer.Addr())
+	peer.QueueMessage(msg.NewGetHeaders(locator, zeroHash), nil)
+}
+
+// resetHeadersFirst clears all state of headers-first mode.
+func (sm *SyncManager) resetHeadersFirst() {
+	for hash, request := range sm.blockRequests {
+		delete(sm.requestedConfirmedBlocks, hash)
+		if state, ok := sm.peerStates[request.peer]; ok {
+			delete(state.requestedConfirmedBlocks, hash)
+		}
+	}
+	sm.headersFirstMode = false
+	sm.headersDone = false
+	sm.headerList.Init()
+	sm.lastHeader = nil
+	sm.blockRequests = make(map[common.Uint256]*blockRequest)
+	sm.receivedBlocks = make(map[common.Uint256]*receivedBlock)
+}
+
+// clearBlockRequests removes blocks requested from the peer in headers-first
+// mode, so that they will be requested from other peers.
+func (sm *SyncManager) clearBlockRequests(peer *peer.Peer) {
+	state, exists := sm.peerStates[peer]
+	for hash, request := range sm.blockRequests {
+		if request.peer != peer {
+			continue
+		}
+		delete(sm.blockRequests, hash)
+		delete(sm.requestedConfirmedBlocks, hash)
+		if exists {
+			delete(state.requestedConfirmedBlocks, hash)
+		}
+	}
+}
+
+// handleHeadersMsg handles headers messages from all peers.
+func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
+	peer := hmsg.peer
+	if _, exists := sm.peerStates[peer]; !exists {
+		log.Warnf("Received headers message from unknown peer %s", peer)
+		return
+	}
+
+	if !sm.headersFirstMode || peer != sm.syncPeer || sm.headersDone {
+		log.Warnf("Got unrequested headers from %s -- disconnecting", peer)
+		peer.Disconnect()
+		return
+	}
+
+	// An empty headers message means the peer has no more headers.
+	headers := hmsg.headers.Headers
+	if len(headers) == 0 {
+		sm.headersDone = true
+		sm.connectBlocks()
+		return
+	}
+
+	nodes, err := connectHeaders(sm.lastHeader, headers,
+		sm.chain.CheckHeaderSanity)
+	if err == errHeadersNotConnected && sm.headerList.Len() == 0 &&
+		len(sm.blockRequests) == 0 {
+		// The sync peer is on another branch, fall back to the getblocks
+		// path which handles reorganizing with orphan blocks.
+		log.Infof("Headers from %s do not connect to best block, falling"+
+			" back to getblocks", peer)
+		sm.resetHeadersFirst()
+		locator, err := sm.chain.LatestBlockLocator()
+		if err != nil {
+			log.Errorf("Failed to get block locator for the "+
+				"latest block: %v", err)
+			return
+		}
+		peer.PushGetBlocksMsg(locator, &zeroHash)
+		return
+	}
+	if err != nil {
+		log.Warnf("Received invalid headers from %s, %v -- disconnecting",
+			peer, err)
+		peer.Disconnect()
+		return
+	}
+
+	for _, node := range nodes {
+		sm.headerList.PushBack(node)
+	}
+	sm.lastHeader = nodes[len(nodes)-1]
+	sm.syncStartTime = time.Now()
+
+	// Request more headers if the peer may have more.
+	if len(headers) < msg.MaxHeadersPerMsg ||
+		sm.lastHeader.height >= peer.Height() {
+		sm.headersDone = true
+		log.Infof("Downloaded headers to height %d from peer %s",
+			sm.lastHeader.height, peer.Addr())
+	} else {
+		locator := []*common.Uint256{&sm.lastHeader.hash}
+		peer.QueueMessage(msg.NewGetHeaders(locator, zeroHash), nil)
+	}
+
+	sm.fetchBlocks()
+}
+
+// selectBlockPeer returns the peer with the least blocks in flight that can
+// serve the block at the given height, or nil if all peers are busy.
+func (sm *SyncManager) selectBlockPeer(height uint32) *peer.Peer {
+	var best *peer.Peer
+	var bestInFlight int
+	for p, state := range sm.peerStates {
+		if !state.syncCandidate || p.Height() < height ||
+			height <= state.notFoundHeight {
+			continue
+		}
+
+		// A pruned peer only keeps the recent blocks.
+		if p.Services()&pact.SFNodePruned == pact.SFNodePruned &&
+			p.Height() > height+blockchain.MinPruneDepth {
+			continue
+		}
+		inFlight := len(state.requestedConfirmedBlocks)
+		if inFlight >= maxBlocksInFlightPerPeer {
+			continue
+		}
+		if best == nil || inFlight < bestInFlight {
+			best = p
+			bestInFlight = inFlight
+		}
+	}
+	return best
+}
+
+// fetchBlocks requests blocks in the download window from peers in parallel.
+func (sm *SyncManager) fetchBlocks() {
+	requests := make(map[*peer.Peer]*msg.GetData)
+	now := time.Now()
+	e := sm.headerList.Front()
+	for i := 0; e != nil && i < blockDownloadWindow; i++ {
+		node := e.Value.(*headerNode)
+		e = e.Next()
+		if _, ok := sm.blockRequests[node.hash]; ok {
+			continue
+		}
+		if _, ok := sm.receivedBlocks[node.hash]; ok {
+			continue
+		}
+
+		p := sm.selectBlockPeer(node.height)
+		if p == nil {
+			break
+		}
+		hash := node.hash
+		sm.blockRequests[hash] = &blockRequest{peer: p, height: node.height,
+			time: now}
+		sm.requestedConfirmedBlocks[hash] = struct{}{}
+		sm.peerStates[p].requestedConfirmedBlocks[hash] = struct{}{}
+
+		gdmsg, ok := requests[p]
+		if !ok {
+			gdmsg = msg.NewGetData()
+			requests[p] = gdmsg
+		}
+		gdmsg.AddInvVect(msg.NewInvVect(msg.InvTypeConfirmedBlock, &hash))
+	}
+
+	for p, gdmsg := range requests {
+		p.QueueMessage(gdmsg, nil)
+	}
+}
+
+// handleNotFoundMsg handles notfound messages from all peers.  The blocks
+// requested in headers-first mode are requested from other peers, and the
+// blocks requested otherwise will be fetched from elsewhere next time we get
+// an inv.
+func (sm *SyncManager) handleNotFoundMsg(nmsg *notFoundMsg) {
+	peer := nmsg.peer
+	state, exists := sm.peerStates[peer]
+	if !exists {
+		log.Warnf("Received notfound message from unknown peer %s", peer)
+		return
+	}
+
+	var refetch bool
+	for _, iv := range nmsg.notFound.InvList {
+		switch iv.Type {
+		case msg.InvTypeBlock, msg.InvTypeConfirmedBlock:
+		default:
+			continue
+		}
+
+		hash := iv.Hash
+		_, blockExist := state.requestedBlocks[hash]
+		_, confirmedBlockExist := state.requestedConfirmedBlocks[hash]
+		if !blockExist && !confirmedBlockExist {
+			log.Warnf("Got unrequested notfound block %v from %s -- "+
+				"disconnecting", hash, peer)
+			peer.Disconnect()
+			return
+		}
+		delete(state.requestedBlocks, hash)
+		delete(sm.requestedBlocks, hash)
+		delete(state.requestedConfirmedBlocks, hash)
+		delete(sm.requestedConfirmedBlocks, hash)
+
+		request, ok := sm.blockRequests[hash]
+		if !ok || request.peer != peer {
+			continue
+		}
+		log.Debugf("Peer %s does not have block %s at height %d", peer,
+			hash, request.height)
+		delete(sm.blockRequests, hash)
+		if request.height > state.notFoundHeight {
+			state.notFoundHeight = request.height
+		}
+		refetch = true
+	}
+
+	if refetch && sm.headersFirstMode {
+		sm.fetchBlocks()
+	}
+}
+
+// handleHeadersFirstBlock handles a block requested in headers-first mode.
+func (sm *SyncManager) handleHeadersFirstBlock(peer *peer.Peer,
+	block *types.DposBlock) {
+	hash := block.Hash()
+	delete(sm.blockRequests, hash)
+	sm.receivedBlocks[hash] = &receivedBlock{block: block, peer: peer}
+	sm.connectBlocks()
+}
+
+// connectBlocks processes received blocks in the order of headers, and
+// requests more blocks as the download window moves forward.
+func (sm *SyncManager) connectBlocks() {
+	for e := sm.headerList.Front(); e != nil; e = sm.headerList.Front() {
+		node := e.Value.(*headerNode)
+		received, ok := sm.receivedBlocks[node.hash]
+		if !ok {
+			break
+		}
+		delete(sm.receivedBlocks, node.hash)
+		sm.headerList.Remove(e)
+
+		log.Debugf("Receive block %s at height %d", node.hash, node.height)
+		_, _, err := sm.blockMemPool.AddDposBlock(received.block)
+		if err != nil {
+			// The header is valid but the block is not, restart syncing
+			// without the peer sent the block.
+			log.Warnf("Rejected block %s at height %d from %s, %v --"+
+				" disconnecting", node.hash, node.height, received.peer, err)
+			elaErr := elaerr.SimpleWithMessage(elaerr.ErrP2pReject, err,
+				fmt.Sprintf("Rejected block %v from %s", node.hash,
+					received.peer))
+			received.peer.PushRejectMsg(p2p.CmdBlock, elaErr, &node.hash, false)
+			received.peer.Disconnect()
+
+			sm.resetHeadersFirst()
+			sm.syncPeer = nil
+			sm.startSync()
+			return
+		}
+		sm.syncStartTime = time.Now()
+
+		// Clear the rejected transactions.
+		sm.rejectedTxns = make(map[common.Uint256]struct{})
+	}
+
+	if sm.headersDone && sm.headerList.Len() == 0 {
+		log.Infof("Headers-first sync finished at height %d",
+			sm.chain.GetHeight())
+		sm.resetHeadersFirst()
+		sm.syncPeer = nil
+		sm.startSync()
+		return
+	}
+
+	sm.fetchBlocks()
+}
+
+// handleStallSample disconnects the peer stalling the download window in
+// headers-first mode, and requests the blocks from other peers.
+func (sm *SyncManager) handleStallSample() {
+	if !sm.headersFirstMode {
+		return
+	}
+
+	e := sm.headerList.Front()
+	if e == nil {
+		return
+	}
+	node := e.Value.(*headerNode)
+	request, ok := sm.blockRequests[node.hash]
+	if !ok || time.Since(request.time) < blockStallTimeout {
+		return
+	}
+
+	log.Warnf("Peer %s stalled block %s at height %d for more than %v --"+
+		" disconnecting", request.peer, node.hash, node.height,
+		blockStallTimeout)
+	stalled := request.peer
+	stalled.Disconnect()
+	sm.clearBlockRequests(stalled)
+	if stalled == sm.syncPeer {
+		// The headers-first state is reset when the sync peer is done.
+		return
+	}
+	if state, ok := sm.peerStates[stalled]; ok {
+		state.syncCandidate = false
+	}
+	sm.fetchBlocks()
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package netsync
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/blockchain"
+	"github.com/elastos/Elastos.ELA/common"
+	common2 "github.com/elastos/Elastos.ELA/core/types/common"
+	"github.com/elastos/Elastos.ELA/elanet/pact"
+	"github.com/elastos/Elastos.ELA/elanet/peer"
+	"github.com/elastos/Elastos.ELA/p2p/msg"
+	p2ppeer "github.com/elastos/Elastos.ELA/p2p/peer"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newHeaderChain(prev *headerNode, count int) []*common2.Header {
+	headers := make([]*common2.Header, 0, count)
+	prevHash, height := prev.hash, prev.height
+	for i := 0; i < count; i++ {
+		height++
+		header := &common2.Header{
+			Previous:  prevHash,
+			Height:    height,
+			Timestamp: height,
+		}
+		headers = append(headers, header)
+		prevHash = header.Hash()
+	}
+	return headers
+}
+
+func TestConnectHeaders(t *testing.T) {
+	checkHeader := func(*common2.Header) error { return nil }
+	tip := &headerNode{height: 100, hash: common.Uint256{1}}
+	headers := newHeaderChain(tip, 10)
+
+	nodes, err := connectHeaders(tip, headers, checkHeader)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, len(nodes))
+	for i, node := range nodes {
+		assert.Equal(t, headers[i].Height, node.height)
+		assert.Equal(t, headers[i].Hash(), node.hash)
+	}
+
+	// Headers continue from the last node.
+	more := newHeaderChain(nodes[len(nodes)-1], 5)
+	nodes, err = connectHeaders(nodes[len(nodes)-1], more, checkHeader)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(115), nodes[len(nodes)-1].height)
+
+	// Headers of another branch.
+	other := &headerNode{height: 100, hash: common.Uint256{2}}
+	_, err = connectHeaders(other, headers, checkHeader)
+	assert.Equal(t, errHeadersNotConnected, err)
+
+	// Headers not connected in the middle.
+	broken := newHeaderChain(tip, 10)
+	broken[5].Previous = common.Uint256{3}
+	_, err = connectHeaders(tip, broken, checkHeader)
+	assert.Error(t, err)
+	assert.NotEqual(t, errHeadersNotConnected, err)
+
+	broken = newHeaderChain(tip, 10)
+	broken[3].Height = 200
+	_, err = connectHeaders(tip, broken, checkHeader)
+	assert.Error(t, err)
+
+	// Invalid headers.
+	_, err = connectHeaders(tip, headers, func(h *common2.Header) error {
+		if h.Height == 105 {
+			return errors.New("invalid aux pow")
+		}
+		return nil
+	})
+	assert.EqualError(t, err, "invalid aux pow")
+}
+
+func TestHeadersMsg(t *testing.T) {
+	tip := &headerNode{height: 0, hash: common.Uint256{}}
+	headers := newHeaderChain(tip, 3)
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, msg.NewHeaders(headers).Serialize(buf))
+	var received msg.Headers
+	assert.NoError(t, received.Deserialize(buf))
+	assert.Equal(t, len(headers), len(received.Headers))
+	for i, header := range received.Headers {
+		assert.Equal(t, headers[i].Hash(), header.Hash())
+	}
+
+	buf.Reset()
+	tooMany := make([]*common2.Header, msg.MaxHeadersPerMsg+1)
+	assert.Error(t, msg.NewHeaders(tooMany).Serialize(buf))
+
+	buf.Reset()
+	hash := headers[2].Hash()
+	locator := []*common.Uint256{&hash, &common.Uint256{}}
+	assert.NoError(t, msg.NewGetHeaders(locator, common.Uint256{}).Serialize(buf))
+	var getHeaders msg.GetHeaders
+	assert.NoError(t, getHeaders.Deserialize(buf))
+	assert.Equal(t, 2, len(getHeaders.Locator))
+	assert.Equal(t, hash, *getHeaders.Locator[0])
+}
+
+func newSyncTestPeer(services pact.ServiceFlag, height uint32) *peer.Peer {
+	p := &peer.Peer{Peer: p2ppeer.NewInboundPeer(
+		&p2ppeer.Config{Services: uint64(services)})}
+	p.UpdateHeight(height)
+	return p
+}
+
+func newSyncTestState() *peerSyncState {
+	return &peerSyncState{
+		syncCandidate:            true,
+		requestedBlocks:          make(map[common.Uint256]struct{}),
+		requestedConfirmedBlocks: make(map[common.Uint256]struct{}),
+	}
+}
+
+func TestSelectBlockPeer(t *testing.T) {
+	full := newSyncTestPeer(pact.SFNodeNetwork, 5000)
+	pruned := newSyncTestPeer(pact.SFNodePruned, 5000)
+	sm := &SyncManager{peerStates: map[*peer.Peer]*peerSyncState{
+		full:   newSyncTestState(),
+		pruned: newSyncTestState(),
+	}}
+
+	// The pruned peer does not serve the blocks below its prune depth.
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, full, sm.selectBlockPeer(10))
+	}
+	sm.peerStates[full].syncCandidate = false
+	assert.Nil(t, sm.selectBlockPeer(10))
+	assert.Equal(t, pruned, sm.selectBlockPeer(5000-blockchain.MinPruneDepth))
+
+	// Blocks not found by the peer are not requested from it again.
+	sm.peerStates[pruned].notFoundHeight = 4000
+	assert.Nil(t, sm.selectBlockPeer(4000))
+	assert.Equal(t, pruned, sm.selectBlockPeer(4001))
+}
+
+func TestHandleNotFoundMsg(t *testing.T) {
+	p1 := newSyncTestPeer(pact.SFNodeNetwork, 100)
+	p2 := newSyncTestPeer(pact.SFNodeNetwork, 100)
+	sm := &SyncManager{
+		peerStates: map[*peer.Peer]*peerSyncState{
+			p1: newSyncTestState(),
+			p2: newSyncTestState(),
+		},
+		requestedBlocks:          make(map[common.Uint256]struct{}),
+		requestedConfirmedBlocks: make(map[common.Uint256]struct{}),
+		headersFirstMode:         true,
+		headerList:               list.New(),
+		blockRequests:            make(map[common.Uint256]*blockRequest),
+		receivedBlocks:           make(map[common.Uint256]*receivedBlock),
+	}
+	node := &headerNode{height: 10, hash: common.Uint256{10}}
+	sm.headerList.PushBack(node)
+	notFound := msg.NewNotFound()
+	notFound.AddInvVect(msg.NewInvVect(msg.InvTypeConfirmedBlock, &node.hash))
+
+	// The block not found is requested from the other peer.
+	sm.fetchBlocks()
+	first := sm.blockRequests[node.hash].peer
+	sm.handleNotFoundMsg(&notFoundMsg{notFound: notFound, peer: first})
+	assert.Equal(t, uint32(10), sm.peerStates[first].notFoundHeight)
+	assert.Equal(t, 0, len(sm.peerStates[first].requestedConfirmedBlocks))
+	second := sm.blockRequests[node.hash].peer
+	assert.NotEqual(t, first, second)
+
+	// No peer is able to serve the block.
+	sm.handleNotFoundMsg(&notFoundMsg{notFound: notFound, peer: second})
+	assert.Equal(t, 0, len(sm.blockRequests))
+	assert.Equal(t, 0, len(sm.requestedConfirmedBlocks))
+}
@@ -6,6 +6,7 @@
 package netsync
 
 import (
+	"container/list"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -23,6 +24,7 @@ import (
 	"github.com/elastos/Elastos.ELA/mempool"
 	"github.com/elastos/Elastos.ELA/p2p"
 	"github.com/elastos/Elastos.ELA/p2p/msg"
+	"github.com/elastos/Elastos.ELA/utils/elalog"
 )
 
 const (
@@ -59,6 +61,29 @@ type blockMsg struct {
 	reply chan struct{}
 }
 
+// cmpctBlockMsg packages a compact block message and the peer it came from
+// together so the block handler has access to that information.
+type cmpctBlockMsg struct {
+	block *msg.CmpctBlock
+	peer  *peer.Peer
+	reply chan struct{}
+}
+
+// blockTxnMsg packages a blocktxn message and the peer it came from together
+// so the block handler has access to that information.
+type blockTxnMsg struct {
+	blockTxn *msg.BlockTxn
+	peer     *peer.Peer
+	reply    chan struct{}
+}
+
+// headersMsg packages a headers message and the peer it came from together
+// so the block handler has access to that information.
+type headersMsg struct {
+	headers *msg.Headers
+	peer    *peer.Peer
+}
+
 // invMsg packages a bitcoin inv message and the peer it came from together
 // so the block handler has access to that information.
 type invMsg struct {
@@ -66,6 +91,13 @@ type invMsg struct {
 	peer *peer.Peer
 }
 
+// notFoundMsg packages a notfound message and the peer it came from together
+// so the block handler has access to that information.
+type notFoundMsg struct {
+	notFound *msg.NotFound
+	peer     *peer.Peer
+}
+
 // donePeerMsg signifies a newly disconnected peer to the block handler.
 type donePeerMsg struct {
 	peer *peer.Peer
@@ -108,6 +140,12 @@ type peerSyncState struct {
 	requestedTxns            map[common.Uint256]struct{}
 	requestedBlocks          map[common.Uint256]struct{}
 	requestedConfirmedBlocks map[common.Uint256]struct{}
+	compactBlocks            map[common.Uint256]*partialBlock
+
+	// notFoundHeight is the highest height of the blocks the peer replied
+	// not found in headers-first mode, the blocks at and below it are not
+	// requested from the peer again.
+	notFoundHeight uint32
 }
 
 // SyncManager is used to communicate block related messages with peers. The
@@ -136,6 +174,14 @@ type SyncManager struct {
 	syncStartTime            time.Time
 	syncHeight               uint32
 	peerStates               map[*peer.Peer]*peerSyncState
+
+	// The following fields are used for headers-first mode.
+	headersFirstMode bool
+	headersDone      bool
+	headerList       *list.List
+	lastHeader       *headerNode
+	blockRequests    map[common.Uint256]*blockRequest
+	receivedBlocks   map[common.Uint256]*receivedBlock
 }
 
 // startSync will choose the best peer among the available candidate peers to
@@ -166,6 +212,13 @@ func (sm *SyncManager) startSync() {
 			continue
 		}
 
+		// A pruned peer only keeps the recent blocks, so skip it when the
+		// chain is too far behind to be synced from it.
+		if peer.Services()&pact.SFNodePruned == pact.SFNodePruned &&
+			peer.Height() > bestHeight+blockchain.MinPruneDepth {
+			continue
+		}
+
 		// Just pick the first available candidate.
 		bestPeer = peer
 		break
@@ -197,7 +250,14 @@ func (sm *SyncManager) startSync() {
 		sm.syncPeer = bestPeer
 		sm.syncHeight = bestPeer.Height()
 		sm.syncStartTime = time.Now()
-		bestPeer.PushGetBlocksMsg(locator, &zeroHash)
+
+		// Download headers first if the peer can serve them, so that
+		// blocks can be downloaded from several peers in parallel.
+		if sm.isHeadersFirstCandidate(bestPeer, bestHeight) {
+			sm.startHeadersFirst(bestPeer, locator)
+		} else {
+			bestPeer.PushGetBlocksMsg(locator, &zeroHash)
+		}
 	} else {
 		log.Warnf("No sync peer candidates available")
 	}
@@ -206,8 +266,9 @@ func (sm *SyncManager) startSync() {
 // isSyncCandidate returns whether or not the peer is a candidate to consider
 // syncing from.
 func (sm *SyncManager) isSyncCandidate(peer *peer.Peer) bool {
-	// The peer is not a candidate for sync if it's not a full node.
-	if peer.Services()&pact.SFNodeNetwork != pact.SFNodeNetwork {
+	// The peer is not a candidate for sync if it's neither a full node nor
+	// a pruned node.
+	if peer.Services()&(pact.SFNodeNetwork|pact.SFNodePruned) == 0 {
 		return false
 	}
 
@@ -233,12 +294,18 @@ func (sm *SyncManager) handleNewPeerMsg(peer *peer.Peer) {
 		requestedTxns:            make(map[common.Uint256]struct{}),
 		requestedBlocks:          make(map[common.Uint256]struct{}),
 		requestedConfirmedBlocks: make(map[common.Uint256]struct{}),
+		compactBlocks:            make(map[common.Uint256]*partialBlock),
 	}
 
 	// Start syncing by choosing the best candidate if needed.
 	if isSyncCandidate && sm.syncPeer == nil {
 		sm.startSync()
 	}
+
+	// Download blocks from the new peer as well in headers-first mode.
+	if isSyncCandidate && sm.headersFirstMode {
+		sm.fetchBlocks()
+	}
 }
 
 // handleDonePeerMsg deals with peers that have signalled they are done.  It
@@ -263,6 +330,10 @@ func (sm *SyncManager) handleDonePeerMsg(peer *peer.Peer) {
 		delete(sm.requestedTxns, txHash)
 	}
 
+	// Remove blocks requested in headers-first mode so that they will be
+	// requested from other peers.
+	sm.clearBlockRequests(peer)
+
 	// Remove requested blocks from the global map so that they will be
 	// fetched from elsewhere next time we get an inv.
 	// TODO: we could possibly here check which peers have these blocks
@@ -273,12 +344,21 @@ func (sm *SyncManager) handleDonePeerMsg(peer *peer.Peer) {
 	for blockHash := range state.requestedConfirmedBlocks {
 		delete(sm.requestedConfirmedBlocks, blockHash)
 	}
+
+	// Drop the compact blocks waiting for transactions from the peer.
+	state.compactBlocks = nil
+
 	// Attempt to find a new peer to sync from if the quitting peer is the
 	// sync peer.  Also, reset the headers-first state if in headers-first
-	// mode so
+	// mode so headers will be downloaded from the new sync peer.
 	if sm.syncPeer == peer {
 		sm.syncPeer = nil
+		if sm.headersFirstMode {
+			sm.resetHeadersFirst()
+		}
 		sm.startSync()
+	} else if sm.headersFirstMode {
+		sm.fetchBlocks()
 	}
 }
 
@@ -413,13 +493,23 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 		}
 	}
 
+	// Blocks requested in headers-first mode are connected in order of
+	// headers.
+	if sm.headersFirstMode {
+		if _, ok := sm.blockRequests[blockHash]; ok {
+			sm.handleHeadersFirstBlock(peer, bmsg.block)
+			return
+		}
+	}
+
 	// GetProcessor the block to include validation, best chain selection, orphan
 	// handling, etc.
 	log.Debugf("Receive block %s at height %d", blockHash,
 		bmsg.block.Block.Height)
 	_, isOrphan, err := sm.blockMemPool.AddDposBlock(bmsg.block)
 	if err != nil {
-		log.Warn("add block error:", err)
+		log.WithFields(elalog.Height(bmsg.block.Block.Height),
+			elalog.Peer(peer.String())).Warn("add block error:", err)
 		elaErr := errors.SimpleWithMessage(errors.ErrP2pReject, err,
 			fmt.Sprintf("Rejected block %v from %s", blockHash, peer))
 
@@ -461,6 +551,142 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 	}
 }
 
+// useCompactBlocks returns whether or not to request blocks from the peer as
+// compact blocks.  Compact blocks are only requested from peers supporting
+// them after the initial block download, when most transactions of new blocks
+// are supposed to be in the transaction pool.
+func (sm *SyncManager) useCompactBlocks(peer *peer.Peer) bool {
+	return peer.Services()&pact.SFNodeCompactBlocks ==
+		pact.SFNodeCompactBlocks && sm.syncPeer == nil
+}
+
+// requestFullBlock requests the full block from the peer when the block can
+// not be rebuilt from the compact block.
+func (sm *SyncManager) requestFullBlock(peer *peer.Peer, hash common.Uint256,
+	haveConfirm bool) {
+	invType := msg.InvTypeBlock
+	if haveConfirm {
+		invType = msg.InvTypeConfirmedBlock
+	}
+	gdmsg := msg.NewGetData()
+	gdmsg.AddInvVect(msg.NewInvVect(invType, &hash))
+	peer.QueueMessage(gdmsg, nil)
+}
+
+// processCompactBlock processes the block rebuilt from a compact block as a
+// block received from the peer, or requests the full block if it can not be
+// rebuilt.
+func (sm *SyncManager) processCompactBlock(peer *peer.Peer, pb *partialBlock) {
+	block, err := pb.block()
+	if err != nil {
+		log.Debugf("Failed to rebuild compact block %s from %s, %s",
+			pb.hash(), peer, err)
+		sm.requestFullBlock(peer, pb.hash(), pb.header.HaveConfirm)
+		return
+	}
+	sm.handleBlockMsg(&blockMsg{block: block, peer: peer})
+}
+
+// handleCmpctBlockMsg handles compact block messages from all peers.  The
+// block is rebuilt from transactions in the pool, and transactions missing
+// are requested from the peer.
+func (sm *SyncManager) handleCmpctBlockMsg(cmsg *cmpctBlockMsg) {
+	peer := cmsg.peer
+	state, exists := sm.peerStates[peer]
+	if !exists {
+		log.Warnf("Received compact block message from unknown peer %s",
+			peer)
+		return
+	}
+
+	pb, err := newPartialBlock(cmsg.block, sm.txMemPool.GetTxsInPool())
+	if err != nil {
+		log.Warnf("Got invalid compact block from %s, %s -- "+
+			"disconnecting", peer, err)
+		peer.Disconnect()
+		return
+	}
+
+	// If we didn't ask for this block then the peer is misbehaving.
+	blockHash := pb.hash()
+	_, blockExist := state.requestedBlocks[blockHash]
+	_, confirmedBlockExist := state.requestedConfirmedBlocks[blockHash]
+	if !blockExist && !confirmedBlockExist {
+		log.Warnf("Got unrequested compact block %v from %s -- "+
+			"disconnecting", blockHash, peer)
+		peer.Disconnect()
+		return
+	}
+
+	if len(pb.missing) == 0 {
+		sm.processCompactBlock(peer, pb)
+		return
+	}
+
+	// Request the full block if too many compact blocks are waiting for
+	// the peer.
+	if _, ok := state.compactBlocks[blockHash]; !ok &&
+		len(state.compactBlocks) >= maxPeerCompactBlocks {
+		sm.requestFullBlock(peer, blockHash, pb.header.HaveConfirm)
+		return
+	}
+
+	log.Debugf("Request %d missing transactions of compact block %s "+
+		"from %s", len(pb.missing), blockHash, peer)
+	pb.requested = time.Now()
+	state.compactBlocks[blockHash] = pb
+	peer.QueueMessage(msg.NewGetBlockTxn(blockHash, pb.missing), nil)
+}
+
+// handleBlockTxnMsg handles blocktxn messages from all peers, which fill in
+// the missing transactions of compact blocks.
+func (sm *SyncManager) handleBlockTxnMsg(bmsg *blockTxnMsg) {
+	peer := bmsg.peer
+	state, exists := sm.peerStates[peer]
+	if !exists {
+		log.Warnf("Received blocktxn message from unknown peer %s", peer)
+		return
+	}
+
+	blockHash := bmsg.blockTxn.BlockHash
+	pb, ok := state.compactBlocks[blockHash]
+	if !ok {
+		log.Warnf("Got unrequested blocktxn %v from %s -- "+
+			"disconnecting", blockHash, peer)
+		peer.Disconnect()
+		return
+	}
+	delete(state.compactBlocks, blockHash)
+
+	if err := pb.fill(bmsg.blockTxn.Transactions); err != nil {
+		log.Debugf("Failed to fill compact block %s from %s, %s",
+			blockHash, peer, err)
+		sm.requestFullBlock(peer, blockHash, pb.header.HaveConfirm)
+		return
+	}
+	sm.processCompactBlock(peer, pb)
+}
+
+// expireCompactBlocks gives up the compact blocks whose missing transactions
+// are not received in time, the blocks will be fetched from elsewhere next time
+// we get an inv.
+func (sm *SyncManager) expireCompactBlocks() {
+	for peer, state := range sm.peerStates {
+		for blockHash, pb := range state.compactBlocks {
+			if time.Since(pb.requested) < compactBlockTimeout {
+				continue
+			}
+			log.Debugf("Missing transactions of compact block %s from %s "+
+				"timed out", blockHash, peer)
+			delete(state.compactBlocks, blockHash)
+			delete(state.requestedBlocks, blockHash)
+			delete(sm.requestedBlocks, blockHash)
+			delete(state.requestedConfirmedBlocks, blockHash)
+			delete(sm.requestedConfirmedBlocks, blockHash)
+		}
+	}
+}
+
 // haveInventory returns whether or not the inventory represented by the passed
 // inventory vector is known.  This includes checking all of the various places
 // inventory can be when it is in different states such as blocks that are part
@@ -522,6 +748,9 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 			"seconds, -- disconnecting", sm.syncPeer, syncTimeout)
 		sm.syncPeer.Disconnect()
 		sm.syncPeer = nil
+		if sm.headersFirstMode {
+			sm.resetHeadersFirst()
+		}
 	}
 
 	// Ignore invs from peers that aren't the sync if we are not current.
@@ -535,6 +764,11 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 	// Finally, attempt to detect potential stalls due to long side chains
 	// we already have and request more blocks to prevent them.
 	for _, iv := range invVects {
+		// Blocks are requested by headers in headers-first mode.
+		if sm.headersFirstMode && iv.Type != msg.InvTypeTx {
+			continue
+		}
+
 		// Ignore unsupported inventory types.
 		switch iv.Type {
 		case msg.InvTypeBlock:
@@ -608,6 +842,7 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 	// the request will be requested on the next inv message.
 	numRequested := 0
 	gdmsg := msg.NewGetData()
+	useCompact := sm.useCompactBlocks(peer)
 	requestQueue := state.requestQueue
 	for len(requestQueue) != 0 {
 		iv := requestQueue[0]
@@ -622,6 +857,9 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 				sm.requestedBlocks[iv.Hash] = struct{}{}
 				sm.limitMap(sm.requestedBlocks, maxRequestedBlocks)
 				state.requestedBlocks[iv.Hash] = struct{}{}
+				if useCompact {
+					iv = msg.NewInvVect(msg.InvTypeCompactBlock, &iv.Hash)
+				}
 				gdmsg.AddInvVect(iv)
 				numRequested++
 			}
@@ -632,6 +870,10 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 				sm.requestedConfirmedBlocks[iv.Hash] = struct{}{}
 				sm.limitMap(sm.requestedConfirmedBlocks, maxRequestedBlocks)
 				state.requestedConfirmedBlocks[iv.Hash] = struct{}{}
+				if useCompact {
+					iv = msg.NewInvVect(msg.InvTypeCompactConfirmedBlock,
+						&iv.Hash)
+				}
 				gdmsg.AddInvVect(iv)
 				numRequested++
 			}
@@ -657,7 +899,7 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 	}
 
 	// maxBlockLocators = 500
-	if len(invVects) == 500 {
+	if len(invVects) == 500 && !sm.headersFirstMode {
 		locator := sm.chain.GetOrphanBlockLocator(invVects)
 		log.Info("PushGetBlocksMsg 2:", locator, "count:", len(gdmsg.InvList))
 		if err := peer.PushGetBlocksMsg(locator, &zeroHash); err != nil {
@@ -691,6 +933,9 @@ func (sm *SyncManager) limitMap(m map[common.Uint256]struct{}, limit int) {
 // important because the sync manager controls which blocks are needed and how
 // the fetching should proceed.
 func (sm *SyncManager) blockHandler() {
+	stallTicker := time.NewTicker(stallSampleInterval)
+	defer stallTicker.Stop()
+
 out:
 	for {
 		select {
@@ -707,9 +952,23 @@ out:
 				sm.handleBlockMsg(msg)
 				msg.reply <- struct{}{}
 
+			case *cmpctBlockMsg:
+				sm.handleCmpctBlockMsg(msg)
+				msg.reply <- struct{}{}
+
+			case *blockTxnMsg:
+				sm.handleBlockTxnMsg(msg)
+				msg.reply <- struct{}{}
+
 			case *invMsg:
 				sm.handleInvMsg(msg)
 
+			case *headersMsg:
+				sm.handleHeadersMsg(msg)
+
+			case *notFoundMsg:
+				sm.handleNotFoundMsg(msg)
+
 			case *donePeerMsg:
 				sm.handleDonePeerMsg(msg.peer)
 
@@ -732,6 +991,10 @@ out:
 					"handler: %T", msg)
 			}
 
+		case <-stallTicker.C:
+			sm.handleStallSample()
+			sm.expireCompactBlocks()
+
 		case <-sm.quit:
 			break out
 		}
@@ -947,6 +1210,34 @@ func (sm *SyncManager) QueueBlock(block *types.DposBlock, peer *peer.Peer, done
 	sm.msgChan <- &blockMsg{block: block, peer: peer, reply: done}
 }
 
+// QueueCmpctBlock adds the passed compact block message and peer to the block
+// handling queue. Responds to the done channel argument after the compact
+// block message is processed.
+func (sm *SyncManager) QueueCmpctBlock(block *msg.CmpctBlock, peer *peer.Peer,
+	done chan struct{}) {
+	// Don't accept more blocks if we're shutting down.
+	if atomic.LoadInt32(&sm.shutdown) != 0 {
+		done <- struct{}{}
+		return
+	}
+
+	sm.msgChan <- &cmpctBlockMsg{block: block, peer: peer, reply: done}
+}
+
+// QueueBlockTxn adds the passed blocktxn message and peer to the block
+// handling queue. Responds to the done channel argument after the blocktxn
+// message is processed.
+func (sm *SyncManager) QueueBlockTxn(blockTxn *msg.BlockTxn, peer *peer.Peer,
+	done chan struct{}) {
+	// Don't accept more blocks if we're shutting down.
+	if atomic.LoadInt32(&sm.shutdown) != 0 {
+		done <- struct{}{}
+		return
+	}
+
+	sm.msgChan <- &blockTxnMsg{blockTxn: blockTxn, peer: peer, reply: done}
+}
+
 // QueueInv adds the passed inv message and peer to the block handling queue.
 func (sm *SyncManager) QueueInv(inv *msg.Inv, peer *peer.Peer) {
 	// No channel handling here because peers do not need to block on inv
@@ -958,6 +1249,30 @@ func (sm *SyncManager) QueueInv(inv *msg.Inv, peer *peer.Peer) {
 	sm.msgChan <- &invMsg{inv: inv, peer: peer}
 }
 
+// QueueHeaders adds the passed headers message and peer to the block handling
+// queue.
+func (sm *SyncManager) QueueHeaders(headers *msg.Headers, peer *peer.Peer) {
+	// No channel handling here because peers do not need to block on
+	// headers messages.
+	if atomic.LoadInt32(&sm.shutdown) != 0 {
+		return
+	}
+
+	sm.msgChan <- &headersMsg{headers: headers, peer: peer}
+}
+
+// QueueNotFound adds the passed notfound message and peer to the block
+// handling queue.
+func (sm *SyncManager) QueueNotFound(notFound *msg.NotFound, peer *peer.Peer) {
+	// No channel handling here because peers do not need to block on
+	// notfound messages.
+	if atomic.LoadInt32(&sm.shutdown) != 0 {
+		return
+	}
+
+	sm.msgChan <- &notFoundMsg{notFound: notFound, peer: peer}
+}
+
 // DonePeer informs the blockmanager that a peer has disconnected.
 func (sm *SyncManager) DonePeer(peer *peer.Peer) {
 	// Ignore if we are shutting down.
@@ -1033,6 +1348,9 @@ func New(config *Config) *SyncManager {
 		requestedBlocks:          make(map[common.Uint256]struct{}),
 		requestedConfirmedBlocks: make(map[common.Uint256]struct{}),
 		peerStates:               make(map[*peer.Peer]*peerSyncState),
+		headerList:               list.New(),
+		blockRequests:            make(map[common.Uint256]*blockRequest),
+		receivedBlocks:           make(map[common.Uint256]*receivedBlock),
 		msgChan:                  make(chan interface{}, config.MaxPeers*3),
 		quit:                     make(chan struct{}),
 	}
@@ -55,13 +55,38 @@ const (
 
 	// SFNodeBloom is a flag used to indicate a peer supports bloom filtering.
 	SFNodeBloom
+
+	// SFNodeCompactBlocks is a flag used to indicate a peer supports compact
+	// block relay.
+	SFNodeCompactBlocks
+
+	// SFNodeHeaders is a flag used to indicate a peer serves block headers
+	// for headers-first synchronization.
+	SFNodeHeaders
+
+	// SFNodePruned is a flag used to indicate a peer only keeps the recent
+	// blocks.
+	SFNodePruned
+
+	// SFNodeAddrV2 is a flag used to indicate a peer supports the addrv2
+	// message, which relays Tor v3 onion addresses.
+	SFNodeAddrV2
+
+	// SFNodeEncryption is a flag used to indicate a peer supports the
+	// encrypted transport.
+	SFNodeEncryption
 )
 
 // Map of service flags back to their constant names for pretty printing.
 var sfStrings = map[ServiceFlag]string{
-	SFNodeNetwork: "SFNodeNetwork",
-	SFTxFiltering: "SFTxFiltering",
-	SFNodeBloom:   "SFNodeBloom",
+	SFNodeNetwork:       "SFNodeNetwork",
+	SFTxFiltering:       "SFTxFiltering",
+	SFNodeBloom:         "SFNodeBloom",
+	SFNodeCompactBlocks: "SFNodeCompactBlocks",
+	SFNodeHeaders:       "SFNodeHeaders",
+	SFNodePruned:        "SFNodePruned",
+	SFNodeAddrV2:        "SFNodeAddrV2",
+	SFNodeEncryption:    "SFNodeEncryption",
 }
 
 // orderedSFStrings is an ordered list of service flags from highest to
@@ -70,6 +95,11 @@ var orderedSFStrings = []ServiceFlag{
 	SFNodeNetwork,
 	SFTxFiltering,
 	SFNodeBloom,
+	SFNodeCompactBlocks,
+	SFNodeHeaders,
+	SFNodePruned,
+	SFNodeAddrV2,
+	SFNodeEncryption,
 }
 
 // String returns the ServiceFlag in human-readable form.
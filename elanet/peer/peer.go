@@ -98,6 +98,21 @@ type Listeners struct {
 
 	// OnDAddr is invoked when a peer receives a daddr message.
 	OnDAddr func(p *Peer, msg *msg.DAddr)
+
+	// OnCmpctBlock is invoked when a peer receives a cmpctblock message.
+	OnCmpctBlock func(p *Peer, msg *msg.CmpctBlock)
+
+	// OnGetBlockTxn is invoked when a peer receives a getblocktxn message.
+	OnGetBlockTxn func(p *Peer, msg *msg.GetBlockTxn)
+
+	// OnBlockTxn is invoked when a peer receives a blocktxn message.
+	OnBlockTxn func(p *Peer, msg *msg.BlockTxn)
+
+	// OnGetHeaders is invoked when a peer receives a getheaders message.
+	OnGetHeaders func(p *Peer, msg *msg.GetHeaders)
+
+	// OnHeaders is invoked when a peer receives a headers message.
+	OnHeaders func(p *Peer, msg *msg.Headers)
 }
 
 type Peer struct {
@@ -244,12 +259,22 @@ func (p *Peer) maybeAddDeadline(pendingResponses map[string]time.Time, msgCmd st
 		pendingResponses[p2p.CmdInv] = deadline
 
 	case p2p.CmdGetData:
-		// Expects all block, merkleblock, tx, notfound or daddr message.
+		// Expects all block, merkleblock, cmpctblock, tx, notfound or
+		// daddr message.
 		pendingResponses[p2p.CmdBlock] = deadline
 		pendingResponses[p2p.CmdMerkleBlock] = deadline
+		pendingResponses[p2p.CmdCmpctBlock] = deadline
 		pendingResponses[p2p.CmdTx] = deadline
 		pendingResponses[p2p.CmdNotFound] = deadline
 		pendingResponses[p2p.CmdDAddr] = deadline
+
+	case p2p.CmdGetBlockTxn:
+		// Expects a blocktxn message.
+		pendingResponses[p2p.CmdBlockTxn] = deadline
+
+	case p2p.CmdGetHeaders:
+		// Expects a headers message.
+		pendingResponses[p2p.CmdHeaders] = deadline
 	}
 }
 
@@ -295,11 +320,14 @@ out:
 					fallthrough
 				case p2p.CmdMerkleBlock:
 					fallthrough
+				case p2p.CmdCmpctBlock:
+					fallthrough
 				case p2p.CmdTx:
 					fallthrough
 				case p2p.CmdNotFound:
 					delete(pendingResponses, p2p.CmdBlock)
 					delete(pendingResponses, p2p.CmdMerkleBlock)
+					delete(pendingResponses, p2p.CmdCmpctBlock)
 					delete(pendingResponses, p2p.CmdTx)
 					delete(pendingResponses, p2p.CmdNotFound)
 					delete(pendingResponses, p2p.CmdDAddr)
@@ -554,6 +582,21 @@ func New(orgPeer server.IPeer, listeners *Listeners) *Peer {
 		case *msg.DAddr:
 			listeners.OnDAddr(p, m)
 
+		case *msg.CmpctBlock:
+			listeners.OnCmpctBlock(p, m)
+
+		case *msg.GetBlockTxn:
+			listeners.OnGetBlockTxn(p, m)
+
+		case *msg.BlockTxn:
+			listeners.OnBlockTxn(p, m)
+
+		case *msg.GetHeaders:
+			listeners.OnGetHeaders(p, m)
+
+		case *msg.Headers:
+			listeners.OnHeaders(p, m)
+
 		case *msg.VerAck, *msg.GetAddr, *msg.Addr, *msg.Ping, *msg.Pong:
 		//	Basic messages have been handled, ignore them.
 
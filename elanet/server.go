@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"math/rand"
 	"net"
 	"sync/atomic"
 	"time"
@@ -41,10 +42,16 @@ import (
 const (
 	// defaultServices describes the default services that are supported by
 	// the NetServer.
-	defaultServices = pact.SFNodeNetwork | pact.SFTxFiltering | pact.SFNodeBloom
+	defaultServices = pact.SFNodeNetwork | pact.SFTxFiltering | pact.SFNodeBloom |
+		pact.SFNodeCompactBlocks | pact.SFNodeHeaders | pact.SFNodeAddrV2 |
+		pact.SFNodeEncryption
 
 	// maxNonNodePeers defines the maximum count of accepting non-node peers.
 	maxNonNodePeers = 100
+
+	// getHeadersInterval is the duration a repeated getheaders request of a
+	// peer is ignored, the headers have been sent to it.
+	getHeadersInterval = 30 * time.Second
 )
 
 // naFilter defines a network address filter for the main chain NetServer, for now
@@ -52,8 +59,7 @@ const (
 type naFilter struct{}
 
 func (f *naFilter) Filter(na *p2p.NetAddress) bool {
-	service := pact.ServiceFlag(na.Services)
-	return service&pact.SFNodeNetwork == pact.SFNodeNetwork
+	return nodeFlag(na.Services)
 }
 
 // NewPeerMsg represent a new connected peer.
@@ -105,6 +111,11 @@ type ServerPeer struct {
 	// The following chans are used to sync blockmanager and NetServer.
 	txProcessed    chan struct{}
 	blockProcessed chan struct{}
+
+	// prevGetHeaders is the start of the locator and the stop hash of the
+	// last getheaders request served.
+	prevGetHeaders     [2]common.Uint256
+	prevGetHeadersTime time.Time
 }
 
 // newServerPeer returns a new ServerPeer instance. The peer needs to be set by
@@ -288,6 +299,66 @@ func (sp *ServerPeer) OnBlock(_ *peer.Peer, msgBlock *msg.Block) {
 	<-sp.blockProcessed
 }
 
+// OnCmpctBlock is invoked when a peer receives a cmpctblock message.  It
+// blocks until the compact block has been fully processed.
+func (sp *ServerPeer) OnCmpctBlock(_ *peer.Peer, cmpctBlock *msg.CmpctBlock) {
+	header, ok := cmpctBlock.Header.(*types.DPOSHeader)
+	if !ok {
+		return
+	}
+	blockHash := header.Header.Hash()
+	iv := msg.NewInvVect(msg.InvTypeBlock, &blockHash)
+	if header.HaveConfirm {
+		iv.Type = msg.InvTypeConfirmedBlock
+	}
+
+	// Add the block to the known inventory for the peer.
+	sp.AddKnownInventory(iv)
+
+	// Queue the compact block up to be handled by the sync manager, the
+	// same as a block message.
+	sp.server.SyncManager.QueueCmpctBlock(cmpctBlock, sp.Peer,
+		sp.blockProcessed)
+	<-sp.blockProcessed
+}
+
+// OnGetBlockTxn is invoked when a peer receives a getblocktxn message.  It
+// responds the transactions the peer missed to rebuild a compact block.
+func (sp *ServerPeer) OnGetBlockTxn(_ *peer.Peer, getBlockTxn *msg.GetBlockTxn) {
+	s := sp.server
+	block, _ := s.blockMemPool.GetDposBlockByHash(getBlockTxn.BlockHash)
+	if block == nil {
+		block, _ = s.chain.GetDposBlockByHash(getBlockTxn.BlockHash)
+		if block == nil {
+			notFound := msg.NewNotFound()
+			notFound.AddInvVect(msg.NewInvVect(msg.InvTypeBlock,
+				&getBlockTxn.BlockHash))
+			sp.QueueMessage(notFound, nil)
+			return
+		}
+	}
+
+	txs := make([]interfaces.Transaction, 0, len(getBlockTxn.Indexes))
+	for _, index := range getBlockTxn.Indexes {
+		if index >= uint32(len(block.Transactions)) {
+			log.Debugf("%s sent getblocktxn with out of range index "+
+				"%d -- disconnecting", sp, index)
+			sp.AddBanScore(100, 0, getBlockTxn.CMD())
+			sp.Disconnect()
+			return
+		}
+		txs = append(txs, block.Transactions[index])
+	}
+	sp.QueueMessage(msg.NewBlockTxn(getBlockTxn.BlockHash, txs), nil)
+}
+
+// OnBlockTxn is invoked when a peer receives a blocktxn message.  It blocks
+// until the compact block it fills in has been fully processed.
+func (sp *ServerPeer) OnBlockTxn(_ *peer.Peer, blockTxn *msg.BlockTxn) {
+	sp.server.SyncManager.QueueBlockTxn(blockTxn, sp.Peer, sp.blockProcessed)
+	<-sp.blockProcessed
+}
+
 // OnInv is invoked when a peer receives an inv message and is
 // used to examine the inventory being advertised by the remote peer and react
 // accordingly.  We pass the message down to blockmanager which will call
@@ -299,17 +370,16 @@ func (sp *ServerPeer) OnInv(_ *peer.Peer, inv *msg.Inv) {
 	}
 }
 
-// OnNotFound is invoked when a peer receives an notfounc message.
-// A peer should not response a notfound message so we just disconnect it.
+// OnNotFound is invoked when a peer receives an notfound message.  A pruned
+// peer responses notfound for the blocks it no longer keeps, the message is
+// passed down to the sync manager to request the blocks from other peers.
 func (sp *ServerPeer) OnNotFound(_ *peer.Peer, notFound *msg.NotFound) {
 	for _, i := range notFound.InvList {
 		if i.Type == msg.InvTypeTx {
 			continue
 		}
 
-		log.Debugf("%s sent us notfound message --  disconnecting", sp)
-		sp.AddBanScore(100, 0, notFound.CMD())
-		sp.Disconnect()
+		sp.server.SyncManager.QueueNotFound(notFound, sp.Peer)
 		return
 	}
 }
@@ -359,6 +429,10 @@ func (sp *ServerPeer) OnGetData(_ *peer.Peer, getData *msg.GetData) {
 			err = sp.server.pushConfirmedBlockMsg(sp, &iv.Hash, c, waitChan)
 		case msg.InvTypeFilteredBlock:
 			err = sp.server.pushMerkleBlockMsg(sp, &iv.Hash, c, waitChan)
+		case msg.InvTypeCompactBlock:
+			err = sp.server.pushCompactBlockMsg(sp, &iv.Hash, false, c, waitChan)
+		case msg.InvTypeCompactConfirmedBlock:
+			err = sp.server.pushCompactBlockMsg(sp, &iv.Hash, true, c, waitChan)
 		case msg.InvTypeAddress:
 			continue
 		default:
@@ -413,6 +487,11 @@ func (sp *ServerPeer) OnGetBlocks(_ *peer.Peer, m *msg.GetBlocks) {
 	// Generate inventory message.
 	invMsg := msg.NewInv()
 	for i := range hashList {
+		// The blocks removed by pruning or below the imported snapshot
+		// can not be served.
+		if chain.IsBlockPruned(hashList[i]) {
+			continue
+		}
 		invType := msg.InvTypeConfirmedBlock
 		if sp.filter.IsLoaded() { // Compatible for SPV client.
 			invType = msg.InvTypeBlock
@@ -436,6 +515,38 @@ func (sp *ServerPeer) OnGetBlocks(_ *peer.Peer, m *msg.GetBlocks) {
 	}
 }
 
+// OnGetHeaders is invoked when a peer receives a getheaders message.  It
+// responds the headers of blocks after the first known block in the locator,
+// up to msg.MaxHeadersPerMsg headers.
+func (sp *ServerPeer) OnGetHeaders(_ *peer.Peer, m *msg.GetHeaders) {
+	// Ignore the request repeating the last one, reading the headers is not
+	// free and they have been sent.
+	request := [2]common.Uint256{{}, m.HashStop}
+	if len(m.Locator) > 0 {
+		request[0] = *m.Locator[0]
+	}
+	if request == sp.prevGetHeaders &&
+		time.Since(sp.prevGetHeadersTime) < getHeadersInterval {
+		log.Debugf("Ignoring repeated getheaders from %s", sp)
+		return
+	}
+	sp.prevGetHeaders = request
+	sp.prevGetHeadersTime = time.Now()
+
+	chain := sp.server.chain
+	headers := chain.LocateHeaders(m.Locator, &m.HashStop, msg.MaxHeadersPerMsg)
+
+	// Send found headers to the requesting peer, an empty headers message
+	// tells the peer there are no more headers to download.
+	sp.QueueMessage(msg.NewHeaders(headers), nil)
+}
+
+// OnHeaders is invoked when a peer receives a headers message.  The message is
+// passed down to the sync manager.
+func (sp *ServerPeer) OnHeaders(_ *peer.Peer, headers *msg.Headers) {
+	sp.server.SyncManager.QueueHeaders(headers, sp.Peer)
+}
+
 // enforceTxFilterFlag disconnects the peer if the NetServer is not configured to
 // allow tx filters.  Additionally, if the peer has negotiated to a protocol
 // version  that is high enough to observe the bloom filter service support bit,
@@ -683,6 +794,44 @@ func (s *NetServer) pushConfirmedBlockMsg(sp *ServerPeer, hash *common.Uint256,
 	return nil
 }
 
+// pushCompactBlockMsg sends a cmpctblock message for the provided block hash
+// to the connected peer, with the confirm of the block if confirmed is true.
+// An error is returned if the block hash is not known.
+func (s *NetServer) pushCompactBlockMsg(sp *ServerPeer, hash *common.Uint256,
+	confirmed bool, doneChan chan<- struct{}, waitChan <-chan struct{}) error {
+
+	// Fetch the block from the block pool or the database.
+	var block *types.DposBlock
+	if confirmed {
+		block, _ = s.chain.GetDposBlockByHash(*hash)
+		if block == nil {
+			block, _ = s.blockMemPool.GetDposBlockByHash(*hash)
+		}
+	} else {
+		block, _ = s.blockMemPool.GetDposBlockByHash(*hash)
+		if block == nil {
+			block, _ = s.chain.GetDposBlockByHash(*hash)
+		}
+	}
+	if block == nil || (confirmed && !block.HaveConfirm) {
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return errors.New("block not found")
+	}
+	if !confirmed {
+		block = &types.DposBlock{Block: block.Block}
+	}
+
+	// Once we have fetched data wait for any previous operation to finish.
+	if waitChan != nil {
+		<-waitChan
+	}
+
+	sp.QueueMessage(netsync.NewCompactBlock(block, rand.Uint64()), doneChan)
+	return nil
+}
+
 // pushMerkleBlockMsg sends a merkleblock message for the provided block hash to
 // the connected peer.  Since a merkle block requires the peer to have a filter
 // loaded, this call will simply be ignored if there is no filter loaded.  An
@@ -925,6 +1074,11 @@ func (s *NetServer) HandlePeerMsg(peers map[svr.IPeer]*ServerPeer, p interface{}
 			OnTxFilterLoad: sp.OnTxFilterLoad,
 			OnReject:       sp.OnReject,
 			OnDAddr:        s.Routes.QueueDAddr,
+			OnCmpctBlock:   sp.OnCmpctBlock,
+			OnGetBlockTxn:  sp.OnGetBlockTxn,
+			OnBlockTxn:     sp.OnBlockTxn,
+			OnGetHeaders:   sp.OnGetHeaders,
+			OnHeaders:      sp.OnHeaders,
 		})
 		peers[p.IPeer] = sp
 		p.Reply <- true
@@ -1003,6 +1157,13 @@ func NewServer(dataDir string, cfg *Config, nodeVersion string) (*NetServer, err
 		services &^= pact.SFNodeBloom
 		services &^= pact.SFTxFiltering
 	}
+	// A node without the full history advertises itself as a pruned node
+	// instead of a full node, so the peers not aware of pruning never sync
+	// the old blocks from it.
+	if !cfg.Chain.HaveFullHistory() {
+		services &^= pact.SFNodeNetwork
+		services |= pact.SFNodePruned
+	}
 
 	// If no listeners added, create default listener.
 	if len(params.ListenAddrs) == 0 {
@@ -1024,6 +1185,13 @@ func NewServer(dataDir string, cfg *Config, nodeVersion string) (*NetServer, err
 	svrCfg.DataDir = dataDir
 	svrCfg.NAFilter = &naFilter{}
 	svrCfg.PermanentPeers = cfg.PermanentPeers
+	svrCfg.ExternalIPs = params.ExternalIPs
+	svrCfg.Proxy = params.Proxy
+	svrCfg.ProxyUser = params.ProxyUser
+	svrCfg.ProxyPass = params.ProxyPass
+	svrCfg.OnionProxy = params.OnionProxy
+	svrCfg.TorIsolation = params.TorIsolation
+	svrCfg.OnlyNet = params.OnlyNet
 
 	s := NetServer{
 		chain:        cfg.Chain,
@@ -1099,6 +1267,21 @@ func createMessage(hdr p2p.Header, r net.Conn) (p2p.Message, error) {
 	case p2p.CmdDAddr:
 		message = &msg.DAddr{}
 
+	case p2p.CmdCmpctBlock:
+		message = msg.NewCmpctBlock(&types.DPOSHeader{})
+
+	case p2p.CmdGetBlockTxn:
+		message = &msg.GetBlockTxn{}
+
+	case p2p.CmdBlockTxn:
+		message = &msg.BlockTxn{}
+
+	case p2p.CmdGetHeaders:
+		message = &msg.GetHeaders{}
+
+	case p2p.CmdHeaders:
+		message = &msg.Headers{}
+
 	default:
 		return nil, fmt.Errorf("unhandled command [%s]", hdr.GetCMD())
 	}
@@ -1106,7 +1289,7 @@ func createMessage(hdr p2p.Header, r net.Conn) (p2p.Message, error) {
 	return peer2.CheckAndCreateMessage(hdr, message, r)
 }
 
-// nodeFlag returns if a peer contains the full node flag.
+// nodeFlag returns if a peer contains the full node or the pruned node flag.
 func nodeFlag(flag uint64) bool {
-	return pact.ServiceFlag(flag)&pact.SFNodeNetwork == pact.SFNodeNetwork
+	return pact.ServiceFlag(flag)&(pact.SFNodeNetwork|pact.SFNodePruned) != 0
 }
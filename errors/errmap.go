@@ -150,4 +150,6 @@ var ErrMap = map[ErrCode]string{
 		"CR transaction conflict"),
 	ErrTxPoolDoubleSpend: FormatErrString(prefixPool, prefixTxPool,
 		"double spend with transaction in transaction pool"),
+	ErrTxPoolReplacementRejected: FormatErrString(prefixPool, prefixTxPool,
+		"replacement rejected"),
 }
@@ -73,6 +73,10 @@ const (
 
 	//ETOutdatedTxRelay indicates that need to resend outdate tx to tx pool
 	ETOutdatedTxRelay
+
+	// ETTransactionRemoved indicates the associated transaction was removed
+	// from the transaction mem pool without being packed into a block.
+	ETTransactionRemoved
 )
 
 // notificationTypeStrings is a map of notification types back to their constant
@@ -93,6 +97,7 @@ var notificationTypeStrings = map[EventType]string{
 	ETCRCChangeCommittee:           "ETCRCChangeCommittee",
 	ETSmallCrossChainNeedRelay:     "ETSmallCrossChainNeedRelay",
 	ETOutdatedTxRelay:              "ETOutdatedTxRelay",
+	ETTransactionRemoved:           "ETTransactionRemoved",
 }
 
 // String returns the EventType in human-readable form.
@@ -110,6 +115,7 @@ func (n EventType) String() string {
 // 	- ETBlockConnected:    *types.Block
 // 	- ETBlockDisconnected: *types.Block
 // 	- ETTransactionAccepted: *types.BaseTransaction
+// 	- ETTransactionRemoved: *types.BaseTransaction
 type Event struct {
 	Type EventType
 	Data interface{}
@@ -8,9 +8,12 @@ package main
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/elastos/Elastos.ELA/blockchain"
 	"github.com/elastos/Elastos.ELA/common/config"
 	"github.com/elastos/Elastos.ELA/common/log"
 	"github.com/elastos/Elastos.ELA/core/transaction"
@@ -21,102 +24,15 @@ import (
 	"github.com/elastos/Elastos.ELA/elanet/netsync"
 	"github.com/elastos/Elastos.ELA/elanet/peer"
 	"github.com/elastos/Elastos.ELA/elanet/routes"
+	"github.com/elastos/Elastos.ELA/mempool"
 	"github.com/elastos/Elastos.ELA/p2p/addrmgr"
 	"github.com/elastos/Elastos.ELA/p2p/connmgr"
+	"github.com/elastos/Elastos.ELA/pow"
 	"github.com/elastos/Elastos.ELA/utils/elalog"
 
 	"gopkg.in/cheggaaa/pb.v1"
 )
 
-type logWrapper struct {
-	level  elalog.Level
-	logger elalog.Logger
-}
-
-func (l *logWrapper) Debug(a ...interface{}) {
-	if l.level > elalog.LevelDebug {
-		return
-	}
-	l.logger.Debug(a...)
-}
-
-func (l *logWrapper) Debugf(format string, a ...interface{}) {
-	if l.level > elalog.LevelDebug {
-		return
-	}
-	l.logger.Debugf(format, a...)
-}
-
-func (l *logWrapper) Info(a ...interface{}) {
-	if l.level > elalog.LevelInfo {
-		return
-	}
-	l.logger.Info(a...)
-}
-
-func (l *logWrapper) Infof(format string, a ...interface{}) {
-	if l.level > elalog.LevelInfo {
-		return
-	}
-	l.logger.Infof(format, a...)
-}
-
-func (l *logWrapper) Warn(a ...interface{}) {
-	if l.level > elalog.LevelWarn {
-		return
-	}
-	l.logger.Warn(a...)
-}
-
-func (l *logWrapper) Warnf(format string, a ...interface{}) {
-	if l.level > elalog.LevelWarn {
-		return
-	}
-	l.logger.Warnf(format, a...)
-}
-
-func (l *logWrapper) Error(a ...interface{}) {
-	if l.level > elalog.LevelError {
-		return
-	}
-	l.logger.Error(a...)
-}
-
-func (l *logWrapper) Errorf(format string, a ...interface{}) {
-	if l.level > elalog.LevelError {
-		return
-	}
-	l.logger.Errorf(format, a...)
-}
-
-func (l *logWrapper) Fatal(a ...interface{}) {
-	if l.level > elalog.LevelFatal {
-		return
-	}
-	l.logger.Fatal(a...)
-}
-
-func (l *logWrapper) Fatalf(format string, a ...interface{}) {
-	if l.level > elalog.LevelFatal {
-		return
-	}
-	l.logger.Fatalf(format, a...)
-}
-
-// Level returns the current logging level.
-func (l *logWrapper) Level() elalog.Level {
-	return l.level
-}
-
-// SetLevel changes the logging level to the passed level.
-func (l *logWrapper) SetLevel(level elalog.Level) {
-	l.level = level
-}
-
-func wrap(logger *log.Logger, level elalog.Level) *logWrapper {
-	return &logWrapper{logger: logger, level: level}
-}
-
 const (
 	// progressRefreshRate indicates the duration between refresh progress.
 	progressRefreshRate = time.Millisecond * 500
@@ -170,36 +86,71 @@ var (
 	pgBar  *progress
 )
 
+// subsystemLevel returns the level to print log of the subsystem, which is
+// the level in LogLevels or PrintLevel if the subsystem is not configured.
+func subsystemLevel(s *config.Configuration, subsystem string) elalog.Level {
+	if level, ok := s.LogLevels[subsystem]; ok {
+		return elalog.Level(level)
+	}
+	return elalog.Level(s.PrintLevel)
+}
+
 // The default amount of logging is none.
 func setupLog(s *config.Configuration) {
 	flagDataDir := config.DataDir
 	if s.DataDir != "" {
 		flagDataDir = s.DataDir
 	}
+	format, ok := elalog.FormatFromString(s.LogFormat)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "invalid log format %s, use %s\n",
+			s.LogFormat, format)
+	}
 	path := filepath.Join(flagDataDir, nodeLogPath)
-	logger = log.NewDefault(path, uint8(s.PrintLevel),
-		s.MaxPerLogSize, s.MaxLogsSize)
-	pgBar = newProgress(logger.Writer())
-
-	admrlog := wrap(logger, elalog.LevelOff)
-	cmgrlog := wrap(logger, elalog.LevelOff)
-	hublog := wrap(logger, elalog.LevelOff)
-	synclog := wrap(logger, elalog.Level(s.PrintLevel))
-	peerlog := wrap(logger, elalog.Level(s.PrintLevel))
-	routlog := wrap(logger, elalog.Level(s.PrintLevel))
-	elanlog := wrap(logger, elalog.Level(s.PrintLevel))
-	statlog := wrap(logger, elalog.Level(s.PrintLevel))
-	crstatlog := wrap(logger, elalog.Level(s.PrintLevel))
-	txslog := wrap(logger, elalog.Level(s.PrintLevel))
+	logger = log.NewDefaultWithConfig(&log.Config{
+		Path:            path,
+		Subsystem:       "node",
+		Level:           uint8(s.PrintLevel),
+		Format:          format,
+		MaxPerLogSizeMb: s.MaxPerLogSize,
+		MaxLogsSizeMb:   s.MaxLogsSize,
+		RotateInterval:  time.Duration(s.LogRotateHours) * time.Hour,
+	})
+	if format == elalog.FormatJSON {
+		// the progress bar can not be parsed as JSON objects.
+		pgBar = newProgress(ioutil.Discard)
+	} else {
+		pgBar = newProgress(logger.Writer())
+	}
+
+	level := elalog.Level(s.PrintLevel)
+	admrlog := logger.Subsystem("addrmgr", elalog.LevelOff)
+	cmgrlog := logger.Subsystem("connmgr", elalog.LevelOff)
+	hublog := logger.Subsystem("hub", elalog.LevelOff)
+	elanlog := logger.Subsystem("elanet", level)
+	dposlog := logger.Subsystem("dpos", level)
+	crlog := logger.Subsystem("cr", level)
+	poollog := logger.Subsystem("mempool", level)
+	chainlog := logger.Subsystem("blockchain", level)
+	powlog := logger.Subsystem("pow", level)
+	for subsystem, level := range s.LogLevels {
+		if err := log.SetSubsystemLevel(subsystem,
+			elalog.Level(level)); err != nil {
+			logger.Warnf("Invalid LogLevels, %s", err)
+		}
+	}
 
 	addrmgr.UseLogger(admrlog)
 	connmgr.UseLogger(cmgrlog)
-	netsync.UseLogger(synclog)
-	peer.UseLogger(peerlog)
-	routes.UseLogger(routlog)
+	netsync.UseLogger(elanlog)
+	peer.UseLogger(elanlog)
+	routes.UseLogger(elanlog)
 	elanet.UseLogger(elanlog)
-	state.UseLogger(statlog)
-	crstate.UseLogger(crstatlog)
-	transaction.UseLogger(txslog)
+	state.UseLogger(dposlog)
+	crstate.UseLogger(crlog)
+	mempool.UseLogger(poollog)
+	blockchain.UseLogger(chainlog)
+	transaction.UseLogger(chainlog)
+	pow.UseLogger(powlog)
 	hub.UseLogger(hublog)
 }
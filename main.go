@@ -42,6 +42,7 @@ import (
 	"github.com/elastos/Elastos.ELA/servers/httpwebsocket"
 	"github.com/elastos/Elastos.ELA/utils"
 	"github.com/elastos/Elastos.ELA/utils/elalog"
+	"github.com/elastos/Elastos.ELA/utils/metrics"
 	"github.com/elastos/Elastos.ELA/utils/signal"
 )
 
@@ -100,6 +101,13 @@ func startNode(cfg *config.Configuration) {
 	if cfg.ProfilePort != 0 {
 		go utils.StartPProf(cfg.ProfilePort, cfg.ProfileHost)
 	}
+	if cfg.MetricsPort != 0 {
+		go func() {
+			if err := metrics.Serve(cfg.MetricsHost, cfg.MetricsPort); err != nil {
+				log.Errorf("Start metrics server failed, %s", err)
+			}
+		}()
+	}
 
 	flagDataDir := config.DataDir
 	if cfg.DataDir != "" {
@@ -111,7 +119,17 @@ func startNode(cfg *config.Configuration) {
 	ckpManager.SetDataPath(filepath.Join(dataDir, checkpointPath))
 
 	var acc account.Account
-	if cfg.DPoSConfiguration.EnableArbiter {
+	if cfg.DPoSConfiguration.EnableArbiter && cfg.DPoSConfiguration.Signer != "" {
+		var err error
+		acc, err = account.OpenSigner(cfg.DPoSConfiguration.Signer,
+			cfg.DPoSConfiguration.SignerToken,
+			cfg.DPoSConfiguration.SignerPublicKey)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		log.Infof("Arbiter key is held by signer %s",
+			cfg.DPoSConfiguration.Signer)
+	} else if cfg.DPoSConfiguration.EnableArbiter {
 		var err error
 		var password []byte
 		if cfg.Password != "" {
@@ -141,6 +159,16 @@ func startNode(cfg *config.Configuration) {
 	defer chainStore.Close()
 	ledger.Store = chainStore // fixme
 
+	if cfg.ImportSnapshot != "" {
+		_, err := blockchain.ImportSnapshot(chainStore, cfg,
+			cfg.ImportSnapshot, filepath.Join(dataDir, checkpointPath))
+		if err == blockchain.ErrChainStateExists {
+			log.Warn("chain data already exists, skip importing snapshot")
+		} else if err != nil {
+			printErrorAndExit(err)
+		}
+	}
+
 	txMemPool := mempool.NewTxPool(cfg, ckpManager)
 	blockMemPool := mempool.NewBlockPool(cfg)
 	blockMemPool.Store = chainStore
@@ -219,7 +247,13 @@ func startNode(cfg *config.Configuration) {
 	})
 
 	if acc != nil {
-		dlog.Init(flagDataDir, uint8(cfg.PrintLevel), cfg.MaxPerLogSize, cfg.MaxLogsSize)
+		dlog.Init(flagDataDir, log.Config{
+			Level:           uint8(subsystemLevel(cfg, "dpos")),
+			Format:          logger.Format(),
+			MaxPerLogSizeMb: cfg.MaxPerLogSize,
+			MaxLogsSizeMb:   cfg.MaxLogsSize,
+			RotateInterval:  time.Duration(cfg.LogRotateHours) * time.Hour,
+		})
 		arbitrator, err := dpos.NewArbitrator(acc, dpos.Config{
 			EnableEventLog: true,
 			Chain:          chain,
@@ -234,6 +268,7 @@ func startNode(cfg *config.Configuration) {
 			AnnounceAddr: route.AnnounceAddr,
 			NodeVersion:  nodePrefix + Version,
 			Addr:         routesCfg.Addr,
+			Performance:  arbiters.Performance,
 		})
 		if err != nil {
 			printErrorAndExit(err)
@@ -266,6 +301,7 @@ func startNode(cfg *config.Configuration) {
 	servers.TxMemPool = txMemPool
 	servers.Server = netServer
 	servers.Arbiters = arbiters
+	servers.Performance = arbiters.Performance
 	servers.Pow = pow.NewService(&pow.Config{
 		PayToAddr:   cfg.PowConfiguration.PayToAddr,
 		MinerInfo:   cfg.PowConfiguration.MinerInfo,
@@ -301,6 +337,20 @@ func startNode(cfg *config.Configuration) {
 		}
 	}
 
+	// Load transactions saved on last shutdown to transaction pool
+	txMemPool.SetDataPath(filepath.Join(dataDir, mempool.MempoolFileName))
+	if cfg.PersistMempool {
+		if _, _, err := txMemPool.LoadMempool(); err != nil &&
+			!os.IsNotExist(err) {
+			log.Warn("load transaction pool failed,", err)
+		}
+		defer func() {
+			if _, err := txMemPool.SaveMempool(); err != nil {
+				log.Error("save transaction pool failed,", err)
+			}
+		}()
+	}
+
 	log.Info("Start the P2P networks")
 	netServer.Start()
 	defer netServer.Stop()
@@ -359,8 +409,7 @@ out:
 }
 
 func printSyncState(bc *blockchain.BlockChain, server elanet.Server) {
-	statlog := elalog.NewBackend(logger.Writer()).Logger("STAT",
-		elalog.LevelInfo)
+	statlog := logger.Subsystem("stat", elalog.LevelInfo)
 
 	ticker := time.NewTicker(printStateInterval)
 	defer ticker.Stop()
@@ -380,6 +429,6 @@ func printSyncState(bc *blockchain.BlockChain, server elanet.Server) {
 			}
 		}
 		buf.WriteString("]")
-		statlog.Info(buf.String())
+		statlog.WithFields(elalog.Height(bc.GetHeight())).Info(buf.String())
 	}
 }
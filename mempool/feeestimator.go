@@ -0,0 +1,389 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package mempool
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/checkpoint"
+	"github.com/elastos/Elastos.ELA/core/types"
+)
+
+const (
+	// feeEstimatorCheckpointKey defines key of fee estimator checkpoint.
+	feeEstimatorCheckpointKey = "cp_feeEstimator"
+
+	// feeEstimatorCheckpointExtension defines checkpoint file extension of
+	// fee estimator checkpoint.
+	feeEstimatorCheckpointExtension = ".fecp"
+
+	// feeEstimatorCheckpointHeight defines interval height between two
+	// neighbor check points.
+	feeEstimatorCheckpointHeight = uint32(1)
+
+	// FeeBucketMinRate is the lower bound in sela per KB of the second fee
+	// bucket, the first bucket holds every fee rate below it.
+	FeeBucketMinRate = common.Fixed64(100)
+
+	// FeeBucketCount is the count of fee buckets, the upper bound of each
+	// bucket is twice of its lower bound and the last bucket is unbounded.
+	FeeBucketCount = 20
+
+	// FeeEstimatorWindow is the count of recent connected blocks whose
+	// transactions are used to estimate fee.
+	FeeEstimatorWindow = uint32(100)
+
+	// MaxConfirmationTarget is the max count of blocks a fee can be
+	// estimated for.
+	MaxConfirmationTarget = uint32(25)
+
+	// DefaultFeeConfidence is the default probability for a transaction to
+	// be confirmed within the target blocks.
+	DefaultFeeConfidence = 0.85
+
+	// minFeeBucketSamples is the minimum count of samples required to decide
+	// whether a group of fee buckets reaches the confidence.
+	minFeeBucketSamples = 10
+)
+
+// FeeBucketRange returns the fee rate range in sela per KB of the fee bucket
+// at the given index, max is zero for the last bucket which is unbounded.
+func FeeBucketRange(index int) (min common.Fixed64, max common.Fixed64) {
+	if index > 0 {
+		min = FeeBucketMinRate << uint(index-1)
+	}
+	if index < FeeBucketCount-1 {
+		max = FeeBucketMinRate << uint(index)
+	}
+	return
+}
+
+// feeBucketIndex returns the index of the fee bucket the given fee rate in
+// sela per KB belongs to.
+func feeBucketIndex(feeRate common.Fixed64) int {
+	index := 0
+	for bound := FeeBucketMinRate; feeRate >= bound &&
+		index < FeeBucketCount-1; bound <<= 1 {
+		index++
+	}
+	return index
+}
+
+// feeRatePerKB returns the fee rate of a transaction in sela per KB.
+func feeRatePerKB(fee common.Fixed64, size int) common.Fixed64 {
+	if size <= 0 {
+		return 0
+	}
+	return fee * 1000 / common.Fixed64(size)
+}
+
+// feeObservation records the fee bucket of a confirmed transaction and the
+// count of blocks it waited in the transaction pool.
+type feeObservation struct {
+	Bucket uint8
+	Delay  uint32
+}
+
+// blockFeeStats holds the fee observations of transactions confirmed by a
+// block.
+type blockFeeStats struct {
+	Height       uint32
+	Observations []feeObservation
+}
+
+// FeeEstimate is the result of a fee estimation.
+type FeeEstimate struct {
+	// FeeRate is the estimated fee rate in sela per KB.
+	FeeRate common.Fixed64
+
+	// Blocks is the confirmation target of the estimation.
+	Blocks uint32
+
+	// SuccessRate is the rate of transactions paying the estimated fee
+	// rate that have been confirmed within the target blocks.
+	SuccessRate float64
+
+	// Samples is the count of transactions the estimation based on.
+	Samples uint32
+}
+
+// FeeEstimator tracks how many blocks transactions in each fee bucket wait
+// before being confirmed, over a rolling window of connected blocks.
+type FeeEstimator struct {
+	mtx        sync.RWMutex
+	bestHeight uint32
+	blocks     []*blockFeeStats
+
+	height uint32
+}
+
+// ProcessBlock records the fee buckets and confirmation delays of the pooled
+// transactions confirmed by the block at the given height.
+func (e *FeeEstimator) ProcessBlock(height uint32, observations []feeObservation) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	e.bestHeight = height
+	if len(observations) > 0 {
+		e.blocks = append(e.blocks, &blockFeeStats{
+			Height:       height,
+			Observations: observations,
+		})
+	}
+	e.prune()
+}
+
+// prune removes the stats of blocks out of the estimation window.
+func (e *FeeEstimator) prune() {
+	index := 0
+	for ; index < len(e.blocks); index++ {
+		if e.blocks[index].Height+FeeEstimatorWindow > e.bestHeight {
+			break
+		}
+	}
+	if index > 0 {
+		e.blocks = append(e.blocks[:0], e.blocks[index:]...)
+	}
+}
+
+// BestHeight returns the height of the last processed block.
+func (e *FeeEstimator) BestHeight() uint32 {
+	e.mtx.RLock()
+	defer e.mtx.RUnlock()
+	return e.bestHeight
+}
+
+// Estimate returns the lowest fee rate, of which transactions have been
+// confirmed within target blocks with a rate no less than confidence.  The
+// pending parameter counts transactions by bucket that are still waiting in
+// the transaction pool after target blocks, they are treated as failures.
+func (e *FeeEstimator) Estimate(target uint32, confidence float64,
+	pending [FeeBucketCount]uint32) (*FeeEstimate, bool) {
+	e.mtx.RLock()
+	defer e.mtx.RUnlock()
+
+	var total, confirmed [FeeBucketCount]uint32
+	for _, b := range e.blocks {
+		for _, o := range b.Observations {
+			total[o.Bucket]++
+			if o.Delay <= target {
+				confirmed[o.Bucket]++
+			}
+		}
+	}
+	for i, count := range pending {
+		total[i] += count
+	}
+
+	// Group buckets from the highest fee rate down until a group has enough
+	// samples, and stop at the first group missing the confidence.
+	var result *FeeEstimate
+	var groupTotal, groupConfirmed uint32
+	for i := FeeBucketCount - 1; i >= 0; i-- {
+		groupTotal += total[i]
+		groupConfirmed += confirmed[i]
+		if groupTotal < minFeeBucketSamples {
+			continue
+		}
+
+		successRate := float64(groupConfirmed) / float64(groupTotal)
+		if successRate < confidence {
+			break
+		}
+		feeRate, _ := FeeBucketRange(i)
+		result = &FeeEstimate{
+			FeeRate:     feeRate,
+			Blocks:      target,
+			SuccessRate: successRate,
+			Samples:     groupTotal,
+		}
+		groupTotal, groupConfirmed = 0, 0
+	}
+
+	return result, result != nil
+}
+
+func (e *FeeEstimator) OnBlockSaved(block *types.DposBlock) {
+}
+
+func (e *FeeEstimator) OnRollbackTo(height uint32) error {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	index := len(e.blocks)
+	for index > 0 && e.blocks[index-1].Height > height {
+		index--
+	}
+	e.blocks = e.blocks[:index]
+	if e.bestHeight > height {
+		e.bestHeight = height
+	}
+	return nil
+}
+
+func (e *FeeEstimator) OnRollbackSeekTo(uint32) {
+	return
+}
+
+func (e *FeeEstimator) Key() string {
+	return feeEstimatorCheckpointKey
+}
+
+func (e *FeeEstimator) Snapshot() checkpoint.ICheckPoint {
+	buf := bytes.Buffer{}
+	if err := e.Serialize(&buf); err != nil {
+		e.LogError(err)
+		return nil
+	}
+	result := NewFeeEstimator()
+	if err := result.Deserialize(&buf); err != nil {
+		e.LogError(err)
+		return nil
+	}
+	return result
+}
+
+func (e *FeeEstimator) GetHeight() uint32 {
+	e.mtx.RLock()
+	defer e.mtx.RUnlock()
+	return e.height
+}
+
+func (e *FeeEstimator) OnReset() error {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	e.bestHeight = 0
+	e.blocks = nil
+	return nil
+}
+
+func (e *FeeEstimator) SetHeight(height uint32) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	e.height = height
+}
+
+func (e *FeeEstimator) SavePeriod() uint32 {
+	return feeEstimatorCheckpointHeight
+}
+
+func (e *FeeEstimator) EffectivePeriod() uint32 {
+	return feeEstimatorCheckpointHeight
+}
+
+func (e *FeeEstimator) DataExtension() string {
+	return feeEstimatorCheckpointExtension
+}
+
+func (e *FeeEstimator) Generator() func(buf []byte) checkpoint.ICheckPoint {
+	return func(buf []byte) checkpoint.ICheckPoint {
+		stream := bytes.Buffer{}
+		stream.Write(buf)
+
+		result := NewFeeEstimator()
+		if err := result.Deserialize(&stream); err != nil {
+			e.LogError(err)
+			return nil
+		}
+		return result
+	}
+}
+
+func (e *FeeEstimator) LogError(err error) {
+	log.Warn(err)
+}
+
+func (e *FeeEstimator) Priority() checkpoint.Priority {
+	return checkpoint.Low
+}
+
+func (e *FeeEstimator) OnInit() {
+}
+
+func (e *FeeEstimator) SaveStartHeight() uint32 {
+	return uint32(1)
+}
+
+func (e *FeeEstimator) StartHeight() uint32 {
+	return uint32(1)
+}
+
+func (e *FeeEstimator) Serialize(w io.Writer) (err error) {
+	e.mtx.RLock()
+	defer e.mtx.RUnlock()
+
+	if err = common.WriteUint32(w, e.height); err != nil {
+		return
+	}
+	if err = common.WriteUint32(w, e.bestHeight); err != nil {
+		return
+	}
+	if err = common.WriteVarUint(w, uint64(len(e.blocks))); err != nil {
+		return
+	}
+	for _, b := range e.blocks {
+		if err = common.WriteUint32(w, b.Height); err != nil {
+			return
+		}
+		if err = common.WriteVarUint(w, uint64(len(b.Observations))); err != nil {
+			return
+		}
+		for _, o := range b.Observations {
+			if err = common.WriteElements(w, o.Bucket, o.Delay); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (e *FeeEstimator) Deserialize(r io.Reader) (err error) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	if e.height, err = common.ReadUint32(r); err != nil {
+		return
+	}
+	if e.bestHeight, err = common.ReadUint32(r); err != nil {
+		return
+	}
+	var count uint64
+	if count, err = common.ReadVarUint(r, 0); err != nil {
+		return
+	}
+	e.blocks = make([]*blockFeeStats, 0, count)
+	for i := uint64(0); i < count; i++ {
+		b := &blockFeeStats{}
+		if b.Height, err = common.ReadUint32(r); err != nil {
+			return
+		}
+		var obsCount uint64
+		if obsCount, err = common.ReadVarUint(r, 0); err != nil {
+			return
+		}
+		b.Observations = make([]feeObservation, 0, obsCount)
+		for j := uint64(0); j < obsCount; j++ {
+			var o feeObservation
+			if err = common.ReadElements(r, &o.Bucket, &o.Delay); err != nil {
+				return
+			}
+			if int(o.Bucket) >= FeeBucketCount {
+				o.Bucket = FeeBucketCount - 1
+			}
+			b.Observations = append(b.Observations, o)
+		}
+		e.blocks = append(e.blocks, b)
+	}
+	return
+}
+
+// NewFeeEstimator creates a fee estimator with no observations.
+func NewFeeEstimator() *FeeEstimator {
+	return &FeeEstimator{}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package mempool
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeeBucketIndex(t *testing.T) {
+	assert.Equal(t, 0, feeBucketIndex(0))
+	assert.Equal(t, 0, feeBucketIndex(FeeBucketMinRate-1))
+	assert.Equal(t, 1, feeBucketIndex(FeeBucketMinRate))
+	assert.Equal(t, 1, feeBucketIndex(FeeBucketMinRate*2-1))
+	assert.Equal(t, 2, feeBucketIndex(FeeBucketMinRate*2))
+	assert.Equal(t, FeeBucketCount-1, feeBucketIndex(common.Fixed64(1)<<62))
+
+	for i := 0; i < FeeBucketCount; i++ {
+		min, max := FeeBucketRange(i)
+		assert.Equal(t, i, feeBucketIndex(min))
+		if max != 0 {
+			assert.Equal(t, i+1, feeBucketIndex(max))
+		} else {
+			assert.Equal(t, FeeBucketCount-1, i)
+		}
+	}
+
+	assert.Equal(t, common.Fixed64(4000), feeRatePerKB(1000, 250))
+	assert.Equal(t, common.Fixed64(0), feeRatePerKB(1000, 0))
+}
+
+func TestFeeEstimator_Estimate(t *testing.T) {
+	e := NewFeeEstimator()
+	var pending [FeeBucketCount]uint32
+
+	_, ok := e.Estimate(1, DefaultFeeConfidence, pending)
+	assert.False(t, ok)
+
+	// transactions in bucket 8 are packed in the next block, while
+	// transactions in bucket 4 wait 3 blocks.
+	for height := uint32(1); height <= 10; height++ {
+		e.ProcessBlock(height, []feeObservation{
+			{Bucket: 8, Delay: 1},
+			{Bucket: 8, Delay: 1},
+			{Bucket: 4, Delay: 3},
+		})
+	}
+
+	estimate, ok := e.Estimate(1, DefaultFeeConfidence, pending)
+	assert.True(t, ok)
+	rate, _ := FeeBucketRange(8)
+	assert.Equal(t, rate, estimate.FeeRate)
+	assert.Equal(t, uint32(1), estimate.Blocks)
+	assert.Equal(t, float64(1), estimate.SuccessRate)
+	assert.Equal(t, uint32(20), estimate.Samples)
+
+	estimate, ok = e.Estimate(3, DefaultFeeConfidence, pending)
+	assert.True(t, ok)
+	rate, _ = FeeBucketRange(4)
+	assert.Equal(t, rate, estimate.FeeRate)
+
+	// transactions waiting in the pool are failures of their buckets.
+	pending[4] = 10
+	estimate, ok = e.Estimate(3, DefaultFeeConfidence, pending)
+	assert.True(t, ok)
+	rate, _ = FeeBucketRange(8)
+	assert.Equal(t, rate, estimate.FeeRate)
+
+	// a lower confidence accepts the lower bucket again.
+	estimate, ok = e.Estimate(3, 0.5, pending)
+	assert.True(t, ok)
+	rate, _ = FeeBucketRange(4)
+	assert.Equal(t, rate, estimate.FeeRate)
+}
+
+func TestFeeEstimator_Window(t *testing.T) {
+	e := NewFeeEstimator()
+	e.ProcessBlock(1, []feeObservation{{Bucket: 1, Delay: 1}})
+	e.ProcessBlock(2, []feeObservation{{Bucket: 2, Delay: 1}})
+	e.ProcessBlock(3, nil)
+	assert.Equal(t, 2, len(e.blocks))
+
+	e.ProcessBlock(FeeEstimatorWindow+1, nil)
+	assert.Equal(t, 1, len(e.blocks))
+	assert.Equal(t, uint32(2), e.blocks[0].Height)
+
+	e.ProcessBlock(FeeEstimatorWindow+2, []feeObservation{{Bucket: 3, Delay: 2}})
+	assert.Equal(t, 1, len(e.blocks))
+	assert.Equal(t, FeeEstimatorWindow+2, e.blocks[0].Height)
+
+	assert.NoError(t, e.OnRollbackTo(FeeEstimatorWindow+1))
+	assert.Equal(t, 0, len(e.blocks))
+	assert.Equal(t, FeeEstimatorWindow+1, e.BestHeight())
+}
+
+func TestFeeEstimator_Deserialize(t *testing.T) {
+	e := NewFeeEstimator()
+	e.SetHeight(12)
+	e.ProcessBlock(10, []feeObservation{
+		{Bucket: 1, Delay: 1},
+		{Bucket: 5, Delay: 2},
+	})
+	e.ProcessBlock(12, []feeObservation{
+		{Bucket: 19, Delay: 7},
+	})
+
+	buf := bytes.Buffer{}
+	assert.NoError(t, e.Serialize(&buf))
+
+	e2 := NewFeeEstimator()
+	assert.NoError(t, e2.Deserialize(&buf))
+	assert.Equal(t, e.height, e2.height)
+	assert.Equal(t, e.bestHeight, e2.bestHeight)
+	assert.Equal(t, e.blocks, e2.blocks)
+
+	snapshot := e.Snapshot().(*FeeEstimator)
+	assert.Equal(t, e.blocks, snapshot.blocks)
+}
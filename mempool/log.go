@@ -0,0 +1,42 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package mempool
+
+import (
+	"github.com/elastos/Elastos.ELA/core/types/interfaces"
+	elaerr "github.com/elastos/Elastos.ELA/errors"
+	"github.com/elastos/Elastos.ELA/utils/elalog"
+)
+
+// log is a logger that is initialized with no output filters.  This
+// means the package will not perform any logging by default until the caller
+// requests it.
+var log elalog.Logger
+
+// The default amount of logging is none.
+func init() {
+	DisableLog()
+}
+
+// DisableLog disables all library log output.  Logging output is disabled
+// by default until either UseLogger or SetLogWriter are called.
+func DisableLog() {
+	log = elalog.Disabled
+}
+
+// UseLogger uses a specified Logger to output package logging info.
+// This should be used in preference to SetLogWriter if the caller is also
+// using elalog.
+func UseLogger(logger elalog.Logger) {
+	log = logger
+}
+
+// txLog returns a logger attaching the hash of the transaction and the code of
+// the error rejecting it.
+func txLog(tx interfaces.Transaction, err elaerr.ELAError) elalog.Logger {
+	return log.WithFields(elalog.TxHash(tx.Hash()),
+		elalog.ErrCode(int(err.Code())))
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package mempool
+
+import (
+	"strconv"
+
+	"github.com/elastos/Elastos.ELA/utils/metrics"
+)
+
+const (
+	// evictCapacity is the reason of transactions evicted by the lowest fee
+	// rate when the pool is full.
+	evictCapacity = "capacity"
+
+	// evictReplaced is the reason of transactions evicted by replacements.
+	evictReplaced = "replaced"
+)
+
+// evictions is the count of transactions evicted from the pool by reason.
+var evictions = metrics.NewCounter("ela_mempool_evictions_total",
+	"Number of transactions evicted from the pool.", "reason")
+
+func init() {
+	metrics.Register(evictions)
+}
+
+// registerMetrics registers the metrics collected from the pool.
+func (mp *TxPool) registerMetrics() {
+	metrics.Register(
+		metrics.NewGaugeFunc("ela_mempool_transactions",
+			"Number of transactions in the pool.", func() float64 {
+				mp.RLock()
+				defer mp.RUnlock()
+				return float64(len(mp.txnList))
+			}),
+		metrics.NewGaugeFunc("ela_mempool_bytes",
+			"Total size of transactions in the pool.", func() float64 {
+				mp.RLock()
+				defer mp.RUnlock()
+				return float64(mp.txFees.totalSize)
+			}),
+		metrics.NewCollector("ela_mempool_fee_rate_transactions",
+			"Number of transactions in the pool by fee bucket, min_fee_rate "+
+				"is the lower bound of the bucket in sela per KB.",
+			metrics.TypeGauge, []string{"min_fee_rate"}, mp.collectFeeRates),
+	)
+}
+
+// collectFeeRates returns the count of transactions in every fee bucket.
+func (mp *TxPool) collectFeeRates() []metrics.Sample {
+	var counts [FeeBucketCount]uint32
+	mp.RLock()
+	for _, tx := range mp.txnList {
+		counts[feeBucketIndex(feeRatePerKB(tx.Fee(), tx.GetSize()))]++
+	}
+	mp.RUnlock()
+
+	samples := make([]metrics.Sample, 0, FeeBucketCount)
+	for i, count := range counts {
+		min, _ := FeeBucketRange(i)
+		samples = append(samples, metrics.Sample{
+			LabelValues: []string{strconv.FormatInt(int64(min), 10)},
+			Value:       float64(count),
+		})
+	}
+	return samples
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package mempool
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/functions"
+	"github.com/elastos/Elastos.ELA/core/types/interfaces"
+	elaerr "github.com/elastos/Elastos.ELA/errors"
+)
+
+const (
+	// MempoolFileName defines the default name of the file the transaction
+	// pool is saved to.
+	MempoolFileName = "mempool.dat"
+
+	// mempoolFileVersion defines the version of the mempool file format.
+	mempoolFileVersion = uint32(1)
+)
+
+// SetDataPath sets the path of the file the transaction pool is saved to and
+// loaded from.
+func (mp *TxPool) SetDataPath(path string) {
+	mp.Lock()
+	mp.dataPath = path
+	mp.Unlock()
+}
+
+// SaveMempool writes all transactions in the pool to the data path, and
+// returns the count of saved transactions.
+func (mp *TxPool) SaveMempool() (int, error) {
+	mp.RLock()
+	path := mp.dataPath
+	mp.RUnlock()
+	if path == "" {
+		return 0, errors.New("data path of transaction pool not set")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return 0, err
+	}
+	txs := mp.GetTxsInPool()
+
+	// Write to a temporary file first, so that the saved transactions will
+	// not be corrupted if the node is killed while saving.
+	tmpPath := path + ".new"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, err
+	}
+	w := bufio.NewWriter(file)
+	err = writeMempool(w, txs)
+	if err == nil {
+		err = w.Flush()
+	}
+	if e := file.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return 0, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return 0, err
+	}
+
+	log.Infof("saved %d transactions of transaction pool to %s", len(txs), path)
+	return len(txs), nil
+}
+
+// LoadMempool reads transactions saved by SaveMempool from the data path and
+// puts them into the pool.  Every transaction is validated again against the
+// current best block, transactions no longer valid are dropped.  It returns
+// the count of accepted and dropped transactions, transactions already in the
+// pool are counted as accepted.
+func (mp *TxPool) LoadMempool() (accepted int, dropped int, err error) {
+	mp.RLock()
+	path := mp.dataPath
+	mp.RUnlock()
+	if path == "" {
+		return 0, 0, errors.New("data path of transaction pool not set")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	txs, err := readMempool(bufio.NewReader(file))
+	file.Close()
+	if err != nil {
+		return 0, 0, fmt.Errorf("read transactions from %s failed, %s",
+			path, err)
+	}
+
+	for _, tx := range txs {
+		err := mp.MaybeAcceptTransaction(tx)
+		if err == nil {
+			accepted++
+			continue
+		}
+		if e, ok := err.(elaerr.ELAError); ok &&
+			e.Code() == elaerr.ErrTxDuplicate {
+			accepted++
+			continue
+		}
+		log.Infof("drop saved tx %s, %s", tx.Hash(), err)
+		dropped++
+	}
+
+	log.Infof("loaded %d transactions to transaction pool from %s,"+
+		" %d dropped", accepted, path, dropped)
+	return accepted, dropped, nil
+}
+
+func writeMempool(w *bufio.Writer, txs []interfaces.Transaction) error {
+	if err := common.WriteUint32(w, mempoolFileVersion); err != nil {
+		return err
+	}
+	if err := common.WriteVarUint(w, uint64(len(txs))); err != nil {
+		return err
+	}
+	for _, tx := range txs {
+		if err := tx.Serialize(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readMempool(r *bufio.Reader) ([]interfaces.Transaction, error) {
+	version, err := common.ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	if version != mempoolFileVersion {
+		return nil, fmt.Errorf("unknown version %d", version)
+	}
+	count, err := common.ReadVarUint(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	txs := make([]interfaces.Transaction, 0)
+	for i := uint64(0); i < count; i++ {
+		tx, err := functions.GetTransactionByBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Deserialize(r); err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package mempool
+
+import (
+	"bufio"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/common/config"
+	elaLog "github.com/elastos/Elastos.ELA/common/log"
+	"github.com/elastos/Elastos.ELA/core/checkpoint"
+	"github.com/elastos/Elastos.ELA/core/contract/program"
+	common2 "github.com/elastos/Elastos.ELA/core/types/common"
+	"github.com/elastos/Elastos.ELA/core/types/functions"
+	"github.com/elastos/Elastos.ELA/core/types/interfaces"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/utils/test"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxPool_SaveAndLoadMempool(t *testing.T) {
+	elaLog.NewDefault(test.NodeLogPath, 0, 0, 0)
+
+	conflictTestProc(func(db *UtxoCacheDB) {
+		dir, err := os.MkdirTemp("", "mempool")
+		assert.NoError(t, err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, MempoolFileName)
+
+		pool := NewTxPool(&config.DefaultParams,
+			checkpoint.NewManager(config.GetDefaultParams()))
+		_, err = pool.SaveMempool()
+		assert.Error(t, err)
+		_, _, err = pool.LoadMempool()
+		assert.Error(t, err)
+
+		pool.SetDataPath(path)
+		_, _, err = pool.LoadMempool()
+		assert.True(t, os.IsNotExist(err))
+
+		addTx := func(tx interfaces.Transaction) {
+			assert.NoError(t, pool.AppendTx(tx))
+			assert.NoError(t, pool.doAddTransaction(tx))
+			db.PutTransaction(tx)
+		}
+		tx1 := newReplaceableTx(newPreviousTx(db), math.MaxUint32, 100)
+		tx2 := newReplaceableTx(newPreviousTx(db), math.MaxUint32, 1000)
+		tx3 := newReplaceableTx(newPreviousTx(db), math.MaxUint32, 10000)
+		tx4 := newReplaceableTx(newPreviousTx(db), math.MaxUint32, 500)
+		addTx(tx1)
+		addTx(tx2)
+		addTx(tx3)
+		addTx(tx4)
+
+		saved, err := pool.SaveMempool()
+		assert.NoError(t, err)
+		assert.Equal(t, 4, saved)
+		_, err = os.Stat(path + ".new")
+		assert.True(t, os.IsNotExist(err))
+
+		file, err := os.Open(path)
+		assert.NoError(t, err)
+		txs, err := readMempool(bufio.NewReader(file))
+		file.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, 4, len(txs))
+		saveds := make(map[common.Uint256]struct{})
+		for _, tx := range txs {
+			saveds[tx.Hash()] = struct{}{}
+		}
+		for _, tx := range []interfaces.Transaction{tx1, tx2, tx3, tx4} {
+			_, ok := saveds[tx.Hash()]
+			assert.True(t, ok)
+		}
+
+		// transactions already in the pool are counted as accepted.
+		accepted, dropped, err := pool.LoadMempool()
+		assert.NoError(t, err)
+		assert.Equal(t, 4, accepted)
+		assert.Equal(t, 0, dropped)
+
+		// transactions no longer valid are dropped.
+		invalidPool := NewTxPool(&config.DefaultParams,
+			checkpoint.NewManager(config.GetDefaultParams()))
+		invalidPool.SetDataPath(path)
+		coinbase := functions.CreateTransaction(
+			0,
+			common2.CoinBase,
+			0,
+			&payload.CoinBase{},
+			[]*common2.Attribute{},
+			[]*common2.Input{},
+			[]*common2.Output{},
+			0,
+			[]*program.Program{},
+		)
+		assert.NoError(t, invalidPool.doAddTransaction(coinbase))
+		saved, err = invalidPool.SaveMempool()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, saved)
+
+		newPool := NewTxPool(&config.DefaultParams,
+			checkpoint.NewManager(config.GetDefaultParams()))
+		newPool.SetDataPath(path)
+		accepted, dropped, err = newPool.LoadMempool()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, accepted)
+		assert.Equal(t, 1, dropped)
+		assert.Equal(t, 0, newPool.GetTransactionCount())
+
+		// corrupted file can not be loaded.
+		assert.NoError(t, os.WriteFile(path, []byte{1, 0, 0, 0, 1}, 0600))
+		_, _, err = newPool.LoadMempool()
+		assert.Error(t, err)
+	})
+}
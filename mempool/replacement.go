@@ -0,0 +1,183 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package mempool
+
+import (
+	"fmt"
+	"math"
+
+	. "github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/common"
+	"github.com/elastos/Elastos.ELA/core/types/interfaces"
+	elaerr "github.com/elastos/Elastos.ELA/errors"
+	"github.com/elastos/Elastos.ELA/events"
+)
+
+const (
+	// MaxReplacementSequence is the max input sequence signals that the
+	// transaction can be replaced by a transaction paying higher fee, the
+	// sequence equals to math.MaxUint32-1 is reserved to spend locked UTXOs.
+	MaxReplacementSequence = math.MaxUint32 - 2
+
+	// maxReplacementEvictions is the max count of transactions can be evicted
+	// from the pool by a replacement.
+	maxReplacementEvictions = 100
+)
+
+// replacedTx holds a transaction evicted by a replacement, so that it can be
+// put back into the pool if the replacement failed.
+type replacedTx struct {
+	tx   interfaces.Transaction
+	info TxReceivingInfo
+}
+
+// SignalsReplacement returns if the transaction opts in to be replaced by a
+// transaction paying higher fee.  Only TransferAsset transactions can opt in,
+// by setting the sequence of any input no more than MaxReplacementSequence.
+func SignalsReplacement(tx interfaces.Transaction) bool {
+	if tx.TxType() != common.TransferAsset {
+		return false
+	}
+	for _, input := range tx.Inputs() {
+		if input.Sequence <= MaxReplacementSequence {
+			return true
+		}
+	}
+	return false
+}
+
+// getDescendants returns transactions in the pool spending outputs of the
+// given transaction, and transactions spending their outputs recursively.
+func (mp *TxPool) getDescendants(tx interfaces.Transaction,
+	descendants map[Uint256]interfaces.Transaction) {
+	txHash := tx.Hash()
+	for i := range tx.Outputs() {
+		input := common.Input{
+			Previous: common.OutPoint{
+				TxID:  txHash,
+				Index: uint16(i),
+			},
+		}
+		child := mp.getInputUTXOList(&input)
+		if child == nil {
+			continue
+		}
+		if _, ok := descendants[child.Hash()]; ok {
+			continue
+		}
+		descendants[child.Hash()] = child
+		mp.getDescendants(child, descendants)
+	}
+}
+
+// getReplacedTransactions returns transactions in the pool that the given
+// transaction replaces, including the descendants of the conflicting ones.
+// It returns nothing if the transaction is not a TransferAsset transaction
+// or any of the conflicting transactions did not opt in to be replaced, then
+// the conflicts will be rejected as usual.
+func (mp *TxPool) getReplacedTransactions(
+	tx interfaces.Transaction) ([]interfaces.Transaction, elaerr.ELAError) {
+	if tx.TxType() != common.TransferAsset {
+		return nil, nil
+	}
+
+	conflicts := make(map[Uint256]interfaces.Transaction)
+	for _, input := range tx.Inputs() {
+		conflict := mp.getInputUTXOList(input)
+		if conflict == nil {
+			continue
+		}
+		if !SignalsReplacement(conflict) {
+			return nil, nil
+		}
+		conflicts[conflict.Hash()] = conflict
+	}
+	if len(conflicts) == 0 {
+		return nil, nil
+	}
+
+	evicted := make(map[Uint256]interfaces.Transaction)
+	for hash, conflict := range conflicts {
+		evicted[hash] = conflict
+		mp.getDescendants(conflict, evicted)
+	}
+	if len(evicted) > maxReplacementEvictions {
+		return nil, elaerr.SimpleWithMessage(elaerr.ErrTxPoolReplacementRejected,
+			nil, fmt.Sprintf("replacing %d transactions exceeds the max"+
+				" count %d", len(evicted), maxReplacementEvictions))
+	}
+
+	// The replacement should pay higher fee than all the evicted
+	// transactions together, and higher fee rate than each of them.
+	fee := tx.Fee()
+	feeRate := float64(fee) / float64(tx.GetSize())
+	var evictedFee Fixed64
+	result := make([]interfaces.Transaction, 0, len(evicted))
+	for hash, e := range evicted {
+		evictedFee += e.Fee()
+		if feeRate <= float64(e.Fee())/float64(e.GetSize()) {
+			return nil, elaerr.SimpleWithMessage(
+				elaerr.ErrTxPoolReplacementRejected, nil, fmt.Sprintf(
+					"fee rate of replacement is not higher than %s", hash))
+		}
+		result = append(result, e)
+	}
+	if fee <= evictedFee {
+		return nil, elaerr.SimpleWithMessage(elaerr.ErrTxPoolReplacementRejected,
+			nil, fmt.Sprintf("fee %s of replacement is not higher than %s"+
+				" of replaced transactions", fee, evictedFee))
+	}
+
+	return result, nil
+}
+
+// removeReplacedTransactions removes transactions replaced by the given
+// transaction from the pool without notifying, and returns them.
+func (mp *TxPool) removeReplacedTransactions(
+	tx interfaces.Transaction) ([]replacedTx, elaerr.ELAError) {
+	txs, err := mp.getReplacedTransactions(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	replaced := make([]replacedTx, 0, len(txs))
+	for _, t := range txs {
+		replaced = append(replaced, replacedTx{
+			tx:   t,
+			info: mp.txReceivingInfo[t.Hash()],
+		})
+		mp.deleteTransaction(t)
+	}
+	return replaced, nil
+}
+
+// restoreReplacedTransactions puts the replaced transactions back into the
+// pool after the replacement failed.
+func (mp *TxPool) restoreReplacedTransactions(replaced []replacedTx) {
+	for _, r := range replaced {
+		if err := mp.AppendTx(r.tx); err != nil {
+			log.Warnf("restore replaced tx %s failed, %s", r.tx.Hash(), err)
+			continue
+		}
+		if err := mp.doAddTransaction(r.tx); err != nil {
+			log.Warnf("restore replaced tx %s failed, %s", r.tx.Hash(), err)
+			mp.removeTx(r.tx)
+			continue
+		}
+		mp.txReceivingInfo[r.tx.Hash()] = r.info
+	}
+}
+
+// notifyReplacedTransactions notifies the transactions evicted by the given
+// replacement are removed from the pool.
+func (mp *TxPool) notifyReplacedTransactions(tx interfaces.Transaction,
+	replaced []replacedTx) {
+	for _, r := range replaced {
+		log.Infof("tx %s replaced by %s", r.tx.Hash(), tx.Hash())
+		evictions.Inc(evictReplaced)
+		go events.Notify(events.ETTransactionRemoved, r.tx)
+	}
+}
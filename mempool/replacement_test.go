@@ -0,0 +1,135 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package mempool
+
+import (
+	"math"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/common/config"
+	"github.com/elastos/Elastos.ELA/core/checkpoint"
+	"github.com/elastos/Elastos.ELA/core/contract/program"
+	common2 "github.com/elastos/Elastos.ELA/core/types/common"
+	"github.com/elastos/Elastos.ELA/core/types/functions"
+	"github.com/elastos/Elastos.ELA/core/types/interfaces"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	elaerr "github.com/elastos/Elastos.ELA/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newReplaceableTx(prevTx interfaces.Transaction, sequence uint32,
+	fee common.Fixed64) interfaces.Transaction {
+	tx := functions.CreateTransaction(
+		0,
+		common2.TransferAsset,
+		0,
+		&payload.TransferAsset{},
+		[]*common2.Attribute{{
+			Usage: common2.Nonce,
+			Data:  randomNonceData(),
+		}},
+		[]*common2.Input{{
+			Previous: common2.OutPoint{
+				TxID:  prevTx.Hash(),
+				Index: 0,
+			},
+			Sequence: sequence,
+		}},
+		[]*common2.Output{{
+			Value:       1,
+			ProgramHash: *randomProgramHash(),
+		}},
+		0,
+		[]*program.Program{},
+	)
+	tx.SetFee(fee)
+	return tx
+}
+
+func TestSignalsReplacement(t *testing.T) {
+	prevTx := newPreviousTx(NewUtxoCacheDB())
+	assert.True(t, SignalsReplacement(newReplaceableTx(prevTx, 0, 100)))
+	assert.True(t, SignalsReplacement(newReplaceableTx(prevTx,
+		MaxReplacementSequence, 100)))
+	assert.False(t, SignalsReplacement(newReplaceableTx(prevTx,
+		math.MaxUint32-1, 100)))
+	assert.False(t, SignalsReplacement(newReplaceableTx(prevTx,
+		math.MaxUint32, 100)))
+
+	tx := newReplaceableTx(prevTx, 0, 100)
+	tx.SetTxType(common2.TransferCrossChainAsset)
+	assert.False(t, SignalsReplacement(tx))
+}
+
+func TestTxPool_ReplaceTransaction(t *testing.T) {
+	conflictTestProc(func(db *UtxoCacheDB) {
+		pool := NewTxPool(&config.DefaultParams,
+			checkpoint.NewManager(config.GetDefaultParams()))
+		addTx := func(tx interfaces.Transaction) {
+			assert.NoError(t, pool.AppendTx(tx))
+			assert.NoError(t, pool.doAddTransaction(tx))
+			pool.txReceivingInfo[tx.Hash()] = TxReceivingInfo{Height: 10}
+		}
+
+		prevTx := newPreviousTx(db)
+		original := newReplaceableTx(prevTx, 0, 1000)
+		addTx(original)
+
+		// a child spending the original should be evicted along with it.
+		db.PutTransaction(original)
+		child := newReplaceableTx(original, math.MaxUint32, 500)
+		addTx(child)
+		assert.Equal(t, 2, pool.txFees.GetSize())
+
+		// not paying more than the evicted transactions together.
+		_, err := pool.removeReplacedTransactions(
+			newReplaceableTx(prevTx, 0, 1500))
+		assert.Equal(t, elaerr.ErrTxPoolReplacementRejected, err.Code())
+
+		// not paying higher fee rate than each evicted transaction.
+		bigger := newReplaceableTx(prevTx, 0, 1000)
+		bigger.SetAttributes(append(bigger.Attributes(), &common2.Attribute{
+			Usage: common2.Memo,
+			Data:  make([]byte, 100*original.GetSize()),
+		}))
+		bigger.SetFee(1600)
+		_, err = pool.removeReplacedTransactions(bigger)
+		assert.Equal(t, elaerr.ErrTxPoolReplacementRejected, err.Code())
+		assert.Equal(t, 2, pool.txFees.GetSize())
+
+		// the replacement evicts the original and its child.
+		replacement := newReplaceableTx(prevTx, 0, 1600)
+		assert.Error(t, pool.VerifyTx(replacement))
+		replaced, err := pool.removeReplacedTransactions(replacement)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(replaced))
+		assert.Equal(t, 0, len(pool.txnList))
+		assert.Equal(t, 0, pool.txFees.GetSize())
+		assert.Equal(t, uint64(0), pool.txFees.totalSize)
+		assert.Equal(t, 0, len(pool.txReceivingInfo))
+		assert.NoError(t, pool.VerifyTx(replacement))
+
+		// the evicted transactions are restored if the replacement failed.
+		pool.restoreReplacedTransactions(replaced)
+		assert.Equal(t, 2, len(pool.txnList))
+		assert.Equal(t, 2, pool.txFees.GetSize())
+		assert.Equal(t, TxReceivingInfo{Height: 10},
+			pool.txReceivingInfo[original.Hash()])
+		assert.Error(t, pool.VerifyTx(replacement))
+
+		// transactions not opting in can not be replaced.
+		prevTx2 := newPreviousTx(db)
+		final := newReplaceableTx(prevTx2, math.MaxUint32, 1000)
+		addTx(final)
+		replaced, err = pool.removeReplacedTransactions(
+			newReplaceableTx(prevTx2, 0, 5000))
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(replaced))
+		assert.Equal(t, 3, len(pool.txnList))
+	})
+}
@@ -14,13 +14,13 @@ import (
 	"github.com/elastos/Elastos.ELA/blockchain"
 	. "github.com/elastos/Elastos.ELA/common"
 	"github.com/elastos/Elastos.ELA/common/config"
-	"github.com/elastos/Elastos.ELA/common/log"
 	"github.com/elastos/Elastos.ELA/core/checkpoint"
 	. "github.com/elastos/Elastos.ELA/core/types"
 	"github.com/elastos/Elastos.ELA/core/types/common"
 	"github.com/elastos/Elastos.ELA/core/types/interfaces"
 	"github.com/elastos/Elastos.ELA/core/types/outputpayload"
 	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/elanet/pact"
 	elaerr "github.com/elastos/Elastos.ELA/errors"
 	"github.com/elastos/Elastos.ELA/events"
 )
@@ -36,6 +36,8 @@ type TxPool struct {
 	crossChainHeightList map[Uint256]uint32
 	CkpManager           *checkpoint.Manager
 	txReceivingInfo      map[Uint256]TxReceivingInfo
+	feeEstimator         *FeeEstimator
+	dataPath             string
 
 	sync.RWMutex
 }
@@ -109,19 +111,41 @@ func (mp *TxPool) appendToTxPool(tx interfaces.Transaction) elaerr.ELAError {
 	}
 
 	if err := chain.CheckTransactionSanity(bestHeight+1, tx); err != nil {
-		log.Warn("[TxPool CheckTransactionSanity] failed", tx.Hash())
+		txLog(tx, err).Warn("[TxPool CheckTransactionSanity] failed", tx.Hash())
 		return err
 	}
 	if _, err := chain.CheckTransactionContext(
 		bestHeight+1, tx, mp.proposalsUsedAmount, 0); err != nil {
-		log.Warnf("[TxPool CheckTransactionContext] failed, hash: %s, err: %s", tx.Hash(),
-			err)
+		txLog(tx, err).Warnf("[TxPool CheckTransactionContext] failed, hash: %s, err: %s",
+			tx.Hash(), err)
 		return err
 	}
+
+	// Evict the transactions replaced by this one, and put them back if this
+	// one is not accepted.
+	replaced, err := mp.removeReplacedTransactions(tx)
+	if err != nil {
+		log.Warnf("[TxPool removeReplacedTransactions] failed, hash: %s,"+
+			" err: %s", tx.Hash(), err)
+		return err
+	}
+	if err := mp.addTransaction(tx, bestHeight); err != nil {
+		mp.restoreReplacedTransactions(replaced)
+		return err
+	}
+	mp.notifyReplacedTransactions(tx, replaced)
+
+	return nil
+}
+
+// addTransaction verifies the transaction with transactions in the pool, and
+// adds it into the pool.
+func (mp *TxPool) addTransaction(tx interfaces.Transaction,
+	bestHeight uint32) elaerr.ELAError {
 	//verify transaction by pool with lock
 	if err := mp.verifyTransactionWithTxnPool(tx); err != nil {
-		log.Error("[TxPool verifyTransactionWithTxnPool] err", err)
-		log.Warn("[TxPool verifyTransactionWithTxnPool] failed", tx.Hash())
+		txLog(tx, err).Error("[TxPool verifyTransactionWithTxnPool] err", err)
+		txLog(tx, err).Warn("[TxPool verifyTransactionWithTxnPool] failed", tx.Hash())
 		return err
 	}
 
@@ -131,8 +155,8 @@ func (mp *TxPool) appendToTxPool(tx interfaces.Transaction) elaerr.ELAError {
 		return elaerr.Simple(elaerr.ErrTxPoolOverCapacity, nil)
 	}
 	if err := mp.AppendTx(tx); err != nil {
-		log.Error("[TxPool AppendTx] err", err)
-		log.Warn("[TxPool AppendTx] failed", tx.Hash())
+		txLog(tx, err).Error("[TxPool AppendTx] err", err)
+		txLog(tx, err).Warn("[TxPool AppendTx] failed", tx.Hash())
 		return err
 	}
 	// Add the transaction to mem pool
@@ -198,6 +222,7 @@ func (mp *TxPool) GetTxsInPool() []interfaces.Transaction {
 // clean the transaction Pool with committed block.
 func (mp *TxPool) CleanSubmittedTransactions(block *Block) {
 	mp.Lock()
+	mp.recordFeeObservations(block)
 	mp.cleanTransactions(block.Transactions)
 	mp.cleanSideChainPowTx()
 	if err := mp.cleanCanceledProducerAndCR(block.Transactions); err != nil {
@@ -206,6 +231,128 @@ func (mp *TxPool) CleanSubmittedTransactions(block *Block) {
 	mp.Unlock()
 }
 
+// recordFeeObservations records the fee rates and waiting blocks of pooled
+// transactions confirmed by the block into the fee estimator.
+func (mp *TxPool) recordFeeObservations(block *Block) {
+	observations := make([]feeObservation, 0)
+	for _, blockTx := range block.Transactions {
+		hash := blockTx.Hash()
+		tx, ok := mp.txnList[hash]
+		if !ok {
+			continue
+		}
+		info, ok := mp.txReceivingInfo[hash]
+		if !ok || block.Height <= info.Height {
+			continue
+		}
+		feeRate := feeRatePerKB(tx.Fee(), tx.GetSize())
+		observations = append(observations, feeObservation{
+			Bucket: uint8(feeBucketIndex(feeRate)),
+			Delay:  block.Height - info.Height,
+		})
+	}
+	mp.feeEstimator.ProcessBlock(block.Height, observations)
+}
+
+// EstimateFee returns the fee rate in sela per KB for a transaction to be
+// confirmed within target blocks with the given confidence.  The estimation
+// is based on the confirmation history of recent blocks and is raised if the
+// transactions waiting in the pool need more than target blocks to be packed.
+func (mp *TxPool) EstimateFee(target uint32, confidence float64) (
+	*FeeEstimate, bool) {
+	mp.RLock()
+	defer mp.RUnlock()
+
+	// Transactions waiting longer than target blocks are failures of their
+	// buckets.
+	var pending [FeeBucketCount]uint32
+	bestHeight := mp.feeEstimator.BestHeight()
+	for hash, info := range mp.txReceivingInfo {
+		if bestHeight < info.Height+target {
+			continue
+		}
+		tx, ok := mp.txnList[hash]
+		if !ok {
+			continue
+		}
+		pending[feeBucketIndex(feeRatePerKB(tx.Fee(), tx.GetSize()))]++
+	}
+	estimate, ok := mp.feeEstimator.Estimate(target, confidence, pending)
+
+	// The fee rate of the transaction that can not be packed into the next
+	// target blocks is the lowest rate to compete with the pool.
+	var poolFeeRate Fixed64
+	var size uint64
+	maxSize := uint64(target) * uint64(pact.MaxBlockContextSize)
+	for _, item := range mp.txFees.list {
+		size += uint64(item.Size)
+		if size > maxSize {
+			poolFeeRate = Fixed64(item.FeeRate*1000) + 1
+			break
+		}
+	}
+
+	if !ok {
+		if poolFeeRate == 0 {
+			return nil, false
+		}
+		estimate = &FeeEstimate{Blocks: target}
+	}
+	if poolFeeRate > estimate.FeeRate {
+		estimate.FeeRate = poolFeeRate
+	}
+	return estimate, true
+}
+
+// FeeHistogramBucket holds the count and total size of the transactions in
+// the pool whose fee rate is in the range of a fee bucket.
+type FeeHistogramBucket struct {
+	MinFeeRate Fixed64
+	MaxFeeRate Fixed64
+	Count      uint32
+	Size       uint64
+}
+
+// MempoolInfo holds the statistics of the transaction pool.
+type MempoolInfo struct {
+	Count        uint32
+	Size         uint64
+	MaxSize      uint64
+	FeeHistogram []FeeHistogramBucket
+	TxTypes      map[common.TxType]uint32
+}
+
+// GetMempoolInfo returns the statistics of transactions in the pool, the fee
+// histogram only contains the fee buckets holding transactions.
+func (mp *TxPool) GetMempoolInfo() *MempoolInfo {
+	mp.RLock()
+	defer mp.RUnlock()
+
+	var buckets [FeeBucketCount]FeeHistogramBucket
+	info := &MempoolInfo{
+		Count:   uint32(len(mp.txnList)),
+		Size:    mp.txFees.totalSize,
+		MaxSize: mp.txFees.maxSize,
+		TxTypes: make(map[common.TxType]uint32),
+	}
+	for _, tx := range mp.txnList {
+		size := tx.GetSize()
+		bucket := &buckets[feeBucketIndex(feeRatePerKB(tx.Fee(), size))]
+		bucket.Count++
+		bucket.Size += uint64(size)
+		info.TxTypes[tx.TxType()]++
+	}
+	info.FeeHistogram = make([]FeeHistogramBucket, 0)
+	for i, bucket := range buckets {
+		if bucket.Count == 0 {
+			continue
+		}
+		bucket.MinFeeRate, bucket.MaxFeeRate = FeeBucketRange(i)
+		info.FeeHistogram = append(info.FeeHistogram, bucket)
+	}
+	return info
+}
+
 // ResendOutdatedTransactions Resend outdated transactions
 func (mp *TxPool) ResendOutdatedTransactions(block *Block) {
 	mp.Lock()
@@ -301,8 +448,13 @@ func (mp *TxPool) cleanTransactions(blockTxs []interfaces.Transaction) {
 						blockTx.Hash(), tx.Hash(), input.Previous.TxID, input.Previous.Index)
 				}
 
-				//1.remove from txnList
-				mp.doRemoveTransaction(tx)
+				//1.remove from txnList, a transaction packed into the
+				// block is not reported as removed.
+				if tx.Hash() == blockTx.Hash() {
+					mp.deleteTransaction(tx)
+				} else {
+					mp.doRemoveTransaction(tx)
+				}
 
 				deleteCount++
 			}
@@ -597,11 +749,21 @@ func (mp *TxPool) doAddTransaction(tx interfaces.Transaction) elaerr.ELAError {
 }
 
 func (mp *TxPool) doRemoveTransaction(tx interfaces.Transaction) {
-	hash := tx.Hash()
-	txSize := tx.GetSize()
-	feeRate := float64(tx.Fee()) / float64(txSize)
+	if mp.deleteTransaction(tx) {
+		go events.Notify(events.ETTransactionRemoved, tx)
+	}
+}
 
-	if _, exist := mp.txnList[hash]; exist {
+// deleteTransaction removes the transaction from the pool and returns if the
+// transaction was in the pool.
+func (mp *TxPool) deleteTransaction(tx interfaces.Transaction) bool {
+	hash := tx.Hash()
+	// Use the transaction in the pool, the fee of the given one may not be
+	// calculated, thus it can not be located in the fee ordered list.
+	tx, exist := mp.txnList[hash]
+	if exist {
+		txSize := tx.GetSize()
+		feeRate := float64(tx.Fee()) / float64(txSize)
 		delete(mp.txnList, hash)
 		if tx.IsCRCProposalTx() {
 			mp.dealDelProposalTx(tx)
@@ -615,6 +777,7 @@ func (mp *TxPool) doRemoveTransaction(tx interfaces.Transaction) {
 		mp.txFees.RemoveTx(hash, uint64(txSize), feeRate)
 		mp.removeTx(tx)
 	}
+	return exist
 }
 
 func (mp *TxPool) onPopBack(hash Uint256) {
@@ -629,7 +792,8 @@ func (mp *TxPool) onPopBack(hash Uint256) {
 	}
 	delete(mp.txnList, hash)
 	mp.dealDelProposalTx(tx)
-
+	evictions.Inc(evictCapacity)
+	go events.Notify(events.ETTransactionRemoved, tx)
 }
 
 func NewTxPool(params *config.Configuration, ckpManager *checkpoint.Manager) *TxPool {
@@ -640,6 +804,7 @@ func NewTxPool(params *config.Configuration, ckpManager *checkpoint.Manager) *Tx
 		proposalsUsedAmount:  0,
 		crossChainHeightList: make(map[Uint256]uint32),
 		txReceivingInfo:      make(map[Uint256]TxReceivingInfo),
+		feeEstimator:         NewFeeEstimator(),
 	}
 	rtn.txPoolCheckpoint = newTxPoolCheckpoint(
 		rtn, func(m map[Uint256]interfaces.Transaction) {
@@ -650,5 +815,7 @@ func NewTxPool(params *config.Configuration, ckpManager *checkpoint.Manager) *Tx
 			}
 		})
 	rtn.CkpManager.Register(rtn.txPoolCheckpoint)
+	rtn.CkpManager.Register(rtn.feeEstimator)
+	rtn.registerMetrics()
 	return rtn
 }
@@ -18,7 +18,7 @@ import (
 	"github.com/elastos/Elastos.ELA/blockchain"
 	"github.com/elastos/Elastos.ELA/common"
 	"github.com/elastos/Elastos.ELA/common/config"
-	"github.com/elastos/Elastos.ELA/common/log"
+	elaLog "github.com/elastos/Elastos.ELA/common/log"
 	"github.com/elastos/Elastos.ELA/core"
 	"github.com/elastos/Elastos.ELA/core/checkpoint"
 	"github.com/elastos/Elastos.ELA/core/contract"
@@ -80,8 +80,8 @@ func NewUtxoCacheDB() *UtxoCacheDB {
 }
 
 func TestTxPoolInit(t *testing.T) {
-	log.NewDefault(test.NodeLogPath, 0, 0, 0)
-	dplog.Init("elastos", 0, 0, 0)
+	elaLog.NewDefault(test.NodeLogPath, 0, 0, 0)
+	dplog.Init("elastos", elaLog.Config{})
 
 	ckpManager := checkpoint.NewManager(config.GetDefaultParams())
 	params := &config.DefaultParams
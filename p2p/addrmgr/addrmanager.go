@@ -48,6 +48,7 @@ type AddrManager struct {
 	localAddresses map[string]*localAddress
 
 	checkAddr func(addr string) error
+	lookup    func(host string) ([]net.IP, error)
 }
 
 type serializedKnownAddress struct {
@@ -172,6 +173,12 @@ func (a *AddrManager) SetCheckAddr(checkAddr func(addr string) error) {
 	a.checkAddr = checkAddr
 }
 
+// SetLookup used to set function to resolve host names in addrManager, such
+// as resolving through Tor to not leak DNS queries.
+func (a *AddrManager) SetLookup(lookup func(host string) ([]net.IP, error)) {
+	a.lookup = lookup
+}
+
 // updateAddress is a helper function to either update an address already known
 // to the address manager, or to add the address if not already known.
 func (a *AddrManager) updateAddress(netAddr, srcAddr *p2p.NetAddress) {
@@ -756,6 +763,15 @@ func (a *AddrManager) reset() {
 // is a Tor .onion address this will be taken care of.  Else if the host is
 // not an IP address it will be resolved (via Tor if required).
 func (a *AddrManager) HostToNetAddress(host string, port uint16, services uint64) (*p2p.NetAddress, error) {
+	// Tor v3 address is 56 char base32 + ".onion"
+	if len(host) == 62 && p2p.IsOnionHost(host) {
+		pubKey, err := p2p.DecodeTorV3(host)
+		if err != nil {
+			return nil, err
+		}
+		return p2p.NewNetAddressTorV3(pubKey, port, services), nil
+	}
+
 	// Tor address is 16 char base32 + ".onion"
 	var ip net.IP
 	if len(host) == 22 && host[16:] == ".onion" {
@@ -769,8 +785,10 @@ func (a *AddrManager) HostToNetAddress(host string, port uint16, services uint64
 		}
 		prefix := []byte{0xfd, 0x87, 0xd8, 0x7e, 0xeb, 0x43}
 		ip = net.IP(append(prefix, data...))
+	} else if p2p.IsOnionHost(host) {
+		return nil, fmt.Errorf("invalid onion address %s", host)
 	} else if ip = net.ParseIP(host); ip == nil {
-		ips, err := net.LookupIP(host)
+		ips, err := a.lookup(host)
 		if err != nil {
 			return nil, err
 		}
@@ -787,6 +805,9 @@ func (a *AddrManager) HostToNetAddress(host string, port uint16, services uint64
 // ip is in the range used for Tor addresses then it will be transformed into
 // the relevant .onion address.
 func ipString(na *p2p.NetAddress) string {
+	if na.TorV3 != nil {
+		return p2p.EncodeTorV3(na.TorV3)
+	}
 	if IsOnionCatTor(na) {
 		// We know now that na.IP is long enough.
 		base32 := base32.StdEncoding.EncodeToString(na.IP[6:])
@@ -1181,6 +1202,7 @@ func New(dataDir string, filter p2p.NAFilter) *AddrManager {
 		rand:           rand.New(rand.NewSource(time.Now().UnixNano())),
 		quit:           make(chan struct{}),
 		localAddresses: make(map[string]*localAddress),
+		lookup:         net.LookupIP,
 	}
 	am.reset()
 	return &am
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -495,3 +496,60 @@ func TestLoadPeers(t *testing.T) {
 
 	am.Start()
 }
+
+func TestHostToNetAddress(t *testing.T) {
+	n := addrmgr.New("testhosttonetaddress", nil)
+	n.SetLookup(func(host string) ([]net.IP, error) {
+		return nil, fmt.Errorf("unexpected lookup of %s", host)
+	})
+
+	const onionV3 = "2gzyxa5ihm7nsggfxnu52rck2vv4rvmdlkiu3zzui5du4xyclen53wid.onion"
+	tests := []struct {
+		host    string
+		key     string
+		network addrmgr.Network
+		group   string
+	}{
+		{host: someIP, key: someIP + ":20338", network: addrmgr.IPv4,
+			group: "173.194.0.0"},
+		{host: "2602:100::1", key: "[2602:100::1]:20338",
+			network: addrmgr.IPv6, group: "2602:100::"},
+		{host: "expyuzz4wqqyqhjn.onion", key: "expyuzz4wqqyqhjn.onion:20338",
+			network: addrmgr.Onion, group: "tor:5"},
+		{host: onionV3, key: onionV3 + ":20338", network: addrmgr.Onion,
+			group: "tor:1"},
+	}
+	for _, test := range tests {
+		na, err := n.HostToNetAddress(test.host, 20338, 1)
+		if err != nil {
+			t.Errorf("HostToNetAddress(%s): unexpected error %v", test.host,
+				err)
+			continue
+		}
+		if key := addrmgr.NetAddressKey(na); key != test.key {
+			t.Errorf("NetAddressKey(%s): got %s, want %s", test.host, key,
+				test.key)
+		}
+		if network := addrmgr.GetNetwork(na); network != test.network {
+			t.Errorf("GetNetwork(%s): got %s, want %s", test.host, network,
+				test.network)
+		}
+		if group := addrmgr.GroupKey(na); group != test.group {
+			t.Errorf("GroupKey(%s): got %s, want %s", test.host, group,
+				test.group)
+		}
+
+		// The address is restored from the key saved in peers file.
+		restored, err := n.DeserializeNetAddress(test.key)
+		if err != nil || addrmgr.NetAddressKey(restored) != test.key {
+			t.Errorf("DeserializeNetAddress(%s): got %v, %v", test.key,
+				restored, err)
+		}
+	}
+
+	// Onion names are not resolved.
+	if _, err := n.HostToNetAddress("invalid.onion", 20338, 1); err == nil ||
+		strings.Contains(err.Error(), "lookup") {
+		t.Errorf("HostToNetAddress(invalid.onion): got error %v", err)
+	}
+}
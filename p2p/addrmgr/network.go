@@ -8,6 +8,7 @@ package addrmgr
 import (
 	"fmt"
 	"net"
+	"strings"
 
 	"github.com/elastos/Elastos.ELA/p2p"
 )
@@ -118,6 +119,43 @@ func IsOnionCatTor(na *p2p.NetAddress) bool {
 	return onionCatNet.Contains(na.IP)
 }
 
+// Network is the name of a network peers are reachable through.
+type Network string
+
+const (
+	// IPv4 is the network of IPv4 addresses.
+	IPv4 Network = "ipv4"
+
+	// IPv6 is the network of IPv6 addresses.
+	IPv6 Network = "ipv6"
+
+	// Onion is the network of Tor onion addresses.
+	Onion Network = "onion"
+)
+
+// ParseNetwork returns the network of the given name, the name is case
+// insensitive.
+func ParseNetwork(name string) (Network, error) {
+	switch network := Network(strings.ToLower(name)); network {
+	case IPv4, IPv6, Onion:
+		return network, nil
+	}
+	return "", fmt.Errorf("unknown network %s, expect ipv4, ipv6 or onion",
+		name)
+}
+
+// GetNetwork returns the network of the given address.
+func GetNetwork(na *p2p.NetAddress) Network {
+	switch {
+	case IsOnionCatTor(na):
+		return Onion
+	case IsIPv4(na):
+		return IPv4
+	default:
+		return IPv6
+	}
+}
+
 // IsRFC1918 returns whether or not the passed address is part of the IPv4
 // private network address space as defined by RFC1918 (10.0.0.0/8,
 // 172.16.0.0/12, or 192.168.0.0/16).
@@ -200,3 +200,17 @@ func TestGroupKey(t *testing.T) {
 		}
 	}
 }
+
+func TestParseNetwork(t *testing.T) {
+	for name, want := range map[string]addrmgr.Network{
+		"ipv4": addrmgr.IPv4, "IPv6": addrmgr.IPv6, "onion": addrmgr.Onion,
+	} {
+		if network, err := addrmgr.ParseNetwork(name); err != nil ||
+			network != want {
+			t.Errorf("ParseNetwork(%s): got %s, %v", name, network, err)
+		}
+	}
+	if _, err := addrmgr.ParseNetwork("i2p"); err == nil {
+		t.Errorf("ParseNetwork(i2p): expected error")
+	}
+}
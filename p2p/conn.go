@@ -0,0 +1,44 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package p2p
+
+import (
+	"net"
+	"sync/atomic"
+)
+
+// StatsConn is a connection counting the bytes read and written, the
+// counters are the first fields to keep them 64-bit aligned for atomic access
+// when StatsConn is the first field of a struct.
+type StatsConn struct {
+	// The following variables must only be used atomically.
+	bytesRead    uint64
+	bytesWritten uint64
+
+	net.Conn
+}
+
+func (c *StatsConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddUint64(&c.bytesRead, uint64(n))
+	return n, err
+}
+
+func (c *StatsConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddUint64(&c.bytesWritten, uint64(n))
+	return n, err
+}
+
+// BytesRead returns the total bytes read from the connection.
+func (c *StatsConn) BytesRead() uint64 {
+	return atomic.LoadUint64(&c.bytesRead)
+}
+
+// BytesWritten returns the total bytes written to the connection.
+func (c *StatsConn) BytesWritten() uint64 {
+	return atomic.LoadUint64(&c.bytesWritten)
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package connmgr
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	socksVersion = 0x05
+
+	socksAuthNone     = 0x00
+	socksAuthPassword = 0x02
+
+	socksCmdConnect = 0x01
+
+	socksAtypIPv4   = 0x01
+	socksAtypDomain = 0x03
+	socksAtypIPv6   = 0x04
+)
+
+var (
+	// ErrProxyAuthFailed indicates the proxy rejected the username and
+	// password.
+	ErrProxyAuthFailed = errors.New("proxy authentication failed")
+)
+
+// Proxy dials connections through a SOCKS5 proxy, such as a Tor client.
+// Host names are sent to the proxy to resolve, so neither DNS queries nor
+// connections leak the node IP.
+type Proxy struct {
+	// Addr is the address of the proxy, eg. 127.0.0.1:9050.
+	Addr string
+
+	// Username and Password authenticate to the proxy if the username is
+	// not empty.
+	Username string
+	Password string
+
+	// TorIsolation enables stream isolation by authenticating with random
+	// credentials for each connection, so Tor uses a different circuit for
+	// each peer (IsolateSOCKSAuth).  It overrides the Username and Password.
+	TorIsolation bool
+}
+
+// DialTimeout connects to the address on the named network through the
+// proxy.  The timeout includes the proxy handshake.
+func (p *Proxy) DialTimeout(network, addr string, timeout time.Duration) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout(network, p.Addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	if err := p.connect(conn, host, uint16(port)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s connect %s failed, %s", p.Addr,
+			addr, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// credentials returns the username and password to authenticate with.
+func (p *Proxy) credentials() (string, string) {
+	if !p.TorIsolation {
+		return p.Username, p.Password
+	}
+	var buf [16]byte
+	rand.Read(buf[:])
+	return hex.EncodeToString(buf[:8]), hex.EncodeToString(buf[8:])
+}
+
+// connect negotiates the SOCKS5 handshake and requests the proxy to connect
+// to the host.
+func (p *Proxy) connect(conn net.Conn, host string, port uint16) error {
+	if len(host) > 255 {
+		return errors.New("host name too long")
+	}
+	username, password := p.credentials()
+
+	method := byte(socksAuthNone)
+	if username != "" {
+		method = socksAuthPassword
+	}
+	if _, err := conn.Write([]byte{socksVersion, 1, method}); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return err
+	}
+	if buf[0] != socksVersion {
+		return ErrTorInvalidProxyResponse
+	}
+	if buf[1] != method {
+		return ErrTorUnrecognizedAuthMethod
+	}
+
+	if method == socksAuthPassword {
+		if len(username) > 255 || len(password) > 255 {
+			return errors.New("proxy username or password too long")
+		}
+		req := []byte{0x01, byte(len(username))}
+		req = append(req, username...)
+		req = append(req, byte(len(password)))
+		req = append(req, password...)
+		if _, err := conn.Write(req); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return err
+		}
+		if buf[1] != 0x00 {
+			return ErrProxyAuthFailed
+		}
+	}
+
+	req := []byte{socksVersion, socksCmdConnect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		req = append(req, socksAtypDomain, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, socksAtypIPv4)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, socksAtypIPv6)
+		req = append(req, ip.To16()...)
+	}
+	req = append(req, 0, 0)
+	binary.BigEndian.PutUint16(req[len(req)-2:], port)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	// Reply is version, status, reserved, address type, the bound address
+	// and port.
+	buf = make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return err
+	}
+	if buf[0] != socksVersion {
+		return ErrTorInvalidProxyResponse
+	}
+	if buf[1] != torSucceeded {
+		if err, ok := torStatusErrors[buf[1]]; ok {
+			return err
+		}
+		return ErrTorInvalidProxyResponse
+	}
+
+	var addrLen int
+	switch buf[3] {
+	case socksAtypIPv4:
+		addrLen = net.IPv4len
+	case socksAtypIPv6:
+		addrLen = net.IPv6len
+	case socksAtypDomain:
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return err
+		}
+		addrLen = int(buf[0])
+	default:
+		return ErrTorInvalidAddressResponse
+	}
+	_, err := io.ReadFull(conn, make([]byte, addrLen+2))
+	return err
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package connmgr
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// socksRequest is a connect request received by the mock SOCKS5 proxy.
+type socksRequest struct {
+	username string
+	password string
+	host     string
+	port     uint16
+}
+
+// mockSocksProxy serves the SOCKS5 connect requests, it replies the status to
+// each request and echoes the data of the succeeded connections.
+func mockSocksProxy(t *testing.T, status byte) (string, chan socksRequest) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := make(chan socksRequest, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSocks(conn, status, requests)
+		}
+	}()
+	return l.Addr().String(), requests
+}
+
+func serveSocks(conn net.Conn, status byte, requests chan socksRequest) {
+	defer conn.Close()
+	readBytes := func(n int) []byte {
+		buf := make([]byte, n)
+		io.ReadFull(conn, buf)
+		return buf
+	}
+
+	var req socksRequest
+	methods := readBytes(int(readBytes(2)[1]))
+	conn.Write([]byte{socksVersion, methods[0]})
+	if methods[0] == socksAuthPassword {
+		req.username = string(readBytes(int(readBytes(2)[1])))
+		req.password = string(readBytes(int(readBytes(1)[0])))
+		conn.Write([]byte{0x01, 0x00})
+	}
+
+	hdr := readBytes(4)
+	switch hdr[3] {
+	case socksAtypDomain:
+		req.host = string(readBytes(int(readBytes(1)[0])))
+	case socksAtypIPv4:
+		req.host = net.IP(readBytes(net.IPv4len)).String()
+	case socksAtypIPv6:
+		req.host = net.IP(readBytes(net.IPv6len)).String()
+	}
+	req.port = binary.BigEndian.Uint16(readBytes(2))
+	requests <- req
+
+	conn.Write([]byte{socksVersion, status, 0, socksAtypIPv4, 127, 0, 0, 1,
+		0, 0})
+	if status == torSucceeded {
+		io.Copy(conn, conn)
+	}
+}
+
+func TestProxy_DialTimeout(t *testing.T) {
+	addr, requests := mockSocksProxy(t, torSucceeded)
+	const onion = "2gzyxa5ihm7nsggfxnu52rck2vv4rvmdlkiu3zzui5du4xyclen53wid.onion"
+
+	tests := []struct {
+		proxy Proxy
+		host  string
+	}{
+		{proxy: Proxy{Addr: addr}, host: onion},
+		{proxy: Proxy{Addr: addr, Username: "user", Password: "pass"},
+			host: "12.1.2.3"},
+		{proxy: Proxy{Addr: addr, TorIsolation: true}, host: "2602:100::1"},
+	}
+	for _, test := range tests {
+		target := net.JoinHostPort(test.host, "20338")
+		conn, err := test.proxy.DialTimeout("tcp", target, time.Second)
+		if err != nil {
+			t.Fatalf("DialTimeout %s: unexpected error %v", target, err)
+		}
+
+		req := <-requests
+		if req.host != test.host || req.port != 20338 {
+			t.Errorf("proxy connected to %s, want %s",
+				net.JoinHostPort(req.host, strconv.Itoa(int(req.port))),
+				target)
+		}
+		username, password := test.proxy.Username, test.proxy.Password
+		if test.proxy.TorIsolation {
+			if req.username == "" || req.password == "" {
+				t.Errorf("isolated connection authenticated with %q:%q",
+					req.username, req.password)
+			}
+		} else if req.username != username || req.password != password {
+			t.Errorf("authenticated with %q:%q, want %q:%q", req.username,
+				req.password, username, password)
+		}
+
+		// The connection is usable after the handshake.
+		conn.Write([]byte("ping"))
+		buf := make([]byte, 4)
+		if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+			t.Errorf("read %q, %v", buf, err)
+		}
+		conn.Close()
+	}
+
+	// Isolated connections use different credentials.
+	proxy := Proxy{Addr: addr, TorIsolation: true}
+	users := make(map[string]struct{})
+	for i := 0; i < 3; i++ {
+		conn, err := proxy.DialTimeout("tcp", "12.1.2.3:20338", time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+		users[(<-requests).username] = struct{}{}
+	}
+	if len(users) != 3 {
+		t.Errorf("isolated connections share credentials")
+	}
+}
+
+func TestProxy_DialTimeoutRefused(t *testing.T) {
+	addr, _ := mockSocksProxy(t, torHostUnreachable)
+	proxy := Proxy{Addr: addr}
+	_, err := proxy.DialTimeout("tcp", "12.1.2.3:20338", time.Second)
+	if err == nil {
+		t.Fatalf("DialTimeout: expected error")
+	}
+}
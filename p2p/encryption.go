@@ -0,0 +1,298 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package p2p
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// EncKeySize is the size of the ephemeral public key exchanged to
+	// negotiate the encrypted transport.
+	EncKeySize = curve25519.PointSize
+
+	// MaxFrameSize is the maximum plaintext bytes of an encrypted frame,
+	// larger writes are split into multiple frames.
+	MaxFrameSize = 1 << 18
+
+	// frameLenSize is the size of the encrypted frame length.
+	frameLenSize = 3
+
+	// sessionSalt is the HKDF salt prefix of the session keys, followed by
+	// the network magic so sessions are not valid across networks.
+	sessionSalt = "ela_p2p_encryption_v1"
+)
+
+var (
+	ErrFrameSizeExceeded = errors.New("encrypted frame size exceeded")
+	ErrInvalidFrame      = errors.New("encrypted frame authentication failed")
+)
+
+// EphemeralKey is the X25519 key pair generated for each connection to
+// negotiate the session keys.  It must not be reused.
+type EphemeralKey struct {
+	private [32]byte
+	Public  [EncKeySize]byte
+}
+
+// NewEphemeralKey generates a random ephemeral key pair.
+func NewEphemeralKey() (*EphemeralKey, error) {
+	var key EphemeralKey
+	if _, err := io.ReadFull(rand.Reader, key.private[:]); err != nil {
+		return nil, err
+	}
+	pub, err := curve25519.X25519(key.private[:], curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	copy(key.Public[:], pub)
+	return &key, nil
+}
+
+// Session is the keys of an encrypted connection derived from the ephemeral
+// key exchange.  Each direction uses its own keys.
+type Session struct {
+	// ID identifies the session, both sides derive the same ID.  Signing
+	// the ID with a long-term key binds the identity to the session.
+	ID [32]byte
+
+	sendLenKey, sendKey [32]byte
+	recvLenKey, recvKey [32]byte
+}
+
+// NewSession derives the session keys from the local ephemeral key and the
+// remote public key.  The initiator is the side opened the connection, the
+// two sides of a connection must not be both or neither initiator.
+func NewSession(key *EphemeralKey, remote [EncKeySize]byte, magic uint32,
+	initiator bool) (*Session, error) {
+	secret, err := curve25519.X25519(key.private[:], remote[:])
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, len(sessionSalt)+4)
+	copy(salt, sessionSalt)
+	binary.LittleEndian.PutUint32(salt[len(sessionSalt):], magic)
+
+	// The transcript is the initiator public key followed by the responder
+	// public key.
+	transcript := make([]byte, 0, EncKeySize*2)
+	if initiator {
+		transcript = append(transcript, key.Public[:]...)
+		transcript = append(transcript, remote[:]...)
+	} else {
+		transcript = append(transcript, remote[:]...)
+		transcript = append(transcript, key.Public[:]...)
+	}
+
+	// Derive the initiator keys, the responder keys and the session ID.
+	var keys [5][32]byte
+	kdf := hkdf.New(sha256.New, secret, salt, transcript)
+	for i := range keys {
+		if _, err := io.ReadFull(kdf, keys[i][:]); err != nil {
+			return nil, err
+		}
+	}
+
+	s := Session{ID: keys[4]}
+	if initiator {
+		s.sendLenKey, s.sendKey = keys[0], keys[1]
+		s.recvLenKey, s.recvKey = keys[2], keys[3]
+	} else {
+		s.sendLenKey, s.sendKey = keys[2], keys[3]
+		s.recvLenKey, s.recvKey = keys[0], keys[1]
+	}
+	return &s, nil
+}
+
+// frameCipher encrypts or decrypts the frames of one direction.  The frame
+// length is encrypted with ChaCha20 and the payload is encrypted with
+// ChaCha20-Poly1305 taking the encrypted length as additional data, both
+// use the frame counter as nonce.
+type frameCipher struct {
+	lenKey  []byte
+	aead    cipher.AEAD
+	counter uint64
+}
+
+func newFrameCipher(lenKey, key [32]byte) (*frameCipher, error) {
+	aead, err := chacha20poly1305.New(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return &frameCipher{lenKey: lenKey[:], aead: aead}, nil
+}
+
+// nonce returns the nonce of the current frame.
+func (c *frameCipher) nonce() []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.LittleEndian.PutUint64(nonce[4:], c.counter)
+	return nonce
+}
+
+// xorLength encrypts or decrypts the frame length in place.
+func (c *frameCipher) xorLength(nonce, length []byte) error {
+	stream, err := chacha20.NewUnauthenticatedCipher(c.lenKey, nonce)
+	if err != nil {
+		return err
+	}
+	stream.XORKeyStream(length, length)
+	return nil
+}
+
+// seal returns the encrypted frame of the plaintext.
+func (c *frameCipher) seal(plaintext []byte) ([]byte, error) {
+	nonce := c.nonce()
+	frame := make([]byte, frameLenSize,
+		frameLenSize+len(plaintext)+chacha20poly1305.Overhead)
+	size := len(plaintext)
+	frame[0], frame[1], frame[2] = byte(size), byte(size>>8), byte(size>>16)
+	if err := c.xorLength(nonce, frame); err != nil {
+		return nil, err
+	}
+
+	var ad [frameLenSize]byte
+	copy(ad[:], frame)
+	frame = c.aead.Seal(frame, nonce, plaintext, ad[:])
+	c.counter++
+	return frame, nil
+}
+
+// open reads and decrypts the next frame from r.
+func (c *frameCipher) open(r io.Reader) ([]byte, error) {
+	var length, ad [frameLenSize]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	copy(ad[:], length[:])
+
+	nonce := c.nonce()
+	if err := c.xorLength(nonce, length[:]); err != nil {
+		return nil, err
+	}
+	size := int(length[0]) | int(length[1])<<8 | int(length[2])<<16
+	if size > MaxFrameSize {
+		return nil, ErrFrameSizeExceeded
+	}
+
+	frame := make([]byte, size+chacha20poly1305.Overhead)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	plaintext, err := c.aead.Open(frame[:0], nonce, frame, ad[:])
+	if err != nil {
+		return nil, ErrInvalidFrame
+	}
+	c.counter++
+	return plaintext, nil
+}
+
+// EncryptedConn is a connection transfers the data in plaintext until
+// Encrypt is called, then the data is sent in authenticated encrypted frames.
+// Encrypt must be called before the connection is shared by goroutines.
+type EncryptedConn struct {
+	net.Conn
+
+	writeMtx sync.Mutex
+	send     *frameCipher
+
+	readMtx sync.Mutex
+	recv    *frameCipher
+	readBuf []byte
+	readErr error
+}
+
+// NewEncryptedConn returns a plaintext connection of conn which is able to
+// start encryption.
+func NewEncryptedConn(conn net.Conn) *EncryptedConn {
+	return &EncryptedConn{Conn: conn}
+}
+
+// Encrypt starts encrypting the data in both directions with the session
+// keys.  The data already buffered by the remote side must be consumed before
+// calling Encrypt.
+func (c *EncryptedConn) Encrypt(s *Session) error {
+	send, err := newFrameCipher(s.sendLenKey, s.sendKey)
+	if err != nil {
+		return err
+	}
+	recv, err := newFrameCipher(s.recvLenKey, s.recvKey)
+	if err != nil {
+		return err
+	}
+	c.writeMtx.Lock()
+	c.send = send
+	c.writeMtx.Unlock()
+	c.readMtx.Lock()
+	c.recv = recv
+	c.readMtx.Unlock()
+	return nil
+}
+
+// Encrypted returns whether the connection has started encryption.
+func (c *EncryptedConn) Encrypted() bool {
+	c.writeMtx.Lock()
+	defer c.writeMtx.Unlock()
+	return c.send != nil
+}
+
+func (c *EncryptedConn) Read(b []byte) (int, error) {
+	c.readMtx.Lock()
+	defer c.readMtx.Unlock()
+	if c.recv == nil {
+		return c.Conn.Read(b)
+	}
+
+	// The frames are not able to be resynchronized after an error, so
+	// the connection is unusable then.
+	for len(c.readBuf) == 0 {
+		if c.readErr != nil {
+			return 0, c.readErr
+		}
+		c.readBuf, c.readErr = c.recv.open(c.Conn)
+	}
+	n := copy(b, c.readBuf)
+	c.readBuf = c.readBuf[n:]
+	return n, nil
+}
+
+func (c *EncryptedConn) Write(b []byte) (int, error) {
+	c.writeMtx.Lock()
+	defer c.writeMtx.Unlock()
+	if c.send == nil {
+		return c.Conn.Write(b)
+	}
+
+	var n int
+	for len(b) > 0 {
+		size := len(b)
+		if size > MaxFrameSize {
+			size = MaxFrameSize
+		}
+		frame, err := c.send.seal(b[:size])
+		if err != nil {
+			return n, err
+		}
+		if _, err := c.Conn.Write(frame); err != nil {
+			return n, err
+		}
+		n += size
+		b = b[size:]
+	}
+	return n, nil
+}
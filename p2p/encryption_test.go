@@ -0,0 +1,157 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package p2p
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+)
+
+// newSessions returns the sessions of the initiator and the responder.
+func newSessions(t *testing.T, magic uint32) (*Session, *Session) {
+	initKey, err := NewEphemeralKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	respKey, err := NewEphemeralKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	initSession, err := NewSession(initKey, respKey.Public, magic, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	respSession, err := NewSession(respKey, initKey.Public, magic, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return initSession, respSession
+}
+
+func TestNewSession(t *testing.T) {
+	initSession, respSession := newSessions(t, 123123)
+	if initSession.ID != respSession.ID {
+		t.Errorf("session ID mismatch %x, %x", initSession.ID, respSession.ID)
+	}
+	if initSession.sendKey != respSession.recvKey ||
+		initSession.recvKey != respSession.sendKey ||
+		initSession.sendLenKey != respSession.recvLenKey {
+		t.Errorf("session keys mismatch")
+	}
+	if initSession.sendKey == initSession.recvKey {
+		t.Errorf("both directions use the same key")
+	}
+
+	// Sessions are not valid across networks.
+	key, _ := NewEphemeralKey()
+	remote, _ := NewEphemeralKey()
+	s1, _ := NewSession(key, remote.Public, 123123, true)
+	s2, _ := NewSession(key, remote.Public, 123124, true)
+	if s1.ID == s2.ID || s1.sendKey == s2.sendKey {
+		t.Errorf("sessions of different networks share keys")
+	}
+
+	// The low order public key is rejected.
+	if _, err := NewSession(key, [EncKeySize]byte{}, 123123, true); err == nil {
+		t.Errorf("NewSession: expected error with zero public key")
+	}
+}
+
+func TestEncryptedConn(t *testing.T) {
+	c1, c2 := net.Pipe()
+	conn1, conn2 := NewEncryptedConn(c1), NewEncryptedConn(c2)
+	defer conn1.Close()
+	defer conn2.Close()
+
+	initSession, respSession := newSessions(t, 123123)
+	conn1.Encrypt(initSession)
+	conn2.Encrypt(respSession)
+	if !conn1.Encrypted() || !conn2.Encrypted() {
+		t.Fatalf("connection is not encrypted")
+	}
+
+	// Writes larger than the frame size are split into multiple frames.
+	data := make([]byte, MaxFrameSize*2+100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	for _, msg := range [][]byte{[]byte("version"), data} {
+		go func(msg []byte) {
+			if _, err := conn1.Write(msg); err != nil {
+				t.Errorf("Write: unexpected error %v", err)
+			}
+		}(msg)
+		buf := make([]byte, len(msg))
+		if _, err := io.ReadFull(conn2, buf); err != nil {
+			t.Fatalf("Read: unexpected error %v", err)
+		}
+		if !bytes.Equal(buf, msg) {
+			t.Errorf("Read: data mismatch")
+		}
+	}
+}
+
+func TestEncryptedConn_Tampered(t *testing.T) {
+	initSession, respSession := newSessions(t, 123123)
+
+	// Record the frames written by the initiator.
+	var frames bytes.Buffer
+	send, _ := newFrameCipher(initSession.sendLenKey, initSession.sendKey)
+	for _, msg := range []string{"ping", "pong"} {
+		frame, err := send.seal([]byte(msg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames.Write(frame)
+	}
+	raw := frames.Bytes()
+
+	_, otherSession := newSessions(t, 123123)
+
+	tests := []struct {
+		name    string
+		session *Session
+		frames  func() []byte
+	}{
+		{"flipped payload", respSession, func() []byte {
+			b := append([]byte(nil), raw...)
+			b[frameLenSize] ^= 0x01
+			return b
+		}},
+		{"flipped length", respSession, func() []byte {
+			b := append([]byte(nil), raw...)
+			b[0] ^= 0x01
+			return b
+		}},
+		{"reordered", respSession, func() []byte {
+			half := len(raw) / 2
+			return append(append([]byte(nil), raw[half:]...), raw[:half]...)
+		}},
+		{"other session", otherSession, func() []byte {
+			return raw
+		}},
+	}
+	for _, test := range tests {
+		recv, _ := newFrameCipher(test.session.recvLenKey, test.session.recvKey)
+		_, err := recv.open(bytes.NewReader(test.frames()))
+		if err != ErrInvalidFrame && err != ErrFrameSizeExceeded &&
+			err != io.ErrUnexpectedEOF {
+			t.Errorf("%s: got error %v, want invalid frame", test.name, err)
+		}
+	}
+
+	// The untampered frames are decrypted in order.
+	recv, _ := newFrameCipher(respSession.recvLenKey, respSession.recvKey)
+	r := bytes.NewReader(raw)
+	for _, msg := range []string{"ping", "pong"} {
+		plaintext, err := recv.open(r)
+		if err != nil || string(plaintext) != msg {
+			t.Errorf("open: got %q, %v, want %q", plaintext, err, msg)
+		}
+	}
+}
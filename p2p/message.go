@@ -42,6 +42,7 @@ const (
 	CmdVerAck      = "verack"
 	CmdGetAddr     = "getaddr"
 	CmdAddr        = "addr"
+	CmdAddrV2      = "addrv2"
 	CmdGetBlocks   = "getblocks"
 	CmdInv         = "inv"
 	CmdGetData     = "getdata"
@@ -58,6 +59,12 @@ const (
 	CmdReject      = "reject"
 	CmdTxFilter    = "txfilter"
 	CmdDAddr       = "daddr"
+	CmdCmpctBlock  = "cmpctblock"
+	CmdGetBlockTxn = "getblocktxn"
+	CmdBlockTxn    = "blocktxn"
+	CmdGetHeaders  = "getheaders"
+	CmdHeaders     = "headers"
+	CmdEncInit     = "encinit"
 )
 
 var (
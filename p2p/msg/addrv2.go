@@ -0,0 +1,92 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package msg
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/p2p"
+)
+
+// maxAddrV2Length is the maximum length of an address in the addrv2 message,
+// 8 bytes timestamp, 8 bytes services, 1 byte network ID, 1 byte address
+// length, the address and 2 bytes port.
+const maxAddrV2Length = 20 + p2p.MaxAddrV2Size
+
+// Ensure AddrV2 implement p2p.Message interface.
+var _ p2p.Message = (*AddrV2)(nil)
+
+// AddrV2 is the addr message encoding addresses with their network IDs,
+// which is able to relay Tor v3 onion addresses.  It is only sent to peers
+// advertising the SFNodeAddrV2 service.
+type AddrV2 struct {
+	Addr
+}
+
+func NewAddrV2(addresses []*p2p.NetAddress) *AddrV2 {
+	return &AddrV2{Addr{AddrList: addresses}}
+}
+
+func (msg *AddrV2) CMD() string {
+	return p2p.CmdAddrV2
+}
+
+func (msg *AddrV2) MaxLength() uint32 {
+	return 8 + (MaxAddrPerMsg * maxAddrV2Length)
+}
+
+func (msg *AddrV2) Serialize(w io.Writer) error {
+	count := len(msg.AddrList)
+	if count > MaxAddrPerMsg {
+		str := fmt.Sprintf("too many addresses for message "+
+			"[count %v, max %v]", count, MaxAddrPerMsg)
+		return common.FuncError("AddrV2.Serialize", str)
+	}
+
+	err := common.WriteUint64(w, uint64(count))
+	if err != nil {
+		return err
+	}
+
+	for _, na := range msg.AddrList {
+		if err := na.SerializeV2(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (msg *AddrV2) Deserialize(r io.Reader) error {
+	count, err := common.ReadUint64(r)
+	if err != nil {
+		return err
+	}
+
+	// Limit to max addresses per message.
+	if count > MaxAddrPerMsg {
+		return fmt.Errorf("AddrV2.Deserialize too many addresses"+
+			" for message [count %v, max %v]", count, MaxAddrPerMsg)
+	}
+
+	addrList := make([]p2p.NetAddress, count)
+	msg.AddrList = make([]*p2p.NetAddress, 0, count)
+	for i := uint64(0); i < count; i++ {
+		na := &addrList[i]
+		err := na.DeserializeV2(r)
+		if err == p2p.ErrUnknownNetwork {
+			// Ignore addresses of the networks unknown to us.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		msg.AddrList = append(msg.AddrList, na)
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package msg
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/functions"
+	"github.com/elastos/Elastos.ELA/core/types/interfaces"
+	"github.com/elastos/Elastos.ELA/elanet/pact"
+	"github.com/elastos/Elastos.ELA/p2p"
+)
+
+// Ensure BlockTxn implement p2p.Message interface.
+var _ p2p.Message = (*BlockTxn)(nil)
+
+// BlockTxn responds a GetBlockTxn message with the requested transactions of
+// the block, in the order of the requested indexes.
+type BlockTxn struct {
+	BlockHash    common.Uint256
+	Transactions []interfaces.Transaction
+}
+
+func NewBlockTxn(blockHash common.Uint256,
+	txs []interfaces.Transaction) *BlockTxn {
+	return &BlockTxn{BlockHash: blockHash, Transactions: txs}
+}
+
+func (msg *BlockTxn) CMD() string {
+	return p2p.CmdBlockTxn
+}
+
+func (msg *BlockTxn) MaxLength() uint32 {
+	return pact.MaxBlockContextSize
+}
+
+func (msg *BlockTxn) Serialize(w io.Writer) error {
+	if uint32(len(msg.Transactions)) > pact.MaxTxPerBlock {
+		str := fmt.Sprintf("too many transactions for message "+
+			"[count %v, max %v]", len(msg.Transactions), pact.MaxTxPerBlock)
+		return common.FuncError("BlockTxn.Serialize", str)
+	}
+
+	if err := msg.BlockHash.Serialize(w); err != nil {
+		return err
+	}
+	if err := common.WriteVarUint(w, uint64(len(msg.Transactions))); err != nil {
+		return err
+	}
+	for _, tx := range msg.Transactions {
+		if err := tx.Serialize(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (msg *BlockTxn) Deserialize(r io.Reader) error {
+	if err := msg.BlockHash.Deserialize(r); err != nil {
+		return err
+	}
+	count, err := common.ReadVarUint(r, 0)
+	if err != nil {
+		return err
+	}
+	if count > uint64(pact.MaxTxPerBlock) {
+		return fmt.Errorf("BlockTxn.Deserialize too many transactions "+
+			"for message [count %v, max %v]", count, pact.MaxTxPerBlock)
+	}
+	msg.Transactions = make([]interfaces.Transaction, 0, count)
+	for i := uint64(0); i < count; i++ {
+		tx, err := functions.GetTransactionByBytes(r)
+		if err != nil {
+			return err
+		}
+		if err := tx.Deserialize(r); err != nil {
+			return err
+		}
+		msg.Transactions = append(msg.Transactions, tx)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package msg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/functions"
+	"github.com/elastos/Elastos.ELA/core/types/interfaces"
+	"github.com/elastos/Elastos.ELA/elanet/pact"
+	"github.com/elastos/Elastos.ELA/p2p"
+)
+
+// ShortIDSize is the size in bytes of a short transaction ID in a compact
+// block.
+const ShortIDSize = 6
+
+// Ensure CmpctBlock implement p2p.Message interface.
+var _ p2p.Message = (*CmpctBlock)(nil)
+
+// PrefilledTx is a transaction sent along with a compact block, which the
+// receiver is not likely to have, such as the coinbase.
+type PrefilledTx struct {
+	Index uint32
+	Tx    interfaces.Transaction
+}
+
+// CmpctBlock represents a block by its header and short IDs of transactions,
+// so that the receiver can rebuild the block from its transaction pool.  Short
+// IDs are in the order of transactions in the block, skipping the prefilled
+// ones.
+type CmpctBlock struct {
+	Header       common.Serializable
+	Nonce        uint64
+	ShortIDs     []uint64
+	PrefilledTxs []*PrefilledTx
+}
+
+func NewCmpctBlock(header common.Serializable) *CmpctBlock {
+	return &CmpctBlock{Header: header}
+}
+
+func (msg *CmpctBlock) CMD() string {
+	return p2p.CmdCmpctBlock
+}
+
+func (msg *CmpctBlock) MaxLength() uint32 {
+	return pact.MaxBlockContextSize + pact.MaxBlockHeaderSize
+}
+
+// TxCount returns the count of transactions in the block.
+func (msg *CmpctBlock) TxCount() int {
+	return len(msg.ShortIDs) + len(msg.PrefilledTxs)
+}
+
+func (msg *CmpctBlock) Serialize(w io.Writer) error {
+	if uint32(msg.TxCount()) > pact.MaxTxPerBlock {
+		str := fmt.Sprintf("too many transactions for message "+
+			"[count %v, max %v]", msg.TxCount(), pact.MaxTxPerBlock)
+		return common.FuncError("CmpctBlock.Serialize", str)
+	}
+
+	if err := msg.Header.Serialize(w); err != nil {
+		return err
+	}
+	if err := common.WriteUint64(w, msg.Nonce); err != nil {
+		return err
+	}
+
+	if err := common.WriteVarUint(w, uint64(len(msg.ShortIDs))); err != nil {
+		return err
+	}
+	var buf [8]byte
+	for _, id := range msg.ShortIDs {
+		binary.LittleEndian.PutUint64(buf[:], id)
+		if _, err := w.Write(buf[:ShortIDSize]); err != nil {
+			return err
+		}
+	}
+
+	if err := common.WriteVarUint(w, uint64(len(msg.PrefilledTxs))); err != nil {
+		return err
+	}
+	for _, p := range msg.PrefilledTxs {
+		if err := common.WriteVarUint(w, uint64(p.Index)); err != nil {
+			return err
+		}
+		if err := p.Tx.Serialize(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (msg *CmpctBlock) Deserialize(r io.Reader) error {
+	if err := msg.Header.Deserialize(r); err != nil {
+		return err
+	}
+	var err error
+	if msg.Nonce, err = common.ReadUint64(r); err != nil {
+		return err
+	}
+
+	count, err := common.ReadVarUint(r, 0)
+	if err != nil {
+		return err
+	}
+	if count > uint64(pact.MaxTxPerBlock) {
+		return fmt.Errorf("CmpctBlock.Deserialize too many short IDs "+
+			"for message [count %v, max %v]", count, pact.MaxTxPerBlock)
+	}
+	msg.ShortIDs = make([]uint64, 0, count)
+	var buf [8]byte
+	for i := uint64(0); i < count; i++ {
+		if _, err := io.ReadFull(r, buf[:ShortIDSize]); err != nil {
+			return err
+		}
+		msg.ShortIDs = append(msg.ShortIDs, binary.LittleEndian.Uint64(buf[:]))
+	}
+
+	count, err = common.ReadVarUint(r, 0)
+	if err != nil {
+		return err
+	}
+	if count+uint64(len(msg.ShortIDs)) > uint64(pact.MaxTxPerBlock) {
+		return fmt.Errorf("CmpctBlock.Deserialize too many transactions "+
+			"for message [count %v, max %v]",
+			count+uint64(len(msg.ShortIDs)), pact.MaxTxPerBlock)
+	}
+	msg.PrefilledTxs = make([]*PrefilledTx, 0, count)
+	for i := uint64(0); i < count; i++ {
+		index, err := common.ReadVarUint(r, 0)
+		if err != nil {
+			return err
+		}
+		tx, err := functions.GetTransactionByBytes(r)
+		if err != nil {
+			return err
+		}
+		if err := tx.Deserialize(r); err != nil {
+			return err
+		}
+		msg.PrefilledTxs = append(msg.PrefilledTxs, &PrefilledTx{
+			Index: uint32(index),
+			Tx:    tx,
+		})
+	}
+	return nil
+}
+
+// ShortIDKey returns the key to calculate short transaction IDs of a compact
+// block with the given block hash and nonce.  The nonce is chosen randomly by
+// each sender, so that collisions of short IDs can not be made on purpose
+// across the network.
+func ShortIDKey(blockHash common.Uint256, nonce uint64) common.Uint256 {
+	buf := new(bytes.Buffer)
+	blockHash.Serialize(buf)
+	common.WriteUint64(buf, nonce)
+	return sha256.Sum256(buf.Bytes())
+}
+
+// ShortTxID returns the short ID of the transaction with the given hash, by
+// the key returned by ShortIDKey.
+func ShortTxID(key common.Uint256, txHash common.Uint256) uint64 {
+	var buf [common.UINT256SIZE * 2]byte
+	copy(buf[:], key[:])
+	copy(buf[common.UINT256SIZE:], txHash[:])
+	hash := sha256.Sum256(buf[:])
+	return binary.LittleEndian.Uint64(hash[:8]) & (1<<(ShortIDSize*8) - 1)
+}
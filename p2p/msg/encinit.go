@@ -0,0 +1,45 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package msg
+
+import (
+	"io"
+
+	"github.com/elastos/Elastos.ELA/p2p"
+)
+
+// Ensure EncInit implement p2p.Message interface.
+var _ p2p.Message = (*EncInit)(nil)
+
+// EncInit carries the ephemeral public key to negotiate the encrypted
+// transport.  It is sent by both sides right after the version messages when
+// both peers advertise the encryption service, the following messages are
+// encrypted.
+type EncInit struct {
+	PubKey [p2p.EncKeySize]byte
+}
+
+func (msg *EncInit) CMD() string {
+	return p2p.CmdEncInit
+}
+
+func (msg *EncInit) MaxLength() uint32 {
+	return p2p.EncKeySize
+}
+
+func (msg *EncInit) Serialize(w io.Writer) error {
+	_, err := w.Write(msg.PubKey[:])
+	return err
+}
+
+func (msg *EncInit) Deserialize(r io.Reader) error {
+	_, err := io.ReadFull(r, msg.PubKey[:])
+	return err
+}
+
+func NewEncInit(pubKey [p2p.EncKeySize]byte) *EncInit {
+	return &EncInit{PubKey: pubKey}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package msg
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/elanet/pact"
+	"github.com/elastos/Elastos.ELA/p2p"
+)
+
+// Ensure GetBlockTxn implement p2p.Message interface.
+var _ p2p.Message = (*GetBlockTxn)(nil)
+
+// GetBlockTxn requests transactions of a block by their indexes, which are
+// missing when rebuilding the block from a compact block.
+type GetBlockTxn struct {
+	BlockHash common.Uint256
+	Indexes   []uint32
+}
+
+func NewGetBlockTxn(blockHash common.Uint256, indexes []uint32) *GetBlockTxn {
+	return &GetBlockTxn{BlockHash: blockHash, Indexes: indexes}
+}
+
+func (msg *GetBlockTxn) CMD() string {
+	return p2p.CmdGetBlockTxn
+}
+
+func (msg *GetBlockTxn) MaxLength() uint32 {
+	return common.UINT256SIZE + 9 + pact.MaxTxPerBlock*5
+}
+
+func (msg *GetBlockTxn) Serialize(w io.Writer) error {
+	if uint32(len(msg.Indexes)) > pact.MaxTxPerBlock {
+		str := fmt.Sprintf("too many indexes for message "+
+			"[count %v, max %v]", len(msg.Indexes), pact.MaxTxPerBlock)
+		return common.FuncError("GetBlockTxn.Serialize", str)
+	}
+
+	if err := msg.BlockHash.Serialize(w); err != nil {
+		return err
+	}
+	if err := common.WriteVarUint(w, uint64(len(msg.Indexes))); err != nil {
+		return err
+	}
+	for _, index := range msg.Indexes {
+		if err := common.WriteVarUint(w, uint64(index)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (msg *GetBlockTxn) Deserialize(r io.Reader) error {
+	if err := msg.BlockHash.Deserialize(r); err != nil {
+		return err
+	}
+	count, err := common.ReadVarUint(r, 0)
+	if err != nil {
+		return err
+	}
+	if count > uint64(pact.MaxTxPerBlock) {
+		return fmt.Errorf("GetBlockTxn.Deserialize too many indexes "+
+			"for message [count %v, max %v]", count, pact.MaxTxPerBlock)
+	}
+	msg.Indexes = make([]uint32, 0, count)
+	for i := uint64(0); i < count; i++ {
+		index, err := common.ReadVarUint(r, 0)
+		if err != nil {
+			return err
+		}
+		msg.Indexes = append(msg.Indexes, uint32(index))
+	}
+	return nil
+}
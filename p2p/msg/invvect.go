@@ -30,6 +30,8 @@ const (
 	InvTypeFilteredBlock
 	InvTypeConfirmedBlock
 	InvTypeAddress
+	InvTypeCompactBlock
+	InvTypeCompactConfirmedBlock
 )
 
 func (i InvType) String() string {
@@ -46,6 +48,10 @@ func (i InvType) String() string {
 		return "MSG_CONFIRMED_BLOCK"
 	case InvTypeAddress:
 		return "MSG_ADDRESS"
+	case InvTypeCompactBlock:
+		return "MSG_CMPCT_BLOCK"
+	case InvTypeCompactConfirmedBlock:
+		return "MSG_CMPCT_CONFIRMED_BLOCK"
 	default:
 		return fmt.Sprintf("Unknown InvType (%d)", i)
 	}
@@ -6,6 +6,8 @@
 package p2p
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -14,6 +16,30 @@ import (
 	"github.com/elastos/Elastos.ELA/common"
 )
 
+// Network IDs of the addresses in the addrv2 message, which are the same as
+// BIP155.
+const (
+	NetworkIPv4  uint8 = 0x01
+	NetworkIPv6  uint8 = 0x02
+	NetworkTorV2 uint8 = 0x03
+	NetworkTorV3 uint8 = 0x04
+)
+
+// MaxAddrV2Size is the maximum size of an address in the addrv2 message.
+const MaxAddrV2Size = 32
+
+// ErrUnknownNetwork indicates the network ID of an addrv2 address is unknown
+// to this node, the address should be ignored.
+var ErrUnknownNetwork = errors.New("unknown network ID")
+
+// networkAddrSizes are the address sizes of the known networks.
+var networkAddrSizes = map[uint8]int{
+	NetworkIPv4:  net.IPv4len,
+	NetworkIPv6:  net.IPv6len,
+	NetworkTorV2: 10,
+	NetworkTorV3: TorV3KeySize,
+}
+
 // NetAddress defines information about a peer on the network including the time
 // it was last seen, the services it supports, its IP address, and port.
 type NetAddress struct {
@@ -31,9 +57,17 @@ type NetAddress struct {
 
 	// Port the peer is using. This is encoded in little endian.
 	Port uint16
+
+	// TorV3 is the public key of the Tor v3 onion service if it is an onion
+	// address, the IP is then the OnionCat address derived from the key.
+	// Onion v3 addresses are only encoded in the addrv2 message.
+	TorV3 []byte
 }
 
 func (na NetAddress) String() string {
+	if na.TorV3 != nil {
+		return fmt.Sprint(EncodeTorV3(na.TorV3), ":", na.Port)
+	}
 	return fmt.Sprint(na.IP.String(), ":", na.Port)
 }
 
@@ -73,6 +107,77 @@ func (na *NetAddress) Deserialize(r io.Reader) error {
 	return nil
 }
 
+// SerializeV2 serializes a NetAddress to w in the addrv2 format, which encodes
+// the network ID and the address of the network instead of a 16 bytes IP.
+func (na *NetAddress) SerializeV2(w io.Writer) error {
+	err := common.WriteElements(w, na.Timestamp.Unix(), na.Services)
+	if err != nil {
+		return err
+	}
+
+	var network uint8
+	var addr []byte
+	switch {
+	case na.TorV3 != nil:
+		network, addr = NetworkTorV3, na.TorV3
+	case na.IP.To4() != nil:
+		network, addr = NetworkIPv4, na.IP.To4()
+	case bytes.HasPrefix(na.IP, onionCatPrefix):
+		network, addr = NetworkTorV2, na.IP[len(onionCatPrefix):]
+	default:
+		var ip [16]byte
+		copy(ip[:], na.IP.To16())
+		network, addr = NetworkIPv6, ip[:]
+	}
+	if err := common.WriteUint8(w, network); err != nil {
+		return err
+	}
+	if err := common.WriteVarBytes(w, addr); err != nil {
+		return err
+	}
+	return common.WriteUint16(w, na.Port)
+}
+
+// DeserializeV2 reads a NetAddress in the addrv2 format from r.  It returns
+// ErrUnknownNetwork if the network ID is unknown, the address is read
+// completely in this case so the following addresses can still be read.
+func (na *NetAddress) DeserializeV2(r io.Reader) error {
+	var timestamp int64
+	var network uint8
+	err := common.ReadElements(r, &timestamp, &na.Services, &network)
+	if err != nil {
+		return err
+	}
+	addr, err := common.ReadVarBytes(r, MaxAddrV2Size, "NetAddress.Addr")
+	if err != nil {
+		return err
+	}
+	if na.Port, err = common.ReadUint16(r); err != nil {
+		return err
+	}
+	na.Timestamp = time.Unix(timestamp, 0)
+
+	size, ok := networkAddrSizes[network]
+	if !ok {
+		return ErrUnknownNetwork
+	}
+	if len(addr) != size {
+		return fmt.Errorf("invalid address length %d of network %d",
+			len(addr), network)
+	}
+	switch network {
+	case NetworkIPv4, NetworkIPv6:
+		na.IP = net.IP(addr).To16()
+	case NetworkTorV2:
+		na.IP = net.IP(append(append([]byte(nil), onionCatPrefix...),
+			addr...))
+	case NetworkTorV3:
+		*na = *NewNetAddressTorV3(addr, na.Port, na.Services)
+		na.Timestamp = time.Unix(timestamp, 0)
+	}
+	return nil
+}
+
 // NewNetAddressIPPort returns a new NetAddress using the provided IP, port, and
 // supported services with defaults for the remaining fields.
 func NewNetAddressIPPort(ip net.IP, port uint16, services uint64) *NetAddress {
@@ -0,0 +1,98 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package p2p
+
+import (
+	"bytes"
+	"encoding/base32"
+	"errors"
+	"net"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	// TorV3KeySize is the size of the ed25519 public key of a Tor v3 onion
+	// service.
+	TorV3KeySize = 32
+
+	// torV3Version is the version byte of Tor v3 onion addresses.
+	torV3Version = 0x03
+
+	// torV3HostLen is the length of a Tor v3 onion host, 56 char base32 and
+	// the ".onion" suffix.
+	torV3HostLen = 62
+
+	// onionSuffix is the top level domain of Tor onion services.
+	onionSuffix = ".onion"
+)
+
+// onionCatPrefix is the prefix of the OnionCat IPv6 address block
+// fd87:d87e:eb43::/48 used to support Tor.
+var onionCatPrefix = []byte{0xfd, 0x87, 0xd8, 0x7e, 0xeb, 0x43}
+
+// onionEncoding is the base32 encoding used by onion addresses.
+var onionEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// IsOnionHost returns whether the host is a Tor onion service name.
+func IsOnionHost(host string) bool {
+	return strings.HasSuffix(strings.ToLower(host), onionSuffix)
+}
+
+// torV3Checksum returns the checksum of the onion address of the public key.
+func torV3Checksum(pubKey []byte) []byte {
+	h := sha3.New256()
+	h.Write([]byte(".onion checksum"))
+	h.Write(pubKey)
+	h.Write([]byte{torV3Version})
+	return h.Sum(nil)[:2]
+}
+
+// EncodeTorV3 returns the Tor v3 onion host of the onion service public key.
+func EncodeTorV3(pubKey []byte) string {
+	buf := make([]byte, 0, TorV3KeySize+3)
+	buf = append(buf, pubKey...)
+	buf = append(buf, torV3Checksum(pubKey)...)
+	buf = append(buf, torV3Version)
+	return strings.ToLower(onionEncoding.EncodeToString(buf)) + onionSuffix
+}
+
+// DecodeTorV3 returns the onion service public key of the Tor v3 onion host,
+// the checksum and version of the address are verified.
+func DecodeTorV3(host string) ([]byte, error) {
+	if len(host) != torV3HostLen || !IsOnionHost(host) {
+		return nil, errors.New("invalid Tor v3 onion address length")
+	}
+	data, err := onionEncoding.DecodeString(
+		strings.ToUpper(host[:torV3HostLen-len(onionSuffix)]))
+	if err != nil {
+		return nil, err
+	}
+	pubKey := data[:TorV3KeySize]
+	if data[TorV3KeySize+2] != torV3Version {
+		return nil, errors.New("invalid Tor v3 onion address version")
+	}
+	if !bytes.Equal(data[TorV3KeySize:TorV3KeySize+2],
+		torV3Checksum(pubKey)) {
+		return nil, errors.New("invalid Tor v3 onion address checksum")
+	}
+	return pubKey, nil
+}
+
+// NewNetAddressTorV3 returns a new NetAddress of the Tor v3 onion service
+// using the provided public key, port, and supported services.  The IP of
+// the address is an OnionCat address derived from the public key, so it is
+// keyed and grouped like the other Tor addresses.
+func NewNetAddressTorV3(pubKey []byte, port uint16, services uint64) *NetAddress {
+	ip := make(net.IP, 0, net.IPv6len)
+	ip = append(ip, onionCatPrefix...)
+	ip = append(ip, pubKey[:net.IPv6len-len(onionCatPrefix)]...)
+
+	na := NewNetAddressIPPort(ip, port, services)
+	na.TorV3 = append([]byte(nil), pubKey...)
+	return na
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package p2p
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/common"
+)
+
+const testOnionV3 = "2gzyxa5ihm7nsggfxnu52rck2vv4rvmdlkiu3zzui5du4xyclen53wid.onion"
+
+func TestTorV3(t *testing.T) {
+	pubKey, err := DecodeTorV3(testOnionV3)
+	if err != nil {
+		t.Fatalf("DecodeTorV3: unexpected error %v", err)
+	}
+	if host := EncodeTorV3(pubKey); host != testOnionV3 {
+		t.Errorf("EncodeTorV3: got %s, want %s", host, testOnionV3)
+	}
+
+	// The checksum is verified.
+	invalid := "3" + testOnionV3[1:]
+	if _, err := DecodeTorV3(invalid); err == nil {
+		t.Errorf("DecodeTorV3(%s): expected error", invalid)
+	}
+	for _, host := range []string{"", "expyuzz4wqqyqhjn.onion",
+		testOnionV3[:56] + ".com"} {
+		if _, err := DecodeTorV3(host); err == nil {
+			t.Errorf("DecodeTorV3(%s): expected error", host)
+		}
+	}
+
+	na := NewNetAddressTorV3(pubKey, 20338, 1)
+	if na.String() != testOnionV3+":20338" {
+		t.Errorf("String: got %s", na)
+	}
+	if !bytes.HasPrefix(na.IP, onionCatPrefix) {
+		t.Errorf("IP %s is not an OnionCat address", na.IP)
+	}
+}
+
+func TestNetAddress_SerializeV2(t *testing.T) {
+	pubKey, _ := DecodeTorV3(testOnionV3)
+	timestamp := time.Unix(time.Now().Unix(), 0)
+	tests := []*NetAddress{
+		NewNetAddressTimestamp(timestamp, 1, net.ParseIP("12.1.2.3"), 20338),
+		NewNetAddressTimestamp(timestamp, 3, net.ParseIP("2602:100::1"), 20338),
+		NewNetAddressTimestamp(timestamp, 0,
+			net.ParseIP("fd87:d87e:eb43:1234::5678"), 20338),
+		NewNetAddressTorV3(pubKey, 20338, 5),
+	}
+	tests[3].Timestamp = timestamp
+
+	for _, na := range tests {
+		var buf bytes.Buffer
+		if err := na.SerializeV2(&buf); err != nil {
+			t.Fatalf("SerializeV2 %s: %v", na, err)
+		}
+		var got NetAddress
+		if err := got.DeserializeV2(&buf); err != nil {
+			t.Fatalf("DeserializeV2 %s: %v", na, err)
+		}
+		if got.String() != na.String() || !got.IP.Equal(na.IP) ||
+			got.Services != na.Services || !got.Timestamp.Equal(timestamp) {
+			t.Errorf("DeserializeV2: got %+v, want %+v", got, na)
+		}
+	}
+
+	// Addresses of unknown networks are read completely.
+	var buf bytes.Buffer
+	common.WriteElements(&buf, timestamp.Unix(), uint64(1), uint8(0x06))
+	common.WriteVarBytes(&buf, make([]byte, 32))
+	common.WriteUint16(&buf, 20338)
+	tests[0].SerializeV2(&buf)
+
+	var na NetAddress
+	if err := na.DeserializeV2(&buf); err != ErrUnknownNetwork {
+		t.Errorf("DeserializeV2: got error %v, want %v", err,
+			ErrUnknownNetwork)
+	}
+	if err := na.DeserializeV2(&buf); err != nil || na.String() != "12.1.2.3:20338" {
+		t.Errorf("DeserializeV2: got %s, %v", na, err)
+	}
+}
@@ -74,6 +74,9 @@ func messageSummary(message p2p.Message) string {
 	case *msg.Addr:
 		return fmt.Sprintf("%d addr", len(message.AddrList))
 
+	case *msg.AddrV2:
+		return fmt.Sprintf("%d addr", len(message.AddrList))
+
 	case *msg.Ping:
 		// No summary - perhaps add nonce.
 
@@ -97,6 +97,9 @@ type StatsSnap struct {
 	LastPingTime   time.Time
 	LastPingMicros int64
 	NodeVersion    string
+	BytesSent      uint64
+	BytesRecv      uint64
+	Encrypted      bool
 }
 
 // MessageFunc is a message handler in peer's configuration
@@ -159,6 +162,10 @@ func newNetAddress(addr net.Addr, services uint64) (*p2p.NetAddress, error) {
 type HostToNetAddrFunc func(host string, port uint16, services uint64) (*p2p.NetAddress, error)
 
 type Peer struct {
+	// stats counts the bytes of conn, it is the first field to keep the
+	// counters 64-bit aligned for atomic access.
+	stats p2p.StatsConn
+
 	// The following variables must only be used atomically.
 	lastRecv   int64
 	lastSend   int64
@@ -167,6 +174,10 @@ type Peer struct {
 
 	conn net.Conn
 
+	// crypt is the transport of conn, it starts encryption after the
+	// encryption is negotiated.
+	crypt *p2p.EncryptedConn
+
 	// These fields are set at creation time and never modified, so they are
 	// safe to read from concurrently without a mutex.
 	addr         string
@@ -262,6 +273,8 @@ func (p *Peer) StatsSnapshot() *StatsSnap {
 		Services:       services,
 		LastSend:       p.LastSend(),
 		LastRecv:       p.LastRecv(),
+		BytesSent:      p.BytesSent(),
+		BytesRecv:      p.BytesReceived(),
 		ConnTime:       p.timeConnected,
 		TimeOffset:     p.timeOffset,
 		Version:        protocolVersion,
@@ -271,6 +284,7 @@ func (p *Peer) StatsSnapshot() *StatsSnap {
 		LastPingMicros: p.lastPingMicros,
 		LastPingTime:   p.lastPingTime,
 		NodeVersion:    nodeVersion,
+		Encrypted:      p.Encrypted(),
 	}
 
 	p.statsMtx.RUnlock()
@@ -353,6 +367,13 @@ func (p *Peer) Services() uint64 {
 	return services
 }
 
+// Encrypted returns whether the connection to the remote peer is encrypted.
+//
+// This function is safe for concurrent access.
+func (p *Peer) Encrypted() bool {
+	return p.crypt != nil && p.crypt.Encrypted()
+}
+
 // LastPingTime returns the last ping time of the remote peer.
 //
 // This function is safe for concurrent access.
@@ -435,6 +456,20 @@ func (p *Peer) LastRecv() time.Time {
 	return time.Unix(atomic.LoadInt64(&p.lastRecv), 0)
 }
 
+// BytesSent returns the total number of bytes sent by the peer.
+//
+// This function is safe for concurrent access.
+func (p *Peer) BytesSent() uint64 {
+	return p.stats.BytesWritten()
+}
+
+// BytesReceived returns the total number of bytes received by the peer.
+//
+// This function is safe for concurrent access.
+func (p *Peer) BytesReceived() uint64 {
+	return p.stats.BytesRead()
+}
+
 // LocalAddr returns the local address of the connection.
 //
 // This function is safe fo concurrent access.
@@ -507,6 +542,24 @@ func (p *Peer) PushAddrMsg(addresses []*p2p.NetAddress) []*p2p.NetAddress {
 		return nil
 	}
 
+	// Onion v3 addresses can only be sent in the addrv2 message, so they are
+	// filtered if the peer does not support it.
+	addrV2 := pact.ServiceFlag(p.Services())&pact.SFNodeAddrV2 ==
+		pact.SFNodeAddrV2
+	if !addrV2 {
+		legacy := make([]*p2p.NetAddress, 0, addressCount)
+		for _, na := range addresses {
+			if na.TorV3 == nil {
+				legacy = append(legacy, na)
+			}
+		}
+		addresses = legacy
+		addressCount = len(addresses)
+		if addressCount == 0 {
+			return nil
+		}
+	}
+
 	addr := msg.NewAddr(addresses)
 
 	// Randomize the addresses sent if there are more than the maximum allowed.
@@ -521,7 +574,11 @@ func (p *Peer) PushAddrMsg(addresses []*p2p.NetAddress) []*p2p.NetAddress {
 		addr.AddrList = addr.AddrList[:msg.MaxAddrPerMsg]
 	}
 
-	p.QueueMessage(addr, nil)
+	if addrV2 {
+		p.QueueMessage(&msg.AddrV2{Addr: *addr}, nil)
+	} else {
+		p.QueueMessage(addr, nil)
+	}
 	return addr.AddrList
 }
 
@@ -612,12 +669,18 @@ func (p *Peer) createMessage(hdr p2p.Header, r net.Conn) (p2p.Message, error) {
 	case p2p.CmdVerAck:
 		message = &msg.VerAck{}
 
+	case p2p.CmdEncInit:
+		message = &msg.EncInit{}
+
 	case p2p.CmdGetAddr:
 		message = &msg.GetAddr{}
 
 	case p2p.CmdAddr:
 		message = &msg.Addr{}
 
+	case p2p.CmdAddrV2:
+		message = &msg.AddrV2{}
+
 	case p2p.CmdPing:
 		message = &msg.Ping{}
 
@@ -1140,6 +1203,51 @@ func (p *Peer) writeLocalVersionMsg() error {
 	return p.writeMessage(localVerMsg)
 }
 
+// negotiateEncryption exchanges the ephemeral keys and starts encrypting the
+// connection if both sides advertised the SFNodeEncryption service, otherwise
+// the connection stays plaintext for the legacy peers.
+func (p *Peer) negotiateEncryption() error {
+	if p.cfg.Services&uint64(pact.SFNodeEncryption) == 0 ||
+		p.Services()&uint64(pact.SFNodeEncryption) == 0 {
+		return nil
+	}
+
+	key, err := p2p.NewEphemeralKey()
+	if err != nil {
+		return err
+	}
+
+	// The outbound peer sends its key first, then the inbound peer replies.
+	if !p.inbound {
+		if err := p.writeMessage(msg.NewEncInit(key.Public)); err != nil {
+			return err
+		}
+	}
+
+	message, err := p.readMessage()
+	if err != nil {
+		return err
+	}
+	encInit, ok := message.(*msg.EncInit)
+	if !ok {
+		return fmt.Errorf("unexpected message %s, expecting encinit",
+			message.CMD())
+	}
+
+	if p.inbound {
+		if err := p.writeMessage(msg.NewEncInit(key.Public)); err != nil {
+			return err
+		}
+	}
+
+	session, err := p2p.NewSession(key, encInit.PubKey, p.cfg.Magic,
+		!p.inbound)
+	if err != nil {
+		return err
+	}
+	return p.crypt.Encrypt(session)
+}
+
 // negotiateInboundProtocol waits to receive a version message from the peer
 // then sends our version message. If the events do not occur in that order then
 // it returns an error.
@@ -1148,7 +1256,11 @@ func (p *Peer) negotiateInboundProtocol() error {
 		return err
 	}
 
-	return p.writeLocalVersionMsg()
+	if err := p.writeLocalVersionMsg(); err != nil {
+		return err
+	}
+
+	return p.negotiateEncryption()
 }
 
 // negotiateOutboundProtocol sends our version message then waits to receive a
@@ -1159,7 +1271,11 @@ func (p *Peer) negotiateOutboundProtocol() error {
 		return err
 	}
 
-	return p.readRemoteVersionMsg()
+	if err := p.readRemoteVersionMsg(); err != nil {
+		return err
+	}
+
+	return p.negotiateEncryption()
 }
 
 // start begins processing input and output messages.
@@ -1205,7 +1321,9 @@ func (p *Peer) AssociateConnection(conn net.Conn) {
 		return
 	}
 
-	p.conn = conn
+	p.stats.Conn = conn
+	p.crypt = p2p.NewEncryptedConn(&p.stats)
+	p.conn = p.crypt
 	p.timeConnected = time.Now()
 	go func() {
 		if err := p.start(); err != nil {
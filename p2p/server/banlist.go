@@ -0,0 +1,191 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// banListFile is the file name to save the banned subnets in the data
+// directory.
+const banListFile = "banlist.json"
+
+// BanInfo represents a banned subnet.
+type BanInfo struct {
+	// Subnet is the banned subnet in CIDR notation, a single IP address is
+	// a subnet with all mask bits set.
+	Subnet string `json:"subnet"`
+
+	// Created is the time the subnet was banned.
+	Created time.Time `json:"created"`
+
+	// Until is the time the ban ends.
+	Until time.Time `json:"until"`
+}
+
+// ParseSubnet parses an IP address or a subnet in CIDR notation.
+func ParseSubnet(subnet string) (*net.IPNet, error) {
+	if ip := net.ParseIP(subnet); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, ipNet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP address or subnet %s", subnet)
+	}
+	return ipNet, nil
+}
+
+// banList holds the banned subnets and saves them to file on changes.  It is
+// only accessed from the peerHandler goroutine.
+type banList struct {
+	path    string
+	entries map[string]*BanInfo
+}
+
+// newBanList returns a ban list loaded from the file in the data directory,
+// or an empty ban list if the file does not exist.
+func newBanList(dataDir string) *banList {
+	l := &banList{entries: make(map[string]*BanInfo)}
+	if dataDir == "" {
+		return l
+	}
+	l.path = filepath.Join(dataDir, banListFile)
+	if err := l.load(); err != nil {
+		log.Warnf("Load ban list %s failed, %s", l.path, err)
+	}
+	return l
+}
+
+// ban bans the subnet until the given time, and replaces the existing ban of
+// the subnet.
+func (l *banList) ban(subnet *net.IPNet, until time.Time) {
+	l.entries[subnet.String()] = &BanInfo{
+		Subnet:  subnet.String(),
+		Created: time.Now(),
+		Until:   until,
+	}
+	l.save()
+}
+
+// unban removes the ban of the subnet.
+func (l *banList) unban(subnet *net.IPNet) error {
+	if _, ok := l.entries[subnet.String()]; !ok {
+		return errors.New("subnet is not banned")
+	}
+	delete(l.entries, subnet.String())
+	l.save()
+	return nil
+}
+
+// clear removes all bans.
+func (l *banList) clear() {
+	l.entries = make(map[string]*BanInfo)
+	l.save()
+}
+
+// isBanned returns the end time of the ban if the IP address is within a
+// banned subnet.  Expired bans are removed.
+func (l *banList) isBanned(ip net.IP) (time.Time, bool) {
+	l.sweep()
+	var until time.Time
+	var banned bool
+	for _, e := range l.entries {
+		_, subnet, err := net.ParseCIDR(e.Subnet)
+		if err != nil || !subnet.Contains(ip) {
+			continue
+		}
+		if !banned || e.Until.After(until) {
+			until = e.Until
+		}
+		banned = true
+	}
+	return until, banned
+}
+
+// list returns the bans sorted by subnet.  Expired bans are removed.
+func (l *banList) list() []BanInfo {
+	l.sweep()
+	return l.sorted()
+}
+
+func (l *banList) sorted() []BanInfo {
+	bans := make([]BanInfo, 0, len(l.entries))
+	for _, e := range l.entries {
+		bans = append(bans, *e)
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].Subnet < bans[j].Subnet
+	})
+	return bans
+}
+
+// sweep removes the expired bans.
+func (l *banList) sweep() {
+	now := time.Now()
+	changed := false
+	for k, e := range l.entries {
+		if !now.Before(e.Until) {
+			log.Infof("Subnet %s is no longer banned", e.Subnet)
+			delete(l.entries, k)
+			changed = true
+		}
+	}
+	if changed {
+		l.save()
+	}
+}
+
+func (l *banList) load() error {
+	file, err := os.Open(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	var bans []BanInfo
+	if err := json.NewDecoder(file).Decode(&bans); err != nil {
+		return err
+	}
+	for i := range bans {
+		subnet, err := ParseSubnet(bans[i].Subnet)
+		if err != nil {
+			return err
+		}
+		bans[i].Subnet = subnet.String()
+		l.entries[bans[i].Subnet] = &bans[i]
+	}
+	return nil
+}
+
+func (l *banList) save() {
+	if l.path == "" {
+		return
+	}
+	file, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		log.Errorf("Error opening file %s: %v", l.path, err)
+		return
+	}
+	defer file.Close()
+
+	if err := json.NewEncoder(file).Encode(l.sorted()); err != nil {
+		log.Errorf("Failed to encode file %s: %v", l.path, err)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseSubnet(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "10.0.0.1", want: "10.0.0.1/32"},
+		{in: "10.0.0.1/24", want: "10.0.0.0/24"},
+		{in: "::ffff:10.0.0.1", want: "10.0.0.1/32"},
+		{in: "2001:db8::1", want: "2001:db8::1/128"},
+		{in: "2001:db8::/32", want: "2001:db8::/32"},
+	}
+	for _, test := range tests {
+		subnet, err := ParseSubnet(test.in)
+		if err != nil {
+			t.Errorf("ParseSubnet(%s): unexpected error %v", test.in, err)
+			continue
+		}
+		if subnet.String() != test.want {
+			t.Errorf("ParseSubnet(%s): got %s, want %s", test.in,
+				subnet.String(), test.want)
+		}
+	}
+
+	for _, in := range []string{"", "10.0.0", "10.0.0.1/33", "host:20338"} {
+		if _, err := ParseSubnet(in); err == nil {
+			t.Errorf("ParseSubnet(%s): expected error", in)
+		}
+	}
+}
+
+// TestBanList ensures the bans match the addresses within the banned subnets,
+// expire in time and are restored from the file.
+func TestBanList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "banlist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mustParse := func(s string) *net.IPNet {
+		subnet, err := ParseSubnet(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return subnet
+	}
+	until := time.Now().Add(time.Hour).Round(time.Second)
+
+	l := newBanList(dir)
+	l.ban(mustParse("10.0.0.0/24"), until)
+	l.ban(mustParse("192.168.1.1"), until.Add(time.Hour))
+	l.ban(mustParse("172.16.0.1"), time.Now().Add(-time.Second))
+
+	tests := []struct {
+		ip     string
+		banned bool
+		until  time.Time
+	}{
+		{ip: "10.0.0.1", banned: true, until: until},
+		{ip: "10.0.1.1", banned: false},
+		{ip: "192.168.1.1", banned: true, until: until.Add(time.Hour)},
+		{ip: "192.168.1.2", banned: false},
+		{ip: "172.16.0.1", banned: false},
+	}
+	check := func(l *banList) {
+		for _, test := range tests {
+			end, banned := l.isBanned(net.ParseIP(test.ip))
+			if banned != test.banned {
+				t.Errorf("isBanned(%s): got %v, want %v", test.ip, banned,
+					test.banned)
+				continue
+			}
+			if banned && !end.Equal(test.until) {
+				t.Errorf("isBanned(%s): ban ends at %v, want %v", test.ip,
+					end, test.until)
+			}
+		}
+	}
+	check(l)
+
+	// the expired ban is removed
+	bans := l.list()
+	if len(bans) != 2 || bans[0].Subnet != "10.0.0.0/24" ||
+		bans[1].Subnet != "192.168.1.1/32" {
+		t.Fatalf("list: unexpected bans %v", bans)
+	}
+
+	// bans are restored from the file
+	check(newBanList(dir))
+
+	if err := l.unban(mustParse("10.0.0.0/24")); err != nil {
+		t.Errorf("unban: unexpected error %v", err)
+	}
+	if err := l.unban(mustParse("10.0.0.0/24")); err == nil {
+		t.Errorf("unban: expected error for subnet not banned")
+	}
+	if bans := newBanList(dir).list(); len(bans) != 1 {
+		t.Errorf("list: got %d bans after unban, want 1", len(bans))
+	}
+
+	l.clear()
+	if bans := newBanList(dir).list(); len(bans) != 0 {
+		t.Errorf("list: got %d bans after clear, want 0", len(bans))
+	}
+}
@@ -6,11 +6,15 @@
 package server
 
 import (
+	"errors"
+	"fmt"
 	"net"
 	"strconv"
 	"time"
 
 	"github.com/elastos/Elastos.ELA/p2p"
+	"github.com/elastos/Elastos.ELA/p2p/addrmgr"
+	"github.com/elastos/Elastos.ELA/p2p/connmgr"
 )
 
 const (
@@ -111,6 +115,27 @@ type Config struct {
 
 	// NodeVersion is the version of node
 	NodeVersion string
+
+	// Proxy is the address of a SOCKS5 proxy to make outbound connections
+	// through, such as 127.0.0.1:9050 of a Tor client.  Host names are
+	// resolved through the proxy too.
+	Proxy string
+
+	// ProxyUser and ProxyPass authenticate to the proxies.
+	ProxyUser string
+	ProxyPass string
+
+	// OnionProxy is the address of a SOCKS5 proxy to connect to onion
+	// addresses through, the Proxy is used if it is empty.
+	OnionProxy string
+
+	// TorIsolation authenticates to the proxies with random credentials for
+	// each connection, so Tor uses a separate circuit for each peer.
+	TorIsolation bool
+
+	// OnlyNet restricts the automatic outbound connections to the networks,
+	// which are ipv4, ipv6 or onion.  All networks are allowed if empty.
+	OnlyNet []string
 }
 
 func (cfg *Config) normalize() {
@@ -151,8 +176,79 @@ func (cfg *Config) inWhitelist(addr net.Addr) bool {
 	return false
 }
 
-func dialTimeout(addr net.Addr) (net.Conn, error) {
-	return net.DialTimeout(addr.Network(), addr.String(), defaultConnectTimeout)
+// validate checks the proxies and networks of the configuration.
+func (cfg *Config) validate() error {
+	for _, name := range cfg.OnlyNet {
+		network, err := addrmgr.ParseNetwork(name)
+		if err != nil {
+			return err
+		}
+		if network == addrmgr.Onion && cfg.Proxy == "" &&
+			cfg.OnionProxy == "" {
+			return errors.New("onion network requires a proxy")
+		}
+	}
+	return nil
+}
+
+// isReachable returns whether automatic outbound connections can be made to
+// the address.  Onion addresses are only reachable through a proxy.
+func (cfg *Config) isReachable(na *p2p.NetAddress) bool {
+	network := addrmgr.GetNetwork(na)
+	if network == addrmgr.Onion && cfg.Proxy == "" && cfg.OnionProxy == "" {
+		return false
+	}
+	if len(cfg.OnlyNet) == 0 {
+		return true
+	}
+	for _, name := range cfg.OnlyNet {
+		if n, err := addrmgr.ParseNetwork(name); err == nil && n == network {
+			return true
+		}
+	}
+	return false
+}
+
+// proxy returns the proxy to connect to the host through, or nil to connect
+// directly.
+func (cfg *Config) proxy(host string) *connmgr.Proxy {
+	addr := cfg.Proxy
+	if p2p.IsOnionHost(host) && cfg.OnionProxy != "" {
+		addr = cfg.OnionProxy
+	}
+	if addr == "" {
+		return nil
+	}
+	return &connmgr.Proxy{
+		Addr:         addr,
+		Username:     cfg.ProxyUser,
+		Password:     cfg.ProxyPass,
+		TorIsolation: cfg.TorIsolation,
+	}
+}
+
+// dialTimeout connects to the address through the proxy if configured.
+func (cfg *Config) dialTimeout(addr string, timeout time.Duration) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if proxy := cfg.proxy(host); proxy != nil {
+		return proxy.DialTimeout("tcp", addr, timeout)
+	}
+	if p2p.IsOnionHost(host) {
+		return nil, fmt.Errorf("no proxy to connect to %s", addr)
+	}
+	return net.DialTimeout("tcp", addr, timeout)
+}
+
+// lookup resolves the host through Tor if a proxy is configured, so the DNS
+// queries do not leak the node IP.
+func (cfg *Config) lookup(host string) ([]net.IP, error) {
+	if cfg.Proxy != "" {
+		return connmgr.TorLookupIP(host, cfg.Proxy)
+	}
+	return net.LookupIP(host)
 }
 
 // removeDuplicateAddresses returns a new slice with all duplicate entries in
@@ -0,0 +1,63 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/p2p"
+)
+
+func TestConfig_IsReachable(t *testing.T) {
+	ipv4 := p2p.NewNetAddressIPPort(net.ParseIP("12.1.2.3"), 20338, 1)
+	ipv6 := p2p.NewNetAddressIPPort(net.ParseIP("2602:100::1"), 20338, 1)
+	onion := p2p.NewNetAddressTorV3(make([]byte, p2p.TorV3KeySize), 20338, 1)
+
+	tests := []struct {
+		cfg       Config
+		reachable [3]bool
+	}{
+		{cfg: Config{}, reachable: [3]bool{true, true, false}},
+		{cfg: Config{OnionProxy: "127.0.0.1:9050"},
+			reachable: [3]bool{true, true, true}},
+		{cfg: Config{Proxy: "127.0.0.1:9050", OnlyNet: []string{"onion"}},
+			reachable: [3]bool{false, false, true}},
+		{cfg: Config{OnlyNet: []string{"IPv4", "ipv6"}},
+			reachable: [3]bool{true, true, false}},
+	}
+	for i, test := range tests {
+		if err := test.cfg.validate(); err != nil {
+			t.Errorf("#%d validate: unexpected error %v", i, err)
+		}
+		for j, na := range []*p2p.NetAddress{ipv4, ipv6, onion} {
+			if r := test.cfg.isReachable(na); r != test.reachable[j] {
+				t.Errorf("#%d isReachable(%s): got %v, want %v", i, na, r,
+					test.reachable[j])
+			}
+		}
+	}
+
+	for _, cfg := range []Config{
+		{OnlyNet: []string{"i2p"}},
+		{OnlyNet: []string{"onion"}},
+	} {
+		if err := cfg.validate(); err == nil {
+			t.Errorf("validate %v: expected error", cfg.OnlyNet)
+		}
+	}
+}
+
+func TestConfig_AddrStringToNetAddr(t *testing.T) {
+	const onion = "2gzyxa5ihm7nsggfxnu52rck2vv4rvmdlkiu3zzui5du4xyclen53wid.onion:20338"
+	if _, err := (&Config{}).addrStringToNetAddr(onion); err == nil {
+		t.Errorf("addrStringToNetAddr: expected error without proxy")
+	}
+	addr, err := (&Config{Proxy: "127.0.0.1:9050"}).addrStringToNetAddr(onion)
+	if err != nil || addr.Network() != "onion" || addr.String() != onion {
+		t.Errorf("addrStringToNetAddr: got %v, %v", addr, err)
+	}
+}
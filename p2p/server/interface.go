@@ -82,6 +82,26 @@ type IServer interface {
 	// error.
 	DisconnectByAddr(addr string) error
 
+	// Ban bans the IP address or subnet in CIDR notation for the duration,
+	// the configured ban duration is used if duration is zero.  Connected
+	// peers within the subnet are disconnected.
+	Ban(subnet string, duration time.Duration) error
+
+	// BanUntil bans the IP address or subnet in CIDR notation until the
+	// given time.  Connected peers within the subnet are disconnected.
+	BanUntil(subnet string, until time.Time) error
+
+	// Unban removes the ban of the IP address or subnet in CIDR notation.
+	// Attempting to unban a subnet not banned will return an error.
+	Unban(subnet string) error
+
+	// BannedList returns the banned subnets, the bans are saved in the data
+	// directory and restored on restart.
+	BannedList() []BanInfo
+
+	// ClearBanned removes all bans.
+	ClearBanned()
+
 	// ConnectedCount returns the number of currently connected peers.
 	ConnectedCount() int32
 
@@ -57,6 +57,11 @@ func (s *seed) GetAddress() (net.Addr, error) {
 		// in the same group so that we are not connecting
 		// to the same network segment at the expense of
 		// others.
+		// Only connect to the networks reachable or allowed.
+		if !s.cfg.isReachable(addr.NetAddress()) {
+			continue
+		}
+
 		key := addrmgr.GroupKey(addr.NetAddress())
 		if s.outboundGroupCount(key) != 0 {
 			continue
@@ -73,7 +78,7 @@ func (s *seed) GetAddress() (net.Addr, error) {
 		}
 
 		addrString := addrmgr.NetAddressKey(addr.NetAddress())
-		return addrStringToNetAddr(addrString)
+		return s.cfg.addrStringToNetAddr(addrString)
 	}
 
 	// Trigger DNS seeding if their are no valid address.
@@ -104,6 +109,9 @@ func (s *seed) newConfig(addrChan chan []*p2p.NetAddress) *peer.Config {
 			case *msg.Addr:
 				addrChan <- m.AddrList
 
+			case *msg.AddrV2:
+				addrChan <- m.AddrList
+
 			}
 		},
 		NewVersionHeight: s.cfg.NewVersionHeight,
@@ -121,7 +129,7 @@ func (s *seed) discover(host string, addrChan chan []*p2p.NetAddress,
 	}
 
 	// Connect to the DNS host.
-	conn, err := net.Dial("tcp", host)
+	conn, err := s.cfg.dialTimeout(host, defaultConnectTimeout)
 	if err != nil {
 		log.Debugf("Can not connect to host %s, %s", host, err)
 		return
@@ -25,6 +25,7 @@ import (
 	"github.com/elastos/Elastos.ELA/p2p/connmgr"
 	"github.com/elastos/Elastos.ELA/p2p/msg"
 	"github.com/elastos/Elastos.ELA/p2p/peer"
+	"github.com/elastos/Elastos.ELA/utils/elalog"
 )
 
 const (
@@ -35,6 +36,9 @@ const (
 	// retries when connecting to persistent peers.  It is adjusted by the
 	// number of retries such that there is a retry backoff.
 	connectionRetryInterval = time.Second * 5
+
+	// proxyCheckTimeout is the timeout to check an address through the proxy.
+	proxyCheckTimeout = time.Second * 10
 )
 
 // simpleAddr implements the net.Addr interface with two struct fields
@@ -59,6 +63,28 @@ func (a simpleAddr) Network() string {
 // Ensure simpleAddr implements the net.Addr interface.
 var _ net.Addr = simpleAddr{}
 
+// onionAddr implements the net.Addr interface and represents a tor address.
+type onionAddr struct {
+	addr string
+}
+
+// String returns the onion address.
+//
+// This is part of the net.Addr interface.
+func (oa *onionAddr) String() string {
+	return oa.addr
+}
+
+// Network returns "onion".
+//
+// This is part of the net.Addr interface.
+func (oa *onionAddr) Network() string {
+	return "onion"
+}
+
+// Ensure onionAddr implements the net.Addr interface.
+var _ net.Addr = (*onionAddr)(nil)
+
 // newPeerMsg represent the new connected peer.
 type newPeerMsg *serverPeer
 
@@ -81,8 +107,12 @@ type peerState struct {
 	inboundPeers    map[uint64]*serverPeer
 	outboundPeers   map[uint64]*serverPeer
 	persistentPeers map[uint64]*serverPeer
-	banned          map[string]time.Time
+	banned          *banList
 	outboundGroups  map[string]int
+
+	// bytesSent and bytesReceived are the bytes of the peers done.
+	bytesSent     uint64
+	bytesReceived uint64
 }
 
 // Count returns the count of all known peers.
@@ -130,6 +160,7 @@ type server struct {
 	wg          sync.WaitGroup
 	quit        chan struct{}
 	nat         NAT
+	banList     *banList
 }
 
 // IPeer extends the peer to maintain state shared by the server.
@@ -341,16 +372,18 @@ func (sp *serverPeer) AddBanScore(persistent, transient uint32, reason string) {
 		// logged if the score is above the warn threshold.
 		score := sp.banScore.Int()
 		if score > warnThreshold {
-			log.Warnf("Misbehaving peer %s: %s -- ban score is %d, "+
-				"it was not increased this time", sp, reason, score)
+			log.WithFields(elalog.Peer(sp.String())).Warnf(
+				"Misbehaving peer %s: %s -- ban score is %d, "+
+					"it was not increased this time", sp, reason, score)
 		}
 		return
 	}
 	score := sp.banScore.Increase(persistent, transient)
 	if score > warnThreshold {
-		log.Warnf("Misbehaving peer %s: %s -- ban score increased to %d", sp, reason, score)
+		plog := log.WithFields(elalog.Peer(sp.String()))
+		plog.Warnf("Misbehaving peer %s: %s -- ban score increased to %d", sp, reason, score)
 		if score > cfg.BanThreshold {
-			log.Warnf("Misbehaving peer %s -- banning and disconnecting", sp)
+			plog.Warnf("Misbehaving peer %s -- banning and disconnecting", sp)
 			sp.server.BanPeer(sp)
 			sp.Disconnect()
 		}
@@ -380,7 +413,21 @@ func (s *server) checkAddr(addr string) error {
 		}
 	}
 
-	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	// Unreachable addresses can not be checked, and connecting through the
+	// proxy takes more time.
+	na, err := s.addrManager.DeserializeNetAddress(addr)
+	if err != nil {
+		return err
+	}
+	if !s.cfg.isReachable(na) {
+		return nil
+	}
+	timeout := time.Second
+	if s.cfg.Proxy != "" || addrmgr.GetNetwork(na) == addrmgr.Onion {
+		timeout = proxyCheckTimeout
+	}
+
+	conn, err := s.cfg.dialTimeout(addr, timeout)
 	if err != nil {
 		return err
 	}
@@ -393,12 +440,14 @@ func (s *server) checkAddr(addr string) error {
 		ver = pact.CRProposalVersion
 		s.cfg.ProtocolVersion = ver
 	}
-	// Version message.
+	// Version message, the connection is closed after the version exchange so
+	// do not advertise the encryption service.
 	versionMsg = msg.NewVersion(ver, s.cfg.DefaultPort,
-		s.cfg.Services, uint64(rand.Int63()), bestHeight, s.cfg.DisableRelayTx, nodeVersion)
+		s.cfg.Services&^uint64(pact.SFNodeEncryption), uint64(rand.Int63()),
+		bestHeight, s.cfg.DisableRelayTx, nodeVersion)
 
 	err = p2p.WriteMessage(
-		conn, s.cfg.MagicNumber, versionMsg, time.Second*2,
+		conn, s.cfg.MagicNumber, versionMsg, timeout*2,
 		func(m p2p.Message) (*types.DposBlock, bool) {
 			return nil, false
 		})
@@ -406,7 +455,7 @@ func (s *server) checkAddr(addr string) error {
 		return err
 	}
 	remoteMsg, err := p2p.ReadMessage(
-		conn, s.cfg.MagicNumber, time.Second*2, createMessage)
+		conn, s.cfg.MagicNumber, timeout*2, createMessage)
 	if err != nil {
 		return err
 	}
@@ -486,16 +535,13 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		sp.Disconnect()
 		return false
 	}
-	if banEnd, ok := state.banned[host]; ok {
-		if time.Now().Before(banEnd) {
+	if ip := net.ParseIP(host); ip != nil {
+		if banEnd, ok := state.banned.isBanned(ip); ok {
 			log.Debugf("Peer %s is banned for another %v - disconnecting",
 				host, time.Until(banEnd))
 			sp.Disconnect()
 			return false
 		}
-
-		log.Infof("Peer %s is no longer banned", host)
-		delete(state.banned, host)
 	}
 
 	// Limit max number of total peers.
@@ -550,6 +596,9 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 // handleDonePeerMsg deals with peers that have signalled they are done.  It is
 // invoked from the peerHandler goroutine.
 func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
+	state.bytesSent += sp.BytesSent()
+	state.bytesReceived += sp.BytesReceived()
+
 	var list map[uint64]*serverPeer
 	if sp.persistent {
 		list = state.persistentPeers
@@ -592,9 +641,14 @@ func (s *server) handleBanPeerMsg(state *peerState, sp *serverPeer) {
 		log.Debugf("can't split ban peer %s %v", sp.Addr(), err)
 		return
 	}
+	subnet, err := ParseSubnet(host)
+	if err != nil {
+		log.Debugf("can't ban peer %s %v", sp.Addr(), err)
+		return
+	}
 	direction := directionString(sp.Inbound())
 	log.Infof("Banned peer %s (%s) for %v", host, direction, s.cfg.BanDuration)
-	state.banned[host] = time.Now().Add(s.cfg.BanDuration)
+	state.banned.ban(subnet, time.Now().Add(s.cfg.BanDuration))
 }
 
 // handleBroadcastMsg deals with broadcasting messages to peers.  It is invoked
@@ -648,6 +702,25 @@ type removeNodeMsg struct {
 	reply chan error
 }
 
+type banMsg struct {
+	subnet *net.IPNet
+	until  time.Time
+	reply  chan error
+}
+
+type unbanMsg struct {
+	subnet *net.IPNet
+	reply  chan error
+}
+
+type getBannedMsg struct {
+	reply chan []BanInfo
+}
+
+type clearBannedMsg struct {
+	reply chan struct{}
+}
+
 // handleQuery is the central handler for all queries and commands from other
 // goroutines related to peer state.
 func (s *server) handleQuery(state *peerState, querymsg interface{}) {
@@ -671,6 +744,9 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		})
 		msg.reply <- peers
 
+	case getPeerStatsMsg:
+		msg.reply <- state.stats()
+
 	case connectNodeMsg:
 		// TODO: duplicate oneshots?
 		// Limit max number of total peers.
@@ -689,7 +765,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 			}
 		}
 
-		netAddr, err := addrStringToNetAddr(msg.addr)
+		netAddr, err := s.cfg.addrStringToNetAddr(msg.addr)
 		if err != nil {
 			msg.reply <- err
 			return
@@ -757,6 +833,43 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		}
 
 		msg.reply <- errors.New("peer not found")
+
+	case banMsg:
+		state.banned.ban(msg.subnet, msg.until)
+		log.Infof("Banned subnet %s until %v", msg.subnet, msg.until)
+
+		// Disconnect the connected peers within the banned subnet.
+		inSubnet := func(sp *serverPeer) bool {
+			host, _, err := net.SplitHostPort(sp.Addr())
+			if err != nil {
+				return false
+			}
+			ip := net.ParseIP(host)
+			return ip != nil && msg.subnet.Contains(ip)
+		}
+		outboundDone := func(sp *serverPeer) {
+			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+		}
+		for found := true; found; {
+			found = disconnectPeer(state.inboundPeers, inSubnet, nil)
+		}
+		for found := true; found; {
+			found = disconnectPeer(state.outboundPeers, inSubnet, outboundDone)
+		}
+		for found := true; found; {
+			found = disconnectPeer(state.persistentPeers, inSubnet, outboundDone)
+		}
+		msg.reply <- nil
+
+	case unbanMsg:
+		msg.reply <- state.banned.unban(msg.subnet)
+
+	case getBannedMsg:
+		msg.reply <- state.banned.list()
+
+	case clearBannedMsg:
+		state.banned.clear()
+		msg.reply <- struct{}{}
 	}
 }
 
@@ -822,6 +935,9 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 			case *msg.Addr:
 				sp.OnAddr(peer, m)
 
+			case *msg.AddrV2:
+				sp.OnAddr(peer, &m.Addr)
+
 			}
 		},
 		NewVersionHeight: sp.server.cfg.NewVersionHeight,
@@ -891,14 +1007,14 @@ func (s *server) peerHandler() {
 		inboundPeers:    make(map[uint64]*serverPeer),
 		persistentPeers: make(map[uint64]*serverPeer),
 		outboundPeers:   make(map[uint64]*serverPeer),
-		banned:          make(map[string]time.Time),
+		banned:          s.banList,
 		outboundGroups:  make(map[string]int),
 	}
 
 	// Connect permanent peers if there are.  Permanent peers will not added to
 	// AddrManager so they won't be relayed.
 	for _, addr := range s.cfg.PermanentPeers {
-		netAddr, err := addrStringToNetAddr(addr)
+		netAddr, err := s.cfg.addrStringToNetAddr(addr)
 		if err != nil {
 			continue
 		}
@@ -1002,6 +1118,9 @@ func (s *server) Start() {
 	s.wg.Add(1)
 	go s.peerHandler()
 
+	// Export the statistics of peers to metrics.
+	s.registerMetrics()
+
 	if s.nat != nil {
 		s.wg.Add(1)
 		go s.upnpUpdateThread()
@@ -1239,6 +1358,75 @@ func (s *server) DisconnectByAddr(addr string) error {
 	return <-replyChan
 }
 
+// Ban bans the IP address or subnet in CIDR notation for the duration, the
+// configured ban duration is used if duration is zero.  Connected peers
+// within the subnet are disconnected.
+//
+// This function is safe for concurrent access and is part of the
+// IServer interface implementation.
+func (s *server) Ban(subnet string, duration time.Duration) error {
+	ipNet, err := ParseSubnet(subnet)
+	if err != nil {
+		return err
+	}
+	if duration <= 0 {
+		duration = s.cfg.BanDuration
+	}
+	return s.BanUntil(ipNet.String(), time.Now().Add(duration))
+}
+
+// BanUntil bans the IP address or subnet in CIDR notation until the given
+// time.  Connected peers within the subnet are disconnected.
+//
+// This function is safe for concurrent access and is part of the
+// IServer interface implementation.
+func (s *server) BanUntil(subnet string, until time.Time) error {
+	ipNet, err := ParseSubnet(subnet)
+	if err != nil {
+		return err
+	}
+	if !until.After(time.Now()) {
+		return errors.New("ban end time has passed")
+	}
+	replyChan := make(chan error)
+	s.query <- banMsg{subnet: ipNet, until: until, reply: replyChan}
+	return <-replyChan
+}
+
+// Unban removes the ban of the IP address or subnet in CIDR notation.
+//
+// This function is safe for concurrent access and is part of the
+// IServer interface implementation.
+func (s *server) Unban(subnet string) error {
+	ipNet, err := ParseSubnet(subnet)
+	if err != nil {
+		return err
+	}
+	replyChan := make(chan error)
+	s.query <- unbanMsg{subnet: ipNet, reply: replyChan}
+	return <-replyChan
+}
+
+// BannedList returns the banned subnets.
+//
+// This function is safe for concurrent access and is part of the
+// IServer interface implementation.
+func (s *server) BannedList() []BanInfo {
+	replyChan := make(chan []BanInfo)
+	s.query <- getBannedMsg{reply: replyChan}
+	return <-replyChan
+}
+
+// ClearBanned removes all bans.
+//
+// This function is safe for concurrent access and is part of the
+// IServer interface implementation.
+func (s *server) ClearBanned() {
+	replyChan := make(chan struct{})
+	s.query <- clearBannedMsg{reply: replyChan}
+	<-replyChan
+}
+
 // ConnectedPeers returns an array consisting of all connected peers.
 //
 // This function is safe for concurrent access and is part of the
@@ -1279,6 +1467,9 @@ func (s *server) PersistentPeers() []IPeer {
 func newServer(origCfg *Config) (*server, error) {
 	cfg := *origCfg // Copy to avoid mutating caller.
 	cfg.normalize()
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
 
 	// Create data dir for addrManager to store peer addresses.
 	dataDir := defaultDataDir
@@ -1313,8 +1504,10 @@ func newServer(origCfg *Config) (*server, error) {
 		broadcast:   make(chan broadcastMsg, cfg.MaxPeers),
 		quit:        make(chan struct{}),
 		nat:         nat,
+		banList:     newBanList(dataDir),
 	}
 	s.addrManager.SetCheckAddr(s.checkAddr)
+	s.addrManager.SetLookup(s.cfg.lookup)
 
 	// Create the DNS seeds provider.
 	seeds := newSeed(&cfg, amgr, s.OutboundGroupCount)
@@ -1329,9 +1522,11 @@ func newServer(origCfg *Config) (*server, error) {
 		OnAccept:       s.inboundPeerConnected,
 		RetryDuration:  connectionRetryInterval,
 		TargetOutbound: uint32(targetOutbound),
-		Dial:           dialTimeout,
-		OnConnection:   s.outboundPeerConnected,
-		GetNewAddress:  seeds.GetAddress,
+		Dial: func(addr net.Addr) (net.Conn, error) {
+			return s.cfg.dialTimeout(addr.String(), defaultConnectTimeout)
+		},
+		OnConnection:  s.outboundPeerConnected,
+		GetNewAddress: seeds.GetAddress,
 	})
 	if err != nil {
 		return nil, err
@@ -1388,7 +1583,9 @@ func initListeners(amgr *addrmgr.AddrManager, cfg Config) ([]net.Listener, NAT,
 				log.Warnf("Skipping specified external IP: %v", err)
 			}
 		}
-	} else {
+	} else if cfg.Proxy == "" {
+		// Local addresses are not discovered or advertised when connecting
+		// through a proxy, which would reveal the node IP.
 		if cfg.Upnp {
 			var err error
 			nat, err = Discover()
@@ -1415,7 +1612,7 @@ func initListeners(amgr *addrmgr.AddrManager, cfg Config) ([]net.Listener, NAT,
 // a net.Addr which maps to the original address with any host names resolved
 // to IP addresses.  It also handles tor addresses properly by returning a
 // net.Addr that encapsulates the address.
-func addrStringToNetAddr(addr string) (net.Addr, error) {
+func (cfg *Config) addrStringToNetAddr(addr string) (net.Addr, error) {
 	host, strPort, err := net.SplitHostPort(addr)
 	if err != nil {
 		return nil, err
@@ -1434,8 +1631,18 @@ func addrStringToNetAddr(addr string) (net.Addr, error) {
 		}, nil
 	}
 
+	// Tor addresses cannot be resolved to an IP, so just return an onion
+	// address instead.
+	if p2p.IsOnionHost(host) {
+		if cfg.Proxy == "" && cfg.OnionProxy == "" {
+			return nil, errors.New("tor has been disabled")
+		}
+
+		return &onionAddr{addr: addr}, nil
+	}
+
 	// Attempt to look up an IP address associated with the parsed host.
-	ips, err := net.LookupIP(host)
+	ips, err := cfg.lookup(host)
 	if err != nil {
 		return nil, err
 	}
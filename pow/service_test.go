@@ -15,7 +15,7 @@ import (
 	"github.com/elastos/Elastos.ELA/blockchain"
 	"github.com/elastos/Elastos.ELA/common"
 	"github.com/elastos/Elastos.ELA/common/config"
-	"github.com/elastos/Elastos.ELA/common/log"
+	elaLog "github.com/elastos/Elastos.ELA/common/log"
 	"github.com/elastos/Elastos.ELA/core"
 	"github.com/elastos/Elastos.ELA/core/checkpoint"
 	"github.com/elastos/Elastos.ELA/core/contract"
@@ -37,7 +37,7 @@ var arbitrators []state.ArbiterMember
 var originLedger *blockchain.Ledger
 
 func TestService_Init(t *testing.T) {
-	log.NewDefault(test.NodeLogPath, 0, 0, 0)
+	elaLog.NewDefault(test.NodeLogPath, 0, 0, 0)
 
 	// Initialize functions
 	functions.GetTransactionByTxType = transaction2.GetTransaction
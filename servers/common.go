@@ -172,6 +172,34 @@ type PeerInfo struct {
 	NodeVersion    string `json:"nodeversion"`
 }
 
+type PeerDetailInfo struct {
+	ID             uint64  `json:"id"`
+	Addr           string  `json:"addr"`
+	Services       string  `json:"services"`
+	RelayTx        bool    `json:"relaytx"`
+	LastSend       int64   `json:"lastsend"`
+	LastRecv       int64   `json:"lastrecv"`
+	BytesSent      uint64  `json:"bytessent"`
+	BytesRecv      uint64  `json:"bytesrecv"`
+	ConnTime       int64   `json:"conntime"`
+	TimeOffset     int64   `json:"timeoffset"`
+	PingTime       float64 `json:"pingtime"`
+	Version        uint32  `json:"version"`
+	NodeVersion    string  `json:"nodeversion"`
+	Inbound        bool    `json:"inbound"`
+	Persistent     bool    `json:"persistent"`
+	StartingHeight uint32  `json:"startingheight"`
+	SyncHeight     uint32  `json:"syncheight"`
+	BanScore       uint32  `json:"banscore"`
+	Encrypted      bool    `json:"encrypted"`
+}
+
+type BannedInfo struct {
+	Address     string `json:"address"`
+	BanCreated  int64  `json:"bancreated"`
+	BannedUntil int64  `json:"banneduntil"`
+}
+
 type ArbitratorGroupInfo struct {
 	OnDutyArbitratorIndex int      `json:"ondutyarbitratorindex"`
 	Arbitrators           []string `json:"arbitrators"`
@@ -527,6 +555,61 @@ type UTXOInfo struct {
 	Confirmations uint32 `json:"confirmations"`
 }
 
+type AddressHistoryInfo struct {
+	TxID          string `json:"txid"`
+	Height        uint32 `json:"height"`
+	Position      uint32 `json:"position"`
+	Confirmations uint32 `json:"confirmations"`
+}
+
+type AddressDeltaInfo struct {
+	TxID     string `json:"txid"`
+	Height   uint32 `json:"height"`
+	Position uint32 `json:"position"`
+	Received string `json:"received"`
+	Sent     string `json:"sent"`
+	Delta    string `json:"delta"`
+}
+
+type AddressHistoryPage struct {
+	Address    string        `json:"address"`
+	Items      []interface{} `json:"items"`
+	NextCursor string        `json:"nextcursor"`
+}
+
+type SmartFeeInfo struct {
+	FeeRate     int64   `json:"feerate"`
+	Blocks      uint32  `json:"blocks"`
+	Confidence  float64 `json:"confidence"`
+	SuccessRate float64 `json:"successrate"`
+	Samples     uint32  `json:"samples"`
+	Estimated   bool    `json:"estimated"`
+}
+
+type FeeHistogramInfo struct {
+	MinFeeRate int64  `json:"minfeerate"`
+	MaxFeeRate int64  `json:"maxfeerate"`
+	Count      uint32 `json:"count"`
+	Size       uint64 `json:"size"`
+}
+
+type MempoolInfo struct {
+	Size         uint32             `json:"size"`
+	Bytes        uint64             `json:"bytes"`
+	MaxBytes     uint64             `json:"maxbytes"`
+	FeeHistogram []FeeHistogramInfo `json:"feehistogram"`
+	TxTypes      map[string]uint32  `json:"txtypes"`
+}
+
+type SaveMempoolInfo struct {
+	Saved int `json:"saved"`
+}
+
+type LoadMempoolInfo struct {
+	Accepted int `json:"accepted"`
+	Dropped  int `json:"dropped"`
+}
+
 type SidechainIllegalDataInfo struct {
 	IllegalType         uint8    `json:"illegaltype"`
 	Height              uint32   `json:"height"`
@@ -45,6 +45,7 @@ const (
 	UnknownAsset         ServerErrCode = 44002
 	UnknownBlock         ServerErrCode = 44003
 	UnknownConfirm       ServerErrCode = 44004
+	PrunedBlock          ServerErrCode = 44005
 	InternalError        ServerErrCode = 45002
 )
 
@@ -63,6 +64,7 @@ var ErrMap = map[ServerErrCode]string{
 	UnknownAsset:                "Unknown asset",
 	UnknownBlock:                "Unknown Block",
 	UnknownConfirm:              "Unknown Confirm",
+	PrunedBlock:                 "Block pruned",
 	InternalError:               "Internal error",
 	ErrUTXOLocked:               "Error utxo locked",
 	ErrSideChainPowConsensus:    "Error sidechain pow consensus",
@@ -40,6 +40,7 @@ func TestErrCode_Message(t *testing.T) {
 		UnknownTransaction,
 		UnknownAsset,
 		UnknownBlock,
+		PrunedBlock,
 		InternalError,
 	}
 	for _, errorCode := range errorCodeArray {
@@ -59,6 +59,12 @@ func StartRPCServer() {
 	mainMux["getrawtransaction"] = GetRawTransaction
 	mainMux["getneighbors"] = GetNeighbors
 	mainMux["getnodestate"] = GetNodeState
+	mainMux["getpeerinfo"] = GetPeerInfo
+	mainMux["addnode"] = AddNode
+	mainMux["disconnectnode"] = DisconnectNode
+	mainMux["setban"] = SetBan
+	mainMux["listbanned"] = ListBanned
+	mainMux["clearbanned"] = ClearBanned
 	mainMux["sendrawtransaction"] = SendRawTransaction
 	mainMux["getarbitratorgroupbyheight"] = GetArbitratorGroupByHeight
 	mainMux["getbestblockhash"] = GetBestBlockHash
@@ -76,9 +82,16 @@ func StartRPCServer() {
 	mainMux["getamountbyinputs"] = GetAmountByInputs
 	mainMux["getutxosbyamount"] = GetUTXOsByAmount
 	mainMux["listunspent"] = ListUnspent
+	mainMux["getaddresshistory"] = GetAddressHistory
+	mainMux["getaddressdeltas"] = GetAddressDeltas
 	mainMux["createrawtransaction"] = CreateRawTransaction
 	mainMux["decoderawtransaction"] = DecodeRawTransaction
 	mainMux["signrawtransactionwithkey"] = SignRawTransactionWithKey
+	mainMux["createpsbt"] = CreatePSBT
+	mainMux["decodepsbt"] = DecodePSBT
+	mainMux["signpsbtwithkey"] = SignPSBTWithKey
+	mainMux["combinepsbt"] = CombinePSBT
+	mainMux["finalizepsbt"] = FinalizePSBT
 	// aux interfaces
 	mainMux["help"] = AuxHelp
 	mainMux["submitauxblock"] = SubmitAuxBlock
@@ -94,6 +107,7 @@ func StartRPCServer() {
 
 	mainMux["listcrproposalbasestate"] = ListCRProposalBaseState
 	mainMux["getcrproposalstate"] = GetCRProposalState
+	mainMux["getcrproposaltimeline"] = GetCRProposalTimeline
 	mainMux["getproposaldraftdata"] = GetProposalDraftData
 	mainMux["getsecretarygeneral"] = GetSecretaryGeneral
 	mainMux["getcrrelatedstage"] = GetCRRelatedStage
@@ -102,6 +116,9 @@ func StartRPCServer() {
 	// vote interfaces
 	mainMux["listproducers"] = ListProducers
 	mainMux["getproducerinfo"] = GetProducerInfo
+	mainMux["getproducerperformance"] = GetProducerPerformance
+	mainMux["listillegalevidence"] = ListIllegalEvidence
+	mainMux["getproducerpenalties"] = GetProducerPenalties
 
 	mainMux["producerstatus"] = ProducerStatus
 	mainMux["votestatus"] = VoteStatus
@@ -114,6 +131,9 @@ func StartRPCServer() {
 	mainMux["getsmallcrosstransfertxs"] = GetSmallCrossTransferTxs
 
 	mainMux["estimatesmartfee"] = EstimateSmartFee
+	mainMux["getmempoolinfo"] = GetMempoolInfo
+	mainMux["savemempool"] = SaveMempool
+	mainMux["loadmempool"] = LoadMempool
 	mainMux["getdepositcoin"] = GetDepositCoin
 	mainMux["getcrdepositcoin"] = GetCRDepositCoin
 	mainMux["getarbitersinfo"] = GetArbitersInfo
@@ -123,6 +143,7 @@ func StartRPCServer() {
 	mainMux["getvoterights"] = GetVoteRights
 
 	mainMux["dposv2rewardinfo"] = DposV2RewardInfo
+	mainMux["simulatedposv2reward"] = SimulateDPoSV2Reward
 	mainMux["getdposv2info"] = GetDPosV2Info
 
 	//nft
@@ -402,10 +423,12 @@ func convertParams(method string, params []interface{}) Params {
 		return FromArray(params, "addresses")
 	case "getreceivedbyaddress":
 		return FromArray(params, "address")
+	case "getaddresshistory", "getaddressdeltas":
+		return FromArray(params, "address", "cursor", "limit")
 	case "getblockbyheight":
 		return FromArray(params, "height")
 	case "estimatesmartfee":
-		return FromArray(params, "confirmations")
+		return FromArray(params, "confirmations", "confidence", "verbose")
 	case "getrawmempool":
 		return FromArray(params, "state")
 	default:
@@ -6,6 +6,7 @@
 package httpnodeinfo
 
 import (
+	"encoding/hex"
 	"fmt"
 	"html/template"
 	"net/http"
@@ -26,6 +27,7 @@ type Info struct {
 	HttpJsonPort  int
 	HttpLocalPort int
 	NodePort      uint16
+	Producers     []ProducerInfo
 }
 
 type NgbNodeInfo struct {
@@ -33,6 +35,15 @@ type NgbNodeInfo struct {
 	NbrAddr string
 }
 
+type ProducerInfo struct {
+	NodePublicKey     string
+	BlocksProposed    uint32
+	VotesCast         uint32
+	RoundsMissed      uint32
+	ViewChangesCaused uint32
+	Uptime            string
+}
+
 var templates = template.Must(template.New("info").Parse(page))
 
 func viewHandler(w http.ResponseWriter, r *http.Request) {
@@ -57,6 +68,18 @@ func viewHandler(w http.ResponseWriter, r *http.Request) {
 		HttpJsonPort: config.Parameters.HttpJsonPort,
 		NodePort:     config.Parameters.NodePort,
 	}
+	if servers.Performance != nil {
+		for _, p := range servers.Performance.GetPerformances() {
+			pageInfo.Producers = append(pageInfo.Producers, ProducerInfo{
+				NodePublicKey:     hex.EncodeToString(p.NodePublicKey),
+				BlocksProposed:    p.BlocksProposed,
+				VotesCast:         p.VotesCast,
+				RoundsMissed:      p.RoundsMissed,
+				ViewChangesCaused: p.ViewChangesCaused,
+				Uptime:            fmt.Sprintf("%.2f%%", p.Uptime()*100),
+			})
+		}
+	}
 
 	err := templates.ExecuteTemplate(w, "info", pageInfo)
 	if err != nil {
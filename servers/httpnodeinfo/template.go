@@ -76,7 +76,29 @@ const page = `
 </td>
 </tr>
 </table>
-<br><br><br><br><br><br>
+<br><br><br><br>
+
+{{if .Producers}}
+<table class="bt" width="80%">
+	<tr><th>Producers Performance</th></tr>
+</table>
+<br>
+
+<table class="bd" width="80%">
+<tr>
+<td>
+	<table class="font" width="100%">
+	<tr><th>Node Public Key</th><th>Blocks Proposed</th><th>Votes Cast</th><th>Rounds Missed</th><th>View Changes Caused</th><th>Uptime</th></tr>
+	{{range .Producers}}
+	<tr><td class="pk" width="40%">{{.NodePublicKey}}</td><td align="center">{{.BlocksProposed}}</td><td align="center">{{.VotesCast}}</td><td align="center">{{.RoundsMissed}}</td><td align="center">{{.ViewChangesCaused}}</td><td align="center">{{.Uptime}}</td></tr>
+	{{end}}
+	</table>
+</td>
+</tr>
+</table>
+<br><br><br><br>
+{{end}}
+<br><br>
 
 <table class="font" border="0" width="80%">
 	<tr>
@@ -282,7 +282,7 @@ func (rt *restServer) response(w http.ResponseWriter, resp map[string]interface{
 	resp["Desc"] = ErrMap[resp["Error"].(ServerErrCode)]
 	data, err := json.Marshal(resp)
 	if err != nil {
-		log.Fatal("HTTP Handle - json.Marshal: %v", err)
+		log.Fatalf("HTTP Handle - json.Marshal: %v", err)
 		return
 	}
 	rt.write(w, data)
@@ -43,30 +43,67 @@ var (
 
 type Handler func(servers.Params) map[string]interface{}
 
+type SessionHandler func(*session, servers.Params) map[string]interface{}
+
 type Server struct {
 	sync.RWMutex
 	*http.Server
 	net.Listener
 	websocket.Upgrader
 
-	connCount int64
-	sessions  *sessions
-	handlers  map[string]Handler
+	connCount       int64
+	sessions        *sessions
+	notifications   *notifyQueue
+	handlers        map[string]Handler
+	sessionHandlers map[string]SessionHandler
 }
 
 func Start() {
 	instance = &Server{
-		Upgrader: websocket.Upgrader{},
-		sessions: &sessions{},
+		Upgrader:      websocket.Upgrader{},
+		sessions:      &sessions{},
+		notifications: newNotifyQueue(),
 	}
+	go instance.notifications.handler()
 
 	events.Subscribe(func(e *events.Event) {
 		switch e.Type {
 		case events.ETBlockConnected:
 			SendBlock2WSclient(e.Data)
+			if block, ok := e.Data.(*types.Block); ok {
+				instance.notifications.push(func() {
+					instance.onBlockConnected(block)
+				})
+			}
+
+		case events.ETBlockDisconnected:
+			if block, ok := e.Data.(*types.Block); ok {
+				instance.notifications.push(func() {
+					instance.onBlockDisconnected(block)
+				})
+			}
+
+		case events.ETBlockConfirmAccepted:
+			if block, ok := e.Data.(*types.Block); ok {
+				instance.notifications.push(func() {
+					instance.onBlockConfirmAccepted(block)
+				})
+			}
 
 		case events.ETTransactionAccepted:
 			SendTx2Client(e.Data)
+			if tx, ok := e.Data.(interfaces.Transaction); ok {
+				instance.notifications.push(func() {
+					instance.onTransactionAccepted(tx)
+				})
+			}
+
+		case events.ETTransactionRemoved:
+			if tx, ok := e.Data.(interfaces.Transaction); ok {
+				instance.notifications.push(func() {
+					instance.onTransactionRemoved(tx)
+				})
+			}
 		}
 	})
 
@@ -115,6 +152,11 @@ func (s *Server) initMethods() {
 		"heartbeat":          s.heartBeat,
 		"getsessioncount":    s.getSessionCount,
 	}
+	s.sessionHandlers = map[string]SessionHandler{
+		"subscribe":        s.subscribe,
+		"unsubscribe":      s.unsubscribe,
+		"getsubscriptions": s.getSubscriptions,
+	}
 }
 
 func (s *Server) heartBeat(cmd servers.Params) map[string]interface{} {
@@ -161,11 +203,7 @@ func (s *Server) Handler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 
-	ss := &session{
-		id:         atomic.AddInt64(&s.connCount, 1),
-		conn:       conn,
-		lastActive: time.Now(),
-	}
+	ss := newSession(atomic.AddInt64(&s.connCount, 1), conn)
 	s.sessions.Store(ss.id, ss)
 
 	defer func() {
@@ -228,6 +266,12 @@ func (s *Server) handle(ss *session, bysMsg []byte, r *http.Request) bool {
 		s.response(ss, resp)
 		return false
 	}
+	if sessionHandler, ok := s.sessionHandlers[action]; ok {
+		resp := sessionHandler(ss, req)
+		resp["Action"] = action
+		s.response(ss, resp)
+		return true
+	}
 	handler, ok := s.handlers[action]
 	if !ok {
 		resp := servers.ResponsePack(errors.InvalidMethod, "")
@@ -318,8 +362,12 @@ func (s *Server) PushResult(action string, v interface{}) {
 		return
 	}
 
-	// Broadcast message to all connected clients.
+	// Broadcast message to all connected clients that have not subscribed,
+	// subscribed clients only receive the notifications they asked for.
 	s.sessions.Foreach(func(v *session) {
+		if v.subscription() != nil {
+			return
+		}
 		v.Send(data)
 	})
 }
@@ -13,22 +13,126 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+const (
+	// sendQueueSize is the max number of messages waiting to be written to
+	// a session.  A session which can not keep up with the messages is
+	// closed instead of holding up the others.
+	sendQueueSize = 256
+
+	// writeTimeout is the max duration of writing a message to a session.
+	writeTimeout = 10 * time.Second
+)
+
 type session struct {
-	mtx        sync.Mutex
 	id         int64
 	conn       *websocket.Conn
 	lastActive time.Time
+
+	send      chan []byte
+	quit      chan struct{}
+	closeOnce sync.Once
+
+	subMtx sync.Mutex
+	sub    *subscription
+}
+
+// subscribe returns the subscription of the session, creating it if the
+// session has not subscribed to anything yet.  Once a session subscribed, it
+// only receives the notifications it subscribed to.
+func (s *session) subscribe() *subscription {
+	s.subMtx.Lock()
+	defer s.subMtx.Unlock()
+	if s.sub == nil {
+		s.sub = newSubscription()
+	}
+	return s.sub
+}
+
+// subscription returns the subscription of the session, or nil if the session
+// has never subscribed.
+func (s *session) subscription() *subscription {
+	s.subMtx.Lock()
+	defer s.subMtx.Unlock()
+	return s.sub
+}
+
+// newSession returns a session of the connection and starts writing the
+// messages sent to it.
+func newSession(id int64, conn *websocket.Conn) *session {
+	s := &session{
+		id:         id,
+		conn:       conn,
+		lastActive: time.Now(),
+		send:       make(chan []byte, sendQueueSize),
+		quit:       make(chan struct{}),
+	}
+	go s.outHandler()
+	return s
 }
 
+// Send queues the data to be written to the session without blocking.  The
+// session is closed if too many messages are already waiting.
 func (s *session) Send(data []byte) error {
 	if s.conn == nil {
 		return errors.New("WebSocket is null")
 	}
 
-	s.mtx.Lock()
-	err := s.conn.WriteMessage(websocket.TextMessage, data)
-	s.mtx.Unlock()
-	return err
+	select {
+	case <-s.quit:
+		return errors.New("WebSocket is closed")
+	default:
+	}
+
+	select {
+	case s.send <- data:
+		return nil
+	default:
+		s.Close()
+		return errors.New("WebSocket send queue is full")
+	}
+}
+
+// Close stops the session, the messages already queued are still written
+// before the connection is closed.
+func (s *session) Close() {
+	s.closeOnce.Do(func() {
+		close(s.quit)
+	})
+}
+
+// outHandler writes the queued messages to the connection, each write is
+// bounded by the write timeout.  It must be run as a goroutine.
+func (s *session) outHandler() {
+	defer s.conn.Close()
+	for {
+		select {
+		case data := <-s.send:
+			if err := s.write(data, time.Now().Add(writeTimeout)); err != nil {
+				s.Close()
+				return
+			}
+
+		case <-s.quit:
+			deadline := time.Now().Add(writeTimeout)
+			for {
+				select {
+				case data := <-s.send:
+					if err := s.write(data, deadline); err != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *session) write(data []byte, deadline time.Time) error {
+	if err := s.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	return s.conn.WriteMessage(websocket.TextMessage, data)
 }
 
 type sessions struct {
@@ -41,7 +145,7 @@ func (ss *sessions) Load(id int64) (*session, bool) {
 }
 
 func (ss *sessions) Delete(s *session) {
-	s.conn.Close()
+	s.Close()
 	ss.Map.Delete(s.id)
 }
 
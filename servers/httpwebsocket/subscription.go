@@ -0,0 +1,576 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package httpwebsocket
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/common/log"
+	"github.com/elastos/Elastos.ELA/core/types"
+	"github.com/elastos/Elastos.ELA/core/types/interfaces"
+	"github.com/elastos/Elastos.ELA/servers"
+	"github.com/elastos/Elastos.ELA/servers/errors"
+)
+
+const (
+	// maxSubscribedAddresses is the maximum number of addresses a session
+	// can subscribe to.
+	maxSubscribedAddresses = 1000
+
+	// maxSubscribedTxs is the maximum number of transactions a session can
+	// subscribe to.
+	maxSubscribedTxs = 1000
+
+	// maxConfirmationUpdates is the number of confirmations after which
+	// a subscribed transaction stops receiving confirmation updates.
+	maxConfirmationUpdates = 6
+)
+
+// Topics of the events a session can subscribe to.
+const (
+	topicBlockConnected       = "blockconnected"
+	topicBlockDisconnected    = "blockdisconnected"
+	topicBlockConfirmAccepted = "blockconfirmaccepted"
+	topicTxAccepted           = "txaccepted"
+	topicTxRemoved            = "txremoved"
+)
+
+// Actions of the notifications pushed to subscribed sessions, in addition to
+// the event topics.
+const (
+	actionAddressTransaction = "addresstransaction"
+	actionTransactionStatus  = "transactionstatus"
+)
+
+// Status of a transaction reported to subscribed sessions.
+const (
+	txStatusPending     = "pending"
+	txStatusConfirmed   = "confirmed"
+	txStatusUnconfirmed = "unconfirmed"
+	txStatusRemoved     = "removed"
+)
+
+var eventTopics = map[string]struct{}{
+	topicBlockConnected:       {},
+	topicBlockDisconnected:    {},
+	topicBlockConfirmAccepted: {},
+	topicTxAccepted:           {},
+	topicTxRemoved:            {},
+}
+
+type BlockNotification struct {
+	Hash              string   `json:"hash"`
+	Height            uint32   `json:"height"`
+	PreviousBlockHash string   `json:"previousblockhash"`
+	Tx                []string `json:"tx"`
+	UnconfirmedTx     []string `json:"unconfirmedtx,omitempty"`
+}
+
+type AddressTransactionNotification struct {
+	Address string `json:"address"`
+	TxID    string `json:"txid"`
+	Status  string `json:"status"`
+	Height  uint32 `json:"height"`
+}
+
+type TransactionStatusNotification struct {
+	TxID          string `json:"txid"`
+	Status        string `json:"status"`
+	Height        uint32 `json:"height"`
+	Confirmations uint32 `json:"confirmations"`
+}
+
+type SubscriptionInfo struct {
+	Addresses []string `json:"addresses"`
+	TxIDs     []string `json:"txids"`
+	Events    []string `json:"events"`
+}
+
+// subscription holds the addresses, transactions and event topics a session
+// subscribed to.
+type subscription struct {
+	mtx       sync.RWMutex
+	addresses map[common.Uint168]string
+	txIDs     map[common.Uint256]struct{}
+	events    map[string]struct{}
+}
+
+func newSubscription() *subscription {
+	return &subscription{
+		addresses: make(map[common.Uint168]string),
+		txIDs:     make(map[common.Uint256]struct{}),
+		events:    make(map[string]struct{}),
+	}
+}
+
+func (s *subscription) hasEvent(topic string) bool {
+	s.mtx.RLock()
+	_, ok := s.events[topic]
+	s.mtx.RUnlock()
+	return ok
+}
+
+func (s *subscription) hasAddresses() bool {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return len(s.addresses) > 0
+}
+
+// matchAddresses returns the subscribed addresses among the given program
+// hashes.
+func (s *subscription) matchAddresses(
+	programHashes map[common.Uint168]struct{}) []string {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	var addresses []string
+	for programHash := range programHashes {
+		if address, ok := s.addresses[programHash]; ok {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+func (s *subscription) hasTx(txID common.Uint256) bool {
+	s.mtx.RLock()
+	_, ok := s.txIDs[txID]
+	s.mtx.RUnlock()
+	return ok
+}
+
+func (s *subscription) txs() []common.Uint256 {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	txIDs := make([]common.Uint256, 0, len(s.txIDs))
+	for txID := range s.txIDs {
+		txIDs = append(txIDs, txID)
+	}
+	return txIDs
+}
+
+func (s *subscription) info() SubscriptionInfo {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	info := SubscriptionInfo{
+		Addresses: make([]string, 0, len(s.addresses)),
+		TxIDs:     make([]string, 0, len(s.txIDs)),
+		Events:    make([]string, 0, len(s.events)),
+	}
+	for _, address := range s.addresses {
+		info.Addresses = append(info.Addresses, address)
+	}
+	for txID := range s.txIDs {
+		info.TxIDs = append(info.TxIDs, common.ToReversedString(txID))
+	}
+	for topic := range s.events {
+		info.Events = append(info.Events, topic)
+	}
+	return info
+}
+
+// subscriptionParams is the parsed parameters of a subscribe or unsubscribe
+// request.
+type subscriptionParams struct {
+	addresses map[common.Uint168]string
+	txIDs     map[common.Uint256]struct{}
+	events    map[string]struct{}
+}
+
+func parseSubscriptionParams(params servers.Params) (*subscriptionParams, error) {
+	p := &subscriptionParams{
+		addresses: make(map[common.Uint168]string),
+		txIDs:     make(map[common.Uint256]struct{}),
+		events:    make(map[string]struct{}),
+	}
+	if _, ok := params["addresses"]; ok {
+		addresses, ok := params.ArrayString("addresses")
+		if !ok {
+			return nil, fmt.Errorf("addresses should be an array of string")
+		}
+		for _, address := range addresses {
+			programHash, err := common.Uint168FromAddress(address)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %s", address)
+			}
+			p.addresses[*programHash] = address
+		}
+	}
+	if _, ok := params["txids"]; ok {
+		txIDs, ok := params.ArrayString("txids")
+		if !ok {
+			return nil, fmt.Errorf("txids should be an array of string")
+		}
+		for _, txIDStr := range txIDs {
+			txIDBytes, err := common.FromReversedString(txIDStr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid txid %s", txIDStr)
+			}
+			txID, err := common.Uint256FromBytes(txIDBytes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid txid %s", txIDStr)
+			}
+			p.txIDs[*txID] = struct{}{}
+		}
+	}
+	if _, ok := params["events"]; ok {
+		topics, ok := params.ArrayString("events")
+		if !ok {
+			return nil, fmt.Errorf("events should be an array of string")
+		}
+		for _, topic := range topics {
+			if _, ok := eventTopics[topic]; !ok {
+				return nil, fmt.Errorf("unknown event %s", topic)
+			}
+			p.events[topic] = struct{}{}
+		}
+	}
+	return p, nil
+}
+
+func (s *Server) subscribe(ss *session, params servers.Params) map[string]interface{} {
+	p, err := parseSubscriptionParams(params)
+	if err != nil {
+		return servers.ResponsePack(errors.InvalidParams, err.Error())
+	}
+
+	// Reject the request exceeding the limits by itself before the session
+	// becomes a subscriber.
+	if err := checkSubscriptionLimits(p, 0, 0); err != nil {
+		return servers.ResponsePack(errors.InvalidParams, err.Error())
+	}
+
+	sub := ss.subscribe()
+	sub.mtx.Lock()
+	defer sub.mtx.Unlock()
+
+	// Check the limits with the current subscription before applying any of
+	// the request, so a rejected request changes nothing.
+	var addresses, txIDs int
+	for programHash := range p.addresses {
+		if _, ok := sub.addresses[programHash]; !ok {
+			addresses++
+		}
+	}
+	for txID := range p.txIDs {
+		if _, ok := sub.txIDs[txID]; !ok {
+			txIDs++
+		}
+	}
+	err = checkSubscriptionLimits(p, len(sub.addresses)+addresses-len(p.addresses),
+		len(sub.txIDs)+txIDs-len(p.txIDs))
+	if err != nil {
+		return servers.ResponsePack(errors.InvalidParams, err.Error())
+	}
+
+	for programHash, address := range p.addresses {
+		sub.addresses[programHash] = address
+	}
+	for txID := range p.txIDs {
+		sub.txIDs[txID] = struct{}{}
+	}
+	for topic := range p.events {
+		sub.events[topic] = struct{}{}
+	}
+	return servers.ResponsePack(errors.Success, true)
+}
+
+// checkSubscriptionLimits returns an error if subscribing to the parameters
+// in addition to the given numbers of addresses and transactions exceeds the
+// limits of a session.
+func checkSubscriptionLimits(p *subscriptionParams, addresses, txIDs int) error {
+	if addresses+len(p.addresses) > maxSubscribedAddresses {
+		return fmt.Errorf("can not subscribe to more than %d addresses",
+			maxSubscribedAddresses)
+	}
+	if txIDs+len(p.txIDs) > maxSubscribedTxs {
+		return fmt.Errorf("can not subscribe to more than %d transactions",
+			maxSubscribedTxs)
+	}
+	return nil
+}
+
+func (s *Server) unsubscribe(ss *session, params servers.Params) map[string]interface{} {
+	sub := ss.subscription()
+	if sub == nil {
+		return servers.ResponsePack(errors.Success, true)
+	}
+	if all, ok := params.Bool("all"); ok && all {
+		sub.mtx.Lock()
+		sub.addresses = make(map[common.Uint168]string)
+		sub.txIDs = make(map[common.Uint256]struct{})
+		sub.events = make(map[string]struct{})
+		sub.mtx.Unlock()
+		return servers.ResponsePack(errors.Success, true)
+	}
+
+	p, err := parseSubscriptionParams(params)
+	if err != nil {
+		return servers.ResponsePack(errors.InvalidParams, err.Error())
+	}
+	sub.mtx.Lock()
+	for programHash := range p.addresses {
+		delete(sub.addresses, programHash)
+	}
+	for txID := range p.txIDs {
+		delete(sub.txIDs, txID)
+	}
+	for topic := range p.events {
+		delete(sub.events, topic)
+	}
+	sub.mtx.Unlock()
+	return servers.ResponsePack(errors.Success, true)
+}
+
+func (s *Server) getSubscriptions(ss *session, params servers.Params) map[string]interface{} {
+	sub := ss.subscription()
+	if sub == nil {
+		return servers.ResponsePack(errors.Success, newSubscription().info())
+	}
+	return servers.ResponsePack(errors.Success, sub.info())
+}
+
+// notifyQueue runs the notifications of the chain events one at a time in the
+// order they are queued, so subscribers never receive an event before the
+// events preceding it.  Pushing never blocks the event publisher.
+type notifyQueue struct {
+	mtx     sync.Mutex
+	pending *list.List
+	signal  chan struct{}
+}
+
+func newNotifyQueue() *notifyQueue {
+	return &notifyQueue{
+		pending: list.New(),
+		signal:  make(chan struct{}, 1),
+	}
+}
+
+// push queues the notification.
+func (q *notifyQueue) push(notify func()) {
+	q.mtx.Lock()
+	q.pending.PushBack(notify)
+	q.mtx.Unlock()
+
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
+
+// handler runs the queued notifications in order.  It must be run as a
+// goroutine.
+func (q *notifyQueue) handler() {
+	for range q.signal {
+		for {
+			q.mtx.Lock()
+			e := q.pending.Front()
+			if e != nil {
+				q.pending.Remove(e)
+			}
+			q.mtx.Unlock()
+			if e == nil {
+				break
+			}
+			e.Value.(func())()
+		}
+	}
+}
+
+// push sends the result to the session as a notification of the given action.
+func (s *Server) push(ss *session, action string, result interface{}) {
+	resp := servers.ResponsePack(errors.Success, result)
+	resp["Action"] = action
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		log.Error("Websocket push:", err)
+		return
+	}
+	ss.Send(data)
+}
+
+// forEachSubscriber invokes f with every session that has subscribed to
+// something.
+func (s *Server) forEachSubscriber(f func(*session, *subscription)) {
+	s.sessions.Foreach(func(ss *session) {
+		if sub := ss.subscription(); sub != nil {
+			f(ss, sub)
+		}
+	})
+}
+
+// hasAddressSubscribers returns if any session has subscribed to addresses.
+func (s *Server) hasAddressSubscribers() bool {
+	found := false
+	s.forEachSubscriber(func(ss *session, sub *subscription) {
+		if !found && sub.hasAddresses() {
+			found = true
+		}
+	})
+	return found
+}
+
+// txProgramHashes returns the program hashes of the addresses a transaction
+// pays to or spends from.
+func txProgramHashes(tx interfaces.Transaction) map[common.Uint168]struct{} {
+	programHashes := make(map[common.Uint168]struct{})
+	for _, output := range tx.Outputs() {
+		programHashes[output.ProgramHash] = struct{}{}
+	}
+	if tx.IsCoinBaseTx() {
+		return programHashes
+	}
+	for _, input := range tx.Inputs() {
+		referTx, _, err := servers.Store.GetTransaction(input.Previous.TxID)
+		if err != nil {
+			referTx = servers.TxMemPool.GetTransaction(input.Previous.TxID)
+		}
+		if referTx == nil || int(input.Previous.Index) >= len(referTx.Outputs()) {
+			continue
+		}
+		programHashes[referTx.Outputs()[input.Previous.Index].ProgramHash] = struct{}{}
+	}
+	return programHashes
+}
+
+// notifyTransactions pushes the address and transaction status notifications
+// of the given transactions to the sessions subscribed to them.
+func (s *Server) notifyTransactions(txs []interfaces.Transaction, status string,
+	height uint32) {
+	var programHashes []map[common.Uint168]struct{}
+	if s.hasAddressSubscribers() {
+		programHashes = make([]map[common.Uint168]struct{}, len(txs))
+		for i, tx := range txs {
+			programHashes[i] = txProgramHashes(tx)
+		}
+	}
+
+	var confirmations uint32
+	if status == txStatusConfirmed {
+		confirmations = 1
+	}
+	s.forEachSubscriber(func(ss *session, sub *subscription) {
+		for i, tx := range txs {
+			txID := common.ToReversedString(tx.Hash())
+			if programHashes != nil {
+				for _, address := range sub.matchAddresses(programHashes[i]) {
+					s.push(ss, actionAddressTransaction,
+						AddressTransactionNotification{
+							Address: address,
+							TxID:    txID,
+							Status:  status,
+							Height:  height,
+						})
+				}
+			}
+			if sub.hasTx(tx.Hash()) {
+				s.push(ss, actionTransactionStatus,
+					TransactionStatusNotification{
+						TxID:          txID,
+						Status:        status,
+						Height:        height,
+						Confirmations: confirmations,
+					})
+			}
+		}
+	})
+}
+
+// notifyConfirmations pushes the confirmation updates of the subscribed
+// transactions that were confirmed before the given block.
+func (s *Server) notifyConfirmations(block *types.Block) {
+	inBlock := make(map[common.Uint256]struct{}, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		inBlock[tx.Hash()] = struct{}{}
+	}
+
+	s.forEachSubscriber(func(ss *session, sub *subscription) {
+		for _, txID := range sub.txs() {
+			if _, ok := inBlock[txID]; ok {
+				continue
+			}
+			_, height, err := servers.Store.GetTransaction(txID)
+			if err != nil || height > block.Height {
+				continue
+			}
+			confirmations := block.Height - height + 1
+			if confirmations > maxConfirmationUpdates {
+				continue
+			}
+			s.push(ss, actionTransactionStatus, TransactionStatusNotification{
+				TxID:          common.ToReversedString(txID),
+				Status:        txStatusConfirmed,
+				Height:        height,
+				Confirmations: confirmations,
+			})
+		}
+	})
+}
+
+// notifyEvent pushes the result to the sessions subscribed to the topic.
+func (s *Server) notifyEvent(topic string, result interface{}) {
+	s.forEachSubscriber(func(ss *session, sub *subscription) {
+		if sub.hasEvent(topic) {
+			s.push(ss, topic, result)
+		}
+	})
+}
+
+func newBlockNotification(block *types.Block) BlockNotification {
+	txs := make([]string, 0, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		txs = append(txs, common.ToReversedString(tx.Hash()))
+	}
+	return BlockNotification{
+		Hash:              common.ToReversedString(block.Hash()),
+		Height:            block.Height,
+		PreviousBlockHash: common.ToReversedString(block.Header.Previous),
+		Tx:                txs,
+	}
+}
+
+func (s *Server) onBlockConnected(block *types.Block) {
+	s.notifyEvent(topicBlockConnected, newBlockNotification(block))
+	s.notifyTransactions(block.Transactions, txStatusConfirmed, block.Height)
+	s.notifyConfirmations(block)
+}
+
+func (s *Server) onBlockDisconnected(block *types.Block) {
+	// All transactions except the coinbase go back to unconfirmed.
+	var unconfirmed []interfaces.Transaction
+	for _, tx := range block.Transactions {
+		if !tx.IsCoinBaseTx() {
+			unconfirmed = append(unconfirmed, tx)
+		}
+	}
+
+	notification := newBlockNotification(block)
+	notification.UnconfirmedTx = make([]string, 0, len(unconfirmed))
+	for _, tx := range unconfirmed {
+		notification.UnconfirmedTx = append(notification.UnconfirmedTx,
+			common.ToReversedString(tx.Hash()))
+	}
+	s.notifyEvent(topicBlockDisconnected, notification)
+	s.notifyTransactions(unconfirmed, txStatusUnconfirmed, block.Height)
+}
+
+func (s *Server) onBlockConfirmAccepted(block *types.Block) {
+	s.notifyEvent(topicBlockConfirmAccepted, newBlockNotification(block))
+}
+
+func (s *Server) onTransactionAccepted(tx interfaces.Transaction) {
+	s.notifyEvent(topicTxAccepted, servers.GetTransactionContextInfo(nil, tx))
+	s.notifyTransactions([]interfaces.Transaction{tx}, txStatusPending, 0)
+}
+
+func (s *Server) onTransactionRemoved(tx interfaces.Transaction) {
+	s.notifyEvent(topicTxRemoved, servers.GetTransactionContextInfo(nil, tx))
+	s.notifyTransactions([]interfaces.Transaction{tx}, txStatusRemoved, 0)
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package httpwebsocket
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/servers"
+	"github.com/elastos/Elastos.ELA/servers/errors"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSubscriptionParams(t *testing.T) {
+	address := "EJMzC16Eorq9CuFCGtyMrq4Jmgw9jYCHQR"
+	programHash, _ := common.Uint168FromAddress(address)
+	txID := "9132cf82a18d859d200c952aec548d7895e7b654fd1761d5d059b91edbad1768"
+
+	p, err := parseSubscriptionParams(servers.Params{
+		"addresses": []interface{}{address},
+		"txids":     []interface{}{txID},
+		"events":    []interface{}{topicBlockConnected, topicTxRemoved},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[common.Uint168]string{*programHash: address}, p.addresses)
+	assert.Equal(t, 1, len(p.txIDs))
+	assert.Equal(t, 2, len(p.events))
+
+	_, err = parseSubscriptionParams(servers.Params{
+		"addresses": []interface{}{"invalid"},
+	})
+	assert.Error(t, err)
+
+	_, err = parseSubscriptionParams(servers.Params{
+		"txids": []interface{}{"1234"},
+	})
+	assert.Error(t, err)
+
+	_, err = parseSubscriptionParams(servers.Params{
+		"events": []interface{}{"unknown"},
+	})
+	assert.Error(t, err)
+}
+
+func TestSubscribe(t *testing.T) {
+	s := &Server{}
+	ss := &session{}
+	assert.Nil(t, ss.subscription())
+
+	address := "EJMzC16Eorq9CuFCGtyMrq4Jmgw9jYCHQR"
+	programHash, _ := common.Uint168FromAddress(address)
+	other, _ := common.Uint168FromAddress("EKnWs1jyNdhzH65UST8qMo8ZpQrTpXGnLH")
+	resp := s.subscribe(ss, servers.Params{
+		"addresses": []interface{}{address},
+		"events":    []interface{}{topicBlockDisconnected},
+	})
+	assert.Equal(t, errors.Success, resp["Error"])
+
+	sub := ss.subscription()
+	assert.NotNil(t, sub)
+	assert.True(t, sub.hasAddresses())
+	assert.True(t, sub.hasEvent(topicBlockDisconnected))
+	assert.False(t, sub.hasEvent(topicBlockConnected))
+	assert.Equal(t, []string{address}, sub.matchAddresses(
+		map[common.Uint168]struct{}{*programHash: {}, *other: {}}))
+	assert.Nil(t, sub.matchAddresses(
+		map[common.Uint168]struct{}{*other: {}}))
+
+	resp = s.unsubscribe(ss, servers.Params{
+		"addresses": []interface{}{address},
+	})
+	assert.Equal(t, errors.Success, resp["Error"])
+	assert.False(t, sub.hasAddresses())
+	assert.True(t, sub.hasEvent(topicBlockDisconnected))
+
+	resp = s.unsubscribe(ss, servers.Params{"all": true})
+	assert.Equal(t, errors.Success, resp["Error"])
+	assert.False(t, sub.hasEvent(topicBlockDisconnected))
+
+	// a session keeps receiving only what it subscribed to after clearing
+	// its subscription.
+	assert.NotNil(t, ss.subscription())
+}
+
+func TestSubscribe_Limits(t *testing.T) {
+	s := &Server{}
+	ss := &session{}
+
+	address := "EJMzC16Eorq9CuFCGtyMrq4Jmgw9jYCHQR"
+	other := "EKnWs1jyNdhzH65UST8qMo8ZpQrTpXGnLH"
+	resp := s.subscribe(ss, servers.Params{
+		"addresses": []interface{}{address},
+	})
+	assert.Equal(t, errors.Success, resp["Error"])
+
+	// Fill the subscription up to the address limit.
+	sub := ss.subscription()
+	for i := 0; len(sub.addresses) < maxSubscribedAddresses; i++ {
+		var programHash common.Uint168
+		programHash[0], programHash[1], programHash[2] = 0xff, byte(i), byte(i>>8)
+		sub.addresses[programHash] = ""
+	}
+
+	// Subscribing to an address already subscribed does not count.
+	resp = s.subscribe(ss, servers.Params{
+		"addresses": []interface{}{address},
+	})
+	assert.Equal(t, errors.Success, resp["Error"])
+
+	// A rejected request changes nothing of the subscription.
+	resp = s.subscribe(ss, servers.Params{
+		"addresses": []interface{}{address, other},
+		"txids": []interface{}{
+			"0000000000000000000000000000000000000000000000000000000000000001"},
+		"events": []interface{}{topicBlockConnected},
+	})
+	assert.Equal(t, errors.InvalidParams, resp["Error"])
+	assert.Equal(t, maxSubscribedAddresses, len(sub.addresses))
+	assert.Equal(t, 0, len(sub.txIDs))
+	assert.False(t, sub.hasEvent(topicBlockConnected))
+
+	// A request exceeding the limit by itself does not make the session a
+	// subscriber.
+	txIDs := make([]interface{}, maxSubscribedTxs+1)
+	for i := range txIDs {
+		txIDs[i] = fmt.Sprintf("%064x", i)
+	}
+	ss = &session{}
+	resp = s.subscribe(ss, servers.Params{"txids": txIDs})
+	assert.Equal(t, errors.InvalidParams, resp["Error"])
+	assert.Nil(t, ss.subscription())
+}
+
+func TestNotifyQueue(t *testing.T) {
+	q := newNotifyQueue()
+	go q.handler()
+
+	const count = 1000
+	var mtx sync.Mutex
+	var got []int
+	done := make(chan struct{})
+	for i := 0; i < count; i++ {
+		i := i
+		q.push(func() {
+			mtx.Lock()
+			got = append(got, i)
+			mtx.Unlock()
+			if i == count-1 {
+				close(done)
+			}
+		})
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("notifications not handled")
+	}
+	mtx.Lock()
+	defer mtx.Unlock()
+	for i := range got {
+		if got[i] != i {
+			t.Fatalf("notification %d handled at %d", got[i], i)
+		}
+	}
+}
+
+func TestSessionSendQueueFull(t *testing.T) {
+	ss := &session{
+		conn: &websocket.Conn{},
+		send: make(chan []byte, 2),
+		quit: make(chan struct{}),
+	}
+
+	assert.NoError(t, ss.Send([]byte("1")))
+	assert.NoError(t, ss.Send([]byte("2")))
+
+	// A session which does not keep up is closed instead of blocking the
+	// sender.
+	assert.Error(t, ss.Send([]byte("3")))
+	select {
+	case <-ss.quit:
+	default:
+		t.Fatal("session not closed")
+	}
+	assert.Error(t, ss.Send([]byte("4")))
+	assert.Equal(t, 2, len(ss.send))
+}
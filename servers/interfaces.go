@@ -13,10 +13,14 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/elastos/Elastos.ELA/account"
+	"github.com/elastos/Elastos.ELA/account/coinselect"
+	"github.com/elastos/Elastos.ELA/account/psbt"
 	aux "github.com/elastos/Elastos.ELA/auxpow"
 	"github.com/elastos/Elastos.ELA/blockchain"
+	"github.com/elastos/Elastos.ELA/blockchain/indexers"
 	"github.com/elastos/Elastos.ELA/common"
 	"github.com/elastos/Elastos.ELA/common/config"
 	"github.com/elastos/Elastos.ELA/common/log"
@@ -30,14 +34,17 @@ import (
 	"github.com/elastos/Elastos.ELA/core/types/outputpayload"
 	"github.com/elastos/Elastos.ELA/core/types/payload"
 	crstate "github.com/elastos/Elastos.ELA/cr/state"
+	"github.com/elastos/Elastos.ELA/crypto"
 	"github.com/elastos/Elastos.ELA/dpos"
 	"github.com/elastos/Elastos.ELA/dpos/state"
 	"github.com/elastos/Elastos.ELA/elanet"
 	"github.com/elastos/Elastos.ELA/elanet/pact"
 	"github.com/elastos/Elastos.ELA/mempool"
 	"github.com/elastos/Elastos.ELA/p2p/msg"
+	svr "github.com/elastos/Elastos.ELA/p2p/server"
 	"github.com/elastos/Elastos.ELA/pow"
 	. "github.com/elastos/Elastos.ELA/servers/errors"
+	"github.com/elastos/Elastos.ELA/utils/elalog"
 	"github.com/elastos/Elastos.ELA/wallet"
 
 	"github.com/tidwall/gjson"
@@ -53,6 +60,7 @@ var (
 	Server      elanet.Server
 	Arbiter     *dpos.Arbitrator
 	Arbiters    state.Arbitrators
+	Performance *state.PerformanceTracker
 	Wallet      *wallet.Wallet
 	emptyHash   = common.Uint168{}
 )
@@ -228,6 +236,149 @@ func GetNodeState(param Params) map[string]interface{} {
 	})
 }
 
+func GetPeerInfo(param Params) map[string]interface{} {
+	persistent := make(map[svr.IPeer]struct{})
+	for _, peer := range Server.PersistentPeers() {
+		persistent[peer] = struct{}{}
+	}
+
+	peers := Server.ConnectedPeers()
+	infos := make([]*PeerDetailInfo, 0, len(peers))
+	for _, peer := range peers {
+		snap := peer.ToPeer().StatsSnapshot()
+		_, isPersistent := persistent[peer]
+		infos = append(infos, &PeerDetailInfo{
+			ID:             snap.ID,
+			Addr:           snap.Addr,
+			Services:       pact.ServiceFlag(snap.Services).String(),
+			RelayTx:        snap.RelayTx != 0,
+			LastSend:       snap.LastSend.Unix(),
+			LastRecv:       snap.LastRecv.Unix(),
+			BytesSent:      snap.BytesSent,
+			BytesRecv:      snap.BytesRecv,
+			ConnTime:       snap.ConnTime.Unix(),
+			TimeOffset:     snap.TimeOffset,
+			PingTime:       float64(snap.LastPingMicros) / 1e6,
+			Version:        snap.Version,
+			NodeVersion:    snap.NodeVersion,
+			Inbound:        snap.Inbound,
+			Persistent:     isPersistent,
+			StartingHeight: snap.StartingHeight,
+			SyncHeight:     snap.LastBlock,
+			BanScore:       peer.BanScore(),
+			Encrypted:      snap.Encrypted,
+		})
+	}
+	return ResponsePack(Success, infos)
+}
+
+func AddNode(param Params) map[string]interface{} {
+	if rtn := checkRPCServiceLevel(config.ConfigurationPermitted); rtn != nil {
+		return rtn
+	}
+
+	node, ok := param.String("node")
+	if !ok || node == "" {
+		return ResponsePack(InvalidParams, "parameter node not found")
+	}
+	command, _ := param.String("command")
+
+	var err error
+	switch command {
+	case "add":
+		err = Server.Connect(node, true)
+	case "remove":
+		err = Server.RemoveByAddr(node)
+	case "onetry":
+		err = Server.Connect(node, false)
+	default:
+		return ResponsePack(InvalidParams,
+			"command must be one of add, remove and onetry")
+	}
+	if err != nil {
+		return ResponsePack(InternalError, err.Error())
+	}
+	return ResponsePack(Success, nil)
+}
+
+func DisconnectNode(param Params) map[string]interface{} {
+	if rtn := checkRPCServiceLevel(config.ConfigurationPermitted); rtn != nil {
+		return rtn
+	}
+
+	var err error
+	if address, ok := param.String("address"); ok && address != "" {
+		err = Server.DisconnectByAddr(address)
+	} else if id, ok := param.Int("nodeid"); ok && id >= 0 {
+		err = Server.DisconnectByID(uint64(id))
+	} else {
+		return ResponsePack(InvalidParams, "parameter address or nodeid not found")
+	}
+	if err != nil {
+		return ResponsePack(InternalError, err.Error())
+	}
+	return ResponsePack(Success, nil)
+}
+
+func SetBan(param Params) map[string]interface{} {
+	if rtn := checkRPCServiceLevel(config.ConfigurationPermitted); rtn != nil {
+		return rtn
+	}
+
+	subnet, ok := param.String("subnet")
+	if !ok || subnet == "" {
+		return ResponsePack(InvalidParams, "parameter subnet not found")
+	}
+	if _, err := svr.ParseSubnet(subnet); err != nil {
+		return ResponsePack(InvalidParams, err.Error())
+	}
+	command, _ := param.String("command")
+
+	var err error
+	switch command {
+	case "add":
+		banTime, _ := param.Int("bantime")
+		if banTime < 0 {
+			return ResponsePack(InvalidParams, "bantime must not be negative")
+		}
+		if absolute, _ := param.Bool("absolute"); absolute {
+			err = Server.BanUntil(subnet, time.Unix(banTime, 0))
+		} else {
+			err = Server.Ban(subnet, time.Duration(banTime)*time.Second)
+		}
+	case "remove":
+		err = Server.Unban(subnet)
+	default:
+		return ResponsePack(InvalidParams, "command must be one of add and remove")
+	}
+	if err != nil {
+		return ResponsePack(InternalError, err.Error())
+	}
+	return ResponsePack(Success, nil)
+}
+
+func ListBanned(param Params) map[string]interface{} {
+	bans := Server.BannedList()
+	result := make([]BannedInfo, 0, len(bans))
+	for _, ban := range bans {
+		result = append(result, BannedInfo{
+			Address:     ban.Subnet,
+			BanCreated:  ban.Created.Unix(),
+			BannedUntil: ban.Until.Unix(),
+		})
+	}
+	return ResponsePack(Success, result)
+}
+
+func ClearBanned(param Params) map[string]interface{} {
+	if rtn := checkRPCServiceLevel(config.ConfigurationPermitted); rtn != nil {
+		return rtn
+	}
+
+	Server.ClearBanned()
+	return ResponsePack(Success, nil)
+}
+
 func SetLogLevel(param Params) map[string]interface{} {
 	if rtn := checkRPCServiceLevel(config.ConfigurationPermitted); rtn != nil {
 		return rtn
@@ -238,7 +389,19 @@ func SetLogLevel(param Params) map[string]interface{} {
 		return ResponsePack(InvalidParams, "level must be an integer in 0-6")
 	}
 
+	if subsystem, ok := param.String("subsystem"); ok && subsystem != "" {
+		if err := log.SetSubsystemLevel(subsystem,
+			elalog.Level(level)); err != nil {
+			return ResponsePack(InvalidParams, err.Error())
+		}
+		return ResponsePack(Success, fmt.Sprintf(
+			"log level of %s has been set to %d", subsystem, level))
+	}
+
 	log.SetPrintLevel(uint8(level))
+	for subsystem := range log.SubsystemLevels() {
+		log.SetSubsystemLevel(subsystem, elalog.Level(level))
+	}
 	return ResponsePack(Success, fmt.Sprint("log level has been set to ", level))
 }
 
@@ -444,6 +607,7 @@ func GetArbiterPeersInfo(params Params) map[string]interface{} {
 		IP             string `json:"ip,omitempty"`
 		ConnState      string `json:"connstate"`
 		NodeVersion    string `json:"nodeversion"`
+		Encrypted      bool   `json:"encrypted"`
 	}
 
 	peers := Arbiter.GetArbiterPeersInfo()
@@ -465,6 +629,7 @@ func GetArbiterPeersInfo(params Params) map[string]interface{} {
 			IP:          p.Addr,
 			ConnState:   p.State.String(),
 			NodeVersion: p.NodeVersion,
+			Encrypted:   p.Encrypted,
 		})
 	}
 	return ResponsePack(Success, result)
@@ -552,6 +717,29 @@ func GetAllDetailedDPoSV2Votes(params Params) map[string]interface{} {
 	return ResponsePack(Success, dvi)
 }
 
+// getHistoryState returns the DPoS and CR states at the height given by the
+// optional "height" parameter, or the current states if it is absent.
+func getHistoryState(param Params) (*blockchain.HistoryState, map[string]interface{}) {
+	if _, ok := param["height"]; !ok {
+		return &blockchain.HistoryState{
+			Height:    Chain.GetHeight(),
+			Arbiters:  Arbiters,
+			State:     Chain.GetState(),
+			Committee: Chain.GetCRCommittee(),
+		}, nil
+	}
+	height, ok := param.Uint("height")
+	if !ok {
+		return nil, ResponsePack(InvalidParams, "invalid height")
+	}
+	historyState, err := Chain.GetHistoryState(height)
+	if err != nil {
+		return nil, ResponsePack(InternalError, fmt.Sprintf(
+			"get state at height %d failed: %s", height, err))
+	}
+	return historyState, nil
+}
+
 // GetProducerInfo
 func GetProducerInfo(params Params) map[string]interface{} {
 	publicKey, ok := params.String("publickey")
@@ -562,7 +750,11 @@ func GetProducerInfo(params Params) map[string]interface{} {
 	if err != nil {
 		return ResponsePack(InvalidParams, "invalid public key")
 	}
-	p := Chain.GetState().GetProducer(publicKeyBytes)
+	historyState, resp := getHistoryState(params)
+	if resp != nil {
+		return resp
+	}
+	p := historyState.State.GetProducer(publicKeyBytes)
 	if p == nil {
 		return ResponsePack(InvalidParams, "unknown producer public key")
 	}
@@ -588,6 +780,258 @@ func GetProducerInfo(params Params) map[string]interface{} {
 	return ResponsePack(Success, producerInfo)
 }
 
+type RPCProducerPerformance struct {
+	OwnerPublicKey      string  `json:"ownerpublickey"`
+	NodePublicKey       string  `json:"nodepublickey"`
+	Nickname            string  `json:"nickname"`
+	BlocksProposed      uint32  `json:"blocksproposed"`
+	VotesCast           uint32  `json:"votescast"`
+	RoundsMissed        uint32  `json:"roundsmissed"`
+	ViewChangesCaused   uint32  `json:"viewchangescaused"`
+	Rounds              uint32  `json:"rounds"`
+	RoundsParticipated  uint32  `json:"roundsparticipated"`
+	Uptime              float64 `json:"uptime"`
+	ProposalsReceived   uint32  `json:"proposalsreceived"`
+	VotesReceived       uint32  `json:"votesreceived"`
+	ViewChangesObserved uint32  `json:"viewchangesobserved"`
+	InactiveDetections  uint32  `json:"inactivedetections"`
+}
+
+func getProducerPerformanceInfo(p state.ProducerPerformance) RPCProducerPerformance {
+	info := RPCProducerPerformance{
+		NodePublicKey:       hex.EncodeToString(p.NodePublicKey),
+		BlocksProposed:      p.BlocksProposed,
+		VotesCast:           p.VotesCast,
+		RoundsMissed:        p.RoundsMissed,
+		ViewChangesCaused:   p.ViewChangesCaused,
+		Rounds:              p.Rounds,
+		RoundsParticipated:  p.RoundsParticipated,
+		Uptime:              p.Uptime(),
+		ProposalsReceived:   p.ProposalsReceived,
+		VotesReceived:       p.VotesReceived,
+		ViewChangesObserved: p.ViewChangesObserved,
+		InactiveDetections:  p.InactiveDetections,
+	}
+	if producer := Chain.GetState().GetProducer(p.NodePublicKey); producer != nil {
+		info.OwnerPublicKey = hex.EncodeToString(producer.Info().OwnerKey)
+		info.Nickname = producer.Info().NickName
+	}
+	return info
+}
+
+// GetProducerPerformance returns the consensus performance of the producer
+// with the given owner or node public key, or of all tracked producers if
+// the public key is absent.
+func GetProducerPerformance(params Params) map[string]interface{} {
+	if Performance == nil {
+		return ResponsePack(InternalError, "performance tracker not found")
+	}
+
+	publicKey, ok := params.String("publickey")
+	if !ok {
+		var result []RPCProducerPerformance
+		for _, p := range Performance.GetPerformances() {
+			result = append(result, getProducerPerformanceInfo(p))
+		}
+		return ResponsePack(Success, result)
+	}
+
+	publicKeyBytes, err := common.HexStringToBytes(publicKey)
+	if err != nil {
+		return ResponsePack(InvalidParams, "invalid public key")
+	}
+	nodePublicKey := publicKeyBytes
+	if producer := Chain.GetState().GetProducer(publicKeyBytes); producer != nil {
+		nodePublicKey = producer.Info().NodePublicKey
+	}
+	p, ok := Performance.GetPerformance(nodePublicKey)
+	if !ok {
+		return ResponsePack(InvalidParams, "unknown producer public key")
+	}
+	return ResponsePack(Success, getProducerPerformanceInfo(p))
+}
+
+type RPCIllegalOffender struct {
+	NodePublicKey  string `json:"nodepublickey"`
+	OwnerPublicKey string `json:"ownerpublickey"`
+	Nickname       string `json:"nickname"`
+	Role           string `json:"role"`
+	DepositAddress string `json:"depositaddress"`
+	Penalty        string `json:"penalty"`
+}
+
+type RPCIllegalEvidence struct {
+	TxID      string               `json:"txid"`
+	Height    uint32               `json:"height"`
+	Type      string               `json:"type"`
+	Offenders []RPCIllegalOffender `json:"offenders"`
+}
+
+// getIllegalOffender returns the offender of the illegal evidence entry along
+// with the penalty deducted from its deposit.
+func getIllegalOffender(entry *indexers.IllegalEvidenceEntry,
+	nodePublicKey []byte) RPCIllegalOffender {
+	offender := RPCIllegalOffender{
+		NodePublicKey: common.BytesToHexString(nodePublicKey),
+		Role:          "unknown",
+		Penalty:       common.Fixed64(0).String(),
+	}
+
+	// Inactive arbitrators transactions only punish producers, and illegal
+	// evidences punish CR members claimed the DPoS node as well.
+	if entry.TxType != common2.InactiveArbitrators {
+		member := Chain.GetCRCommittee().GetMemberByNodePublicKey(nodePublicKey)
+		if member != nil {
+			addr, _ := member.DepositHash.ToAddress()
+			offender.Role = "crmember"
+			offender.Nickname = member.Info.NickName
+			offender.DepositAddress = addr
+			offender.Penalty = Chain.GetState().GetIllegalPenaltyByHeight(
+				entry.Height).String()
+			return offender
+		}
+	}
+
+	producer := Chain.GetState().GetProducer(nodePublicKey)
+	if producer == nil {
+		return offender
+	}
+	depositHash := producer.DepositHash()
+	addr, _ := depositHash.ToAddress()
+	offender.Role = "producer"
+	offender.OwnerPublicKey = common.BytesToHexString(producer.OwnerPublicKey())
+	offender.Nickname = producer.Info().NickName
+	offender.DepositAddress = addr
+	if entry.TxType == common2.InactiveArbitrators {
+		offender.Penalty = Chain.GetState().GetEmergencyInactivePenaltyByHeight(
+			entry.Height).String()
+	} else {
+		offender.Penalty = Chain.GetState().GetIllegalPenaltyByHeight(
+			entry.Height).String()
+	}
+	return offender
+}
+
+// getIllegalEvidences returns the illegal evidence entries filtered by the
+// optional "publickey", "startheight" and "endheight" parameters, along with
+// the node public key of the producer if it is given.
+func getIllegalEvidences(param Params) ([]*indexers.IllegalEvidenceEntry,
+	[]byte, map[string]interface{}) {
+	var nodePublicKey []byte
+	if publicKey, ok := param.String("publickey"); ok {
+		publicKeyBytes, err := common.HexStringToBytes(publicKey)
+		if err != nil {
+			return nil, nil, ResponsePack(InvalidParams, "invalid public key")
+		}
+		nodePublicKey = publicKeyBytes
+		if producer := Chain.GetState().GetProducer(publicKeyBytes); producer != nil {
+			nodePublicKey = producer.NodePublicKey()
+		}
+	}
+	startHeight, _ := param.Uint("startheight")
+	endHeight, ok := param.Uint("endheight")
+	if !ok {
+		endHeight = Chain.GetHeight()
+	}
+	if startHeight > endHeight {
+		return nil, nil, ResponsePack(InvalidParams,
+			"start height is higher than end height")
+	}
+
+	entries, err := Store.GetFFLDB().GetIllegalEvidences(nodePublicKey,
+		startHeight, endHeight)
+	if err != nil {
+		if err == indexers.ErrIllegalEvidenceIndexDisabled {
+			return nil, nil, ResponsePack(InvalidMethod, err.Error())
+		}
+		return nil, nil, ResponsePack(InternalError,
+			"get illegal evidences failed, "+err.Error())
+	}
+	return entries, nodePublicKey, nil
+}
+
+// ListIllegalEvidence returns the transactions punishing arbiters along with
+// the penalties deducted from the deposits of the offenders.
+func ListIllegalEvidence(param Params) map[string]interface{} {
+	entries, _, resp := getIllegalEvidences(param)
+	if resp != nil {
+		return resp
+	}
+
+	result := make([]RPCIllegalEvidence, 0, len(entries))
+	for _, entry := range entries {
+		evidence := RPCIllegalEvidence{
+			TxID:      common.ToReversedString(entry.TxID),
+			Height:    entry.Height,
+			Type:      entry.TxType.Name(),
+			Offenders: make([]RPCIllegalOffender, 0, len(entry.Offenders)),
+		}
+		for _, o := range entry.Offenders {
+			evidence.Offenders = append(evidence.Offenders,
+				getIllegalOffender(entry, o))
+		}
+		result = append(result, evidence)
+	}
+	return ResponsePack(Success, result)
+}
+
+type RPCProducerPenalty struct {
+	TxID           string `json:"txid"`
+	Height         uint32 `json:"height"`
+	Type           string `json:"type"`
+	Role           string `json:"role"`
+	DepositAddress string `json:"depositaddress"`
+	Penalty        string `json:"penalty"`
+}
+
+type RPCProducerPenalties struct {
+	OwnerPublicKey string               `json:"ownerpublickey"`
+	NodePublicKey  string               `json:"nodepublickey"`
+	Nickname       string               `json:"nickname"`
+	DepositAddress string               `json:"depositaddress"`
+	DepositAmount  string               `json:"depositamount"`
+	TotalPenalty   string               `json:"totalpenalty"`
+	Penalties      []RPCProducerPenalty `json:"penalties"`
+}
+
+// GetProducerPenalties returns the penalties deducted from the deposit of
+// the producer by the transactions punishing it.
+func GetProducerPenalties(param Params) map[string]interface{} {
+	if _, ok := param.String("publickey"); !ok {
+		return ResponsePack(InvalidParams, "public key not found")
+	}
+	entries, nodePublicKey, resp := getIllegalEvidences(param)
+	if resp != nil {
+		return resp
+	}
+
+	result := RPCProducerPenalties{
+		NodePublicKey: common.BytesToHexString(nodePublicKey),
+		Penalties:     make([]RPCProducerPenalty, 0, len(entries)),
+	}
+	if producer := Chain.GetState().GetProducer(nodePublicKey); producer != nil {
+		depositHash := producer.DepositHash()
+		addr, _ := depositHash.ToAddress()
+		result.OwnerPublicKey = common.BytesToHexString(producer.OwnerPublicKey())
+		result.Nickname = producer.Info().NickName
+		result.DepositAddress = addr
+		result.DepositAmount = producer.DepositAmount().String()
+		result.TotalPenalty = producer.Penalty().String()
+	}
+	for _, entry := range entries {
+		offender := getIllegalOffender(entry, nodePublicKey)
+		result.Penalties = append(result.Penalties, RPCProducerPenalty{
+			TxID:           common.ToReversedString(entry.TxID),
+			Height:         entry.Height,
+			Type:           entry.TxType.Name(),
+			Role:           offender.Role,
+			DepositAddress: offender.DepositAddress,
+			Penalty:        offender.Penalty,
+		})
+	}
+	return ResponsePack(Success, result)
+}
+
 func GetNFTInfo(params Params) map[string]interface{} {
 	idParam, ok := params.String("id")
 	if !ok {
@@ -891,36 +1335,41 @@ func GetArbitersInfo(params Params) map[string]interface{} {
 		NextTurnStartHeight    int      `json:"nextturnstartheight"`
 	}
 
-	dutyIndex := Arbiters.GetDutyIndex()
+	historyState, resp := getHistoryState(params)
+	if resp != nil {
+		return resp
+	}
+
+	dutyIndex := historyState.Arbiters.GetDutyIndex()
 	result := &arbitersInfo{
 		Arbiters:       make([]string, 0),
 		Candidates:     make([]string, 0),
 		NextArbiters:   make([]string, 0),
 		NextCandidates: make([]string, 0),
-		OnDutyArbiter:  common.BytesToHexString(Arbiters.GetOnDutyArbitrator()),
+		OnDutyArbiter:  common.BytesToHexString(historyState.Arbiters.GetOnDutyArbitrator()),
 
-		CurrentTurnStartHeight: int(Chain.GetHeight()) - dutyIndex,
-		NextTurnStartHeight: int(Chain.GetHeight()) +
-			Arbiters.GetArbitersCount() - dutyIndex,
+		CurrentTurnStartHeight: int(historyState.Height) - dutyIndex,
+		NextTurnStartHeight: int(historyState.Height) +
+			historyState.Arbiters.GetArbitersCount() - dutyIndex,
 	}
-	for _, v := range Arbiters.GetArbitrators() {
+	for _, v := range historyState.Arbiters.GetArbitrators() {
 		var nodePK string
 		if v.IsNormal {
 			nodePK = common.BytesToHexString(v.NodePublicKey)
 		}
 		result.Arbiters = append(result.Arbiters, nodePK)
 	}
-	for _, v := range Arbiters.GetCandidates() {
+	for _, v := range historyState.Arbiters.GetCandidates() {
 		result.Candidates = append(result.Candidates, common.BytesToHexString(v))
 	}
-	for _, v := range Arbiters.GetNextArbitrators() {
+	for _, v := range historyState.Arbiters.GetNextArbitrators() {
 		var nodePK string
 		if v.IsNormal {
 			nodePK = common.BytesToHexString(v.NodePublicKey)
 		}
 		result.NextArbiters = append(result.NextArbiters, nodePK)
 	}
-	for _, v := range Arbiters.GetNextCandidates() {
+	for _, v := range historyState.Arbiters.GetNextCandidates() {
 		result.NextCandidates = append(result.NextCandidates,
 			common.BytesToHexString(v))
 	}
@@ -1126,6 +1575,9 @@ func GetConfirmInfo(confirm *payload.Confirm) ConfirmInfo {
 func getBlock(hash common.Uint256, verbose uint32) (interface{}, ServerErrCode) {
 	block, err := Chain.GetBlockByHash(hash)
 	if err != nil {
+		if Chain.IsBlockPruned(&hash) {
+			return "", PrunedBlock
+		}
 		return "", UnknownBlock
 	}
 	switch verbose {
@@ -1565,9 +2017,19 @@ func GetUTXOsByAmount(param Params) map[string]interface{} {
 		utxos = unusedUTXOs
 	}
 
+	// without a strategy the first utxos covering the amount are returned,
+	// otherwise all of the utxos are candidates of the strategy.
+	var strategy coinselect.Strategy
+	if name, ok := param.String("strategy"); ok && name != "" {
+		strategy, err = coinselect.GetStrategy(name)
+		if err != nil {
+			return ResponsePack(InvalidParams, err.Error())
+		}
+	}
+
 	totalAmount := common.Fixed64(0)
 	for _, utxo := range utxos {
-		if totalAmount >= *amount {
+		if strategy == nil && totalAmount >= *amount {
 			break
 		}
 		tx, height, err := Store.GetTransaction(utxo.TxID)
@@ -1603,6 +2065,23 @@ func GetUTXOsByAmount(param Params) map[string]interface{} {
 		return ResponsePack(InternalError, "not enough utxo")
 	}
 
+	if strategy != nil {
+		candidates := make([]coinselect.Coin, 0, len(result))
+		for i, u := range result {
+			value, _ := common.StringToFixed64(u.Amount)
+			candidates = append(candidates, coinselect.Coin{Index: i, Value: *value})
+		}
+		coins, err := strategy.Select(candidates, *amount)
+		if err != nil {
+			return ResponsePack(InternalError, err.Error())
+		}
+		selected := make([]UTXOInfo, 0, len(coins))
+		for _, c := range coins {
+			selected = append(selected, result[c.Index])
+		}
+		result = selected
+	}
+
 	return ResponsePack(Success, result)
 }
 
@@ -1701,6 +2180,110 @@ func ListUnspent(param Params) map[string]interface{} {
 	return ResponsePack(Success, result)
 }
 
+const (
+	// defaultAddressHistoryLimit is the number of history entries returned
+	// per page when no limit is given.
+	defaultAddressHistoryLimit = 100
+
+	// maxAddressHistoryLimit is the maximum number of history entries
+	// returned per page.
+	maxAddressHistoryLimit = 1000
+)
+
+// fetchAddressHistory returns one page of the history of the address given
+// in param, along with the cursor of the next page.
+func fetchAddressHistory(param Params) (string,
+	[]*indexers.AddressHistoryEntry, string, map[string]interface{}) {
+	address, ok := param.String("address")
+	if !ok {
+		return "", nil, "", ResponsePack(InvalidParams, "need a parameter named address")
+	}
+	programHash, err := common.Uint168FromAddress(address)
+	if err != nil {
+		return "", nil, "", ResponsePack(InvalidParams, "invalid address, "+err.Error())
+	}
+	var cursor []byte
+	if c, ok := param.String("cursor"); ok && c != "" {
+		cursor, err = common.HexStringToBytes(c)
+		if err != nil {
+			return "", nil, "", ResponsePack(InvalidParams, "invalid cursor")
+		}
+	}
+	limit := uint32(defaultAddressHistoryLimit)
+	if l, ok := param.Uint("limit"); ok {
+		if l == 0 || l > maxAddressHistoryLimit {
+			return "", nil, "", ResponsePack(InvalidParams,
+				fmt.Sprintf("limit should be between 1 and %d", maxAddressHistoryLimit))
+		}
+		limit = l
+	}
+
+	entries, next, err := Store.GetFFLDB().GetAddressHistory(programHash,
+		cursor, limit)
+	if err != nil {
+		if err == indexers.ErrAddrHistoryIndexDisabled {
+			return "", nil, "", ResponsePack(InvalidMethod, err.Error())
+		}
+		return "", nil, "", ResponsePack(InternalError, "get address history failed, "+err.Error())
+	}
+	return address, entries, common.BytesToHexString(next), nil
+}
+
+func GetAddressHistory(param Params) map[string]interface{} {
+	if rtn := checkRPCServiceLevel(config.WalletPermitted); rtn != nil {
+		return rtn
+	}
+
+	address, entries, next, rtn := fetchAddressHistory(param)
+	if rtn != nil {
+		return rtn
+	}
+
+	bestHeight := Chain.GetHeight()
+	result := AddressHistoryPage{
+		Address:    address,
+		Items:      make([]interface{}, 0, len(entries)),
+		NextCursor: next,
+	}
+	for _, entry := range entries {
+		result.Items = append(result.Items, AddressHistoryInfo{
+			TxID:          common.ToReversedString(entry.TxID),
+			Height:        entry.Height,
+			Position:      entry.Position,
+			Confirmations: bestHeight - entry.Height + 1,
+		})
+	}
+	return ResponsePack(Success, result)
+}
+
+func GetAddressDeltas(param Params) map[string]interface{} {
+	if rtn := checkRPCServiceLevel(config.WalletPermitted); rtn != nil {
+		return rtn
+	}
+
+	address, entries, next, rtn := fetchAddressHistory(param)
+	if rtn != nil {
+		return rtn
+	}
+
+	result := AddressHistoryPage{
+		Address:    address,
+		Items:      make([]interface{}, 0, len(entries)),
+		NextCursor: next,
+	}
+	for _, entry := range entries {
+		result.Items = append(result.Items, AddressDeltaInfo{
+			TxID:     common.ToReversedString(entry.TxID),
+			Height:   entry.Height,
+			Position: entry.Position,
+			Received: entry.Received.String(),
+			Sent:     entry.Sent.String(),
+			Delta:    (entry.Received - entry.Sent).String(),
+		})
+	}
+	return ResponsePack(Success, result)
+}
+
 func CreateRawTransaction(param Params) map[string]interface{} {
 	if rtn := checkRPCServiceLevel(config.WalletPermitted); rtn != nil {
 		return rtn
@@ -1926,6 +2509,237 @@ func SignRawTransactionWithKey(param Params) map[string]interface{} {
 	return ResponsePack(Success, common.BytesToHexString(result.Bytes()))
 }
 
+type RPCPSBTInput struct {
+	TxID    string `json:"txid"`
+	VOut    uint16 `json:"vout"`
+	Address string `json:"address"`
+	Amount  string `json:"amount"`
+}
+
+type RPCPSBTSignature struct {
+	PublicKey string `json:"publickey"`
+	Signature string `json:"signature"`
+}
+
+type RPCPSBTProgram struct {
+	Address    string             `json:"address"`
+	Code       string             `json:"code"`
+	Signed     int                `json:"signed"`
+	Required   int                `json:"required"`
+	Signatures []RPCPSBTSignature `json:"signatures"`
+}
+
+type RPCPSBT struct {
+	TxID     string           `json:"txid"`
+	Tx       interface{}      `json:"tx"`
+	Inputs   []RPCPSBTInput   `json:"inputs"`
+	Programs []RPCPSBTProgram `json:"programs"`
+	Fee      string           `json:"fee"`
+	Complete bool             `json:"complete"`
+}
+
+// getPSBTParam decodes the partially signed transaction of the parameter.
+func getPSBTParam(param Params, name string) (*psbt.Packet, map[string]interface{}) {
+	data, ok := param.String(name)
+	if !ok {
+		return nil, ResponsePack(InvalidParams, "need a parameter named "+name)
+	}
+	packet, err := psbt.Decode(data)
+	if err != nil {
+		return nil, ResponsePack(InvalidParams, err.Error())
+	}
+	return packet, nil
+}
+
+func psbtResponse(packet *psbt.Packet) map[string]interface{} {
+	result, err := packet.Encode()
+	if err != nil {
+		return ResponsePack(InternalError, err.Error())
+	}
+	return ResponsePack(Success, result)
+}
+
+func CreatePSBT(param Params) map[string]interface{} {
+	if rtn := checkRPCServiceLevel(config.WalletPermitted); rtn != nil {
+		return rtn
+	}
+
+	dataParam, ok := param.String("data")
+	if !ok {
+		return ResponsePack(InvalidParams, "need a parameter named data")
+	}
+	txBytes, err := common.HexStringToBytes(dataParam)
+	if err != nil {
+		return ResponsePack(InvalidParams, "hex string to bytes error")
+	}
+	r := bytes.NewReader(txBytes)
+	txn, err := functions.GetTransactionByBytes(r)
+	if err != nil {
+		return ResponsePack(InvalidTransaction, "invalid transaction")
+	}
+	if err := txn.Deserialize(r); err != nil {
+		return ResponsePack(InvalidTransaction, err.Error())
+	}
+
+	var codes [][]byte
+	if codesParam, ok := param.ArrayString("codes"); ok {
+		for _, codeStr := range codesParam {
+			code, err := common.HexStringToBytes(codeStr)
+			if err != nil {
+				return ResponsePack(InvalidParams, "invalid params codes")
+			}
+			codes = append(codes, code)
+		}
+	}
+
+	utxos := make([]*psbt.UTXO, 0, len(txn.Inputs()))
+	for _, input := range txn.Inputs() {
+		reference, err := Chain.UTXOCache.GetTransaction(input.Previous.TxID)
+		if err != nil {
+			return ResponsePack(UnknownTransaction, "unknown referenced transaction "+
+				common.ToReversedString(input.Previous.TxID))
+		}
+		if int(input.Previous.Index) >= len(reference.Outputs()) {
+			return ResponsePack(InvalidTransaction, "invalid input index of "+
+				common.ToReversedString(input.Previous.TxID))
+		}
+		utxos = append(utxos, &psbt.UTXO{
+			TxVersion: reference.Version(),
+			Output:    reference.Outputs()[input.Previous.Index],
+		})
+	}
+
+	packet, err := psbt.New(txn, utxos, codes)
+	if err != nil {
+		return ResponsePack(InvalidParams, err.Error())
+	}
+	return psbtResponse(packet)
+}
+
+func DecodePSBT(param Params) map[string]interface{} {
+	packet, rtn := getPSBTParam(param, "data")
+	if rtn != nil {
+		return rtn
+	}
+
+	result := RPCPSBT{
+		TxID:     common.ToReversedString(packet.Tx.Hash()),
+		Tx:       GetTransactionInfo(packet.Tx),
+		Inputs:   make([]RPCPSBTInput, 0, len(packet.UTXOs)),
+		Programs: make([]RPCPSBTProgram, 0, len(packet.Programs)),
+		Fee:      packet.Fee().String(),
+		Complete: packet.IsComplete(),
+	}
+	for i, utxo := range packet.UTXOs {
+		input := packet.Tx.Inputs()[i]
+		address, _ := utxo.Output.ProgramHash.ToAddress()
+		result.Inputs = append(result.Inputs, RPCPSBTInput{
+			TxID:    common.ToReversedString(input.Previous.TxID),
+			VOut:    input.Previous.Index,
+			Address: address,
+			Amount:  utxo.Output.Value.String(),
+		})
+	}
+	for _, program := range packet.Programs {
+		have, need, err := program.Status()
+		if err != nil {
+			return ResponsePack(InvalidParams, err.Error())
+		}
+		prefix := contract.PrefixStandard
+		if len(program.Code) != crypto.PublicKeyScriptLength {
+			prefix = contract.PrefixMultiSig
+		}
+		address, _ := common.ToProgramHash(byte(prefix), program.Code).ToAddress()
+		p := RPCPSBTProgram{
+			Address:    address,
+			Code:       common.BytesToHexString(program.Code),
+			Signed:     have,
+			Required:   need,
+			Signatures: make([]RPCPSBTSignature, 0, len(program.Signatures)),
+		}
+		for _, s := range program.Signatures {
+			p.Signatures = append(p.Signatures, RPCPSBTSignature{
+				PublicKey: common.BytesToHexString(s.PublicKey),
+				Signature: common.BytesToHexString(s.Signature),
+			})
+		}
+		result.Programs = append(result.Programs, p)
+	}
+	return ResponsePack(Success, result)
+}
+
+func SignPSBTWithKey(param Params) map[string]interface{} {
+	if rtn := checkRPCServiceLevel(config.WalletPermitted); rtn != nil {
+		return rtn
+	}
+
+	packet, rtn := getPSBTParam(param, "data")
+	if rtn != nil {
+		return rtn
+	}
+	privkeys, ok := param.ArrayString("privkeys")
+	if !ok {
+		return ResponsePack(InvalidParams, "need privkeys in an array")
+	}
+	accounts := make(map[common.Uint160]*account.Account)
+	for _, privkeyStr := range privkeys {
+		privkey, err := common.HexStringToBytes(privkeyStr)
+		if err != nil {
+			return ResponsePack(InvalidParams, err.Error())
+		}
+		acc, err := account.NewAccountWithPrivateKey(privkey)
+		if err != nil {
+			return ResponsePack(InvalidParams, err.Error())
+		}
+		accounts[acc.ProgramHash.ToCodeHash()] = acc
+	}
+
+	if _, err := packet.Sign(func(codeHash common.Uint160) *account.Account {
+		return accounts[codeHash]
+	}); err != nil {
+		return ResponsePack(InternalError, err.Error())
+	}
+	return psbtResponse(packet)
+}
+
+func CombinePSBT(param Params) map[string]interface{} {
+	psbts, ok := param.ArrayString("psbts")
+	if !ok || len(psbts) == 0 {
+		return ResponsePack(InvalidParams, "need psbts in an array")
+	}
+	packets := make([]*psbt.Packet, 0, len(psbts))
+	for _, data := range psbts {
+		packet, err := psbt.Decode(data)
+		if err != nil {
+			return ResponsePack(InvalidParams, err.Error())
+		}
+		packets = append(packets, packet)
+	}
+
+	combined, err := psbt.Combine(packets...)
+	if err != nil {
+		return ResponsePack(InvalidParams, err.Error())
+	}
+	return psbtResponse(combined)
+}
+
+func FinalizePSBT(param Params) map[string]interface{} {
+	packet, rtn := getPSBTParam(param, "data")
+	if rtn != nil {
+		return rtn
+	}
+	txn, err := packet.Finalize()
+	if err != nil {
+		return ResponsePack(InvalidParams, err.Error())
+	}
+
+	buf := new(bytes.Buffer)
+	if err := txn.Serialize(buf); err != nil {
+		return ResponsePack(InternalError, err.Error())
+	}
+	return ResponsePack(Success, common.BytesToHexString(buf.Bytes()))
+}
+
 func GetUnspends(param Params) map[string]interface{} {
 	address, ok := param.String("addr")
 	if !ok {
@@ -2361,6 +3175,66 @@ func DposV2RewardInfo(param Params) map[string]interface{} {
 	}
 }
 
+type RPCDPoSV2RewardSimulation struct {
+	Height               uint32  `json:"height"`
+	VoteRights           string  `json:"voterights"`
+	ProducerVoteRights   string  `json:"producervoterights"`
+	Effective            bool    `json:"effective"`
+	InCurrentRound       bool    `json:"incurrentround"`
+	ProducerVotesInRound string  `json:"producervotesinround"`
+	TotalVotesInRound    string  `json:"totalvotesinround"`
+	RoundBlocks          uint32  `json:"roundblocks"`
+	BlockReward          string  `json:"blockreward"`
+	RoundReward          string  `json:"roundreward"`
+	AnnualReward         string  `json:"annualreward"`
+	AnnualRate           float64 `json:"annualrate"`
+}
+
+// SimulateDPoSV2Reward projects the reward of a hypothetical DPoS v2 vote to
+// a producer in the current round and in a year.
+func SimulateDPoSV2Reward(param Params) map[string]interface{} {
+	amountStr, ok := param.String("amount")
+	if !ok {
+		return ResponsePack(InvalidParams, "need a parameter named amount")
+	}
+	amount, err := common.StringToFixed64(amountStr)
+	if err != nil {
+		return ResponsePack(InvalidParams, "invalid amount")
+	}
+	lockUntil, ok := param.Uint("lockuntil")
+	if !ok {
+		return ResponsePack(InvalidParams, "need a parameter named lockuntil")
+	}
+	publicKey, ok := param.String("publickey")
+	if !ok {
+		return ResponsePack(InvalidParams, "need a parameter named publickey")
+	}
+	publicKeyBytes, err := common.HexStringToBytes(publicKey)
+	if err != nil {
+		return ResponsePack(InvalidParams, "invalid public key")
+	}
+
+	s, err := Arbiters.SimulateDPoSV2Reward(publicKeyBytes, *amount, lockUntil)
+	if err != nil {
+		return ResponsePack(InvalidParams, err.Error())
+	}
+	result := RPCDPoSV2RewardSimulation{
+		Height:               s.Height,
+		VoteRights:           common.Fixed64(s.VoteRights).String(),
+		ProducerVoteRights:   common.Fixed64(s.ProducerVoteRights).String(),
+		Effective:            s.Effective,
+		InCurrentRound:       s.InCurrentRound,
+		ProducerVotesInRound: s.ProducerVotesInRound.String(),
+		TotalVotesInRound:    s.TotalVotesInRound.String(),
+		RoundBlocks:          s.RoundBlocks,
+		BlockReward:          s.BlockReward.String(),
+		RoundReward:          s.RoundReward.String(),
+		AnnualReward:         s.AnnualReward.String(),
+		AnnualRate:           s.AnnualRate,
+	}
+	return ResponsePack(Success, result)
+}
+
 func GetDPosV2Info(param Params) map[string]interface{} {
 	result := &RPCDPosV2Info{
 		ConsensusAlgorithm: Chain.GetState().GetConsensusAlgorithm().String(),
@@ -2383,27 +3257,32 @@ func ListProducers(param Params) map[string]interface{} {
 	if ok {
 		s = strings.ToLower(s)
 	}
+	historyState, resp := getHistoryState(param)
+	if resp != nil {
+		return resp
+	}
+
 	var producers []*state.Producer
 	switch s {
 	case "all":
-		ps := Chain.GetState().GetAllProducers()
+		ps := historyState.State.GetAllProducers()
 		for i, _ := range ps {
 			producers = append(producers, &ps[i])
 		}
 	case "pending":
-		producers = Chain.GetState().GetPendingProducers()
+		producers = historyState.State.GetPendingProducers()
 	case "active":
-		producers = Chain.GetState().GetActiveProducers()
+		producers = historyState.State.GetActiveProducers()
 	case "inactive":
-		producers = Chain.GetState().GetInactiveProducers()
+		producers = historyState.State.GetInactiveProducers()
 	case "canceled":
-		producers = Chain.GetState().GetCanceledProducers()
+		producers = historyState.State.GetCanceledProducers()
 	case "illegal":
-		producers = Chain.GetState().GetIllegalProducers()
+		producers = historyState.State.GetIllegalProducers()
 	case "returned":
-		producers = Chain.GetState().GetReturnedDepositProducers()
+		producers = historyState.State.GetReturnedDepositProducers()
 	default:
-		producers = Chain.GetState().GetProducers()
+		producers = historyState.State.GetProducers()
 	}
 
 	sort.Slice(producers, func(i, j int) bool {
@@ -2526,7 +3405,11 @@ func ListCRCandidates(param Params) map[string]interface{} {
 		s = strings.ToLower(s)
 	}
 	var candidates []*crstate.Candidate
-	crCommittee := Chain.GetCRCommittee()
+	historyState, resp := getHistoryState(param)
+	if resp != nil {
+		return resp
+	}
+	crCommittee := historyState.Committee
 	switch s {
 	case "all":
 		candidates = crCommittee.GetAllCandidates()
@@ -2602,7 +3485,11 @@ func ListCRCandidates(param Params) map[string]interface{} {
 
 // list current crs according to (state)
 func ListCurrentCRs(param Params) map[string]interface{} {
-	cm := Chain.GetCRCommittee()
+	historyState, resp := getHistoryState(param)
+	if resp != nil {
+		return resp
+	}
+	cm := historyState.Committee
 	var crMembers []*crstate.CRMember
 	if cm.IsInElectionPeriod() {
 		crMembers = cm.GetCurrentMembers()
@@ -2792,7 +3679,11 @@ func ListCRProposalBaseState(param Params) map[string]interface{} {
 
 func GetCRProposalState(param Params) map[string]interface{} {
 	var proposalState *crstate.ProposalState
-	crCommittee := Chain.GetCRCommittee()
+	historyState, resp := getHistoryState(param)
+	if resp != nil {
+		return resp
+	}
+	crCommittee := historyState.Committee
 	ProposalHashHexStr, ok := param.String("proposalhash")
 	if ok {
 		proposalHashBytes, err := common.FromReversedString(ProposalHashHexStr)
@@ -2973,6 +3864,201 @@ func GetCRProposalState(param Params) map[string]interface{} {
 	return ResponsePack(Success, result)
 }
 
+type RPCCRProposalEvent struct {
+	Height             uint32 `json:"height"`
+	TxID               string `json:"txid,omitempty"`
+	Event              string `json:"event"`
+	Status             string `json:"status,omitempty"`
+	DID                string `json:"did,omitempty"`
+	Opinion            string `json:"opinion,omitempty"`
+	TrackingType       string `json:"trackingtype,omitempty"`
+	Stage              *uint8 `json:"stage,omitempty"`
+	Amount             string `json:"amount,omitempty"`
+	ApproveCount       *int   `json:"approvecount,omitempty"`
+	RejectCount        *int   `json:"rejectcount,omitempty"`
+	AbstainCount       *int   `json:"abstaincount,omitempty"`
+	VotersRejectAmount string `json:"votersrejectamount,omitempty"`
+}
+
+type RPCCRProposalTimeline struct {
+	ProposalHash    string               `json:"proposalhash"`
+	Status          string               `json:"status"`
+	RegisterHeight  uint32               `json:"registerheight"`
+	VoteStartHeight uint32               `json:"votestartheight"`
+	Events          []RPCCRProposalEvent `json:"events"`
+}
+
+// getCRProposalStatusEvents returns the status transitions of the proposal
+// driven by height instead of transactions, which are the ends of the CR
+// voting and public voting along with the termination by a close proposal.
+// The heights of aborted proposals are unknown, so they are not included.
+func getCRProposalStatusEvents(proposalState *crstate.ProposalState,
+	events []*indexers.CRProposalEvent) []RPCCRProposalEvent {
+	var finalized, terminatedByTracking bool
+	for _, e := range events {
+		if e.Type != indexers.CRProposalTracked {
+			continue
+		}
+		switch e.TrackingType {
+		case payload.Finalized:
+			finalized = true
+		case payload.Terminated:
+			terminatedByTracking = true
+		}
+	}
+
+	var result []RPCCRProposalEvent
+	status := proposalState.Status
+	crVoteEnd := proposalState.RegisterHeight +
+		ChainParams.CRConfiguration.ProposalCRVotingPeriod
+	if proposalState.VoteStartHeight != 0 || status == crstate.CRCanceled {
+		var approve, reject, abstain int
+		for _, v := range proposalState.CRVotes {
+			switch v {
+			case payload.Approve:
+				approve++
+			case payload.Reject:
+				reject++
+			case payload.Abstain:
+				abstain++
+			}
+		}
+		event := RPCCRProposalEvent{
+			Height:       crVoteEnd,
+			Event:        "StatusChanged",
+			Status:       crstate.CRCanceled.String(),
+			ApproveCount: &approve,
+			RejectCount:  &reject,
+			AbstainCount: &abstain,
+		}
+		if proposalState.VoteStartHeight != 0 {
+			event.Height = proposalState.VoteStartHeight
+			event.Status = crstate.CRAgreed.String()
+		}
+		result = append(result, event)
+	}
+
+	if proposalState.VoteStartHeight != 0 && status != crstate.CRAgreed &&
+		status != crstate.Aborted {
+		event := RPCCRProposalEvent{
+			Height: proposalState.VoteStartHeight +
+				ChainParams.CRConfiguration.ProposalPublicVotingPeriod,
+			Event:              "StatusChanged",
+			Status:             crstate.VoterAgreed.String(),
+			VotersRejectAmount: proposalState.VotersRejectAmount.String(),
+		}
+		if status == crstate.VoterCanceled {
+			event.Status = crstate.VoterCanceled.String()
+		} else if status == crstate.Finished && !finalized {
+			event.Status = crstate.Finished.String()
+		}
+		result = append(result, event)
+	}
+
+	if status == crstate.Terminated && !terminatedByTracking {
+		result = append(result, RPCCRProposalEvent{
+			Height: proposalState.TerminatedHeight,
+			Event:  "StatusChanged",
+			Status: crstate.Terminated.String(),
+		})
+	}
+	return result
+}
+
+// GetCRProposalTimeline returns the lifecycle of a CR proposal, which are
+// the transactions changing it along with the status transitions.
+func GetCRProposalTimeline(param Params) map[string]interface{} {
+	var proposalState *crstate.ProposalState
+	crCommittee := Chain.GetCRCommittee()
+	if proposalHashStr, ok := param.String("proposalhash"); ok {
+		proposalHashBytes, err := common.FromReversedString(proposalHashStr)
+		if err != nil {
+			return ResponsePack(InvalidParams, "invalidate proposalhash")
+		}
+		proposalHash, err := common.Uint256FromBytes(proposalHashBytes)
+		if err != nil {
+			return ResponsePack(InvalidParams, "invalidate proposalhash")
+		}
+		proposalState = crCommittee.GetProposal(*proposalHash)
+		if proposalState == nil {
+			return ResponsePack(InvalidParams, "proposalhash not exist")
+		}
+	} else {
+		draftHashStr, ok := param.String("drafthash")
+		if !ok {
+			return ResponsePack(InvalidParams, "params at least one of proposalhash and DraftHash")
+		}
+		draftHashBytes, err := common.FromReversedString(draftHashStr)
+		if err != nil {
+			return ResponsePack(InvalidParams, "invalidate drafthash")
+		}
+		draftHash, err := common.Uint256FromBytes(draftHashBytes)
+		if err != nil {
+			return ResponsePack(InvalidParams, "invalidate drafthash")
+		}
+		proposalState = crCommittee.GetProposalByDraftHash(*draftHash)
+		if proposalState == nil {
+			return ResponsePack(InvalidParams, "DraftHash not exist")
+		}
+	}
+
+	proposalHash := proposalState.Proposal.Hash
+	events, err := Store.GetFFLDB().GetCRProposalEvents(proposalHash)
+	if err != nil {
+		if err == indexers.ErrCRProposalTimelineIndexDisabled {
+			return ResponsePack(InvalidMethod, err.Error())
+		}
+		return ResponsePack(InternalError,
+			"get CR proposal events failed, "+err.Error())
+	}
+
+	// Reject votes are counted only during the public voting.
+	publicVoteEnd := proposalState.VoteStartHeight +
+		ChainParams.CRConfiguration.ProposalPublicVotingPeriod
+	rpcEvents := make([]RPCCRProposalEvent, 0, len(events))
+	for _, e := range events {
+		if e.Type == indexers.CRProposalRejectVoted &&
+			(proposalState.VoteStartHeight == 0 ||
+				e.Height < proposalState.VoteStartHeight ||
+				e.Height > publicVoteEnd) {
+			continue
+		}
+		event := RPCCRProposalEvent{
+			Height: e.Height,
+			TxID:   common.ToReversedString(e.TxID),
+			Event:  e.Type.Name(),
+		}
+		switch e.Type {
+		case indexers.CRProposalRegistered:
+			event.DID, _ = e.DID.ToAddress()
+		case indexers.CRProposalReviewed:
+			event.DID, _ = e.DID.ToAddress()
+			event.Opinion = e.Opinion.Name()
+		case indexers.CRProposalTracked:
+			stage := e.Stage
+			event.TrackingType = e.TrackingType.Name()
+			event.Stage = &stage
+		case indexers.CRProposalRejectVoted, indexers.CRProposalWithdrawn,
+			indexers.CRProposalRealWithdrawn:
+			event.Amount = e.Amount.String()
+		}
+		rpcEvents = append(rpcEvents, event)
+	}
+	rpcEvents = append(rpcEvents,
+		getCRProposalStatusEvents(proposalState, events)...)
+	sort.SliceStable(rpcEvents, func(i, j int) bool {
+		return rpcEvents[i].Height < rpcEvents[j].Height
+	})
+
+	return ResponsePack(Success, RPCCRProposalTimeline{
+		ProposalHash:    common.ToReversedString(proposalHash),
+		Status:          proposalState.Status.String(),
+		RegisterHeight:  proposalState.RegisterHeight,
+		VoteStartHeight: proposalState.VoteStartHeight,
+		Events:          rpcEvents,
+	})
+}
+
 func GetProposalDraftData(param Params) map[string]interface{} {
 	hash, ok := param.String("drafthash")
 	if !ok {
@@ -3165,16 +4251,94 @@ func EstimateSmartFee(param Params) map[string]interface{} {
 	if !ok {
 		return ResponsePack(InvalidParams, "need a param called confirmations")
 	}
-	if confirm > 25 {
+	if confirm > int64(mempool.MaxConfirmationTarget) {
 		return ResponsePack(InvalidParams, "support only 25 confirmations at most")
 	}
-	var FeeRate = 10000 //basic fee rate 10000 sela per KB
-	var count = 0
+	if confirm < 1 {
+		confirm = 1
+	}
+	confidence, ok := param.Float("confidence")
+	if !ok {
+		confidence = mempool.DefaultFeeConfidence
+	}
+	if confidence <= 0 || confidence > 1 {
+		return ResponsePack(InvalidParams, "confidence should be in (0, 1]")
+	}
+	verbose, _ := param.Bool("verbose")
+
+	info := SmartFeeInfo{
+		Blocks:     uint32(confirm),
+		Confidence: confidence,
+	}
+	estimate, ok := TxMemPool.EstimateFee(uint32(confirm), confidence)
+	if ok {
+		info.FeeRate = int64(estimate.FeeRate)
+		info.SuccessRate = estimate.SuccessRate
+		info.Samples = estimate.Samples
+		info.Estimated = true
+	} else {
+		// Fall back to the basic fee rate if there are not enough
+		// transactions to estimate.
+		var FeeRate = 10000 //basic fee rate 10000 sela per KB
+		var count = 0
+		info.FeeRate = int64(GetFeeRate(count, int(confirm)) * FeeRate)
+	}
+
+	if !verbose {
+		return ResponsePack(Success, info.FeeRate)
+	}
+	return ResponsePack(Success, info)
+}
+
+func GetMempoolInfo(param Params) map[string]interface{} {
+	info := TxMemPool.GetMempoolInfo()
+	result := MempoolInfo{
+		Size:         info.Count,
+		Bytes:        info.Size,
+		MaxBytes:     info.MaxSize,
+		FeeHistogram: make([]FeeHistogramInfo, 0, len(info.FeeHistogram)),
+		TxTypes:      make(map[string]uint32, len(info.TxTypes)),
+	}
+	for _, bucket := range info.FeeHistogram {
+		result.FeeHistogram = append(result.FeeHistogram, FeeHistogramInfo{
+			MinFeeRate: int64(bucket.MinFeeRate),
+			MaxFeeRate: int64(bucket.MaxFeeRate),
+			Count:      bucket.Count,
+			Size:       bucket.Size,
+		})
+	}
+	for txType, count := range info.TxTypes {
+		result.TxTypes[txType.Name()] = count
+	}
+
+	return ResponsePack(Success, result)
+}
+
+func SaveMempool(param Params) map[string]interface{} {
+	if rtn := checkRPCServiceLevel(config.TransactionPermitted); rtn != nil {
+		return rtn
+	}
+
+	saved, err := TxMemPool.SaveMempool()
+	if err != nil {
+		return ResponsePack(InternalError, "save mempool failed, "+err.Error())
+	}
+	return ResponsePack(Success, SaveMempoolInfo{Saved: saved})
+}
 
-	// TODO just return fixed transaction fee for now, we didn't have that much
-	// transactions in a block yet.
+func LoadMempool(param Params) map[string]interface{} {
+	if rtn := checkRPCServiceLevel(config.TransactionPermitted); rtn != nil {
+		return rtn
+	}
 
-	return ResponsePack(Success, GetFeeRate(count, int(confirm))*FeeRate)
+	accepted, dropped, err := TxMemPool.LoadMempool()
+	if err != nil {
+		return ResponsePack(InternalError, "load mempool failed, "+err.Error())
+	}
+	return ResponsePack(Success, LoadMempoolInfo{
+		Accepted: accepted,
+		Dropped:  dropped,
+	})
 }
 
 func GetFeeRate(count int, confirm int) int {
@@ -0,0 +1,172 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package unit
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/blockchain/indexers"
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/common/log"
+	"github.com/elastos/Elastos.ELA/database"
+	"github.com/elastos/Elastos.ELA/utils/test"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testAddrHistoryIndex *indexers.AddrHistoryIndex
+	addrHistoryIndexDB   database.DB
+)
+
+func TestAddrHistoryIndexInit(t *testing.T) {
+	log.NewDefault(test.NodeLogPath, 0, 0, 0)
+
+	var err error
+	addrHistoryIndexDB, err = LoadBlockDB(test.DataPath)
+	assert.NoError(t, err)
+	txStore := NewTestTxStore()
+	txStore.SetTx(testUtxoIndexReferTx, referHeight)
+
+	testAddrHistoryIndex = indexers.NewAddrHistoryIndex(addrHistoryIndexDB, txStore)
+	assert.NotEqual(t, nil, testAddrHistoryIndex)
+	assert.Equal(t, []byte("addrhistoryidx"), testAddrHistoryIndex.Key())
+	assert.Equal(t, "address history index", testAddrHistoryIndex.Name())
+	_ = addrHistoryIndexDB.Update(func(dbTx database.Tx) error {
+		err := testAddrHistoryIndex.Create(dbTx)
+		assert.NoError(t, err)
+		return nil
+	})
+}
+
+func TestAddrHistoryIndex_ConnectBlock(t *testing.T) {
+	_ = addrHistoryIndexDB.Update(func(dbTx database.Tx) error {
+		err := testAddrHistoryIndex.ConnectBlock(dbTx, testUtxoIndexBlock)
+		assert.NoError(t, err)
+		return nil
+	})
+
+	_ = addrHistoryIndexDB.View(func(dbTx database.Tx) error {
+		// inputs should be recorded as sent by the refer addresses
+		entries, next, err := indexers.DBFetchAddrHistoryEntries(dbTx,
+			referRecipient1, nil, 10)
+		assert.NoError(t, err)
+		assert.Nil(t, next)
+		assert.Equal(t, []*indexers.AddressHistoryEntry{
+			{
+				TxID:     testUtxoIndexTx2.Hash(),
+				Height:   testUtxoIndexBlock.Height,
+				Position: 1,
+				Sent:     100,
+			},
+		}, entries)
+		entries, next, err = indexers.DBFetchAddrHistoryEntries(dbTx,
+			referRecipient2, nil, 10)
+		assert.NoError(t, err)
+		assert.Nil(t, next)
+		assert.Equal(t, []*indexers.AddressHistoryEntry{
+			{
+				TxID:     testUtxoIndexTx2.Hash(),
+				Height:   testUtxoIndexBlock.Height,
+				Position: 1,
+				Sent:     200,
+			},
+		}, entries)
+
+		// outputs should be recorded as received by the recipients
+		entries, next, err = indexers.DBFetchAddrHistoryEntries(dbTx,
+			recipient1, nil, 10)
+		assert.NoError(t, err)
+		assert.Nil(t, next)
+		assert.Equal(t, []*indexers.AddressHistoryEntry{
+			{
+				TxID:     testUtxoIndexTx1.Hash(),
+				Height:   testUtxoIndexBlock.Height,
+				Position: 0,
+				Received: 30,
+			},
+			{
+				TxID:     testUtxoIndexTx2.Hash(),
+				Height:   testUtxoIndexBlock.Height,
+				Position: 1,
+				Received: 30,
+			},
+		}, entries)
+		entries, next, err = indexers.DBFetchAddrHistoryEntries(dbTx,
+			recipient2, nil, 10)
+		assert.NoError(t, err)
+		assert.Nil(t, next)
+		assert.Equal(t, []*indexers.AddressHistoryEntry{
+			{
+				TxID:     testUtxoIndexTx2.Hash(),
+				Height:   testUtxoIndexBlock.Height,
+				Position: 1,
+				Received: 40,
+			},
+		}, entries)
+
+		return nil
+	})
+}
+
+func TestAddrHistoryIndex_Paging(t *testing.T) {
+	_ = addrHistoryIndexDB.View(func(dbTx database.Tx) error {
+		entries, next, err := indexers.DBFetchAddrHistoryEntries(dbTx,
+			recipient1, nil, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(entries))
+		assert.Equal(t, testUtxoIndexTx1.Hash(), entries[0].TxID)
+		assert.NotNil(t, next)
+
+		entries, next, err = indexers.DBFetchAddrHistoryEntries(dbTx,
+			recipient1, next, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(entries))
+		assert.Equal(t, testUtxoIndexTx2.Hash(), entries[0].TxID)
+		assert.Nil(t, next)
+
+		_, _, err = indexers.DBFetchAddrHistoryEntries(dbTx,
+			recipient1, []byte{0x01}, 1)
+		assert.Error(t, err)
+
+		unknown := common.Uint168{0x21}
+		entries, next, err = indexers.DBFetchAddrHistoryEntries(dbTx,
+			&unknown, nil, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(entries))
+		assert.Nil(t, next)
+
+		return nil
+	})
+}
+
+func TestAddrHistoryIndex_DisconnectBlock(t *testing.T) {
+	_ = addrHistoryIndexDB.Update(func(dbTx database.Tx) error {
+		err := testAddrHistoryIndex.DisconnectBlock(dbTx, testUtxoIndexBlock)
+		assert.NoError(t, err)
+
+		for _, programHash := range []*common.Uint168{referRecipient1,
+			referRecipient2, recipient1, recipient2} {
+			entries, next, err := indexers.DBFetchAddrHistoryEntries(dbTx,
+				programHash, nil, 10)
+			assert.NoError(t, err)
+			assert.Nil(t, next)
+			assert.Equal(t, 0, len(entries))
+		}
+
+		return nil
+	})
+}
+
+func TestAddrHistoryIndexEnd(t *testing.T) {
+	_ = addrHistoryIndexDB.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		err := meta.DeleteBucket(indexers.AddrHistoryIndexKey)
+		assert.NoError(t, err)
+		return nil
+	})
+	addrHistoryIndexDB.Close()
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package elalog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Keys of the structured fields shared by all subsystems, log pipelines can
+// rely on the same key having the same meaning in every log message.
+const (
+	FieldTime      = "time"
+	FieldLevel     = "level"
+	FieldSubsystem = "subsystem"
+	FieldMessage   = "msg"
+	FieldCaller    = "caller"
+	FieldHeight    = "height"
+	FieldPeer      = "peer"
+	FieldTxHash    = "txhash"
+	FieldErrCode   = "errcode"
+)
+
+// jsonTimeFormat is the time format of the time field in JSON messages.
+const jsonTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// levelNames defines the names of each logging level in JSON messages.
+var levelNames = [...]string{"debug", "info", "warn", "error", "fatal", "off"}
+
+// Format is the output format of log messages.
+type Format uint8
+
+const (
+	// FormatText writes log messages as free-text lines.
+	FormatText Format = iota
+
+	// FormatJSON writes log messages as JSON objects, one object per line.
+	FormatJSON
+)
+
+// FormatFromString returns a format based on the input string s.  If the
+// input can't be interpreted as a valid format, the text format and false is
+// returned.
+func FormatFromString(s string) (Format, bool) {
+	switch strings.ToLower(s) {
+	case "", "text":
+		return FormatText, true
+	case "json":
+		return FormatJSON, true
+	default:
+		return FormatText, false
+	}
+}
+
+// String returns the name of the format.
+func (f Format) String() string {
+	if f == FormatJSON {
+		return "json"
+	}
+	return "text"
+}
+
+// Field is a structured field attached to log messages.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Height returns the field of a block height.
+func Height(height uint32) Field {
+	return Field{Key: FieldHeight, Value: height}
+}
+
+// Peer returns the field of a peer address.
+func Peer(addr string) Field {
+	return Field{Key: FieldPeer, Value: addr}
+}
+
+// TxHash returns the field of a transaction hash.
+func TxHash(hash fmt.Stringer) Field {
+	return Field{Key: FieldTxHash, Value: hash.String()}
+}
+
+// ErrCode returns the field of an error code.
+func ErrCode(code int) Field {
+	return Field{Key: FieldErrCode, Value: code}
+}
+
+// AppendText appends the fields to buf as space separated key=value pairs.
+func AppendText(buf []byte, fields []Field) []byte {
+	for _, f := range fields {
+		buf = append(buf, ' ')
+		buf = append(buf, f.Key...)
+		buf = append(buf, '=')
+		buf = append(buf, fmt.Sprint(fieldValue(f.Value))...)
+	}
+	return buf
+}
+
+// AppendJSON appends a log message to buf as a JSON object without the
+// trailing newline.  The subsystem and caller are omitted if empty.
+func AppendJSON(buf []byte, t time.Time, level Level, subsystem, caller,
+	msg string, fields []Field) []byte {
+	name := levelNames[LevelOff]
+	if level < LevelOff {
+		name = levelNames[level]
+	}
+
+	buf = append(buf, '{')
+	buf = appendJSONMember(buf, FieldTime, t.Format(jsonTimeFormat))
+	buf = append(buf, ',')
+	buf = appendJSONMember(buf, FieldLevel, name)
+	if subsystem != "" {
+		buf = append(buf, ',')
+		buf = appendJSONMember(buf, FieldSubsystem, subsystem)
+	}
+	if caller != "" {
+		buf = append(buf, ',')
+		buf = appendJSONMember(buf, FieldCaller, caller)
+	}
+	buf = append(buf, ',')
+	buf = appendJSONMember(buf, FieldMessage, msg)
+	for _, f := range fields {
+		buf = append(buf, ',')
+		buf = appendJSONMember(buf, f.Key, fieldValue(f.Value))
+	}
+	return append(buf, '}')
+}
+
+func appendJSONMember(buf []byte, key string, value interface{}) []byte {
+	buf = appendJSONValue(buf, key)
+	buf = append(buf, ':')
+	return appendJSONValue(buf, value)
+}
+
+// appendJSONValue appends the value encoded by encoding/json without escaping
+// HTML characters, and encodes the value as a string if it fails.
+func appendJSONValue(buf []byte, value interface{}) []byte {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		b.Reset()
+		enc.Encode(fmt.Sprint(value))
+	}
+	return append(buf, bytes.TrimSuffix(b.Bytes(), []byte{'\n'})...)
+}
+
+// fieldValue returns the message of errors and the string of Stringers, which
+// are encoded as empty objects by encoding/json otherwise.
+func fieldValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return value
+}
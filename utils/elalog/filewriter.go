@@ -31,6 +31,10 @@ type fileWriter struct {
 	maxFileSize   int64
 	maxFolderSize int64
 
+	// rotateInterval is the interval to create a new log file, new log files
+	// are created by size only if it is 0.
+	rotateInterval time.Duration
+
 	writeChan  chan []byte
 	writeReply chan struct{}
 }
@@ -45,6 +49,7 @@ func (w *fileWriter) writeHandler() {
 	var current *os.File
 	var fileSize int64
 	var folderSize int64
+	var rotateTime time.Time
 
 	files, _ := ioutil.ReadDir(w.path)
 	for _, f := range files {
@@ -55,9 +60,11 @@ func (w *fileWriter) writeHandler() {
 		buf := <-w.writeChan
 		var bufLen = int64(len(buf))
 
-		// create new log file if current file is nil or reach max size.
+		// create new log file if current file is nil, reach max size or
+		// reach the rotate time.
 		if atomic.AddInt64(&fileSize, bufLen) >= w.maxFileSize ||
-			current == nil {
+			current == nil || w.rotateInterval > 0 &&
+			!time.Now().Before(rotateTime) {
 
 			// Create new log file
 			file, err := newLogFile(w.path)
@@ -79,6 +86,9 @@ func (w *fileWriter) writeHandler() {
 
 			current = file
 			atomic.StoreInt64(&fileSize, 0)
+			if w.rotateInterval > 0 {
+				rotateTime = nextRotateTime(time.Now(), w.rotateInterval)
+			}
 		}
 
 		// force write buffer to file.
@@ -124,6 +134,13 @@ func (w *fileWriter) writeHandler() {
 	}
 }
 
+// nextRotateTime returns the next time after t which is a multiple of the
+// rotate interval since the zero time, so an hourly interval rotates log files
+// on the hour, and a daily interval rotates log files at midnight UTC.
+func nextRotateTime(t time.Time, interval time.Duration) time.Time {
+	return t.Truncate(interval).Add(interval)
+}
+
 func newLogFile(path string) (*os.File, error) {
 	if dir, err := os.Stat(path); err == nil {
 		if !dir.IsDir() {
@@ -141,16 +158,25 @@ func newLogFile(path string) (*os.File, error) {
 
 	return os.OpenFile(filepath.Join(path,
 		time.Now().Format("2006-01-02_15.04.05"))+".log",
-		os.O_RDWR|os.O_CREATE, 0640)
+		os.O_RDWR|os.O_CREATE|os.O_APPEND, 0640)
 }
 
 func NewFileWriter(path string, maxFileSize, maxFolderSize int64) *fileWriter {
+	return NewRotateFileWriter(path, maxFileSize, maxFolderSize, 0)
+}
+
+// NewRotateFileWriter creates a file writer which creates a new log file when
+// the current log file reaches the max file size or at each rotate interval,
+// and removes the oldest log file when the folder reaches the max folder size.
+func NewRotateFileWriter(path string, maxFileSize, maxFolderSize int64,
+	rotateInterval time.Duration) *fileWriter {
 	w := fileWriter{
-		path:          path,
-		maxFileSize:   defaultMaxFileSize,
-		maxFolderSize: defaultMaxFolderSize,
-		writeChan:     make(chan []byte, 1),
-		writeReply:    make(chan struct{}),
+		path:           path,
+		maxFileSize:    defaultMaxFileSize,
+		maxFolderSize:  defaultMaxFolderSize,
+		rotateInterval: rotateInterval,
+		writeChan:      make(chan []byte, 1),
+		writeReply:     make(chan struct{}),
 	}
 
 	if maxFolderSize < maxFileSize {
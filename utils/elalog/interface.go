@@ -54,4 +54,8 @@ type Logger interface {
 
 	// SetLevel changes the logging level to the passed level.
 	SetLevel(level Level)
+
+	// WithFields returns a logger attaching the structured fields to the log
+	// messages, which shares the level with the logger.
+	WithFields(fields ...Field) Logger
 }
@@ -6,12 +6,12 @@
 package elalog
 
 import (
-	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -27,6 +27,9 @@ const (
 	// Lshortfile modifies the logger output to include filename and line number
 	// of the logging callsite, e.g. main.go:123.  Overrides Llongfile.
 	Lshortfile
+
+	// LJSON modifies the logger output to JSON objects, one object per line.
+	LJSON
 )
 
 // Level is the level at which a logger is configured.  All messages sent
@@ -202,7 +205,8 @@ func callsite(flag uint32) (string, int) {
 // creating a prefix for the given level and tag according to the formatHeader
 // function and formatting the provided arguments using the default formatting
 // rules.
-func (b *Backend) print(lvl, tag string, args ...interface{}) {
+func (b *Backend) print(lvl Level, tag string, fields []Field,
+	args ...interface{}) {
 	t := time.Now() // get as early as possible
 
 	bytebuf := buffer()
@@ -213,14 +217,8 @@ func (b *Backend) print(lvl, tag string, args ...interface{}) {
 		file, line = callsite(b.flag)
 	}
 
-	formatHeader(bytebuf, t, lvl, tag, file, line)
-	buf := bytes.NewBuffer(*bytebuf)
-	fmt.Fprintln(buf, args...)
-	*bytebuf = buf.Bytes()
-
-	b.mu.Lock()
-	b.w.Write(*bytebuf)
-	b.mu.Unlock()
+	msg := fmt.Sprintln(args...)
+	b.write(bytebuf, t, lvl, tag, file, line, msg[:len(msg)-1], fields)
 
 	recycleBuffer(bytebuf)
 }
@@ -229,7 +227,8 @@ func (b *Backend) print(lvl, tag string, args ...interface{}) {
 // creating a prefix for the given level and tag according to the formatHeader
 // function and formatting the provided arguments according to the given format
 // specifier.
-func (b *Backend) printf(lvl, tag string, format string, args ...interface{}) {
+func (b *Backend) printf(lvl Level, tag string, fields []Field, format string,
+	args ...interface{}) {
 	t := time.Now() // get as early as possible
 
 	bytebuf := buffer()
@@ -240,30 +239,47 @@ func (b *Backend) printf(lvl, tag string, format string, args ...interface{}) {
 		file, line = callsite(b.flag)
 	}
 
-	formatHeader(bytebuf, t, lvl, tag, file, line)
-	buf := bytes.NewBuffer(*bytebuf)
-	fmt.Fprintf(buf, format, args...)
-	*bytebuf = append(buf.Bytes(), '\n')
+	b.write(bytebuf, t, lvl, tag, file, line, fmt.Sprintf(format, args...),
+		fields)
+
+	recycleBuffer(bytebuf)
+}
+
+// write formats the log message as a text line or a JSON object according to
+// the flags, and writes it to the writer.
+func (b *Backend) write(bytebuf *[]byte, t time.Time, lvl Level, tag string,
+	file string, line int, msg string, fields []Field) {
+	if b.flag&LJSON != 0 {
+		var caller string
+		if file != "" {
+			caller = file + ":" + strconv.Itoa(line)
+		}
+		*bytebuf = AppendJSON(*bytebuf, t, lvl, tag, caller, msg, fields)
+	} else {
+		formatHeader(bytebuf, t, lvl.String(), tag, file, line)
+		*bytebuf = append(*bytebuf, msg...)
+		*bytebuf = AppendText(*bytebuf, fields)
+	}
+	*bytebuf = append(*bytebuf, '\n')
 
 	b.mu.Lock()
 	b.w.Write(*bytebuf)
 	b.mu.Unlock()
-
-	recycleBuffer(bytebuf)
 }
 
 // Logger returns a new logger for a particular subsystem that writes to the
 // Backend b.  A tag describes the subsystem and is included in all log
 // messages.  The logger uses the info verbosity level by default.
 func (b *Backend) Logger(subsystemTag string, level Level) Logger {
-	return &slog{level, subsystemTag, b}
+	return &slog{lvl: &level, tag: subsystemTag, b: b}
 }
 
 // slog is a subsystem logger for a Backend.  Implements the Logger interface.
 type slog struct {
-	lvl Level // atomic
-	tag string
-	b   *Backend
+	lvl    *Level // atomic
+	tag    string
+	fields []Field
+	b      *Backend
 }
 
 // Debug formats message using the default formats for its operands, prepends
@@ -273,7 +289,7 @@ type slog struct {
 func (l *slog) Debug(args ...interface{}) {
 	lvl := l.Level()
 	if lvl <= LevelDebug {
-		l.b.print("DBG", l.tag, args...)
+		l.b.print(LevelDebug, l.tag, l.fields, args...)
 	}
 }
 
@@ -284,7 +300,7 @@ func (l *slog) Debug(args ...interface{}) {
 func (l *slog) Debugf(format string, args ...interface{}) {
 	lvl := l.Level()
 	if lvl <= LevelDebug {
-		l.b.printf("DBG", l.tag, format, args...)
+		l.b.printf(LevelDebug, l.tag, l.fields, format, args...)
 	}
 }
 
@@ -295,7 +311,7 @@ func (l *slog) Debugf(format string, args ...interface{}) {
 func (l *slog) Info(args ...interface{}) {
 	lvl := l.Level()
 	if lvl <= LevelInfo {
-		l.b.print("INF", l.tag, args...)
+		l.b.print(LevelInfo, l.tag, l.fields, args...)
 	}
 }
 
@@ -306,7 +322,7 @@ func (l *slog) Info(args ...interface{}) {
 func (l *slog) Infof(format string, args ...interface{}) {
 	lvl := l.Level()
 	if lvl <= LevelInfo {
-		l.b.printf("INF", l.tag, format, args...)
+		l.b.printf(LevelInfo, l.tag, l.fields, format, args...)
 	}
 }
 
@@ -317,7 +333,7 @@ func (l *slog) Infof(format string, args ...interface{}) {
 func (l *slog) Warn(args ...interface{}) {
 	lvl := l.Level()
 	if lvl <= LevelWarn {
-		l.b.print("WRN", l.tag, args...)
+		l.b.print(LevelWarn, l.tag, l.fields, args...)
 	}
 }
 
@@ -328,7 +344,7 @@ func (l *slog) Warn(args ...interface{}) {
 func (l *slog) Warnf(format string, args ...interface{}) {
 	lvl := l.Level()
 	if lvl <= LevelWarn {
-		l.b.printf("WRN", l.tag, format, args...)
+		l.b.printf(LevelWarn, l.tag, l.fields, format, args...)
 	}
 }
 
@@ -339,7 +355,7 @@ func (l *slog) Warnf(format string, args ...interface{}) {
 func (l *slog) Error(args ...interface{}) {
 	lvl := l.Level()
 	if lvl <= LevelError {
-		l.b.print("ERR", l.tag, args...)
+		l.b.print(LevelError, l.tag, l.fields, args...)
 	}
 }
 
@@ -350,7 +366,7 @@ func (l *slog) Error(args ...interface{}) {
 func (l *slog) Errorf(format string, args ...interface{}) {
 	lvl := l.Level()
 	if lvl <= LevelError {
-		l.b.printf("ERR", l.tag, format, args...)
+		l.b.printf(LevelError, l.tag, l.fields, format, args...)
 	}
 }
 
@@ -361,7 +377,7 @@ func (l *slog) Errorf(format string, args ...interface{}) {
 func (l *slog) Fatal(args ...interface{}) {
 	lvl := l.Level()
 	if lvl <= LevelFatal {
-		l.b.print("FAT", l.tag, args...)
+		l.b.print(LevelFatal, l.tag, l.fields, args...)
 	}
 }
 
@@ -372,7 +388,7 @@ func (l *slog) Fatal(args ...interface{}) {
 func (l *slog) Fatalf(format string, args ...interface{}) {
 	lvl := l.Level()
 	if lvl <= LevelFatal {
-		l.b.printf("FAT", l.tag, format, args...)
+		l.b.printf(LevelFatal, l.tag, l.fields, format, args...)
 	}
 }
 
@@ -380,19 +396,33 @@ func (l *slog) Fatalf(format string, args ...interface{}) {
 //
 // This is part of the Logger interface implementation.
 func (l *slog) Level() Level {
-	return Level(atomic.LoadUint32((*uint32)(&l.lvl)))
+	return Level(atomic.LoadUint32((*uint32)(l.lvl)))
 }
 
 // SetLevel changes the logging level to the passed level.
 //
 // This is part of the Logger interface implementation.
 func (l *slog) SetLevel(level Level) {
-	atomic.StoreUint32((*uint32)(&l.lvl), uint32(level))
+	atomic.StoreUint32((*uint32)(l.lvl), uint32(level))
+}
+
+// WithFields returns a logger attaching the structured fields to the log
+// messages, which shares the level with the logger.
+//
+// This is part of the Logger interface implementation.
+func (l *slog) WithFields(fields ...Field) Logger {
+	return &slog{
+		lvl:    l.lvl,
+		tag:    l.tag,
+		fields: append(append([]Field(nil), l.fields...), fields...),
+		b:      l.b,
+	}
 }
 
 // Disabled is a Logger that will never output anything.
 var Disabled Logger
 
 func init() {
-	Disabled = &slog{lvl: LevelOff, b: NewBackend(ioutil.Discard)}
+	level := LevelOff
+	Disabled = &slog{lvl: &level, b: NewBackend(ioutil.Discard)}
 }
@@ -0,0 +1,135 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package elalog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/common"
+)
+
+func TestBackend_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewBackend(&buf, LJSON).Logger("blockchain", LevelInfo)
+
+	log.Debug("not written")
+	log.WithFields(Height(100), TxHash(common.Uint256{1}), ErrCode(45010),
+		Peer("127.0.0.1:20338"), Field{Key: "err", Value: errors.New("bad")}).
+		Warnf("reject tx \"%d\"", 1)
+	log.Info("connected", 2)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), buf.String())
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &m); err != nil {
+		t.Fatalf("invalid JSON %s: %v", lines[0], err)
+	}
+	if _, err := time.Parse(jsonTimeFormat, m[FieldTime].(string)); err != nil {
+		t.Errorf("invalid time %v: %v", m[FieldTime], err)
+	}
+	want := map[string]interface{}{
+		FieldLevel:     "warn",
+		FieldSubsystem: "blockchain",
+		FieldMessage:   "reject tx \"1\"",
+		FieldHeight:    float64(100),
+		FieldTxHash:    common.Uint256{1}.String(),
+		FieldErrCode:   float64(45010),
+		FieldPeer:      "127.0.0.1:20338",
+		"err":          "bad",
+	}
+	for k, v := range want {
+		if m[k] != v {
+			t.Errorf("field %s: got %v, want %v", k, m[k], v)
+		}
+	}
+
+	m = nil
+	if err := json.Unmarshal([]byte(lines[1]), &m); err != nil {
+		t.Fatalf("invalid JSON %s: %v", lines[1], err)
+	}
+	if m[FieldMessage] != "connected 2" || m[FieldHeight] != nil {
+		t.Errorf("unexpected message %s", lines[1])
+	}
+}
+
+func TestBackend_Text(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewBackend(&buf).Logger("MEMP", LevelDebug)
+	log.WithFields(Height(7)).Infof("added %d", 3)
+
+	line := buf.String()
+	if !strings.HasSuffix(line, " [INF] MEMP: added 3 height=7\n") {
+		t.Errorf("unexpected line %q", line)
+	}
+}
+
+func TestSlog_WithFields(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewBackend(&buf).Logger("PEER", LevelInfo)
+	plog := log.WithFields(Peer("a"))
+
+	// the logger with fields shares the level.
+	log.SetLevel(LevelError)
+	plog.Info("filtered")
+	if plog.Level() != LevelError || buf.Len() != 0 {
+		t.Errorf("level is not shared, got %s", buf.String())
+	}
+}
+
+func TestFormatFromString(t *testing.T) {
+	for s, want := range map[string]Format{
+		"": FormatText, "text": FormatText, "JSON": FormatJSON,
+	} {
+		if f, ok := FormatFromString(s); !ok || f != want {
+			t.Errorf("FormatFromString(%s): got %s %v", s, f, ok)
+		}
+	}
+	if _, ok := FormatFromString("xml"); ok {
+		t.Errorf("FormatFromString(xml): expected false")
+	}
+}
+
+func TestFileWriter_Rotate(t *testing.T) {
+	now := time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC)
+	if next := nextRotateTime(now, time.Hour); !next.Equal(
+		time.Date(2020, 1, 2, 16, 0, 0, 0, time.UTC)) {
+		t.Errorf("hourly: got %v", next)
+	}
+	if next := nextRotateTime(now, 24*time.Hour); !next.Equal(
+		time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("daily: got %v", next)
+	}
+
+	dir, err := ioutil.TempDir("", "elalog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// log files are named by seconds, so rotate each second.
+	w := NewRotateFileWriter(dir, MBSize, GBSize, time.Second)
+	w.Write([]byte("first\n"))
+	time.Sleep(time.Until(nextRotateTime(time.Now(), time.Second)))
+	w.Write([]byte("second\n"))
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("got %d log files, want 2", len(files))
+	}
+}
@@ -0,0 +1,436 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+// Package metrics provides counters, gauges and histograms of the node, and
+// exports them in the Prometheus text format.
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Type is the type of a metric.
+type Type string
+
+const (
+	TypeCounter   Type = "counter"
+	TypeGauge     Type = "gauge"
+	TypeHistogram Type = "histogram"
+)
+
+// DefaultBuckets are the default histogram buckets in seconds, which cover
+// the durations from 5 milliseconds to 10 seconds.
+var DefaultBuckets = []float64{
+	.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10,
+}
+
+// Metric is a family of metric series sharing the same name, help and label
+// names.
+type Metric interface {
+	// Name returns the metric name.
+	Name() string
+
+	// write writes the metric in the Prometheus text format.
+	write(b *strings.Builder)
+}
+
+// desc describes a metric family.
+type desc struct {
+	name   string
+	help   string
+	typ    Type
+	labels []string
+}
+
+func (d *desc) Name() string {
+	return d.name
+}
+
+func (d *desc) labelNames() []string {
+	return d.labels
+}
+
+func (d *desc) writeHeader(b *strings.Builder) {
+	fmt.Fprintf(b, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+	fmt.Fprintf(b, "# TYPE %s %s\n", d.name, d.typ)
+}
+
+// key returns the key of the series identified by the label values, and
+// panics if the count of label values does not match the label names.
+func (d *desc) key(labelValues []string) string {
+	if len(labelValues) != len(d.labels) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d",
+			d.name, len(d.labels), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+// series is the value of a metric with a set of label values.
+type series struct {
+	labelValues []string
+	value       float64
+}
+
+// values holds the series of a counter or gauge.
+type values struct {
+	desc
+	mtx    sync.Mutex
+	series map[string]*series
+}
+
+func (v *values) add(delta float64, labelValues []string) {
+	key := v.key(labelValues)
+	v.mtx.Lock()
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		v.series[key] = s
+	}
+	s.value += delta
+	v.mtx.Unlock()
+}
+
+func (v *values) set(value float64, labelValues []string) {
+	key := v.key(labelValues)
+	v.mtx.Lock()
+	v.series[key] = &series{
+		labelValues: append([]string(nil), labelValues...),
+		value:       value,
+	}
+	v.mtx.Unlock()
+}
+
+func (v *values) get(labelValues []string) float64 {
+	key := v.key(labelValues)
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	if s, ok := v.series[key]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (v *values) write(b *strings.Builder) {
+	v.mtx.Lock()
+	samples := make([]Sample, 0, len(v.series))
+	for _, s := range v.series {
+		samples = append(samples, Sample{
+			LabelValues: s.labelValues,
+			Value:       s.value,
+		})
+	}
+	v.mtx.Unlock()
+
+	v.writeHeader(b)
+	writeSamples(b, v.name, v.labels, samples)
+}
+
+// Counter is a metric whose value only goes up, such as the count of
+// processed blocks.
+type Counter struct {
+	values
+}
+
+// NewCounter creates a counter with the given label names.
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{values{
+		desc:   desc{name: name, help: help, typ: TypeCounter, labels: labels},
+		series: make(map[string]*series),
+	}}
+}
+
+// Inc increases the series of the label values by one.
+func (c *Counter) Inc(labelValues ...string) {
+	c.add(1, labelValues)
+}
+
+// Add increases the series of the label values by delta, which must not be
+// negative.
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic(fmt.Sprintf("counter %s cannot decrease", c.name))
+	}
+	c.add(delta, labelValues)
+}
+
+// Value returns the value of the series of the label values.
+func (c *Counter) Value(labelValues ...string) float64 {
+	return c.get(labelValues)
+}
+
+// Gauge is a metric whose value goes up and down, such as the best height.
+type Gauge struct {
+	values
+}
+
+// NewGauge creates a gauge with the given label names.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{values{
+		desc:   desc{name: name, help: help, typ: TypeGauge, labels: labels},
+		series: make(map[string]*series),
+	}}
+}
+
+// Set sets the series of the label values to value.
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.set(value, labelValues)
+}
+
+// Add adds delta to the series of the label values.
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	g.add(delta, labelValues)
+}
+
+// Value returns the value of the series of the label values.
+func (g *Gauge) Value(labelValues ...string) float64 {
+	return g.get(labelValues)
+}
+
+// histogramSeries is the observations of a histogram with a set of label
+// values.
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+// Histogram is a metric counting observations in buckets, such as the
+// durations of processing blocks.
+type Histogram struct {
+	desc
+	buckets []float64
+
+	mtx    sync.Mutex
+	series map[string]*histogramSeries
+}
+
+// NewHistogram creates a histogram with the given upper bounds of buckets
+// and label names, DefaultBuckets is used if buckets is empty.
+func NewHistogram(name, help string, buckets []float64,
+	labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	if math.IsInf(buckets[len(buckets)-1], 1) {
+		buckets = buckets[:len(buckets)-1]
+	}
+	return &Histogram{
+		desc:    desc{name: name, help: help, typ: TypeHistogram, labels: labels},
+		buckets: buckets,
+		series:  make(map[string]*histogramSeries),
+	}
+}
+
+// Observe adds an observation to the series of the label values.
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{
+			labelValues: append([]string(nil), labelValues...),
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.series[key] = s
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+// ObserveDuration adds the duration since start in seconds to the series of
+// the label values.
+func (h *Histogram) ObserveDuration(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+// Count returns the count of observations of the series of the label values.
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key := h.key(labelValues)
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if s, ok := h.series[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(b *strings.Builder) {
+	h.mtx.Lock()
+	series := make([]histogramSeries, 0, len(h.series))
+	for _, s := range h.series {
+		c := *s
+		c.counts = append([]uint64(nil), s.counts...)
+		series = append(series, c)
+	}
+	h.mtx.Unlock()
+
+	sort.Slice(series, func(i, j int) bool {
+		return lessLabelValues(series[i].labelValues, series[j].labelValues)
+	})
+	labels := append(append([]string(nil), h.labels...), "le")
+	h.writeHeader(b)
+	for _, s := range series {
+		samples := make([]Sample, 0, len(h.buckets)+1)
+		for i, bound := range h.buckets {
+			samples = append(samples, Sample{
+				LabelValues: append(append([]string(nil), s.labelValues...),
+					formatFloat(bound)),
+				Value: float64(s.counts[i]),
+			})
+		}
+		samples = append(samples, Sample{
+			LabelValues: append(append([]string(nil), s.labelValues...), "+Inf"),
+			Value:       float64(s.count),
+		})
+		writeOrderedSamples(b, h.name+"_bucket", labels, samples)
+		writeOrderedSamples(b, h.name+"_sum", h.labels,
+			[]Sample{{LabelValues: s.labelValues, Value: s.sum}})
+		writeOrderedSamples(b, h.name+"_count", h.labels,
+			[]Sample{{LabelValues: s.labelValues, Value: float64(s.count)}})
+	}
+}
+
+// Sample is a value of a metric with a set of label values, collected when
+// the metrics are scraped.
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// Collector is a counter or gauge whose samples are collected by a function
+// when the metrics are scraped, such as the count of connected peers.
+type Collector struct {
+	desc
+	collect func() []Sample
+}
+
+// NewCollector creates a collector of the given type, which must be
+// TypeCounter or TypeGauge.
+func NewCollector(name, help string, typ Type, labels []string,
+	collect func() []Sample) *Collector {
+	if typ != TypeCounter && typ != TypeGauge {
+		panic(fmt.Sprintf("collector %s cannot be a %s", name, typ))
+	}
+	return &Collector{
+		desc:    desc{name: name, help: help, typ: typ, labels: labels},
+		collect: collect,
+	}
+}
+
+// NewGaugeFunc creates a gauge without labels whose value is returned by fn.
+func NewGaugeFunc(name, help string, fn func() float64) *Collector {
+	return NewCollector(name, help, TypeGauge, nil, func() []Sample {
+		return []Sample{{Value: fn()}}
+	})
+}
+
+func (c *Collector) write(b *strings.Builder) {
+	samples := c.collect()
+	for _, s := range samples {
+		c.key(s.LabelValues)
+	}
+	c.writeHeader(b)
+	writeSamples(b, c.name, c.labels, samples)
+}
+
+// ErrInvalidName is returned when registering a metric with an invalid name.
+var ErrInvalidName = errors.New("invalid metric name")
+
+// validName returns whether the name is a valid metric or label name.
+func validName(name string, label bool) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z':
+		case r == ':' && !label:
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// writeSamples writes the samples sorted by label values.
+func writeSamples(b *strings.Builder, name string, labels []string,
+	samples []Sample) {
+	sort.Slice(samples, func(i, j int) bool {
+		return lessLabelValues(samples[i].LabelValues, samples[j].LabelValues)
+	})
+	writeOrderedSamples(b, name, labels, samples)
+}
+
+func writeOrderedSamples(b *strings.Builder, name string, labels []string,
+	samples []Sample) {
+	for _, s := range samples {
+		b.WriteString(name)
+		if len(labels) > 0 {
+			b.WriteByte('{')
+			for i, label := range labels {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				fmt.Fprintf(b, "%s=\"%s\"", label,
+					escapeLabelValue(s.LabelValues[i]))
+			}
+			b.WriteByte('}')
+		}
+		b.WriteByte(' ')
+		b.WriteString(formatFloat(s.Value))
+		b.WriteByte('\n')
+	}
+}
+
+func lessLabelValues(a, b []string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return fmt.Sprint(f)
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpReplacer.Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return labelReplacer.Replace(value)
+}
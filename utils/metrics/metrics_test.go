@@ -0,0 +1,135 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func scrape(t *testing.T, r *Registry) string {
+	var b strings.Builder
+	_, err := r.WriteTo(&b)
+	assert.NoError(t, err)
+	return b.String()
+}
+
+func TestRegistry_WriteTo(t *testing.T) {
+	r := NewRegistry()
+	evictions := NewCounter("test_evictions_total", "Evicted transactions.",
+		"reason")
+	height := NewGauge("test_height", "Best height.")
+	duration := NewHistogram("test_duration_seconds", "Duration.",
+		[]float64{1, 0.1})
+	peers := NewCollector("test_peers", "Connected peers.", TypeGauge,
+		[]string{"direction"}, func() []Sample {
+			return []Sample{
+				{LabelValues: []string{"outbound"}, Value: 8},
+				{LabelValues: []string{"inbound"}, Value: 2},
+			}
+		})
+	assert.NoError(t, r.Register(evictions, height, duration, peers))
+
+	evictions.Inc("replaced")
+	evictions.Add(2, "capacity")
+	evictions.Inc("capacity")
+	height.Set(100)
+	height.Add(1)
+	duration.Observe(0.05)
+	duration.Observe(0.5)
+	duration.Observe(5)
+
+	assert.Equal(t, float64(3), evictions.Value("capacity"))
+	assert.Equal(t, float64(101), height.Value())
+	assert.Equal(t, uint64(3), duration.Count())
+	assert.Equal(t, `# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="0.1"} 1
+test_duration_seconds_bucket{le="1"} 2
+test_duration_seconds_bucket{le="+Inf"} 3
+test_duration_seconds_sum 5.55
+test_duration_seconds_count 3
+# HELP test_evictions_total Evicted transactions.
+# TYPE test_evictions_total counter
+test_evictions_total{reason="capacity"} 3
+test_evictions_total{reason="replaced"} 1
+# HELP test_height Best height.
+# TYPE test_height gauge
+test_height 101
+# HELP test_peers Connected peers.
+# TYPE test_peers gauge
+test_peers{direction="inbound"} 2
+test_peers{direction="outbound"} 8
+`, scrape(t, r))
+
+	// the metric of the same name is replaced
+	assert.NoError(t, r.Register(NewGauge("test_height", "Best height.")))
+	r.Unregister("test_duration_seconds")
+	r.Unregister("test_evictions_total")
+	r.Unregister("test_peers")
+	assert.Equal(t, `# HELP test_height Best height.
+# TYPE test_height gauge
+`, scrape(t, r))
+}
+
+func TestRegistry_Register(t *testing.T) {
+	r := NewRegistry()
+	assert.Equal(t, ErrInvalidName, r.Register(NewGauge("", "")))
+	assert.Equal(t, ErrInvalidName, r.Register(NewGauge("0height", "")))
+	assert.Equal(t, ErrInvalidName, r.Register(NewGauge("ela-height", "")))
+	assert.Equal(t, ErrInvalidName, r.Register(NewGauge("height", "", "a:b")))
+	assert.Equal(t, ErrInvalidName, r.Register(
+		NewHistogram("duration", "", nil, "le")))
+	assert.NoError(t, r.Register(NewGauge("ela:height", "", "_peer1")))
+
+	assert.Panics(t, func() {
+		NewGauge("height", "", "peer").Set(1)
+	})
+	assert.Panics(t, func() {
+		NewCounter("count", "").Add(-1)
+	})
+}
+
+func TestEscape(t *testing.T) {
+	r := NewRegistry()
+	g := NewGauge("test_peer_ban_score", "Ban score of \\peers\nconnected.",
+		"addr")
+	assert.NoError(t, r.Register(g))
+	g.Set(1.5, "\"quoted\"\\\n")
+	assert.Equal(t, `# HELP test_peer_ban_score Ban score of \\peers\nconnected.
+# TYPE test_peer_ban_score gauge
+test_peer_ban_score{addr="\"quoted\"\\\n"} 1.5
+`, scrape(t, r))
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	r := NewRegistry()
+	c := NewCounter("test_requests_total", "Requests.")
+	c.Inc()
+	assert.NoError(t, r.Register(c))
+
+	server := httptest.NewServer(r.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, ContentType, resp.Header.Get("Content-Type"))
+	assert.Contains(t, string(body), "test_requests_total 1\n")
+
+	resp, err = http.Post(server.URL, "text/plain", nil)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2017-2020 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package metrics
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContentType is the content type of the Prometheus text format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Registry holds the metrics to be exported.
+type Registry struct {
+	mtx     sync.RWMutex
+	metrics map[string]Metric
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]Metric)}
+}
+
+// Register adds the metrics to the registry, a registered metric of the
+// same name is replaced.
+func (r *Registry) Register(metrics ...Metric) error {
+	for _, m := range metrics {
+		if !validName(m.Name(), false) {
+			return ErrInvalidName
+		}
+		if d, ok := m.(interface{ labelNames() []string }); ok {
+			for _, label := range d.labelNames() {
+				if !validName(label, true) || label == "le" {
+					return ErrInvalidName
+				}
+			}
+		}
+	}
+
+	r.mtx.Lock()
+	for _, m := range metrics {
+		r.metrics[m.Name()] = m
+	}
+	r.mtx.Unlock()
+	return nil
+}
+
+// Unregister removes the metric of the name from the registry.
+func (r *Registry) Unregister(name string) {
+	r.mtx.Lock()
+	delete(r.metrics, name)
+	r.mtx.Unlock()
+}
+
+// WriteTo writes all registered metrics sorted by name in the Prometheus
+// text format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mtx.RLock()
+	metrics := make([]Metric, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		metrics = append(metrics, m)
+	}
+	r.mtx.RUnlock()
+
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].Name() < metrics[j].Name()
+	})
+	var b strings.Builder
+	for _, m := range metrics {
+		m.write(&b)
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Handler returns the HTTP handler exporting the registered metrics.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed),
+				http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteTo(w)
+	})
+}
+
+// DefaultRegistry is the registry of the metrics of the node.
+var DefaultRegistry = NewRegistry()
+
+// Register adds the metrics to the default registry, and panics if the name
+// of a metric or label is invalid.
+func Register(metrics ...Metric) {
+	if err := DefaultRegistry.Register(metrics...); err != nil {
+		panic(err)
+	}
+}
+
+// Unregister removes the metric of the name from the default registry.
+func Unregister(name string) {
+	DefaultRegistry.Unregister(name)
+}
+
+// Serve exports the metrics of the default registry on the /metrics path of
+// the host and port, it only returns on error.
+func Serve(host string, port uint32) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", DefaultRegistry.Handler())
+	server := &http.Server{
+		Addr: net.JoinHostPort(host,
+			strconv.FormatUint(uint64(port), 10)),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return server.ListenAndServe()
+}